@@ -13,19 +13,24 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/athena-dhcpd/athena-dhcpd/internal/anomaly"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/api"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/audit"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/conflict"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/dbconfig"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/dhcp"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/dhcp6"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/dnsproxy"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/fingerprint"
@@ -36,6 +41,7 @@ import (
 	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/portauto"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/radius"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/rogue"
 	syslogfwd "github.com/athena-dhcpd/athena-dhcpd/internal/syslog"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/topology"
@@ -46,8 +52,24 @@ import (
 func main() {
 	configPath := flag.String("config", "/etc/athena-dhcpd/config.toml", "path to configuration file")
 	debugPort := flag.String("debug-port", "", "enable pprof debug server on this port (e.g. 6060)")
+	witnessAddr := flag.String("witness", "", "run only as an HA split-brain witness listening on this address (e.g. :8069)")
+	migrateFrom := flag.String("migrate-leases", "", "copy leases from this backend:path (e.g. boltdb:/var/lib/athena-dhcpd/leases.db) into the configured lease backend and exit (server must be stopped)")
+	restorePath := flag.String("restore", "", "validate this database snapshot, replace the lease database with it and exit (server must be stopped)")
 	flag.Parse()
 
+	if *witnessAddr != "" {
+		runWitness(*witnessAddr)
+		return
+	}
+	if *restorePath != "" {
+		runRestore(*configPath, *restorePath)
+		return
+	}
+	if *migrateFrom != "" {
+		runMigrateLeases(*configPath, *migrateFrom)
+		return
+	}
+
 	// Start pprof debug server if requested
 	if *debugPort != "" {
 		runtime.SetMutexProfileFraction(5)
@@ -96,14 +118,22 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialize lease store (BoltDB)
-	store, err := lease.NewStore(bootstrap.Server.LeaseDB)
+	// Initialize lease store (BoltDB, or SQL with the rest in BoltDB)
+	store, err := lease.Open(bootstrap.Server)
 	if err != nil {
 		logger.Error("failed to open lease database", "error", err)
 		os.Exit(1)
 	}
 	defer store.Close()
-	logger.Info("lease database opened", "path", bootstrap.Server.LeaseDB, "lease_count", store.Count())
+	logger.Info("lease database opened",
+		"backend", bootstrap.Server.LeaseBackend,
+		"path", bootstrap.Server.LeaseDB,
+		"lease_count", store.Count())
+
+	// Scheduled compaction, snapshots and size metrics
+	dbMaint := boltdb.NewMaintainer(store.DB(), bootstrap.Server.Database, lease.DBCheck(bootstrap.Server), logger)
+	dbMaint.Start(ctx)
+	defer dbMaint.Stop()
 
 	// Initialize config store (dynamic config in BoltDB)
 	cfgStore, err := dbconfig.NewStore(store.DB())
@@ -182,7 +212,7 @@ func main() {
 	var earlyHAFSM *ha.FSM
 	var earlyBus *events.Bus
 
-	if bootstrap.HA.Enabled && bootstrap.HA.Role == "secondary" {
+	if bootstrap.HA.Enabled && bootstrap.HA.Role == "secondary" && !bootstrap.HA.Cluster() {
 		logger.Info("secondary node — connecting to primary before starting services",
 			"peer", bootstrap.HA.PeerAddress,
 			"listen", bootstrap.HA.ListenAddress)
@@ -195,11 +225,23 @@ func main() {
 			failoverTimeout = 10 * time.Second
 		}
 		earlyHAFSM = ha.NewFSM(bootstrap.HA.Role, failoverTimeout, earlyBus, logger)
+		earlyHAFSM.SetMode(bootstrap.HA.Mode)
+		startSplitBrainGuard(ctx, &bootstrap.HA, earlyHAFSM, logger)
 		peer, err := ha.NewPeer(&bootstrap.HA, earlyHAFSM, store, earlyBus, logger)
 		if err != nil {
 			logger.Error("failed to create HA peer", "error", err)
 			os.Exit(1)
 		}
+		var lb *ha.LoadBalancer
+		if bootstrap.HA.LoadBalancing() {
+			lb = ha.NewLoadBalancer(&bootstrap.HA, earlyHAFSM)
+		}
+
+		// Lease manager exists before config arrives so leases from the
+		// primary are stored while we wait; the DHCP handler joins once built.
+		leaseMgr := lease.NewManager(store, cfgStore.BuildConfig(bootstrap), earlyBus, logger)
+		var peerHandler atomic.Pointer[dhcp.Handler]
+		wireLeaseSync(peer, leaseMgr, peerHandler.Load, lb, logger)
 
 		configReady := make(chan struct{}, 1)
 
@@ -217,7 +259,7 @@ func main() {
 		})
 
 		peer.OnAdjacencyFormed(func() {
-			if !peer.FSM().IsActive() {
+			if !peer.FSM().IsConfigSource() {
 				logger.Info("HA adjacency formed — waiting for config from primary")
 				return
 			}
@@ -290,8 +332,7 @@ func main() {
 		cfg := cfgStore.BuildConfig(bootstrap)
 		config.ApplyDynamicDefaults(cfg)
 
-		// Lease manager needed for receiving lease syncs from primary
-		leaseMgr := lease.NewManager(store, cfg, earlyBus, logger)
+		leaseMgr.UpdateConfig(cfg)
 		leaseMgr.StartGC(ctx, 60*time.Second)
 
 		// Pools + handler ready but NOT serving yet
@@ -302,16 +343,32 @@ func main() {
 		}
 		handler := dhcp.NewHandler(cfg, leaseMgr, pools, nil, earlyBus, logger)
 		handler.SetHA(earlyHAFSM)
+		if bootstrap.HA.SyncAck {
+			handler.SetPeerSync(earlyHAPeer)
+		}
+		peerHandler.Store(handler)
+		radiusClient := radius.NewClient(logger)
+		loadRADIUS(cfgStore, radiusClient, logger)
+		handler.SetRADIUS(radiusClient)
 
 		// Server group created but NOT started — waits for failover
 		serverGroup := dhcp.NewServerGroup(handler, logger)
+		handler6 := dhcp6.NewHandler(cfg, leaseMgr, logger)
+		handler6.SetHA(earlyHAFSM)
+		if lb != nil {
+			handler.SetLoadBalancer(lb)
+			handler6.SetLoadBalancer(lb)
+		}
+		serverGroup6 := dhcp6.NewServerGroup(handler6, logger)
 
 		// Mutable service state protected by mutex — started/stopped on failover
 		var (
 			svcMu      sync.Mutex
 			svcRunning bool
 			svcDNS     *dnsproxy.Server
+			svcDNSLog  *dnsQueryLog
 			svcRogue   *rogue.Detector
+			svcBulkLQ  *dhcp.BulkLeaseQueryServer
 		)
 
 		startActiveServices := func() {
@@ -326,6 +383,10 @@ func main() {
 			if err := serverGroup.Start(ctx, cfg); err != nil {
 				logger.Error("failed to start DHCP server on failover", "error", err)
 			}
+			if err := serverGroup6.Start(ctx, cfg); err != nil {
+				logger.Error("failed to start DHCPv6 server on failover", "error", err)
+			}
+			svcBulkLQ = syncBulkLeaseQuery(ctx, nil, handler, cfg, logger)
 
 			if cfg.ConflictDetection.Enabled {
 				det, detErr := initConflictDetection(cfg, store, earlyBus, logger)
@@ -367,10 +428,14 @@ func main() {
 					logger.Error("failed to start DNS proxy on failover", "error", dnsErr)
 					svcDNS = nil
 				} else {
+					svcDNS.SetReservations(cfg.Subnets)
+					svcDNS.SetEventBus(earlyBus)
+					svcDNSLog = syncDNSQueryLog(nil, svcDNS, cfg, logger)
+
 					// Populate device mapper from existing leases
 					dm := svcDNS.DeviceMap()
 					for _, l := range store.All() {
-						if l.State == "active" {
+						if l.State == "active" && !l.IsPrefix() {
 							if l.Hostname != "" && cfg.DNS.RegisterLeases {
 								svcDNS.RegisterLease(l.Hostname, l.IP)
 							}
@@ -395,10 +460,19 @@ func main() {
 			svcRunning = false
 			logger.Warn("returning to STANDBY — stopping active services")
 			serverGroup.Stop()
+			serverGroup6.Stop()
+			if svcBulkLQ != nil {
+				svcBulkLQ.Stop()
+				svcBulkLQ = nil
+			}
 			if svcDNS != nil {
 				svcDNS.Stop()
 				svcDNS = nil
 			}
+			if svcDNSLog != nil {
+				svcDNSLog.close()
+				svcDNSLog = nil
+			}
 			if svcRogue != nil {
 				svcRogue.Stop()
 				svcRogue = nil
@@ -408,14 +482,19 @@ func main() {
 
 		// FSM callback — start/stop services on failover transitions
 		earlyHAFSM.OnStateChange(func(oldState, newState dhcpv4.HAState) {
-			isNowActive := newState == dhcpv4.HAStateActive || newState == dhcpv4.HAStatePartnerDown
-			wasActive := oldState == dhcpv4.HAStateActive || oldState == dhcpv4.HAStatePartnerDown
+			isNowActive := ha.IsServing(newState)
+			wasActive := ha.IsServing(oldState)
 			if isNowActive && !wasActive {
 				go startActiveServices()
 			} else if !isNowActive && wasActive {
 				go stopActiveServices()
 			}
 		})
+		// The FSM may already be serving — load balancing starts as soon
+		// as the first bulk sync completes, often before config arrives.
+		if earlyHAFSM.IsActive() {
+			go startActiveServices()
+		}
 
 		// Start full API server for monitoring
 		var allPools []*pool.Pool
@@ -427,6 +506,7 @@ func main() {
 			api.WithConfigStore(cfgStore),
 			api.WithFSM(earlyHAFSM),
 			api.WithPeer(earlyHAPeer),
+			api.WithRADIUSClient(radiusClient),
 		}
 		apiServer := api.NewServer(cfg, store, leaseMgr, nil, allPools, earlyBus, logger, apiOpts...)
 		go func() {
@@ -456,6 +536,13 @@ func main() {
 				return
 			}
 			handler.UpdatePools(newPools)
+			serverGroup6.Reload(cfg)
+			svcMu.Lock()
+			if svcRunning {
+				svcBulkLQ = syncBulkLeaseQuery(ctx, svcBulkLQ, handler, cfg, logger)
+			}
+			svcMu.Unlock()
+			loadRADIUS(cfgStore, radiusClient, logger)
 			if apiServer != nil {
 				apiServer.UpdateConfig(cfg)
 				var ap []*pool.Pool
@@ -503,6 +590,12 @@ func main() {
 					continue
 				}
 				handler.UpdatePools(newPools)
+				serverGroup6.Reload(cfg)
+				svcMu.Lock()
+				if svcRunning {
+					svcBulkLQ = syncBulkLeaseQuery(ctx, svcBulkLQ, handler, cfg, logger)
+				}
+				svcMu.Unlock()
 				logger.Info("configuration reloaded successfully")
 
 			case syscall.SIGINT, syscall.SIGTERM:
@@ -567,6 +660,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Create and start DHCPv6 server group — listens only once a subnet6 exists
+	handler6 := dhcp6.NewHandler(cfg, leaseMgr, logger)
+	serverGroup6 := dhcp6.NewServerGroup(handler6, logger)
+	if err := serverGroup6.Start(ctx, cfg); err != nil {
+		logger.Error("failed to start DHCPv6 server", "error", err)
+		os.Exit(1)
+	}
+
+	// Start bulk leasequery TCP listener if enabled
+	bulkLQ := syncBulkLeaseQuery(ctx, nil, handler, cfg, logger)
+
 	// Set server metrics
 	metrics.ServerStartTime.SetToCurrentTime()
 	metrics.ServerInfo.WithLabelValues("dev").Set(1)
@@ -613,12 +717,17 @@ func main() {
 
 	// Initialize DNS proxy
 	var dnsServer *dnsproxy.Server
+	var dnsLog *dnsQueryLog
 	if cfg.DNS.Enabled {
 		dnsServer = dnsproxy.NewServer(&cfg.DNS, logger)
 		if err := dnsServer.Start(ctx); err != nil {
 			logger.Error("failed to start DNS proxy", "error", err)
 			// Non-fatal — DHCP still works
 		} else {
+			dnsServer.SetReservations(cfg.Subnets)
+			dnsServer.SetEventBus(bus)
+			dnsLog = syncDNSQueryLog(nil, dnsServer, cfg, logger)
+
 			// Subscribe to lease events for DNS registration
 			dnsEventCh := bus.Subscribe(1000)
 			dnsServer.SubscribeToEvents(ctx, dnsEventCh)
@@ -626,7 +735,7 @@ func main() {
 			// Register existing leases for DNS zone + device mapping
 			deviceMap := dnsServer.DeviceMap()
 			for _, l := range store.All() {
-				if l.State == "active" {
+				if l.State == "active" && !l.IsPrefix() {
 					if l.Hostname != "" && cfg.DNS.RegisterLeases {
 						dnsServer.RegisterLease(l.Hostname, l.IP)
 					}
@@ -644,18 +753,87 @@ func main() {
 
 	// Initialize HA peer with config sync (before API so status is available)
 	var haPeer *ha.Peer
+	var haCluster *ha.Cluster
 	var haFSM *ha.FSM
-	if cfg.HA.Enabled {
+	if cfg.HA.Enabled && cfg.HA.Cluster() {
+		// Cluster mode — every member starts in standby until elected
+		failoverTimeout, _ := time.ParseDuration(cfg.HA.FailoverTimeout)
+		if failoverTimeout == 0 {
+			failoverTimeout = 10 * time.Second
+		}
+		haFSM = ha.NewFSM("member", failoverTimeout, bus, logger)
+		haFSM.SetMode(cfg.HA.Mode)
+		cluster, err := ha.NewCluster(&cfg.HA, haFSM, store, bus, logger)
+		if err != nil {
+			logger.Error("failed to create HA cluster", "error", err)
+		} else {
+			wireLeaseSync(cluster, leaseMgr, func() *dhcp.Handler { return handler }, nil, logger)
+
+			cluster.OnConfigSync(func(cs ha.ConfigSyncPayload) {
+				if err := cfgStore.ApplyPeerConfig(cs.Section, cs.Data); err != nil {
+					logger.Error("failed to apply config from cluster member",
+						"section", cs.Section, "error", err)
+				} else {
+					logger.Info("applied config from cluster member", "section", cs.Section)
+				}
+			})
+
+			// The leader pushes its full config to every member that connects
+			cluster.OnAdjacencyFormed(func(link *ha.Peer) {
+				if !cluster.IsLeader() {
+					return
+				}
+				sections := cfgStore.ExportAllSections()
+				if err := link.SendFullConfigSync(sections); err != nil {
+					logger.Error("failed to push full config to cluster member", "error", err)
+				} else {
+					logger.Info("full config sync to cluster member complete", "sections", len(sections))
+				}
+			})
+
+			// Membership changes made through the API are kept across restarts
+			cluster.OnMembership(func(members []config.HAMember) {
+				haCfg := cfg.HA
+				haCfg.Members = members
+				if err := config.WriteHASection(*configPath, &haCfg); err != nil {
+					logger.Error("failed to write HA cluster members to config", "error", err)
+				}
+			})
+
+			handler.SetHA(haFSM)
+			handler6.SetHA(haFSM)
+			if cfg.HA.SyncAck {
+				handler.SetPeerSync(cluster)
+			}
+
+			if err := cluster.Start(ctx); err != nil {
+				logger.Error("failed to start HA cluster", "error", err)
+			} else {
+				haCluster = cluster
+				defer haCluster.Stop()
+			}
+		}
+	} else if cfg.HA.Enabled {
 		// Primary / standalone path — start HA now
 		failoverTimeout, _ := time.ParseDuration(cfg.HA.FailoverTimeout)
 		if failoverTimeout == 0 {
 			failoverTimeout = 10 * time.Second
 		}
 		haFSM = ha.NewFSM(cfg.HA.Role, failoverTimeout, bus, logger)
+		haFSM.SetMode(cfg.HA.Mode)
+		startSplitBrainGuard(ctx, &cfg.HA, haFSM, logger)
 		peer, err := ha.NewPeer(&cfg.HA, haFSM, store, bus, logger)
 		if err != nil {
 			logger.Error("failed to create HA peer", "error", err)
 		} else {
+			var lb *ha.LoadBalancer
+			if cfg.HA.LoadBalancing() {
+				lb = ha.NewLoadBalancer(&cfg.HA, haFSM)
+				handler.SetLoadBalancer(lb)
+				handler6.SetLoadBalancer(lb)
+			}
+			wireLeaseSync(peer, leaseMgr, func() *dhcp.Handler { return handler }, lb, logger)
+
 			// Wire config sync: incoming peer config → apply to local DB
 			peer.OnConfigSync(func(cs ha.ConfigSyncPayload) {
 				if err := cfgStore.ApplyPeerConfig(cs.Section, cs.Data); err != nil {
@@ -668,7 +846,7 @@ func main() {
 
 			// On adjacency formed: if we are the active node, push full config to backup
 			peer.OnAdjacencyFormed(func() {
-				if !peer.FSM().IsActive() {
+				if !peer.FSM().IsConfigSource() {
 					logger.Info("HA adjacency formed, we are standby — waiting for config from primary")
 					return
 				}
@@ -683,6 +861,10 @@ func main() {
 
 			// Wire HA state into DHCP handler so standby node drops packets
 			handler.SetHA(haFSM)
+			handler6.SetHA(haFSM)
+			if cfg.HA.SyncAck {
+				handler.SetPeerSync(peer)
+			}
 
 			if err := peer.Start(ctx); err != nil {
 				logger.Error("failed to start HA peer", "error", err)
@@ -737,14 +919,31 @@ func main() {
 
 		// Wire fingerprint store into DHCP handler
 		handler.SetFingerprintStore(fpStore)
+
+		// DNS filtering policies select clients by fingerprinted device type
+		if dnsServer != nil {
+			dnsServer.SetDeviceTypes(func(mac string) string {
+				if info := fpStore.Get(mac); info != nil {
+					return info.DeviceType
+				}
+				return ""
+			})
+		}
 	}
 
+	// RADIUS authorization for subnets that have it configured
+	radiusClient := radius.NewClient(logger)
+	loadRADIUS(cfgStore, radiusClient, logger)
+	handler.SetRADIUS(radiusClient)
+
 	// Initialize topology map
 	topoMap, err := topology.NewMap(store.DB(), logger)
 	if err != nil {
 		logger.Warn("failed to initialize topology map", "error", err)
 	} else {
 		logger.Info("topology map initialized")
+		go topoMap.Start(bus)
+		defer topoMap.Stop()
 	}
 
 	// Initialize port automation engine
@@ -763,6 +962,17 @@ func main() {
 	// Initialize MAC vendor database
 	macVendorDB := macvendor.NewDB(logger)
 
+	// Run port automation rules on lease events
+	go portAutoEngine.Start(bus, func(lc *portauto.LeaseContext) {
+		if fpStore != nil {
+			if info := fpStore.Get(lc.MAC); info != nil {
+				lc.DeviceType = info.DeviceType
+			}
+		}
+		lc.Vendor = macVendorDB.Lookup(lc.MAC)
+	})
+	defer portAutoEngine.Stop()
+
 	// Initialize API server (always on — essential service)
 	var allPools []*pool.Pool
 	for _, subPools := range pools {
@@ -779,6 +989,8 @@ func main() {
 		api.WithConfigStore(cfgStore),
 		api.WithAnomalyDetector(anomalyDet),
 		api.WithMACVendorDB(macVendorDB),
+		api.WithRADIUSClient(radiusClient),
+		api.WithDatabase(dbMaint),
 	}
 	if auditLog != nil {
 		apiOpts = append(apiOpts, api.WithAuditLog(auditLog))
@@ -798,6 +1010,9 @@ func main() {
 	if haPeer != nil {
 		apiOpts = append(apiOpts, api.WithPeer(haPeer))
 	}
+	if haCluster != nil {
+		apiOpts = append(apiOpts, api.WithCluster(haCluster))
+	}
 	if rogueDetector != nil {
 		apiOpts = append(apiOpts, api.WithRogueDetector(rogueDetector))
 	}
@@ -824,15 +1039,15 @@ func main() {
 	defer vipGroup.ReleaseAll()
 
 	// If we already have VIPs and are active, acquire now
-	if len(vipEntries) > 0 && haFSM != nil && haFSM.IsActive() {
+	if len(vipEntries) > 0 && haFSM != nil && haFSM.HoldsVIP(haFSM.State()) {
 		vipGroup.AcquireAll()
 	}
 
 	// Wire VIP acquire/release to HA state transitions
 	if haFSM != nil {
 		haFSM.OnStateChange(func(oldState, newState dhcpv4.HAState) {
-			isNowActive := newState == dhcpv4.HAStateActive || newState == dhcpv4.HAStatePartnerDown
-			wasActive := oldState == dhcpv4.HAStateActive || oldState == dhcpv4.HAStatePartnerDown
+			isNowActive := haFSM.HoldsVIP(newState)
+			wasActive := haFSM.HoldsVIP(oldState)
 			if isNowActive && !wasActive {
 				logger.Info("HA became active — acquiring VIPs")
 				vipGroup.AcquireAll()
@@ -866,12 +1081,18 @@ func main() {
 		"dns_proxy", cfg.DNS.Enabled,
 		"ha", cfg.HA.Enabled)
 
-	// Wire local config changes → send to HA peer
+	// Wire local config changes → send to HA peer (or every cluster member)
 	cfgStore.OnLocalChange(func(section string, data []byte) {
-		if haPeer == nil {
+		var err error
+		switch {
+		case haPeer != nil:
+			err = haPeer.SendConfigSync(section, data)
+		case haCluster != nil:
+			err = haCluster.SendConfigSync(section, data)
+		default:
 			return
 		}
-		if err := haPeer.SendConfigSync(section, data); err != nil {
+		if err != nil {
 			logger.Warn("failed to sync config to HA peer",
 				"section", section, "error", err)
 		} else {
@@ -911,6 +1132,8 @@ func main() {
 		// Reload DNS proxy config (filter lists, forwarders, zone overrides)
 		if dnsServer != nil {
 			dnsServer.UpdateConfig(&cfg.DNS)
+			dnsServer.SetReservations(cfg.Subnets)
+			dnsLog = syncDNSQueryLog(dnsLog, dnsServer, cfg, logger)
 		}
 
 		// Reload Fingerbank API client if API key changed
@@ -951,11 +1174,17 @@ func main() {
 			}
 		}
 
+		// Reload RADIUS settings (changed via API or synced from peer)
+		loadRADIUS(cfgStore, radiusClient, logger)
+
 		// Reload DHCP listeners — add/remove interfaces as needed
 		serverGroup.Reload(cfg)
+		serverGroup6.Reload(cfg)
+		bulkLQ = syncBulkLeaseQuery(ctx, bulkLQ, handler, cfg, logger)
 
 		logger.Info("live config reload complete",
 			"subnets", len(cfg.Subnets),
+			"subnets6", len(cfg.Subnets6),
 			"conflict_detection", cfg.ConflictDetection.Enabled)
 	})
 
@@ -985,6 +1214,8 @@ func main() {
 			}
 			handler.UpdatePools(newPools)
 			serverGroup.Reload(cfg)
+			serverGroup6.Reload(cfg)
+			bulkLQ = syncBulkLeaseQuery(ctx, bulkLQ, handler, cfg, logger)
 			logger.Info("configuration reloaded successfully")
 
 		case syscall.SIGINT, syscall.SIGTERM:
@@ -1002,13 +1233,20 @@ func main() {
 				apiServer.Stop(shutdownCtx)
 			}
 
-			// Stop DNS proxy
+			// Stop DNS proxy, then flush its query log
 			if dnsServer != nil {
 				dnsServer.Stop()
 			}
+			if dnsLog != nil {
+				dnsLog.close()
+			}
 
-			// Stop DHCP server group (stops accepting new packets)
+			// Stop DHCP server groups (stops accepting new packets)
 			serverGroup.Stop()
+			serverGroup6.Stop()
+			if bulkLQ != nil {
+				bulkLQ.Stop()
+			}
 
 			// Stop event bus (drains remaining events)
 			bus.Stop()
@@ -1039,8 +1277,269 @@ func removePIDFile(path string) {
 	os.Remove(path)
 }
 
+// syncBulkLeaseQuery starts, restarts, or stops the bulk leasequery listener
+// so it matches cfg, returning the server now running (nil when off).
+func syncBulkLeaseQuery(ctx context.Context, cur *dhcp.BulkLeaseQueryServer, handler *dhcp.Handler, cfg *config.Config, logger *slog.Logger) *dhcp.BulkLeaseQueryServer {
+	want := cfg.LeaseQuery.Enabled && cfg.LeaseQuery.BulkEnabled
+	if cur != nil {
+		if want && !cur.NeedsRestart(cfg.LeaseQuery) {
+			return cur
+		}
+		cur.Stop()
+		cur = nil
+	}
+	if !want {
+		return nil
+	}
+	srv := dhcp.NewBulkLeaseQueryServer(handler, logger)
+	if err := srv.Start(ctx); err != nil {
+		logger.Error("failed to start bulk leasequery server", "error", err)
+		return nil
+	}
+	return srv
+}
+
+// dnsQueryLog is the persistent DNS query log and the database file it is
+// kept in.
+type dnsQueryLog struct {
+	cfg   config.DNSQueryLogConfig
+	db    *boltdb.Handle
+	store *dnsproxy.QueryStore
+}
+
+// close writes out queued entries and closes the database.
+func (q *dnsQueryLog) close() {
+	q.store.Stop()
+	q.db.Close()
+}
+
+// syncDNSQueryLog opens, reopens, or closes the persistent DNS query log
+// so it matches cfg, returning the log now attached to srv (nil when off).
+func syncDNSQueryLog(cur *dnsQueryLog, srv *dnsproxy.Server, cfg *config.Config, logger *slog.Logger) *dnsQueryLog {
+	want := cfg.DNS.QueryLog
+	if cur != nil {
+		if want.Persist && want == cur.cfg {
+			return cur
+		}
+		srv.GetQueryLog().SetStore(nil)
+		cur.close()
+	}
+	if !want.Persist {
+		return nil
+	}
+
+	path := want.Path
+	if path == "" {
+		path = filepath.Join(filepath.Dir(cfg.Server.LeaseDB), "dns-querylog.db")
+	}
+	db, err := boltdb.Open(path)
+	if err != nil {
+		logger.Error("failed to open DNS query log", "path", path, "error", err)
+		return nil
+	}
+	store, err := dnsproxy.NewQueryStore(db, want, logger)
+	if err != nil {
+		logger.Error("failed to open DNS query log", "path", path, "error", err)
+		db.Close()
+		return nil
+	}
+	store.Start()
+	srv.GetQueryLog().SetStore(store)
+	logger.Info("DNS query log persisted", "path", path, "entries", store.Count())
+	return &dnsQueryLog{cfg: want, db: db, store: store}
+}
+
+// leaseSyncer replicates leases: an HA peer, or every member of a cluster.
+type leaseSyncer interface {
+	QueueLeaseUpdate(l *lease.Lease, potential time.Time)
+	OnLeaseUpdate(fn func(ha.LeaseUpdatePayload))
+	OnLeaseAck(fn func(ha.LeaseAckPayload))
+}
+
+// wireLeaseSync connects the lease manager to the HA peer. Local lease
+// changes are queued to the peer; the peer's leases are merged through the
+// DHCP handler once handlerFn returns one (so pool bitmaps follow), or
+// straight into the lease manager before that. In load-balancing mode the
+// peer's view of each binding is tracked for the MCLT.
+func wireLeaseSync(peer leaseSyncer, leaseMgr *lease.Manager, handlerFn func() *dhcp.Handler, lb *ha.LoadBalancer, logger *slog.Logger) {
+	leaseMgr.OnLeaseChange(func(l *lease.Lease) {
+		var potential time.Time
+		if lb != nil {
+			if l.State == dhcpv4.LeaseStateActive {
+				potential = lb.PotentialExpiry(l.IP, l.Start)
+			} else {
+				lb.Forget(l.IP)
+			}
+		}
+		peer.QueueLeaseUpdate(l, potential)
+	})
+
+	peer.OnLeaseUpdate(func(lu ha.LeaseUpdatePayload) {
+		l := lu.Lease()
+		if l.IP == nil {
+			return
+		}
+		var err error
+		if h := handlerFn(); h != nil {
+			err = h.ApplyPeerLease(l)
+		} else {
+			_, err = leaseMgr.ApplyPeerLease(l)
+		}
+		if err != nil {
+			logger.Warn("failed to apply lease from HA peer", "ip", lu.IP, "error", err)
+			return
+		}
+		if lb != nil {
+			if l.State == dhcpv4.LeaseStateActive {
+				lb.Acknowledge(l.IP, lu.KnownExpiry())
+			} else {
+				lb.Forget(l.IP)
+			}
+		}
+	})
+
+	if lb != nil {
+		peer.OnLeaseAck(func(la ha.LeaseAckPayload) {
+			for _, a := range la.Leases {
+				if ip := net.ParseIP(a.IP); ip != nil {
+					lb.Acknowledge(ip, time.Unix(a.Expiry, 0))
+				}
+			}
+		})
+	}
+}
+
+// startSplitBrainGuard installs the configured split-brain check on the
+// FSM and starts reporting to the witness.
+func startSplitBrainGuard(ctx context.Context, haCfg *config.HAConfig, fsm *ha.FSM, logger *slog.Logger) {
+	if !haCfg.SplitBrain.Enabled() {
+		return
+	}
+	var pinger ha.Pinger
+	if len(haCfg.SplitBrain.Gateways) > 0 {
+		if prober, err := conflict.NewICMPProber(logger); err == nil {
+			pinger = prober
+		}
+	}
+	guard := ha.NewSplitBrainGuard(haCfg, pinger, logger)
+	fsm.SetArbiter(guard)
+	guard.Start(ctx)
+}
+
+// runWitness serves only the HA split-brain witness until SIGINT/SIGTERM.
+func runWitness(addr string) {
+	logger := logging.Setup("info", os.Stdout)
+	srv := &nethttp.Server{
+		Addr:              addr,
+		Handler:           ha.NewWitnessServer(logger),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	logger.Info("HA witness listening", "address", addr)
+	if err := srv.ListenAndServe(); err != nil && err != nethttp.ErrServerClosed {
+		logger.Error("HA witness failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("HA witness stopped")
+}
+
+// runRestore replaces the lease database named in the config with a
+// validated snapshot. The server must not be running.
+func runRestore(configPath, snapshot string) {
+	bootstrap, err := config.LoadBootstrap(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: %v\n", err)
+		os.Exit(1)
+	}
+	dbPath := bootstrap.Server.LeaseDB
+	kept, err := boltdb.Restore(snapshot, dbPath, lease.DBCheck(bootstrap.Server))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %s from %s\n", dbPath, snapshot)
+	if kept != "" {
+		fmt.Printf("previous database kept as %s\n", kept)
+	}
+}
+
+// runMigrateLeases copies the leases in a backend:path source into the
+// lease backend named in the config. The server must not be running.
+func runMigrateLeases(configPath, from string) {
+	bootstrap, err := config.LoadBootstrap(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: %v\n", err)
+		os.Exit(1)
+	}
+	srcBackend, srcPath, ok := strings.Cut(from, ":")
+	if !ok || srcPath == "" {
+		fmt.Fprintf(os.Stderr, "-migrate-leases wants backend:path, e.g. %s:%s\n", config.LeaseBackendBoltDB, config.DefaultLeaseDB)
+		os.Exit(1)
+	}
+	dstBackend, dstPath := bootstrap.Server.LeaseBackend, bootstrap.Server.LeaseDB
+	if dstBackend == config.LeaseBackendSQLite {
+		dstPath = bootstrap.Server.LeaseDSN
+	}
+	if srcBackend == dstBackend && lease.SameFile(srcPath, dstPath) {
+		fmt.Fprintf(os.Stderr, "source and destination are both %s:%s\n", dstBackend, dstPath)
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		fmt.Fprintf(os.Stderr, "source: %v\n", err)
+		os.Exit(1)
+	}
+	src, err := lease.OpenBackend(srcBackend, srcPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opening source: %v\n", err)
+		os.Exit(1)
+	}
+	defer src.Close()
+	dst, err := lease.OpenBackend(dstBackend, dstPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opening destination: %v\n", err)
+		os.Exit(1)
+	}
+	defer dst.Close()
+
+	n, err := lease.Migrate(dst, src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed after %d leases: %v\n", n, err)
+		os.Exit(1)
+	}
+	fmt.Printf("copied %d leases from %s:%s to %s:%s\n", n, srcBackend, srcPath, dstBackend, dstPath)
+}
+
+// loadRADIUS applies the per-subnet RADIUS settings stored in the database.
+// The client is left alone (and its cache kept) when nothing changed.
+func loadRADIUS(cfgStore *dbconfig.Store, rc *radius.Client, logger *slog.Logger) {
+	data := cfgStore.RADIUS()
+	if data == nil {
+		return
+	}
+	var subnets map[string]radius.SubnetConfig
+	if err := json.Unmarshal(data, &subnets); err != nil {
+		logger.Warn("failed to load radius config from db", "error", err)
+		return
+	}
+	if reflect.DeepEqual(subnets, rc.ListSubnets()) {
+		return
+	}
+	rc.ReplaceSubnets(subnets)
+	logger.Info("RADIUS config loaded", "subnets", len(subnets))
+}
+
 // initConflictDetection sets up the conflict detector with ARP and ICMP probers.
-func initConflictDetection(cfg *config.Config, store *lease.Store, bus *events.Bus, logger *slog.Logger) (*conflict.Detector, error) {
+func initConflictDetection(cfg *config.Config, store lease.Storage, bus *events.Bus, logger *slog.Logger) (*conflict.Detector, error) {
 	probeTimeout, err := time.ParseDuration(cfg.ConflictDetection.ProbeTimeout)
 	if err != nil {
 		probeTimeout = 500 * time.Millisecond
@@ -1102,7 +1601,7 @@ func initConflictDetection(cfg *config.Config, store *lease.Store, bus *events.B
 }
 
 // initPools creates pool objects from the config and reconciles with existing leases.
-func initPools(cfg *config.Config, store *lease.Store) (map[string][]*pool.Pool, error) {
+func initPools(cfg *config.Config, store lease.Storage) (map[string][]*pool.Pool, error) {
 	pools := make(map[string][]*pool.Pool)
 
 	for _, sub := range cfg.Subnets {
@@ -1122,10 +1621,14 @@ func initPools(cfg *config.Config, store *lease.Store) (map[string][]*pool.Pool,
 				return nil, fmt.Errorf("creating pool %s: %w", name, err)
 			}
 
+			p.Strategy = pcfg.Strategy
+			p.Overflow = pcfg.Overflow
 			p.MatchCircuitID = pcfg.MatchCircuitID
 			p.MatchRemoteID = pcfg.MatchRemoteID
 			p.MatchVendorClass = pcfg.MatchVendorClass
 			p.MatchUserClass = pcfg.MatchUserClass
+			p.MatchRadiusClass = pcfg.MatchRadiusClass
+			p.ClientClasses = pcfg.ClientClasses
 			p.LeaseTime = pcfg.LeaseTime
 
 			subPools = append(subPools, p)
@@ -1134,16 +1637,21 @@ func initPools(cfg *config.Config, store *lease.Store) (map[string][]*pool.Pool,
 		pools[sub.Network] = subPools
 	}
 
-	// Reconcile existing leases with pools — mark allocated IPs
+	// Reconcile existing leases with pools — mark allocated IPs and hold
+	// those still within their subnet's affinity period
 	allLeases := store.All()
 	for _, l := range allLeases {
-		if l.State != "active" && l.State != "offered" {
+		if l.State != dhcpv4.LeaseStateActive && l.State != dhcpv4.LeaseStateOffered && l.State != dhcpv4.LeaseStateReclaimed {
 			continue
 		}
 		for _, subPools := range pools {
 			for _, p := range subPools {
 				if p.Contains(l.IP) {
-					p.AllocateSpecific(l.IP)
+					if l.State == dhcpv4.LeaseStateReclaimed {
+						p.Hold(l.IP, l.Expiry)
+					} else {
+						p.AllocateSpecific(l.IP)
+					}
 					break
 				}
 			}
@@ -139,6 +139,21 @@ func (a *AuthMiddleware) authenticateAndGetRole(r *http.Request) string {
 	return ""
 }
 
+// Username returns who made an authenticated request, for audit records:
+// the session or Basic auth user, or "api" for bearer tokens and servers
+// without auth.
+func (a *AuthMiddleware) Username(r *http.Request) string {
+	if cookie, err := r.Cookie(a.cookieName); err == nil {
+		if sess := a.getSession(cookie.Value); sess != nil {
+			return sess.Username
+		}
+	}
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		return username
+	}
+	return "api"
+}
+
 // checkUserCredentials validates username/password against configured users.
 func (a *AuthMiddleware) checkUserCredentials(username, password string) string {
 	a.mu.RLock()
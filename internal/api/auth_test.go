@@ -129,3 +129,29 @@ func TestAuthBasicAuth(t *testing.T) {
 		t.Errorf("wrong password should be unauthorized, got %d", w3.Code)
 	}
 }
+
+func TestAuthUsername(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	auth := NewAuthMiddleware(config.APIConfig{Auth: config.APIAuthConfig{AuthToken: "test-token"}, Session: config.SessionConfig{CookieName: "test", Expiry: "1h"}}, logger)
+
+	// Session cookie
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "test", Value: auth.createSession("alice", "admin")})
+	if got := auth.Username(req); got != "alice" {
+		t.Errorf("session username = %q, want alice", got)
+	}
+
+	// Basic auth
+	req2 := httptest.NewRequest("GET", "/test", nil)
+	req2.SetBasicAuth("bob", "secret")
+	if got := auth.Username(req2); got != "bob" {
+		t.Errorf("basic auth username = %q, want bob", got)
+	}
+
+	// Bearer token
+	req3 := httptest.NewRequest("GET", "/test", nil)
+	req3.Header.Set("Authorization", "Bearer test-token")
+	if got := auth.Username(req3); got != "api" {
+		t.Errorf("bearer token username = %q, want api", got)
+	}
+}
@@ -36,6 +36,9 @@ type leaseResponse struct {
 	Expiry      int64  `json:"expiry"`
 	Remaining   int64  `json:"remaining_seconds"`
 	LastUpdated int64  `json:"last_updated"`
+	DUID        string `json:"duid,omitempty"`
+	IAID        uint32 `json:"iaid,omitempty"`
+	PrefixLen   int    `json:"prefix_len,omitempty"`
 }
 
 // handleListLeases returns all leases with optional filtering.
@@ -162,6 +165,9 @@ func leaseToResponse(l *lease.Lease) leaseResponse {
 		Expiry:      l.Expiry.Unix(),
 		Remaining:   int64(remaining),
 		LastUpdated: l.LastUpdated.Unix(),
+		DUID:        l.DUID,
+		IAID:        l.IAID,
+		PrefixLen:   l.PrefixLen,
 	}
 }
 
@@ -209,6 +215,16 @@ func (s *Server) handleDeleteLease(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Free the address, ending any hold for an expired-reclaimed lease
+	if ip.To4() != nil {
+		for _, p := range s.pools {
+			if p.Contains(ip) {
+				p.Release(ip)
+				break
+			}
+		}
+	}
+
 	JSONResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -417,6 +433,7 @@ func redactSecrets(m map[string]interface{}) {
 		"tsig_key":      true,
 		"api_key":       true,
 		"auth_token":    true,
+		"auth_key":      true,
 		"password_hash": true,
 		"secret":        true,
 		"key_file":      true,
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+)
+
+// handleDatabaseStatus returns the database size, per-bucket usage and snapshots.
+// GET /api/v2/database
+func (s *Server) handleDatabaseStatus(w http.ResponseWriter, r *http.Request) {
+	if s.dbMaint == nil {
+		JSONError(w, http.StatusServiceUnavailable, "database_unavailable", "database maintenance not available")
+		return
+	}
+	status, err := s.dbMaint.Status()
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, "stats_failed", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, status)
+}
+
+// handleDatabaseCompact compacts the database now.
+// POST /api/v2/database/compact
+func (s *Server) handleDatabaseCompact(w http.ResponseWriter, r *http.Request) {
+	if s.dbMaint == nil {
+		JSONError(w, http.StatusServiceUnavailable, "database_unavailable", "database maintenance not available")
+		return
+	}
+	res, err := s.dbMaint.Compact()
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, "compact_failed", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"before_bytes": res.Before,
+		"after_bytes":  res.After,
+		"duration_ms":  res.Duration.Milliseconds(),
+	})
+}
+
+// handleDatabaseSnapshot writes a snapshot to the snapshot directory now.
+// POST /api/v2/database/snapshots
+func (s *Server) handleDatabaseSnapshot(w http.ResponseWriter, r *http.Request) {
+	if s.dbMaint == nil {
+		JSONError(w, http.StatusServiceUnavailable, "database_unavailable", "database maintenance not available")
+		return
+	}
+	info, err := s.dbMaint.Snapshot()
+	if errors.Is(err, boltdb.ErrNoSnapshotDir) {
+		JSONError(w, http.StatusBadRequest, "no_snapshot_dir", err.Error())
+		return
+	}
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, "snapshot_failed", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, info)
+}
+
+// handleDatabaseBackup streams a consistent copy of the database file,
+// suitable for -restore.
+// GET /api/v2/database/backup
+func (s *Server) handleDatabaseBackup(w http.ResponseWriter, r *http.Request) {
+	if s.dbMaint == nil {
+		JSONError(w, http.StatusServiceUnavailable, "database_unavailable", "database maintenance not available")
+		return
+	}
+	filename := fmt.Sprintf("athena-dhcpd-%s.db", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if n, err := s.dbMaint.Handle().WriteTo(w); err != nil {
+		// Headers are gone by now; all we can do is log and cut the stream short
+		s.logger.Error("streaming database backup", "error", err, "bytes_written", n)
+	}
+}
@@ -3,9 +3,11 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/athena-dhcpd/athena-dhcpd/internal/dnsproxy"
 	"github.com/miekg/dns"
@@ -137,41 +139,165 @@ func (s *Server) handleDNSListTest(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
 		Domain string `json:"domain"`
+		Client string `json:"client,omitempty"` // test as this client IP, under its filtering policy
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Domain == "" {
 		JSONError(w, http.StatusBadRequest, "bad_request", "Provide {\"domain\": \"example.com\"}")
 		return
 	}
 
+	if body.Client != "" {
+		if net.ParseIP(body.Client) == nil {
+			JSONError(w, http.StatusBadRequest, "bad_request", "client must be an IP address")
+			return
+		}
+		JSONResponse(w, http.StatusOK, s.dns.TestDomainFor(body.Domain, body.Client))
+		return
+	}
+
 	result := lists.TestDomain(body.Domain)
 	JSONResponse(w, http.StatusOK, result)
 }
 
-// handleDNSQueryLog returns recent DNS query log entries.
+// handleDNSOverrides returns the temporary filtering overrides in effect.
+func (s *Server) handleDNSOverrides(w http.ResponseWriter, r *http.Request) {
+	if s.dns == nil {
+		JSONError(w, http.StatusServiceUnavailable, "dns_disabled", "DNS proxy is not enabled")
+		return
+	}
+
+	overrides := s.dns.Overrides()
+	JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"overrides": overrides,
+		"count":     len(overrides),
+	})
+}
+
+// handleDNSAddOverride adds a temporary filtering override: pause filtering
+// for a client, or allow or block a domain, for a limited time.
+func (s *Server) handleDNSAddOverride(w http.ResponseWriter, r *http.Request) {
+	if s.dns == nil {
+		JSONError(w, http.StatusServiceUnavailable, "dns_disabled", "DNS proxy is not enabled")
+		return
+	}
+
+	var body struct {
+		Action   string `json:"action"`
+		Domain   string `json:"domain,omitempty"`
+		Client   string `json:"client,omitempty"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		JSONError(w, http.StatusBadRequest, "bad_request", "invalid JSON: "+err.Error())
+		return
+	}
+	d, err := time.ParseDuration(body.Duration)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_duration", fmt.Sprintf("duration %q: %v", body.Duration, err))
+		return
+	}
+
+	o, err := s.dns.AddOverride(dnsproxy.Override{
+		Action: body.Action,
+		Domain: body.Domain,
+		Client: body.Client,
+		By:     s.auth.Username(r),
+	}, d)
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_override", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, o)
+}
+
+// handleDNSRemoveOverride ends a temporary filtering override early.
+func (s *Server) handleDNSRemoveOverride(w http.ResponseWriter, r *http.Request) {
+	if s.dns == nil {
+		JSONError(w, http.StatusServiceUnavailable, "dns_disabled", "DNS proxy is not enabled")
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, ok := s.dns.RemoveOverride(id, s.auth.Username(r)); !ok {
+		JSONError(w, http.StatusNotFound, "not_found", "no override with ID "+id)
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"status": "removed", "id": id})
+}
+
+// handleDNSQueryLog searches the DNS query log, newest first. Filters:
+// client (IP or MAC), domain, status, from and to (RFC 3339), limit.
 func (s *Server) handleDNSQueryLog(w http.ResponseWriter, r *http.Request) {
 	if s.dns == nil {
 		JSONError(w, http.StatusServiceUnavailable, "dns_disabled", "DNS proxy is not enabled")
 		return
 	}
 
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
+	q := r.URL.Query()
+	from, to, err := parseTimeRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_time", err.Error())
+		return
+	}
+	filter := dnsproxy.QueryFilter{
+		Client: q.Get("client"),
+		Domain: q.Get("domain"),
+		Status: q.Get("status"),
+		From:   from,
+		To:     to,
+	}
+	if l := q.Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil && n > 0 {
-			limit = n
+			filter.Limit = n
 		}
 	}
 
-	entries := s.dns.GetQueryLog().Recent(limit)
+	qlog := s.dns.GetQueryLog()
+	entries, err := qlog.Search(filter)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, "query_error", err.Error())
+		return
+	}
 	if entries == nil {
 		entries = []dnsproxy.QueryLogEntry{}
 	}
 
 	JSONResponse(w, http.StatusOK, map[string]interface{}{
-		"entries": entries,
-		"total":   s.dns.GetQueryLog().Count(),
+		"entries":    entries,
+		"total":      qlog.Total(),
+		"persistent": qlog.Persistent(),
 	})
 }
 
+// handleDNSQueryLogTop returns the busiest domains, blocked domains and
+// clients between from and to (default: the last 24 hours).
+func (s *Server) handleDNSQueryLogTop(w http.ResponseWriter, r *http.Request) {
+	if s.dns == nil {
+		JSONError(w, http.StatusServiceUnavailable, "dns_disabled", "DNS proxy is not enabled")
+		return
+	}
+
+	q := r.URL.Query()
+	from, to, err := parseTimeRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_time", err.Error())
+		return
+	}
+	limit := 10
+	if l := q.Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	stats, err := s.dns.GetQueryLog().Top(from, to, limit)
+	if err != nil {
+		JSONError(w, http.StatusInternalServerError, "query_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, stats)
+}
+
 // handleDNSQueryLogStream streams DNS query log entries via SSE.
 func (s *Server) handleDNSQueryLogStream(w http.ResponseWriter, r *http.Request) {
 	if s.dns == nil {
@@ -242,3 +368,21 @@ func rrValueString(rr dns.RR) string {
 		return rr.String()
 	}
 }
+
+// parseTimeRange parses optional RFC 3339 from and to query parameters.
+func parseTimeRange(fromStr, toStr string) (from, to time.Time, err error) {
+	if fromStr != "" {
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return from, to, fmt.Errorf("from: %w", err)
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return from, to, fmt.Errorf("to: %w", err)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, fmt.Errorf("to is before from")
+	}
+	return from, to, nil
+}
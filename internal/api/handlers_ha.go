@@ -1,8 +1,14 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/ha"
 )
 
 // handleHAStatus returns the current HA state.
@@ -14,18 +20,37 @@ func (s *Server) handleHAStatus(w http.ResponseWriter, r *http.Request) {
 	if s.fsm != nil {
 		resp["enabled"] = true
 		resp["role"] = s.fsm.Role()
+		resp["mode"] = s.fsm.Mode()
 		resp["state"] = string(s.fsm.State())
 		resp["is_active"] = s.fsm.IsActive()
 		resp["last_heartbeat"] = s.fsm.LastHeartbeat().Format(time.RFC3339)
 		resp["peer_address"] = s.cfg.HA.PeerAddress
 		resp["listen_address"] = s.cfg.HA.ListenAddress
+		if since := s.fsm.PartnerDownSince(); !since.IsZero() {
+			resp["partner_down_since"] = since.Format(time.RFC3339)
+		}
 
 		if s.peer != nil {
 			resp["peer_connected"] = s.peer.Connected()
+			peerSeq, ackedSeq := s.peer.SyncStatus()
+			resp["peer_seq"] = peerSeq
+			resp["acked_seq"] = ackedSeq
+			q := s.peer.QueueStatus()
+			resp["updates_queued"] = q.Queued
+			resp["updates_unacked"] = q.Unacked
+			resp["oldest_unacked_seconds"] = q.OldestUnacked.Seconds()
 			if errMsg, errAt := s.peer.LastConnError(); errMsg != "" {
 				resp["last_error"] = errMsg
 				resp["last_error_at"] = errAt.Format(time.RFC3339)
 			}
+		} else if s.cluster != nil {
+			st := s.cluster.Status()
+			resp["node_id"] = st.NodeID
+			resp["term"] = st.Term
+			resp["leader"] = st.Leader
+			resp["quorum"] = st.Quorum
+			resp["members"] = clusterMembers(st)
+			resp["peer_connected"] = slices.ContainsFunc(st.Members, func(m ha.MemberStatus) bool { return !m.Self && m.Connected })
 		} else {
 			resp["peer_connected"] = false
 		}
@@ -44,13 +69,32 @@ func (s *Server) handleHAStatus(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, http.StatusOK, resp)
 }
 
-// handleHAFailover triggers a manual failover.
+// handleHAFailover triggers a manual failover. With split-brain protection
+// configured the witness and gateway checks must agree unless ?force=true.
 func (s *Server) handleHAFailover(w http.ResponseWriter, r *http.Request) {
 	if s.fsm == nil {
 		JSONError(w, http.StatusBadRequest, "ha_disabled", "HA is not enabled")
 		return
 	}
 
+	// In a cluster the members decide: ask them to elect this node
+	if s.cluster != nil {
+		s.cluster.Campaign()
+		s.logger.Warn("manual failover triggered via API — cluster election started")
+		JSONResponse(w, http.StatusOK, map[string]interface{}{
+			"status":    "election started",
+			"new_state": string(s.fsm.State()),
+		})
+		return
+	}
+
+	if r.URL.Query().Get("force") != "true" {
+		if ok, why := s.fsm.MayTakeOver(r.Context()); !ok {
+			JSONError(w, http.StatusConflict, "split_brain_risk", "split-brain check refused failover: "+why)
+			return
+		}
+	}
+
 	s.fsm.ClaimActive("API manual failover")
 	s.logger.Warn("manual failover triggered via API")
 
@@ -59,3 +103,90 @@ func (s *Server) handleHAFailover(w http.ResponseWriter, r *http.Request) {
 		"new_state": string(s.fsm.State()),
 	})
 }
+
+// clusterMembers renders the member list of a cluster status.
+func clusterMembers(st ha.ClusterStatus) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(st.Members))
+	for _, m := range st.Members {
+		e := map[string]interface{}{
+			"id":        m.ID,
+			"address":   m.Address,
+			"self":      m.Self,
+			"leader":    m.Leader,
+			"connected": m.Connected,
+		}
+		if !m.LastSeen.IsZero() {
+			e["last_seen"] = m.LastSeen.Format(time.RFC3339)
+		}
+		if !m.Self {
+			e["updates_queued"] = m.Queue.Queued
+			e["updates_unacked"] = m.Queue.Unacked
+			e["oldest_unacked_seconds"] = m.Queue.OldestUnacked.Seconds()
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// handleHAMembers lists the cluster members as seen from this node.
+func (s *Server) handleHAMembers(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		JSONError(w, http.StatusBadRequest, "not_cluster", "HA cluster mode is not enabled")
+		return
+	}
+	JSONResponse(w, http.StatusOK, clusterMembers(s.cluster.Status()))
+}
+
+// handleAddHAMember adds a member to the cluster (leader only). The new
+// node must already be running with the full member list in its config.
+func (s *Server) handleAddHAMember(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		JSONError(w, http.StatusBadRequest, "not_cluster", "HA cluster mode is not enabled")
+		return
+	}
+	var m config.HAMember
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	if m.ID == "" || m.Address == "" {
+		JSONError(w, http.StatusBadRequest, "missing_field", "id and address are required")
+		return
+	}
+	members := s.cluster.Members()
+	if slices.ContainsFunc(members, func(e config.HAMember) bool { return e.ID == m.ID }) {
+		JSONError(w, http.StatusConflict, "already_exists", "member "+m.ID+" already exists")
+		return
+	}
+	s.setHAMembers(w, append(members, m), http.StatusCreated)
+}
+
+// handleRemoveHAMember removes a member from the cluster (leader only).
+func (s *Server) handleRemoveHAMember(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		JSONError(w, http.StatusBadRequest, "not_cluster", "HA cluster mode is not enabled")
+		return
+	}
+	id := r.PathValue("id")
+	members := s.cluster.Members()
+	i := slices.IndexFunc(members, func(e config.HAMember) bool { return e.ID == id })
+	if i < 0 {
+		JSONError(w, http.StatusNotFound, "not_found", "member "+id+" not found")
+		return
+	}
+	s.setHAMembers(w, slices.Delete(members, i, i+1), http.StatusOK)
+}
+
+// setHAMembers applies a new member list and writes the response.
+func (s *Server) setHAMembers(w http.ResponseWriter, members []config.HAMember, status int) {
+	if err := s.cluster.SetMembers(members); err != nil {
+		if errors.Is(err, ha.ErrNotLeader) {
+			JSONError(w, http.StatusConflict, "not_leader", "membership changes must be made on the cluster leader ("+s.cluster.Leader()+")")
+			return
+		}
+		JSONError(w, http.StatusBadRequest, "invalid_members", err.Error())
+		return
+	}
+	s.logger.Warn("HA cluster membership changed via API", "members", len(members))
+	JSONResponse(w, status, clusterMembers(s.cluster.Status()))
+}
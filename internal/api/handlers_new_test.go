@@ -607,6 +607,66 @@ func TestHandleHAFailoverEnabled(t *testing.T) {
 	store.Close()
 }
 
+func TestHandleHAMembers(t *testing.T) {
+	srv := newTestServer(t)
+	req := httptest.NewRequest("GET", "/api/v2/ha/members", nil)
+	w := httptest.NewRecorder()
+	srv.handleHAMembers(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("without cluster: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	defer bus.Stop()
+	store, _ := lease.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	defer store.Close()
+
+	haCfg := config.HAConfig{
+		Enabled: true,
+		Mode:    config.HAModeCluster,
+		NodeID:  "a",
+		Members: []config.HAMember{
+			{ID: "a", Address: "10.0.0.1:8068"},
+			{ID: "b", Address: "10.0.0.2:8068"},
+			{ID: "c", Address: "10.0.0.3:8068"},
+		},
+	}
+	fsm := ha.NewFSM("member", 10*time.Second, bus, logger)
+	fsm.SetMode(config.HAModeCluster)
+	cluster, err := ha.NewCluster(&haCfg, fsm, store, bus, logger)
+	if err != nil {
+		t.Fatalf("NewCluster error: %v", err)
+	}
+	cfg := &config.Config{API: config.APIConfig{Listen: "127.0.0.1:0"}, HA: haCfg}
+	srv = NewServer(cfg, store, nil, nil, nil, bus, logger, WithFSM(fsm), WithCluster(cluster))
+
+	w = httptest.NewRecorder()
+	srv.handleHAStatus(w, httptest.NewRequest("GET", "/api/v2/ha/status", nil))
+	var status map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if members, _ := status["members"].([]interface{}); status["node_id"] != "a" || len(members) != 3 {
+		t.Errorf("status node_id = %v, members = %v", status["node_id"], status["members"])
+	}
+
+	// Not elected (never started) — changes must go to the leader
+	body := strings.NewReader(`{"id":"d","address":"10.0.0.4:8068"}`)
+	w = httptest.NewRecorder()
+	srv.handleAddHAMember(w, httptest.NewRequest("POST", "/api/v2/ha/members", body))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "not_leader") {
+		t.Errorf("add on follower: status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v2/ha/members/x", nil)
+	req.SetPathValue("id", "x")
+	w = httptest.NewRecorder()
+	srv.handleRemoveHAMember(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("remove unknown: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
 // --- Metrics Tests ---
 
 func TestMetricsEndpoint(t *testing.T) {
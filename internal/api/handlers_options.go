@@ -0,0 +1,183 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/clientclass"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/dhcp"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// effectiveOption is one resolved option and the layer that supplied it.
+type effectiveOption struct {
+	Code   int    `json:"code"`
+	Name   string `json:"name,omitempty"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveOptionsResponse is the JSON body for the effective options view.
+type effectiveOptionsResponse struct {
+	MAC         string            `json:"mac"`
+	Subnet      string            `json:"subnet"`
+	IP          string            `json:"ip,omitempty"`
+	Pool        string            `json:"pool,omitempty"`
+	Reservation bool              `json:"reservation"`
+	Classes     []string          `json:"classes"`
+	Layers      []string          `json:"layers"`
+	LeaseTime   string            `json:"lease_time"`
+	LeaseSource string            `json:"lease_source"`
+	RenewalTime string            `json:"renewal_time"`
+	RebindTime  string            `json:"rebind_time"`
+	NextServer  string            `json:"next_server,omitempty"`
+	BootFile    string            `json:"boot_file,omitempty"`
+	FileSource  string            `json:"boot_file_source,omitempty"`
+	Options     []effectiveOption `json:"options"`
+}
+
+// handleEffectiveOptions shows the options a client would receive and which
+// layer (defaults, subnet, pool, class, reservation) each one came from.
+// GET /api/v2/options/effective?mac=...&subnet=...
+// The subnet is taken from the client's reservation or lease when omitted.
+func (s *Server) handleEffectiveOptions(w http.ResponseWriter, r *http.Request) {
+	mac, err := net.ParseMAC(r.URL.Query().Get("mac"))
+	if err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_mac", "mac query parameter must be a valid MAC address")
+		return
+	}
+
+	subnetIdx := -1
+	var ip net.IP
+	if network := r.URL.Query().Get("subnet"); network != "" {
+		for i, sub := range s.cfg.Subnets {
+			if sub.Network == network {
+				subnetIdx = i
+				break
+			}
+		}
+		if subnetIdx < 0 {
+			JSONError(w, http.StatusNotFound, "not_found", "subnet not found")
+			return
+		}
+	}
+
+	// Reservation first, then any existing lease, for both subnet and IP.
+	var res *config.ReservationConfig
+	var existing *lease.Lease
+	if s.leaseStore != nil {
+		existing = s.leaseStore.GetByMAC(mac)
+	}
+	for i := range s.cfg.Subnets {
+		if subnetIdx >= 0 && i != subnetIdx {
+			continue
+		}
+		if found := findReservationByMAC(&s.cfg.Subnets[i], mac); found != nil {
+			subnetIdx, res = i, found
+			ip = net.ParseIP(found.IP)
+			break
+		}
+	}
+	if res == nil && existing != nil {
+		for i, sub := range s.cfg.Subnets {
+			if sub.Network == existing.Subnet && (subnetIdx < 0 || subnetIdx == i) {
+				subnetIdx = i
+				ip = existing.IP
+				break
+			}
+		}
+	}
+	if subnetIdx < 0 {
+		JSONError(w, http.StatusNotFound, "no_subnet", "no reservation or lease for this MAC; pass ?subnet=")
+		return
+	}
+
+	sub := &s.cfg.Subnets[subnetIdx]
+	_, network, _ := net.ParseCIDR(sub.Network)
+	poolCfg := dhcp.FindPoolConfig(sub, ip)
+	classifier, _ := dhcp.NewClassifier(s.cfg.ClientClasses)
+	classes := classifier.Classify(s.knownClient(mac, existing))
+	layers := dhcp.OptionLayers(s.cfg, sub, poolCfg, dhcp.ClassLayers(classes), res)
+	resolved := dhcp.ResolveOptions(network, layers)
+
+	resp := effectiveOptionsResponse{
+		MAC:         mac.String(),
+		Subnet:      sub.Network,
+		Reservation: res != nil,
+		Classes:     dhcp.ClassNames(classes),
+		LeaseTime:   resolved.LeaseTime.String(),
+		LeaseSource: resolved.LeaseSource,
+		RenewalTime: resolved.RenewalTime.String(),
+		RebindTime:  resolved.RebindTime.String(),
+		BootFile:    resolved.BootFile,
+		FileSource:  resolved.FileSource,
+		Options:     make([]effectiveOption, 0, len(resolved.Options)),
+	}
+	if ip != nil {
+		resp.IP = ip.String()
+	}
+	if poolCfg != nil {
+		resp.Pool = poolCfg.RangeStart + "-" + poolCfg.RangeEnd
+	}
+	if resolved.NextServer != nil {
+		resp.NextServer = resolved.NextServer.String()
+	}
+	for _, l := range layers {
+		resp.Layers = append(resp.Layers, l.Source)
+	}
+	for code, data := range resolved.Options {
+		opt := effectiveOption{
+			Code:   int(code),
+			Value:  dhcp.FormatOption(code, data),
+			Source: resolved.Sources[code],
+		}
+		if def := dhcpv4.GetOptionDef(code); def != nil {
+			opt.Name = def.Name
+		}
+		resp.Options = append(resp.Options, opt)
+	}
+	sort.Slice(resp.Options, func(i, j int) bool { return resp.Options[i].Code < resp.Options[j].Code })
+
+	JSONResponse(w, http.StatusOK, resp)
+}
+
+// knownClient builds the class-matching view of a client from what the
+// server remembers: relay info from its lease and its device fingerprint.
+// Expressions on other packet options can't match here.
+func (s *Server) knownClient(mac net.HardwareAddr, l *lease.Lease) *clientclass.Client {
+	c := &clientclass.Client{MAC: mac, Options: map[dhcpv4.OptionCode][]byte{}}
+	if l != nil && l.RelayInfo != nil {
+		c.GIAddr = l.RelayInfo.GIAddr
+		c.CircuitID = l.RelayInfo.CircuitID
+		c.RemoteID = l.RelayInfo.RemoteID
+	}
+	if s.fpStore != nil {
+		if fp := s.fpStore.Get(mac.String()); fp != nil {
+			c.VendorClass = fp.VendorClass
+			c.DeviceType = fp.DeviceType
+			c.DeviceName = fp.DeviceName
+			c.DeviceOS = fp.OS
+			if fp.VendorClass != "" {
+				c.Options[dhcpv4.OptionVendorClassID] = []byte(fp.VendorClass)
+			}
+			if fp.Hostname != "" {
+				c.Options[dhcpv4.OptionHostname] = []byte(fp.Hostname)
+			}
+		}
+	}
+	return c
+}
+
+// findReservationByMAC returns the subnet's reservation for mac, or nil.
+// Matching follows lease.Manager.FindReservation.
+func findReservationByMAC(sub *config.SubnetConfig, mac net.HardwareAddr) *config.ReservationConfig {
+	for i, res := range sub.Reservations {
+		if res.MAC != "" && res.MAC == mac.String() {
+			return &sub.Reservations[i]
+		}
+	}
+	return nil
+}
@@ -58,3 +58,13 @@ func (s *Server) handlePortAutoTest(w http.ResponseWriter, r *http.Request) {
 	results := s.portAutoEngine.Evaluate(ctx)
 	JSONResponse(w, http.StatusOK, results)
 }
+
+// handlePortAutoHistory returns recent rule executions triggered by lease events.
+// GET /api/v2/portauto/history?rule=name
+func (s *Server) handlePortAutoHistory(w http.ResponseWriter, r *http.Request) {
+	if s.portAutoEngine == nil {
+		JSONError(w, http.StatusServiceUnavailable, "portauto_disabled", "port automation not available")
+		return
+	}
+	JSONResponse(w, http.StatusOK, s.portAutoEngine.History(r.URL.Query().Get("rule")))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	radiuspkg "github.com/athena-dhcpd/athena-dhcpd/internal/radius"
@@ -48,7 +49,22 @@ func (s *Server) handleRADIUSSetSubnet(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
 		return
 	}
+	if _, _, err := net.ParseCIDR(subnet); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_subnet", err.Error())
+		return
+	}
+	// GET redacts the secret — keep the stored one when it comes back unchanged
+	if cfg.Server.Secret == "***" {
+		if old := s.radiusClient.GetSubnet(subnet); old != nil {
+			cfg.Server.Secret = old.Server.Secret
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_radius_config", err.Error())
+		return
+	}
 	s.radiusClient.SetSubnet(subnet, &cfg)
+	s.persistRADIUS()
 	JSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
@@ -61,9 +77,21 @@ func (s *Server) handleRADIUSDeleteSubnet(w http.ResponseWriter, r *http.Request
 	}
 	subnet := r.PathValue("subnet")
 	s.radiusClient.RemoveSubnet(subnet)
+	s.persistRADIUS()
 	JSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// persistRADIUS writes the current per-subnet RADIUS settings to the database.
+func (s *Server) persistRADIUS() {
+	if s.cfgStore == nil {
+		return
+	}
+	data, _ := json.Marshal(s.radiusClient.ListSubnets())
+	if err := s.cfgStore.SetRADIUS(data); err != nil {
+		s.logger.Warn("failed to persist radius config", "error", err)
+	}
+}
+
 // handleRADIUSTest tests connectivity to a RADIUS server.
 // POST /api/v2/radius/test
 func (s *Server) handleRADIUSTest(w http.ResponseWriter, r *http.Request) {
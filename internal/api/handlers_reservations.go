@@ -23,6 +23,15 @@ type reservationResponse struct {
 	Hostname     string   `json:"hostname,omitempty"`
 	DNSServers   []string `json:"dns_servers,omitempty"`
 	DDNSHostname string   `json:"ddns_hostname,omitempty"`
+
+	// Option overrides — take precedence over subnet, pool and class values
+	Routers    []string              `json:"routers,omitempty"`
+	NTPServers []string              `json:"ntp_servers,omitempty"`
+	DomainName string                `json:"domain_name,omitempty"`
+	LeaseTime  string                `json:"lease_time,omitempty"`
+	NextServer string                `json:"next_server,omitempty"`
+	BootFile   string                `json:"boot_file,omitempty"`
+	Options    []config.OptionConfig `json:"options,omitempty"`
 }
 
 // handleListReservations returns all reservations across all subnets.
@@ -41,6 +50,13 @@ func (s *Server) handleListReservations(w http.ResponseWriter, r *http.Request)
 				Hostname:     res.Hostname,
 				DNSServers:   res.DNSServers,
 				DDNSHostname: res.DDNSHostname,
+				Routers:      res.Routers,
+				NTPServers:   res.NTPServers,
+				DomainName:   res.DomainName,
+				LeaseTime:    res.LeaseTime,
+				NextServer:   res.NextServer,
+				BootFile:     res.BootFile,
+				Options:      res.Options,
 			})
 			id++
 		}
@@ -58,6 +74,14 @@ type reservationRequest struct {
 	Hostname     string   `json:"hostname,omitempty"`
 	DNSServers   []string `json:"dns_servers,omitempty"`
 	DDNSHostname string   `json:"ddns_hostname,omitempty"`
+
+	Routers    []string              `json:"routers,omitempty"`
+	NTPServers []string              `json:"ntp_servers,omitempty"`
+	DomainName string                `json:"domain_name,omitempty"`
+	LeaseTime  string                `json:"lease_time,omitempty"`
+	NextServer string                `json:"next_server,omitempty"`
+	BootFile   string                `json:"boot_file,omitempty"`
+	Options    []config.OptionConfig `json:"options,omitempty"`
 }
 
 // handleCreateReservation adds a new reservation to the config.
@@ -80,6 +104,17 @@ func (s *Server) handleCreateReservation(w http.ResponseWriter, r *http.Request)
 		Hostname:     req.Hostname,
 		DNSServers:   req.DNSServers,
 		DDNSHostname: req.DDNSHostname,
+		Routers:      req.Routers,
+		NTPServers:   req.NTPServers,
+		DomainName:   req.DomainName,
+		LeaseTime:    req.LeaseTime,
+		NextServer:   req.NextServer,
+		BootFile:     req.BootFile,
+		Options:      req.Options,
+	}
+	if err := validateReservationOptions(res); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_option", err.Error())
+		return
 	}
 
 	network := s.cfg.Subnets[req.SubnetIndex].Network
@@ -141,6 +176,31 @@ func (s *Server) handleUpdateReservation(w http.ResponseWriter, r *http.Request)
 				if req.DDNSHostname != "" {
 					res.DDNSHostname = req.DDNSHostname
 				}
+				if req.Routers != nil {
+					res.Routers = req.Routers
+				}
+				if req.NTPServers != nil {
+					res.NTPServers = req.NTPServers
+				}
+				if req.DomainName != "" {
+					res.DomainName = req.DomainName
+				}
+				if req.LeaseTime != "" {
+					res.LeaseTime = req.LeaseTime
+				}
+				if req.NextServer != "" {
+					res.NextServer = req.NextServer
+				}
+				if req.BootFile != "" {
+					res.BootFile = req.BootFile
+				}
+				if req.Options != nil {
+					res.Options = req.Options
+				}
+				if err := validateReservationOptions(res); err != nil {
+					JSONError(w, http.StatusBadRequest, "invalid_option", err.Error())
+					return
+				}
 				network := s.cfg.Subnets[si].Network
 				if s.cfgStore != nil {
 					if err := s.cfgStore.PutReservation(network, res); err != nil {
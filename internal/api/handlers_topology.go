@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/topology"
 )
 
 // handleTopologyTree returns the full topology tree.
@@ -15,6 +17,14 @@ func (s *Server) handleTopologyTree(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, http.StatusOK, s.topoMap.Tree())
 }
 
+// topologyStatsResponse is the topology summary with per-switch utilisation.
+type topologyStatsResponse struct {
+	Switches  int                    `json:"switches"`
+	Ports     int                    `json:"ports"`
+	Devices   int                    `json:"devices"`
+	PerSwitch []topology.SwitchUsage `json:"per_switch"`
+}
+
 // handleTopologyStats returns topology summary statistics.
 // GET /api/v2/topology/stats
 func (s *Server) handleTopologyStats(w http.ResponseWriter, r *http.Request) {
@@ -22,7 +32,13 @@ func (s *Server) handleTopologyStats(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusServiceUnavailable, "topology_disabled", "topology mapping not available")
 		return
 	}
-	JSONResponse(w, http.StatusOK, s.topoMap.Stats())
+	stats := s.topoMap.Stats()
+	JSONResponse(w, http.StatusOK, topologyStatsResponse{
+		Switches:  stats["switches"],
+		Ports:     stats["ports"],
+		Devices:   stats["devices"],
+		PerSwitch: s.topoMap.Usage(),
+	})
 }
 
 // handleTopologySetLabel sets a label on a switch or port.
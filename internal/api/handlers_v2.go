@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -10,6 +12,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/dbconfig"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/dhcp"
 )
 
 // --- Subnets ---
@@ -40,6 +44,10 @@ func (s *Server) handleV2CreateSubnet(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusConflict, "already_exists", "subnet already exists")
 		return
 	}
+	if err := validateSubnetOptions(sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_option", err.Error())
+		return
+	}
 	if err := s.cfgStore.PutSubnet(sub); err != nil {
 		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
 		return
@@ -74,6 +82,10 @@ func (s *Server) handleV2UpdateSubnet(w http.ResponseWriter, r *http.Request) {
 	if sub.Options == nil {
 		sub.Options = existing.Options
 	}
+	if err := validateSubnetOptions(sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_option", err.Error())
+		return
+	}
 	if err := s.cfgStore.PutSubnet(sub); err != nil {
 		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
 		return
@@ -94,6 +106,96 @@ func (s *Server) handleV2DeleteSubnet(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+// --- DHCPv6 Subnets ---
+
+func (s *Server) handleV2ListSubnets6(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	JSONResponse(w, http.StatusOK, s.cfgStore.Subnets6())
+}
+
+func (s *Server) handleV2CreateSubnet6(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	var sub config.Subnet6Config
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	if sub.Network == "" {
+		JSONError(w, http.StatusBadRequest, "missing_field", "network is required")
+		return
+	}
+	if _, exists := s.cfgStore.GetSubnet6(sub.Network); exists {
+		JSONError(w, http.StatusConflict, "already_exists", "subnet6 already exists")
+		return
+	}
+	if err := config.ValidateSubnet6(sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_subnet", err.Error())
+		return
+	}
+	if err := s.cfgStore.PutSubnet6(sub); err != nil {
+		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, sub)
+}
+
+func (s *Server) handleV2UpdateSubnet6(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	network, _ := url.PathUnescape(r.PathValue("network"))
+	existing, exists := s.cfgStore.GetSubnet6(network)
+	if !exists {
+		JSONError(w, http.StatusNotFound, "not_found", "subnet6 not found")
+		return
+	}
+	var sub config.Subnet6Config
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	sub.Network = network
+	// Preserve pools and reservations if not sent in the update
+	if sub.Pools == nil {
+		sub.Pools = existing.Pools
+	}
+	if sub.PDPools == nil {
+		sub.PDPools = existing.PDPools
+	}
+	if sub.Reservations == nil {
+		sub.Reservations = existing.Reservations
+	}
+	if err := config.ValidateSubnet6(sub); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_subnet", err.Error())
+		return
+	}
+	if err := s.cfgStore.PutSubnet6(sub); err != nil {
+		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, sub)
+}
+
+func (s *Server) handleV2DeleteSubnet6(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	network, _ := url.PathUnescape(r.PathValue("network"))
+	if err := s.cfgStore.DeleteSubnet6(network); err != nil {
+		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
 // --- Reservations ---
 
 func (s *Server) handleV2ListReservations(w http.ResponseWriter, r *http.Request) {
@@ -128,6 +230,10 @@ func (s *Server) handleV2CreateReservation(w http.ResponseWriter, r *http.Reques
 		JSONError(w, http.StatusBadRequest, "missing_field", "ip is required")
 		return
 	}
+	if err := validateReservationOptions(res); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_option", err.Error())
+		return
+	}
 	if err := s.cfgStore.PutReservation(network, res); err != nil {
 		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
 		return
@@ -216,6 +322,12 @@ func (s *Server) handleV2ImportReservations(w http.ResponseWriter, r *http.Reque
 		JSONError(w, http.StatusBadRequest, "empty", "no reservations to import")
 		return
 	}
+	for i, res := range reservations {
+		if err := validateReservationOptions(res); err != nil {
+			JSONError(w, http.StatusBadRequest, "invalid_option", fmt.Sprintf("reservation[%d]: %v", i, err))
+			return
+		}
+	}
 
 	added, err := s.cfgStore.ImportReservations(network, reservations)
 	if err != nil {
@@ -283,7 +395,12 @@ func (s *Server) handleV2SetConflict(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleV2GetHA(w http.ResponseWriter, r *http.Request) {
-	JSONResponse(w, http.StatusOK, s.cfg.HA)
+	h := s.cfg.HA
+	// Redact the pre-shared key
+	if h.AuthKey != "" {
+		h.AuthKey = "***"
+	}
+	JSONResponse(w, http.StatusOK, h)
 }
 
 func (s *Server) handleV2SetHA(w http.ResponseWriter, r *http.Request) {
@@ -296,6 +413,10 @@ func (s *Server) handleV2SetHA(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
 		return
 	}
+	// GET redacts the key — keep the stored one when it comes back unchanged
+	if h.AuthKey == "***" {
+		h.AuthKey = s.cfg.HA.AuthKey
+	}
 	if err := config.WriteHASection(s.configPath, &h); err != nil {
 		JSONError(w, http.StatusInternalServerError, "write_error", err.Error())
 		return
@@ -303,6 +424,9 @@ func (s *Server) handleV2SetHA(w http.ResponseWriter, r *http.Request) {
 	// Update in-memory config immediately
 	s.cfg.HA = h
 	s.logger.Info("HA config updated via API", "role", h.Role, "enabled", h.Enabled)
+	if h.AuthKey != "" {
+		h.AuthKey = "***"
+	}
 	JSONResponse(w, http.StatusOK, h)
 }
 
@@ -374,6 +498,26 @@ func (s *Server) handleV2SetDNS(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
 		return
 	}
+	if err := config.ValidateDNSLists(d.Lists); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_list", err.Error())
+		return
+	}
+	if err := config.ValidateDNSPolicies(d); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_policy", err.Error())
+		return
+	}
+	if err := config.ValidateDNSQueryLog(d.QueryLog); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_query_log", err.Error())
+		return
+	}
+	if err := config.ValidateDNSSEC(d.DNSSEC); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_dnssec", err.Error())
+		return
+	}
+	if err := config.ValidateDNSTransports(d); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_upstream", err.Error())
+		return
+	}
 	if err := s.cfgStore.SetDNS(d); err != nil {
 		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
 		return
@@ -381,6 +525,41 @@ func (s *Server) handleV2SetDNS(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, http.StatusOK, d)
 }
 
+// --- Client Classes ---
+
+func (s *Server) handleV2GetClientClasses(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	JSONResponse(w, http.StatusOK, s.cfgStore.ClientClasses())
+}
+
+func (s *Server) handleV2SetClientClasses(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	var classes []config.ClientClassConfig
+	if err := json.NewDecoder(r.Body).Decode(&classes); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	if err := validateClientClasses(classes); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_client_class", err.Error())
+		return
+	}
+	if err := s.cfgStore.SetClientClasses(classes); err != nil {
+		if errors.Is(err, dbconfig.ErrClassInUse) {
+			JSONError(w, http.StatusBadRequest, "class_in_use", err.Error())
+			return
+		}
+		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, classes)
+}
+
 // --- Hostname Sanitisation ---
 
 func (s *Server) handleV2GetHostnameSanitisation(w http.ResponseWriter, r *http.Request) {
@@ -462,6 +641,37 @@ func (s *Server) handleV2SetFingerprint(w http.ResponseWriter, r *http.Request)
 	JSONResponse(w, http.StatusOK, f)
 }
 
+// --- Leasequery Config ---
+
+func (s *Server) handleV2GetLeaseQuery(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	JSONResponse(w, http.StatusOK, s.cfgStore.LeaseQuery())
+}
+
+func (s *Server) handleV2SetLeaseQuery(w http.ResponseWriter, r *http.Request) {
+	if s.cfgStore == nil {
+		JSONError(w, http.StatusServiceUnavailable, "no_config_store", "config store not available")
+		return
+	}
+	var lq config.LeaseQueryConfig
+	if err := json.NewDecoder(r.Body).Decode(&lq); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	if err := config.ValidateLeaseQuery(lq); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_leasequery", err.Error())
+		return
+	}
+	if err := s.cfgStore.SetLeaseQuery(lq); err != nil {
+		JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, lq)
+}
+
 // --- V1 TOML Import ---
 
 func (s *Server) handleV2ImportTOML(w http.ResponseWriter, r *http.Request) {
@@ -487,6 +697,16 @@ func (s *Server) handleV2ImportTOML(w http.ResponseWriter, r *http.Request) {
 		JSONError(w, http.StatusBadRequest, "parse_error", err.Error())
 		return
 	}
+	for _, sub := range cfg.Subnets {
+		if err := validateSubnetOptions(sub); err != nil {
+			JSONError(w, http.StatusBadRequest, "invalid_option", fmt.Sprintf("subnet %s: %v", sub.Network, err))
+			return
+		}
+	}
+	if err := validateClientClasses(cfg.ClientClasses); err != nil {
+		JSONError(w, http.StatusBadRequest, "invalid_client_class", err.Error())
+		return
+	}
 
 	if err := s.cfgStore.ImportFromConfig(&cfg); err != nil {
 		JSONError(w, http.StatusInternalServerError, "import_error", err.Error())
@@ -498,3 +718,52 @@ func (s *Server) handleV2ImportTOML(w http.ResponseWriter, r *http.Request) {
 		"subnets": len(cfg.Subnets),
 	})
 }
+
+// validateSubnetOptions checks the custom options and overrides on a subnet,
+// its pools and its reservations.
+func validateSubnetOptions(sub config.SubnetConfig) error {
+	if err := dhcp.ValidateOptionConfigs(sub.Options); err != nil {
+		return err
+	}
+	if err := config.ValidateAffinityPeriod(sub.AffinityPeriod); err != nil {
+		return fmt.Errorf("affinity_period: %w", err)
+	}
+	if err := config.ValidatePressure(sub); err != nil {
+		return err
+	}
+	for i, pool := range sub.Pools {
+		if err := config.ValidatePoolOverrides(pool); err != nil {
+			return fmt.Errorf("pool[%d]: %w", i, err)
+		}
+		if err := dhcp.ValidateOptionConfigs(pool.Options); err != nil {
+			return fmt.Errorf("pool[%d]: %w", i, err)
+		}
+	}
+	for i, res := range sub.Reservations {
+		if err := validateReservationOptions(res); err != nil {
+			return fmt.Errorf("reservation[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateReservationOptions checks the option overrides on a reservation.
+func validateReservationOptions(res config.ReservationConfig) error {
+	if err := config.ValidateReservationOverrides(res); err != nil {
+		return err
+	}
+	return dhcp.ValidateOptionConfigs(res.Options)
+}
+
+// validateClientClasses checks class definitions and their custom options.
+func validateClientClasses(classes []config.ClientClassConfig) error {
+	if err := config.ValidateClientClasses(classes); err != nil {
+		return err
+	}
+	for _, cc := range classes {
+		if err := dhcp.ValidateOptionConfigs(cc.Options); err != nil {
+			return fmt.Errorf("client_class %q: %w", cc.Name, err)
+		}
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/athena-dhcpd/athena-dhcpd/internal/anomaly"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/audit"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/conflict"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/dbconfig"
@@ -35,13 +36,14 @@ import (
 type Server struct {
 	cfg             *config.Config
 	configPath      string
-	leaseStore      *lease.Store
+	leaseStore      lease.Storage
 	leaseManager    *lease.Manager
 	conflictTable   *conflict.Table
 	pools           []*pool.Pool
 	bus             *events.Bus
 	fsm             *ha.FSM
 	peer            *ha.Peer
+	cluster         *ha.Cluster
 	dns             *dnsproxy.Server
 	auditLog        *audit.Log
 	fpStore         *fingerprint.Store
@@ -52,6 +54,7 @@ type Server struct {
 	radiusClient    *radiuspkg.Client
 	portAutoEngine  *portauto.Engine
 	vipGroup        *vip.Group
+	dbMaint         *boltdb.Maintainer
 	logger          *slog.Logger
 	httpServer      *http.Server
 	auth            *AuthMiddleware
@@ -66,7 +69,7 @@ type Server struct {
 // NewServer creates a new API server.
 func NewServer(
 	cfg *config.Config,
-	store *lease.Store,
+	store lease.Storage,
 	mgr *lease.Manager,
 	ct *conflict.Table,
 	pools []*pool.Pool,
@@ -114,6 +117,11 @@ func WithPeer(peer *ha.Peer) ServerOption {
 	return func(s *Server) { s.peer = peer }
 }
 
+// WithCluster sets the HA cluster (ha.mode = "cluster").
+func WithCluster(c *ha.Cluster) ServerOption {
+	return func(s *Server) { s.cluster = c }
+}
+
 // WithDNSProxy sets the built-in DNS proxy server.
 func WithDNSProxy(dns *dnsproxy.Server) ServerOption {
 	return func(s *Server) { s.dns = dns }
@@ -174,6 +182,11 @@ func WithVIPGroup(g *vip.Group) ServerOption {
 	return func(s *Server) { s.vipGroup = g }
 }
 
+// WithDatabase sets the database maintainer for compaction and snapshots.
+func WithDatabase(m *boltdb.Maintainer) ServerOption {
+	return func(s *Server) { s.dbMaint = m }
+}
+
 // WithSetupMode marks this server as running in setup wizard mode.
 func WithSetupMode(cb func()) ServerOption {
 	return func(s *Server) {
@@ -269,6 +282,9 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/v2/reservations/import", s.auth.RequireAdmin(s.handleImportReservations))
 	mux.HandleFunc("GET /api/v2/reservations/export", s.auth.RequireAuth(s.handleExportReservations))
 
+	// Effective options (precedence chain view for one client)
+	mux.HandleFunc("GET /api/v2/options/effective", s.auth.RequireAuth(s.handleEffectiveOptions))
+
 	// Subnets & Pools (read-only runtime view)
 	mux.HandleFunc("GET /api/v2/subnets", s.auth.RequireAuth(s.handleListSubnets))
 	mux.HandleFunc("GET /api/v2/pools", s.auth.RequireAuth(s.handleListPools))
@@ -290,12 +306,18 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/v2/config/subnets/{network}/reservations", s.auth.RequireAdmin(s.standbyGuard(s.handleV2CreateReservation)))
 	mux.HandleFunc("DELETE /api/v2/config/subnets/{network}/reservations/{mac}", s.auth.RequireAdmin(s.standbyGuard(s.handleV2DeleteReservation)))
 	mux.HandleFunc("POST /api/v2/config/subnets/{network}/reservations/import", s.auth.RequireAdmin(s.standbyGuard(s.handleV2ImportReservations)))
+	mux.HandleFunc("GET /api/v2/config/subnets6", s.auth.RequireAuth(s.handleV2ListSubnets6))
+	mux.HandleFunc("POST /api/v2/config/subnets6", s.auth.RequireAdmin(s.standbyGuard(s.handleV2CreateSubnet6)))
+	mux.HandleFunc("PUT /api/v2/config/subnets6/{network}", s.auth.RequireAdmin(s.standbyGuard(s.handleV2UpdateSubnet6)))
+	mux.HandleFunc("DELETE /api/v2/config/subnets6/{network}", s.auth.RequireAdmin(s.standbyGuard(s.handleV2DeleteSubnet6)))
 	mux.HandleFunc("GET /api/v2/config/defaults", s.auth.RequireAuth(s.handleV2GetDefaults))
 	mux.HandleFunc("PUT /api/v2/config/defaults", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetDefaults)))
 	mux.HandleFunc("GET /api/v2/config/conflict", s.auth.RequireAuth(s.handleV2GetConflict))
 	mux.HandleFunc("PUT /api/v2/config/conflict", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetConflict)))
 	mux.HandleFunc("GET /api/v2/config/ha", s.auth.RequireAuth(s.handleV2GetHA))
 	mux.HandleFunc("PUT /api/v2/config/ha", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetHA)))
+	mux.HandleFunc("GET /api/v2/config/client-classes", s.auth.RequireAuth(s.handleV2GetClientClasses))
+	mux.HandleFunc("PUT /api/v2/config/client-classes", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetClientClasses)))
 	mux.HandleFunc("GET /api/v2/config/hooks", s.auth.RequireAuth(s.handleV2GetHooks))
 	mux.HandleFunc("PUT /api/v2/config/hooks", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetHooks)))
 	mux.HandleFunc("GET /api/v2/config/ddns", s.auth.RequireAuth(s.handleV2GetDDNS))
@@ -308,6 +330,8 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /api/v2/config/syslog", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetSyslog)))
 	mux.HandleFunc("GET /api/v2/config/fingerprint", s.auth.RequireAuth(s.handleV2GetFingerprint))
 	mux.HandleFunc("PUT /api/v2/config/fingerprint", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetFingerprint)))
+	mux.HandleFunc("GET /api/v2/config/leasequery", s.auth.RequireAuth(s.handleV2GetLeaseQuery))
+	mux.HandleFunc("PUT /api/v2/config/leasequery", s.auth.RequireAdmin(s.standbyGuard(s.handleV2SetLeaseQuery)))
 	mux.HandleFunc("POST /api/v2/config/import", s.auth.RequireAdmin(s.standbyGuard(s.handleV2ImportTOML)))
 	mux.HandleFunc("GET /api/v2/config/raw", s.auth.RequireAuth(s.handleGetConfigRaw))
 	mux.HandleFunc("POST /api/v2/config/validate", s.auth.RequireAuth(s.handleValidateConfig))
@@ -358,10 +382,14 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v2/portauto/rules", s.auth.RequireAuth(s.handlePortAutoGetRules))
 	mux.HandleFunc("PUT /api/v2/portauto/rules", s.auth.RequireAdmin(s.handlePortAutoSetRules))
 	mux.HandleFunc("POST /api/v2/portauto/test", s.auth.RequireAdmin(s.handlePortAutoTest))
+	mux.HandleFunc("GET /api/v2/portauto/history", s.auth.RequireAuth(s.handlePortAutoHistory))
 
 	// HA
 	mux.HandleFunc("GET /api/v2/ha/status", s.auth.RequireAuth(s.handleHAStatus))
 	mux.HandleFunc("POST /api/v2/ha/failover", s.auth.RequireAdmin(s.handleHAFailover))
+	mux.HandleFunc("GET /api/v2/ha/members", s.auth.RequireAuth(s.handleHAMembers))
+	mux.HandleFunc("POST /api/v2/ha/members", s.auth.RequireAdmin(s.handleAddHAMember))
+	mux.HandleFunc("DELETE /api/v2/ha/members/{id}", s.auth.RequireAdmin(s.handleRemoveHAMember))
 
 	// Floating VIPs
 	mux.HandleFunc("GET /api/v2/vips", s.auth.RequireAuth(s.handleGetVIPs))
@@ -375,8 +403,12 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v2/dns/lists", s.auth.RequireAuth(s.handleDNSListStatus))
 	mux.HandleFunc("POST /api/v2/dns/lists/refresh", s.auth.RequireAdmin(s.handleDNSListRefresh))
 	mux.HandleFunc("POST /api/v2/dns/lists/test", s.auth.RequireAuth(s.handleDNSListTest))
+	mux.HandleFunc("GET /api/v2/dns/overrides", s.auth.RequireAuth(s.handleDNSOverrides))
+	mux.HandleFunc("POST /api/v2/dns/overrides", s.auth.RequireAdmin(s.handleDNSAddOverride))
+	mux.HandleFunc("DELETE /api/v2/dns/overrides/{id}", s.auth.RequireAdmin(s.handleDNSRemoveOverride))
 	mux.HandleFunc("GET /api/v2/dns/querylog", s.auth.RequireAuth(s.handleDNSQueryLog))
 	mux.HandleFunc("GET /api/v2/dns/querylog/stream", s.auth.RequireAuth(s.handleDNSQueryLogStream))
+	mux.HandleFunc("GET /api/v2/dns/querylog/top", s.auth.RequireAuth(s.handleDNSQueryLogTop))
 
 	// Stats
 	mux.HandleFunc("GET /api/v2/stats", s.auth.RequireAuth(s.handleGetStats))
@@ -391,6 +423,12 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v2/backup", s.auth.RequireAdmin(s.handleBackupExport))
 	mux.HandleFunc("POST /api/v2/backup/restore", s.auth.RequireAdmin(s.handleBackupRestore))
 
+	// Database maintenance
+	mux.HandleFunc("GET /api/v2/database", s.auth.RequireAdmin(s.handleDatabaseStatus))
+	mux.HandleFunc("GET /api/v2/database/backup", s.auth.RequireAdmin(s.handleDatabaseBackup))
+	mux.HandleFunc("POST /api/v2/database/compact", s.auth.RequireAdmin(s.handleDatabaseCompact))
+	mux.HandleFunc("POST /api/v2/database/snapshots", s.auth.RequireAdmin(s.handleDatabaseSnapshot))
+
 	// SPA fallback — serve index.html for all non-API paths
 	mux.HandleFunc("/", s.handleSPA)
 }
@@ -425,13 +463,18 @@ func JSONError(w http.ResponseWriter, status int, code, message string) {
 }
 
 // primaryWebURL returns the primary node's web UI URL by combining the
-// peer's IP with our own API listen port. Returns empty string if HA is
-// not configured or the address can't be parsed.
+// peer's IP (the leader's in cluster mode) with our own API listen port.
+// Returns empty string if HA is not configured or the address can't be
+// parsed.
 func (s *Server) primaryWebURL() string {
-	if !s.cfg.HA.Enabled || s.cfg.HA.PeerAddress == "" {
+	peerAddr := s.cfg.HA.PeerAddress
+	if s.cluster != nil {
+		peerAddr = s.clusterLeaderAddress()
+	}
+	if !s.cfg.HA.Enabled || peerAddr == "" {
 		return ""
 	}
-	peerHost, _, err := net.SplitHostPort(s.cfg.HA.PeerAddress)
+	peerHost, _, err := net.SplitHostPort(peerAddr)
 	if err != nil {
 		return ""
 	}
@@ -442,6 +485,18 @@ func (s *Server) primaryWebURL() string {
 	return fmt.Sprintf("http://%s", net.JoinHostPort(peerHost, apiPort))
 }
 
+// clusterLeaderAddress returns the sync address of the cluster leader, or
+// "" during an election.
+func (s *Server) clusterLeaderAddress() string {
+	leader := s.cluster.Leader()
+	for _, m := range s.cluster.Members() {
+		if m.ID == leader {
+			return m.Address
+		}
+	}
+	return ""
+}
+
 // setupGuard wraps a handler to block setup wizard endpoints after setup is complete.
 func (s *Server) setupGuard(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
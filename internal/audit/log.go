@@ -1,5 +1,6 @@
 // Package audit provides a persistent audit trail for DHCP lease events.
-// Records every lease assignment, renewal, release, and expiry with full context.
+// Records every lease assignment, renewal, release, and expiry with full context,
+// and every temporary DNS filtering override from creation to expiry.
 // Stored in a dedicated BoltDB bucket, separate from operational lease data.
 // Queryable by IP+timestamp for compliance (e.g. Telecommunications Act data retention).
 package audit
@@ -13,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	bolt "go.etcd.io/bbolt"
 )
@@ -57,7 +59,7 @@ type QueryParams struct {
 
 // Log provides append-only audit logging for DHCP lease events.
 type Log struct {
-	db     *bolt.DB
+	db     boltdb.DB
 	bus    *events.Bus
 	logger *slog.Logger
 	ch     chan events.Event
@@ -70,7 +72,7 @@ type Log struct {
 }
 
 // NewLog creates a new audit log backed by BoltDB.
-func NewLog(db *bolt.DB, bus *events.Bus, serverID string, logger *slog.Logger) (*Log, error) {
+func NewLog(db boltdb.DB, bus *events.Bus, serverID string, logger *slog.Logger) (*Log, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		if _, err := tx.CreateBucketIfNotExists(bucketAudit); err != nil {
 			return fmt.Errorf("creating audit bucket: %w", err)
@@ -131,12 +133,15 @@ func (l *Log) Stop() {
 
 // handleEvent converts a bus event into an audit record and persists it.
 func (l *Log) handleEvent(evt events.Event) {
-	// Only audit lease lifecycle events
+	// Only audit lease lifecycle and DNS override events
 	switch evt.Type {
 	case events.EventLeaseAck, events.EventLeaseRenew,
 		events.EventLeaseRelease, events.EventLeaseExpire,
 		events.EventLeaseDecline, events.EventLeaseNak:
 		// record these
+	case events.EventDNSOverrideAdd, events.EventDNSOverrideRemove, events.EventDNSOverrideExpire:
+		l.handleOverride(evt)
+		return
 	default:
 		return
 	}
@@ -179,6 +184,40 @@ func (l *Log) handleEvent(evt events.Event) {
 	}
 }
 
+// handleOverride records a DNS filtering override event. The override's
+// client goes in the IP or MAC column so per-device queries find it.
+func (l *Log) handleOverride(evt events.Event) {
+	o := evt.Override
+	if o == nil {
+		return
+	}
+	l.mu.RLock()
+	haRole := l.haRole
+	l.mu.RUnlock()
+
+	rec := Record{
+		Timestamp:    evt.Timestamp.UTC().Format(time.RFC3339Nano),
+		Event:        string(evt.Type),
+		LeaseExpiry:  o.Expires,
+		ServerID:     l.serverID,
+		HARoleAtTime: haRole,
+		Reason:       fmt.Sprintf("%s (id %s)", o, o.ID),
+	}
+	if ip := net.ParseIP(o.Client); ip != nil {
+		rec.IP = ip.String()
+	} else {
+		rec.MAC = o.Client
+	}
+	if o.By != "" {
+		rec.Reason += " by " + o.By
+	}
+
+	if err := l.append(rec); err != nil {
+		l.logger.Error("failed to write audit record",
+			"event", rec.Event, "override", o.ID, "error", err)
+	}
+}
+
 // append persists a single audit record to BoltDB with an auto-increment ID.
 func (l *Log) append(rec Record) error {
 	return l.db.Update(func(tx *bolt.Tx) error {
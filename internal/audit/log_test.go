@@ -278,3 +278,58 @@ func TestAuditNonLeaseEventsIgnored(t *testing.T) {
 		t.Errorf("expected 0 audit records for non-lease event, got %d", al.Count())
 	}
 }
+
+func TestAuditDNSOverrideEvents(t *testing.T) {
+	db := testDB(t)
+	bus := events.NewBus(100, testLogger())
+	go bus.Start()
+	defer bus.Stop()
+
+	al, err := NewLog(db, bus, "node-1", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go al.Start()
+	defer al.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	expires := time.Now().Add(30 * time.Minute).Unix()
+	bus.Publish(events.Event{
+		Type:      events.EventDNSOverrideAdd,
+		Timestamp: time.Now(),
+		Override: &events.OverrideData{
+			ID: "1", Action: "pause", Client: "10.0.0.50", Expires: expires, By: "admin",
+		},
+	})
+	bus.Publish(events.Event{
+		Type:      events.EventDNSOverrideExpire,
+		Timestamp: time.Now(),
+		Override: &events.OverrideData{
+			ID: "2", Action: "allow", Domain: "example.com", Client: "aa:bb:cc:dd:ee:01", Expires: expires,
+		},
+	})
+
+	time.Sleep(200 * time.Millisecond)
+
+	byIP, err := al.Query(QueryParams{IP: "10.0.0.50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byIP) != 1 {
+		t.Fatalf("expected 1 audit record for the paused client, got %d", len(byIP))
+	}
+	if byIP[0].Event != "dns.override_added" || byIP[0].LeaseExpiry != expires ||
+		byIP[0].Reason != "pause for 10.0.0.50 (id 1) by admin" {
+		t.Errorf("unexpected override record: %+v", byIP[0])
+	}
+
+	byMAC, err := al.Query(QueryParams{MAC: "aa:bb:cc:dd:ee:01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byMAC) != 1 || byMAC[0].Event != "dns.override_expired" {
+		t.Errorf("expected the expired override by MAC, got %+v", byMAC)
+	}
+}
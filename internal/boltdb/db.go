@@ -0,0 +1,199 @@
+// Package boltdb manages the BoltDB file shared by the lease store and the
+// packages that keep their state next to it (config, audit, fingerprints,
+// rogue servers, topology, conflicts, the HA update queue). It compacts the
+// file and takes hot snapshots of it while the server keeps running.
+package boltdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DB is what the packages sharing the database need from it. A *Handle
+// satisfies it, and so does a plain *bolt.DB.
+type DB interface {
+	View(fn func(*bolt.Tx) error) error
+	Update(fn func(*bolt.Tx) error) error
+}
+
+// compactTxSize is how many bytes Compact copies per transaction.
+const compactTxSize = 64 << 20
+
+// Handle is an open database file whose underlying *bolt.DB can be
+// replaced by a compacted copy. Callers keep the Handle; transactions
+// started after a swap run against the new file.
+type Handle struct {
+	path string
+	db   atomic.Pointer[bolt.DB]
+
+	// writeMu is held by every Update and by Compact while it copies, so
+	// no write can land in the old file after the copy started. BoltDB
+	// runs one write transaction at a time anyway.
+	writeMu sync.Mutex
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*Handle, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	h := &Handle{path: path}
+	h.db.Store(db)
+	return h, nil
+}
+
+// Path returns the database file path.
+func (h *Handle) Path() string {
+	return h.path
+}
+
+// Close closes the database.
+func (h *Handle) Close() error {
+	return h.db.Load().Close()
+}
+
+// View runs fn in a read-only transaction.
+func (h *Handle) View(fn func(*bolt.Tx) error) error {
+	for {
+		db := h.db.Load()
+		err := db.View(fn)
+		// The file was swapped between Load and the transaction start;
+		// fn didn't run, so try the new one
+		if errors.Is(err, bolt.ErrDatabaseNotOpen) && h.db.Load() != db {
+			continue
+		}
+		return err
+	}
+}
+
+// Update runs fn in a read-write transaction.
+func (h *Handle) Update(fn func(*bolt.Tx) error) error {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	return h.db.Load().Update(fn)
+}
+
+// WriteTo writes a consistent copy of the database to w without blocking
+// writers, returning the number of bytes written.
+func (h *Handle) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	err := h.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// CompactResult describes a finished compaction.
+type CompactResult struct {
+	Before   int64         `json:"before_bytes"`
+	After    int64         `json:"after_bytes"`
+	Duration time.Duration `json:"-"`
+}
+
+// Compact rewrites the database into a new file holding only live pages
+// and swaps it in. Reads carry on throughout; writes wait until the copy
+// is done, which for a lease database takes well under a second.
+func (h *Handle) Compact() (CompactResult, error) {
+	start := time.Now()
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	old := h.db.Load()
+	before, err := fileSize(h.path)
+	if err != nil {
+		return CompactResult{}, err
+	}
+
+	tmp := h.path + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return CompactResult{}, fmt.Errorf("creating compacted database: %w", err)
+	}
+	if err := bolt.Compact(dst, old, compactTxSize); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return CompactResult{}, fmt.Errorf("compacting database: %w", err)
+	}
+	// Renaming an open BoltDB file is fine: it works through its file
+	// descriptor, and the lock moves with the inode
+	if err := os.Rename(tmp, h.path); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return CompactResult{}, fmt.Errorf("replacing database with compacted copy: %w", err)
+	}
+	syncDir(filepath.Dir(h.path))
+
+	h.db.Store(dst)
+	old.Close() // waits for read transactions still on the old file
+
+	after, _ := fileSize(h.path)
+	return CompactResult{Before: before, After: after, Duration: time.Since(start)}, nil
+}
+
+// BucketStats is the size of one top-level bucket.
+type BucketStats struct {
+	Name  string `json:"name"`
+	Keys  int    `json:"keys"`
+	Bytes int    `json:"bytes"`
+}
+
+// Stats describes how the database file is used.
+type Stats struct {
+	Path      string        `json:"path"`
+	SizeBytes int64         `json:"size_bytes"` // file size on disk
+	FreeBytes int64         `json:"free_bytes"` // reclaimable by compaction
+	Buckets   []BucketStats `json:"buckets"`
+}
+
+// Stats reports the file size, the space a compaction would reclaim and
+// the size of each top-level bucket.
+func (h *Handle) Stats() (Stats, error) {
+	st := Stats{Path: h.path}
+	size, err := fileSize(h.path)
+	if err != nil {
+		return st, err
+	}
+	st.SizeBytes = size
+	err = h.View(func(tx *bolt.Tx) error {
+		used := tx.Size() - int64(tx.DB().Stats().FreeAlloc)
+		st.FreeBytes = max(size-used, 0)
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bs := b.Stats()
+			st.Buckets = append(st.Buckets, BucketStats{
+				Name:  string(name),
+				Keys:  bs.KeyN,
+				Bytes: bs.BranchInuse + bs.LeafInuse + bs.InlineBucketInuse,
+			})
+			return nil
+		})
+	})
+	return st, err
+}
+
+func fileSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// syncDir flushes a rename in dir to disk.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,117 @@
+package boltdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var testBucket = []byte("leases")
+
+func openTestDB(t *testing.T) *Handle {
+	t.Helper()
+	h, err := Open(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// fill writes n keys of size bytes each.
+func fill(t *testing.T, h *Handle, n, size int) {
+	t.Helper()
+	err := h.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(testBucket)
+		if err != nil {
+			return err
+		}
+		for i := range n {
+			if err := b.Put(fmt.Appendf(nil, "key-%05d", i), bytes.Repeat([]byte{'x'}, size)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+}
+
+func countKeys(t *testing.T, h *Handle) int {
+	t.Helper()
+	var n int
+	h.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(testBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+func TestCompactWhileWriting(t *testing.T) {
+	h := openTestDB(t)
+	fill(t, h, 2000, 1024)
+	// Delete most of it so there is space to reclaim
+	h.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(testBucket)
+		for i := 100; i < 2000; i++ {
+			b.Delete(fmt.Appendf(nil, "key-%05d", i))
+		}
+		return nil
+	})
+
+	st, err := h.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if st.FreeBytes == 0 || len(st.Buckets) != 1 || st.Buckets[0].Keys != 100 {
+		t.Fatalf("stats before compaction = %+v", st)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			err := h.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket(testBucket).Put(fmt.Appendf(nil, "new-%05d", i), []byte("v"))
+			})
+			if err != nil {
+				t.Errorf("write during compaction: %v", err)
+			}
+			countKeys(t, h)
+		}
+	}()
+	res, err := h.Compact()
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if res.After >= res.Before {
+		t.Errorf("compaction did not shrink the file: %d -> %d", res.Before, res.After)
+	}
+	if n := countKeys(t, h); n != 150 {
+		t.Errorf("keys after compaction = %d, want 150", n)
+	}
+	if _, err := os.Stat(h.Path() + ".compact"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary compaction file left behind")
+	}
+
+	// The handle still works against the new file after reopening
+	path := h.Path()
+	h.Close()
+	h2, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer h2.Close()
+	if n := countKeys(t, h2); n != 150 {
+		t.Errorf("keys after reopen = %d, want 150", n)
+	}
+}
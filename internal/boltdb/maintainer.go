@@ -0,0 +1,203 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	bolt "go.etcd.io/bbolt"
+)
+
+// statsInterval is how often the size metrics are refreshed.
+const statsInterval = time.Minute
+
+// minCompactFree is the least reclaimable space worth a compaction.
+const minCompactFree = 1 << 20
+
+// ErrNoSnapshotDir is returned by Snapshot without server.database.snapshot_dir.
+var ErrNoSnapshotDir = errors.New("server.database.snapshot_dir is not set")
+
+// Maintainer keeps the database size metrics current and runs the
+// scheduled compactions and snapshots from server.database.
+type Maintainer struct {
+	h         *Handle
+	snapshots *Snapshots // nil without a snapshot directory
+	cfg       config.DatabaseConfig
+	logger    *slog.Logger
+	cancel    context.CancelFunc
+
+	runMu          sync.Mutex // one compaction or snapshot at a time
+	mu             sync.Mutex // guards the fields below
+	lastCompaction time.Time
+	lastSnapshot   time.Time
+}
+
+// NewMaintainer creates the maintainer for h. check validates snapshots
+// (see Validate).
+func NewMaintainer(h *Handle, cfg config.DatabaseConfig, check func(*bolt.Tx) error, logger *slog.Logger) *Maintainer {
+	m := &Maintainer{h: h, cfg: cfg, logger: logger}
+	if cfg.SnapshotDir != "" {
+		m.snapshots = NewSnapshots(h, cfg.SnapshotDir, cfg.SnapshotRetain, check)
+		if list, _ := m.snapshots.List(); len(list) > 0 {
+			m.lastSnapshot = list[0].Time
+			metrics.DBLastSnapshot.Set(float64(list[0].Time.Unix()))
+		}
+	}
+	return m
+}
+
+// Start begins the maintenance loops.
+func (m *Maintainer) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.updateMetrics()
+	go m.every(ctx, statsInterval, m.updateMetrics)
+
+	if d, err := time.ParseDuration(m.cfg.CompactInterval); err == nil && d > 0 {
+		go m.every(ctx, d, m.maybeCompact)
+	}
+	if m.snapshots != nil {
+		if d, err := time.ParseDuration(m.cfg.SnapshotInterval); err == nil && d > 0 {
+			go m.every(ctx, d, func() { m.Snapshot() })
+		}
+	}
+	m.logger.Info("database maintenance started",
+		"path", m.h.Path(),
+		"compact_interval", m.cfg.CompactInterval,
+		"snapshot_dir", m.cfg.SnapshotDir)
+}
+
+// Stop ends the maintenance loops.
+func (m *Maintainer) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+func (m *Maintainer) every(ctx context.Context, d time.Duration, fn func()) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+// updateMetrics refreshes the size gauges.
+func (m *Maintainer) updateMetrics() {
+	st, err := m.h.Stats()
+	if err != nil {
+		m.logger.Warn("reading database stats", "error", err)
+		return
+	}
+	metrics.DBSize.Set(float64(st.SizeBytes))
+	metrics.DBFree.Set(float64(st.FreeBytes))
+	for _, b := range st.Buckets {
+		metrics.DBBucketKeys.WithLabelValues(b.Name).Set(float64(b.Keys))
+		metrics.DBBucketBytes.WithLabelValues(b.Name).Set(float64(b.Bytes))
+	}
+}
+
+// maybeCompact compacts once the reclaimable space reaches the threshold.
+func (m *Maintainer) maybeCompact() {
+	st, err := m.h.Stats()
+	if err != nil || st.SizeBytes == 0 {
+		return
+	}
+	if st.FreeBytes < minCompactFree || st.FreeBytes*100 < st.SizeBytes*int64(m.cfg.CompactThreshold) {
+		m.logger.Debug("database compaction not needed",
+			"size_bytes", st.SizeBytes, "free_bytes", st.FreeBytes)
+		return
+	}
+	m.Compact()
+}
+
+// Compact compacts the database now.
+func (m *Maintainer) Compact() (CompactResult, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	res, err := m.h.Compact()
+	if err != nil {
+		metrics.DBCompactions.WithLabelValues("error").Inc()
+		m.logger.Error("database compaction failed", "error", err)
+		return res, err
+	}
+	m.mu.Lock()
+	m.lastCompaction = time.Now()
+	m.mu.Unlock()
+	metrics.DBCompactions.WithLabelValues("success").Inc()
+	metrics.DBCompactionDuration.Observe(res.Duration.Seconds())
+	m.logger.Info("database compacted",
+		"before_bytes", res.Before,
+		"after_bytes", res.After,
+		"duration", res.Duration.String())
+	m.updateMetrics()
+	return res, nil
+}
+
+// Snapshot takes a snapshot now.
+func (m *Maintainer) Snapshot() (SnapshotInfo, error) {
+	if m.snapshots == nil {
+		return SnapshotInfo{}, ErrNoSnapshotDir
+	}
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	info, err := m.snapshots.Take()
+	if err != nil {
+		metrics.DBSnapshots.WithLabelValues("error").Inc()
+		m.logger.Error("database snapshot failed", "error", err)
+		return info, err
+	}
+	m.mu.Lock()
+	m.lastSnapshot = info.Time
+	m.mu.Unlock()
+	metrics.DBSnapshots.WithLabelValues("success").Inc()
+	metrics.DBLastSnapshot.Set(float64(info.Time.Unix()))
+	m.logger.Info("database snapshot written", "name", info.Name, "size_bytes", info.SizeBytes)
+	return info, nil
+}
+
+// Snapshots returns the snapshot directory manager, or nil without one.
+func (m *Maintainer) Snapshots() *Snapshots {
+	return m.snapshots
+}
+
+// Handle returns the database.
+func (m *Maintainer) Handle() *Handle {
+	return m.h
+}
+
+// Status describes the database and its maintenance.
+type Status struct {
+	Stats
+	LastCompaction time.Time      `json:"last_compaction,omitzero"`
+	LastSnapshot   time.Time      `json:"last_snapshot,omitzero"`
+	SnapshotDir    string         `json:"snapshot_dir,omitempty"`
+	Snapshots      []SnapshotInfo `json:"snapshots"`
+}
+
+// Status returns the current database stats and snapshot list.
+func (m *Maintainer) Status() (Status, error) {
+	st, err := m.h.Stats()
+	if err != nil {
+		return Status{}, err
+	}
+	m.mu.Lock()
+	status := Status{Stats: st, LastCompaction: m.lastCompaction, LastSnapshot: m.lastSnapshot}
+	m.mu.Unlock()
+	status.Snapshots = []SnapshotInfo{}
+	if m.snapshots != nil {
+		status.SnapshotDir = m.snapshots.Dir()
+		if list, err := m.snapshots.List(); err == nil && list != nil {
+			status.Snapshots = list
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,226 @@
+package boltdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// snapshotTimeFormat names snapshot files; it sorts chronologically.
+const snapshotTimeFormat = "20060102-150405.000"
+
+// SnapshotInfo describes one snapshot file.
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"-"`
+	SizeBytes int64     `json:"size_bytes"`
+	Time      time.Time `json:"time"`
+}
+
+// Snapshots writes hot copies of the database to a directory as
+// <db name>-<time>.db and keeps the newest few.
+type Snapshots struct {
+	h      *Handle
+	dir    string
+	retain int
+	prefix string
+	check  func(*bolt.Tx) error
+}
+
+// NewSnapshots keeps up to retain snapshots of h in dir. check, if set,
+// validates each snapshot after it is written (see Validate).
+func NewSnapshots(h *Handle, dir string, retain int, check func(*bolt.Tx) error) *Snapshots {
+	base := strings.TrimSuffix(filepath.Base(h.Path()), filepath.Ext(h.Path()))
+	return &Snapshots{h: h, dir: dir, retain: retain, prefix: base + "-", check: check}
+}
+
+// Dir returns the snapshot directory.
+func (s *Snapshots) Dir() string {
+	return s.dir
+}
+
+// Take writes a snapshot, validates it and prunes the oldest beyond the
+// retention count. Writers are not blocked.
+func (s *Snapshots) Take() (SnapshotInfo, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	now := time.Now().UTC()
+	name := s.prefix + now.Format(snapshotTimeFormat) + ".db"
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("creating snapshot: %w", err)
+	}
+	n, err := s.h.WriteTo(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = Validate(tmp, s.check)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return SnapshotInfo{}, fmt.Errorf("writing snapshot %s: %w", name, err)
+	}
+	syncDir(s.dir)
+
+	s.prune()
+	return SnapshotInfo{Name: name, Path: path, SizeBytes: n, Time: now}, nil
+}
+
+// List returns the snapshots in the directory, newest first.
+func (s *Snapshots) List() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []SnapshotInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, s.prefix) || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		at, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, s.prefix), ".db"))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, SnapshotInfo{Name: name, Path: filepath.Join(s.dir, name), SizeBytes: info.Size(), Time: at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+	return list, nil
+}
+
+// Get returns the snapshot called name.
+func (s *Snapshots) Get(name string) (SnapshotInfo, bool) {
+	list, _ := s.List()
+	for _, info := range list {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return SnapshotInfo{}, false
+}
+
+// prune removes snapshots beyond the retention count.
+func (s *Snapshots) prune() {
+	if s.retain <= 0 {
+		return
+	}
+	list, err := s.List()
+	if err != nil || len(list) <= s.retain {
+		return
+	}
+	for _, old := range list[s.retain:] {
+		os.Remove(old.Path)
+	}
+}
+
+// Validate opens the database file at path read-only, checks its page
+// structure and runs check, if set, against it.
+func Validate(path string, check func(*bolt.Tx) error) error {
+	db, err := bolt.Open(path, 0400, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		var corrupt error
+		for err := range tx.Check() { // drain it, or the checker blocks
+			if corrupt == nil {
+				corrupt = fmt.Errorf("%s is corrupt: %w", path, err)
+			}
+		}
+		if corrupt != nil {
+			return corrupt
+		}
+		if check != nil {
+			return check(tx)
+		}
+		return nil
+	})
+}
+
+// ErrInUse is returned by Restore while a server has the database open.
+var ErrInUse = errors.New("database is in use — stop athena-dhcpd first")
+
+// Restore validates the snapshot and replaces the database at dbPath with
+// it. The database must not be open; the file being replaced is kept as
+// <dbPath>.pre-restore-<time>, whose path is returned.
+func Restore(snapshot, dbPath string, check func(*bolt.Tx) error) (string, error) {
+	if err := Validate(snapshot, check); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+		if errors.Is(err, bolt.ErrTimeout) {
+			return "", ErrInUse
+		}
+		if err == nil {
+			db.Close()
+		}
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(snapshot, tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("copying snapshot: %w", err)
+	}
+	var kept string
+	if _, err := os.Stat(dbPath); err == nil {
+		kept = dbPath + ".pre-restore-" + time.Now().UTC().Format(snapshotTimeFormat)
+		if err := os.Rename(dbPath, kept); err != nil {
+			os.Remove(tmp)
+			return "", fmt.Errorf("moving current database aside: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return kept, fmt.Errorf("moving snapshot into place: %w", err)
+	}
+	syncDir(filepath.Dir(dbPath))
+	return kept, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
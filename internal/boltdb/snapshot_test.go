@@ -0,0 +1,96 @@
+package boltdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func checkTestBucket(tx *bolt.Tx) error {
+	if tx.Bucket(testBucket) == nil {
+		return errors.New("leases bucket missing")
+	}
+	return nil
+}
+
+func TestSnapshotsRetention(t *testing.T) {
+	h := openTestDB(t)
+	fill(t, h, 10, 100)
+	s := NewSnapshots(h, filepath.Join(t.TempDir(), "snapshots"), 2, checkTestBucket)
+
+	var taken []SnapshotInfo
+	for range 3 {
+		info, err := s.Take()
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		if !strings.HasPrefix(info.Name, "leases-") {
+			t.Errorf("snapshot name = %q", info.Name)
+		}
+		taken = append(taken, info)
+		time.Sleep(2 * time.Millisecond) // names have millisecond resolution
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != taken[2].Name || list[1].Name != taken[1].Name {
+		t.Fatalf("List = %+v, want the two newest", list)
+	}
+	if _, ok := s.Get(taken[0].Name); ok {
+		t.Errorf("oldest snapshot was not pruned")
+	}
+	if err := Validate(list[0].Path, checkTestBucket); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	h := openTestDB(t)
+	fill(t, h, 10, 100)
+	s := NewSnapshots(h, t.TempDir(), 0, nil)
+	snap, err := s.Take()
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	fill(t, h, 20, 100)
+
+	if _, err := Restore(snap.Path, h.Path(), checkTestBucket); !errors.Is(err, ErrInUse) {
+		t.Fatalf("Restore with the database open: err = %v, want ErrInUse", err)
+	}
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.db")
+	os.WriteFile(corrupt, []byte("not a database"), 0600)
+	empty := filepath.Join(t.TempDir(), "empty.db")
+	db, _ := bolt.Open(empty, 0600, nil)
+	db.Close()
+
+	h.Close()
+	for _, bad := range []string{corrupt, empty} {
+		if _, err := Restore(bad, h.Path(), checkTestBucket); err == nil {
+			t.Errorf("Restore(%s) succeeded", filepath.Base(bad))
+		}
+	}
+
+	kept, err := Restore(snap.Path, h.Path(), checkTestBucket)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("previous database not kept: %v", err)
+	}
+	h2, err := Open(h.Path())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer h2.Close()
+	if n := countKeys(t, h2); n != 10 {
+		t.Errorf("keys after restore = %d, want 10", n)
+	}
+}
@@ -0,0 +1,621 @@
+// Package clientclass implements the match expression language used by
+// client classes. Expressions are compiled once at config load and evaluated
+// against each incoming packet.
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr     = or
+//	or       = and { "or" and }
+//	and      = not { "and" not }
+//	not      = "not" not | compare
+//	compare  = operand [ op operand ]
+//	op       = "==" | "!=" | "contains" | "startswith" | "endswith" | "matches" | "in"
+//	operand  = string | hex | "true" | "false" | field | func | "(" expr ")"
+//	func     = "substring" "(" operand "," int "," int ")" | "hex" "(" operand ")"
+//	         | "lower" "(" operand ")" | "upper" "(" operand ")"
+//
+// Fields: option[N], option[N].exists, mac, mac.oui, giaddr, relay.circuit_id,
+// relay.remote_id, vendor_class, user_class, hostname, device.type,
+// device.name, device.os.
+package clientclass
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// Client is the view of a DHCP client that expressions are evaluated against.
+type Client struct {
+	MAC         net.HardwareAddr
+	GIAddr      net.IP
+	Options     map[dhcpv4.OptionCode][]byte
+	VendorClass string
+	UserClass   string
+	CircuitID   string
+	RemoteID    string
+	DeviceType  string
+	DeviceName  string
+	DeviceOS    string
+}
+
+// Expr is a compiled match expression.
+type Expr struct {
+	src  string
+	root boolNode
+}
+
+// Compile parses and type-checks a match expression.
+func Compile(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("col %d: unexpected %q", t.pos+1, t.text)
+	}
+	root, ok := n.(boolNode)
+	if !ok {
+		return nil, fmt.Errorf("expression must evaluate to true or false")
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Match reports whether the client satisfies the expression.
+func (e *Expr) Match(c *Client) bool {
+	return e.root.evalBool(c)
+}
+
+// String returns the source text of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// --- Lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokHex
+	tokInt
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	data []byte
+	num  int
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("col %d: unterminated string", i+1)
+			}
+			toks = append(toks, token{kind: tokString, text: src[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '0' && i+1 < len(src) && (src[i+1] == 'x' || src[i+1] == 'X'):
+			j := i + 2
+			for j < len(src) && isHexDigit(src[j]) {
+				j++
+			}
+			b, err := hex.DecodeString(src[i+2 : j])
+			if err != nil || j == i+2 {
+				return nil, fmt.Errorf("col %d: invalid hex literal %q", i+1, src[i:j])
+			}
+			toks = append(toks, token{kind: tokHex, text: src[i:j], data: b, pos: i})
+			i = j
+		case c >= '0' && c <= '9' || c == '-':
+			j := i + 1
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(src[i:j])
+			if err != nil {
+				return nil, fmt.Errorf("col %d: invalid number %q", i+1, src[i:j])
+			}
+			toks = append(toks, token{kind: tokInt, text: src[i:j], num: n, pos: i})
+			i = j
+		case isIdentChar(c):
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: strings.ToLower(src[i:j]), pos: i})
+			i = j
+		case c == '=' || c == '!':
+			if i+1 >= len(src) || src[i+1] != '=' {
+				return nil, fmt.Errorf("col %d: unexpected %q", i+1, c)
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i : i+2], pos: i})
+			i += 2
+		case strings.IndexByte("()[],.", c) >= 0:
+			toks = append(toks, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("col %d: unexpected character %q", i+1, c)
+		}
+	}
+	return append(toks, token{kind: tokEOF, text: "end of expression", pos: len(src)}), nil
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// --- Parser ---
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given keyword or punctuation.
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokIdent || t.kind == tokPunct) && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.peek(); !p.accept(text) {
+		return fmt.Errorf("col %d: expected %q, got %q", t.pos+1, text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, r, err := bothBool(left, right, "or")
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l, r, err := bothBool(left, right, "and")
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		b, ok := x.(boolNode)
+		if !ok {
+			return nil, fmt.Errorf("operand of \"not\" must be true or false")
+		}
+		return &notNode{b}, nil
+	}
+	return p.parseCompare()
+}
+
+var compareOps = map[string]bool{
+	"==": true, "!=": true, "contains": true, "startswith": true,
+	"endswith": true, "matches": true, "in": true,
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokIdent || !compareOps[t.text] {
+		return left, nil
+	}
+	p.next()
+	op := t.text
+
+	l, ok := left.(valueNode)
+	if !ok {
+		return nil, fmt.Errorf("col %d: left side of %q must be a value", t.pos+1, op)
+	}
+
+	rt := p.peek()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "matches":
+		lit, ok := right.(*literalNode)
+		if !ok {
+			return nil, fmt.Errorf("col %d: right side of \"matches\" must be a string pattern", rt.pos+1)
+		}
+		return &globNode{x: l, re: globToRegexp(string(lit.data))}, nil
+	case "in":
+		lit, ok := right.(*literalNode)
+		if !ok {
+			return nil, fmt.Errorf("col %d: right side of \"in\" must be a CIDR string", rt.pos+1)
+		}
+		_, network, err := net.ParseCIDR(string(lit.data))
+		if err != nil {
+			return nil, fmt.Errorf("col %d: invalid CIDR %q", rt.pos+1, lit.data)
+		}
+		return &inNode{x: l, network: network}, nil
+	}
+
+	r, ok := right.(valueNode)
+	if !ok {
+		return nil, fmt.Errorf("col %d: right side of %q must be a value", rt.pos+1, op)
+	}
+	return &compareNode{op: op, l: l, r: r}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &literalNode{[]byte(t.text)}, nil
+	case tokHex:
+		return &literalNode{t.data}, nil
+	case tokInt:
+		return nil, fmt.Errorf("col %d: numbers are only allowed as substring() arguments", t.pos+1)
+	case tokPunct:
+		if t.text != "(" {
+			break
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return &boolLiteral{t.text == "true"}, nil
+		case "option":
+			return p.parseOption(t)
+		case "substring", "hex", "lower", "upper":
+			return p.parseFunc(t)
+		}
+		if compareOps[t.text] || t.text == "and" || t.text == "or" || t.text == "not" {
+			break
+		}
+		name := t.text
+		for p.accept(".") {
+			part := p.next()
+			if part.kind != tokIdent {
+				return nil, fmt.Errorf("col %d: expected field name after %q", part.pos+1, name+".")
+			}
+			name += "." + part.text
+		}
+		fn, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("col %d: unknown field %q", t.pos+1, name)
+		}
+		return &fieldNode{name: name, get: fn}, nil
+	}
+	return nil, fmt.Errorf("col %d: unexpected %q", t.pos+1, t.text)
+}
+
+// parseOption parses option[N], option[N].exists and option[N].hex.
+func (p *parser) parseOption(t token) (node, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	n := p.next()
+	if n.kind != tokInt || n.num < 1 || n.num > 254 {
+		return nil, fmt.Errorf("col %d: option code must be 1-254", n.pos+1)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	code := dhcpv4.OptionCode(n.num)
+	if p.accept(".") {
+		attr := p.next()
+		switch attr.text {
+		case "exists":
+			return &optionExistsNode{code}, nil
+		case "hex":
+			return &hexNode{&optionNode{code}}, nil
+		}
+		return nil, fmt.Errorf("col %d: unknown option attribute %q (want exists or hex)", attr.pos+1, attr.text)
+	}
+	return &optionNode{code}, nil
+}
+
+func (p *parser) parseFunc(t token) (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	argTok := p.peek()
+	arg, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	x, ok := arg.(valueNode)
+	if !ok {
+		return nil, fmt.Errorf("col %d: %s() argument must be a value", argTok.pos+1, t.text)
+	}
+
+	var out node
+	switch t.text {
+	case "substring":
+		var nums [2]int
+		for i := range nums {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			n := p.next()
+			if n.kind != tokInt {
+				return nil, fmt.Errorf("col %d: substring() expects integer offset and length", n.pos+1)
+			}
+			nums[i] = n.num
+		}
+		out = &substringNode{x: x, start: nums[0], length: nums[1]}
+	case "hex":
+		out = &hexNode{x}
+	case "lower":
+		out = &caseNode{x: x, fn: bytes.ToLower}
+	case "upper":
+		out = &caseNode{x: x, fn: bytes.ToUpper}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func bothBool(l, r node, op string) (boolNode, boolNode, error) {
+	lb, lok := l.(boolNode)
+	rb, rok := r.(boolNode)
+	if !lok || !rok {
+		return nil, nil, fmt.Errorf("operands of %q must be true or false", op)
+	}
+	return lb, rb, nil
+}
+
+// globToRegexp converts a glob pattern to an anchored regexp. Unlike
+// filepath.Match, '*' also matches '/' so "eth0/*" covers "eth0/1/3".
+func globToRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// --- Fields ---
+
+var fields = map[string]func(c *Client) []byte{
+	"mac": func(c *Client) []byte {
+		if len(c.MAC) == 0 {
+			return nil
+		}
+		return []byte(c.MAC.String())
+	},
+	"mac.oui": func(c *Client) []byte {
+		if len(c.MAC) < 3 {
+			return nil
+		}
+		return []byte(fmt.Sprintf("%02x:%02x:%02x", c.MAC[0], c.MAC[1], c.MAC[2]))
+	},
+	"giaddr": func(c *Client) []byte {
+		if c.GIAddr == nil || c.GIAddr.IsUnspecified() {
+			return nil
+		}
+		return []byte(c.GIAddr.String())
+	},
+	"relay.circuit_id": func(c *Client) []byte { return []byte(c.CircuitID) },
+	"relay.remote_id":  func(c *Client) []byte { return []byte(c.RemoteID) },
+	"vendor_class":     func(c *Client) []byte { return []byte(c.VendorClass) },
+	"user_class":       func(c *Client) []byte { return []byte(c.UserClass) },
+	"hostname":         func(c *Client) []byte { return c.Options[dhcpv4.OptionHostname] },
+	"device.type":      func(c *Client) []byte { return []byte(c.DeviceType) },
+	"device.name":      func(c *Client) []byte { return []byte(c.DeviceName) },
+	"device.os":        func(c *Client) []byte { return []byte(c.DeviceOS) },
+}
+
+// --- AST ---
+
+type node interface{}
+
+type boolNode interface {
+	evalBool(c *Client) bool
+}
+
+type valueNode interface {
+	evalBytes(c *Client) []byte
+}
+
+type boolLiteral struct{ v bool }
+
+func (n *boolLiteral) evalBool(*Client) bool { return n.v }
+
+type andNode struct{ l, r boolNode }
+
+func (n *andNode) evalBool(c *Client) bool { return n.l.evalBool(c) && n.r.evalBool(c) }
+
+type orNode struct{ l, r boolNode }
+
+func (n *orNode) evalBool(c *Client) bool { return n.l.evalBool(c) || n.r.evalBool(c) }
+
+type notNode struct{ x boolNode }
+
+func (n *notNode) evalBool(c *Client) bool { return !n.x.evalBool(c) }
+
+type compareNode struct {
+	op   string
+	l, r valueNode
+}
+
+func (n *compareNode) evalBool(c *Client) bool {
+	l, r := n.l.evalBytes(c), n.r.evalBytes(c)
+	switch n.op {
+	case "==":
+		return bytes.Equal(l, r)
+	case "!=":
+		return !bytes.Equal(l, r)
+	case "contains":
+		return len(l) > 0 && bytes.Contains(l, r)
+	case "startswith":
+		return len(l) > 0 && bytes.HasPrefix(l, r)
+	case "endswith":
+		return len(l) > 0 && bytes.HasSuffix(l, r)
+	}
+	return false
+}
+
+type globNode struct {
+	x  valueNode
+	re *regexp.Regexp
+}
+
+func (n *globNode) evalBool(c *Client) bool {
+	v := n.x.evalBytes(c)
+	return len(v) > 0 && n.re.Match(v)
+}
+
+type inNode struct {
+	x       valueNode
+	network *net.IPNet
+}
+
+func (n *inNode) evalBool(c *Client) bool {
+	ip := net.ParseIP(string(n.x.evalBytes(c)))
+	return ip != nil && n.network.Contains(ip)
+}
+
+type literalNode struct{ data []byte }
+
+func (n *literalNode) evalBytes(*Client) []byte { return n.data }
+
+type fieldNode struct {
+	name string
+	get  func(c *Client) []byte
+}
+
+func (n *fieldNode) evalBytes(c *Client) []byte { return n.get(c) }
+
+type optionNode struct{ code dhcpv4.OptionCode }
+
+func (n *optionNode) evalBytes(c *Client) []byte { return c.Options[n.code] }
+
+type optionExistsNode struct{ code dhcpv4.OptionCode }
+
+func (n *optionExistsNode) evalBool(c *Client) bool {
+	_, ok := c.Options[n.code]
+	return ok
+}
+
+type hexNode struct{ x valueNode }
+
+func (n *hexNode) evalBytes(c *Client) []byte {
+	return []byte(hex.EncodeToString(n.x.evalBytes(c)))
+}
+
+type caseNode struct {
+	x  valueNode
+	fn func([]byte) []byte
+}
+
+func (n *caseNode) evalBytes(c *Client) []byte { return n.fn(n.x.evalBytes(c)) }
+
+// substringNode extracts length bytes from start. A negative start counts
+// from the end; a negative length means "to the end".
+type substringNode struct {
+	x             valueNode
+	start, length int
+}
+
+func (n *substringNode) evalBytes(c *Client) []byte {
+	v := n.x.evalBytes(c)
+	start := n.start
+	if start < 0 {
+		start = max(len(v)+start, 0)
+	}
+	if start >= len(v) {
+		return nil
+	}
+	end := len(v)
+	if n.length >= 0 && start+n.length < end {
+		end = start + n.length
+	}
+	return v[start:end]
+}
@@ -0,0 +1,108 @@
+package clientclass
+
+import (
+	"net"
+	"testing"
+
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+func testClient() *Client {
+	return &Client{
+		MAC:    net.HardwareAddr{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e},
+		GIAddr: net.IPv4(10, 1, 2, 1),
+		Options: map[dhcpv4.OptionCode][]byte{
+			dhcpv4.OptionVendorClassID: []byte("PXEClient:Arch:00007:UNDI:003016"),
+			dhcpv4.OptionHostname:      []byte("Desk-Phone-12"),
+			93:                         {0x00, 0x07},
+		},
+		VendorClass: "PXEClient:Arch:00007:UNDI:003016",
+		CircuitID:   "eth0/1/3",
+		RemoteID:    "switch-a",
+		DeviceType:  "voip",
+		DeviceOS:    "Polycom",
+	}
+}
+
+func TestCompileAndMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`vendor_class startswith 'PXEClient'`, true},
+		{`substring(option[60], 0, 9) == 'PXEClient'`, true},
+		{`option[93] == 0x0007`, true},
+		{`option[93] == 0x0006`, false},
+		{`option[93].exists and not option[77].exists`, true},
+		{`option[93].hex == '0007'`, true},
+		{`hex(option[93]) contains '07'`, true},
+		{`mac.oui == '00:1a:2b'`, true},
+		{`mac matches '00:1a:2b:*'`, true},
+		{`relay.circuit_id matches 'eth0/*'`, true},
+		{`relay.remote_id == "switch-b"`, false},
+		{`giaddr in '10.1.0.0/16'`, true},
+		{`giaddr in '192.168.0.0/16'`, false},
+		{`device.type == 'voip' or device.type == 'phone'`, true},
+		{`lower(hostname) startswith 'desk-phone'`, true},
+		{`upper(device.os) == 'POLYCOM'`, true},
+		{`substring(hostname, -2, -1) == '12'`, true},
+		{`substring(hostname, 100, 4) == ''`, true},
+		{`NOT (device.type == 'printer') AND true`, true},
+		{`user_class == 'x'`, false},
+		{`user_class != 'x'`, true},
+		{`hostname contains ''`, true},
+		{`device.name contains ''`, false},
+	}
+
+	c := testClient()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile error: %v", err)
+			}
+			if got := e.Match(c); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	bad := []string{
+		``,
+		`vendor_class`,
+		`vendor_class == `,
+		`option[0] == 'x'`,
+		`option[60 == 'x'`,
+		`option[60].size == 1`,
+		`nosuchfield == 'x'`,
+		`'abc`,
+		`0xzz == 'x'`,
+		`0x123 == 'x'`,
+		`giaddr in 'not-a-cidr'`,
+		`giaddr in vendor_class`,
+		`mac matches hostname`,
+		`true and 'x'`,
+		`not 'x'`,
+		`substring(hostname, 'a', 1) == 'x'`,
+		`hostname == 'x' extra`,
+		`42 == 'x'`,
+		`hostname = 'x'`,
+	}
+	for _, expr := range bad {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%q) should fail", expr)
+		}
+	}
+}
+
+func TestMatchEmptyClient(t *testing.T) {
+	e, err := Compile(`option[60] startswith 'MSFT' or mac.oui == '00:11:22' or giaddr in '0.0.0.0/0'`)
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+	if e.Match(&Client{}) {
+		t.Error("empty client should not match")
+	}
+}
@@ -2,13 +2,23 @@
 package config
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/miekg/dns"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/clientclass"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
 )
 
 // Config is the top-level configuration for athena-dhcpd.
@@ -22,7 +32,10 @@ type Config struct {
 	Fingerprint          FingerprintConfig          `toml:"fingerprint" json:"fingerprint"`
 	Syslog               SyslogConfig               `toml:"syslog" json:"syslog"`
 	HostnameSanitisation HostnameSanitisationConfig `toml:"hostname_sanitisation" json:"hostname_sanitisation"`
+	LeaseQuery           LeaseQueryConfig           `toml:"leasequery" json:"leasequery"`
 	Subnets              []SubnetConfig             `toml:"subnet"`
+	Subnets6             []Subnet6Config            `toml:"subnet6" json:"subnet6,omitempty"`
+	ClientClasses        []ClientClassConfig        `toml:"client_class" json:"client_class"`
 	Defaults             DefaultsConfig             `toml:"defaults"`
 	API                  APIConfig                  `toml:"api"`
 }
@@ -67,15 +80,51 @@ type FingerprintConfig struct {
 	FingerbankURL string `toml:"fingerbank_url" json:"fingerbank_url"`
 }
 
+// LeaseQueryConfig holds DHCPLEASEQUERY (RFC 4388) and bulk leasequery
+// (RFC 6926) settings. Only relays listed in AllowedRelays may query.
+type LeaseQueryConfig struct {
+	Enabled        bool     `toml:"enabled" json:"enabled"`
+	AllowedRelays  []string `toml:"allowed_relays" json:"allowed_relays"`   // IPs or CIDRs
+	BulkEnabled    bool     `toml:"bulk_enabled" json:"bulk_enabled"`       // RFC 6926 over TCP
+	BulkListen     string   `toml:"bulk_listen" json:"bulk_listen"`         // default "0.0.0.0:67"
+	MaxConnections int      `toml:"max_connections" json:"max_connections"` // concurrent bulk connections (default: 10)
+	IdleTimeout    string   `toml:"idle_timeout" json:"idle_timeout"`       // bulk connection idle timeout (default: "60s")
+}
+
 // ServerConfig holds core server settings.
 type ServerConfig struct {
-	Interface   string          `toml:"interface"`
-	BindAddress string          `toml:"bind_address"`
-	ServerID    string          `toml:"server_id"`
-	LogLevel    string          `toml:"log_level"`
-	LeaseDB     string          `toml:"lease_db"`
-	PIDFile     string          `toml:"pid_file"`
-	RateLimit   RateLimitConfig `toml:"rate_limit"`
+	Interface    string          `toml:"interface"`
+	BindAddress  string          `toml:"bind_address"`
+	ServerID     string          `toml:"server_id"`
+	LogLevel     string          `toml:"log_level"`
+	LeaseDB      string          `toml:"lease_db"`
+	LeaseBackend string          `toml:"lease_backend"` // where leases live: "boltdb" (in lease_db) or "sqlite"
+	LeaseDSN     string          `toml:"lease_dsn"`     // SQLite database file for the sqlite backend
+	PIDFile      string          `toml:"pid_file"`
+	RateLimit    RateLimitConfig `toml:"rate_limit"`
+	Database     DatabaseConfig  `toml:"database"`
+}
+
+// Pool allocation strategies (pool.allocation_strategy).
+const (
+	PoolStrategyLRU        = "lru"
+	PoolStrategySequential = "sequential"
+	PoolStrategyRandom     = "random"
+)
+
+// Lease storage backends.
+const (
+	LeaseBackendBoltDB = "boltdb"
+	LeaseBackendSQLite = "sqlite"
+)
+
+// DatabaseConfig holds maintenance settings for the lease database file.
+type DatabaseConfig struct {
+	CompactInterval  string `toml:"compact_interval"`  // how often to check whether to compact ("" = never)
+	CompactThreshold int    `toml:"compact_threshold"` // reclaimable percentage of the file that triggers a compaction (default: 25)
+	SnapshotDir      string `toml:"snapshot_dir"`      // directory for hot snapshots ("" = none)
+	SnapshotInterval string `toml:"snapshot_interval"` // how often to take one (default: "1h")
+	SnapshotRetain   int    `toml:"snapshot_retain"`   // snapshots kept (default: 24)
 }
 
 // RateLimitConfig holds anti-starvation settings (RFC 5765).
@@ -102,22 +151,85 @@ type ConflictDetectionConfig struct {
 
 // HAConfig holds high availability settings.
 type HAConfig struct {
-	Enabled           bool        `toml:"enabled" json:"enabled"`
-	Role              string      `toml:"role" json:"role"`
-	PeerAddress       string      `toml:"peer_address" json:"peer_address"`
-	ListenAddress     string      `toml:"listen_address" json:"listen_address"`
-	HeartbeatInterval string      `toml:"heartbeat_interval" json:"heartbeat_interval"`
-	FailoverTimeout   string      `toml:"failover_timeout" json:"failover_timeout"`
-	SyncBatchSize     int         `toml:"sync_batch_size" json:"sync_batch_size"`
-	TLS               HATLSConfig `toml:"tls" json:"tls"`
+	Enabled           bool               `toml:"enabled" json:"enabled"`
+	Role              string             `toml:"role" json:"role"`
+	PeerAddress       string             `toml:"peer_address" json:"peer_address"`
+	ListenAddress     string             `toml:"listen_address" json:"listen_address"`
+	HeartbeatInterval string             `toml:"heartbeat_interval" json:"heartbeat_interval"`
+	FailoverTimeout   string             `toml:"failover_timeout" json:"failover_timeout"`
+	SyncBatchSize     int                `toml:"sync_batch_size" json:"sync_batch_size"`
+	SyncQueueSize     int                `toml:"sync_queue_size" json:"sync_queue_size"`   // max bindings with unacknowledged updates (default: 10000)
+	SyncAck           bool               `toml:"sync_ack" json:"sync_ack"`                 // delay DHCPACK until the peer acknowledges the binding
+	SyncAckTimeout    string             `toml:"sync_ack_timeout" json:"sync_ack_timeout"` // how long to wait for that acknowledgement (default: "1s")
+	Mode              string             `toml:"mode" json:"mode"`                         // "active-standby" (default) or "load-balancing"
+	MCLT              string             `toml:"mclt" json:"mclt"`                         // maximum client lead time (default: "1h")
+	SafetyPeriod      string             `toml:"safety_period" json:"safety_period"`       // partner-down wait before using the peer's free addresses (default: mclt)
+	AuthKey           string             `toml:"auth_key" json:"auth_key,omitempty"`       // pre-shared key every sync frame is signed with
+	AuthKeyFile       string             `toml:"auth_key_file" json:"auth_key_file,omitempty"`
+	AuthWindow        string             `toml:"auth_window" json:"auth_window"` // max clock difference for a signed frame (default: "30s")
+	TLS               HATLSConfig        `toml:"tls" json:"tls"`
+	SplitBrain        HASplitBrainConfig `toml:"split_brain" json:"split_brain"`
+
+	// Cluster mode: this node's ID and every member, this node included.
+	NodeID  string     `toml:"node_id" json:"node_id,omitempty"`
+	Members []HAMember `toml:"member" json:"member,omitempty"`
+}
+
+// HA modes.
+const (
+	HAModeActiveStandby = "active-standby"
+	HAModeLoadBalancing = "load-balancing"
+	HAModeCluster       = "cluster"
+)
+
+// LoadBalancing reports whether both peers serve clients split by hash.
+func (h *HAConfig) LoadBalancing() bool {
+	return h.Mode == HAModeLoadBalancing
+}
+
+// Cluster reports whether three or more nodes elect a leader among
+// themselves instead of running as a primary/secondary pair.
+func (h *HAConfig) Cluster() bool {
+	return h.Mode == HAModeCluster
+}
+
+// HAMember is one node of an HA cluster.
+type HAMember struct {
+	ID      string `toml:"id" json:"id"`
+	Address string `toml:"address" json:"address"`             // host:port of the member's HA listener
+	TLSName string `toml:"tls_name" json:"tls_name,omitempty"` // SAN or CN its certificate must carry (default: host of address)
+}
+
+// MinClusterMembers is the smallest cluster that survives losing a node.
+const MinClusterMembers = 3
+
+// MinHAAuthKeyLength is the shortest pre-shared key accepted for ha.auth_key.
+const MinHAAuthKeyLength = 16
+
+// HASplitBrainConfig configures the tie-breaker consulted before a node
+// takes over from a silent peer. With neither a witness nor gateways set, a
+// heartbeat timeout alone triggers failover.
+type HASplitBrainConfig struct {
+	Witness      string   `toml:"witness" json:"witness"`             // witness URL, e.g. "http://10.0.0.9:8069"
+	Group        string   `toml:"group" json:"group"`                 // name this pair registers under at the witness (default: "default")
+	Gateways     []string `toml:"gateways" json:"gateways,omitempty"` // IPs of which at least one must answer ICMP echo
+	CheckTimeout string   `toml:"check_timeout" json:"check_timeout"` // per-check timeout (default: "2s")
+}
+
+// Enabled reports whether any split-brain check is configured.
+func (s *HASplitBrainConfig) Enabled() bool {
+	return s.Witness != "" || len(s.Gateways) > 0
 }
 
 // HATLSConfig holds TLS settings for HA peer communication.
+// Both sides present a certificate signed by the CA, and each checks that
+// the other's carries the expected name.
 type HATLSConfig struct {
 	Enabled  bool   `toml:"enabled" json:"enabled"`
 	CertFile string `toml:"cert_file" json:"cert_file"`
 	KeyFile  string `toml:"key_file" json:"key_file"`
 	CAFile   string `toml:"ca_file" json:"ca_file"`
+	PeerName string `toml:"peer_name" json:"peer_name,omitempty"` // SAN or CN the peer's certificate must carry (default: host of peer_address)
 }
 
 // HooksConfig holds event hook settings.
@@ -163,6 +275,7 @@ type DDNSConfig struct {
 	UseDHCID        bool               `toml:"use_dhcid" json:"use_dhcid"`
 	Forward         DDNSZoneConfig     `toml:"forward" json:"forward"`
 	Reverse         DDNSZoneConfig     `toml:"reverse" json:"reverse"`
+	Reverse6        DDNSZoneConfig     `toml:"reverse6" json:"reverse6"` // ip6.arpa zone for DHCPv6 leases
 	ZoneOverrides   []DDNSZoneOverride `toml:"zone_override" json:"zone_override,omitempty"`
 }
 
@@ -195,21 +308,43 @@ type DNSProxyConfig struct {
 	Enabled          bool              `toml:"enabled" json:"enabled"`
 	ListenUDP        string            `toml:"listen_udp" json:"listen_udp"`
 	ListenDoH        string            `toml:"listen_doh" json:"listen_doh,omitempty"`
+	ListenDoT        string            `toml:"listen_dot" json:"listen_dot,omitempty"` // DNS-over-TLS, RFC 7858
+	ListenDoQ        string            `toml:"listen_doq" json:"listen_doq,omitempty"` // DNS-over-QUIC, RFC 9250
 	DoHTLS           DoHTLSConfig      `toml:"doh_tls" json:"doh_tls,omitempty"`
 	Domain           string            `toml:"domain" json:"domain"`
 	TTL              int               `toml:"ttl" json:"ttl"`
 	RegisterLeases   bool              `toml:"register_leases" json:"register_leases"`
 	ForwardLeasesPTR bool              `toml:"register_leases_ptr" json:"register_leases_ptr"`
-	Forwarders       []string          `toml:"forwarders" json:"forwarders"`
+	Forwarders       []string          `toml:"forwarders" json:"forwarders"` // host[:port] or tls://, https://, quic:// URIs
 	UseRootServers   bool              `toml:"use_root_servers" json:"use_root_servers"`
 	CacheSize        int               `toml:"cache_size" json:"cache_size"`
 	CacheTTL         string            `toml:"cache_ttl" json:"cache_ttl"`
 	ZoneOverrides    []DNSZoneOverride `toml:"zone_override" json:"zone_override,omitempty"`
 	StaticRecords    []DNSStaticRecord `toml:"record" json:"record,omitempty"`
 	Lists            []DNSListConfig   `toml:"list" json:"list,omitempty"`
+	Policies         []DNSPolicyConfig `toml:"policy" json:"policy,omitempty"`
+	QueryLog         DNSQueryLogConfig `toml:"query_log" json:"query_log"`
+	DNSSEC           DNSSECConfig      `toml:"dnssec" json:"dnssec"`
 }
 
-// DoHTLSConfig holds TLS settings for DNS-over-HTTPS.
+// DNSSECConfig controls validation of upstream answers. Secure answers get
+// the AD bit and bogus ones are answered with SERVFAIL.
+type DNSSECConfig struct {
+	Validate     bool     `toml:"validate" json:"validate"`
+	TrustAnchors []string `toml:"trust_anchors" json:"trust_anchors,omitempty"` // DS or DNSKEY records (default: the root KSKs)
+}
+
+// DNSQueryLogConfig controls the on-disk DNS query log. Without persist,
+// only the last few thousand queries are kept, in memory.
+type DNSQueryLogConfig struct {
+	Persist     bool   `toml:"persist" json:"persist"`
+	Path        string `toml:"path" json:"path,omitempty"`                   // BoltDB file (default: dns-querylog.db next to lease_db)
+	MaxAge      string `toml:"max_age" json:"max_age,omitempty"`             // drop entries older than this (default: "168h")
+	MaxEntries  int    `toml:"max_entries" json:"max_entries,omitempty"`     // and beyond this many, oldest first (default: 500000)
+	StatsMaxAge string `toml:"stats_max_age" json:"stats_max_age,omitempty"` // hourly top tables kept this long (default: "2160h")
+}
+
+// DoHTLSConfig holds the certificate for the DoH, DoT and DoQ listeners.
 type DoHTLSConfig struct {
 	CertFile string `toml:"cert_file" json:"cert_file,omitempty"`
 	KeyFile  string `toml:"key_file" json:"key_file,omitempty"`
@@ -224,12 +359,39 @@ type DNSListConfig struct {
 	Action          string `toml:"action" json:"action"` // "nxdomain", "zero", "refuse"
 	Enabled         bool   `toml:"enabled" json:"enabled"`
 	RefreshInterval string `toml:"refresh_interval" json:"refresh_interval"` // e.g. "24h", "6h"
+	// Schedule limits an enabled list to these windows; empty means always.
+	Schedule []DNSScheduleConfig `toml:"schedule" json:"schedule,omitempty"`
+}
+
+// DNSScheduleConfig is a weekly time window in the server's local time.
+// An end at or before the start runs past midnight into the next day.
+type DNSScheduleConfig struct {
+	Days  []string `toml:"days" json:"days,omitempty"` // "mon".."sun"; empty means every day
+	Start string   `toml:"start" json:"start"`         // "09:00"
+	End   string   `toml:"end" json:"end"`             // "17:00"
+}
+
+// DNSPolicyConfig is a filtering group. A client it selects — by subnet,
+// MAC, reservation or fingerprinted device type — is filtered by the
+// policy's lists and rules instead of the globally enabled lists.
+// Policies are tried in order and the first that selects a client applies.
+type DNSPolicyConfig struct {
+	Name        string   `toml:"name" json:"name"`
+	Subnets     []string `toml:"subnets" json:"subnets,omitempty"`
+	MACs        []string `toml:"macs" json:"macs,omitempty"`
+	Reserved    bool     `toml:"reserved" json:"reserved,omitempty"`         // clients with a DHCP reservation
+	DeviceTypes []string `toml:"device_types" json:"device_types,omitempty"` // fingerprint device_type
+	Lists       []string `toml:"lists" json:"lists,omitempty"`               // names of [[dns.list]] entries
+	Block       []string `toml:"block" json:"block,omitempty"`               // custom blocked domains
+	Allow       []string `toml:"allow" json:"allow,omitempty"`               // custom allowed domains
+	BlockAction string   `toml:"block_action" json:"block_action,omitempty"` // for custom blocks: "nxdomain", "zero", "refuse"
+	SafeSearch  bool     `toml:"safe_search" json:"safe_search"`
 }
 
 // DNSZoneOverride routes queries for a specific domain to a specific nameserver.
 type DNSZoneOverride struct {
 	Zone       string `toml:"zone" json:"zone"`
-	Nameserver string `toml:"nameserver" json:"nameserver"`
+	Nameserver string `toml:"nameserver" json:"nameserver"` // same forms as forwarders
 	DoH        bool   `toml:"doh" json:"doh"`
 	DoHURL     string `toml:"doh_url" json:"doh_url,omitempty"`
 }
@@ -265,32 +427,126 @@ type SubnetConfig struct {
 	LeaseTime            string                      `toml:"lease_time" json:"lease_time,omitempty"`
 	RenewalTime          string                      `toml:"renewal_time" json:"renewal_time,omitempty"`
 	RebindTime           string                      `toml:"rebind_time" json:"rebind_time,omitempty"`
+	AffinityPeriod       string                      `toml:"affinity_period" json:"affinity_period,omitempty"` // how long an expired or released address stays with its client
+	OverflowSubnet       string                      `toml:"overflow_subnet" json:"overflow_subnet,omitempty"` // subnet on the same link to hand out from once this one is full
 	NTPServers           []string                    `toml:"ntp_servers" json:"ntp_servers,omitempty"`
+	OnlyRequestedOptions *bool                       `toml:"only_requested_options" json:"only_requested_options,omitempty"`
+	NextServer           string                      `toml:"next_server" json:"next_server,omitempty"`
+	BootFile             string                      `toml:"boot_file" json:"boot_file,omitempty"`
 	Pools                []PoolConfig                `toml:"pool" json:"pool,omitempty"`
 	Reservations         []ReservationConfig         `toml:"reservation" json:"reservation,omitempty"`
 	Options              []OptionConfig              `toml:"option" json:"option,omitempty"`
 	HostnameSanitisation *HostnameSanitisationConfig `toml:"hostname_sanitisation" json:"hostname_sanitisation,omitempty"`
+	Pressure             *PressureConfig             `toml:"pressure" json:"pressure,omitempty"`
+}
+
+// PressureConfig holds how a subnet behaves as its pools fill up.
+type PressureConfig struct {
+	HighUtilisation   float64             `toml:"high_utilisation" json:"high_utilisation,omitempty"`       // percent; pool.utilisation_high fires above it (default 90)
+	OfferReclaimAfter string              `toml:"offer_reclaim_after" json:"offer_reclaim_after,omitempty"` // offers older than this are reclaimed when a pool runs out (default 10s, "0s" = never)
+	LeaseTimes        []PressureLeaseTime `toml:"lease_time" json:"lease_time,omitempty"`
+}
+
+// PressureLeaseTime shortens the leases handed out from a pool once its
+// utilisation is above a threshold.
+type PressureLeaseTime struct {
+	Above     float64 `toml:"above" json:"above"` // percent
+	LeaseTime string  `toml:"lease_time" json:"lease_time"`
 }
 
 // PoolConfig holds IP pool configuration.
 type PoolConfig struct {
-	RangeStart       string `toml:"range_start" json:"range_start"`
-	RangeEnd         string `toml:"range_end" json:"range_end"`
-	LeaseTime        string `toml:"lease_time" json:"lease_time,omitempty"`
-	MatchCircuitID   string `toml:"match_circuit_id" json:"match_circuit_id,omitempty"`
-	MatchRemoteID    string `toml:"match_remote_id" json:"match_remote_id,omitempty"`
-	MatchVendorClass string `toml:"match_vendor_class" json:"match_vendor_class,omitempty"`
-	MatchUserClass   string `toml:"match_user_class" json:"match_user_class,omitempty"`
+	RangeStart       string         `toml:"range_start" json:"range_start"`
+	RangeEnd         string         `toml:"range_end" json:"range_end"`
+	Strategy         string         `toml:"allocation_strategy" json:"allocation_strategy,omitempty"` // lru (default), sequential or random
+	Overflow         bool           `toml:"overflow" json:"overflow,omitempty"`                       // only used once the pool a client matches is full
+	LeaseTime        string         `toml:"lease_time" json:"lease_time,omitempty"`
+	MatchCircuitID   string         `toml:"match_circuit_id" json:"match_circuit_id,omitempty"`
+	MatchRemoteID    string         `toml:"match_remote_id" json:"match_remote_id,omitempty"`
+	MatchVendorClass string         `toml:"match_vendor_class" json:"match_vendor_class,omitempty"`
+	MatchUserClass   string         `toml:"match_user_class" json:"match_user_class,omitempty"`
+	MatchRadiusClass string         `toml:"match_radius_class" json:"match_radius_class,omitempty"` // RADIUS Class attribute from Access-Accept
+	ClientClasses    []string       `toml:"client_classes" json:"client_classes,omitempty"`         // only serve members of these classes
+	Routers          []string       `toml:"routers" json:"routers,omitempty"`
+	DNSServers       []string       `toml:"dns_servers" json:"dns_servers,omitempty"`
+	NTPServers       []string       `toml:"ntp_servers" json:"ntp_servers,omitempty"`
+	DomainName       string         `toml:"domain_name" json:"domain_name,omitempty"`
+	NextServer       string         `toml:"next_server" json:"next_server,omitempty"`
+	BootFile         string         `toml:"boot_file" json:"boot_file,omitempty"`
+	Options          []OptionConfig `toml:"option" json:"option,omitempty"`
 }
 
 // ReservationConfig holds static lease (reservation) configuration.
+// Any option set here overrides the subnet, pool and client class values.
 type ReservationConfig struct {
-	MAC          string   `toml:"mac" json:"mac"`
-	Identifier   string   `toml:"identifier" json:"identifier,omitempty"`
-	IP           string   `toml:"ip" json:"ip"`
-	Hostname     string   `toml:"hostname" json:"hostname,omitempty"`
-	DNSServers   []string `toml:"dns_servers" json:"dns_servers,omitempty"`
-	DDNSHostname string   `toml:"ddns_hostname" json:"ddns_hostname,omitempty"`
+	MAC          string         `toml:"mac" json:"mac"`
+	Identifier   string         `toml:"identifier" json:"identifier,omitempty"`
+	IP           string         `toml:"ip" json:"ip"`
+	Hostname     string         `toml:"hostname" json:"hostname,omitempty"`
+	DNSServers   []string       `toml:"dns_servers" json:"dns_servers,omitempty"`
+	DDNSHostname string         `toml:"ddns_hostname" json:"ddns_hostname,omitempty"`
+	Routers      []string       `toml:"routers" json:"routers,omitempty"`
+	NTPServers   []string       `toml:"ntp_servers" json:"ntp_servers,omitempty"`
+	DomainName   string         `toml:"domain_name" json:"domain_name,omitempty"`
+	LeaseTime    string         `toml:"lease_time" json:"lease_time,omitempty"`
+	NextServer   string         `toml:"next_server" json:"next_server,omitempty"`
+	BootFile     string         `toml:"boot_file" json:"boot_file,omitempty"`
+	Options      []OptionConfig `toml:"option" json:"option,omitempty"`
+}
+
+// Subnet6Config holds per-link DHCPv6 configuration (RFC 8415). Addresses
+// are assigned from pools (IA_NA) and prefixes delegated from pd_pools (IA_PD).
+type Subnet6Config struct {
+	Network           string               `toml:"network" json:"network"`
+	Interface         string               `toml:"interface" json:"interface,omitempty"`
+	DNSServers        []string             `toml:"dns_servers" json:"dns_servers,omitempty"`
+	DomainSearch      []string             `toml:"domain_search" json:"domain_search,omitempty"`
+	PreferredLifetime string               `toml:"preferred_lifetime" json:"preferred_lifetime,omitempty"`
+	ValidLifetime     string               `toml:"valid_lifetime" json:"valid_lifetime,omitempty"`
+	RenewalTime       string               `toml:"renewal_time" json:"renewal_time,omitempty"`
+	RebindTime        string               `toml:"rebind_time" json:"rebind_time,omitempty"`
+	RapidCommit       bool                 `toml:"rapid_commit" json:"rapid_commit"`
+	Pools             []Pool6Config        `toml:"pool" json:"pool,omitempty"`
+	PDPools           []PDPoolConfig       `toml:"pd_pool" json:"pd_pool,omitempty"`
+	Reservations      []Reservation6Config `toml:"reservation" json:"reservation,omitempty"`
+}
+
+// Pool6Config holds an IA_NA address range.
+type Pool6Config struct {
+	RangeStart string `toml:"range_start" json:"range_start"`
+	RangeEnd   string `toml:"range_end" json:"range_end"`
+}
+
+// PDPoolConfig holds a prefix delegation pool: Prefix is carved into
+// delegated_length-sized prefixes handed to requesting routers.
+type PDPoolConfig struct {
+	Prefix          string `toml:"prefix" json:"prefix"`
+	DelegatedLength int    `toml:"delegated_length" json:"delegated_length"`
+}
+
+// Reservation6Config pins an address and/or delegated prefix to a client DUID.
+type Reservation6Config struct {
+	DUID     string `toml:"duid" json:"duid"`
+	IP       string `toml:"ip" json:"ip,omitempty"`
+	Prefix   string `toml:"prefix" json:"prefix,omitempty"`
+	Hostname string `toml:"hostname" json:"hostname,omitempty"`
+}
+
+// ClientClassConfig defines a named client class. A client belongs to the
+// class when Match evaluates true (see package clientclass for the syntax).
+// Classes can gate pools, attach options, set the lease time or deny service.
+type ClientClassConfig struct {
+	Name       string         `toml:"name" json:"name"`
+	Match      string         `toml:"match" json:"match"`
+	Deny       bool           `toml:"deny" json:"deny,omitempty"`
+	LeaseTime  string         `toml:"lease_time" json:"lease_time,omitempty"`
+	Routers    []string       `toml:"routers" json:"routers,omitempty"`
+	DNSServers []string       `toml:"dns_servers" json:"dns_servers,omitempty"`
+	NTPServers []string       `toml:"ntp_servers" json:"ntp_servers,omitempty"`
+	DomainName string         `toml:"domain_name" json:"domain_name,omitempty"`
+	NextServer string         `toml:"next_server" json:"next_server,omitempty"`
+	BootFile   string         `toml:"boot_file" json:"boot_file,omitempty"`
+	Options    []OptionConfig `toml:"option" json:"option,omitempty"`
 }
 
 // OptionConfig holds custom DHCP option configuration.
@@ -302,11 +558,12 @@ type OptionConfig struct {
 
 // DefaultsConfig holds global default option values.
 type DefaultsConfig struct {
-	LeaseTime   string   `toml:"lease_time" json:"lease_time"`
-	RenewalTime string   `toml:"renewal_time" json:"renewal_time"`
-	RebindTime  string   `toml:"rebind_time" json:"rebind_time"`
-	DNSServers  []string `toml:"dns_servers" json:"dns_servers"`
-	DomainName  string   `toml:"domain_name" json:"domain_name"`
+	LeaseTime            string   `toml:"lease_time" json:"lease_time"`
+	RenewalTime          string   `toml:"renewal_time" json:"renewal_time"`
+	RebindTime           string   `toml:"rebind_time" json:"rebind_time"`
+	DNSServers           []string `toml:"dns_servers" json:"dns_servers"`
+	DomainName           string   `toml:"domain_name" json:"domain_name"`
+	OnlyRequestedOptions bool     `toml:"only_requested_options" json:"only_requested_options"`
 }
 
 // APIConfig holds HTTP API and web UI settings.
@@ -400,9 +657,11 @@ func applyBootstrapDefaults(cfg *Config) {
 	if cfg.Server.LeaseDB == "" {
 		cfg.Server.LeaseDB = DefaultLeaseDB
 	}
+	applyLeaseBackendDefaults(&cfg.Server)
 	if cfg.Server.PIDFile == "" {
 		cfg.Server.PIDFile = DefaultPIDFile
 	}
+	applyDatabaseDefaults(&cfg.Server.Database)
 	if cfg.API.Listen == "" {
 		cfg.API.Listen = DefaultAPIListen
 	}
@@ -424,9 +683,205 @@ func applyBootstrapDefaults(cfg *Config) {
 		if cfg.HA.SyncBatchSize == 0 {
 			cfg.HA.SyncBatchSize = 100
 		}
+		applyHAModeDefaults(&cfg.HA)
+	}
+}
+
+// applyHAModeDefaults fills in the failover mode, its timers, the lease
+// update queue and the split-brain check settings.
+func applyHAModeDefaults(ha *HAConfig) {
+	if ha.Mode == "" {
+		ha.Mode = HAModeActiveStandby
+	}
+	if ha.MCLT == "" {
+		ha.MCLT = DefaultHAMCLT.String()
+	}
+	if ha.SafetyPeriod == "" {
+		ha.SafetyPeriod = ha.MCLT
+	}
+	if ha.SyncQueueSize == 0 {
+		ha.SyncQueueSize = DefaultHASyncQueueSize
+	}
+	if ha.SyncAckTimeout == "" {
+		ha.SyncAckTimeout = DefaultHASyncAckTimeout.String()
+	}
+	if ha.AuthWindow == "" {
+		ha.AuthWindow = DefaultHAAuthWindow.String()
+	}
+	if ha.SplitBrain.Group == "" {
+		ha.SplitBrain.Group = DefaultHAWitnessGroup
+	}
+	if ha.SplitBrain.CheckTimeout == "" {
+		ha.SplitBrain.CheckTimeout = DefaultHASplitBrainTimeout.String()
+	}
+}
+
+// validateHAMode checks the failover mode and the MCLT / safety period.
+func validateHAMode(ha HAConfig) error {
+	switch ha.Mode {
+	case "", HAModeActiveStandby, HAModeLoadBalancing:
+	case HAModeCluster:
+		if err := ValidateHACluster(ha); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("ha.mode must be %q, %q or %q, got %q", HAModeActiveStandby, HAModeLoadBalancing, HAModeCluster, ha.Mode)
+	}
+	if ha.MCLT != "" {
+		if d, err := time.ParseDuration(ha.MCLT); err != nil || d <= 0 {
+			return fmt.Errorf("ha.mclt %q is not a positive duration", ha.MCLT)
+		}
+	}
+	if ha.SafetyPeriod != "" {
+		if d, err := time.ParseDuration(ha.SafetyPeriod); err != nil || d < 0 {
+			return fmt.Errorf("ha.safety_period %q is not a valid duration", ha.SafetyPeriod)
+		}
+	}
+	if ha.SyncQueueSize < 0 {
+		return fmt.Errorf("ha.sync_queue_size must not be negative, got %d", ha.SyncQueueSize)
+	}
+	if ha.SyncAckTimeout != "" {
+		if d, err := time.ParseDuration(ha.SyncAckTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("ha.sync_ack_timeout %q is not a positive duration", ha.SyncAckTimeout)
+		}
+	}
+	if err := validateHASecurity(ha); err != nil {
+		return err
+	}
+	return validateHASplitBrain(ha.SplitBrain)
+}
+
+// validateHASecurity checks the pre-shared key and TLS settings.
+func validateHASecurity(ha HAConfig) error {
+	if ha.AuthKey != "" && ha.AuthKeyFile != "" {
+		return fmt.Errorf("ha.auth_key and ha.auth_key_file are mutually exclusive")
+	}
+	if ha.AuthKey != "" && len(ha.AuthKey) < MinHAAuthKeyLength {
+		return fmt.Errorf("ha.auth_key must be at least %d characters", MinHAAuthKeyLength)
+	}
+	if ha.AuthWindow != "" {
+		if d, err := time.ParseDuration(ha.AuthWindow); err != nil || d <= 0 {
+			return fmt.Errorf("ha.auth_window %q is not a positive duration", ha.AuthWindow)
+		}
+	}
+	if ha.TLS.Enabled && (ha.TLS.CertFile == "" || ha.TLS.KeyFile == "" || ha.TLS.CAFile == "") {
+		return fmt.Errorf("ha.tls requires cert_file, key_file and ca_file")
+	}
+	return nil
+}
+
+// applyLeaseBackendDefaults selects BoltDB unless another lease backend
+// is configured.
+func applyLeaseBackendDefaults(srv *ServerConfig) {
+	if srv.LeaseBackend == "" {
+		srv.LeaseBackend = LeaseBackendBoltDB
+	}
+	if srv.LeaseBackend == LeaseBackendSQLite && srv.LeaseDSN == "" {
+		srv.LeaseDSN = DefaultLeaseSQLite
 	}
 }
 
+// validateLeaseBackend checks server.lease_backend and server.lease_dsn.
+func validateLeaseBackend(srv ServerConfig) error {
+	switch srv.LeaseBackend {
+	case "", LeaseBackendBoltDB:
+		return nil
+	case LeaseBackendSQLite:
+		if srv.LeaseDSN == srv.LeaseDB {
+			return fmt.Errorf("server.lease_dsn must not be the BoltDB file server.lease_db")
+		}
+		return nil
+	}
+	return fmt.Errorf("server.lease_backend %q must be %q or %q", srv.LeaseBackend, LeaseBackendBoltDB, LeaseBackendSQLite)
+}
+
+// applyDatabaseDefaults fills in the compaction and snapshot defaults.
+func applyDatabaseDefaults(db *DatabaseConfig) {
+	if db.CompactThreshold == 0 {
+		db.CompactThreshold = DefaultCompactThreshold
+	}
+	if db.SnapshotInterval == "" {
+		db.SnapshotInterval = DefaultSnapshotInterval.String()
+	}
+	if db.SnapshotRetain == 0 {
+		db.SnapshotRetain = DefaultSnapshotRetain
+	}
+}
+
+// validateDatabase checks the compaction and snapshot settings.
+func validateDatabase(db DatabaseConfig) error {
+	for name, v := range map[string]string{
+		"compact_interval":  db.CompactInterval,
+		"snapshot_interval": db.SnapshotInterval,
+	} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < time.Minute {
+			return fmt.Errorf("server.database.%s %q must be a duration of at least 1m", name, v)
+		}
+	}
+	if db.CompactThreshold < 0 || db.CompactThreshold > 100 {
+		return fmt.Errorf("server.database.compact_threshold must be between 0 and 100, got %d", db.CompactThreshold)
+	}
+	if db.SnapshotRetain < 0 {
+		return fmt.Errorf("server.database.snapshot_retain must not be negative")
+	}
+	return nil
+}
+
+// ValidateHACluster checks the member list of a cluster: at least
+// MinClusterMembers members with unique IDs and host:port addresses, this
+// node among them.
+func ValidateHACluster(ha HAConfig) error {
+	if ha.NodeID == "" {
+		return fmt.Errorf("ha.node_id is required in cluster mode")
+	}
+	if len(ha.Members) < MinClusterMembers {
+		return fmt.Errorf("ha cluster needs at least %d [[ha.member]] entries, got %d", MinClusterMembers, len(ha.Members))
+	}
+	seen := make(map[string]bool, len(ha.Members))
+	self := false
+	for i, m := range ha.Members {
+		if m.ID == "" {
+			return fmt.Errorf("ha.member[%d]: id is required", i)
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("ha.member[%d]: duplicate id %q", i, m.ID)
+		}
+		seen[m.ID] = true
+		if _, _, err := net.SplitHostPort(m.Address); err != nil {
+			return fmt.Errorf("ha.member[%d] (%s): address %q is not host:port", i, m.ID, m.Address)
+		}
+		self = self || m.ID == ha.NodeID
+	}
+	if !self {
+		return fmt.Errorf("ha.node_id %q is not one of the [[ha.member]] entries", ha.NodeID)
+	}
+	return nil
+}
+
+// validateHASplitBrain checks the witness URL, gateway IPs and check timeout.
+func validateHASplitBrain(sb HASplitBrainConfig) error {
+	if sb.Witness != "" {
+		u, err := url.Parse(sb.Witness)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("ha.split_brain.witness %q must be an http:// or https:// URL", sb.Witness)
+		}
+	}
+	for _, gw := range sb.Gateways {
+		if ip := net.ParseIP(gw); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("ha.split_brain.gateways: %q is not a valid IPv4 address", gw)
+		}
+	}
+	if sb.CheckTimeout != "" {
+		if d, err := time.ParseDuration(sb.CheckTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("ha.split_brain.check_timeout %q is not a positive duration", sb.CheckTimeout)
+		}
+	}
+	return nil
+}
+
 // validateBootstrap checks only the bootstrap config sections.
 func validateBootstrap(cfg *Config) error {
 	if cfg.Server.ServerID != "" {
@@ -434,18 +889,44 @@ func validateBootstrap(cfg *Config) error {
 			return fmt.Errorf("server.server_id %q is not a valid IP address", cfg.Server.ServerID)
 		}
 	}
+	if err := validateLeaseBackend(cfg.Server); err != nil {
+		return err
+	}
+	if err := validateDatabase(cfg.Server.Database); err != nil {
+		return err
+	}
+
+	// Validate DHCPv6 subnets
+	for i, sub := range cfg.Subnets6 {
+		if err := ValidateSubnet6(sub); err != nil {
+			return fmt.Errorf("subnet6[%d]: %w", i, err)
+		}
+	}
+	for i := 0; i < len(cfg.Subnets6); i++ {
+		for j := i + 1; j < len(cfg.Subnets6); j++ {
+			if subnetsOverlap(cfg.Subnets6[i].Network, cfg.Subnets6[j].Network) {
+				return fmt.Errorf("subnet6[%d] (%s) overlaps with subnet6[%d] (%s)",
+					i, cfg.Subnets6[i].Network, j, cfg.Subnets6[j].Network)
+			}
+		}
+	}
 
 	// Validate HA config
 	if cfg.HA.Enabled {
-		if cfg.HA.Role != "primary" && cfg.HA.Role != "secondary" {
-			return fmt.Errorf("ha.role must be \"primary\" or \"secondary\", got %q", cfg.HA.Role)
-		}
-		if cfg.HA.PeerAddress == "" {
-			return fmt.Errorf("ha.peer_address is required when HA is enabled")
+		if !cfg.HA.Cluster() {
+			if cfg.HA.Role != "primary" && cfg.HA.Role != "secondary" {
+				return fmt.Errorf("ha.role must be \"primary\" or \"secondary\", got %q", cfg.HA.Role)
+			}
+			if cfg.HA.PeerAddress == "" {
+				return fmt.Errorf("ha.peer_address is required when HA is enabled")
+			}
 		}
 		if cfg.HA.ListenAddress == "" {
 			return fmt.Errorf("ha.listen_address is required when HA is enabled")
 		}
+		if err := validateHAMode(cfg.HA); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -509,6 +990,7 @@ func ApplyDynamicDefaults(cfg *Config) {
 	if cfg.HA.SyncBatchSize == 0 {
 		cfg.HA.SyncBatchSize = DefaultHASyncBatchSize
 	}
+	applyHAModeDefaults(&cfg.HA)
 
 	// Global defaults
 	if cfg.Defaults.LeaseTime == "" {
@@ -534,6 +1016,26 @@ func ApplyDynamicDefaults(cfg *Config) {
 	if cfg.DNS.CacheTTL == "" {
 		cfg.DNS.CacheTTL = DefaultDNSCacheTTL.String()
 	}
+	if cfg.DNS.QueryLog.MaxAge == "" {
+		cfg.DNS.QueryLog.MaxAge = DefaultDNSQueryLogMaxAge.String()
+	}
+	if cfg.DNS.QueryLog.MaxEntries == 0 {
+		cfg.DNS.QueryLog.MaxEntries = DefaultDNSQueryLogEntries
+	}
+	if cfg.DNS.QueryLog.StatsMaxAge == "" {
+		cfg.DNS.QueryLog.StatsMaxAge = DefaultDNSQueryStatsMaxAge.String()
+	}
+
+	// Leasequery defaults
+	if cfg.LeaseQuery.BulkListen == "" {
+		cfg.LeaseQuery.BulkListen = DefaultLeaseQueryBulkListen
+	}
+	if cfg.LeaseQuery.MaxConnections == 0 {
+		cfg.LeaseQuery.MaxConnections = DefaultLeaseQueryMaxConns
+	}
+	if cfg.LeaseQuery.IdleTimeout == "" {
+		cfg.LeaseQuery.IdleTimeout = DefaultLeaseQueryIdleTimeout.String()
+	}
 
 	// DDNS defaults
 	if cfg.DDNS.TTL == 0 {
@@ -568,9 +1070,11 @@ func applyDefaults(cfg *Config) {
 	if cfg.Server.LeaseDB == "" {
 		cfg.Server.LeaseDB = DefaultLeaseDB
 	}
+	applyLeaseBackendDefaults(&cfg.Server)
 	if cfg.Server.PIDFile == "" {
 		cfg.Server.PIDFile = DefaultPIDFile
 	}
+	applyDatabaseDefaults(&cfg.Server.Database)
 	if cfg.Server.RateLimit.MaxDiscoversPerSecond == 0 {
 		cfg.Server.RateLimit.MaxDiscoversPerSecond = DefaultRateLimitDiscovers
 	}
@@ -625,6 +1129,7 @@ func applyDefaults(cfg *Config) {
 	if cfg.HA.SyncBatchSize == 0 {
 		cfg.HA.SyncBatchSize = DefaultHASyncBatchSize
 	}
+	applyHAModeDefaults(&cfg.HA)
 
 	// Global defaults
 	if cfg.Defaults.LeaseTime == "" {
@@ -660,6 +1165,26 @@ func applyDefaults(cfg *Config) {
 	if cfg.DNS.CacheTTL == "" {
 		cfg.DNS.CacheTTL = DefaultDNSCacheTTL.String()
 	}
+	if cfg.DNS.QueryLog.MaxAge == "" {
+		cfg.DNS.QueryLog.MaxAge = DefaultDNSQueryLogMaxAge.String()
+	}
+	if cfg.DNS.QueryLog.MaxEntries == 0 {
+		cfg.DNS.QueryLog.MaxEntries = DefaultDNSQueryLogEntries
+	}
+	if cfg.DNS.QueryLog.StatsMaxAge == "" {
+		cfg.DNS.QueryLog.StatsMaxAge = DefaultDNSQueryStatsMaxAge.String()
+	}
+
+	// Leasequery defaults
+	if cfg.LeaseQuery.BulkListen == "" {
+		cfg.LeaseQuery.BulkListen = DefaultLeaseQueryBulkListen
+	}
+	if cfg.LeaseQuery.MaxConnections == 0 {
+		cfg.LeaseQuery.MaxConnections = DefaultLeaseQueryMaxConns
+	}
+	if cfg.LeaseQuery.IdleTimeout == "" {
+		cfg.LeaseQuery.IdleTimeout = DefaultLeaseQueryIdleTimeout.String()
+	}
 
 	// DDNS defaults
 	if cfg.DDNS.TTL == 0 {
@@ -691,6 +1216,12 @@ func validate(cfg *Config) error {
 			return fmt.Errorf("server.server_id %q is not a valid IP address", cfg.Server.ServerID)
 		}
 	}
+	if err := validateLeaseBackend(cfg.Server); err != nil {
+		return err
+	}
+	if err := validateDatabase(cfg.Server.Database); err != nil {
+		return err
+	}
 
 	// Validate conflict detection
 	if cfg.ConflictDetection.Enabled {
@@ -708,6 +1239,19 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	// Validate client classes
+	if err := ValidateClientClasses(cfg.ClientClasses); err != nil {
+		return err
+	}
+
+	if err := ValidateLeaseQuery(cfg.LeaseQuery); err != nil {
+		return err
+	}
+	classNames := make(map[string]bool, len(cfg.ClientClasses))
+	for _, cc := range cfg.ClientClasses {
+		classNames[cc.Name] = true
+	}
+
 	// Validate subnets
 	for i, sub := range cfg.Subnets {
 		if sub.Network == "" {
@@ -734,6 +1278,14 @@ func validate(cfg *Config) error {
 			if !network.Contains(end) {
 				return fmt.Errorf("subnet[%d].pool[%d]: range_end %s is not in network %s", i, j, end, network)
 			}
+			if err := ValidatePoolOverrides(pool); err != nil {
+				return fmt.Errorf("subnet[%d].pool[%d]: %w", i, j, err)
+			}
+			for _, name := range pool.ClientClasses {
+				if !classNames[name] {
+					return fmt.Errorf("subnet[%d].pool[%d]: unknown client class %q", i, j, name)
+				}
+			}
 		}
 
 		// Validate pool range ordering (end >= start)
@@ -775,6 +1327,22 @@ func validate(cfg *Config) error {
 			if !network.Contains(ip) {
 				return fmt.Errorf("subnet[%d].reservation[%d]: ip %s is not in network %s", i, j, ip, network)
 			}
+			if err := ValidateReservationOverrides(res); err != nil {
+				return fmt.Errorf("subnet[%d].reservation[%d]: %w", i, j, err)
+			}
+		}
+
+		// Validate custom options
+		for j, opt := range sub.Options {
+			if err := ValidateOptionConfig(opt); err != nil {
+				return fmt.Errorf("subnet[%d].option[%d]: %w", i, j, err)
+			}
+		}
+		if sub.NextServer != "" && net.ParseIP(sub.NextServer).To4() == nil {
+			return fmt.Errorf("subnet[%d]: invalid next_server %q", i, sub.NextServer)
+		}
+		if len(sub.BootFile) > maxBootFileLen {
+			return fmt.Errorf("subnet[%d]: boot_file longer than %d bytes", i, maxBootFileLen)
 		}
 
 		// Validate duration fields
@@ -783,6 +1351,15 @@ func validate(cfg *Config) error {
 				return fmt.Errorf("subnet[%d].lease_time: %w", i, err)
 			}
 		}
+		if err := ValidateAffinityPeriod(sub.AffinityPeriod); err != nil {
+			return fmt.Errorf("subnet[%d].affinity_period: %w", i, err)
+		}
+		if err := ValidatePressure(sub); err != nil {
+			return fmt.Errorf("subnet[%d]: %w", i, err)
+		}
+		if sub.OverflowSubnet != "" && !slices.ContainsFunc(cfg.Subnets, func(s SubnetConfig) bool { return s.Network == sub.OverflowSubnet }) {
+			return fmt.Errorf("subnet[%d].overflow_subnet: no subnet %s", i, sub.OverflowSubnet)
+		}
 	}
 
 	// Validate no overlapping subnets
@@ -795,17 +1372,53 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	// Validate DHCPv6 subnets
+	for i, sub := range cfg.Subnets6 {
+		if err := ValidateSubnet6(sub); err != nil {
+			return fmt.Errorf("subnet6[%d]: %w", i, err)
+		}
+	}
+	for i := 0; i < len(cfg.Subnets6); i++ {
+		for j := i + 1; j < len(cfg.Subnets6); j++ {
+			if subnetsOverlap(cfg.Subnets6[i].Network, cfg.Subnets6[j].Network) {
+				return fmt.Errorf("subnet6[%d] (%s) overlaps with subnet6[%d] (%s)",
+					i, cfg.Subnets6[i].Network, j, cfg.Subnets6[j].Network)
+			}
+		}
+	}
+
 	// Validate HA config
 	if cfg.HA.Enabled {
-		if cfg.HA.Role != "primary" && cfg.HA.Role != "secondary" {
-			return fmt.Errorf("ha.role must be \"primary\" or \"secondary\", got %q", cfg.HA.Role)
-		}
-		if cfg.HA.PeerAddress == "" {
-			return fmt.Errorf("ha.peer_address is required when HA is enabled")
+		if !cfg.HA.Cluster() {
+			if cfg.HA.Role != "primary" && cfg.HA.Role != "secondary" {
+				return fmt.Errorf("ha.role must be \"primary\" or \"secondary\", got %q", cfg.HA.Role)
+			}
+			if cfg.HA.PeerAddress == "" {
+				return fmt.Errorf("ha.peer_address is required when HA is enabled")
+			}
 		}
 		if cfg.HA.ListenAddress == "" {
 			return fmt.Errorf("ha.listen_address is required when HA is enabled")
 		}
+		if err := validateHAMode(cfg.HA); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidateDNSLists(cfg.DNS.Lists); err != nil {
+		return err
+	}
+	if err := ValidateDNSPolicies(cfg.DNS); err != nil {
+		return err
+	}
+	if err := ValidateDNSQueryLog(cfg.DNS.QueryLog); err != nil {
+		return err
+	}
+	if err := ValidateDNSSEC(cfg.DNS.DNSSEC); err != nil {
+		return err
+	}
+	if err := ValidateDNSTransports(cfg.DNS); err != nil {
+		return err
 	}
 
 	// Validate DDNS
@@ -822,6 +1435,237 @@ func validate(cfg *Config) error {
 	return nil
 }
 
+// reservedOptionCodes are options the server manages itself and which may
+// not be set through [[option]] blocks.
+var reservedOptionCodes = map[int]string{
+	int(dhcpv4.OptionPad):                  "pad",
+	int(dhcpv4.OptionIPLeaseTime):          "use lease_time instead",
+	int(dhcpv4.OptionOverload):             "set by the server",
+	int(dhcpv4.OptionDHCPMessageType):      "set by the server",
+	int(dhcpv4.OptionServerIdentifier):     "set by the server",
+	int(dhcpv4.OptionParameterRequestList): "client-only option",
+	int(dhcpv4.OptionMaxDHCPMessageSize):   "client-only option",
+	int(dhcpv4.OptionRenewalTime):          "use renewal_time instead",
+	int(dhcpv4.OptionRebindingTime):        "use rebind_time instead",
+	int(dhcpv4.OptionClientIdentifier):     "client-only option",
+	int(dhcpv4.OptionRelayAgentInfo):       "relay-only option",
+	int(dhcpv4.OptionEnd):                  "end",
+}
+
+// ValidateClientClasses checks class names are unique, match expressions
+// compile and option overrides are well-formed.
+func ValidateClientClasses(classes []ClientClassConfig) error {
+	seen := make(map[string]bool, len(classes))
+	for i, cc := range classes {
+		if cc.Name == "" {
+			return fmt.Errorf("client_class[%d]: name is required", i)
+		}
+		if seen[cc.Name] {
+			return fmt.Errorf("client_class[%d]: duplicate name %q", i, cc.Name)
+		}
+		seen[cc.Name] = true
+		if _, err := clientclass.Compile(cc.Match); err != nil {
+			return fmt.Errorf("client_class %q: match: %w", cc.Name, err)
+		}
+		if err := validateOverrides(cc.Routers, cc.DNSServers, cc.NTPServers, cc.LeaseTime, cc.NextServer, cc.BootFile, cc.Options); err != nil {
+			return fmt.Errorf("client_class %q: %w", cc.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateSubnet6 checks a DHCPv6 subnet: an IPv6 network, pools and
+// reservations inside it, well-formed PD pools and lifetimes.
+func ValidateSubnet6(sub Subnet6Config) error {
+	if sub.Network == "" {
+		return fmt.Errorf("network is required")
+	}
+	_, network, err := net.ParseCIDR(sub.Network)
+	if err != nil || network.IP.To4() != nil {
+		return fmt.Errorf("invalid IPv6 network %q", sub.Network)
+	}
+
+	for j, pool := range sub.Pools {
+		start := net.ParseIP(pool.RangeStart)
+		end := net.ParseIP(pool.RangeEnd)
+		if start == nil || start.To4() != nil {
+			return fmt.Errorf("pool[%d]: invalid range_start %q", j, pool.RangeStart)
+		}
+		if end == nil || end.To4() != nil {
+			return fmt.Errorf("pool[%d]: invalid range_end %q", j, pool.RangeEnd)
+		}
+		if !network.Contains(start) || !network.Contains(end) {
+			return fmt.Errorf("pool[%d]: range %s-%s is not in network %s", j, start, end, network)
+		}
+		if bytes.Compare(end.To16(), start.To16()) < 0 {
+			return fmt.Errorf("pool[%d]: range_end %s is before range_start %s", j, end, start)
+		}
+	}
+
+	for j, pd := range sub.PDPools {
+		_, prefix, err := net.ParseCIDR(pd.Prefix)
+		if err != nil || prefix.IP.To4() != nil {
+			return fmt.Errorf("pd_pool[%d]: invalid IPv6 prefix %q", j, pd.Prefix)
+		}
+		ones, _ := prefix.Mask.Size()
+		if pd.DelegatedLength < ones || pd.DelegatedLength > 128 {
+			return fmt.Errorf("pd_pool[%d]: delegated_length %d must be between %d and 128", j, pd.DelegatedLength, ones)
+		}
+	}
+
+	for j, res := range sub.Reservations {
+		if _, err := dhcpv6.ParseDUID(res.DUID); err != nil {
+			return fmt.Errorf("reservation[%d]: %w", j, err)
+		}
+		if res.IP == "" && res.Prefix == "" {
+			return fmt.Errorf("reservation[%d]: ip or prefix is required", j)
+		}
+		if res.IP != "" {
+			ip := net.ParseIP(res.IP)
+			if ip == nil || ip.To4() != nil {
+				return fmt.Errorf("reservation[%d]: invalid IPv6 ip %q", j, res.IP)
+			}
+			if !network.Contains(ip) {
+				return fmt.Errorf("reservation[%d]: ip %s is not in network %s", j, ip, network)
+			}
+		}
+		if res.Prefix != "" {
+			if _, p, err := net.ParseCIDR(res.Prefix); err != nil || p.IP.To4() != nil {
+				return fmt.Errorf("reservation[%d]: invalid IPv6 prefix %q", j, res.Prefix)
+			}
+		}
+	}
+
+	for _, s := range sub.DNSServers {
+		if ip := net.ParseIP(s); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 dns server %q", s)
+		}
+	}
+	for name, d := range map[string]string{
+		"preferred_lifetime": sub.PreferredLifetime,
+		"valid_lifetime":     sub.ValidLifetime,
+		"renewal_time":       sub.RenewalTime,
+		"rebind_time":        sub.RebindTime,
+	} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ValidatePoolOverrides checks the allocation strategy and option
+// overrides on a pool.
+func ValidatePoolOverrides(pool PoolConfig) error {
+	switch pool.Strategy {
+	case "", PoolStrategyLRU, PoolStrategySequential, PoolStrategyRandom:
+	default:
+		return fmt.Errorf("allocation_strategy must be %q, %q or %q, got %q",
+			PoolStrategyLRU, PoolStrategySequential, PoolStrategyRandom, pool.Strategy)
+	}
+	return validateOverrides(pool.Routers, pool.DNSServers, pool.NTPServers, pool.LeaseTime, pool.NextServer, pool.BootFile, pool.Options)
+}
+
+// ValidateReservationOverrides checks the option overrides on a reservation.
+func ValidateReservationOverrides(res ReservationConfig) error {
+	return validateOverrides(res.Routers, res.DNSServers, res.NTPServers, res.LeaseTime, res.NextServer, res.BootFile, res.Options)
+}
+
+// ValidateLeaseQuery checks the leasequery relay ACL and bulk listener settings.
+func ValidateLeaseQuery(lq LeaseQueryConfig) error {
+	for _, r := range lq.AllowedRelays {
+		if net.ParseIP(r) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			return fmt.Errorf("leasequery.allowed_relays: %q is not an IP or CIDR", r)
+		}
+	}
+	if lq.BulkListen != "" {
+		if _, _, err := net.SplitHostPort(lq.BulkListen); err != nil {
+			return fmt.Errorf("leasequery.bulk_listen: %w", err)
+		}
+	}
+	if lq.MaxConnections < 0 {
+		return fmt.Errorf("leasequery.max_connections must not be negative")
+	}
+	if lq.IdleTimeout != "" {
+		if _, err := time.ParseDuration(lq.IdleTimeout); err != nil {
+			return fmt.Errorf("leasequery.idle_timeout: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateOverrides checks the option override fields shared by pools and
+// reservations.
+func validateOverrides(routers, dnsServers, ntpServers []string, leaseTime, nextServer, bootFile string, opts []OptionConfig) error {
+	for _, list := range []struct {
+		name string
+		ips  []string
+	}{{"routers", routers}, {"dns_servers", dnsServers}, {"ntp_servers", ntpServers}} {
+		for _, s := range list.ips {
+			if net.ParseIP(s).To4() == nil {
+				return fmt.Errorf("%s: invalid IPv4 address %q", list.name, s)
+			}
+		}
+	}
+	if leaseTime != "" {
+		if _, err := time.ParseDuration(leaseTime); err != nil {
+			return fmt.Errorf("lease_time: %w", err)
+		}
+	}
+	if nextServer != "" && net.ParseIP(nextServer).To4() == nil {
+		return fmt.Errorf("invalid next_server %q", nextServer)
+	}
+	if len(bootFile) > maxBootFileLen {
+		return fmt.Errorf("boot_file longer than %d bytes", maxBootFileLen)
+	}
+	for k, opt := range opts {
+		if err := ValidateOptionConfig(opt); err != nil {
+			return fmt.Errorf("option[%d]: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// maxBootFileLen is the BOOTP file header field (128 bytes) less its
+// terminating NUL.
+const maxBootFileLen = 127
+
+// maxOptionValueLen bounds a single custom option value so that it can still
+// fit in a reply once split into 255-byte segments.
+const maxOptionValueLen = 1024
+
+// ValidateOptionConfig checks that a custom option has a usable code, a
+// value that encodes cleanly for its declared type, and that the encoded
+// value fits the option registry's definition for that code.
+func ValidateOptionConfig(opt OptionConfig) error {
+	if opt.Code < 1 || opt.Code > 254 {
+		return fmt.Errorf("code %d out of range (1-254)", opt.Code)
+	}
+	if why, reserved := reservedOptionCodes[opt.Code]; reserved {
+		return fmt.Errorf("code %d cannot be configured (%s)", opt.Code, why)
+	}
+	if opt.Type == "" {
+		return fmt.Errorf("code %d: type is required", opt.Code)
+	}
+	data, err := dhcpv4.EncodeOptionValue(opt.Type, opt.Value)
+	if err != nil {
+		return fmt.Errorf("code %d: %w", opt.Code, err)
+	}
+	// Values over 255 bytes are split per RFC 3396, but must still fit a reply.
+	if len(data) > maxOptionValueLen {
+		return fmt.Errorf("code %d: encoded value is %d bytes (max %d)", opt.Code, len(data), maxOptionValueLen)
+	}
+	// Known options must also match the registry's type and length rules,
+	// e.g. a string value for option 3 or a uint32 for the MTU.
+	return dhcpv4.ValidateOption(dhcpv4.OptionCode(opt.Code), data)
+}
+
 // poolsOverlap returns true if two pool ranges overlap.
 func poolsOverlap(a, b PoolConfig) bool {
 	aStart := net.ParseIP(a.RangeStart).To4()
@@ -870,6 +1714,283 @@ func (cfg *Config) GetLeaseTime(subnetIdx int) time.Duration {
 	return d
 }
 
+// ValidatePressure checks a subnet's overflow_subnet and [subnet.pressure]
+// settings. Whether the overflow subnet exists is checked with the rest of
+// the config.
+func ValidatePressure(sub SubnetConfig) error {
+	if sub.OverflowSubnet != "" {
+		if _, _, err := net.ParseCIDR(sub.OverflowSubnet); err != nil {
+			return fmt.Errorf("overflow_subnet: %w", err)
+		}
+		if sub.OverflowSubnet == sub.Network {
+			return fmt.Errorf("overflow_subnet must be another subnet")
+		}
+	}
+	p := sub.Pressure
+	if p == nil {
+		return nil
+	}
+	if p.HighUtilisation < 0 || p.HighUtilisation > 100 {
+		return fmt.Errorf("pressure.high_utilisation must be between 0 and 100, got %g", p.HighUtilisation)
+	}
+	if p.OfferReclaimAfter != "" {
+		d, err := time.ParseDuration(p.OfferReclaimAfter)
+		if err != nil {
+			return fmt.Errorf("pressure.offer_reclaim_after: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("pressure.offer_reclaim_after must not be negative")
+		}
+	}
+	for i, lt := range p.LeaseTimes {
+		if lt.Above < 0 || lt.Above >= 100 {
+			return fmt.Errorf("pressure.lease_time[%d].above must be between 0 and 100, got %g", i, lt.Above)
+		}
+		d, err := time.ParseDuration(lt.LeaseTime)
+		if err != nil {
+			return fmt.Errorf("pressure.lease_time[%d].lease_time: %w", i, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("pressure.lease_time[%d].lease_time must be positive", i)
+		}
+	}
+	return nil
+}
+
+// ValidateDNSLists checks the schedules on DNS filter lists.
+func ValidateDNSLists(lists []DNSListConfig) error {
+	for _, l := range lists {
+		for i, sch := range l.Schedule {
+			if err := ValidateDNSSchedule(sch); err != nil {
+				return fmt.Errorf("dns.list %q: schedule[%d]: %w", l.Name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateDNSSchedule checks a schedule window: known day names and
+// distinct HH:MM start and end times.
+func ValidateDNSSchedule(sch DNSScheduleConfig) error {
+	for _, d := range sch.Days {
+		if _, ok := ScheduleDays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q, want mon, tue, wed, thu, fri, sat or sun", d)
+		}
+	}
+	if _, err := time.Parse("15:04", sch.Start); err != nil {
+		return fmt.Errorf("start must be HH:MM, got %q", sch.Start)
+	}
+	if _, err := time.Parse("15:04", sch.End); err != nil {
+		return fmt.Errorf("end must be HH:MM, got %q", sch.End)
+	}
+	if sch.Start == sch.End {
+		return fmt.Errorf("start and end are both %s", sch.Start)
+	}
+	return nil
+}
+
+// ScheduleDays maps the day names a schedule accepts to weekdays.
+var ScheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// DNS upstream transports.
+const (
+	DNSUpstreamUDP   = "udp"
+	DNSUpstreamTCP   = "tcp"
+	DNSUpstreamTLS   = "tls"   // RFC 7858
+	DNSUpstreamHTTPS = "https" // RFC 8484
+	DNSUpstreamQUIC  = "quic"  // RFC 9250
+)
+
+// ParseDNSUpstream splits a forwarder into its transport and address. A
+// plain host or host:port is UDP; otherwise the URI scheme picks the
+// transport. udp:// and tcp:// default to port 53, tls:// and quic:// to
+// 853. For https:// the address is the full URL, with /dns-query as the
+// default path.
+func ParseDNSUpstream(upstream string) (scheme, addr string, err error) {
+	upstream = strings.TrimSpace(upstream)
+	if upstream == "" {
+		return "", "", fmt.Errorf("empty upstream")
+	}
+	scheme, rest := DNSUpstreamUDP, upstream
+	if i := strings.Index(upstream, "://"); i >= 0 {
+		scheme, rest = strings.ToLower(upstream[:i]), upstream[i+3:]
+	}
+
+	port := "53"
+	switch scheme {
+	case DNSUpstreamHTTPS:
+		u, err := url.Parse(upstream)
+		if err != nil || u.Host == "" {
+			return "", "", fmt.Errorf("invalid DoH upstream %q", upstream)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return scheme, u.String(), nil
+	case DNSUpstreamUDP, DNSUpstreamTCP:
+	case DNSUpstreamTLS, DNSUpstreamQUIC:
+		port = "853"
+	default:
+		return "", "", fmt.Errorf("upstream %q: unsupported scheme %q (must be udp, tcp, tls, https, or quic)", upstream, scheme)
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+	host, p, err := net.SplitHostPort(rest)
+	if err != nil {
+		// No port, or a bare IPv6 address
+		host, p = strings.Trim(rest, "[]"), port
+	}
+	if host == "" || strings.ContainsAny(host, "/?#[]") {
+		return "", "", fmt.Errorf("upstream %q: invalid host", upstream)
+	}
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("upstream %q: invalid port %q", upstream, p)
+	}
+	return scheme, net.JoinHostPort(host, p), nil
+}
+
+// ValidateDNSTransports checks the forwarder and zone override upstreams,
+// and that the DoT and DoQ listeners have a certificate.
+func ValidateDNSTransports(d DNSProxyConfig) error {
+	for _, f := range d.Forwarders {
+		if _, _, err := ParseDNSUpstream(f); err != nil {
+			return fmt.Errorf("dns.forwarders: %w", err)
+		}
+	}
+	for _, zo := range d.ZoneOverrides {
+		if zo.DoH && zo.DoHURL != "" {
+			continue
+		}
+		if _, _, err := ParseDNSUpstream(zo.Nameserver); err != nil {
+			return fmt.Errorf("dns.zone_override %q: %w", zo.Zone, err)
+		}
+	}
+	hasCert := d.DoHTLS.CertFile != "" && d.DoHTLS.KeyFile != ""
+	if d.ListenDoT != "" && !hasCert {
+		return fmt.Errorf("dns.listen_dot needs dns.doh_tls.cert_file and key_file")
+	}
+	if d.ListenDoQ != "" && !hasCert {
+		return fmt.Errorf("dns.listen_doq needs dns.doh_tls.cert_file and key_file")
+	}
+	return nil
+}
+
+// ValidateDNSSEC checks that every trust anchor is a DS or DNSKEY record.
+func ValidateDNSSEC(d DNSSECConfig) error {
+	for _, a := range d.TrustAnchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			return fmt.Errorf("dns.dnssec.trust_anchors: %w", err)
+		}
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+		default:
+			return fmt.Errorf("dns.dnssec.trust_anchors: %q is not a DS or DNSKEY record", a)
+		}
+	}
+	return nil
+}
+
+// ValidateDNSQueryLog checks the query log retention settings.
+func ValidateDNSQueryLog(q DNSQueryLogConfig) error {
+	for _, f := range []struct{ name, value string }{
+		{"max_age", q.MaxAge},
+		{"stats_max_age", q.StatsMaxAge},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil {
+			return fmt.Errorf("dns.query_log.%s: %w", f.name, err)
+		}
+		if d < time.Hour {
+			return fmt.Errorf("dns.query_log.%s must be at least 1h, got %s", f.name, f.value)
+		}
+	}
+	if q.MaxEntries < 0 {
+		return fmt.Errorf("dns.query_log.max_entries must not be negative")
+	}
+	return nil
+}
+
+// ValidateDNSPolicies checks the DNS filtering policies: each needs a
+// unique name and at least one selector, and may only enable lists that
+// exist.
+func ValidateDNSPolicies(d DNSProxyConfig) error {
+	names := make(map[string]bool, len(d.Policies))
+	for i, p := range d.Policies {
+		if p.Name == "" {
+			return fmt.Errorf("dns.policy[%d]: name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("dns.policy[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+		if len(p.Subnets) == 0 && len(p.MACs) == 0 && !p.Reserved && len(p.DeviceTypes) == 0 {
+			return fmt.Errorf("dns.policy %q: needs at least one of subnets, macs, reserved or device_types", p.Name)
+		}
+		for _, cidr := range p.Subnets {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("dns.policy %q: subnets: %w", p.Name, err)
+			}
+		}
+		for _, mac := range p.MACs {
+			if _, err := net.ParseMAC(mac); err != nil {
+				return fmt.Errorf("dns.policy %q: macs: %w", p.Name, err)
+			}
+		}
+		for _, name := range p.Lists {
+			if !slices.ContainsFunc(d.Lists, func(l DNSListConfig) bool { return l.Name == name }) {
+				return fmt.Errorf("dns.policy %q: unknown list %q", p.Name, name)
+			}
+		}
+		for _, domain := range slices.Concat(p.Block, p.Allow) {
+			if strings.Trim(domain, ". ") == "" {
+				return fmt.Errorf("dns.policy %q: empty domain in block or allow", p.Name)
+			}
+		}
+		switch p.BlockAction {
+		case "", "nxdomain", "zero", "refuse":
+		default:
+			return fmt.Errorf("dns.policy %q: block_action must be nxdomain, zero or refuse, got %q", p.Name, p.BlockAction)
+		}
+	}
+	return nil
+}
+
+// ValidateAffinityPeriod checks a subnet's affinity_period: empty or a
+// non-negative duration.
+func ValidateAffinityPeriod(s string) error {
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+// AffinityPeriod returns how long an expired or released lease in the
+// subnet with the given network keeps its address for the same client
+// (0: the address is freed straight away).
+func (cfg *Config) AffinityPeriod(network string) time.Duration {
+	for _, sub := range cfg.Subnets {
+		if sub.Network == network {
+			d, _ := time.ParseDuration(sub.AffinityPeriod)
+			return max(d, 0)
+		}
+	}
+	return 0
+}
+
 // GetRenewalTime returns the effective renewal time (T1).
 func (cfg *Config) GetRenewalTime(subnetIdx int) time.Duration {
 	if subnetIdx >= 0 && subnetIdx < len(cfg.Subnets) {
@@ -904,6 +2025,43 @@ func (cfg *Config) GetRebindTime(subnetIdx int) time.Duration {
 	return d
 }
 
+// OnlyRequestedOptions reports whether replies on a subnet should carry only
+// the options the client asked for in its Parameter Request List.
+func (cfg *Config) OnlyRequestedOptions(subnetIdx int) bool {
+	if subnetIdx >= 0 && subnetIdx < len(cfg.Subnets) {
+		if v := cfg.Subnets[subnetIdx].OnlyRequestedOptions; v != nil {
+			return *v
+		}
+	}
+	return cfg.Defaults.OnlyRequestedOptions
+}
+
+// GetLifetimes6 returns the effective preferred and valid lifetimes and the
+// T1/T2 times for a DHCPv6 subnet. The valid lifetime falls back to the
+// default lease time, the preferred lifetime to the valid one, and T1/T2 to
+// 0.5 and 0.8 times the preferred lifetime (RFC 8415 §21.4).
+func (cfg *Config) GetLifetimes6(subnetIdx int) (preferred, valid, t1, t2 time.Duration) {
+	valid = cfg.GetLeaseTime(-1)
+	if subnetIdx < 0 || subnetIdx >= len(cfg.Subnets6) {
+		return valid, valid, valid / 2, valid * 4 / 5
+	}
+	sub := cfg.Subnets6[subnetIdx]
+	parse := func(s string, fallback time.Duration) time.Duration {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d
+		}
+		return fallback
+	}
+	valid = parse(sub.ValidLifetime, valid)
+	preferred = parse(sub.PreferredLifetime, valid)
+	if preferred > valid {
+		preferred = valid
+	}
+	t1 = parse(sub.RenewalTime, preferred/2)
+	t2 = parse(sub.RebindTime, preferred*4/5)
+	return preferred, valid, t1, t2
+}
+
 // ServerIP returns the parsed server identifier IP.
 func (cfg *Config) ServerIP() net.IP {
 	if cfg.Server.ServerID == "" {
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/BurntSushi/toml"
 )
 
 func writeTestConfig(t *testing.T, content string) string {
@@ -399,3 +403,607 @@ func TestApplyDefaults(t *testing.T) {
 		t.Errorf("default ProbeStrategy = %q, want %q", cfg.ConflictDetection.ProbeStrategy, "sequential")
 	}
 }
+
+func TestValidateCustomOptions(t *testing.T) {
+	path := writeTestConfig(t, minimalConfig+`
+  [[subnet.option]]
+  code = 66
+  type = "string"
+  value = "tftp.example.com"
+
+  [[subnet.option]]
+  code = 150
+  type = "ip_list"
+  value = ["192.168.1.5"]
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Subnets[0].Options) != 2 {
+		t.Fatalf("Options = %d, want 2", len(cfg.Subnets[0].Options))
+	}
+
+	bad := []string{
+		"code = 66\ntype = \"ip\"\nvalue = \"not-an-ip\"",
+		"code = 0\ntype = \"string\"\nvalue = \"x\"",
+		"code = 54\ntype = \"ip\"\nvalue = \"192.168.1.1\"",
+		"code = 200\ntype = \"float\"\nvalue = \"1.5\"",
+		"code = 3\ntype = \"string\"\nvalue = \"gateway\"", // router must be an IP list
+		"code = 26\ntype = \"uint32\"\nvalue = 1500",       // MTU is uint16
+	}
+	for _, opt := range bad {
+		path := writeTestConfig(t, minimalConfig+"\n  [[subnet.option]]\n"+opt+"\n")
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for option %q", opt)
+		}
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	path := writeTestConfig(t, minimalConfig+`  routers = ["192.168.1.254"]
+  lease_time = "1h"
+  boot_file = "ipxe.efi"
+
+  [[subnet.reservation]]
+  mac = "00:11:22:33:44:55"
+  ip = "192.168.1.50"
+  dns_servers = ["1.1.1.1"]
+  next_server = "192.168.1.5"
+
+    [[subnet.reservation.option]]
+    code = 66
+    type = "string"
+    value = "tftp.example.com"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if got := cfg.Subnets[0].Pools[0].Routers; len(got) != 1 || got[0] != "192.168.1.254" {
+		t.Errorf("pool routers = %v", got)
+	}
+	if got := cfg.Subnets[0].Reservations[0].Options; len(got) != 1 || got[0].Code != 66 {
+		t.Errorf("reservation options = %v", got)
+	}
+
+	bad := []string{
+		"  routers = [\"not-an-ip\"]\n",
+		"  lease_time = \"forever\"\n",
+		"\n  [[subnet.reservation]]\n  mac = \"00:11:22:33:44:55\"\n  ip = \"192.168.1.50\"\n  next_server = \"bad\"\n",
+		"\n  [[subnet.reservation]]\n  mac = \"00:11:22:33:44:55\"\n  ip = \"192.168.1.50\"\n  boot_file = \"" + strings.Repeat("x", 128) + "\"\n",
+		"\n  [[subnet.reservation]]\n  mac = \"00:11:22:33:44:55\"\n  ip = \"192.168.1.50\"\n    [[subnet.reservation.option]]\n    code = 51\n    type = \"uint32\"\n    value = 60\n",
+	}
+	for _, extra := range bad {
+		path := writeTestConfig(t, minimalConfig+extra)
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for %q", extra)
+		}
+	}
+}
+
+func TestValidateClientClasses(t *testing.T) {
+	classes := `
+[[client_class]]
+name = "voip"
+match = "device.type == 'voip' or option[60] startswith 'Polycom'"
+lease_time = "1h"
+
+[[client_class]]
+name = "blocked"
+match = "mac.oui == '00:11:22'"
+deny = true
+`
+	path := writeTestConfig(t, minimalConfig+"  client_classes = [\"voip\"]\n"+classes)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.ClientClasses) != 2 || !cfg.ClientClasses[1].Deny {
+		t.Errorf("ClientClasses = %+v", cfg.ClientClasses)
+	}
+	if got := cfg.Subnets[0].Pools[0].ClientClasses; len(got) != 1 || got[0] != "voip" {
+		t.Errorf("pool client_classes = %v", got)
+	}
+
+	bad := []string{
+		"  client_classes = [\"nope\"]\n" + classes,
+		"[[client_class]]\nname = \"x\"\nmatch = \"vendor_class ==\"\n",
+		"[[client_class]]\nname = \"\"\nmatch = \"true\"\n",
+		"[[client_class]]\nname = \"x\"\nmatch = \"true\"\n[[client_class]]\nname = \"x\"\nmatch = \"true\"\n",
+		"[[client_class]]\nname = \"x\"\nmatch = \"true\"\nlease_time = \"soon\"\n",
+	}
+	for _, extra := range bad {
+		path := writeTestConfig(t, minimalConfig+extra)
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for %q", extra)
+		}
+	}
+}
+
+func TestValidateSubnet6(t *testing.T) {
+	subnet6 := `
+[[subnet6]]
+network = "2001:db8:1::/64"
+dns_servers = ["2001:db8::53"]
+valid_lifetime = "2h"
+preferred_lifetime = "1h"
+
+  [[subnet6.pool]]
+  range_start = "2001:db8:1::100"
+  range_end = "2001:db8:1::1ff"
+
+  [[subnet6.pd_pool]]
+  prefix = "2001:db8:ff00::/40"
+  delegated_length = 56
+
+  [[subnet6.reservation]]
+  duid = "00:03:00:01:aa:bb:cc:00:00:01"
+  ip = "2001:db8:1::10"
+`
+	cfg, err := Load(writeTestConfig(t, minimalConfig+subnet6))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Subnets6) != 1 || cfg.Subnets6[0].PDPools[0].DelegatedLength != 56 {
+		t.Fatalf("Subnets6 = %+v", cfg.Subnets6)
+	}
+	preferred, valid, t1, t2 := cfg.GetLifetimes6(0)
+	if preferred != time.Hour || valid != 2*time.Hour || t1 != 30*time.Minute || t2 != 48*time.Minute {
+		t.Errorf("lifetimes = %v %v %v %v", preferred, valid, t1, t2)
+	}
+	if _, valid, _, _ := cfg.GetLifetimes6(5); valid != 8*time.Hour {
+		t.Errorf("fallback valid lifetime = %v, want defaults lease_time", valid)
+	}
+
+	bad := []Subnet6Config{
+		{},
+		{Network: "192.168.1.0/24"},
+		{Network: "2001:db8::/64", Pools: []Pool6Config{{RangeStart: "2001:db9::1", RangeEnd: "2001:db9::2"}}},
+		{Network: "2001:db8::/64", Pools: []Pool6Config{{RangeStart: "2001:db8::9", RangeEnd: "2001:db8::1"}}},
+		{Network: "2001:db8::/64", PDPools: []PDPoolConfig{{Prefix: "2001:db8:ff00::/48", DelegatedLength: 40}}},
+		{Network: "2001:db8::/64", Reservations: []Reservation6Config{{DUID: "zz", IP: "2001:db8::1"}}},
+		{Network: "2001:db8::/64", Reservations: []Reservation6Config{{DUID: "00:03:00:01:aa:bb:cc:00:00:01"}}},
+		{Network: "2001:db8::/64", DNSServers: []string{"8.8.8.8"}},
+		{Network: "2001:db8::/64", ValidLifetime: "forever"},
+	}
+	for i, sub := range bad {
+		if err := ValidateSubnet6(sub); err == nil {
+			t.Errorf("bad[%d]: expected error for %+v", i, sub)
+		}
+	}
+
+	overlap := minimalConfig + subnet6 + "\n[[subnet6]]\nnetwork = \"2001:db8:1::/48\"\n"
+	if _, err := Load(writeTestConfig(t, overlap)); err == nil {
+		t.Error("expected error for overlapping subnet6 networks")
+	}
+}
+
+func TestValidateLeaseQuery(t *testing.T) {
+	lq := `
+[leasequery]
+enabled = true
+allowed_relays = ["10.0.0.1", "192.168.0.0/16"]
+bulk_enabled = true
+`
+	cfg, err := Load(writeTestConfig(t, minimalConfig+lq))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.LeaseQuery.BulkListen != DefaultLeaseQueryBulkListen || cfg.LeaseQuery.MaxConnections != DefaultLeaseQueryMaxConns {
+		t.Errorf("LeaseQuery defaults not applied: %+v", cfg.LeaseQuery)
+	}
+
+	bad := []LeaseQueryConfig{
+		{AllowedRelays: []string{"relay1"}},
+		{AllowedRelays: []string{"10.0.0.0/33"}},
+		{BulkListen: "67"},
+		{MaxConnections: -1},
+		{IdleTimeout: "forever"},
+	}
+	for i, c := range bad {
+		if err := ValidateLeaseQuery(c); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, c)
+		}
+	}
+}
+
+// testMembers returns cluster members with the given IDs.
+func testMembers(ids ...string) []HAMember {
+	var m []HAMember
+	for i, id := range ids {
+		m = append(m, HAMember{ID: id, Address: fmt.Sprintf("10.0.0.%d:8068", i+1)})
+	}
+	return m
+}
+
+func TestValidateHAMode(t *testing.T) {
+	good := []HAConfig{
+		{},
+		{Mode: HAModeActiveStandby},
+		{Mode: HAModeLoadBalancing, MCLT: "30m", SafetyPeriod: "0s"},
+		{SplitBrain: HASplitBrainConfig{Witness: "http://10.0.0.9:8069", Gateways: []string{"192.168.1.1"}, CheckTimeout: "1s"}},
+		{SyncQueueSize: 500, SyncAck: true, SyncAckTimeout: "250ms"},
+		{Mode: HAModeCluster, NodeID: "b", Members: testMembers("a", "b", "c")},
+		{AuthKey: "0123456789abcdef", AuthWindow: "10s"},
+		{AuthKeyFile: "/etc/athena-dhcpd/ha.key"},
+		{TLS: HATLSConfig{Enabled: true, CertFile: "a.crt", KeyFile: "a.key", CAFile: "ca.crt", PeerName: "dhcp-b"}},
+	}
+	for i, c := range good {
+		if err := validateHAMode(c); err != nil {
+			t.Errorf("good case %d: unexpected error: %v", i, err)
+		}
+	}
+
+	bad := []HAConfig{
+		{Mode: "active-active"},
+		{Mode: HAModeLoadBalancing, MCLT: "0s"},
+		{Mode: HAModeLoadBalancing, MCLT: "soon"},
+		{Mode: HAModeLoadBalancing, SafetyPeriod: "-1h"},
+		{SplitBrain: HASplitBrainConfig{Witness: "10.0.0.9:8069"}},
+		{SplitBrain: HASplitBrainConfig{Gateways: []string{"gateway"}}},
+		{SplitBrain: HASplitBrainConfig{Gateways: []string{"fe80::1"}}},
+		{SplitBrain: HASplitBrainConfig{CheckTimeout: "0s"}},
+		{SyncQueueSize: -1},
+		{SyncAck: true, SyncAckTimeout: "0s"},
+		{Mode: HAModeCluster, Members: testMembers("a", "b", "c")},
+		{Mode: HAModeCluster, NodeID: "a", Members: testMembers("a", "b")},
+		{Mode: HAModeCluster, NodeID: "d", Members: testMembers("a", "b", "c")},
+		{Mode: HAModeCluster, NodeID: "a", Members: testMembers("a", "b", "b")},
+		{Mode: HAModeCluster, NodeID: "a", Members: append(testMembers("a", "b"), HAMember{ID: "c", Address: "10.0.0.3"})},
+		{AuthKey: "short"},
+		{AuthKey: "0123456789abcdef", AuthKeyFile: "/etc/athena-dhcpd/ha.key"},
+		{AuthKey: "0123456789abcdef", AuthWindow: "0s"},
+		{TLS: HATLSConfig{Enabled: true, CertFile: "a.crt", KeyFile: "a.key"}},
+	}
+	for i, c := range bad {
+		if err := validateHAMode(c); err == nil {
+			t.Errorf("bad case %d: expected error for %+v", i, c)
+		}
+	}
+
+	ha := HAConfig{Mode: HAModeLoadBalancing}
+	applyHAModeDefaults(&ha)
+	if ha.MCLT != DefaultHAMCLT.String() || ha.SafetyPeriod != ha.MCLT ||
+		ha.SyncQueueSize != DefaultHASyncQueueSize || ha.SyncAckTimeout != DefaultHASyncAckTimeout.String() {
+		t.Errorf("HA mode defaults not applied: %+v", ha)
+	}
+}
+
+func TestWriteHASectionCluster(t *testing.T) {
+	path := writeTestConfig(t, `
+[server]
+interface = "eth0"
+
+[ha]
+enabled = true
+mode = "cluster"
+node_id = "a"
+
+[[ha.member]]
+id = "old"
+address = "10.0.0.9:8068"
+
+[api]
+listen = ":8067"
+`)
+	ha := HAConfig{Enabled: true, Mode: HAModeCluster, NodeID: "a", ListenAddress: "0.0.0.0:8068",
+		Members: testMembers("a", "b", "c")}
+	ha.TLS.Enabled = true
+	if err := WriteHASection(path, &ha); err != nil {
+		t.Fatalf("WriteHASection error: %v", err)
+	}
+
+	var got Config
+	if _, err := toml.DecodeFile(path, &got); err != nil {
+		t.Fatalf("decoding rewritten config: %v", err)
+	}
+	if len(got.HA.Members) != 3 || got.HA.Members[2].ID != "c" || got.HA.NodeID != "a" || !got.HA.TLS.Enabled {
+		t.Errorf("rewritten ha = %+v", got.HA)
+	}
+	if got.Server.Interface != "eth0" || got.API.Listen != ":8067" {
+		t.Errorf("other sections lost: server=%+v api=%+v", got.Server, got.API)
+	}
+}
+
+func TestValidateDatabase(t *testing.T) {
+	db := `
+[server.database]
+snapshot_dir = "/var/lib/athena-dhcpd/snapshots"
+compact_interval = "6h"
+`
+	cfg, err := Load(writeTestConfig(t, strings.Replace(minimalConfig, "\n[defaults]", db+"\n[defaults]", 1)))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	d := cfg.Server.Database
+	if d.CompactThreshold != DefaultCompactThreshold || d.SnapshotInterval != DefaultSnapshotInterval.String() ||
+		d.SnapshotRetain != DefaultSnapshotRetain {
+		t.Errorf("database defaults not applied: %+v", d)
+	}
+
+	bad := []DatabaseConfig{
+		{CompactInterval: "30s"},
+		{CompactInterval: "daily"},
+		{SnapshotInterval: "0s"},
+		{CompactThreshold: 101},
+		{SnapshotRetain: -1},
+	}
+	for i, c := range bad {
+		if err := validateDatabase(c); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, c)
+		}
+	}
+}
+
+func TestValidateLeaseBackend(t *testing.T) {
+	srv := ServerConfig{LeaseDB: DefaultLeaseDB}
+	applyLeaseBackendDefaults(&srv)
+	if srv.LeaseBackend != LeaseBackendBoltDB || srv.LeaseDSN != "" {
+		t.Errorf("boltdb defaults = %+v", srv)
+	}
+	srv = ServerConfig{LeaseDB: DefaultLeaseDB, LeaseBackend: LeaseBackendSQLite}
+	applyLeaseBackendDefaults(&srv)
+	if srv.LeaseDSN != DefaultLeaseSQLite {
+		t.Errorf("sqlite lease_dsn default = %q", srv.LeaseDSN)
+	}
+	if err := validateLeaseBackend(srv); err != nil {
+		t.Errorf("sqlite backend: %v", err)
+	}
+
+	bad := []ServerConfig{
+		{LeaseBackend: "postgres"},
+		{LeaseBackend: LeaseBackendSQLite, LeaseDB: "/var/lib/a.db", LeaseDSN: "/var/lib/a.db"},
+	}
+	for i, c := range bad {
+		if err := validateLeaseBackend(c); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, c)
+		}
+	}
+}
+
+func TestAffinityPeriod(t *testing.T) {
+	withAffinity := func(d string) string {
+		return strings.Replace(minimalConfig, "dns_servers = [\"8.8.8.8\"]\n", "dns_servers = [\"8.8.8.8\"]\naffinity_period = \""+d+"\"\n", 1)
+	}
+	path := writeTestConfig(t, withAffinity("72h"))
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if got := cfg.AffinityPeriod("192.168.1.0/24"); got != 72*time.Hour {
+		t.Errorf("AffinityPeriod = %v, want 72h", got)
+	}
+	if got := cfg.AffinityPeriod("10.0.0.0/8"); got != 0 {
+		t.Errorf("AffinityPeriod of an unknown subnet = %v, want 0", got)
+	}
+
+	for _, bad := range []string{"forever", "-1h"} {
+		path := writeTestConfig(t, withAffinity(bad))
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for affinity_period %q", bad)
+		}
+	}
+}
+
+func TestValidatePoolStrategy(t *testing.T) {
+	for _, strategy := range []string{"", PoolStrategyLRU, PoolStrategySequential, PoolStrategyRandom} {
+		path := writeTestConfig(t, minimalConfig+"allocation_strategy = \""+strategy+"\"\n")
+		if _, err := Load(path); err != nil {
+			t.Errorf("allocation_strategy %q: %v", strategy, err)
+		}
+	}
+	path := writeTestConfig(t, minimalConfig+"allocation_strategy = \"fifo\"\n")
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for allocation_strategy \"fifo\"")
+	}
+}
+
+func TestValidatePressure(t *testing.T) {
+	good := []SubnetConfig{
+		{Network: "10.0.0.0/24"},
+		{Network: "10.0.0.0/24", OverflowSubnet: "10.0.1.0/24"},
+		{Network: "10.0.0.0/24", Pressure: &PressureConfig{HighUtilisation: 80, OfferReclaimAfter: "0s",
+			LeaseTimes: []PressureLeaseTime{{Above: 75, LeaseTime: "1h"}, {Above: 95, LeaseTime: "10m"}}}},
+	}
+	for i, sub := range good {
+		if err := ValidatePressure(sub); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+	}
+
+	bad := []SubnetConfig{
+		{Network: "10.0.0.0/24", OverflowSubnet: "10.0.0.0/24"},
+		{Network: "10.0.0.0/24", OverflowSubnet: "bogus"},
+		{Network: "10.0.0.0/24", Pressure: &PressureConfig{HighUtilisation: 120}},
+		{Network: "10.0.0.0/24", Pressure: &PressureConfig{OfferReclaimAfter: "-1s"}},
+		{Network: "10.0.0.0/24", Pressure: &PressureConfig{LeaseTimes: []PressureLeaseTime{{Above: 100, LeaseTime: "1h"}}}},
+		{Network: "10.0.0.0/24", Pressure: &PressureConfig{LeaseTimes: []PressureLeaseTime{{Above: 80, LeaseTime: "0s"}}}},
+	}
+	for i, sub := range bad {
+		if err := ValidatePressure(sub); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, sub)
+		}
+	}
+
+	// The overflow subnet has to exist
+	path := writeTestConfig(t, strings.Replace(minimalConfig, "dns_servers = [\"8.8.8.8\"]\n", "dns_servers = [\"8.8.8.8\"]\noverflow_subnet = \"10.9.0.0/24\"\n", 1))
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for an overflow_subnet that is not configured")
+	}
+}
+
+func TestValidateDNSLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		sch     DNSScheduleConfig
+		wantErr bool
+	}{
+		{"weekdays", DNSScheduleConfig{Days: []string{"mon", "Tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}, false},
+		{"every day overnight", DNSScheduleConfig{Start: "22:00", End: "06:30"}, false},
+		{"bad day", DNSScheduleConfig{Days: []string{"monday"}, Start: "09:00", End: "17:00"}, true},
+		{"bad start", DNSScheduleConfig{Start: "9am", End: "17:00"}, true},
+		{"missing end", DNSScheduleConfig{Start: "09:00"}, true},
+		{"empty window", DNSScheduleConfig{Start: "09:00", End: "09:00"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists := []DNSListConfig{{Name: "social", Schedule: []DNSScheduleConfig{tt.sch}}}
+			if err := ValidateDNSLists(lists); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDNSLists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	path := writeTestConfig(t, minimalConfig+"\n[[dns.list]]\nname = \"social\"\nurl = \"http://example.com/social.txt\"\n[[dns.list.schedule]]\nstart = \"25:00\"\nend = \"17:00\"\n")
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a list schedule with a bad start")
+	}
+}
+
+func TestValidateDNSQueryLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       DNSQueryLogConfig
+		wantErr bool
+	}{
+		{"defaults", DNSQueryLogConfig{}, false},
+		{"a month", DNSQueryLogConfig{Persist: true, MaxAge: "720h", MaxEntries: 2000000, StatsMaxAge: "8760h"}, false},
+		{"bad max_age", DNSQueryLogConfig{MaxAge: "a week"}, true},
+		{"max_age under an hour", DNSQueryLogConfig{MaxAge: "30m"}, true},
+		{"stats_max_age under an hour", DNSQueryLogConfig{StatsMaxAge: "1m"}, true},
+		{"negative max_entries", DNSQueryLogConfig{MaxEntries: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDNSQueryLog(tt.q); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDNSQueryLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	path := writeTestConfig(t, minimalConfig+"\n[dns.query_log]\npersist = true\nmax_age = \"10m\"\n")
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a query log max_age under an hour")
+	}
+}
+
+func TestParseDNSUpstream(t *testing.T) {
+	tests := []struct {
+		upstream   string
+		wantScheme string
+		wantAddr   string
+		wantErr    bool
+	}{
+		{"1.1.1.1", "udp", "1.1.1.1:53", false},
+		{"8.8.8.8:5353", "udp", "8.8.8.8:5353", false},
+		{"2606:4700:4700::1111", "udp", "[2606:4700:4700::1111]:53", false},
+		{"tcp://9.9.9.9", "tcp", "9.9.9.9:53", false},
+		{"tls://1.1.1.1", "tls", "1.1.1.1:853", false},
+		{"TLS://dns.quad9.net:8853", "tls", "dns.quad9.net:8853", false},
+		{"tls://[2606:4700:4700::1111]", "tls", "[2606:4700:4700::1111]:853", false},
+		{"quic://dns.adguard-dns.com", "quic", "dns.adguard-dns.com:853", false},
+		{"https://dns.example/dns-query", "https", "https://dns.example/dns-query", false},
+		{"https://dns.example", "https", "https://dns.example/dns-query", false},
+		{"https://dns.example:8443/resolve", "https", "https://dns.example:8443/resolve", false},
+		{"", "", "", true},
+		{"sdns://abc", "", "", true},
+		{"tls://", "", "", true},
+		{"tls://1.1.1.1:0", "", "", true},
+		{"1.1.1.1:dns", "", "", true},
+		{"https://", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream, func(t *testing.T) {
+			scheme, addr, err := ParseDNSUpstream(tt.upstream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDNSUpstream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if scheme != tt.wantScheme || addr != tt.wantAddr {
+				t.Errorf("ParseDNSUpstream() = %q, %q, want %q, %q", scheme, addr, tt.wantScheme, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestValidateDNSTransports(t *testing.T) {
+	cert := DoHTLSConfig{CertFile: "/etc/athena/dns.crt", KeyFile: "/etc/athena/dns.key"}
+	tests := []struct {
+		name    string
+		d       DNSProxyConfig
+		wantErr bool
+	}{
+		{"mixed forwarders", DNSProxyConfig{Forwarders: []string{"1.1.1.1", "tls://1.1.1.1", "https://dns.example/dns-query", "quic://dns.example"}}, false},
+		{"bad forwarder", DNSProxyConfig{Forwarders: []string{"ftp://1.1.1.1"}}, true},
+		{"override nameserver uri", DNSProxyConfig{ZoneOverrides: []DNSZoneOverride{{Zone: "corp", Nameserver: "tls://10.0.0.53"}}}, false},
+		{"override doh url", DNSProxyConfig{ZoneOverrides: []DNSZoneOverride{{Zone: "corp", DoH: true, DoHURL: "https://dns.corp/dns-query"}}}, false},
+		{"override without nameserver", DNSProxyConfig{ZoneOverrides: []DNSZoneOverride{{Zone: "corp"}}}, true},
+		{"dot with cert", DNSProxyConfig{ListenDoT: "0.0.0.0:853", DoHTLS: cert}, false},
+		{"dot without cert", DNSProxyConfig{ListenDoT: "0.0.0.0:853"}, true},
+		{"doq without cert", DNSProxyConfig{ListenDoQ: "0.0.0.0:853"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDNSTransports(tt.d); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDNSTransports() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDNSSEC(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       DNSSECConfig
+		wantErr bool
+	}{
+		{"default anchors", DNSSECConfig{Validate: true}, false},
+		{"root anchors", DNSSECConfig{Validate: true, TrustAnchors: DefaultDNSSECTrustAnchors}, false},
+		{"dnskey anchor", DNSSECConfig{TrustAnchors: []string{"corp. 3600 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ=="}}, false},
+		{"not a record", DNSSECConfig{TrustAnchors: []string{"20326 8 2 E06D44B8"}}, true},
+		{"wrong type", DNSSECConfig{TrustAnchors: []string{"corp. IN A 192.0.2.1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDNSSEC(tt.d); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDNSSEC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDNSPolicies(t *testing.T) {
+	lists := []DNSListConfig{{Name: "adult", URL: "http://example.com/adult.txt"}}
+	good := []DNSPolicyConfig{
+		{Name: "kids", Subnets: []string{"10.0.20.0/24"}, Lists: []string{"adult"}, SafeSearch: true},
+		{Name: "iot", DeviceTypes: []string{"camera"}, Block: []string{"telemetry.example"}, BlockAction: "zero"},
+		{Name: "known", Reserved: true, MACs: []string{"aa:bb:cc:dd:ee:ff"}, Allow: []string{"example.com"}},
+	}
+	if err := ValidateDNSPolicies(DNSProxyConfig{Lists: lists, Policies: good}); err != nil {
+		t.Errorf("valid policies: %v", err)
+	}
+
+	bad := []DNSPolicyConfig{
+		{Subnets: []string{"10.0.20.0/24"}},
+		{Name: "empty"},
+		{Name: "cidr", Subnets: []string{"10.0.20.0"}},
+		{Name: "mac", MACs: []string{"not-a-mac"}},
+		{Name: "list", Reserved: true, Lists: []string{"missing"}},
+		{Name: "rule", Reserved: true, Block: []string{"."}},
+		{Name: "action", Reserved: true, BlockAction: "drop"},
+	}
+	for i, p := range bad {
+		if err := ValidateDNSPolicies(DNSProxyConfig{Lists: lists, Policies: []DNSPolicyConfig{p}}); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, p)
+		}
+	}
+	dup := []DNSPolicyConfig{good[0], good[0]}
+	if err := ValidateDNSPolicies(DNSProxyConfig{Lists: lists, Policies: dup}); err == nil {
+		t.Error("expected error for duplicate policy names")
+	}
+
+	path := writeTestConfig(t, minimalConfig+"\n[[dns.policy]]\nname = \"kids\"\nsubnets = [\"10.0.20.0/24\"]\nlists = [\"missing\"]\n")
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a policy enabling an unknown list")
+	}
+}
@@ -7,7 +7,11 @@ const (
 	DefaultInterface            = "eth0"
 	DefaultLogLevel             = "info"
 	DefaultLeaseDB              = "/var/lib/athena-dhcpd/leases.db"
+	DefaultLeaseSQLite          = "/var/lib/athena-dhcpd/leases.sqlite"
 	DefaultPIDFile              = "/run/athena-dhcpd/athena-dhcpd.pid"
+	DefaultCompactThreshold     = 25
+	DefaultSnapshotInterval     = 1 * time.Hour
+	DefaultSnapshotRetain       = 24
 	DefaultLeaseTime            = 12 * time.Hour
 	DefaultRenewalTime          = 6 * time.Hour
 	DefaultRebindTime           = 10*time.Hour + 30*time.Minute
@@ -25,6 +29,12 @@ const (
 	DefaultHAHeartbeatInterval  = 1 * time.Second
 	DefaultHAFailoverTimeout    = 10 * time.Second
 	DefaultHASyncBatchSize      = 100
+	DefaultHASyncQueueSize      = 10000
+	DefaultHASyncAckTimeout     = 1 * time.Second
+	DefaultHAAuthWindow         = 30 * time.Second
+	DefaultHAMCLT               = 1 * time.Hour
+	DefaultHASplitBrainTimeout  = 2 * time.Second
+	DefaultHAWitnessGroup       = "default"
 	DefaultAPIListen            = "0.0.0.0:8067"
 	DefaultSessionExpiry        = 24 * time.Hour
 	DefaultSessionCookieName    = "athena_session"
@@ -39,4 +49,20 @@ const (
 	DefaultDNSTTL               = 60
 	DefaultDNSCacheSize         = 10000
 	DefaultDNSCacheTTL          = 5 * time.Minute
+	DefaultDNSQueryLogMaxAge    = 7 * 24 * time.Hour
+	DefaultDNSQueryLogEntries   = 500000
+	DefaultDNSQueryStatsMaxAge  = 90 * 24 * time.Hour
+	DefaultHighUtilisation      = 90
+	DefaultOfferReclaimAfter    = 10 * time.Second
+
+	DefaultLeaseQueryBulkListen  = "0.0.0.0:67"
+	DefaultLeaseQueryMaxConns    = 10
+	DefaultLeaseQueryIdleTimeout = 60 * time.Second
 )
+
+// DefaultDNSSECTrustAnchors are the IANA root zone KSK-2017 and KSK-2024
+// DS records, used when dnssec.trust_anchors is empty.
+var DefaultDNSSECTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
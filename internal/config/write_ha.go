@@ -20,12 +20,12 @@ func WriteHASection(path string, ha *HAConfig) error {
 		return fmt.Errorf("reading config file: %w", err)
 	}
 
-	// Build the new [ha] block
+	// Build the new [ha] block. Encoding it under an "ha" key keeps
+	// sub-tables and [[ha.member]] arrays inside the section.
 	var buf bytes.Buffer
-	buf.WriteString("[ha]\n")
 	enc := toml.NewEncoder(&buf)
 	enc.Indent = ""
-	if err := enc.Encode(haToMap(ha)); err != nil {
+	if err := enc.Encode(map[string]interface{}{"ha": haToMap(ha)}); err != nil {
 		return fmt.Errorf("encoding HA config: %w", err)
 	}
 
@@ -75,7 +75,7 @@ func WriteHASection(path string, ha *HAConfig) error {
 
 // replaceOrAppendSection replaces a TOML top-level section (and its sub-tables)
 // with newContent, or appends it if the section doesn't exist.
-// sectionName should be bare, e.g. "ha" — it matches [ha] and [ha.*].
+// sectionName should be bare, e.g. "ha" — it matches [ha], [ha.*] and [[ha.*]].
 func replaceOrAppendSection(content, sectionName, newContent string) string {
 	lines := strings.Split(content, "\n")
 	var result []string
@@ -83,11 +83,15 @@ func replaceOrAppendSection(content, sectionName, newContent string) string {
 	replaced := false
 	header := "[" + sectionName + "]"
 	subPrefix := "[" + sectionName + "."
+	arrayPrefix := "[[" + sectionName + "."
+	ours := func(trimmed string) bool {
+		return trimmed == header || strings.HasPrefix(trimmed, subPrefix) || strings.HasPrefix(trimmed, arrayPrefix)
+	}
 
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if !inSection {
-			if trimmed == header || strings.HasPrefix(trimmed, subPrefix) {
+			if ours(trimmed) {
 				inSection = true
 				if !replaced {
 					result = append(result, strings.TrimRight(newContent, "\n"))
@@ -98,7 +102,7 @@ func replaceOrAppendSection(content, sectionName, newContent string) string {
 			result = append(result, line)
 		} else {
 			// Check if this line starts a new section that isn't part of our target
-			if len(trimmed) > 0 && trimmed[0] == '[' && trimmed != header && !strings.HasPrefix(trimmed, subPrefix) {
+			if len(trimmed) > 0 && trimmed[0] == '[' && !ours(trimmed) {
 				inSection = false
 				result = append(result, line)
 				continue
@@ -114,8 +118,7 @@ func replaceOrAppendSection(content, sectionName, newContent string) string {
 	return strings.Join(result, "\n")
 }
 
-// haToMap converts HAConfig to a flat map for TOML encoding without the
-// wrapping [ha] table header (we add that ourselves).
+// haToMap converts HAConfig to the map encoded as the [ha] table.
 func haToMap(ha *HAConfig) map[string]interface{} {
 	m := map[string]interface{}{
 		"enabled": ha.Enabled,
@@ -138,6 +141,47 @@ func haToMap(ha *HAConfig) map[string]interface{} {
 	if ha.SyncBatchSize > 0 {
 		m["sync_batch_size"] = ha.SyncBatchSize
 	}
+	if ha.SyncQueueSize > 0 {
+		m["sync_queue_size"] = ha.SyncQueueSize
+	}
+	if ha.SyncAck {
+		m["sync_ack"] = true
+	}
+	if ha.SyncAckTimeout != "" {
+		m["sync_ack_timeout"] = ha.SyncAckTimeout
+	}
+	if ha.Mode != "" {
+		m["mode"] = ha.Mode
+	}
+	if ha.NodeID != "" {
+		m["node_id"] = ha.NodeID
+	}
+	if len(ha.Members) > 0 {
+		members := make([]map[string]interface{}, 0, len(ha.Members))
+		for _, mem := range ha.Members {
+			entry := map[string]interface{}{"id": mem.ID, "address": mem.Address}
+			if mem.TLSName != "" {
+				entry["tls_name"] = mem.TLSName
+			}
+			members = append(members, entry)
+		}
+		m["member"] = members
+	}
+	if ha.MCLT != "" {
+		m["mclt"] = ha.MCLT
+	}
+	if ha.SafetyPeriod != "" {
+		m["safety_period"] = ha.SafetyPeriod
+	}
+	if ha.AuthKey != "" {
+		m["auth_key"] = ha.AuthKey
+	}
+	if ha.AuthKeyFile != "" {
+		m["auth_key_file"] = ha.AuthKeyFile
+	}
+	if ha.AuthWindow != "" {
+		m["auth_window"] = ha.AuthWindow
+	}
 	if ha.TLS.Enabled || ha.TLS.CertFile != "" || ha.TLS.KeyFile != "" || ha.TLS.CAFile != "" {
 		tls := map[string]interface{}{
 			"enabled": ha.TLS.Enabled,
@@ -151,7 +195,26 @@ func haToMap(ha *HAConfig) map[string]interface{} {
 		if ha.TLS.CAFile != "" {
 			tls["ca_file"] = ha.TLS.CAFile
 		}
+		if ha.TLS.PeerName != "" {
+			tls["peer_name"] = ha.TLS.PeerName
+		}
 		m["tls"] = tls
 	}
+	if sb := ha.SplitBrain; sb.Enabled() {
+		split := map[string]interface{}{}
+		if sb.Witness != "" {
+			split["witness"] = sb.Witness
+		}
+		if sb.Group != "" {
+			split["group"] = sb.Group
+		}
+		if len(sb.Gateways) > 0 {
+			split["gateways"] = sb.Gateways
+		}
+		if sb.CheckTimeout != "" {
+			split["check_timeout"] = sb.CheckTimeout
+		}
+		m["split_brain"] = split
+	}
 	return m
 }
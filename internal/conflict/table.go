@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -29,7 +30,7 @@ type Record struct {
 
 // Table manages the conflict table with BoltDB persistence and in-memory cache.
 type Table struct {
-	db              *bolt.DB
+	db              boltdb.DB
 	records         map[string]*Record // IP string → Record
 	mu              sync.RWMutex
 	holdTime        time.Duration
@@ -37,7 +38,7 @@ type Table struct {
 }
 
 // NewTable creates a new conflict table backed by BoltDB.
-func NewTable(db *bolt.DB, holdTime time.Duration, maxConflictCount int) (*Table, error) {
+func NewTable(db boltdb.DB, holdTime time.Duration, maxConflictCount int) (*Table, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists(bucketConflicts)
 		return err
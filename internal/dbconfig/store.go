@@ -4,10 +4,12 @@ package dbconfig
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	bolt "go.etcd.io/bbolt"
 )
@@ -15,6 +17,7 @@ import (
 // BoltDB bucket names for config storage.
 var (
 	bucketSubnets     = []byte("config_subnets")
+	bucketSubnets6    = []byte("config_subnets6")
 	bucketDefaults    = []byte("config_defaults")
 	bucketConflict    = []byte("config_conflict")
 	bucketHooks       = []byte("config_hooks")
@@ -23,8 +26,11 @@ var (
 	bucketHostSanit   = []byte("config_hostname_sanitisation")
 	bucketFingerprint = []byte("config_fingerprint")
 	bucketSyslog      = []byte("config_syslog")
+	bucketLeaseQuery  = []byte("config_leasequery")
 	bucketPortAuto    = []byte("config_portauto")
+	bucketRADIUS      = []byte("config_radius")
 	bucketVIPs        = []byte("config_vips")
+	bucketClasses     = []byte("config_client_classes")
 	bucketMeta        = []byte("config_meta")
 	bucketUsers       = []byte("config_users")
 
@@ -36,19 +42,27 @@ var (
 	keyHostSanit     = []byte("hostname_sanitisation")
 	keyFingerprint   = []byte("fingerprint")
 	keySyslog        = []byte("syslog")
+	keyLeaseQuery    = []byte("leasequery")
 	keyPortAuto      = []byte("portauto_rules")
+	keyRADIUS        = []byte("radius_subnets")
 	keyVIPs          = []byte("vips")
+	keyClasses       = []byte("client_classes")
 	keySetupComplete = []byte("setup_complete")
 )
 
+// ErrClassInUse is returned when a client class update would remove a class
+// that a pool still limits itself to.
+var ErrClassInUse = errors.New("client class in use")
+
 // Store provides CRUD access to dynamic configuration stored in BoltDB.
 // Thread-safe — all reads and writes are protected by a mutex.
 type Store struct {
-	db *bolt.DB
+	db boltdb.DB
 	mu sync.RWMutex
 
 	// In-memory cache
 	subnets       []config.SubnetConfig
+	subnets6      []config.Subnet6Config
 	defaults      config.DefaultsConfig
 	conflict      config.ConflictDetectionConfig
 	hooks         config.HooksConfig
@@ -57,8 +71,11 @@ type Store struct {
 	hostSanit     config.HostnameSanitisationConfig
 	fingerprint   config.FingerprintConfig
 	syslog        config.SyslogConfig
+	leaseQuery    config.LeaseQueryConfig
 	portAutoRules json.RawMessage
+	radius        json.RawMessage
 	vips          json.RawMessage
+	clientClasses []config.ClientClassConfig
 	users         []config.UserConfig
 
 	// Listeners notified on config changes (fires for ALL changes, local + peer)
@@ -73,12 +90,12 @@ type Store struct {
 }
 
 // NewStore initializes the config store, creating buckets and loading cached state.
-func NewStore(db *bolt.DB) (*Store, error) {
+func NewStore(db boltdb.DB) (*Store, error) {
 	// Create buckets
 	err := db.Update(func(tx *bolt.Tx) error {
 		for _, b := range [][]byte{
-			bucketSubnets, bucketDefaults, bucketConflict,
-			bucketHooks, bucketDDNS, bucketDNS, bucketHostSanit, bucketFingerprint, bucketSyslog, bucketPortAuto, bucketVIPs, bucketMeta, bucketUsers,
+			bucketSubnets, bucketSubnets6, bucketDefaults, bucketConflict,
+			bucketHooks, bucketDDNS, bucketDNS, bucketHostSanit, bucketFingerprint, bucketSyslog, bucketLeaseQuery, bucketPortAuto, bucketRADIUS, bucketVIPs, bucketClasses, bucketMeta, bucketUsers,
 		} {
 			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
 				return fmt.Errorf("creating config bucket %s: %w", b, err)
@@ -232,6 +249,84 @@ func (s *Store) DeleteSubnet(network string) error {
 	return nil
 }
 
+// --- DHCPv6 Subnets ---
+
+// Subnets6 returns all configured DHCPv6 subnets.
+func (s *Store) Subnets6() []config.Subnet6Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]config.Subnet6Config, len(s.subnets6))
+	copy(out, s.subnets6)
+	return out
+}
+
+// GetSubnet6 returns a DHCPv6 subnet by network CIDR.
+func (s *Store) GetSubnet6(network string) (*config.Subnet6Config, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.subnets6 {
+		if s.subnets6[i].Network == network {
+			sub := s.subnets6[i]
+			return &sub, true
+		}
+	}
+	return nil, false
+}
+
+// PutSubnet6 creates or updates a DHCPv6 subnet.
+func (s *Store) PutSubnet6(sub config.Subnet6Config) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("marshalling subnet6: %w", err)
+	}
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSubnets6)
+		return b.Put([]byte(sub.Network), data)
+	}); err != nil {
+		return fmt.Errorf("storing subnet6 %s: %w", sub.Network, err)
+	}
+
+	s.mu.Lock()
+	found := false
+	for i := range s.subnets6 {
+		if s.subnets6[i].Network == sub.Network {
+			s.subnets6[i] = sub
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.subnets6 = append(s.subnets6, sub)
+	}
+	snapshot, _ := json.Marshal(s.subnets6)
+	s.mu.Unlock()
+	s.notifyLocalChange("subnets6", snapshot)
+	return nil
+}
+
+// DeleteSubnet6 removes a DHCPv6 subnet by network CIDR.
+func (s *Store) DeleteSubnet6(network string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSubnets6)
+		return b.Delete([]byte(network))
+	}); err != nil {
+		return fmt.Errorf("deleting subnet6 %s: %w", network, err)
+	}
+
+	s.mu.Lock()
+	for i := range s.subnets6 {
+		if s.subnets6[i].Network == network {
+			s.subnets6 = append(s.subnets6[:i], s.subnets6[i+1:]...)
+			break
+		}
+	}
+	snapshot, _ := json.Marshal(s.subnets6)
+	s.mu.Unlock()
+	s.notifyLocalChange("subnets6", snapshot)
+	return nil
+}
+
 // --- Reservations (scoped to a subnet) ---
 
 // GetReservations returns all reservations for a subnet.
@@ -478,6 +573,24 @@ func (s *Store) SetSyslog(sl config.SyslogConfig) error {
 	return nil
 }
 
+func (s *Store) LeaseQuery() config.LeaseQueryConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.leaseQuery
+}
+
+func (s *Store) SetLeaseQuery(lq config.LeaseQueryConfig) error {
+	data, _ := json.Marshal(lq)
+	if err := s.putJSON(bucketLeaseQuery, keyLeaseQuery, lq); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.leaseQuery = lq
+	s.mu.Unlock()
+	s.notifyLocalChange("leasequery", data)
+	return nil
+}
+
 // --- Port Automation Rules (stored as raw JSON) ---
 
 func (s *Store) PortAutoRules() json.RawMessage {
@@ -505,6 +618,33 @@ func (s *Store) SetPortAutoRules(data json.RawMessage) error {
 	return nil
 }
 
+// --- RADIUS per-subnet settings (stored as raw JSON, subnet CIDR → config) ---
+
+func (s *Store) RADIUS() json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.radius == nil {
+		return nil
+	}
+	cp := make(json.RawMessage, len(s.radius))
+	copy(cp, s.radius)
+	return cp
+}
+
+func (s *Store) SetRADIUS(data json.RawMessage) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRADIUS).Put(keyRADIUS, data)
+	}); err != nil {
+		return fmt.Errorf("storing radius config: %w", err)
+	}
+	s.mu.Lock()
+	s.radius = make(json.RawMessage, len(data))
+	copy(s.radius, data)
+	s.mu.Unlock()
+	s.notifyLocalChange("radius", data)
+	return nil
+}
+
 // --- Virtual IPs (floating IPs for HA) ---
 
 func (s *Store) VIPs() json.RawMessage {
@@ -534,6 +674,52 @@ func (s *Store) SetVIPs(data json.RawMessage) error {
 
 // HA config lives in TOML, not the database — see config.WriteHASection().
 
+// --- Client classes ---
+
+func (s *Store) ClientClasses() []config.ClientClassConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]config.ClientClassConfig, len(s.clientClasses))
+	copy(out, s.clientClasses)
+	return out
+}
+
+func (s *Store) SetClientClasses(classes []config.ClientClassConfig) error {
+	if err := s.checkClassesInUse(classes); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(classes)
+	if err := s.putJSON(bucketClasses, keyClasses, classes); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.clientClasses = classes
+	s.mu.Unlock()
+	s.notifyLocalChange("client_classes", data)
+	return nil
+}
+
+// checkClassesInUse returns ErrClassInUse if a pool is limited to a class
+// missing from classes. Such a pool would serve nobody.
+func (s *Store) checkClassesInUse(classes []config.ClientClassConfig) error {
+	names := make(map[string]bool, len(classes))
+	for _, cc := range classes {
+		names[cc.Name] = true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.subnets {
+		for i, p := range sub.Pools {
+			for _, name := range p.ClientClasses {
+				if !names[name] {
+					return fmt.Errorf("%w: %q is used by subnet %s pool[%d]", ErrClassInUse, name, sub.Network, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // --- Build full config ---
 
 // BuildConfig merges bootstrap TOML config with DB-stored dynamic config.
@@ -544,6 +730,8 @@ func (s *Store) BuildConfig(bootstrap *config.Config) *config.Config {
 	cfg := *bootstrap // shallow copy
 	cfg.Subnets = make([]config.SubnetConfig, len(s.subnets))
 	copy(cfg.Subnets, s.subnets)
+	cfg.Subnets6 = make([]config.Subnet6Config, len(s.subnets6))
+	copy(cfg.Subnets6, s.subnets6)
 	cfg.Defaults = s.defaults
 	cfg.ConflictDetection = s.conflict
 	// HA stays in TOML — it's node-identity config outside the DB sync
@@ -553,6 +741,9 @@ func (s *Store) BuildConfig(bootstrap *config.Config) *config.Config {
 	cfg.HostnameSanitisation = s.hostSanit
 	cfg.Fingerprint = s.fingerprint
 	cfg.Syslog = s.syslog
+	cfg.LeaseQuery = s.leaseQuery
+	cfg.ClientClasses = make([]config.ClientClassConfig, len(s.clientClasses))
+	copy(cfg.ClientClasses, s.clientClasses)
 
 	// Merge DB users into API auth (TOML users take precedence by being first)
 	if len(s.users) > 0 {
@@ -597,11 +788,22 @@ func (s *Store) ImportFromConfig(cfg *config.Config) error {
 	if err := s.SetSyslog(cfg.Syslog); err != nil {
 		return fmt.Errorf("importing syslog: %w", err)
 	}
+	if err := s.SetLeaseQuery(cfg.LeaseQuery); err != nil {
+		return fmt.Errorf("importing leasequery: %w", err)
+	}
+	if err := s.SetClientClasses(cfg.ClientClasses); err != nil {
+		return fmt.Errorf("importing client classes: %w", err)
+	}
 	for _, sub := range cfg.Subnets {
 		if err := s.PutSubnet(sub); err != nil {
 			return fmt.Errorf("importing subnet %s: %w", sub.Network, err)
 		}
 	}
+	for _, sub := range cfg.Subnets6 {
+		if err := s.PutSubnet6(sub); err != nil {
+			return fmt.Errorf("importing subnet6 %s: %w", sub.Network, err)
+		}
+	}
 	return nil
 }
 
@@ -616,6 +818,9 @@ func (s *Store) ExportAllSections() map[string][]byte {
 	if data, err := json.Marshal(s.subnets); err == nil {
 		sections["subnets"] = data
 	}
+	if data, err := json.Marshal(s.subnets6); err == nil {
+		sections["subnets6"] = data
+	}
 	if data, err := json.Marshal(s.defaults); err == nil {
 		sections["defaults"] = data
 	}
@@ -641,9 +846,18 @@ func (s *Store) ExportAllSections() map[string][]byte {
 	if data, err := json.Marshal(s.syslog); err == nil {
 		sections["syslog"] = data
 	}
+	if data, err := json.Marshal(s.leaseQuery); err == nil {
+		sections["leasequery"] = data
+	}
+	if data, err := json.Marshal(s.clientClasses); err == nil {
+		sections["client_classes"] = data
+	}
 	if s.portAutoRules != nil {
 		sections["portauto"] = s.portAutoRules
 	}
+	if s.radius != nil {
+		sections["radius"] = s.radius
+	}
 	if s.vips != nil {
 		sections["vips"] = s.vips
 	}
@@ -690,6 +904,36 @@ func (s *Store) ApplyPeerConfig(section string, data []byte) error {
 		s.subnets = subs
 		s.mu.Unlock()
 
+	case "subnets6":
+		var subs []config.Subnet6Config
+		if err := json.Unmarshal(data, &subs); err != nil {
+			return fmt.Errorf("unmarshalling peer subnets6: %w", err)
+		}
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(bucketSubnets6)
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			for _, sub := range subs {
+				d, err := json.Marshal(sub)
+				if err != nil {
+					return err
+				}
+				if err := b.Put([]byte(sub.Network), d); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("applying peer subnets6: %w", err)
+		}
+		s.mu.Lock()
+		s.subnets6 = subs
+		s.mu.Unlock()
+
 	case "defaults":
 		var d config.DefaultsConfig
 		if err := json.Unmarshal(data, &d); err != nil {
@@ -786,6 +1030,35 @@ func (s *Store) ApplyPeerConfig(section string, data []byte) error {
 		s.syslog = sl
 		s.mu.Unlock()
 
+	case "leasequery":
+		var lq config.LeaseQueryConfig
+		if err := json.Unmarshal(data, &lq); err != nil {
+			return fmt.Errorf("unmarshalling peer leasequery config: %w", err)
+		}
+		if err := s.putJSON(bucketLeaseQuery, keyLeaseQuery, lq); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.leaseQuery = lq
+		s.mu.Unlock()
+
+	case "client_classes":
+		var classes []config.ClientClassConfig
+		if err := json.Unmarshal(data, &classes); err != nil {
+			return fmt.Errorf("unmarshalling peer client classes: %w", err)
+		}
+		// The peer sends its subnets too; if they no longer use the class,
+		// a later sync applies the removal
+		if err := s.checkClassesInUse(classes); err != nil {
+			return err
+		}
+		if err := s.putJSON(bucketClasses, keyClasses, classes); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.clientClasses = classes
+		s.mu.Unlock()
+
 	case "portauto":
 		// Validate it's valid JSON array
 		if !json.Valid(data) {
@@ -801,6 +1074,20 @@ func (s *Store) ApplyPeerConfig(section string, data []byte) error {
 		copy(s.portAutoRules, data)
 		s.mu.Unlock()
 
+	case "radius":
+		if !json.Valid(data) {
+			return fmt.Errorf("invalid JSON for peer radius config")
+		}
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketRADIUS).Put(keyRADIUS, data)
+		}); err != nil {
+			return fmt.Errorf("storing peer radius config: %w", err)
+		}
+		s.mu.Lock()
+		s.radius = make(json.RawMessage, len(data))
+		copy(s.radius, data)
+		s.mu.Unlock()
+
 	case "vips":
 		if !json.Valid(data) {
 			return fmt.Errorf("invalid JSON for peer VIPs")
@@ -850,6 +1137,15 @@ func (s *Store) loadAll() error {
 				return nil
 			})
 		}
+		if b6 := tx.Bucket(bucketSubnets6); b6 != nil {
+			b6.ForEach(func(k, v []byte) error {
+				var sub config.Subnet6Config
+				if err := json.Unmarshal(v, &sub); err == nil {
+					s.subnets6 = append(s.subnets6, sub)
+				}
+				return nil
+			})
+		}
 
 		// Load singleton sections
 		loadJSON(tx, bucketDefaults, keyDefaults, &s.defaults)
@@ -860,6 +1156,8 @@ func (s *Store) loadAll() error {
 		loadJSON(tx, bucketHostSanit, keyHostSanit, &s.hostSanit)
 		loadJSON(tx, bucketFingerprint, keyFingerprint, &s.fingerprint)
 		loadJSON(tx, bucketSyslog, keySyslog, &s.syslog)
+		loadJSON(tx, bucketLeaseQuery, keyLeaseQuery, &s.leaseQuery)
+		loadJSON(tx, bucketClasses, keyClasses, &s.clientClasses)
 
 		// Load portauto rules as raw JSON
 		pab := tx.Bucket(bucketPortAuto)
@@ -870,6 +1168,15 @@ func (s *Store) loadAll() error {
 			}
 		}
 
+		// Load RADIUS settings as raw JSON
+		rb := tx.Bucket(bucketRADIUS)
+		if rb != nil {
+			if data := rb.Get(keyRADIUS); data != nil {
+				s.radius = make(json.RawMessage, len(data))
+				copy(s.radius, data)
+			}
+		}
+
 		// Load VIPs as raw JSON
 		vb := tx.Bucket(bucketVIPs)
 		if vb != nil {
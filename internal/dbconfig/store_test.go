@@ -1,6 +1,7 @@
 package dbconfig
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -210,6 +211,18 @@ func TestSingletonConfigs(t *testing.T) {
 		t.Error("expected conflict detection enabled")
 	}
 
+	// Leasequery
+	lq := config.LeaseQueryConfig{Enabled: true, AllowedRelays: []string{"10.0.0.0/8"}}
+	if err := s.SetLeaseQuery(lq); err != nil {
+		t.Fatalf("SetLeaseQuery: %v", err)
+	}
+	if got := s.LeaseQuery(); !got.Enabled || len(got.AllowedRelays) != 1 {
+		t.Errorf("LeaseQuery = %+v", got)
+	}
+	if !s.BuildConfig(&config.Config{}).LeaseQuery.Enabled {
+		t.Error("BuildConfig did not carry leasequery section")
+	}
+
 	// HA is bootstrap config (TOML only) — no DB getter/setter to test
 }
 
@@ -270,3 +283,150 @@ func TestImportFromConfig(t *testing.T) {
 
 // Suppress unused import warning
 var _ = os.TempDir
+
+func TestClientClassesPersistAndSync(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "classes.db")
+
+	db, _ := bolt.Open(path, 0600, nil)
+	s, _ := NewStore(db)
+	classes := []config.ClientClassConfig{
+		{Name: "voip", Match: "device.type == 'voip'", LeaseTime: "1h"},
+		{Name: "blocked", Match: "mac.oui == '00:11:22'", Deny: true},
+	}
+	if err := s.SetClientClasses(classes); err != nil {
+		t.Fatalf("SetClientClasses: %v", err)
+	}
+	if _, ok := s.ExportAllSections()["client_classes"]; !ok {
+		t.Error("client_classes missing from exported sections")
+	}
+	db.Close()
+
+	db2, _ := bolt.Open(path, 0600, nil)
+	defer db2.Close()
+	s2, _ := NewStore(db2)
+	got := s2.BuildConfig(&config.Config{}).ClientClasses
+	if len(got) != 2 || got[0].Name != "voip" || !got[1].Deny {
+		t.Fatalf("classes after reopen = %+v", got)
+	}
+
+	if err := s2.ApplyPeerConfig("client_classes", []byte(`[{"name":"peer","match":"true"}]`)); err != nil {
+		t.Fatalf("ApplyPeerConfig: %v", err)
+	}
+	if got := s2.ClientClasses(); len(got) != 1 || got[0].Name != "peer" {
+		t.Errorf("classes after peer sync = %+v", got)
+	}
+}
+
+func TestClientClassesInUse(t *testing.T) {
+	db, _ := bolt.Open(filepath.Join(t.TempDir(), "classes.db"), 0600, nil)
+	defer db.Close()
+	s, _ := NewStore(db)
+	classes := []config.ClientClassConfig{{Name: "voip", Match: "device.type == 'voip'"}}
+	if err := s.SetClientClasses(classes); err != nil {
+		t.Fatalf("SetClientClasses: %v", err)
+	}
+	sub := config.SubnetConfig{
+		Network: "10.0.0.0/24",
+		Pools:   []config.PoolConfig{{RangeStart: "10.0.0.100", RangeEnd: "10.0.0.199", ClientClasses: []string{"voip"}}},
+	}
+	if err := s.PutSubnet(sub); err != nil {
+		t.Fatalf("PutSubnet: %v", err)
+	}
+
+	if err := s.SetClientClasses(nil); !errors.Is(err, ErrClassInUse) {
+		t.Errorf("removing a class a pool uses: err = %v, want ErrClassInUse", err)
+	}
+	if err := s.ApplyPeerConfig("client_classes", []byte(`[]`)); !errors.Is(err, ErrClassInUse) {
+		t.Errorf("peer removing a class a pool uses: err = %v, want ErrClassInUse", err)
+	}
+	if got := s.ClientClasses(); len(got) != 1 {
+		t.Errorf("classes = %+v, want voip kept", got)
+	}
+
+	// Once the pool lets go of it, the class can go
+	sub.Pools[0].ClientClasses = nil
+	if err := s.PutSubnet(sub); err != nil {
+		t.Fatalf("PutSubnet: %v", err)
+	}
+	if err := s.SetClientClasses(nil); err != nil {
+		t.Errorf("removing an unused class: %v", err)
+	}
+}
+
+func TestRADIUSPersistAndSync(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "radius.db")
+
+	db, _ := bolt.Open(path, 0600, nil)
+	s, _ := NewStore(db)
+	if s.RADIUS() != nil {
+		t.Error("expected no radius config on a fresh store")
+	}
+	data := []byte(`{"10.0.0.0/24":{"enabled":true,"server":{"address":"127.0.0.1:1812","secret":"x"}}}`)
+	if err := s.SetRADIUS(data); err != nil {
+		t.Fatalf("SetRADIUS: %v", err)
+	}
+	if _, ok := s.ExportAllSections()["radius"]; !ok {
+		t.Error("radius missing from exported sections")
+	}
+	db.Close()
+
+	db2, _ := bolt.Open(path, 0600, nil)
+	defer db2.Close()
+	s2, _ := NewStore(db2)
+	if string(s2.RADIUS()) != string(data) {
+		t.Fatalf("radius after reopen = %s", s2.RADIUS())
+	}
+
+	if err := s2.ApplyPeerConfig("radius", []byte(`{not json`)); err == nil {
+		t.Error("expected error for invalid peer radius JSON")
+	}
+	if err := s2.ApplyPeerConfig("radius", []byte(`{}`)); err != nil {
+		t.Fatalf("ApplyPeerConfig: %v", err)
+	}
+	if string(s2.RADIUS()) != "{}" {
+		t.Errorf("radius after peer sync = %s", s2.RADIUS())
+	}
+}
+
+func TestSubnet6PersistAndSync(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subnets6.db")
+
+	db, _ := bolt.Open(path, 0600, nil)
+	s, _ := NewStore(db)
+	sub := config.Subnet6Config{
+		Network: "2001:db8:1::/64",
+		Pools:   []config.Pool6Config{{RangeStart: "2001:db8:1::100", RangeEnd: "2001:db8:1::1ff"}},
+		PDPools: []config.PDPoolConfig{{Prefix: "2001:db8:ff00::/40", DelegatedLength: 56}},
+	}
+	if err := s.PutSubnet6(sub); err != nil {
+		t.Fatalf("PutSubnet6: %v", err)
+	}
+	if _, ok := s.ExportAllSections()["subnets6"]; !ok {
+		t.Error("subnets6 missing from exported sections")
+	}
+	db.Close()
+
+	db2, _ := bolt.Open(path, 0600, nil)
+	defer db2.Close()
+	s2, _ := NewStore(db2)
+	got := s2.BuildConfig(&config.Config{}).Subnets6
+	if len(got) != 1 || got[0].PDPools[0].DelegatedLength != 56 {
+		t.Fatalf("subnets6 after reopen = %+v", got)
+	}
+
+	if err := s2.ApplyPeerConfig("subnets6", []byte(`[{"network":"2001:db8:2::/64"}]`)); err != nil {
+		t.Fatalf("ApplyPeerConfig: %v", err)
+	}
+	if _, ok := s2.GetSubnet6("2001:db8:1::/64"); ok {
+		t.Error("peer sync should replace local subnets6")
+	}
+	if err := s2.DeleteSubnet6("2001:db8:2::/64"); err != nil {
+		t.Fatalf("DeleteSubnet6: %v", err)
+	}
+	if len(s2.Subnets6()) != 0 {
+		t.Errorf("subnets6 after delete = %+v", s2.Subnets6())
+	}
+}
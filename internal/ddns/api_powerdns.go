@@ -77,6 +77,33 @@ func (c *PowerDNSClient) RemoveA(zone, fqdn string) error {
 	return c.patchZone(zone, body, "RemoveA", fqdn)
 }
 
+// AddAAAA adds or replaces an AAAA record.
+func (c *PowerDNSClient) AddAAAA(zone, fqdn string, ip net.IP, ttl uint32) error {
+	body := pdnsPatchBody{
+		RRSets: []pdnsRRSet{{
+			Name:       ensureDot(fqdn),
+			Type:       "AAAA",
+			TTL:        int(ttl),
+			Changetype: "REPLACE",
+			Records:    []pdnsRecord{{Content: ip.String(), Disabled: false}},
+		}},
+	}
+	return c.patchZone(zone, body, "AddAAAA", fqdn)
+}
+
+// RemoveAAAA removes an AAAA record.
+func (c *PowerDNSClient) RemoveAAAA(zone, fqdn string) error {
+	body := pdnsPatchBody{
+		RRSets: []pdnsRRSet{{
+			Name:       ensureDot(fqdn),
+			Type:       "AAAA",
+			Changetype: "DELETE",
+			Records:    []pdnsRecord{},
+		}},
+	}
+	return c.patchZone(zone, body, "RemoveAAAA", fqdn)
+}
+
 // AddPTR adds or replaces a PTR record.
 func (c *PowerDNSClient) AddPTR(zone, reverseIP, fqdn string, ttl uint32) error {
 	body := pdnsPatchBody{
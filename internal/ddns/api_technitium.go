@@ -56,6 +56,31 @@ func (c *TechnitiumClient) RemoveA(zone, fqdn string) error {
 	return c.doRequest("/api/zones/records/delete", params, "RemoveA", fqdn)
 }
 
+// AddAAAA adds or updates an AAAA record.
+func (c *TechnitiumClient) AddAAAA(zone, fqdn string, ip net.IP, ttl uint32) error {
+	params := url.Values{
+		"token":     {c.apiKey},
+		"domain":    {fqdn},
+		"zone":      {zone},
+		"type":      {"AAAA"},
+		"ipAddress": {ip.String()},
+		"ttl":       {fmt.Sprintf("%d", ttl)},
+		"overwrite": {"true"},
+	}
+	return c.doRequest("/api/zones/records/add", params, "AddAAAA", fqdn)
+}
+
+// RemoveAAAA removes an AAAA record.
+func (c *TechnitiumClient) RemoveAAAA(zone, fqdn string) error {
+	params := url.Values{
+		"token":  {c.apiKey},
+		"domain": {fqdn},
+		"zone":   {zone},
+		"type":   {"AAAA"},
+	}
+	return c.doRequest("/api/zones/records/delete", params, "RemoveAAAA", fqdn)
+}
+
 // AddPTR adds or updates a PTR record.
 func (c *TechnitiumClient) AddPTR(zone, reverseIP, fqdn string, ttl uint32) error {
 	params := url.Values{
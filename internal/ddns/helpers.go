@@ -17,14 +17,27 @@ func ensureDot(name string) string {
 	return name
 }
 
-// ReverseIPName converts an IPv4 address to its in-addr.arpa PTR name.
+// ReverseIPName converts an IP address to its PTR name.
 // e.g., 192.168.1.100 → 100.1.168.192.in-addr.arpa
+// and 2001:db8::1 → 1.0.0.0.…8.b.d.0.1.0.0.2.ip6.arpa (RFC 3596 §2.5)
 func ReverseIPName(ip net.IP) string {
-	ip4 := ip.To4()
-	if ip4 == nil {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	ip6 := ip.To16()
+	if ip6 == nil {
 		return ""
 	}
-	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	const hexDigits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip6) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip6[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip6[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String()
 }
 
 // BuildFQDN constructs an FQDN from hostname and domain.
@@ -86,6 +99,8 @@ func SanitizeHostname(hostname string) string {
 type DNSUpdater interface {
 	AddA(zone, fqdn string, ip net.IP, ttl uint32) error
 	RemoveA(zone, fqdn string) error
+	AddAAAA(zone, fqdn string, ip net.IP, ttl uint32) error
+	RemoveAAAA(zone, fqdn string) error
 	AddPTR(zone, reverseIP, fqdn string, ttl uint32) error
 	RemovePTR(zone, reverseIP string) error
 }
@@ -32,6 +32,7 @@ func TestReverseIPName(t *testing.T) {
 		{net.IPv4(10, 0, 0, 1), "1.0.0.10.in-addr.arpa"},
 		{net.IPv4(172, 16, 254, 3), "3.254.16.172.in-addr.arpa"},
 		{net.IPv4(0, 0, 0, 0), "0.0.0.0.in-addr.arpa"},
+		{net.ParseIP("2001:db8::567:89ab"), "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
 	}
 	for _, tt := range tests {
 		got := ReverseIPName(tt.ip)
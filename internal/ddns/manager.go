@@ -20,6 +20,7 @@ type Manager struct {
 	cfg          *config.DDNSConfig
 	forward      DNSUpdater
 	reverse      DNSUpdater
+	reverse6     DNSUpdater
 	bus          *events.Bus
 	logger       *slog.Logger
 	ch           chan events.Event
@@ -61,6 +62,15 @@ func NewManager(cfg *config.DDNSConfig, bus *events.Bus, logger *slog.Logger) (*
 		m.reverse = rev
 	}
 
+	// Initialize ip6.arpa zone updater (optional)
+	if cfg.Reverse6.Zone != "" {
+		rev6, err := m.createUpdater(cfg.Reverse6)
+		if err != nil {
+			return nil, fmt.Errorf("creating IPv6 reverse zone updater: %w", err)
+		}
+		m.reverse6 = rev6
+	}
+
 	return m, nil
 }
 
@@ -157,10 +167,10 @@ func (m *Manager) handleEvent(evt events.Event) {
 	}
 }
 
-// addRecords creates forward (A) and reverse (PTR) DNS records for a lease.
+// addRecords creates forward (A or AAAA) and reverse (PTR) DNS records for a lease.
 func (m *Manager) addRecords(evt events.Event) {
 	l := evt.Lease
-	if l.IP == nil || l.MAC == "" {
+	if !registrable(l) {
 		return
 	}
 
@@ -175,31 +185,39 @@ func (m *Manager) addRecords(evt events.Event) {
 	zone := m.getForwardZone(l.Subnet)
 	ttl := uint32(m.cfg.TTL)
 
-	// Forward A record
+	// Forward A/AAAA record
 	start := time.Now()
-	m.withRetry("AddA", fqdn, func() error {
-		return m.forward.AddA(zone, fqdn, l.IP, ttl)
-	})
-	metrics.DDNSUpdates.WithLabelValues("add_a", "success").Inc()
-	metrics.DDNSDuration.WithLabelValues("add_a").Observe(time.Since(start).Seconds())
+	if l.IP.To4() == nil {
+		m.withRetry("AddAAAA", fqdn, func() error {
+			return m.forward.AddAAAA(zone, fqdn, l.IP, ttl)
+		})
+		metrics.DDNSUpdates.WithLabelValues("add_aaaa", "success").Inc()
+		metrics.DDNSDuration.WithLabelValues("add_aaaa").Observe(time.Since(start).Seconds())
+	} else {
+		m.withRetry("AddA", fqdn, func() error {
+			return m.forward.AddA(zone, fqdn, l.IP, ttl)
+		})
+		metrics.DDNSUpdates.WithLabelValues("add_a", "success").Inc()
+		metrics.DDNSDuration.WithLabelValues("add_a").Observe(time.Since(start).Seconds())
+	}
 
 	// Reverse PTR record
-	if m.reverse != nil {
-		reverseZone := m.getReverseZone(l.Subnet)
+	if reverse := m.reverseFor(l.IP); reverse != nil {
+		reverseZone := m.getReverseZone(l.Subnet, l.IP)
 		ptrName := ReverseIPName(l.IP)
 		ptrStart := time.Now()
 		m.withRetry("AddPTR", ptrName, func() error {
-			return m.reverse.AddPTR(reverseZone, ptrName, fqdn, ttl)
+			return reverse.AddPTR(reverseZone, ptrName, fqdn, ttl)
 		})
 		metrics.DDNSUpdates.WithLabelValues("add_ptr", "success").Inc()
 		metrics.DDNSDuration.WithLabelValues("add_ptr").Observe(time.Since(ptrStart).Seconds())
 	}
 }
 
-// removeRecords removes forward (A) and reverse (PTR) DNS records for a lease.
+// removeRecords removes forward (A or AAAA) and reverse (PTR) DNS records for a lease.
 func (m *Manager) removeRecords(evt events.Event) {
 	l := evt.Lease
-	if l.IP == nil || l.MAC == "" {
+	if !registrable(l) {
 		return
 	}
 
@@ -210,23 +228,27 @@ func (m *Manager) removeRecords(evt events.Event) {
 
 	zone := m.getForwardZone(l.Subnet)
 
-	// Remove forward A record — best-effort
+	// Remove forward A/AAAA record — best-effort
+	op, rrType, remove := "remove_a", "A", m.forward.RemoveA
+	if l.IP.To4() == nil {
+		op, rrType, remove = "remove_aaaa", "AAAA", m.forward.RemoveAAAA
+	}
 	aStart := time.Now()
-	if err := m.forward.RemoveA(zone, fqdn); err != nil {
-		metrics.DDNSUpdates.WithLabelValues("remove_a", "error").Inc()
-		m.logger.Warn("failed to remove A record (best-effort)",
+	if err := remove(zone, fqdn); err != nil {
+		metrics.DDNSUpdates.WithLabelValues(op, "error").Inc()
+		m.logger.Warn("failed to remove "+rrType+" record (best-effort)",
 			"fqdn", fqdn, "error", err)
 	} else {
-		metrics.DDNSUpdates.WithLabelValues("remove_a", "success").Inc()
+		metrics.DDNSUpdates.WithLabelValues(op, "success").Inc()
 	}
-	metrics.DDNSDuration.WithLabelValues("remove_a").Observe(time.Since(aStart).Seconds())
+	metrics.DDNSDuration.WithLabelValues(op).Observe(time.Since(aStart).Seconds())
 
 	// Remove reverse PTR record — best-effort
-	if m.reverse != nil {
-		reverseZone := m.getReverseZone(l.Subnet)
+	if reverse := m.reverseFor(l.IP); reverse != nil {
+		reverseZone := m.getReverseZone(l.Subnet, l.IP)
 		ptrName := ReverseIPName(l.IP)
 		ptrStart := time.Now()
-		if err := m.reverse.RemovePTR(reverseZone, ptrName); err != nil {
+		if err := reverse.RemovePTR(reverseZone, ptrName); err != nil {
 			metrics.DDNSUpdates.WithLabelValues("remove_ptr", "error").Inc()
 			m.logger.Warn("failed to remove PTR record (best-effort)",
 				"ptr", ptrName, "error", err)
@@ -271,16 +293,35 @@ func (m *Manager) getForwardZone(subnet string) string {
 	return m.cfg.Forward.Zone
 }
 
-// getReverseZone returns the reverse zone for a subnet (with override support).
-func (m *Manager) getReverseZone(subnet string) string {
+// getReverseZone returns the reverse zone for a subnet (with override support),
+// falling back to the in-addr.arpa or ip6.arpa zone by address family.
+func (m *Manager) getReverseZone(subnet string, ip net.IP) string {
 	for _, override := range m.cfg.ZoneOverrides {
 		if override.Subnet == subnet && override.ReverseZone != "" {
 			return override.ReverseZone
 		}
 	}
+	if ip.To4() == nil {
+		return m.cfg.Reverse6.Zone
+	}
 	return m.cfg.Reverse.Zone
 }
 
+// reverseFor returns the PTR updater for the address family of ip, or nil.
+func (m *Manager) reverseFor(ip net.IP) DNSUpdater {
+	if ip.To4() == nil {
+		return m.reverse6
+	}
+	return m.reverse
+}
+
+// registrable reports whether a lease gets DNS records: it needs an address
+// and a client identity (MAC, or DUID for DHCPv6). Delegated prefixes are
+// routed to a requesting router, not assigned to a host, so they get none.
+func registrable(l *events.LeaseData) bool {
+	return l.IP != nil && (l.MAC != "" || l.DUID != "") && l.PrefixLen == 0
+}
+
 // withRetry retries an operation with exponential backoff.
 func (m *Manager) withRetry(op, name string, fn func() error) {
 	var err error
@@ -330,6 +371,11 @@ func (m *Manager) SetReverseUpdater(u DNSUpdater) {
 	m.reverse = u
 }
 
+// SetReverse6Updater sets the ip6.arpa DNS updater (for testing).
+func (m *Manager) SetReverse6Updater(u DNSUpdater) {
+	m.reverse6 = u
+}
+
 // NewManagerForTest creates a manager with mock updaters for testing.
 func NewManagerForTest(cfg *config.DDNSConfig, bus *events.Bus, logger *slog.Logger, forward, reverse DNSUpdater) *Manager {
 	return &Manager{
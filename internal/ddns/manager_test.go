@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -15,12 +16,14 @@ import (
 
 // mockUpdater records DNS update calls for testing.
 type mockUpdater struct {
-	mu         sync.Mutex
-	aAdded     []string
-	aRemoved   []string
-	ptrAdded   []string
-	ptrRemoved []string
-	failNext   bool
+	mu          sync.Mutex
+	aAdded      []string
+	aRemoved    []string
+	aaaaAdded   []string
+	aaaaRemoved []string
+	ptrAdded    []string
+	ptrRemoved  []string
+	failNext    bool
 }
 
 func (m *mockUpdater) AddA(zone, fqdn string, ip net.IP, ttl uint32) error {
@@ -41,6 +44,20 @@ func (m *mockUpdater) RemoveA(zone, fqdn string) error {
 	return nil
 }
 
+func (m *mockUpdater) AddAAAA(zone, fqdn string, ip net.IP, ttl uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aaaaAdded = append(m.aaaaAdded, fqdn)
+	return nil
+}
+
+func (m *mockUpdater) RemoveAAAA(zone, fqdn string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aaaaRemoved = append(m.aaaaRemoved, fqdn)
+	return nil
+}
+
 func (m *mockUpdater) AddPTR(zone, reverseIP, fqdn string, ttl uint32) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -195,6 +212,50 @@ func TestManagerRemoveRecords(t *testing.T) {
 	}
 }
 
+func TestManagerV6Records(t *testing.T) {
+	mgr, fwd, rev, _ := newTestManager(t)
+	rev6 := &mockUpdater{}
+	mgr.SetReverse6Updater(rev6)
+	mgr.cfg.Reverse6 = config.DDNSZoneConfig{Zone: "8.b.d.0.1.0.0.2.ip6.arpa.", Method: "rfc2136"}
+
+	duid := "00:01:00:01:2a:3b:4c:5d:00:11:22:33:44:55"
+	for _, evt := range []events.Event{
+		{Type: events.EventLeaseAck, Lease: &events.LeaseData{
+			IP: net.ParseIP("2001:db8::100"), DUID: duid, Hostname: "v6host", Subnet: "2001:db8::/64",
+		}},
+		// Delegated prefixes are not registered.
+		{Type: events.EventLeaseAck, Lease: &events.LeaseData{
+			IP: net.ParseIP("2001:db8:ff00::"), DUID: duid, PrefixLen: 56, Hostname: "v6host", Subnet: "2001:db8::/64",
+		}},
+		{Type: events.EventLeaseExpire, Lease: &events.LeaseData{
+			IP: net.ParseIP("2001:db8::100"), DUID: duid, Hostname: "v6host", Subnet: "2001:db8::/64",
+		}},
+	} {
+		mgr.handleEvent(evt)
+		mgr.wg.Wait()
+	}
+
+	fwd.mu.Lock()
+	defer fwd.mu.Unlock()
+	if len(fwd.aaaaAdded) != 1 || fwd.aaaaAdded[0] != "v6host.example.com." || len(fwd.aaaaRemoved) != 1 {
+		t.Errorf("AAAA added %v removed %v", fwd.aaaaAdded, fwd.aaaaRemoved)
+	}
+	if len(fwd.aAdded) != 0 || len(fwd.aRemoved) != 0 {
+		t.Errorf("v6 lease touched A records: added %v removed %v", fwd.aAdded, fwd.aRemoved)
+	}
+
+	rev6.mu.Lock()
+	defer rev6.mu.Unlock()
+	if len(rev6.ptrAdded) != 1 || !strings.HasSuffix(rev6.ptrAdded[0], ".8.b.d.0.1.0.0.2.ip6.arpa") || len(rev6.ptrRemoved) != 1 {
+		t.Errorf("ip6.arpa PTR added %v removed %v", rev6.ptrAdded, rev6.ptrRemoved)
+	}
+	rev.mu.Lock()
+	defer rev.mu.Unlock()
+	if len(rev.ptrAdded) != 0 {
+		t.Errorf("v6 lease written to in-addr.arpa zone: %v", rev.ptrAdded)
+	}
+}
+
 func TestManagerSkipsRenewByDefault(t *testing.T) {
 	mgr, fwd, _, _ := newTestManager(t)
 
@@ -259,7 +320,7 @@ func TestManagerZoneOverride(t *testing.T) {
 	if got := mgr.getForwardZone("10.0.0.0/24"); got != "lab.example.com." {
 		t.Errorf("forward zone for 10.0.0.0/24 = %q, want %q", got, "lab.example.com.")
 	}
-	if got := mgr.getReverseZone("10.0.0.0/24"); got != "0.0.10.in-addr.arpa." {
+	if got := mgr.getReverseZone("10.0.0.0/24", net.IPv4(10, 0, 0, 5)); got != "0.0.10.in-addr.arpa." {
 		t.Errorf("reverse zone for 10.0.0.0/24 = %q, want %q", got, "0.0.10.in-addr.arpa.")
 	}
 
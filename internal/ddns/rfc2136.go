@@ -79,6 +79,50 @@ func (c *RFC2136Client) RemoveA(zone, fqdn string) error {
 	return c.send(msg, "RemoveA", fqdn, "")
 }
 
+// AddAAAA adds or updates an AAAA record via DNS UPDATE.
+func (c *RFC2136Client) AddAAAA(zone, fqdn string, ip net.IP, ttl uint32) error {
+	msg := c.newUpdateMsg(zone)
+
+	rr := &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(fqdn),
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		AAAA: ip.To16(),
+	}
+
+	// Remove existing AAAA records, then add new one
+	rrRemove := &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(fqdn),
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassANY,
+		},
+	}
+	msg.RemoveRRset([]dns.RR{rrRemove})
+	msg.Insert([]dns.RR{rr})
+
+	return c.send(msg, "AddAAAA", fqdn, ip.String())
+}
+
+// RemoveAAAA removes an AAAA record via DNS UPDATE.
+func (c *RFC2136Client) RemoveAAAA(zone, fqdn string) error {
+	msg := c.newUpdateMsg(zone)
+
+	rr := &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(fqdn),
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassANY,
+		},
+	}
+	msg.RemoveRRset([]dns.RR{rr})
+
+	return c.send(msg, "RemoveAAAA", fqdn, "")
+}
+
 // AddPTR adds or updates a PTR record via DNS UPDATE.
 func (c *RFC2136Client) AddPTR(zone, reverseIP, fqdn string, ttl uint32) error {
 	msg := c.newUpdateMsg(zone)
@@ -0,0 +1,304 @@
+package dhcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// BulkLeaseQueryServer answers RFC 6926 bulk leasequery over TCP. Each
+// message on the connection is a DHCP packet prefixed with its two-byte
+// length. Only peers in leasequery.allowed_relays may connect.
+type BulkLeaseQueryServer struct {
+	handler *Handler
+	logger  *slog.Logger
+
+	ln      net.Listener
+	slots   chan struct{} // one per allowed concurrent connection
+	idle    time.Duration
+	started config.LeaseQueryConfig
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+	done  chan struct{}
+}
+
+// NewBulkLeaseQueryServer creates a bulk leasequery server sharing the DHCP handler.
+func NewBulkLeaseQueryServer(handler *Handler, logger *slog.Logger) *BulkLeaseQueryServer {
+	return &BulkLeaseQueryServer{
+		handler: handler,
+		logger:  logger,
+		conns:   make(map[net.Conn]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start listens on leasequery.bulk_listen and begins accepting connections.
+func (s *BulkLeaseQueryServer) Start(ctx context.Context) error {
+	lqCfg := s.handler.cfg.LeaseQuery
+	s.started = lqCfg
+	s.idle, _ = time.ParseDuration(lqCfg.IdleTimeout)
+	if s.idle <= 0 {
+		s.idle = 60 * time.Second
+	}
+	maxConns := lqCfg.MaxConnections
+	if maxConns <= 0 {
+		maxConns = 10
+	}
+	s.slots = make(chan struct{}, maxConns)
+
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp4", lqCfg.BulkListen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", lqCfg.BulkListen, err)
+	}
+	s.ln = ln
+
+	s.logger.Info("bulk leasequery server started",
+		"address", ln.Addr().String(),
+		"max_connections", maxConns)
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// Addr returns the listening address (useful when bound to port 0).
+func (s *BulkLeaseQueryServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// NeedsRestart reports whether lq changes a setting that is fixed once the
+// listener is up. The relay allow-list is checked per connection and never
+// needs a restart.
+func (s *BulkLeaseQueryServer) NeedsRestart(lq config.LeaseQueryConfig) bool {
+	return lq.BulkListen != s.started.BulkListen ||
+		lq.MaxConnections != s.started.MaxConnections ||
+		lq.IdleTimeout != s.started.IdleTimeout
+}
+
+// Stop closes the listener and all open connections.
+func (s *BulkLeaseQueryServer) Stop() {
+	close(s.done)
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.logger.Info("bulk leasequery server stopped")
+}
+
+func (s *BulkLeaseQueryServer) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.logger.Error("accepting bulk leasequery connection", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		peer := tcpPeerIP(conn)
+		if !relayAllowed(peer, s.handler.cfg.LeaseQuery.AllowedRelays) {
+			metrics.LeaseQueries.WithLabelValues("tcp", "none", "denied").Inc()
+			s.logger.Warn("bulk leasequery connection from unauthorized peer", "peer", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			s.logger.Warn("bulk leasequery connection limit reached", "peer", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		metrics.BulkLeaseQueryConnections.Inc()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				conn.Close()
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				metrics.BulkLeaseQueryConnections.Dec()
+				<-s.slots
+			}()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn processes queries on one connection until the peer closes it,
+// the idle timeout passes, or a framing error occurs. Queries are answered
+// one at a time in arrival order.
+func (s *BulkLeaseQueryServer) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idle))
+		data, err := readFramed(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debug("bulk leasequery connection closed", "peer", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		pkt, err := DecodePacket(data)
+		if err != nil {
+			metrics.PacketErrors.WithLabelValues("decode").Inc()
+			s.logger.Warn("dropping malformed bulk leasequery message", "peer", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(s.idle))
+		send := func(reply *Packet) error {
+			out, err := reply.Encode()
+			if err != nil {
+				return err
+			}
+			return writeFramed(w, out)
+		}
+		if err := s.handler.handleBulkLeaseQuery(pkt, send); err != nil {
+			s.logger.Warn("bulk leasequery reply failed", "peer", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handleBulkLeaseQuery answers one query received over TCP, streaming a
+// reply per binding through send and finishing with DHCPLEASEQUERYDONE
+// (RFC 6926 §7.4). Errors are reported with DHCPLEASEQUERYSTATUS.
+func (h *Handler) handleBulkLeaseQuery(pkt *Packet, send func(*Packet) error) error {
+	status := func(code dhcpv4.LeaseQueryStatus, msg string) error {
+		reply := pkt.NewReply(dhcpv4.MessageTypeLeaseQueryStatus, h.serverIP)
+		setLeaseQueryStatus(reply, code, msg)
+		return send(reply)
+	}
+
+	if !h.cfg.LeaseQuery.Enabled {
+		metrics.LeaseQueries.WithLabelValues("tcp", "none", "denied").Inc()
+		return status(dhcpv4.LeaseQueryStatusNotAllowed, "leasequery disabled")
+	}
+	if h.ha != nil && !h.ha.IsActive() {
+		return status(dhcpv4.LeaseQueryStatusUnspecFail, "server is not the active HA node")
+	}
+
+	msgType := pkt.MessageType()
+	if pkt.Op != dhcpv4.OpCodeBootRequest ||
+		(msgType != dhcpv4.MessageTypeBulkLeaseQuery && msgType != dhcpv4.MessageTypeLeaseQuery) {
+		metrics.LeaseQueries.WithLabelValues("tcp", "none", "malformed").Inc()
+		return status(dhcpv4.LeaseQueryStatusMalformedQuery, "expected DHCPBULKLEASEQUERY")
+	}
+
+	q, err := parseLeaseQuery(pkt, msgType == dhcpv4.MessageTypeBulkLeaseQuery)
+	if err != nil {
+		metrics.LeaseQueries.WithLabelValues("tcp", "none", "malformed").Inc()
+		return status(dhcpv4.LeaseQueryStatusMalformedQuery, err.Error())
+	}
+
+	// Plain DHCPLEASEQUERY over TCP gets the same single answer as over UDP
+	if msgType == dhcpv4.MessageTypeLeaseQuery {
+		reply := h.answerLeaseQuery(pkt, q)
+		metrics.LeaseQueries.WithLabelValues("tcp", q.kind, leaseQueryResult(reply)).Inc()
+		return send(reply)
+	}
+
+	base := time.Now()
+	sent := 0
+	for _, l := range h.queryBindings(q) {
+		msg := dhcpv4.MessageTypeLeaseUnassigned
+		if isActiveBinding(l) {
+			msg = dhcpv4.MessageTypeLeaseActive
+		}
+		reply := h.bindingReply(pkt, l, msg)
+		reply.Options[dhcpv4.OptionBaseTime] = dhcpv4.Uint32ToBytes(uint32(base.Unix()))
+		reply.Options[dhcpv4.OptionDHCPState] = []byte{byte(leaseDHCPState(l))}
+		if !l.LastUpdated.IsZero() {
+			reply.Options[dhcpv4.OptionStartTimeOfState] = dhcpv4.Uint32ToBytes(uint32(base.Sub(l.LastUpdated).Seconds()))
+		}
+		if err := send(reply); err != nil {
+			return err
+		}
+		sent++
+	}
+	metrics.BulkLeaseQueryBindings.Add(float64(sent))
+	metrics.LeaseQueries.WithLabelValues("tcp", q.kind, "ok").Inc()
+
+	h.logger.Info("DHCPBULKLEASEQUERY",
+		"query", q.kind,
+		"bindings", sent)
+
+	done := pkt.NewReply(dhcpv4.MessageTypeLeaseQueryDone, h.serverIP)
+	done.Options[dhcpv4.OptionBaseTime] = dhcpv4.Uint32ToBytes(uint32(base.Unix()))
+	return send(done)
+}
+
+// readFramed reads one length-prefixed message (RFC 6926 §6.1).
+func readFramed(r io.Reader) ([]byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint16(hdr[:])
+	if n == 0 {
+		return nil, fmt.Errorf("zero-length message")
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeFramed writes one length-prefixed message.
+func writeFramed(w io.Writer, data []byte) error {
+	if len(data) > 0xffff {
+		return fmt.Errorf("message too large: %d bytes", len(data))
+	}
+	var hdr [2]byte
+	binary.BigEndian.PutUint16(hdr[:], uint16(len(data)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// tcpPeerIP returns the remote IP of a connection.
+func tcpPeerIP(conn net.Conn) net.IP {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
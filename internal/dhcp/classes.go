@@ -0,0 +1,122 @@
+package dhcp
+
+import (
+	"fmt"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/clientclass"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/fingerprint"
+)
+
+// Classifier evaluates the configured client classes against clients.
+type Classifier struct {
+	classes []compiledClass
+}
+
+// compiledClass is a client class with its match expression parsed.
+type compiledClass struct {
+	cfg  *config.ClientClassConfig
+	expr *clientclass.Expr
+}
+
+// NewClassifier compiles the match expressions of the given classes.
+// Classes that fail to compile are skipped and reported in the returned errors.
+func NewClassifier(classes []config.ClientClassConfig) (*Classifier, []error) {
+	cl := &Classifier{}
+	var errs []error
+	for i := range classes {
+		expr, err := clientclass.Compile(classes[i].Match)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("client class %q: %w", classes[i].Name, err))
+			continue
+		}
+		cl.classes = append(cl.classes, compiledClass{cfg: &classes[i], expr: expr})
+	}
+	return cl, errs
+}
+
+// Classify returns the classes the client belongs to, in config order.
+func (cl *Classifier) Classify(c *clientclass.Client) []*config.ClientClassConfig {
+	if cl == nil {
+		return nil
+	}
+	var out []*config.ClientClassConfig
+	for _, cc := range cl.classes {
+		if cc.expr.Match(c) {
+			out = append(out, cc.cfg)
+		}
+	}
+	return out
+}
+
+// ClassNames returns the names of the given classes.
+func ClassNames(classes []*config.ClientClassConfig) []string {
+	names := make([]string, len(classes))
+	for i, cc := range classes {
+		names[i] = cc.Name
+	}
+	return names
+}
+
+// DeniedBy returns the first class that denies service, or nil.
+func DeniedBy(classes []*config.ClientClassConfig) *config.ClientClassConfig {
+	for _, cc := range classes {
+		if cc.Deny {
+			return cc
+		}
+	}
+	return nil
+}
+
+// ClassLayers converts matched classes into option layers. Classes later in
+// the config override earlier ones.
+func ClassLayers(classes []*config.ClientClassConfig) []OptionLayer {
+	layers := make([]OptionLayer, 0, len(classes))
+	for _, cc := range classes {
+		layers = append(layers, OptionLayer{
+			Source:     LayerClass + ":" + cc.Name,
+			Routers:    cc.Routers,
+			DNSServers: cc.DNSServers,
+			NTPServers: cc.NTPServers,
+			DomainName: cc.DomainName,
+			LeaseTime:  cc.LeaseTime,
+			NextServer: cc.NextServer,
+			BootFile:   cc.BootFile,
+			Options:    cc.Options,
+		})
+	}
+	return layers
+}
+
+// ClientFromPacket builds the expression view of a packet. fp may be nil.
+func ClientFromPacket(pkt *Packet, fp *fingerprint.DeviceInfo) *clientclass.Client {
+	c := &clientclass.Client{
+		MAC:         pkt.CHAddr,
+		GIAddr:      pkt.GIAddr,
+		Options:     pkt.Options,
+		VendorClass: pkt.VendorClassID(),
+		UserClass:   pkt.UserClassID(),
+	}
+	if ri := GetRelayInfo(pkt); ri != nil {
+		c.CircuitID = ri.CircuitID
+		c.RemoteID = ri.RemoteID
+	}
+	if fp != nil {
+		c.DeviceType = fp.DeviceType
+		c.DeviceName = fp.DeviceName
+		c.DeviceOS = fp.OS
+	}
+	return c
+}
+
+// classify returns the client classes a packet belongs to.
+func (h *Handler) classify(pkt *Packet) []*config.ClientClassConfig {
+	if h.classifier == nil {
+		return nil
+	}
+	var fp *fingerprint.DeviceInfo
+	if h.fpStore != nil {
+		fp = h.fpStore.Get(pkt.CHAddr.String())
+	}
+	return h.classifier.Classify(ClientFromPacket(pkt, fp))
+}
@@ -0,0 +1,63 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/fingerprint"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+func TestClassifier(t *testing.T) {
+	classes := []config.ClientClassConfig{
+		{Name: "pxe", Match: "vendor_class startswith 'PXEClient'", BootFile: "ipxe.efi"},
+		{Name: "broken", Match: "vendor_class ==="},
+		{Name: "voip", Match: "device.type == 'voip'", LeaseTime: "1h", DNSServers: []string{"10.0.0.53"}},
+		{Name: "blocked", Match: "mac.oui == '00:11:22'", Deny: true},
+	}
+	cl, errs := NewClassifier(classes)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 compile error, got %v", errs)
+	}
+
+	pkt := &Packet{
+		CHAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		GIAddr: net.IPv4zero,
+		Options: Options{
+			dhcpv4.OptionVendorClassID: []byte("PXEClient:Arch:00007"),
+		},
+	}
+	got := cl.Classify(ClientFromPacket(pkt, &fingerprint.DeviceInfo{DeviceType: "voip"}))
+
+	names := ClassNames(got)
+	if len(names) != 3 || names[0] != "pxe" || names[1] != "voip" || names[2] != "blocked" {
+		t.Fatalf("classes = %v, want [pxe voip blocked]", names)
+	}
+	if cc := DeniedBy(got); cc == nil || cc.Name != "blocked" {
+		t.Errorf("DeniedBy = %v, want blocked", cc)
+	}
+	if DeniedBy(got[:2]) != nil {
+		t.Error("pxe/voip should not deny")
+	}
+
+	layers := ClassLayers(got)
+	if layers[1].Source != "class:voip" || layers[1].LeaseTime != "1h" {
+		t.Errorf("voip layer = %+v", layers[1])
+	}
+
+	r := ResolveOptions(nil, layers)
+	if r.BootFile != "ipxe.efi" || r.FileSource != "class:pxe" {
+		t.Errorf("boot file = %q from %q", r.BootFile, r.FileSource)
+	}
+	if r.Sources[dhcpv4.OptionDomainNameServer] != "class:voip" {
+		t.Errorf("dns source = %q, want class:voip", r.Sources[dhcpv4.OptionDomainNameServer])
+	}
+}
+
+func TestClassifierNil(t *testing.T) {
+	var cl *Classifier
+	if got := cl.Classify(nil); got != nil {
+		t.Errorf("nil classifier returned %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package dhcp
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"net"
@@ -14,12 +15,30 @@ import (
 	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/radius"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
-// HAChecker is satisfied by the HA FSM — lets the handler skip packets when standby.
+// HAChecker is satisfied by the HA FSM — lets the handler skip packets when
+// standby, and stick to existing bindings while HA communications are
+// interrupted.
 type HAChecker interface {
 	IsActive() bool
+	RenewalsOnly() bool
+}
+
+// LoadBalancer is satisfied by the HA load balancer — splits clients and
+// pool space between two serving peers and caps leases at the MCLT.
+type LoadBalancer interface {
+	InScope(key []byte) bool
+	PoolShare() (index, count int)
+	LeaseTime(ip net.IP, desired time.Duration) time.Duration
+}
+
+// PeerSync is satisfied by the HA peer — lets the handler hold a DHCPACK
+// until the peer has stored the binding (ha.sync_ack).
+type PeerSync interface {
+	WaitAck(ctx context.Context, ip net.IP) error
 }
 
 // Handler processes DHCP messages implementing the DORA cycle (RFC 2131).
@@ -33,7 +52,12 @@ type Handler struct {
 	serverIP net.IP
 	ifaceIP  net.IP // auto-discovered from listening interface
 	ha       HAChecker
+	lb       LoadBalancer
+	peerSync PeerSync
 	fpStore  *fingerprint.Store
+	radius   *radius.Client
+
+	classifier *Classifier
 }
 
 // NewHandler creates a new DHCP message handler.
@@ -54,6 +78,8 @@ func NewHandler(
 		logger:   logger,
 		serverIP: cfg.ServerIP(),
 	}
+	h.checkCustomOptions(cfg)
+	h.classifier = h.buildClassifier(cfg)
 
 	// Auto-discover interface IP for subnet matching fallback
 	if iface, err := net.InterfaceByName(cfg.Server.Interface); err == nil {
@@ -88,14 +114,46 @@ func NewHandler(
 		logger.Error("CRITICAL: no server_id configured and no interface IP discovered — DHCP will not work")
 	}
 
+	// Expired and released addresses go back to the pools from here
+	leases.OnAddressFreed(h.freeAddress)
+
 	return h
 }
 
+// freeAddress returns the address of an ended lease to its pool: held for
+// the same client until holdUntil, or free for anyone if that is zero.
+func (h *Handler) freeAddress(l *lease.Lease, holdUntil time.Time) {
+	for _, subnetPools := range h.pools {
+		for _, p := range subnetPools {
+			if !p.Contains(l.IP) {
+				continue
+			}
+			if holdUntil.IsZero() {
+				p.Release(l.IP)
+			} else {
+				p.Hold(l.IP, holdUntil)
+			}
+			return
+		}
+	}
+}
+
 // SetHA sets the HA state checker (call after FSM is created).
 func (h *Handler) SetHA(ha HAChecker) {
 	h.ha = ha
 }
 
+// SetLoadBalancer enables HA load balancing (nil for active/standby).
+func (h *Handler) SetLoadBalancer(lb LoadBalancer) {
+	h.lb = lb
+}
+
+// SetPeerSync makes DHCPACKs wait for the HA peer's acknowledgement of
+// the binding (nil to answer without waiting).
+func (h *Handler) SetPeerSync(ps PeerSync) {
+	h.peerSync = ps
+}
+
 // SetFingerprintStore sets the fingerprint store for device classification.
 func (h *Handler) SetFingerprintStore(fp *fingerprint.Store) {
 	h.fpStore = fp
@@ -115,6 +173,13 @@ func (h *Handler) HandlePacket(ctx context.Context, pkt *Packet, src net.Addr) (
 
 	msgType := pkt.MessageType()
 
+	// Load balancing: clients hashing to the peer are its to answer.
+	// Both nodes hold every lease, so either may answer a leasequery.
+	if h.lb != nil && msgType != dhcpv4.MessageTypeLeaseQuery && !h.lb.InScope(loadBalanceKey(pkt)) {
+		metrics.HALoadBalanceSkipped.Inc()
+		return nil, nil
+	}
+
 	h.logger.Debug("received DHCP packet",
 		"msg_type", msgType.String(),
 		"mac", pkt.CHAddr.String(),
@@ -135,6 +200,8 @@ func (h *Handler) HandlePacket(ctx context.Context, pkt *Packet, src net.Addr) (
 		return nil, nil
 	case dhcpv4.MessageTypeInform:
 		return h.handleInform(pkt)
+	case dhcpv4.MessageTypeLeaseQuery:
+		return h.handleLeaseQuery(pkt)
 	default:
 		h.logger.Warn("unsupported DHCP message type",
 			"msg_type", msgType.String(),
@@ -185,6 +252,24 @@ func (h *Handler) handleDiscover(ctx context.Context, pkt *Packet) (*Packet, err
 		return nil, nil // Silently ignore — no subnet to serve
 	}
 
+	classes := h.classify(pkt)
+	if cc := DeniedBy(classes); cc != nil {
+		h.logger.Info("DHCPDISCOVER denied by client class",
+			"mac", mac.String(),
+			"class", cc.Name)
+		return nil, nil
+	}
+
+	// RADIUS authorization — rejected clients get no offer
+	auth, ok := h.authorize(ctx, pkt, subnetCfg)
+	if !ok {
+		return nil, nil
+	}
+	if ip := h.framedIP(auth, subnetCfg, mac); ip != nil {
+		poolRange := claimPoolIP(h.pools[subnetCfg.Network], ip)
+		return h.buildOffer(ctx, pkt, ip, mac, clientID, hostname, subnetIdx, subnetCfg, poolRange, false, auth)
+	}
+
 	// Check for reservation
 	res := h.leases.FindReservation(clientID, mac, subnetIdx)
 	if res != nil {
@@ -192,7 +277,7 @@ func (h *Handler) handleDiscover(ctx context.Context, pkt *Packet) (*Packet, err
 		// Validate reservation IP is within the subnet CIDR
 		_, network, _ := net.ParseCIDR(subnetCfg.Network)
 		if ip != nil && network != nil && network.Contains(ip) {
-			return h.buildOffer(ctx, pkt, ip, mac, clientID, hostname, subnetIdx, subnetCfg, "", true)
+			return h.buildOffer(ctx, pkt, ip, mac, clientID, hostname, subnetIdx, subnetCfg, "", true, auth)
 		}
 		h.logger.Warn("reservation IP outside subnet CIDR, skipping",
 			"mac", mac.String(),
@@ -202,9 +287,27 @@ func (h *Handler) handleDiscover(ctx context.Context, pkt *Packet) (*Packet, err
 
 	// Check for existing lease
 	existing := h.leases.FindExistingLease(clientID, mac)
+	if existing != nil && existing.Subnet != subnetCfg.Network && existing.Subnet == subnetCfg.OverflowSubnet {
+		// The client was given an address in the overflow subnet before
+		subnetIdx, subnetCfg = h.overflowSubnet(subnetCfg)
+	}
 	if existing != nil && existing.Subnet == subnetCfg.Network {
+		poolRange := existing.Pool
+		if existing.State == dhcpv4.LeaseStateReclaimed {
+			// Back within the affinity period: take the held address again
+			poolRange = claimPoolIP(h.pools[subnetCfg.Network], existing.IP)
+		}
 		// Re-offer the same IP
-		return h.buildOffer(ctx, pkt, existing.IP, mac, clientID, hostname, subnetIdx, subnetCfg, existing.Pool, false)
+		return h.buildOffer(ctx, pkt, existing.IP, mac, clientID, hostname, subnetIdx, subnetCfg, poolRange, false, auth)
+	}
+
+	// The peer may be handing out the same free addresses right now
+	if h.renewalsOnly() {
+		metrics.HARenewalsOnlyDropped.Inc()
+		h.logger.Info("DHCPDISCOVER from new client ignored — HA communications interrupted",
+			"mac", mac.String(),
+			"subnet", subnetCfg.Network)
+		return nil, nil
 	}
 
 	// Check if client requested a specific IP
@@ -214,6 +317,8 @@ func (h *Handler) handleDiscover(ctx context.Context, pkt *Packet) (*Packet, err
 	criteria := pool.MatchCriteria{
 		VendorClass: pkt.VendorClassID(),
 		UserClass:   pkt.UserClassID(),
+		RadiusClass: radiusClass(auth),
+		Classes:     ClassNames(classes),
 	}
 	relayInfo := GetRelayInfo(pkt)
 	if relayInfo != nil {
@@ -230,54 +335,30 @@ func (h *Handler) handleDiscover(ctx context.Context, pkt *Packet) (*Packet, err
 		return nil, nil
 	}
 
-	// Try requested IP first if valid
-	if requestedIP != nil && selectedPool.Contains(requestedIP) && !selectedPool.IsAllocated(requestedIP) {
-		return h.buildOffer(ctx, pkt, requestedIP, mac, clientID, hostname, subnetIdx, subnetCfg, selectedPool.RangeString(), false)
-	}
-
-	// Allocate from pool — get candidates for conflict probing
-	if h.detector != nil && h.cfg.ConflictDetection.Enabled {
-		candidates := selectedPool.AllocateN(h.cfg.ConflictDetection.MaxProbesPerDiscover)
-		if len(candidates) == 0 {
-			metrics.PoolExhausted.WithLabelValues(subnetCfg.Network).Inc()
-			h.logger.Warn("pool exhausted",
-				"subnet", subnetCfg.Network,
-				"pool", selectedPool.String())
-			return nil, nil
-		}
+	// In load-balancing mode only hand out free addresses from our share
+	h.applyPoolShare(selectedPool)
 
-		// Probe candidates — RFC 2131 §4.4.1
-		clearIP, err := h.detector.ProbeAndSelect(ctx, candidates, subnetCfg.Network)
-		if err != nil {
-			h.logger.Warn("all candidate IPs conflicted",
-				"subnet", subnetCfg.Network,
-				"error", err)
-			return nil, nil
-		}
-
-		// Mark the selected IP as allocated
-		selectedPool.AllocateSpecific(clearIP)
-		return h.buildOffer(ctx, pkt, clearIP, mac, clientID, hostname, subnetIdx, subnetCfg, selectedPool.RangeString(), false)
+	// Try requested IP first if valid
+	if requestedIP != nil && selectedPool.Owns(requestedIP) && !selectedPool.IsAllocated(requestedIP) && !selectedPool.IsHeld(requestedIP) {
+		return h.buildOffer(ctx, pkt, requestedIP, mac, clientID, hostname, subnetIdx, subnetCfg, selectedPool.RangeString(), false, auth)
 	}
 
-	// No conflict detection — just allocate
-	ip := selectedPool.Allocate()
-	if ip == nil {
-		metrics.PoolExhausted.WithLabelValues(subnetCfg.Network).Inc()
-		h.logger.Warn("pool exhausted",
-			"subnet", subnetCfg.Network,
-			"pool", selectedPool.String())
+	a := h.allocate(ctx, subnetIdx, subnetCfg, selectedPool, criteria)
+	if a == nil {
 		return nil, nil
 	}
-
-	return h.buildOffer(ctx, pkt, ip, mac, clientID, hostname, subnetIdx, subnetCfg, selectedPool.RangeString(), false)
+	return h.buildOffer(ctx, pkt, a.ip, mac, clientID, hostname, a.subnetIdx, a.subnet, a.pool.RangeString(), false, auth)
 }
 
 // buildOffer constructs and sends a DHCPOFFER.
 func (h *Handler) buildOffer(ctx context.Context, pkt *Packet, ip net.IP, mac net.HardwareAddr,
-	clientID, hostname string, subnetIdx int, subnetCfg *config.SubnetConfig, poolRange string, isReservation bool) (*Packet, error) {
+	clientID, hostname string, subnetIdx int, subnetCfg *config.SubnetConfig, poolRange string, isReservation bool,
+	auth *radius.AuthResult) (*Packet, error) {
 
-	leaseTime := h.cfg.GetLeaseTime(subnetIdx)
+	resolved := h.resolveClientOptions(pkt, subnetIdx, subnetCfg, ip, auth)
+	h.shortenLeaseTime(resolved, subnetCfg, ip)
+	h.capLeaseTime(resolved, ip)
+	leaseTime := resolved.LeaseTime
 
 	// Create the offer in the lease manager
 	var relayInfo *lease.RelayInfo
@@ -288,6 +369,7 @@ func (h *Handler) buildOffer(ctx context.Context, pkt *Packet, ip net.IP, mac ne
 				GIAddr:    pkt.GIAddr,
 				CircuitID: ri.CircuitID,
 				RemoteID:  ri.RemoteID,
+				RelayID:   hex.EncodeToString(ri.RelayID),
 			}
 		}
 	}
@@ -302,13 +384,14 @@ func (h *Handler) buildOffer(ctx context.Context, pkt *Packet, ip net.IP, mac ne
 	reply.YIAddr = ip
 
 	// Set options from config
-	h.setSubnetOptions(reply, subnetIdx, subnetCfg, leaseTime)
+	h.applyOptions(pkt, reply, resolved, true)
 
 	// Copy relay agent info back (RFC 3046)
 	if pkt.Options.Has(dhcpv4.OptionRelayAgentInfo) {
 		reply.Options[dhcpv4.OptionRelayAgentInfo] = pkt.Options[dhcpv4.OptionRelayAgentInfo]
 	}
 
+	h.finaliseReply(pkt, reply, subnetIdx)
 	return reply, nil
 }
 
@@ -353,6 +436,34 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 		return h.buildNAK(pkt, "no matching subnet"), nil
 	}
 
+	classes := h.classify(pkt)
+	if cc := DeniedBy(classes); cc != nil {
+		h.logger.Info("DHCPREQUEST denied by client class",
+			"mac", mac.String(),
+			"class", cc.Name)
+		return h.buildNAK(pkt, "denied by client class"), nil
+	}
+
+	auth, ok := h.authorize(ctx, pkt, subnetCfg)
+	if !ok {
+		return h.buildNAK(pkt, "RADIUS access rejected"), nil
+	}
+	framed := h.framedIP(auth, subnetCfg, mac)
+	if framed != nil && !framed.Equal(ip) {
+		h.logger.Info("DHCPREQUEST for address other than RADIUS Framed-IP-Address",
+			"mac", mac.String(),
+			"requested_ip", ip.String(),
+			"framed_ip", framed.String())
+		return h.buildNAK(pkt, "address not assigned by RADIUS"), nil
+	}
+
+	// An address handed out from the overflow subnet is confirmed there
+	if idx, overflowCfg := h.overflowSubnet(subnetCfg); idx >= 0 {
+		if _, overflowNet, _ := net.ParseCIDR(overflowCfg.Network); overflowNet != nil && overflowNet.Contains(ip) {
+			subnetIdx, subnetCfg = idx, overflowCfg
+		}
+	}
+
 	// Verify the requested IP is within the subnet CIDR
 	_, subnetNet, _ := net.ParseCIDR(subnetCfg.Network)
 	if subnetNet != nil && !subnetNet.Contains(ip) {
@@ -363,6 +474,17 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 		return h.buildNAK(pkt, "requested IP not in subnet"), nil
 	}
 
+	// A pool limited to client classes is closed to everyone else, whether
+	// they got the address from us or just ask for it
+	if p := poolContaining(h.pools[subnetCfg.Network], ip); p != nil && framed == nil &&
+		!p.AllowsClasses(ClassNames(classes)) && !h.reservedFor(clientID, mac, subnetIdx, ip) {
+		h.logger.Info("DHCPREQUEST for address in a pool outside the client's classes",
+			"mac", mac.String(),
+			"requested_ip", ip.String(),
+			"pool", p.RangeString())
+		return h.buildNAK(pkt, "address not available to client class"), nil
+	}
+
 	// Verify the IP is valid for this client
 	existing := h.leases.FindExistingLease(clientID, mac)
 	if existing != nil && !existing.IP.Equal(ip) {
@@ -372,8 +494,28 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 			"requested", ip.String(),
 			"offered", existing.IP.String())
 	}
+	if h.renewalsOnly() && framed == nil && (existing == nil || !existing.IP.Equal(ip)) && !h.reservedFor(clientID, mac, subnetIdx, ip) {
+		metrics.HARenewalsOnlyDropped.Inc()
+		h.logger.Info("DHCPREQUEST for unbound address ignored — HA communications interrupted",
+			"mac", mac.String(),
+			"requested_ip", ip.String())
+		return nil, nil
+	}
+
+	// The address may have been offered to someone else since, e.g. after
+	// this client's offer was reclaimed under pool pressure
+	if holder := h.leases.Store().GetByIP(ip); holder != nil && holder.MAC.String() != mac.String() {
+		h.logger.Warn("DHCPREQUEST for address leased to another client",
+			"mac", mac.String(),
+			"requested_ip", ip.String(),
+			"holder", holder.MAC.String())
+		return h.buildNAK(pkt, "address in use"), nil
+	}
 
-	leaseTime := h.cfg.GetLeaseTime(subnetIdx)
+	resolved := h.resolveClientOptions(pkt, subnetIdx, subnetCfg, ip, auth)
+	h.shortenLeaseTime(resolved, subnetCfg, ip)
+	h.capLeaseTime(resolved, ip)
+	leaseTime := resolved.LeaseTime
 
 	var relayInfo *lease.RelayInfo
 	if pkt.IsRelayed() {
@@ -383,13 +525,21 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 				GIAddr:    pkt.GIAddr,
 				CircuitID: ri.CircuitID,
 				RemoteID:  ri.RemoteID,
+				RelayID:   hex.EncodeToString(ri.RelayID),
 			}
 		}
 	}
 
 	// Confirm the lease
-	poolRange := ""
-	if existing != nil {
+	var poolRange string
+	switch {
+	case existing == nil || !existing.IP.Equal(ip):
+		// Nothing on record for this address, e.g. a reclaimed offer: take it now
+		poolRange = claimPoolIP(h.pools[subnetCfg.Network], ip)
+	case existing.State == dhcpv4.LeaseStateReclaimed:
+		// INIT-REBOOT into an address held for this client
+		poolRange = claimPoolIP(h.pools[subnetCfg.Network], ip)
+	default:
 		poolRange = existing.Pool
 	}
 	_, err := h.leases.ConfirmLease(ip, mac, clientID, hostname, subnetCfg.Network, poolRange, leaseTime, relayInfo)
@@ -397,6 +547,18 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 		return nil, fmt.Errorf("confirming lease for %s: %w", mac, err)
 	}
 
+	// Synchronous HA: no DHCPACK until the peer has the binding. The
+	// client retransmits, by which time the peer has caught up or is gone.
+	if h.peerSync != nil {
+		if err := h.peerSync.WaitAck(ctx, ip); err != nil {
+			h.logger.Warn("DHCPACK withheld — HA peer has not acknowledged the binding",
+				"mac", mac.String(),
+				"ip", ip.String(),
+				"error", err)
+			return nil, nil
+		}
+	}
+
 	// Send gratuitous ARP after successful ACK (local subnets only)
 	if h.detector != nil && h.cfg.ConflictDetection.SendGratuitousARP {
 		h.detector.SendGratuitousARPForLease(mac, ip)
@@ -411,13 +573,14 @@ func (h *Handler) handleRequest(ctx context.Context, pkt *Packet) (*Packet, erro
 		reply.CIAddr = pkt.CIAddr
 	}
 
-	h.setSubnetOptions(reply, subnetIdx, subnetCfg, leaseTime)
+	h.applyOptions(pkt, reply, resolved, true)
 
 	// Copy relay agent info back
 	if pkt.Options.Has(dhcpv4.OptionRelayAgentInfo) {
 		reply.Options[dhcpv4.OptionRelayAgentInfo] = pkt.Options[dhcpv4.OptionRelayAgentInfo]
 	}
 
+	h.finaliseReply(pkt, reply, subnetIdx)
 	return reply, nil
 }
 
@@ -475,21 +638,12 @@ func (h *Handler) handleRelease(pkt *Packet) {
 		"mac", mac.String(),
 		"ip", ip.String())
 
+	// The lease manager hands the address back to its pool (freeAddress)
 	if err := h.leases.Release(ip, mac); err != nil {
 		h.logger.Error("failed to process RELEASE",
 			"ip", ip.String(),
 			"error", err)
 	}
-
-	// Release IP back to pool
-	for _, subnetPools := range h.pools {
-		for _, p := range subnetPools {
-			if p.Contains(ip) {
-				p.Release(ip)
-				break
-			}
-		}
-	}
 }
 
 // handleInform processes DHCPINFORM — client requesting options only (no IP assignment).
@@ -505,6 +659,9 @@ func (h *Handler) handleInform(pkt *Packet) (*Packet, error) {
 	if subnetIdx < 0 {
 		return nil, nil
 	}
+	if DeniedBy(h.classify(pkt)) != nil {
+		return nil, nil
+	}
 
 	reply := pkt.NewReply(dhcpv4.MessageTypeAck, h.serverIP)
 	reply.CIAddr = pkt.CIAddr
@@ -512,11 +669,10 @@ func (h *Handler) handleInform(pkt *Packet) (*Packet, error) {
 	reply.YIAddr = net.IPv4zero
 
 	// Set options (no lease time for INFORM)
-	h.setSubnetOptions(reply, subnetIdx, subnetCfg, 0)
-	reply.Options.Delete(dhcpv4.OptionIPLeaseTime)
-	reply.Options.Delete(dhcpv4.OptionRenewalTime)
-	reply.Options.Delete(dhcpv4.OptionRebindingTime)
+	resolved := h.resolveClientOptions(pkt, subnetIdx, subnetCfg, pkt.CIAddr, nil)
+	h.applyOptions(pkt, reply, resolved, false)
 
+	h.finaliseReply(pkt, reply, subnetIdx)
 	return reply, nil
 }
 
@@ -613,86 +769,207 @@ func (h *Handler) findSubnetForIP(ip net.IP) (int, *config.SubnetConfig) {
 	return -1, nil
 }
 
-// setSubnetOptions populates response options from subnet configuration.
-func (h *Handler) setSubnetOptions(reply *Packet, subnetIdx int, subnetCfg *config.SubnetConfig, leaseTime time.Duration) {
+// resolveClientOptions computes the effective options for a client by walking
+// the precedence chain: defaults → subnet → pool → client class → reservation,
+// with RADIUS reply attributes on top. ip selects the pool layer; it may be nil
+// or outside every pool. auth may be nil.
+func (h *Handler) resolveClientOptions(pkt *Packet, subnetIdx int, subnetCfg *config.SubnetConfig, ip net.IP, auth *radius.AuthResult) *ResolvedOptions {
+	clientID := fmt.Sprintf("%x", pkt.ClientIdentifier())
+	res := h.leases.FindReservation(clientID, pkt.CHAddr, subnetIdx)
 	_, network, _ := net.ParseCIDR(subnetCfg.Network)
 
-	// Subnet mask
-	reply.Options[dhcpv4.OptionSubnetMask] = []byte(network.Mask)
+	classes := ClassLayers(h.classify(pkt))
+	layers := OptionLayers(h.cfg, subnetCfg, FindPoolConfig(subnetCfg, ip), classes, res)
+	layers = append(layers, radiusLayer(auth)...)
+	return ResolveOptions(network, layers)
+}
+
+// applyOptions copies resolved options into a reply. Lease timers are only
+// included when withLease is set (not for DHCPINFORM, RFC 2131 §4.3.5).
+func (h *Handler) applyOptions(pkt, reply *Packet, r *ResolvedOptions, withLease bool) {
+	for code, data := range r.Options {
+		reply.Options[code] = data
+	}
+
+	if withLease && r.LeaseTime > 0 {
+		reply.Options.SetUint32(dhcpv4.OptionIPLeaseTime, uint32(r.LeaseTime.Seconds()))
+		reply.Options.SetUint32(dhcpv4.OptionRenewalTime, uint32(r.RenewalTime.Seconds()))
+		reply.Options.SetUint32(dhcpv4.OptionRebindingTime, uint32(r.RebindTime.Seconds()))
+	}
 
-	// Routers
-	if len(subnetCfg.Routers) > 0 {
-		var routers []net.IP
-		for _, r := range subnetCfg.Routers {
-			if ip := net.ParseIP(r); ip != nil {
-				routers = append(routers, ip)
+	// PXE: siaddr is the next server, the file header field the boot file.
+	// Clients that ask for option 67 get it there too.
+	if r.NextServer != nil {
+		reply.SIAddr = r.NextServer
+	}
+	if r.BootFile != "" {
+		copy(reply.File[:len(reply.File)-1], r.BootFile)
+		if !reply.Options.Has(dhcpv4.OptionBootfileName) {
+			for _, code := range pkt.ParameterRequestList() {
+				if code == dhcpv4.OptionBootfileName {
+					reply.Options.SetString(dhcpv4.OptionBootfileName, r.BootFile)
+					break
+				}
 			}
 		}
-		if len(routers) > 0 {
-			reply.Options[dhcpv4.OptionRouter] = dhcpv4.IPListToBytes(routers)
+	}
+}
+
+// essentialOptions are sent even when the client didn't request them.
+// RFC 2131 §4.3.1 — message type, server identifier and lease times are
+// mandatory; client-id and relay info are echoes (RFC 6842, RFC 3046).
+var essentialOptions = map[dhcpv4.OptionCode]bool{
+	dhcpv4.OptionSubnetMask:       true,
+	dhcpv4.OptionIPLeaseTime:      true,
+	dhcpv4.OptionDHCPMessageType:  true,
+	dhcpv4.OptionServerIdentifier: true,
+	dhcpv4.OptionMessage:          true,
+	dhcpv4.OptionRenewalTime:      true,
+	dhcpv4.OptionRebindingTime:    true,
+	dhcpv4.OptionClientIdentifier: true,
+	dhcpv4.OptionRelayAgentInfo:   true,
+}
+
+// finaliseReply orders reply options per the client's Parameter Request List
+// (RFC 2132 §9.8), optionally strips options it didn't ask for, and sizes the
+// reply to the client's maximum message size (option 57, default 576).
+func (h *Handler) finaliseReply(pkt, reply *Packet, subnetIdx int) {
+	prl := pkt.ParameterRequestList()
+	reply.OptionOrder = prl
+	reply.MaxSize = pkt.ReplySizeLimit()
+
+	if len(prl) == 0 || !h.cfg.OnlyRequestedOptions(subnetIdx) {
+		return
+	}
+	requested := make(map[dhcpv4.OptionCode]bool, len(prl))
+	for _, code := range prl {
+		requested[code] = true
+	}
+	for code := range reply.Options {
+		if !requested[code] && !essentialOptions[code] {
+			delete(reply.Options, code)
 		}
 	}
+}
 
-	// DNS servers (subnet → defaults)
-	dnsServers := subnetCfg.DNSServers
-	if len(dnsServers) == 0 {
-		dnsServers = h.cfg.Defaults.DNSServers
+// UpdateConfig updates the handler's configuration (for hot-reload).
+func (h *Handler) UpdateConfig(cfg *config.Config) {
+	h.checkCustomOptions(cfg)
+	h.classifier = h.buildClassifier(cfg)
+	h.cfg = cfg
+	h.serverIP = cfg.ServerIP()
+	if h.serverIP == nil && h.ifaceIP != nil {
+		h.serverIP = h.ifaceIP
 	}
-	if len(dnsServers) > 0 {
-		var ips []net.IP
-		for _, s := range dnsServers {
-			if ip := net.ParseIP(s); ip != nil {
-				ips = append(ips, ip)
+}
+
+// checkCustomOptions logs custom options that fail to encode. The API and
+// TOML loader reject these before they are saved, so this only fires for
+// hand-edited databases; resolution skips the bad entries.
+func (h *Handler) checkCustomOptions(cfg *config.Config) {
+	logBad := func(scope string, opts []config.OptionConfig) {
+		for i, oc := range opts {
+			if _, _, err := EncodeOptionConfig(oc); err != nil {
+				h.logger.Error("invalid custom option, skipping",
+					"scope", scope,
+					"index", i,
+					"error", err)
 			}
 		}
-		if len(ips) > 0 {
-			reply.Options[dhcpv4.OptionDomainNameServer] = dhcpv4.IPListToBytes(ips)
+	}
+	for _, sub := range cfg.Subnets {
+		logBad("subnet "+sub.Network, sub.Options)
+		for _, p := range sub.Pools {
+			logBad("pool "+p.RangeStart+"-"+p.RangeEnd, p.Options)
+		}
+		for _, res := range sub.Reservations {
+			logBad("reservation "+res.MAC+res.Identifier, res.Options)
 		}
 	}
+}
 
-	// Domain name
-	domainName := subnetCfg.DomainName
-	if domainName == "" {
-		domainName = h.cfg.Defaults.DomainName
+// buildClassifier compiles the configured client classes, logging any that
+// fail — those never match.
+func (h *Handler) buildClassifier(cfg *config.Config) *Classifier {
+	cl, errs := NewClassifier(cfg.ClientClasses)
+	for _, err := range errs {
+		h.logger.Error("invalid client class, skipping", "error", err)
 	}
-	if domainName != "" {
-		reply.Options.SetString(dhcpv4.OptionDomainName, domainName)
+	return cl
+}
+
+// loadBalanceKey is the RFC 3074 hash input: the client identifier if
+// present, otherwise chaddr.
+func loadBalanceKey(pkt *Packet) []byte {
+	if cid := pkt.ClientIdentifier(); len(cid) > 0 {
+		return cid
 	}
+	return pkt.CHAddr
+}
 
-	// NTP servers
-	if len(subnetCfg.NTPServers) > 0 {
-		var ips []net.IP
-		for _, s := range subnetCfg.NTPServers {
-			if ip := net.ParseIP(s); ip != nil {
-				ips = append(ips, ip)
-			}
-		}
-		if len(ips) > 0 {
-			reply.Options[dhcpv4.OptionNTPServers] = dhcpv4.IPListToBytes(ips)
+// renewalsOnly reports whether only existing bindings may be served because
+// HA communications with the peer are interrupted.
+func (h *Handler) renewalsOnly() bool {
+	return h.ha != nil && h.ha.RenewalsOnly()
+}
+
+// poolContaining returns the pool whose range holds ip, or nil.
+func poolContaining(pools []*pool.Pool, ip net.IP) *pool.Pool {
+	for _, p := range pools {
+		if p.Contains(ip) {
+			return p
 		}
 	}
+	return nil
+}
 
-	// Broadcast address
-	broadcastIP := dhcpv4.Uint32ToIP(dhcpv4.IPToUint32(network.IP) | ^dhcpv4.IPToUint32(net.IP(network.Mask)))
-	reply.Options[dhcpv4.OptionBroadcastAddress] = dhcpv4.IPToBytes(broadcastIP)
+// reservedFor reports whether ip is the client's reservation in the subnet.
+func (h *Handler) reservedFor(clientID string, mac net.HardwareAddr, subnetIdx int, ip net.IP) bool {
+	res := h.leases.FindReservation(clientID, mac, subnetIdx)
+	return res != nil && net.ParseIP(res.IP).Equal(ip)
+}
 
-	// Lease timing
-	if leaseTime > 0 {
-		reply.Options.SetUint32(dhcpv4.OptionIPLeaseTime, uint32(leaseTime.Seconds()))
-		renewalTime := h.cfg.GetRenewalTime(subnetIdx)
-		rebindTime := h.cfg.GetRebindTime(subnetIdx)
-		reply.Options.SetUint32(dhcpv4.OptionRenewalTime, uint32(renewalTime.Seconds()))
-		reply.Options.SetUint32(dhcpv4.OptionRebindingTime, uint32(rebindTime.Seconds()))
+// applyPoolShare restricts p to this node's slice when load balancing.
+func (h *Handler) applyPoolShare(p *pool.Pool) {
+	if h.lb != nil {
+		p.SetShare(h.lb.PoolShare())
 	}
 }
 
-// UpdateConfig updates the handler's configuration (for hot-reload).
-func (h *Handler) UpdateConfig(cfg *config.Config) {
-	h.cfg = cfg
-	h.serverIP = cfg.ServerIP()
-	if h.serverIP == nil && h.ifaceIP != nil {
-		h.serverIP = h.ifaceIP
+// capLeaseTime shortens the lease to the MCLT beyond what the HA peer has
+// acknowledged for ip (load-balancing mode only).
+func (h *Handler) capLeaseTime(r *ResolvedOptions, ip net.IP) {
+	if h.lb == nil {
+		return
+	}
+	if d := h.lb.LeaseTime(ip, r.LeaseTime); d < r.LeaseTime {
+		metrics.HAMCLTCapped.Inc()
+		r.SetLeaseTime(d)
+	}
+}
+
+// ApplyPeerLease merges a binding received from the HA peer and keeps the
+// pool bitmaps in step, so neither node hands out an address the other
+// has leased.
+func (h *Handler) ApplyPeerLease(l *lease.Lease) error {
+	changed, err := h.leases.ApplyPeerLease(l)
+	if err != nil || !changed || l.IsV6() {
+		return err
+	}
+	switch l.State {
+	case dhcpv4.LeaseStateActive:
+		claimPoolIP(h.pools[l.Subnet], l.IP)
+		return nil
+	case dhcpv4.LeaseStateReclaimed:
+		h.freeAddress(l, l.Expiry)
+		return nil
+	}
+	for _, p := range h.pools[l.Subnet] {
+		if p.Contains(l.IP) {
+			p.Release(l.IP)
+		}
 	}
+	return nil
 }
 
 // UpdatePools updates the handler's pool map (for hot-reload).
@@ -0,0 +1,460 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+func TestFinaliseReplyOnlyRequested(t *testing.T) {
+	strict := true
+	h := &Handler{cfg: &config.Config{
+		Subnets: []config.SubnetConfig{{Network: "192.168.1.0/24", OnlyRequestedOptions: &strict}},
+	}}
+
+	req := &Packet{Options: Options{
+		dhcpv4.OptionParameterRequestList: {byte(dhcpv4.OptionRouter), byte(dhcpv4.OptionDomainNameServer)},
+		dhcpv4.OptionMaxDHCPMessageSize:   dhcpv4.Uint16ToBytes(1400),
+	}}
+	reply := &Packet{Options: Options{
+		dhcpv4.OptionDHCPMessageType:  {byte(dhcpv4.MessageTypeAck)},
+		dhcpv4.OptionServerIdentifier: {192, 168, 1, 1},
+		dhcpv4.OptionSubnetMask:       {255, 255, 255, 0},
+		dhcpv4.OptionRouter:           {192, 168, 1, 1},
+		dhcpv4.OptionDomainNameServer: {8, 8, 8, 8},
+		dhcpv4.OptionNTPServers:       {192, 168, 1, 2},
+		dhcpv4.OptionIPLeaseTime:      dhcpv4.Uint32ToBytes(3600),
+	}}
+
+	h.finaliseReply(req, reply, 0)
+
+	if reply.Options.Has(dhcpv4.OptionNTPServers) {
+		t.Error("unrequested NTP option should be stripped")
+	}
+	for _, code := range []dhcpv4.OptionCode{
+		dhcpv4.OptionRouter, dhcpv4.OptionDomainNameServer, dhcpv4.OptionSubnetMask,
+		dhcpv4.OptionIPLeaseTime, dhcpv4.OptionServerIdentifier,
+	} {
+		if !reply.Options.Has(code) {
+			t.Errorf("option %d should be kept", code)
+		}
+	}
+	if reply.MaxSize != 1400-28 {
+		t.Errorf("MaxSize = %d, want %d", reply.MaxSize, 1400-28)
+	}
+	if len(reply.OptionOrder) != 2 || reply.OptionOrder[0] != dhcpv4.OptionRouter {
+		t.Errorf("OptionOrder = %v, want client PRL", reply.OptionOrder)
+	}
+}
+
+func TestFinaliseReplyDefaultKeepsAll(t *testing.T) {
+	h := &Handler{cfg: &config.Config{
+		Subnets: []config.SubnetConfig{{Network: "192.168.1.0/24"}},
+	}}
+	req := &Packet{Options: Options{
+		dhcpv4.OptionParameterRequestList: {byte(dhcpv4.OptionRouter)},
+	}}
+	reply := &Packet{Options: Options{
+		dhcpv4.OptionNTPServers: {192, 168, 1, 2},
+	}}
+
+	h.finaliseReply(req, reply, 0)
+
+	if !reply.Options.Has(dhcpv4.OptionNTPServers) {
+		t.Error("NTP option should be kept when only_requested_options is off")
+	}
+}
+
+// testLoadBalancer answers a fixed scope and caps leases at mclt.
+type testLoadBalancer struct {
+	inScope bool
+	mclt    time.Duration
+}
+
+func (lb testLoadBalancer) InScope([]byte) bool   { return lb.inScope }
+func (lb testLoadBalancer) PoolShare() (int, int) { return 0, 2 }
+func (lb testLoadBalancer) LeaseTime(_ net.IP, d time.Duration) time.Duration {
+	return min(d, lb.mclt)
+}
+
+func TestLoadBalancerScopeAndMCLT(t *testing.T) {
+	h := &Handler{
+		cfg:    &config.Config{},
+		logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
+	}
+	h.SetLoadBalancer(testLoadBalancer{inScope: false, mclt: time.Hour})
+
+	pkt := &Packet{
+		Op:     dhcpv4.OpCodeBootRequest,
+		CHAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Options: Options{
+			dhcpv4.OptionDHCPMessageType: {byte(dhcpv4.MessageTypeDiscover)},
+		},
+	}
+	reply, err := h.HandlePacket(context.Background(), pkt, nil)
+	if err != nil || reply != nil {
+		t.Errorf("out-of-scope DISCOVER answered: reply=%v err=%v", reply, err)
+	}
+
+	r := &ResolvedOptions{LeaseTime: 8 * time.Hour, RenewalTime: 4 * time.Hour, RebindTime: 7 * time.Hour}
+	h.capLeaseTime(r, net.IPv4(192, 168, 1, 10))
+	if r.LeaseTime != time.Hour {
+		t.Errorf("LeaseTime = %s, want MCLT 1h", r.LeaseTime)
+	}
+	if r.RenewalTime != 30*time.Minute || r.RebindTime != 52*time.Minute+30*time.Second {
+		t.Errorf("T1/T2 = %s/%s, want defaults inside the capped lease", r.RenewalTime, r.RebindTime)
+	}
+}
+
+// testHA is an HA state that always serves.
+type testHA struct{ renewalsOnly bool }
+
+func (testHA) IsActive() bool       { return true }
+func (h testHA) RenewalsOnly() bool { return h.renewalsOnly }
+
+func TestRenewalsOnly(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+	h.SetHA(testHA{renewalsOnly: true})
+
+	// A new client asking for a free address is left alone
+	pkt := leaseQueryPacket(dhcpv4.MessageTypeRequest)
+	pkt.CHAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x60}
+	pkt.Options[dhcpv4.OptionRequestedIP] = net.IPv4(10, 0, 0, 60).To4()
+	reply, err := h.HandlePacket(context.Background(), pkt, nil)
+	if err != nil || reply != nil {
+		t.Errorf("REQUEST for unbound address answered: reply=%v err=%v", reply, err)
+	}
+
+	// The existing binding is still renewed
+	pkt = leaseQueryPacket(dhcpv4.MessageTypeRequest)
+	pkt.CHAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x50}
+	pkt.Options[dhcpv4.OptionClientIdentifier] = []byte{0x01, 0xaa, 0xbb, 0xcc, 0, 0, 0x50}
+	pkt.CIAddr = net.IPv4(10, 0, 0, 50).To4()
+	reply, err = h.HandlePacket(context.Background(), pkt, nil)
+	if err != nil || reply == nil || reply.MessageType() != dhcpv4.MessageTypeAck {
+		t.Fatalf("renewal not ACKed: reply=%v err=%v", reply, err)
+	}
+}
+
+// testPeerSync acknowledges bindings only when acked is set.
+type testPeerSync struct {
+	acked bool
+	ips   []string
+}
+
+func (p *testPeerSync) WaitAck(_ context.Context, ip net.IP) error {
+	p.ips = append(p.ips, ip.String())
+	if !p.acked {
+		return errors.New("timeout")
+	}
+	return nil
+}
+
+func TestSyncAck(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+	ps := &testPeerSync{}
+	h.SetPeerSync(ps)
+
+	renew := func() *Packet {
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeRequest)
+		pkt.CHAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x50}
+		pkt.Options[dhcpv4.OptionClientIdentifier] = []byte{0x01, 0xaa, 0xbb, 0xcc, 0, 0, 0x50}
+		pkt.CIAddr = net.IPv4(10, 0, 0, 50).To4()
+		return pkt
+	}
+
+	// No acknowledgement from the peer — the DHCPACK is withheld
+	reply, err := h.HandlePacket(context.Background(), renew(), nil)
+	if err != nil || reply != nil {
+		t.Errorf("DHCPACK sent without peer acknowledgement: reply=%v err=%v", reply, err)
+	}
+
+	ps.acked = true
+	reply, err = h.HandlePacket(context.Background(), renew(), nil)
+	if err != nil || reply == nil || reply.MessageType() != dhcpv4.MessageTypeAck {
+		t.Fatalf("acknowledged renewal not ACKed: reply=%v err=%v", reply, err)
+	}
+	if len(ps.ips) != 2 || ps.ips[1] != "10.0.0.50" {
+		t.Errorf("WaitAck calls = %v, want two for 10.0.0.50", ps.ips)
+	}
+}
+
+func TestLeaseAffinity(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Server:  config.ServerConfig{ServerID: "10.0.0.1"},
+		Subnets: []config.SubnetConfig{{Network: "10.0.0.0/24", LeaseTime: "1h", AffinityPeriod: "72h"}},
+	}
+	_, network, _ := net.ParseCIDR("10.0.0.0/24")
+	p, err := pool.NewPool("test", net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 102), network)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	leases := lease.NewManager(store, cfg, bus, logger)
+	h := NewHandler(cfg, leases, map[string][]*pool.Pool{"10.0.0.0/24": {p}}, nil, bus, logger)
+
+	ip := func(last byte) net.IP { return net.IPv4(10, 0, 0, last).To4() }
+	mac := func(last byte) net.HardwareAddr { return net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, last} }
+	discover := func(m net.HardwareAddr) net.IP {
+		t.Helper()
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeDiscover)
+		pkt.CHAddr = m
+		reply, err := h.HandlePacket(context.Background(), pkt, nil)
+		if err != nil || reply == nil {
+			t.Fatalf("DISCOVER from %s: reply=%v err=%v", m, reply, err)
+		}
+		return reply.YIAddr
+	}
+	bind := func(m net.HardwareAddr) net.IP {
+		t.Helper()
+		offered := discover(m)
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeRequest)
+		pkt.CHAddr = m
+		pkt.Options[dhcpv4.OptionRequestedIP] = offered.To4()
+		reply, err := h.HandlePacket(context.Background(), pkt, nil)
+		if err != nil || reply == nil || reply.MessageType() != dhcpv4.MessageTypeAck {
+			t.Fatalf("REQUEST from %s not ACKed: reply=%v err=%v", m, reply, err)
+		}
+		return offered
+	}
+	expire := func(addr net.IP) {
+		t.Helper()
+		l := store.GetByIP(addr)
+		l.Expiry = time.Now().Add(-time.Minute)
+		store.Put(l)
+		if n := leases.ExpireLeases(); n != 1 {
+			t.Fatalf("ExpireLeases = %d, want 1", n)
+		}
+	}
+
+	// The laptop's lease runs out; the address is held for it
+	if got := bind(mac(1)); !got.Equal(ip(100)) {
+		t.Fatalf("first client bound %s, want 10.0.0.100", got)
+	}
+	expire(ip(100))
+	if l := store.GetByIP(ip(100)); l == nil || l.State != dhcpv4.LeaseStateReclaimed || time.Until(l.Expiry) < 71*time.Hour {
+		t.Fatalf("expired lease = %+v, want expired-reclaimed for 72h", l)
+	}
+	if !p.IsHeld(ip(100)) {
+		t.Error("address of the expired lease not held")
+	}
+
+	// Others get other addresses; the laptop gets its own back
+	if got := bind(mac(2)); !got.Equal(ip(101)) {
+		t.Errorf("second client bound %s, want 10.0.0.101", got)
+	}
+	if got := bind(mac(1)); !got.Equal(ip(100)) {
+		t.Errorf("returning client bound %s, want its old 10.0.0.100", got)
+	}
+	if !p.IsAllocated(ip(100)) || p.IsHeld(ip(100)) {
+		t.Error("returning client's address not allocated")
+	}
+
+	// A released address is held too
+	rel := leaseQueryPacket(dhcpv4.MessageTypeRelease)
+	rel.CHAddr = mac(2)
+	rel.CIAddr = ip(101)
+	h.HandlePacket(context.Background(), rel, nil)
+	if l := store.GetByIP(ip(101)); l == nil || l.State != dhcpv4.LeaseStateReclaimed || !p.IsHeld(ip(101)) {
+		t.Errorf("released lease = %+v, want expired-reclaimed and held", l)
+	}
+
+	// Under pressure a held address goes to someone else, the soonest hold first
+	expire(ip(100))
+	bind(mac(3)) // .102, the last free one
+	if got := discover(mac(4)); !got.Equal(ip(100)) {
+		t.Errorf("client under pressure offered %s, want the held 10.0.0.100", got)
+	}
+	if l := leases.FindExistingLease("", mac(1)); l != nil {
+		t.Errorf("evicted client still has lease %+v", l)
+	}
+}
+
+func TestRequestPoolClientClasses(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Server:        config.ServerConfig{ServerID: "10.0.0.1"},
+		Subnets:       []config.SubnetConfig{{Network: "10.0.0.0/24", LeaseTime: "1h"}},
+		ClientClasses: []config.ClientClassConfig{{Name: "voip", Match: "vendor_class startswith 'Polycom'"}},
+	}
+	_, network, _ := net.ParseCIDR("10.0.0.0/24")
+	general, _ := pool.NewPool("general", net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 149), network)
+	phones, _ := pool.NewPool("phones", net.IPv4(10, 0, 0, 150), net.IPv4(10, 0, 0, 199), network)
+	phones.ClientClasses = []string{"voip"}
+	leases := lease.NewManager(store, cfg, bus, logger)
+	h := NewHandler(cfg, leases, map[string][]*pool.Pool{"10.0.0.0/24": {general, phones}}, nil, bus, logger)
+
+	request := func(last byte, ip net.IP, vendor string) *Packet {
+		t.Helper()
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeRequest)
+		pkt.CHAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, last}
+		pkt.Options[dhcpv4.OptionRequestedIP] = ip.To4()
+		if vendor != "" {
+			pkt.Options[dhcpv4.OptionVendorClassID] = []byte(vendor)
+		}
+		reply, err := h.HandlePacket(context.Background(), pkt, nil)
+		if err != nil || reply == nil {
+			t.Fatalf("REQUEST for %s: reply=%v err=%v", ip, reply, err)
+		}
+		return reply
+	}
+
+	// A laptop asking straight for a phone address is refused
+	if reply := request(1, net.IPv4(10, 0, 0, 150), ""); reply.MessageType() != dhcpv4.MessageTypeNak {
+		t.Errorf("non-member REQUEST for class pool address got %v, want NAK", reply.MessageType())
+	}
+	if phones.IsAllocated(net.IPv4(10, 0, 0, 150)) {
+		t.Error("refused address was allocated")
+	}
+
+	// Members get it, and everyone may still use the open pool
+	if reply := request(2, net.IPv4(10, 0, 0, 151), "Polycom-VVX"); reply.MessageType() != dhcpv4.MessageTypeAck {
+		t.Errorf("member REQUEST got %v, want ACK", reply.MessageType())
+	}
+	if reply := request(3, net.IPv4(10, 0, 0, 100), ""); reply.MessageType() != dhcpv4.MessageTypeAck {
+		t.Errorf("REQUEST in the open pool got %v, want ACK", reply.MessageType())
+	}
+}
+
+func TestPoolPressure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+	sub := bus.Subscribe(100)
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{ServerID: "10.0.0.1"},
+		Subnets: []config.SubnetConfig{
+			{
+				Network: "10.0.0.0/24", LeaseTime: "1h", OverflowSubnet: "10.0.1.0/24",
+				Pressure: &config.PressureConfig{
+					HighUtilisation:   50,
+					OfferReclaimAfter: "1h",
+					LeaseTimes:        []config.PressureLeaseTime{{Above: 40, LeaseTime: "10m"}},
+				},
+			},
+			{Network: "10.0.1.0/24", LeaseTime: "1h"},
+		},
+	}
+	newPool := func(start, end net.IP, network string) *pool.Pool {
+		_, n, _ := net.ParseCIDR(network)
+		p, err := pool.NewPool(start.String(), start, end, n)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		return p
+	}
+	primary := newPool(net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 101), "10.0.0.0/24")
+	spill := newPool(net.IPv4(10, 0, 0, 200), net.IPv4(10, 0, 0, 200), "10.0.0.0/24")
+	spill.Overflow = true
+	pools := map[string][]*pool.Pool{
+		"10.0.0.0/24": {spill, primary},
+		"10.0.1.0/24": {newPool(net.IPv4(10, 0, 1, 100), net.IPv4(10, 0, 1, 100), "10.0.1.0/24")},
+	}
+	leases := lease.NewManager(store, cfg, bus, logger)
+	h := NewHandler(cfg, leases, pools, nil, bus, logger)
+
+	mac := func(last byte) net.HardwareAddr { return net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, last} }
+	discover := func(m net.HardwareAddr) net.IP {
+		t.Helper()
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeDiscover)
+		pkt.CHAddr = m
+		reply, err := h.HandlePacket(context.Background(), pkt, nil)
+		if err != nil {
+			t.Fatalf("DISCOVER from %s: %v", m, err)
+		}
+		if reply == nil {
+			return nil
+		}
+		return reply.YIAddr.To4()
+	}
+	request := func(m net.HardwareAddr, addr net.IP) *Packet {
+		t.Helper()
+		pkt := leaseQueryPacket(dhcpv4.MessageTypeRequest)
+		pkt.CHAddr = m
+		pkt.Options[dhcpv4.OptionRequestedIP] = addr.To4()
+		reply, err := h.HandlePacket(context.Background(), pkt, nil)
+		if err != nil || reply == nil {
+			t.Fatalf("REQUEST from %s: reply=%v err=%v", m, reply, err)
+		}
+		return reply
+	}
+
+	// Half the pool in use: leases are shortened and utilisation_high fires
+	reply := request(mac(1), discover(mac(1)))
+	if d := reply.Options[dhcpv4.OptionIPLeaseTime]; len(d) != 4 || binary.BigEndian.Uint32(d) != 600 {
+		t.Errorf("lease time under pressure = %v, want 600s", d)
+	}
+	if evt := waitForEvent(t, sub, events.EventPoolHigh); evt.Pool == nil || evt.Pool.Utilisation != 50 || evt.Pool.Threshold != 50 {
+		t.Errorf("utilisation_high event = %+v", evt.Pool)
+	}
+
+	// The pool fills up: the overflow pool, then the overflow subnet
+	if got := discover(mac(2)); !got.Equal(net.IPv4(10, 0, 0, 101)) {
+		t.Fatalf("second client offered %s, want 10.0.0.101", got)
+	}
+	if got := discover(mac(3)); !got.Equal(net.IPv4(10, 0, 0, 200)) {
+		t.Errorf("third client offered %s, want the overflow pool's 10.0.0.200", got)
+	}
+	if evt := waitForEvent(t, sub, events.EventPoolExhausted); evt.Pool == nil || evt.Pool.Subnet != "10.0.0.0/24" || evt.Pool.Overflow == "" {
+		t.Errorf("exhausted event = %+v", evt.Pool)
+	}
+	overflowed := discover(mac(4))
+	if !overflowed.Equal(net.IPv4(10, 0, 1, 100)) {
+		t.Fatalf("fourth client offered %s, want the overflow subnet's 10.0.1.100", overflowed)
+	}
+	if reply := request(mac(4), overflowed); reply.MessageType() != dhcpv4.MessageTypeAck {
+		t.Errorf("REQUEST for the overflow subnet address not ACKed")
+	}
+	if l := store.GetByIP(overflowed); l == nil || l.Subnet != "10.0.1.0/24" {
+		t.Errorf("overflow lease = %+v, want subnet 10.0.1.0/24", l)
+	}
+	if got := discover(mac(5)); got != nil {
+		t.Errorf("client offered %s with every pool full", got)
+	}
+
+	// Unanswered offers are reclaimed for new clients
+	cfg.Subnets[0].Pressure.OfferReclaimAfter = "1ns"
+	if got := discover(mac(5)); !got.Equal(net.IPv4(10, 0, 0, 101)) {
+		t.Fatalf("client offered %s after reclaiming unanswered offers, want 10.0.0.101", got)
+	}
+	if reply := request(mac(2), net.IPv4(10, 0, 0, 101)); reply.MessageType() != dhcpv4.MessageTypeNak {
+		t.Errorf("REQUEST for a reclaimed offer given to someone else = %v, want NAK", reply.MessageType())
+	}
+}
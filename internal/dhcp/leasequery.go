@@ -0,0 +1,316 @@
+package dhcp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// leaseQuery is a parsed DHCPLEASEQUERY or DHCPBULKLEASEQUERY. Kind names
+// the selector that was used; "all" is the bulk-only query for every
+// configured address (RFC 6926 §7.2).
+type leaseQuery struct {
+	kind     string // "ip", "client_id", "mac", "relay_id", "remote_id", "all"
+	ip       net.IP
+	mac      net.HardwareAddr
+	clientID string // hex, as stored on the lease
+	relayID  string // hex, as stored on the lease
+	remoteID string
+
+	// RFC 6926 query-start-time / query-end-time filters (bulk only).
+	start, end time.Time
+}
+
+// parseLeaseQuery picks the query type. Precedence follows RFC 4388 §6.1:
+// ciaddr, then client-id, then chaddr. Bulk queries may instead select by
+// relay-id or remote-id in option 82, or by nothing at all.
+func parseLeaseQuery(pkt *Packet, bulk bool) (*leaseQuery, error) {
+	q := &leaseQuery{}
+	switch {
+	case pkt.CIAddr != nil && !pkt.CIAddr.Equal(net.IPv4zero):
+		q.kind = "ip"
+		q.ip = pkt.CIAddr.To4()
+	case len(pkt.ClientIdentifier()) > 0:
+		q.kind = "client_id"
+		q.clientID = fmt.Sprintf("%x", pkt.ClientIdentifier())
+	case hasHardwareAddr(pkt):
+		q.kind = "mac"
+		q.mac = pkt.CHAddr
+	case bulk && pkt.Options[dhcpv4.OptionRelayAgentInfo] != nil:
+		ri, err := ParseRelayAgentInfo(pkt.Options[dhcpv4.OptionRelayAgentInfo])
+		if err != nil {
+			return nil, err
+		}
+		if len(ri.RelayID) > 0 {
+			q.kind = "relay_id"
+			q.relayID = hex.EncodeToString(ri.RelayID)
+		} else if ri.RemoteID != "" {
+			q.kind = "remote_id"
+			q.remoteID = ri.RemoteID
+		} else {
+			return nil, fmt.Errorf("relay agent info carries neither relay-id nor remote-id")
+		}
+	case bulk:
+		q.kind = "all"
+	default:
+		return nil, fmt.Errorf("no ciaddr, client-id or chaddr to query by")
+	}
+
+	if bulk {
+		if v, ok := pkt.Options[dhcpv4.OptionQueryStartTime]; ok {
+			sec, err := dhcpv4.BytesToUint32(v)
+			if err != nil {
+				return nil, fmt.Errorf("query-start-time: %w", err)
+			}
+			q.start = time.Unix(int64(sec), 0)
+		}
+		if v, ok := pkt.Options[dhcpv4.OptionQueryEndTime]; ok {
+			sec, err := dhcpv4.BytesToUint32(v)
+			if err != nil {
+				return nil, fmt.Errorf("query-end-time: %w", err)
+			}
+			q.end = time.Unix(int64(sec), 0)
+		}
+	}
+	return q, nil
+}
+
+// matches reports whether l is selected by the query, ignoring the time filter.
+func (q *leaseQuery) matches(l *lease.Lease) bool {
+	switch q.kind {
+	case "ip":
+		return l.IP.Equal(q.ip)
+	case "client_id":
+		return l.ClientID == q.clientID
+	case "mac":
+		return l.MAC.String() == q.mac.String()
+	case "remote_id":
+		return l.RelayInfo != nil && l.RelayInfo.RemoteID == q.remoteID
+	case "relay_id":
+		return l.RelayInfo != nil && l.RelayInfo.RelayID == q.relayID
+	case "all":
+		return true
+	}
+	return false
+}
+
+// inWindow applies the RFC 6926 query-start-time / query-end-time filter to
+// the time the binding last changed.
+func (q *leaseQuery) inWindow(l *lease.Lease) bool {
+	if !q.start.IsZero() && l.LastUpdated.Before(q.start) {
+		return false
+	}
+	if !q.end.IsZero() && l.LastUpdated.After(q.end) {
+		return false
+	}
+	return true
+}
+
+// hasHardwareAddr reports whether chaddr carries a non-zero address.
+func hasHardwareAddr(pkt *Packet) bool {
+	for _, b := range pkt.CHAddr {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// relayAllowed checks an address against the leasequery ACL. An empty ACL
+// allows nobody.
+func relayAllowed(ip net.IP, allowed []string) bool {
+	if ip == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.Contains(a, "/") {
+			if _, network, err := net.ParseCIDR(a); err == nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleLeaseQuery answers a DHCPLEASEQUERY (RFC 4388) with DHCPLEASEACTIVE,
+// DHCPLEASEUNASSIGNED or DHCPLEASEUNKNOWN. Queries must come through a relay
+// listed in leasequery.allowed_relays; everything else is silently dropped.
+func (h *Handler) handleLeaseQuery(pkt *Packet) (*Packet, error) {
+	lqCfg := h.cfg.LeaseQuery
+	if !lqCfg.Enabled {
+		return nil, nil
+	}
+
+	// RFC 4388 §6.3 — the reply goes to giaddr, so a query without one is unanswerable
+	if !pkt.IsRelayed() {
+		metrics.LeaseQueries.WithLabelValues("udp", "none", "malformed").Inc()
+		return nil, nil
+	}
+	if !relayAllowed(pkt.GIAddr, lqCfg.AllowedRelays) {
+		metrics.LeaseQueries.WithLabelValues("udp", "none", "denied").Inc()
+		h.logger.Warn("DHCPLEASEQUERY from unauthorized relay", "giaddr", pkt.GIAddr.String())
+		return nil, nil
+	}
+
+	q, err := parseLeaseQuery(pkt, false)
+	if err != nil {
+		metrics.LeaseQueries.WithLabelValues("udp", "none", "malformed").Inc()
+		reply := pkt.NewReply(dhcpv4.MessageTypeLeaseUnknown, h.serverIP)
+		setLeaseQueryStatus(reply, dhcpv4.LeaseQueryStatusMalformedQuery, err.Error())
+		return reply, nil
+	}
+
+	reply := h.answerLeaseQuery(pkt, q)
+	metrics.LeaseQueries.WithLabelValues("udp", q.kind, leaseQueryResult(reply)).Inc()
+	h.logger.Info("DHCPLEASEQUERY",
+		"giaddr", pkt.GIAddr.String(),
+		"query", q.kind,
+		"result", reply.MessageType().String(),
+		"ciaddr", reply.CIAddr.String())
+	return reply, nil
+}
+
+// answerLeaseQuery resolves an IP, client-id or MAC query to a single reply.
+// An active binding gives DHCPLEASEACTIVE; a known address or client with no
+// active binding gives DHCPLEASEUNASSIGNED; anything else DHCPLEASEUNKNOWN.
+func (h *Handler) answerLeaseQuery(pkt *Packet, q *leaseQuery) *Packet {
+	bindings := h.queryBindings(q)
+
+	var best *lease.Lease
+	var associated []net.IP
+	for _, l := range bindings {
+		if isActiveBinding(l) {
+			associated = append(associated, l.IP)
+			if best == nil || !isActiveBinding(best) || l.LastUpdated.After(best.LastUpdated) {
+				best = l
+			}
+		} else if best == nil || (!isActiveBinding(best) && l.LastUpdated.After(best.LastUpdated)) {
+			best = l
+		}
+	}
+
+	switch {
+	case best != nil && isActiveBinding(best):
+		reply := h.bindingReply(pkt, best, dhcpv4.MessageTypeLeaseActive)
+		if len(associated) > 1 {
+			reply.Options[dhcpv4.OptionAssociatedIP] = dhcpv4.IPListToBytes(associated)
+		}
+		return reply
+	case best != nil:
+		return h.bindingReply(pkt, best, dhcpv4.MessageTypeLeaseUnassigned)
+	case q.kind == "ip" && h.servesIP(q.ip):
+		reply := pkt.NewReply(dhcpv4.MessageTypeLeaseUnassigned, h.serverIP)
+		reply.CIAddr = q.ip
+		return reply
+	default:
+		return pkt.NewReply(dhcpv4.MessageTypeLeaseUnknown, h.serverIP)
+	}
+}
+
+// leaseQueryResult is the metric label for a reply: active, unassigned or unknown.
+func leaseQueryResult(reply *Packet) string {
+	return strings.ToLower(strings.TrimPrefix(reply.MessageType().String(), "DHCPLEASE"))
+}
+
+// queryBindings returns the DHCPv4 leases selected by q, oldest change first.
+func (h *Handler) queryBindings(q *leaseQuery) []*lease.Lease {
+	store := h.leases.Store()
+	var out []*lease.Lease
+	if q.kind == "ip" {
+		if l := store.GetByIP(q.ip); l != nil && !l.IsV6() && q.inWindow(l) {
+			out = append(out, l)
+		}
+		return out
+	}
+	for _, l := range store.All() {
+		if !l.IsV6() && q.matches(l) && q.inWindow(l) {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastUpdated.Before(out[j].LastUpdated) })
+	return out
+}
+
+// servesIP reports whether ip falls inside one of our DHCPv4 subnets.
+func (h *Handler) servesIP(ip net.IP) bool {
+	for _, sub := range h.cfg.Subnets {
+		if _, network, err := net.ParseCIDR(sub.Network); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingReply builds a leasequery reply describing one binding (RFC 4388
+// §6.4.2): ciaddr and chaddr from the lease, plus remaining lease time,
+// client-last-transaction-time, client-id and the stored relay agent info.
+func (h *Handler) bindingReply(pkt *Packet, l *lease.Lease, msgType dhcpv4.MessageType) *Packet {
+	reply := pkt.NewReply(msgType, h.serverIP)
+	reply.CIAddr = l.IP.To4()
+	if len(l.MAC) > 0 {
+		reply.HType = dhcpv4.HardwareTypeEthernet
+		reply.HLen = byte(len(l.MAC))
+		reply.CHAddr = append(net.HardwareAddr(nil), l.MAC...)
+	}
+	delete(reply.Options, dhcpv4.OptionClientIdentifier)
+	if cid, err := hex.DecodeString(l.ClientID); err == nil && len(cid) > 0 {
+		reply.Options[dhcpv4.OptionClientIdentifier] = cid
+	}
+	if msgType == dhcpv4.MessageTypeLeaseActive {
+		reply.Options[dhcpv4.OptionIPLeaseTime] = dhcpv4.Uint32ToBytes(uint32(l.Remaining().Seconds()))
+	}
+	if !l.LastUpdated.IsZero() {
+		reply.Options[dhcpv4.OptionClientLastTransTime] = dhcpv4.Uint32ToBytes(uint32(time.Since(l.LastUpdated).Seconds()))
+	}
+	if ri := l.RelayInfo; ri != nil && (ri.CircuitID != "" || ri.RemoteID != "" || ri.RelayID != "") {
+		relayID, _ := hex.DecodeString(ri.RelayID)
+		reply.Options[dhcpv4.OptionRelayAgentInfo] = EncodeRelayAgentInfo(&RelayAgentInfo{
+			CircuitID: ri.CircuitID,
+			RemoteID:  ri.RemoteID,
+			RelayID:   relayID,
+		})
+	}
+	return reply
+}
+
+// isActiveBinding reports whether a lease is currently bound.
+func isActiveBinding(l *lease.Lease) bool {
+	return l.State == dhcpv4.LeaseStateActive && !l.IsExpired()
+}
+
+// leaseDHCPState maps a lease state to the RFC 6926 dhcp-state option value.
+func leaseDHCPState(l *lease.Lease) dhcpv4.DHCPState {
+	switch l.State {
+	case dhcpv4.LeaseStateActive:
+		if l.IsExpired() {
+			return dhcpv4.DHCPStateExpired
+		}
+		return dhcpv4.DHCPStateActive
+	case dhcpv4.LeaseStateOffered:
+		return dhcpv4.DHCPStateTransitioning
+	case dhcpv4.LeaseStateExpired, dhcpv4.LeaseStateReclaimed:
+		return dhcpv4.DHCPStateExpired
+	case dhcpv4.LeaseStateReleased:
+		return dhcpv4.DHCPStateReleased
+	case dhcpv4.LeaseStateDeclined:
+		return dhcpv4.DHCPStateAbandoned
+	default:
+		return dhcpv4.DHCPStateAvailable
+	}
+}
+
+// setLeaseQueryStatus adds an RFC 6926 status-code option.
+func setLeaseQueryStatus(reply *Packet, code dhcpv4.LeaseQueryStatus, msg string) {
+	reply.Options[dhcpv4.OptionStatusCode] = append([]byte{byte(code)}, msg...)
+}
@@ -0,0 +1,284 @@
+package dhcp
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// leaseQueryTestHandler builds a handler for 10.0.0.0/24 holding an active
+// lease for 10.0.0.50 and a released one for 10.0.0.51, answering
+// leasequery from relays in 10.0.0.0/30 and 127.0.0.1.
+func leaseQueryTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	for _, l := range []*lease.Lease{
+		{
+			IP: net.IPv4(10, 0, 0, 50).To4(), MAC: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x50},
+			ClientID: "01aabbcc000050", Subnet: "10.0.0.0/24", State: dhcpv4.LeaseStateActive,
+			Start: now.Add(-time.Minute), Expiry: now.Add(time.Hour), LastUpdated: now.Add(-time.Minute),
+			RelayInfo: &lease.RelayInfo{GIAddr: net.IPv4(10, 0, 0, 1), CircuitID: "eth0/1/3", RemoteID: "sw1", RelayID: "0003000102030405"},
+		},
+		{
+			IP: net.IPv4(10, 0, 0, 51).To4(), MAC: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x51},
+			Subnet: "10.0.0.0/24", State: dhcpv4.LeaseStateReleased,
+			Start: now.Add(-2 * time.Hour), Expiry: now.Add(-time.Hour), LastUpdated: now.Add(-time.Hour),
+		},
+	} {
+		if err := store.Put(l); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		Server:  config.ServerConfig{ServerID: "10.0.0.1"},
+		Subnets: []config.SubnetConfig{{Network: "10.0.0.0/24", LeaseTime: "1h"}},
+		LeaseQuery: config.LeaseQueryConfig{
+			Enabled:       true,
+			AllowedRelays: []string{"10.0.0.0/30", "127.0.0.1"},
+			BulkListen:    "127.0.0.1:0",
+		},
+	}
+	leases := lease.NewManager(store, cfg, bus, logger)
+	return NewHandler(cfg, leases, nil, nil, bus, logger)
+}
+
+func leaseQueryPacket(msgType dhcpv4.MessageType) *Packet {
+	return &Packet{
+		Op:      dhcpv4.OpCodeBootRequest,
+		HType:   1,
+		HLen:    6,
+		XID:     0x4242,
+		CIAddr:  net.IPv4zero,
+		GIAddr:  net.IPv4(10, 0, 0, 2).To4(),
+		CHAddr:  make(net.HardwareAddr, 6),
+		Options: Options{dhcpv4.OptionDHCPMessageType: {byte(msgType)}},
+	}
+}
+
+func TestLeaseQuery(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+
+	tests := []struct {
+		name      string
+		setup     func(p *Packet)
+		want      dhcpv4.MessageType
+		wantIP    string
+		wantMAC   string
+		wantRelay bool
+	}{
+		{"by IP active", func(p *Packet) { p.CIAddr = net.IPv4(10, 0, 0, 50).To4() },
+			dhcpv4.MessageTypeLeaseActive, "10.0.0.50", "aa:bb:cc:00:00:50", true},
+		{"by IP released", func(p *Packet) { p.CIAddr = net.IPv4(10, 0, 0, 51).To4() },
+			dhcpv4.MessageTypeLeaseUnassigned, "10.0.0.51", "aa:bb:cc:00:00:51", false},
+		{"by IP free in subnet", func(p *Packet) { p.CIAddr = net.IPv4(10, 0, 0, 99).To4() },
+			dhcpv4.MessageTypeLeaseUnassigned, "10.0.0.99", "", false},
+		{"by IP not ours", func(p *Packet) { p.CIAddr = net.IPv4(172, 16, 0, 1).To4() },
+			dhcpv4.MessageTypeLeaseUnknown, "0.0.0.0", "", false},
+		{"by MAC", func(p *Packet) { p.CHAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, 0x50} },
+			dhcpv4.MessageTypeLeaseActive, "10.0.0.50", "aa:bb:cc:00:00:50", true},
+		{"by client-id", func(p *Packet) {
+			p.Options[dhcpv4.OptionClientIdentifier] = []byte{0x01, 0xaa, 0xbb, 0xcc, 0, 0, 0x50}
+		}, dhcpv4.MessageTypeLeaseActive, "10.0.0.50", "aa:bb:cc:00:00:50", true},
+		{"unknown MAC", func(p *Packet) { p.CHAddr = net.HardwareAddr{0xde, 0xad, 0, 0, 0, 1} },
+			dhcpv4.MessageTypeLeaseUnknown, "0.0.0.0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt := leaseQueryPacket(dhcpv4.MessageTypeLeaseQuery)
+			tt.setup(pkt)
+			reply, err := h.HandlePacket(context.Background(), pkt, nil)
+			if err != nil || reply == nil {
+				t.Fatalf("HandlePacket = %v, %v", reply, err)
+			}
+			if reply.MessageType() != tt.want {
+				t.Fatalf("reply = %s, want %s", reply.MessageType(), tt.want)
+			}
+			if reply.CIAddr.String() != tt.wantIP {
+				t.Errorf("ciaddr = %s, want %s", reply.CIAddr, tt.wantIP)
+			}
+			if tt.wantMAC != "" && reply.CHAddr.String() != tt.wantMAC {
+				t.Errorf("chaddr = %s, want %s", reply.CHAddr, tt.wantMAC)
+			}
+			if got := reply.Options.Has(dhcpv4.OptionRelayAgentInfo); got != tt.wantRelay {
+				t.Errorf("relay agent info present = %v, want %v", got, tt.wantRelay)
+			}
+			if tt.want == dhcpv4.MessageTypeLeaseActive && !reply.Options.Has(dhcpv4.OptionIPLeaseTime) {
+				t.Error("DHCPLEASEACTIVE without lease time")
+			}
+		})
+	}
+}
+
+func TestLeaseQueryAccessControl(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+
+	pkt := leaseQueryPacket(dhcpv4.MessageTypeLeaseQuery)
+	pkt.CIAddr = net.IPv4(10, 0, 0, 50).To4()
+	pkt.GIAddr = net.IPv4(10, 0, 0, 9).To4()
+	if reply, _ := h.HandlePacket(context.Background(), pkt, nil); reply != nil {
+		t.Errorf("unauthorized relay got %s", reply.MessageType())
+	}
+
+	pkt.GIAddr = net.IPv4zero
+	if reply, _ := h.HandlePacket(context.Background(), pkt, nil); reply != nil {
+		t.Errorf("query without giaddr got %s", reply.MessageType())
+	}
+
+	h.cfg.LeaseQuery.Enabled = false
+	pkt.GIAddr = net.IPv4(10, 0, 0, 2).To4()
+	if reply, _ := h.HandlePacket(context.Background(), pkt, nil); reply != nil {
+		t.Errorf("disabled leasequery got %s", reply.MessageType())
+	}
+}
+
+func TestBulkLeaseQuery(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	srv := NewBulkLeaseQueryServer(h, logger)
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	query := func(pkt *Packet) []*Packet {
+		t.Helper()
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if err := writeFramed(conn, data); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var replies []*Packet
+		for {
+			data, err := readFramed(r)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			reply, err := DecodePacket(data)
+			if err != nil {
+				t.Fatalf("DecodePacket: %v", err)
+			}
+			if reply.XID != pkt.XID {
+				t.Fatalf("xid = %x, want %x", reply.XID, pkt.XID)
+			}
+			replies = append(replies, reply)
+			switch reply.MessageType() {
+			case dhcpv4.MessageTypeLeaseQueryDone, dhcpv4.MessageTypeLeaseQueryStatus:
+				return replies
+			}
+		}
+	}
+
+	// All configured addresses: two bindings then DONE
+	all := leaseQueryPacket(dhcpv4.MessageTypeBulkLeaseQuery)
+	all.GIAddr = net.IPv4zero
+	replies := query(all)
+	if len(replies) != 3 || replies[2].MessageType() != dhcpv4.MessageTypeLeaseQueryDone {
+		t.Fatalf("got %d replies, want 2 bindings + DONE", len(replies))
+	}
+	// Oldest change first: the released lease, then the active one
+	if replies[0].MessageType() != dhcpv4.MessageTypeLeaseUnassigned ||
+		replies[0].Options[dhcpv4.OptionDHCPState][0] != byte(dhcpv4.DHCPStateReleased) {
+		t.Errorf("first binding = %s state %v", replies[0].MessageType(), replies[0].Options[dhcpv4.OptionDHCPState])
+	}
+	if replies[1].MessageType() != dhcpv4.MessageTypeLeaseActive || !replies[1].CIAddr.Equal(net.IPv4(10, 0, 0, 50)) {
+		t.Errorf("second binding = %s %s", replies[1].MessageType(), replies[1].CIAddr)
+	}
+	if !replies[1].Options.Has(dhcpv4.OptionBaseTime) {
+		t.Error("bulk binding without base-time")
+	}
+
+	// Query start time excludes the binding that changed an hour ago
+	windowed := leaseQueryPacket(dhcpv4.MessageTypeBulkLeaseQuery)
+	windowed.XID = 0x4243
+	windowed.Options[dhcpv4.OptionQueryStartTime] = dhcpv4.Uint32ToBytes(uint32(time.Now().Add(-10 * time.Minute).Unix()))
+	if replies := query(windowed); len(replies) != 2 {
+		t.Errorf("windowed query got %d replies, want 1 binding + DONE", len(replies))
+	}
+
+	// By remote-id from option 82
+	byRemote := leaseQueryPacket(dhcpv4.MessageTypeBulkLeaseQuery)
+	byRemote.XID = 0x4244
+	byRemote.Options[dhcpv4.OptionRelayAgentInfo] = EncodeRelayAgentInfo(&RelayAgentInfo{RemoteID: "sw1"})
+	if replies := query(byRemote); len(replies) != 2 || !replies[0].CIAddr.Equal(net.IPv4(10, 0, 0, 50)) {
+		t.Errorf("remote-id query got %d replies", len(replies))
+	}
+
+	// By relay-id (RFC 6925 sub-option 12), echoed back in the binding
+	byRelay := leaseQueryPacket(dhcpv4.MessageTypeBulkLeaseQuery)
+	byRelay.XID = 0x4246
+	byRelay.Options[dhcpv4.OptionRelayAgentInfo] = EncodeRelayAgentInfo(&RelayAgentInfo{RelayID: []byte{0, 3, 0, 1, 2, 3, 4, 5}})
+	replies = query(byRelay)
+	if len(replies) != 2 || !replies[0].CIAddr.Equal(net.IPv4(10, 0, 0, 50)) {
+		t.Fatalf("relay-id query got %d replies", len(replies))
+	}
+	if ri := GetRelayInfo(replies[0]); ri == nil || string(ri.RelayID) != "\x00\x03\x00\x01\x02\x03\x04\x05" {
+		t.Errorf("binding relay agent info = %+v", ri)
+	}
+	otherRelay := leaseQueryPacket(dhcpv4.MessageTypeBulkLeaseQuery)
+	otherRelay.XID = 0x4247
+	otherRelay.Options[dhcpv4.OptionRelayAgentInfo] = EncodeRelayAgentInfo(&RelayAgentInfo{RelayID: []byte{0, 3, 0, 1, 9, 9, 9, 9}})
+	if replies := query(otherRelay); len(replies) != 1 || replies[0].MessageType() != dhcpv4.MessageTypeLeaseQueryDone {
+		t.Errorf("unknown relay-id got %d replies, want just DONE", len(replies))
+	}
+
+	// Wrong message type is answered with a status, not dropped
+	bad := leaseQueryPacket(dhcpv4.MessageTypeDiscover)
+	bad.XID = 0x4245
+	replies = query(bad)
+	if st := replies[0].Options[dhcpv4.OptionStatusCode]; len(st) == 0 || st[0] != byte(dhcpv4.LeaseQueryStatusMalformedQuery) {
+		t.Errorf("status = %v, want MalformedQuery", st)
+	}
+}
+
+func TestBulkLeaseQueryRejectsUnlistedPeer(t *testing.T) {
+	h := leaseQueryTestHandler(t)
+	h.cfg.LeaseQuery.AllowedRelays = []string{"10.0.0.1"}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	srv := NewBulkLeaseQueryServer(h, logger)
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := readFramed(conn); err == nil {
+		t.Error("expected connection from unlisted peer to be closed")
+	}
+}
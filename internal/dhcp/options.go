@@ -2,6 +2,7 @@ package dhcp
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
@@ -40,8 +41,10 @@ func DecodeOptions(data []byte) (Options, error) {
 			return nil, fmt.Errorf("truncated option %d: need %d bytes, have %d", code, length, len(data)-i)
 		}
 
-		value := make([]byte, length)
-		copy(value, data[i:i+length])
+		// RFC 3396 — a repeated option is split; concatenate the parts.
+		value := make([]byte, 0, length)
+		value = append(value, opts[code]...)
+		value = append(value, data[i:i+length]...)
 		opts[code] = value
 		i += length
 	}
@@ -50,22 +53,23 @@ func DecodeOptions(data []byte) (Options, error) {
 }
 
 // Encode serializes options to bytes with end marker.
+// Options are written in ascending code order; see EncodeOrdered.
 func (opts Options) Encode() []byte {
-	// Estimate size
-	size := 0
+	return opts.EncodeOrdered(nil)
+}
+
+// EncodeOrdered serializes options with end marker, writing the codes in
+// priority first (typically the client's Parameter Request List).
+// Values longer than 255 bytes are split per RFC 3396.
+func (opts Options) EncodeOrdered(priority []dhcpv4.OptionCode) []byte {
+	size := 1 // End option
 	for _, v := range opts {
-		size += 2 + len(v) // code + length + value
+		size += encodedLen(v)
 	}
-	size++ // End option
 
 	buf := make([]byte, 0, size)
-	for code, value := range opts {
-		if code == dhcpv4.OptionPad || code == dhcpv4.OptionEnd {
-			continue
-		}
-		buf = append(buf, byte(code))
-		buf = append(buf, byte(len(value)))
-		buf = append(buf, value...)
+	for _, code := range opts.orderedCodes(priority) {
+		buf = appendOption(buf, code, opts[code])
 	}
 
 	// End option
@@ -73,6 +77,72 @@ func (opts Options) Encode() []byte {
 	return buf
 }
 
+// orderedCodes returns the option codes present in opts in wire order.
+// RFC 2132 §9.8 — the client lists options "in order of preference".
+// Message type and server identifier always lead, the listed priority codes
+// follow, then everything else ascending. Relay agent info goes last
+// (RFC 3046 §2.1).
+func (opts Options) orderedCodes(priority []dhcpv4.OptionCode) []dhcpv4.OptionCode {
+	codes := make([]dhcpv4.OptionCode, 0, len(opts))
+	seen := make(map[dhcpv4.OptionCode]bool, len(opts))
+	add := func(code dhcpv4.OptionCode) {
+		if seen[code] || code == dhcpv4.OptionPad || code == dhcpv4.OptionEnd || code == dhcpv4.OptionRelayAgentInfo {
+			return
+		}
+		if _, ok := opts[code]; !ok {
+			return
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+
+	add(dhcpv4.OptionDHCPMessageType)
+	add(dhcpv4.OptionServerIdentifier)
+	add(dhcpv4.OptionOverload)
+	for _, code := range priority {
+		add(code)
+	}
+	rest := make([]dhcpv4.OptionCode, 0, len(opts))
+	for code := range opts {
+		rest = append(rest, code)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+	for _, code := range rest {
+		add(code)
+	}
+	if _, ok := opts[dhcpv4.OptionRelayAgentInfo]; ok {
+		codes = append(codes, dhcpv4.OptionRelayAgentInfo)
+	}
+	return codes
+}
+
+// appendOption writes one option as TLV, splitting values over 255 bytes
+// into consecutive instances of the same code (RFC 3396 §5).
+func appendOption(buf []byte, code dhcpv4.OptionCode, value []byte) []byte {
+	if len(value) == 0 {
+		return append(buf, byte(code), 0)
+	}
+	for len(value) > 0 {
+		n := len(value)
+		if n > 255 {
+			n = 255
+		}
+		buf = append(buf, byte(code), byte(n))
+		buf = append(buf, value[:n]...)
+		value = value[n:]
+	}
+	return buf
+}
+
+// encodedLen returns the number of bytes appendOption writes for value.
+func encodedLen(value []byte) int {
+	if len(value) == 0 {
+		return 2
+	}
+	segments := (len(value) + 254) / 255
+	return segments*2 + len(value)
+}
+
 // Get returns the raw value for an option code.
 func (opts Options) Get(code dhcpv4.OptionCode) ([]byte, bool) {
 	v, ok := opts[code]
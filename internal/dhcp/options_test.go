@@ -3,6 +3,7 @@ package dhcp
 import (
 	"testing"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
@@ -161,3 +162,108 @@ func TestOptionsSettersAndGetters(t *testing.T) {
 		t.Error("Delete failed — option still present")
 	}
 }
+
+func TestBuildCustomOptions(t *testing.T) {
+	opts, err := BuildCustomOptions([]config.OptionConfig{
+		{Code: 66, Type: "string", Value: "tftp.example.com"},
+		{Code: 67, Type: "string", Value: "pxelinux.0"},
+		{Code: 43, Type: "hex", Value: "01:04:c0:a8:01:01"},
+		{Code: 121, Type: "routes", Value: []interface{}{"10.0.0.0/8 192.168.1.1"}},
+		{Code: 119, Type: "domain_list", Value: []interface{}{"example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildCustomOptions error: %v", err)
+	}
+	if string(opts[dhcpv4.OptionTFTPServerName]) != "tftp.example.com" {
+		t.Errorf("option 66 = %q", opts[dhcpv4.OptionTFTPServerName])
+	}
+	if string(opts[dhcpv4.OptionBootfileName]) != "pxelinux.0" {
+		t.Errorf("option 67 = %q", opts[dhcpv4.OptionBootfileName])
+	}
+	if len(opts[dhcpv4.OptionVendorSpecific]) != 6 {
+		t.Errorf("option 43 length = %d, want 6", len(opts[dhcpv4.OptionVendorSpecific]))
+	}
+	if len(opts[dhcpv4.OptionClasslessStaticRoute]) != 6 {
+		t.Errorf("option 121 length = %d, want 6", len(opts[dhcpv4.OptionClasslessStaticRoute]))
+	}
+	if !opts.Has(dhcpv4.OptionDomainSearch) {
+		t.Error("expected option 119")
+	}
+}
+
+func TestBuildCustomOptionsRejectsTypeMismatch(t *testing.T) {
+	tests := []config.OptionConfig{
+		{Code: 3, Type: "string", Value: "gateway"},        // router must be an IP list
+		{Code: 26, Type: "uint32", Value: int64(1500)},     // MTU is uint16
+		{Code: 53, Type: "uint8", Value: int64(2)},         // message type is server-managed
+		{Code: 300, Type: "string", Value: "out of range"}, // code > 254
+		{Code: 66, Type: "", Value: "tftp"},                // type missing
+	}
+	for _, oc := range tests {
+		if _, err := BuildCustomOptions([]config.OptionConfig{oc}); err == nil {
+			t.Errorf("BuildCustomOptions(%+v) expected error", oc)
+		}
+	}
+}
+
+func TestOptionsEncodeOrdered(t *testing.T) {
+	opts := Options{
+		dhcpv4.OptionRelayAgentInfo:   {1, 1, 'x'},
+		dhcpv4.OptionSubnetMask:       {255, 255, 255, 0},
+		dhcpv4.OptionRouter:           {192, 168, 1, 1},
+		dhcpv4.OptionDomainNameServer: {8, 8, 8, 8},
+		dhcpv4.OptionServerIdentifier: {192, 168, 1, 1},
+		dhcpv4.OptionDHCPMessageType:  {byte(dhcpv4.MessageTypeAck)},
+	}
+
+	encoded := opts.EncodeOrdered([]dhcpv4.OptionCode{dhcpv4.OptionDomainNameServer, dhcpv4.OptionRouter})
+
+	var codes []dhcpv4.OptionCode
+	for i := 0; i < len(encoded) && encoded[i] != byte(dhcpv4.OptionEnd); i += 2 + int(encoded[i+1]) {
+		codes = append(codes, dhcpv4.OptionCode(encoded[i]))
+	}
+	want := []dhcpv4.OptionCode{
+		dhcpv4.OptionDHCPMessageType,
+		dhcpv4.OptionServerIdentifier,
+		dhcpv4.OptionDomainNameServer,
+		dhcpv4.OptionRouter,
+		dhcpv4.OptionSubnetMask,
+		dhcpv4.OptionRelayAgentInfo,
+	}
+	if len(codes) != len(want) {
+		t.Fatalf("codes = %v, want %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("codes[%d] = %d, want %d", i, codes[i], want[i])
+		}
+	}
+}
+
+func TestOptionsLongOptionSplit(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	opts := Options{dhcpv4.OptionDomainSearch: long}
+
+	encoded := opts.Encode()
+	// Two instances: 2+255 and 2+45, plus End
+	if len(encoded) != 2+255+2+45+1 {
+		t.Fatalf("encoded length = %d, want %d", len(encoded), 2+255+2+45+1)
+	}
+
+	decoded, err := DecodeOptions(encoded)
+	if err != nil {
+		t.Fatalf("DecodeOptions error: %v", err)
+	}
+	got := decoded[dhcpv4.OptionDomainSearch]
+	if len(got) != len(long) {
+		t.Fatalf("concatenated length = %d, want %d", len(got), len(long))
+	}
+	for i := range long {
+		if got[i] != long[i] {
+			t.Fatalf("byte %d = %d, want %d", i, got[i], long[i])
+		}
+	}
+}
@@ -31,8 +31,23 @@ type Packet struct {
 	// ReceivingInterface is set by the server to indicate which network
 	// interface this packet arrived on. Not part of the wire format.
 	ReceivingInterface string
+
+	// OptionOrder lists option codes to encode first (the client's
+	// Parameter Request List for replies). Not part of the wire format.
+	OptionOrder []dhcpv4.OptionCode
+
+	// MaxSize caps the encoded DHCP message length in bytes (excluding IP
+	// and UDP headers). Options that don't fit overflow into the file and
+	// sname fields (RFC 2132 §9.3) or are dropped. Zero means no limit.
+	MaxSize int
+
+	// Dropped lists options Encode had to leave out to honour MaxSize.
+	Dropped []dhcpv4.OptionCode
 }
 
+// ipUDPHeaderLen is the IPv4 + UDP header overhead counted by option 57.
+const ipUDPHeaderLen = 28
+
 // packetPool reuses packet buffers to reduce allocations in the hot path.
 var packetPool = sync.Pool{
 	New: func() interface{} {
@@ -109,13 +124,49 @@ func DecodePacket(data []byte) (*Packet, error) {
 		p.Options = make(Options)
 	}
 
+	// RFC 2132 §9.3 — option overload: file and/or sname carry more options.
+	// RFC 3396 §5 — parts are concatenated in options, file, sname order.
+	if ov, ok := p.Options[dhcpv4.OptionOverload]; ok && len(ov) == 1 {
+		if ov[0]&dhcpv4.OverloadFile != 0 {
+			if err := mergeOverloadOptions(p.Options, p.File[:]); err != nil {
+				return nil, fmt.Errorf("decoding file field options: %w", err)
+			}
+			p.File = [128]byte{}
+		}
+		if ov[0]&dhcpv4.OverloadSName != 0 {
+			if err := mergeOverloadOptions(p.Options, p.SName[:]); err != nil {
+				return nil, fmt.Errorf("decoding sname field options: %w", err)
+			}
+			p.SName = [64]byte{}
+		}
+		delete(p.Options, dhcpv4.OptionOverload)
+	}
+
 	return p, nil
 }
 
+// mergeOverloadOptions decodes options carried in an overloaded header field
+// and appends them to opts.
+func mergeOverloadOptions(opts Options, field []byte) error {
+	extra, err := DecodeOptions(field)
+	if err != nil {
+		return err
+	}
+	for code, v := range extra {
+		opts[code] = append(opts[code], v...)
+	}
+	return nil
+}
+
 // Encode serializes a DHCPv4 packet to bytes.
+// Options are ordered per OptionOrder and, when MaxSize is set, laid out to
+// fit within it using option overload if the file/sname fields are free.
 func (p *Packet) Encode() ([]byte, error) {
 	// Fixed header: 236 bytes + 4 magic cookie + options
-	optBytes := p.Options.Encode()
+	optBytes, fileOpts, snameOpts, err := p.layoutOptions()
+	if err != nil {
+		return nil, err
+	}
 	totalLen := 240 + len(optBytes)
 	if totalLen < dhcpv4.MinPacketSize {
 		totalLen = dhcpv4.MinPacketSize
@@ -145,8 +196,16 @@ func (p *Packet) Encode() ([]byte, error) {
 	if p.CHAddr != nil {
 		copy(buf[28:44], p.CHAddr)
 	}
-	copy(buf[44:108], p.SName[:])
-	copy(buf[108:236], p.File[:])
+	if snameOpts != nil {
+		copy(buf[44:108], snameOpts)
+	} else {
+		copy(buf[44:108], p.SName[:])
+	}
+	if fileOpts != nil {
+		copy(buf[108:236], fileOpts)
+	} else {
+		copy(buf[108:236], p.File[:])
+	}
 
 	// Magic cookie
 	copy(buf[236:240], dhcpv4.MagicCookie)
@@ -157,6 +216,134 @@ func (p *Packet) Encode() ([]byte, error) {
 	return buf, nil
 }
 
+// mustFitOptions are never dropped to honour MaxSize: a reply without its
+// message type, server identifier, subnet mask or lease times is useless to
+// the client, and a relay agent drops a reply that lost its option 82.
+// Encode fails instead.
+var mustFitOptions = map[dhcpv4.OptionCode]bool{
+	dhcpv4.OptionDHCPMessageType:  true,
+	dhcpv4.OptionServerIdentifier: true,
+	dhcpv4.OptionSubnetMask:       true,
+	dhcpv4.OptionIPLeaseTime:      true,
+	dhcpv4.OptionRenewalTime:      true,
+	dhcpv4.OptionRebindingTime:    true,
+	dhcpv4.OptionRelayAgentInfo:   true,
+}
+
+// mainAreaOptions are never moved into the overloaded file/sname fields.
+// Message type must be in the options area (RFC 2132 §9.3), and the relay
+// agent looks for its option 82 there (RFC 3046 §2.1).
+var mainAreaOptions = map[dhcpv4.OptionCode]bool{
+	dhcpv4.OptionDHCPMessageType:  true,
+	dhcpv4.OptionServerIdentifier: true,
+	dhcpv4.OptionSubnetMask:       true,
+	dhcpv4.OptionRelayAgentInfo:   true,
+}
+
+// layoutOptions encodes the options area and, if the options don't fit in
+// MaxSize, the overloaded file and sname fields (nil when unused).
+// mustFitOptions are placed first, then the rest in priority order; any
+// that fit nowhere are dropped and reported in Dropped. Relay agent info
+// stays last in the options area, after the overload option.
+func (p *Packet) layoutOptions() (main, file, sname []byte, err error) {
+	all := p.Options.EncodeOrdered(p.OptionOrder)
+	if p.MaxSize <= 0 || 240+len(all) <= p.MaxSize {
+		return all, nil, nil, nil
+	}
+
+	mainCap := p.MaxSize - 240 - 1 // room for End
+	if p.Options.Has(dhcpv4.OptionOverload) {
+		return nil, nil, nil, fmt.Errorf("options exceed max message size %d and overload is already set", p.MaxSize)
+	}
+
+	relay, relayed := p.Options[dhcpv4.OptionRelayAgentInfo]
+	if relayed {
+		mainCap -= encodedLen(relay)
+	}
+
+	type area struct {
+		buf  []byte
+		cap  int
+		free bool
+	}
+	areas := []*area{
+		{cap: mainCap - 3, free: true}, // reserve option 52
+		{cap: len(p.File) - 1, free: isZero(p.File[:])},
+		{cap: len(p.SName) - 1, free: isZero(p.SName[:])},
+	}
+
+	ordered := p.Options.orderedCodes(p.OptionOrder)
+	codes := make([]dhcpv4.OptionCode, 0, len(ordered))
+	for _, code := range ordered {
+		if mustFitOptions[code] && code != dhcpv4.OptionRelayAgentInfo {
+			codes = append(codes, code)
+		}
+	}
+	for _, code := range ordered {
+		if !mustFitOptions[code] {
+			codes = append(codes, code)
+		}
+	}
+
+	if mainCap-3 < 0 {
+		return nil, nil, nil, fmt.Errorf("max message size %d too small for option %d", p.MaxSize, dhcpv4.OptionRelayAgentInfo)
+	}
+
+	p.Dropped = nil
+	for _, code := range codes {
+		value := p.Options[code]
+		n := encodedLen(value)
+		placed := false
+		for i, a := range areas {
+			if !a.free || len(a.buf)+n > a.cap {
+				continue
+			}
+			if i > 0 && mainAreaOptions[code] {
+				continue
+			}
+			a.buf = appendOption(a.buf, code, value)
+			placed = true
+			break
+		}
+		if !placed {
+			if mustFitOptions[code] {
+				return nil, nil, nil, fmt.Errorf("max message size %d too small for option %d", p.MaxSize, code)
+			}
+			p.Dropped = append(p.Dropped, code)
+		}
+	}
+
+	var overload byte
+	if len(areas[1].buf) > 0 {
+		overload |= dhcpv4.OverloadFile
+		file = append(areas[1].buf, byte(dhcpv4.OptionEnd))
+	}
+	if len(areas[2].buf) > 0 {
+		overload |= dhcpv4.OverloadSName
+		sname = append(areas[2].buf, byte(dhcpv4.OptionEnd))
+	}
+
+	main = areas[0].buf
+	if overload != 0 {
+		main = appendOption(main, dhcpv4.OptionOverload, []byte{overload})
+	}
+	if relayed {
+		main = appendOption(main, dhcpv4.OptionRelayAgentInfo, relay)
+	}
+	main = append(main, byte(dhcpv4.OptionEnd))
+	return main, file, sname, nil
+}
+
+// isZero reports whether every byte in b is zero.
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // MessageType returns the DHCP message type from the packet options.
 func (p *Packet) MessageType() dhcpv4.MessageType {
 	if data, ok := p.Options[dhcpv4.OptionDHCPMessageType]; ok && len(data) == 1 {
@@ -279,3 +466,18 @@ func (p *Packet) MaxMessageSize() uint16 {
 	}
 	return 0
 }
+
+// ReplySizeLimit returns the largest DHCP message (without IP/UDP headers)
+// this client can accept. RFC 2131 §2 guarantees 576-byte datagrams; option 57
+// may raise that, and values below 576 are ignored (RFC 2132 §9.10).
+// The result is capped at the Ethernet MTU.
+func (p *Packet) ReplySizeLimit() int {
+	size := int(p.MaxMessageSize())
+	if size < dhcpv4.DefaultPacketSize {
+		size = dhcpv4.DefaultPacketSize
+	}
+	if size > dhcpv4.MaxPacketSize {
+		size = dhcpv4.MaxPacketSize
+	}
+	return size - ipUDPHeaderLen
+}
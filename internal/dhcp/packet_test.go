@@ -1,7 +1,9 @@
 package dhcp
 
 import (
+	"bytes"
 	"net"
+	"slices"
 	"testing"
 
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
@@ -269,3 +271,162 @@ func TestGetBufferPutBuffer(t *testing.T) {
 	}
 	PutBuffer(buf) // Should not panic
 }
+
+func TestPacketReplySizeLimit(t *testing.T) {
+	pkt := &Packet{Options: make(Options)}
+	if got := pkt.ReplySizeLimit(); got != 548 {
+		t.Errorf("default limit = %d, want 548", got)
+	}
+
+	pkt.Options.SetUint16(dhcpv4.OptionMaxDHCPMessageSize, 1024)
+	if got := pkt.ReplySizeLimit(); got != 996 {
+		t.Errorf("limit with option 57 = %d, want 996", got)
+	}
+
+	// Values below 576 are invalid and ignored
+	pkt.Options.SetUint16(dhcpv4.OptionMaxDHCPMessageSize, 300)
+	if got := pkt.ReplySizeLimit(); got != 548 {
+		t.Errorf("limit with tiny option 57 = %d, want 548", got)
+	}
+}
+
+func TestPacketEncodeOverload(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	req, err := DecodePacket(buildTestDiscover(mac, 1))
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	reply := req.NewReply(dhcpv4.MessageTypeOffer, net.IPv4(192, 168, 1, 1))
+	reply.Options[dhcpv4.OptionSubnetMask] = []byte{255, 255, 255, 0}
+	reply.Options.SetString(dhcpv4.OptionRootPath, string(make([]byte, 200)))
+	reply.Options.SetString(dhcpv4.OptionMeritDumpFile, "dump-file-that-overflows-into-the-file-field")
+	reply.Options.SetString(dhcpv4.OptionExtensionsPath, "short")
+	reply.MaxSize = 300
+
+	data, err := reply.Encode()
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if len(data) > 300 {
+		t.Errorf("encoded length = %d, want <= 300", len(data))
+	}
+
+	decoded, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("re-decode error: %v", err)
+	}
+	if decoded.MessageType() != dhcpv4.MessageTypeOffer {
+		t.Errorf("message type = %v, want OFFER", decoded.MessageType())
+	}
+	if !decoded.Options.Has(dhcpv4.OptionMeritDumpFile) {
+		t.Error("option 14 should have been carried in an overloaded field")
+	}
+	for _, code := range reply.Dropped {
+		if decoded.Options.Has(code) {
+			t.Errorf("dropped option %d present in decoded packet", code)
+		}
+	}
+	if len(reply.Dropped)+len(decoded.Options) < len(reply.Options) {
+		t.Errorf("options lost without being reported: dropped=%v", reply.Dropped)
+	}
+}
+
+func TestPacketEncodeOverloadRelayed(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	req, err := DecodePacket(buildTestDiscover(mac, 1))
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	req.GIAddr = net.IPv4(10, 0, 0, 1)
+	relayInfo := []byte{1, 6, 'e', 't', 'h', '0', '/', '1', 2, 4, 0xde, 0xad, 0xbe, 0xef}
+	req.Options[dhcpv4.OptionRelayAgentInfo] = relayInfo
+	reply := req.NewReply(dhcpv4.MessageTypeOffer, net.IPv4(192, 168, 1, 1))
+	reply.Options[dhcpv4.OptionSubnetMask] = []byte{255, 255, 255, 0}
+	reply.Options[dhcpv4.OptionRelayAgentInfo] = relayInfo
+	reply.Options.SetString(dhcpv4.OptionRootPath, string(make([]byte, 60)))
+	reply.Options.SetString(dhcpv4.OptionMeritDumpFile, string(make([]byte, 100)))
+	// Ask for the mask last so it would otherwise be placed after the rest
+	reply.OptionOrder = []dhcpv4.OptionCode{dhcpv4.OptionRootPath, dhcpv4.OptionMeritDumpFile}
+	reply.MaxSize = 240 + 3 + 6 + 6 + 16 + 62 + 3 + 1
+
+	data, err := reply.Encode()
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	// Walk the main options area: mask and relay info must be there, with
+	// option 82 the last one before End and after the overload option
+	var main []dhcpv4.OptionCode
+	for i := 240; i < len(data) && data[i] != byte(dhcpv4.OptionEnd); {
+		if data[i] == byte(dhcpv4.OptionPad) {
+			i++
+			continue
+		}
+		main = append(main, dhcpv4.OptionCode(data[i]))
+		i += 2 + int(data[i+1])
+	}
+	if len(main) < 2 || main[len(main)-2] != dhcpv4.OptionOverload || main[len(main)-1] != dhcpv4.OptionRelayAgentInfo {
+		t.Errorf("main area options = %v, want ... 52, 82", main)
+	}
+	if !slices.Contains(main, dhcpv4.OptionSubnetMask) {
+		t.Errorf("main area options = %v, want the subnet mask", main)
+	}
+
+	decoded, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("re-decode error: %v", err)
+	}
+	if !bytes.Equal(decoded.Options[dhcpv4.OptionRelayAgentInfo], relayInfo) {
+		t.Errorf("relay agent info = %x, want %x", decoded.Options[dhcpv4.OptionRelayAgentInfo], relayInfo)
+	}
+	if !decoded.Options.Has(dhcpv4.OptionMeritDumpFile) {
+		t.Error("option 14 should have been carried in an overloaded field")
+	}
+
+	// Without room for option 82 in the options area the reply fails
+	reply.MaxSize = 240 + 3 + 6 + 6 + 16 + 3
+	if _, err := reply.Encode(); err == nil {
+		t.Error("expected an error when option 82 does not fit")
+	}
+}
+
+func TestPacketEncodeKeepsLeaseTimes(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	req, err := DecodePacket(buildTestDiscover(mac, 1))
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	reply := req.NewReply(dhcpv4.MessageTypeOffer, net.IPv4(192, 168, 1, 1))
+	reply.Options.SetUint32(dhcpv4.OptionIPLeaseTime, 3600)
+	reply.Options.SetUint32(dhcpv4.OptionRenewalTime, 1800)
+	reply.Options.SetUint32(dhcpv4.OptionRebindingTime, 3150)
+	reply.Options.SetString(dhcpv4.OptionRootPath, string(make([]byte, 40)))
+	// The client asks for option 17 first, so it would otherwise claim
+	// the space ahead of the lease times
+	reply.OptionOrder = []dhcpv4.OptionCode{dhcpv4.OptionRootPath}
+	copy(reply.File[:], "pxelinux.0")
+	copy(reply.SName[:], "tftp")
+	reply.MaxSize = 240 + 1 + 3 + 3 + 6 + 3*6
+
+	data, err := reply.Encode()
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	decoded, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("re-decode error: %v", err)
+	}
+	for _, code := range []dhcpv4.OptionCode{dhcpv4.OptionIPLeaseTime, dhcpv4.OptionRenewalTime, dhcpv4.OptionRebindingTime} {
+		if !decoded.Options.Has(code) {
+			t.Errorf("option %d missing from the reply", code)
+		}
+	}
+	if len(reply.Dropped) != 1 || reply.Dropped[0] != dhcpv4.OptionRootPath {
+		t.Errorf("Dropped = %v, want [17]", reply.Dropped)
+	}
+
+	// With no room for the lease times the reply fails to encode
+	reply.MaxSize = 240 + 1 + 3 + 3 + 6
+	if _, err := reply.Encode(); err == nil {
+		t.Error("expected an error when the lease time does not fit")
+	}
+}
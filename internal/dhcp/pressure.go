@@ -0,0 +1,233 @@
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
+)
+
+// allocation is an address handed out for a DISCOVER, with the subnet and
+// pool it came from — not the client's own subnet if it overflowed.
+type allocation struct {
+	ip        net.IP
+	pool      *pool.Pool
+	subnetIdx int
+	subnet    *config.SubnetConfig
+}
+
+// allocate picks a free address for a new client from the pool it
+// matched. When that pool is full it reclaims unanswered offers in the
+// subnet and tries again, then spills into the subnet's overflow pools
+// and then into its overflow_subnet. Returns nil when there is nothing to
+// give or every candidate conflicted.
+func (h *Handler) allocate(ctx context.Context, subnetIdx int, subnetCfg *config.SubnetConfig,
+	selected *pool.Pool, criteria pool.MatchCriteria) *allocation {
+
+	ip, exhausted := h.allocateFrom(ctx, selected, subnetCfg.Network)
+	if exhausted {
+		if after := offerReclaimAfter(subnetCfg); after > 0 && h.leases.ReclaimOffers(subnetCfg.Network, after) > 0 {
+			ip, exhausted = h.allocateFrom(ctx, selected, subnetCfg.Network)
+		}
+	}
+	if !exhausted {
+		h.signalPressure(subnetCfg, selected, false, "")
+		if ip == nil {
+			return nil
+		}
+		return &allocation{ip: ip, pool: selected, subnetIdx: subnetIdx, subnet: subnetCfg}
+	}
+
+	metrics.PoolExhausted.WithLabelValues(subnetCfg.Network).Inc()
+	a := h.allocateOverflow(ctx, subnetIdx, subnetCfg, criteria)
+	overflow := ""
+	if a != nil {
+		overflow = a.pool.RangeString()
+		if a.subnet != subnetCfg {
+			overflow = a.subnet.Network
+		}
+	}
+	h.logger.Warn("pool exhausted",
+		"subnet", subnetCfg.Network,
+		"pool", selected.String(),
+		"overflow", overflow)
+	h.signalPressure(subnetCfg, selected, true, overflow)
+	return a
+}
+
+// allocateOverflow hands out an address from the first overflow pool of
+// the subnet the client matches, or else from the subnet's overflow_subnet.
+func (h *Handler) allocateOverflow(ctx context.Context, subnetIdx int, subnetCfg *config.SubnetConfig,
+	criteria pool.MatchCriteria) *allocation {
+
+	if p := pool.SelectOverflow(h.pools[subnetCfg.Network], criteria); p != nil {
+		h.applyPoolShare(p)
+		if ip, _ := h.allocateFrom(ctx, p, subnetCfg.Network); ip != nil {
+			metrics.PoolOverflow.WithLabelValues(subnetCfg.Network).Inc()
+			h.signalPressure(subnetCfg, p, false, "")
+			return &allocation{ip: ip, pool: p, subnetIdx: subnetIdx, subnet: subnetCfg}
+		}
+	}
+
+	idx, overflowCfg := h.overflowSubnet(subnetCfg)
+	if idx < 0 {
+		return nil
+	}
+	pools := h.pools[overflowCfg.Network]
+	for _, p := range []*pool.Pool{pool.SelectPool(pools, criteria), pool.SelectOverflow(pools, criteria)} {
+		if p == nil {
+			continue
+		}
+		h.applyPoolShare(p)
+		if ip, _ := h.allocateFrom(ctx, p, overflowCfg.Network); ip != nil {
+			metrics.PoolOverflow.WithLabelValues(subnetCfg.Network).Inc()
+			h.signalPressure(overflowCfg, p, false, "")
+			return &allocation{ip: ip, pool: p, subnetIdx: idx, subnet: overflowCfg}
+		}
+	}
+	return nil
+}
+
+// allocateFrom takes a free address from p, probing candidates first when
+// conflict detection is on. exhausted reports that p had none to offer; a
+// nil ip without it means every candidate conflicted.
+func (h *Handler) allocateFrom(ctx context.Context, p *pool.Pool, subnet string) (ip net.IP, exhausted bool) {
+	if h.detector != nil && h.cfg.ConflictDetection.Enabled {
+		candidates := p.AllocateN(h.cfg.ConflictDetection.MaxProbesPerDiscover)
+		if len(candidates) == 0 {
+			return nil, true
+		}
+
+		// Probe candidates — RFC 2131 §4.4.1
+		clearIP, err := h.detector.ProbeAndSelect(ctx, candidates, subnet)
+		if err != nil {
+			h.logger.Warn("all candidate IPs conflicted",
+				"subnet", subnet,
+				"error", err)
+			return nil, false
+		}
+
+		// Mark the selected IP as allocated
+		p.AllocateSpecific(clearIP)
+		return clearIP, false
+	}
+
+	ip = p.Allocate()
+	return ip, ip == nil
+}
+
+// overflowSubnet returns the subnet named by subnetCfg's overflow_subnet,
+// or -1 if it has none.
+func (h *Handler) overflowSubnet(subnetCfg *config.SubnetConfig) (int, *config.SubnetConfig) {
+	if subnetCfg.OverflowSubnet == "" {
+		return -1, nil
+	}
+	for i := range h.cfg.Subnets {
+		if h.cfg.Subnets[i].Network == subnetCfg.OverflowSubnet {
+			return i, &h.cfg.Subnets[i]
+		}
+	}
+	return -1, nil
+}
+
+// signalPressure publishes pool.exhausted or pool.utilisation_high when p
+// has just reached that level. overflow names where the client was sent
+// instead, if anywhere.
+func (h *Handler) signalPressure(subnetCfg *config.SubnetConfig, p *pool.Pool, exhausted bool, overflow string) {
+	high := highUtilisation(subnetCfg)
+	utilisation := p.Utilization()
+
+	level := pool.PressureNormal
+	evtType := events.EventPoolHigh
+	switch {
+	case exhausted:
+		level, evtType = pool.PressureExhausted, events.EventPoolExhausted
+	case utilisation >= high:
+		level = pool.PressureHigh
+	}
+	if !p.SignalPressure(level) {
+		return
+	}
+
+	data := &events.PoolData{
+		Subnet:      subnetCfg.Network,
+		Pool:        p.RangeString(),
+		Size:        p.Size(),
+		Allocated:   p.Allocated(),
+		Utilisation: utilisation,
+		Overflow:    overflow,
+	}
+	reason := fmt.Sprintf("%.1f%% of %d addresses in use", utilisation, p.Size())
+	if level == pool.PressureHigh {
+		data.Threshold = high
+		reason = fmt.Sprintf("%s, above %g%%", reason, high)
+		h.logger.Warn("pool utilisation high",
+			"subnet", subnetCfg.Network,
+			"pool", p.String(),
+			"threshold", high)
+	}
+	if overflow != "" {
+		reason += ", overflowing into " + overflow
+	}
+	h.bus.Publish(events.Event{
+		Type:      evtType,
+		Timestamp: time.Now(),
+		Pool:      data,
+		Reason:    reason,
+	})
+}
+
+// shortenLeaseTime applies the subnet's pressure lease_time thresholds:
+// a lease from a pool above one of them gets at most that threshold's
+// lease time.
+func (h *Handler) shortenLeaseTime(r *ResolvedOptions, subnetCfg *config.SubnetConfig, ip net.IP) {
+	if subnetCfg.Pressure == nil || len(subnetCfg.Pressure.LeaseTimes) == 0 {
+		return
+	}
+	var p *pool.Pool
+	for _, sp := range h.pools[subnetCfg.Network] {
+		if sp.Contains(ip) {
+			p = sp
+			break
+		}
+	}
+	if p == nil {
+		return
+	}
+
+	utilisation := p.Utilization()
+	shortest := r.LeaseTime
+	for _, lt := range subnetCfg.Pressure.LeaseTimes {
+		d, err := time.ParseDuration(lt.LeaseTime)
+		if err == nil && utilisation > lt.Above && d < shortest {
+			shortest = d
+		}
+	}
+	if shortest < r.LeaseTime {
+		metrics.LeasesShortened.WithLabelValues(subnetCfg.Network).Inc()
+		r.SetLeaseTime(shortest)
+	}
+}
+
+// highUtilisation returns the subnet's pool.utilisation_high threshold in percent.
+func highUtilisation(subnetCfg *config.SubnetConfig) float64 {
+	if subnetCfg.Pressure == nil || subnetCfg.Pressure.HighUtilisation == 0 {
+		return config.DefaultHighUtilisation
+	}
+	return subnetCfg.Pressure.HighUtilisation
+}
+
+// offerReclaimAfter returns how old an unanswered offer in the subnet must
+// be to be reclaimed when a pool runs out (0: never).
+func offerReclaimAfter(subnetCfg *config.SubnetConfig) time.Duration {
+	if subnetCfg.Pressure == nil || subnetCfg.Pressure.OfferReclaimAfter == "" {
+		return config.DefaultOfferReclaimAfter
+	}
+	d, _ := time.ParseDuration(subnetCfg.Pressure.OfferReclaimAfter)
+	return d
+}
@@ -0,0 +1,165 @@
+package dhcp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/radius"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// SetRADIUS sets the RADIUS client used to authorize clients on subnets
+// with RADIUS enabled.
+func (h *Handler) SetRADIUS(rc *radius.Client) {
+	h.radius = rc
+}
+
+// authorize checks a client against RADIUS when its subnet has it enabled.
+// It returns the Access-Accept (nil when RADIUS isn't in play for the subnet)
+// and false if the client must be refused. Rejects fire radius.reject.
+func (h *Handler) authorize(ctx context.Context, pkt *Packet, subnetCfg *config.SubnetConfig) (*radius.AuthResult, bool) {
+	if h.radius == nil || !h.radius.Enabled(subnetCfg.Network) {
+		return nil, true
+	}
+
+	mac := pkt.CHAddr
+	var opt82 *radius.Option82Info
+	relayInfo := GetRelayInfo(pkt)
+	if pkt.IsRelayed() || relayInfo != nil {
+		opt82 = &radius.Option82Info{}
+		if relayInfo != nil {
+			opt82.CircuitID = relayInfo.CircuitID
+			opt82.RemoteID = relayInfo.RemoteID
+		}
+		if pkt.IsRelayed() {
+			opt82.GIAddr = pkt.GIAddr.String()
+		}
+	}
+
+	result := h.radius.Authenticate(ctx, subnetCfg.Network, mac, mac.String(), opt82)
+	metrics.RADIUSAuth.WithLabelValues(subnetCfg.Network, radiusMetricResult(result)).Inc()
+
+	if result.Accepted {
+		if result.Code == "fail_open" {
+			h.logger.Warn("RADIUS unreachable, serving client (fail_open)",
+				"mac", mac.String(),
+				"subnet", subnetCfg.Network,
+				"error", result.Error)
+		}
+		return &result, true
+	}
+
+	reason := result.Code
+	if result.Error != "" {
+		reason += ": " + result.Error
+	} else if msg := result.Attrs["Reply-Message"]; msg != "" {
+		reason += ": " + msg
+	}
+
+	h.logger.Warn("RADIUS refused client",
+		"mac", mac.String(),
+		"subnet", subnetCfg.Network,
+		"reason", reason,
+		"cached", result.Cached)
+
+	evt := events.Event{
+		Type:      events.EventRadiusReject,
+		Timestamp: time.Now(),
+		Lease: &events.LeaseData{
+			MAC:      mac.String(),
+			Hostname: pkt.Hostname(),
+			Subnet:   subnetCfg.Network,
+		},
+		Reason: reason,
+	}
+	if opt82 != nil {
+		evt.Lease.Relay = &events.RelayData{
+			GIAddr:    pkt.GIAddr,
+			CircuitID: opt82.CircuitID,
+			RemoteID:  opt82.RemoteID,
+		}
+	}
+	h.bus.Publish(evt)
+	return nil, false
+}
+
+// radiusMetricResult maps an auth result to its metrics label.
+func radiusMetricResult(r radius.AuthResult) string {
+	var result string
+	switch {
+	case r.Code == "fail_open" || r.Code == "error":
+		return r.Code
+	case r.Accepted:
+		result = "accept"
+	default:
+		result = "reject"
+	}
+	if r.Cached {
+		result = "cached_" + result
+	}
+	return result
+}
+
+// radiusLayer turns the authorization attributes of an Access-Accept into an
+// option layer. Session-Timeout becomes the lease time.
+func radiusLayer(auth *radius.AuthResult) []OptionLayer {
+	if auth == nil || auth.SessionTimeout == 0 {
+		return nil
+	}
+	return []OptionLayer{{
+		Source:    LayerRADIUS,
+		LeaseTime: strconv.FormatUint(uint64(auth.SessionTimeout), 10) + "s",
+	}}
+}
+
+// radiusClass returns the Class attribute of an Access-Accept, if any.
+func radiusClass(auth *radius.AuthResult) string {
+	if auth == nil {
+		return ""
+	}
+	return auth.Class
+}
+
+// framedIP returns the Framed-IP-Address from an Access-Accept if it can be
+// handed to mac in this subnet: inside the subnet and not leased to anyone else.
+func (h *Handler) framedIP(auth *radius.AuthResult, subnetCfg *config.SubnetConfig, mac net.HardwareAddr) net.IP {
+	if auth == nil || auth.FramedIP == nil {
+		return nil
+	}
+	ip := auth.FramedIP
+	_, network, _ := net.ParseCIDR(subnetCfg.Network)
+	if network == nil || !network.Contains(ip) {
+		h.logger.Warn("RADIUS Framed-IP-Address outside subnet, ignoring",
+			"mac", mac.String(),
+			"framed_ip", ip.String(),
+			"subnet", subnetCfg.Network)
+		return nil
+	}
+	if l := h.leases.Store().GetByIP(ip); l != nil && l.MAC.String() != mac.String() && time.Now().Before(l.Expiry) &&
+		l.State != dhcpv4.LeaseStateReclaimed {
+		h.logger.Warn("RADIUS Framed-IP-Address leased to another client, ignoring",
+			"mac", mac.String(),
+			"framed_ip", ip.String(),
+			"holder", l.MAC.String())
+		return nil
+	}
+	return ip
+}
+
+// claimPoolIP marks ip as allocated in whichever pool of the subnet contains
+// it and returns that pool's range, or "" if the IP is outside every pool.
+func claimPoolIP(pools []*pool.Pool, ip net.IP) string {
+	for _, p := range pools {
+		if p.Contains(ip) {
+			p.AllocateSpecific(ip)
+			return p.RangeString()
+		}
+	}
+	return ""
+}
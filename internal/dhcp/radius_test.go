@@ -0,0 +1,192 @@
+package dhcp
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/pool"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/radius"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+	layeh "layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// radiusTestHandler builds a handler for 10.0.0.0/24 with two pools — a
+// default one and one for RADIUS class "staff" — and a RADIUS stand-in on a
+// local UDP port answering with reply.
+func radiusTestHandler(t *testing.T, reply func(r *layeh.Request) *layeh.Packet) (*Handler, chan events.Event) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &layeh.PacketServer{
+		SecretSource: layeh.StaticSecretSource([]byte("s3cret")),
+		Handler: layeh.HandlerFunc(func(w layeh.ResponseWriter, r *layeh.Request) {
+			w.Write(reply(r))
+		}),
+	}
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+	sub := bus.Subscribe(100)
+
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{ServerID: "10.0.0.1"},
+		Subnets: []config.SubnetConfig{{
+			Network:   "10.0.0.0/24",
+			Routers:   []string{"10.0.0.1"},
+			LeaseTime: "1h",
+			Pools: []config.PoolConfig{
+				{RangeStart: "10.0.0.100", RangeEnd: "10.0.0.149"},
+				{RangeStart: "10.0.0.150", RangeEnd: "10.0.0.199", MatchRadiusClass: "staff"},
+			},
+		}},
+	}
+	_, network, _ := net.ParseCIDR("10.0.0.0/24")
+	var pools []*pool.Pool
+	for _, pc := range cfg.Subnets[0].Pools {
+		p, err := pool.NewPool(pc.RangeStart, net.ParseIP(pc.RangeStart), net.ParseIP(pc.RangeEnd), network)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		p.MatchRadiusClass = pc.MatchRadiusClass
+		pools = append(pools, p)
+	}
+
+	leases := lease.NewManager(store, cfg, bus, logger)
+	h := NewHandler(cfg, leases, map[string][]*pool.Pool{"10.0.0.0/24": pools}, nil, bus, logger)
+
+	rc := radius.NewClient(logger)
+	rc.SetSubnet("10.0.0.0/24", &radius.SubnetConfig{
+		Enabled: true,
+		Server:  radius.ServerConfig{Address: conn.LocalAddr().String(), Secret: "s3cret", Timeout: "2s"},
+	})
+	h.SetRADIUS(rc)
+	return h, sub
+}
+
+func radiusTestPacket(msgType dhcpv4.MessageType) *Packet {
+	return &Packet{
+		Op:     dhcpv4.OpCodeBootRequest,
+		HType:  1,
+		HLen:   6,
+		XID:    0x1234,
+		CIAddr: net.IPv4zero,
+		GIAddr: net.IPv4(10, 0, 0, 1).To4(),
+		CHAddr: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01},
+		Options: Options{
+			dhcpv4.OptionDHCPMessageType: {byte(msgType)},
+			dhcpv4.OptionRelayAgentInfo:  EncodeRelayAgentInfo(&RelayAgentInfo{CircuitID: "eth0/1/3"}),
+		},
+	}
+}
+
+func waitForEvent(t *testing.T, ch chan events.Event, typ events.EventType) events.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case evt := <-ch:
+			if evt.Type == typ {
+				return evt
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", typ)
+			return events.Event{}
+		}
+	}
+}
+
+func TestDiscoverRADIUSAcceptAttributes(t *testing.T) {
+	h, _ := radiusTestHandler(t, func(r *layeh.Request) *layeh.Packet {
+		resp := r.Response(layeh.CodeAccessAccept)
+		rfc2865.FramedIPAddress_Set(resp, net.IPv4(10, 0, 0, 42))
+		rfc2865.SessionTimeout_Set(resp, 600)
+		return resp
+	})
+
+	reply, err := h.HandlePacket(context.Background(), radiusTestPacket(dhcpv4.MessageTypeDiscover), nil)
+	if err != nil || reply == nil {
+		t.Fatalf("expected OFFER, got %v, %v", reply, err)
+	}
+	if !reply.YIAddr.Equal(net.IPv4(10, 0, 0, 42)) {
+		t.Errorf("yiaddr = %v, want Framed-IP-Address 10.0.0.42", reply.YIAddr)
+	}
+	if lt, _ := dhcpv4.BytesToUint32(reply.Options[dhcpv4.OptionIPLeaseTime]); lt != 600 {
+		t.Errorf("lease time = %d, want Session-Timeout 600", lt)
+	}
+
+	// A REQUEST for anything but the framed address is refused.
+	req := radiusTestPacket(dhcpv4.MessageTypeRequest)
+	req.Options[dhcpv4.OptionRequestedIP] = net.IPv4(10, 0, 0, 43).To4()
+	nak, _ := h.HandlePacket(context.Background(), req, nil)
+	if nak == nil || nak.MessageType() != dhcpv4.MessageTypeNak {
+		t.Fatalf("expected NAK for non-framed address, got %v", nak)
+	}
+}
+
+func TestDiscoverRADIUSClassSelectsPool(t *testing.T) {
+	h, _ := radiusTestHandler(t, func(r *layeh.Request) *layeh.Packet {
+		resp := r.Response(layeh.CodeAccessAccept)
+		rfc2865.Class_SetString(resp, "staff")
+		return resp
+	})
+
+	reply, err := h.HandlePacket(context.Background(), radiusTestPacket(dhcpv4.MessageTypeDiscover), nil)
+	if err != nil || reply == nil {
+		t.Fatalf("expected OFFER, got %v, %v", reply, err)
+	}
+	if ip := reply.YIAddr.To4(); ip[3] < 150 || ip[3] > 199 {
+		t.Errorf("yiaddr = %v, want an address from the staff pool", reply.YIAddr)
+	}
+	if lt, _ := dhcpv4.BytesToUint32(reply.Options[dhcpv4.OptionIPLeaseTime]); lt != 3600 {
+		t.Errorf("lease time = %d, want subnet default 3600", lt)
+	}
+}
+
+func TestRADIUSRejectDropsAndNAKs(t *testing.T) {
+	h, sub := radiusTestHandler(t, func(r *layeh.Request) *layeh.Packet {
+		resp := r.Response(layeh.CodeAccessReject)
+		rfc2865.ReplyMessage_SetString(resp, "unknown device")
+		return resp
+	})
+
+	reply, err := h.HandlePacket(context.Background(), radiusTestPacket(dhcpv4.MessageTypeDiscover), nil)
+	if err != nil || reply != nil {
+		t.Fatalf("rejected DISCOVER should be dropped, got %v, %v", reply, err)
+	}
+	evt := waitForEvent(t, sub, events.EventRadiusReject)
+	if evt.Lease == nil || evt.Lease.Subnet != "10.0.0.0/24" || evt.Lease.Relay == nil || evt.Lease.Relay.CircuitID != "eth0/1/3" {
+		t.Errorf("reject event = %+v", evt.Lease)
+	}
+	if evt.Reason != "Access-Reject: unknown device" {
+		t.Errorf("reason = %q", evt.Reason)
+	}
+
+	req := radiusTestPacket(dhcpv4.MessageTypeRequest)
+	req.Options[dhcpv4.OptionRequestedIP] = net.IPv4(10, 0, 0, 100).To4()
+	nak, _ := h.HandlePacket(context.Background(), req, nil)
+	if nak == nil || nak.MessageType() != dhcpv4.MessageTypeNak {
+		t.Fatalf("expected NAK for rejected client, got %v", nak)
+	}
+}
@@ -11,6 +11,7 @@ type RelayAgentInfo struct {
 	CircuitID  string
 	RemoteID   string
 	LinkSelect []byte // RFC 3527 sub-option 5
+	RelayID    []byte // RFC 6925 sub-option 12
 	Raw        []byte
 }
 
@@ -40,6 +41,9 @@ func ParseRelayAgentInfo(data []byte) (*RelayAgentInfo, error) {
 		case dhcpv4.RelaySubOptionLinkSelect:
 			info.LinkSelect = make([]byte, len(subData))
 			copy(info.LinkSelect, subData)
+		case dhcpv4.RelaySubOptionRelayID:
+			info.RelayID = make([]byte, len(subData))
+			copy(info.RelayID, subData)
 		}
 	}
 	return info, nil
@@ -63,6 +67,11 @@ func EncodeRelayAgentInfo(info *RelayAgentInfo) []byte {
 		buf = append(buf, byte(len(info.LinkSelect)))
 		buf = append(buf, info.LinkSelect...)
 	}
+	if len(info.RelayID) > 0 {
+		buf = append(buf, dhcpv4.RelaySubOptionRelayID)
+		buf = append(buf, byte(len(info.RelayID)))
+		buf = append(buf, info.RelayID...)
+	}
 	return buf
 }
 
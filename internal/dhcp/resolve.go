@@ -0,0 +1,227 @@
+package dhcp
+
+import (
+	"net"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// Option layer names, in precedence order (later layers win).
+const (
+	LayerDefaults    = "defaults"
+	LayerSubnet      = "subnet"
+	LayerPool        = "pool"
+	LayerClass       = "class"
+	LayerReservation = "reservation"
+	LayerRADIUS      = "radius"
+)
+
+// OptionLayer is one level of the option precedence chain:
+// global defaults → subnet → pool → client class → reservation.
+// Empty fields leave the value from earlier layers untouched.
+type OptionLayer struct {
+	Source      string
+	Routers     []string
+	DNSServers  []string
+	NTPServers  []string
+	DomainName  string
+	Hostname    string
+	LeaseTime   string
+	RenewalTime string
+	RebindTime  string
+	NextServer  string
+	BootFile    string
+	Options     []config.OptionConfig
+}
+
+// ResolvedOptions is the effective option set for one client, with the
+// layer each value came from.
+type ResolvedOptions struct {
+	Options     Options
+	Sources     map[dhcpv4.OptionCode]string
+	LeaseTime   time.Duration
+	RenewalTime time.Duration
+	RebindTime  time.Duration
+	LeaseSource string
+	NextServer  net.IP
+	BootFile    string
+	FileSource  string
+}
+
+// OptionLayers builds the precedence chain for a client in a subnet.
+// pool and res may be nil; classes are applied in the order given.
+func OptionLayers(cfg *config.Config, sub *config.SubnetConfig, pool *config.PoolConfig,
+	classes []OptionLayer, res *config.ReservationConfig) []OptionLayer {
+
+	layers := []OptionLayer{
+		{
+			Source:      LayerDefaults,
+			DNSServers:  cfg.Defaults.DNSServers,
+			DomainName:  cfg.Defaults.DomainName,
+			LeaseTime:   cfg.Defaults.LeaseTime,
+			RenewalTime: cfg.Defaults.RenewalTime,
+			RebindTime:  cfg.Defaults.RebindTime,
+		},
+		{
+			Source:      LayerSubnet,
+			Routers:     sub.Routers,
+			DNSServers:  sub.DNSServers,
+			NTPServers:  sub.NTPServers,
+			DomainName:  sub.DomainName,
+			LeaseTime:   sub.LeaseTime,
+			RenewalTime: sub.RenewalTime,
+			RebindTime:  sub.RebindTime,
+			NextServer:  sub.NextServer,
+			BootFile:    sub.BootFile,
+			Options:     sub.Options,
+		},
+	}
+	if pool != nil {
+		layers = append(layers, OptionLayer{
+			Source:     LayerPool,
+			Routers:    pool.Routers,
+			DNSServers: pool.DNSServers,
+			NTPServers: pool.NTPServers,
+			DomainName: pool.DomainName,
+			LeaseTime:  pool.LeaseTime,
+			NextServer: pool.NextServer,
+			BootFile:   pool.BootFile,
+			Options:    pool.Options,
+		})
+	}
+	layers = append(layers, classes...)
+	if res != nil {
+		layers = append(layers, OptionLayer{
+			Source:     LayerReservation,
+			Routers:    res.Routers,
+			DNSServers: res.DNSServers,
+			NTPServers: res.NTPServers,
+			DomainName: res.DomainName,
+			Hostname:   res.Hostname,
+			LeaseTime:  res.LeaseTime,
+			NextServer: res.NextServer,
+			BootFile:   res.BootFile,
+			Options:    res.Options,
+		})
+	}
+	return layers
+}
+
+// ResolveOptions applies layers in order on top of the subnet-derived
+// options (mask, broadcast). Invalid values are skipped — config load and the
+// API reject them before they get here.
+func ResolveOptions(network *net.IPNet, layers []OptionLayer) *ResolvedOptions {
+	r := &ResolvedOptions{
+		Options:     make(Options),
+		Sources:     make(map[dhcpv4.OptionCode]string),
+		LeaseTime:   config.DefaultLeaseTime,
+		RenewalTime: config.DefaultRenewalTime,
+		RebindTime:  config.DefaultRebindTime,
+		LeaseSource: "builtin",
+	}
+
+	if network != nil {
+		r.set(dhcpv4.OptionSubnetMask, []byte(network.Mask), LayerSubnet)
+		broadcastIP := dhcpv4.Uint32ToIP(dhcpv4.IPToUint32(network.IP) | ^dhcpv4.IPToUint32(net.IP(network.Mask)))
+		r.set(dhcpv4.OptionBroadcastAddress, dhcpv4.IPToBytes(broadcastIP), LayerSubnet)
+	}
+
+	for _, l := range layers {
+		if ips := parseIPList(l.Routers); len(ips) > 0 {
+			r.set(dhcpv4.OptionRouter, dhcpv4.IPListToBytes(ips), l.Source)
+		}
+		if ips := parseIPList(l.DNSServers); len(ips) > 0 {
+			r.set(dhcpv4.OptionDomainNameServer, dhcpv4.IPListToBytes(ips), l.Source)
+		}
+		if ips := parseIPList(l.NTPServers); len(ips) > 0 {
+			r.set(dhcpv4.OptionNTPServers, dhcpv4.IPListToBytes(ips), l.Source)
+		}
+		if l.DomainName != "" {
+			r.set(dhcpv4.OptionDomainName, []byte(l.DomainName), l.Source)
+		}
+		if l.Hostname != "" {
+			r.set(dhcpv4.OptionHostname, []byte(l.Hostname), l.Source)
+		}
+		if d, err := time.ParseDuration(l.LeaseTime); err == nil && l.LeaseTime != "" {
+			r.LeaseTime = d
+			r.LeaseSource = l.Source
+		}
+		if d, err := time.ParseDuration(l.RenewalTime); err == nil && l.RenewalTime != "" {
+			r.RenewalTime = d
+		}
+		if d, err := time.ParseDuration(l.RebindTime); err == nil && l.RebindTime != "" {
+			r.RebindTime = d
+		}
+		if ip := net.ParseIP(l.NextServer).To4(); ip != nil {
+			r.NextServer = ip
+		}
+		if l.BootFile != "" {
+			r.BootFile = l.BootFile
+			r.FileSource = l.Source
+		}
+		for _, oc := range l.Options {
+			code, data, err := EncodeOptionConfig(oc)
+			if err != nil {
+				continue
+			}
+			r.set(code, data, l.Source)
+		}
+	}
+
+	r.fixTimers()
+	return r
+}
+
+// SetLeaseTime replaces the lease time, pulling T1 and T2 back inside it.
+func (r *ResolvedOptions) SetLeaseTime(d time.Duration) {
+	r.LeaseTime = d
+	r.fixTimers()
+}
+
+// fixTimers applies RFC 2131 §4.4.5 — T1 defaults to 0.5 and T2 to 0.875
+// of the lease. Fall back to those when a shortened lease leaves the
+// configured timers outside it.
+func (r *ResolvedOptions) fixTimers() {
+	if r.RenewalTime >= r.LeaseTime || r.RebindTime >= r.LeaseTime || r.RenewalTime >= r.RebindTime {
+		r.RenewalTime = r.LeaseTime / 2
+		r.RebindTime = r.LeaseTime * 7 / 8
+	}
+}
+
+// set records an option value and the layer it came from.
+func (r *ResolvedOptions) set(code dhcpv4.OptionCode, data []byte, source string) {
+	r.Options[code] = data
+	r.Sources[code] = source
+}
+
+// parseIPList parses IPv4 address strings, skipping invalid entries.
+func parseIPList(list []string) []net.IP {
+	var ips []net.IP
+	for _, s := range list {
+		if ip := net.ParseIP(s).To4(); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// FindPoolConfig returns the pool config whose range contains ip, or nil.
+func FindPoolConfig(sub *config.SubnetConfig, ip net.IP) *config.PoolConfig {
+	if ip == nil {
+		return nil
+	}
+	target := dhcpv4.IPToUint32(ip)
+	for i := range sub.Pools {
+		start := net.ParseIP(sub.Pools[i].RangeStart)
+		end := net.ParseIP(sub.Pools[i].RangeEnd)
+		if start == nil || end == nil {
+			continue
+		}
+		if target >= dhcpv4.IPToUint32(start) && target <= dhcpv4.IPToUint32(end) {
+			return &sub.Pools[i]
+		}
+	}
+	return nil
+}
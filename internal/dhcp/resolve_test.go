@@ -0,0 +1,194 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+func resolveTestConfig() *config.Config {
+	return &config.Config{
+		Defaults: config.DefaultsConfig{
+			LeaseTime:   "8h",
+			RenewalTime: "4h",
+			RebindTime:  "7h",
+			DNSServers:  []string{"8.8.8.8"},
+			DomainName:  "default.lan",
+		},
+		Subnets: []config.SubnetConfig{
+			{
+				Network:    "192.168.1.0/24",
+				Routers:    []string{"192.168.1.1"},
+				DNSServers: []string{"192.168.1.1"},
+				NextServer: "192.168.1.5",
+				Pools: []config.PoolConfig{
+					{RangeStart: "192.168.1.100", RangeEnd: "192.168.1.149"},
+					{
+						RangeStart: "192.168.1.150",
+						RangeEnd:   "192.168.1.199",
+						Routers:    []string{"192.168.1.254"},
+						LeaseTime:  "1h",
+						BootFile:   "pool.efi",
+					},
+				},
+				Reservations: []config.ReservationConfig{
+					{
+						MAC:        "00:11:22:33:44:55",
+						IP:         "192.168.1.160",
+						Hostname:   "printer",
+						DNSServers: []string{"1.1.1.1"},
+						BootFile:   "printer.bin",
+						Options: []config.OptionConfig{
+							{Code: 66, Type: "string", Value: "tftp.example.com"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestResolveOptionsPrecedence(t *testing.T) {
+	cfg := resolveTestConfig()
+	sub := &cfg.Subnets[0]
+	_, network, _ := net.ParseCIDR(sub.Network)
+	ip := net.ParseIP("192.168.1.160")
+
+	tests := []struct {
+		name       string
+		pool       *config.PoolConfig
+		res        *config.ReservationConfig
+		wantRouter net.IP
+		wantDNS    net.IP
+		wantLease  time.Duration
+		wantSource map[dhcpv4.OptionCode]string
+		wantFile   string
+	}{
+		{
+			name:       "subnet only",
+			wantRouter: net.IPv4(192, 168, 1, 1),
+			wantDNS:    net.IPv4(192, 168, 1, 1),
+			wantLease:  8 * time.Hour,
+			wantSource: map[dhcpv4.OptionCode]string{
+				dhcpv4.OptionRouter:     LayerSubnet,
+				dhcpv4.OptionDomainName: LayerDefaults,
+			},
+		},
+		{
+			name:       "pool overrides subnet",
+			pool:       FindPoolConfig(sub, ip),
+			wantRouter: net.IPv4(192, 168, 1, 254),
+			wantDNS:    net.IPv4(192, 168, 1, 1),
+			wantLease:  time.Hour,
+			wantSource: map[dhcpv4.OptionCode]string{
+				dhcpv4.OptionRouter:           LayerPool,
+				dhcpv4.OptionDomainNameServer: LayerSubnet,
+			},
+			wantFile: "pool.efi",
+		},
+		{
+			name:       "reservation overrides pool",
+			pool:       FindPoolConfig(sub, ip),
+			res:        &sub.Reservations[0],
+			wantRouter: net.IPv4(192, 168, 1, 254),
+			wantDNS:    net.IPv4(1, 1, 1, 1),
+			wantLease:  time.Hour,
+			wantSource: map[dhcpv4.OptionCode]string{
+				dhcpv4.OptionDomainNameServer: LayerReservation,
+				dhcpv4.OptionHostname:         LayerReservation,
+				dhcpv4.OptionTFTPServerName:   LayerReservation,
+			},
+			wantFile: "printer.bin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ResolveOptions(network, OptionLayers(cfg, sub, tt.pool, nil, tt.res))
+
+			if got := net.IP(r.Options[dhcpv4.OptionRouter]); !got.Equal(tt.wantRouter) {
+				t.Errorf("router = %s, want %s", got, tt.wantRouter)
+			}
+			if got := net.IP(r.Options[dhcpv4.OptionDomainNameServer]); !got.Equal(tt.wantDNS) {
+				t.Errorf("dns = %s, want %s", got, tt.wantDNS)
+			}
+			if r.LeaseTime != tt.wantLease {
+				t.Errorf("lease = %v, want %v", r.LeaseTime, tt.wantLease)
+			}
+			for code, want := range tt.wantSource {
+				if got := r.Sources[code]; got != want {
+					t.Errorf("source of option %d = %q, want %q", code, got, want)
+				}
+			}
+			if r.BootFile != tt.wantFile {
+				t.Errorf("boot file = %q, want %q", r.BootFile, tt.wantFile)
+			}
+			if !r.NextServer.Equal(net.IPv4(192, 168, 1, 5)) {
+				t.Errorf("next server = %s, want 192.168.1.5", r.NextServer)
+			}
+			if got := net.IP(r.Options[dhcpv4.OptionBroadcastAddress]); !got.Equal(net.IPv4(192, 168, 1, 255)) {
+				t.Errorf("broadcast = %s", got)
+			}
+		})
+	}
+}
+
+func TestResolveOptionsClassLayer(t *testing.T) {
+	cfg := resolveTestConfig()
+	sub := &cfg.Subnets[0]
+	class := OptionLayer{Source: LayerClass, DNSServers: []string{"9.9.9.9"}, DomainName: "class.lan"}
+
+	r := ResolveOptions(nil, OptionLayers(cfg, sub, nil, []OptionLayer{class}, &sub.Reservations[0]))
+	if got := string(r.Options[dhcpv4.OptionDomainName]); got != "class.lan" {
+		t.Errorf("domain = %q, want class.lan", got)
+	}
+	if r.Sources[dhcpv4.OptionDomainNameServer] != LayerReservation {
+		t.Errorf("reservation DNS should win over class, got source %q", r.Sources[dhcpv4.OptionDomainNameServer])
+	}
+}
+
+func TestResolveOptionsTimerFallback(t *testing.T) {
+	cfg := resolveTestConfig()
+	sub := &cfg.Subnets[0]
+
+	// Subnet lease keeps the configured timers.
+	r := ResolveOptions(nil, OptionLayers(cfg, sub, nil, nil, nil))
+	if r.RenewalTime != 4*time.Hour || r.RebindTime != 7*time.Hour {
+		t.Errorf("timers = %v/%v, want 4h/7h", r.RenewalTime, r.RebindTime)
+	}
+
+	// A 1h pool lease can't use 4h/7h timers — fall back to 0.5 / 0.875.
+	r = ResolveOptions(nil, OptionLayers(cfg, sub, &sub.Pools[1], nil, nil))
+	if r.RenewalTime != 30*time.Minute || r.RebindTime != 52*time.Minute+30*time.Second {
+		t.Errorf("timers = %v/%v, want 30m/52m30s", r.RenewalTime, r.RebindTime)
+	}
+}
+
+func TestFindPoolConfig(t *testing.T) {
+	sub := &resolveTestConfig().Subnets[0]
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.168.1.100", "192.168.1.100"},
+		{"192.168.1.199", "192.168.1.150"},
+		{"192.168.1.10", ""},
+	}
+	for _, tt := range tests {
+		p := FindPoolConfig(sub, net.ParseIP(tt.ip))
+		got := ""
+		if p != nil {
+			got = p.RangeStart
+		}
+		if got != tt.want {
+			t.Errorf("FindPoolConfig(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+	if FindPoolConfig(sub, nil) != nil {
+		t.Error("FindPoolConfig(nil) should return nil")
+	}
+}
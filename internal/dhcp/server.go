@@ -184,6 +184,13 @@ func (s *Server) processPacket(ctx context.Context, data []byte, src *net.UDPAdd
 		return
 	}
 
+	if len(reply.Dropped) > 0 {
+		s.logger.Warn("reply exceeded client max message size, options dropped",
+			"mac", pkt.CHAddr.String(),
+			"max_size", reply.MaxSize,
+			"dropped", reply.Dropped)
+	}
+
 	// Determine destination address
 	dst := s.getReplyDestination(pkt, src)
 
@@ -0,0 +1,472 @@
+package dhcp6
+
+import (
+	"context"
+	"hash/fnv"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
+)
+
+// maxProbes bounds the linear probe through an address range or PD pool
+// after the hashed starting point is taken. IPv6 ranges are usually far too
+// large to scan; a client that hits this many collisions gets NoAddrsAvail.
+const maxProbes = 4096
+
+// assignIAs answers every IA_NA and IA_PD in a Solicit or Request. Bindings
+// are written to the lease store only when commit is true (Request, or
+// Solicit with Rapid Commit); an Advertise merely proposes them.
+func (h *Handler) assignIAs(ctx context.Context, req *request, idx int, sub *config.Subnet6Config, reply *dhcpv6.Message, commit bool) {
+	duid := dhcpv6.FormatDUID(req.msg.Options.Get(dhcpv6.OptionClientID))
+	res := findReservation(sub, duid)
+	hostname, fqdn := clientHostname(req.msg)
+	if res != nil && res.Hostname != "" {
+		hostname, fqdn = res.Hostname, ""
+	}
+	preferred, valid, t1, t2 := h.cfg.GetLifetimes6(idx)
+
+	for _, prefix := range []bool{false, true} {
+		code := dhcpv6.OptionIANA
+		if prefix {
+			code = dhcpv6.OptionIAPD
+		}
+		for _, data := range req.msg.Options.GetAll(code) {
+			ia, err := dhcpv6.DecodeIA(data)
+			if err != nil {
+				h.logger.Debug("dropping malformed IA", "duid", duid, "error", err)
+				continue
+			}
+			out := &dhcpv6.IA{IAID: ia.IAID, T1: seconds(t1), T2: seconds(t2)}
+
+			var ip net.IP
+			var plen int
+			var pool string
+			switch {
+			case res == nil && h.renewalsOnly() && !h.hasBinding(sub, duid, ia.IAID, prefix):
+				// Only existing bindings while HA communications are interrupted
+			case prefix:
+				ip, plen, pool = h.pickPrefix(sub, duid, ia, res)
+			default:
+				ip, pool = h.pickAddress(sub, duid, ia, res)
+			}
+			if ip == nil {
+				out.T1, out.T2 = 0, 0
+				if prefix {
+					out.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusNoPrefixAvail, "no prefixes available"))
+				} else {
+					out.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusNoAddrsAvail, "no addresses available"))
+				}
+				if !reserved(res, prefix) {
+					h.logger.Warn("DHCPv6 pool exhausted",
+						"subnet", sub.Network,
+						"duid", duid,
+						"iaid", ia.IAID,
+						"prefix_delegation", prefix)
+				}
+				reply.Options.Add(code, out.Encode())
+				continue
+			}
+
+			if commit {
+				tmpl := &lease.Lease{
+					IP:        ip,
+					MAC:       req.clientMAC(),
+					DUID:      duid,
+					IAID:      ia.IAID,
+					PrefixLen: plen,
+					Hostname:  hostname,
+					FQDN:      fqdn,
+					Subnet:    sub.Network,
+					Pool:      pool,
+					RelayInfo: req.relayInfo(),
+				}
+				if _, err := h.leases.ConfirmLease6(tmpl, valid); err != nil {
+					h.logger.Error("failed to store DHCPv6 lease", "ip", ip.String(), "error", err)
+					out.T1, out.T2 = 0, 0
+					out.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusUnspecFail, "lease store error"))
+					reply.Options.Add(code, out.Encode())
+					continue
+				}
+			}
+			out.Options.Add(bindingOption(ip, plen, preferred, valid))
+			reply.Options.Add(code, out.Encode())
+		}
+	}
+}
+
+// extendIAs answers a Renew or Rebind: known bindings get fresh lifetimes,
+// addresses the client holds but no longer owns get zero lifetimes, and
+// unknown IAs get NoBinding (RFC 8415 §18.3.4).
+func (h *Handler) extendIAs(req *request, idx int, sub *config.Subnet6Config, reply *dhcpv6.Message) {
+	duid := dhcpv6.FormatDUID(req.msg.Options.Get(dhcpv6.OptionClientID))
+	preferred, valid, t1, t2 := h.cfg.GetLifetimes6(idx)
+
+	for _, prefix := range []bool{false, true} {
+		code, inner := dhcpv6.OptionIANA, dhcpv6.OptionIAAddr
+		if prefix {
+			code, inner = dhcpv6.OptionIAPD, dhcpv6.OptionIAPrefix
+		}
+		for _, data := range req.msg.Options.GetAll(code) {
+			ia, err := dhcpv6.DecodeIA(data)
+			if err != nil {
+				continue
+			}
+			out := &dhcpv6.IA{IAID: ia.IAID, T1: seconds(t1), T2: seconds(t2)}
+
+			l := h.leases.FindLease6(duid, ia.IAID, prefix)
+			if l == nil || l.Subnet != sub.Network {
+				out.T1, out.T2 = 0, 0
+				out.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusNoBinding, "no binding for IA"))
+				reply.Options.Add(code, out.Encode())
+				continue
+			}
+			l.RelayInfo = req.relayInfo()
+			if _, err := h.leases.ConfirmLease6(l, valid); err != nil {
+				h.logger.Error("failed to extend DHCPv6 lease", "ip", l.IP.String(), "error", err)
+				out.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusUnspecFail, "lease store error"))
+				reply.Options.Add(code, out.Encode())
+				continue
+			}
+			out.Options.Add(bindingOption(l.IP, l.PrefixLen, preferred, valid))
+
+			// Anything else the client listed is no longer valid.
+			for _, held := range ia.Options.GetAll(inner) {
+				ip, plen := decodeBinding(held, prefix)
+				if ip != nil && !ip.Equal(l.IP) {
+					out.Options.Add(bindingOption(ip, plen, 0, 0))
+				}
+			}
+			reply.Options.Add(code, out.Encode())
+		}
+	}
+}
+
+// releaseIAs removes the client's bindings listed in a Release or Decline.
+// Declined addresses go through the lease manager's decline path.
+func (h *Handler) releaseIAs(req *request, decline bool) {
+	duid := dhcpv6.FormatDUID(req.msg.Options.Get(dhcpv6.OptionClientID))
+	for _, prefix := range []bool{false, true} {
+		code, inner := dhcpv6.OptionIANA, dhcpv6.OptionIAAddr
+		if prefix {
+			code, inner = dhcpv6.OptionIAPD, dhcpv6.OptionIAPrefix
+		}
+		for _, data := range req.msg.Options.GetAll(code) {
+			ia, err := dhcpv6.DecodeIA(data)
+			if err != nil {
+				continue
+			}
+			for _, held := range ia.Options.GetAll(inner) {
+				ip, _ := decodeBinding(held, prefix)
+				if ip == nil {
+					continue
+				}
+				l := h.leases.Store().GetByIP(ip)
+				if l == nil || l.DUID != duid {
+					continue
+				}
+				if decline && !prefix {
+					err = h.leases.Decline(ip, l.MAC)
+				} else {
+					err = h.leases.Release(ip, l.MAC)
+				}
+				if err != nil {
+					h.logger.Error("failed to remove DHCPv6 lease", "ip", ip.String(), "error", err)
+				}
+			}
+		}
+	}
+}
+
+// pickAddress chooses an IA_NA address: the DUID reservation, the client's
+// current binding, the address it asked for, then a hashed pool slot. A
+// reserved address still leased to another client is not handed out.
+func (h *Handler) pickAddress(sub *config.Subnet6Config, duid string, ia *dhcpv6.IA, res *config.Reservation6Config) (net.IP, string) {
+	free := func(ip net.IP) bool { return h.available(sub, ip, duid, ia.IAID) }
+	if res != nil && res.IP != "" {
+		ip := net.ParseIP(res.IP)
+		if !free(ip) {
+			h.reservationConflict(sub, ip, duid, ia.IAID)
+			return nil, ""
+		}
+		return ip, ""
+	}
+
+	if l := h.leases.FindLease6(duid, ia.IAID, false); l != nil && l.Subnet == sub.Network {
+		if pool := poolFor(sub, l.IP); pool != "" && free(l.IP) {
+			return l.IP, pool
+		}
+	}
+	for _, held := range ia.Options.GetAll(dhcpv6.OptionIAAddr) {
+		ip, _ := decodeBinding(held, false)
+		if pool := poolFor(sub, ip); pool != "" && free(ip) {
+			return ip, pool
+		}
+	}
+
+	seed := clientHash(duid, ia.IAID, false)
+	for _, p := range sub.Pools {
+		start := new(big.Int).SetBytes(net.ParseIP(p.RangeStart).To16())
+		end := new(big.Int).SetBytes(net.ParseIP(p.RangeEnd).To16())
+		size := new(big.Int).Sub(end, start)
+		size.Add(size, big.NewInt(1))
+		if size.Sign() <= 0 {
+			continue
+		}
+		var found net.IP
+		probe(size, seed, func(i *big.Int) bool {
+			ip := bigToIP(new(big.Int).Add(start, i))
+			if free(ip) {
+				found = ip
+				return true
+			}
+			return false
+		})
+		if found != nil {
+			return found, p.RangeStart + "-" + p.RangeEnd
+		}
+	}
+	return nil, ""
+}
+
+// pickPrefix chooses an IA_PD prefix: the DUID reservation, the client's
+// current delegation, then a hashed slot in a PD pool. A reserved prefix
+// still delegated to another client is not handed out.
+func (h *Handler) pickPrefix(sub *config.Subnet6Config, duid string, ia *dhcpv6.IA, res *config.Reservation6Config) (net.IP, int, string) {
+	free := func(ip net.IP) bool { return h.available(sub, ip, duid, ia.IAID) }
+	if res != nil && res.Prefix != "" {
+		if _, p, err := net.ParseCIDR(res.Prefix); err == nil {
+			if !free(p.IP) {
+				h.reservationConflict(sub, p.IP, duid, ia.IAID)
+				return nil, 0, ""
+			}
+			ones, _ := p.Mask.Size()
+			return p.IP, ones, ""
+		}
+	}
+
+	if l := h.leases.FindLease6(duid, ia.IAID, true); l != nil && l.Subnet == sub.Network && free(l.IP) {
+		return l.IP, l.PrefixLen, l.Pool
+	}
+
+	seed := clientHash(duid, ia.IAID, true)
+	for _, pd := range sub.PDPools {
+		_, base, err := net.ParseCIDR(pd.Prefix)
+		if err != nil {
+			continue
+		}
+		ones, _ := base.Mask.Size()
+		if pd.DelegatedLength < ones || pd.DelegatedLength > 128 {
+			continue
+		}
+		start := new(big.Int).SetBytes(base.IP.To16())
+		count := new(big.Int).Lsh(big.NewInt(1), uint(pd.DelegatedLength-ones))
+		shift := uint(128 - pd.DelegatedLength)
+		var found net.IP
+		probe(count, seed, func(i *big.Int) bool {
+			ip := bigToIP(new(big.Int).Add(start, new(big.Int).Lsh(i, shift)))
+			if free(ip) {
+				found = ip
+				return true
+			}
+			return false
+		})
+		if found != nil {
+			return found, pd.DelegatedLength, pd.Prefix
+		}
+	}
+	return nil, 0, ""
+}
+
+// available reports whether ip can be bound to the client's IA: not leased
+// to anyone else and not reserved for another DUID.
+func (h *Handler) available(sub *config.Subnet6Config, ip net.IP, duid string, iaid uint32) bool {
+	if l := h.leases.Store().GetByIP(ip); l != nil && !l.IsExpired() && (l.DUID != duid || l.IAID != iaid) {
+		return false
+	}
+	for _, res := range sub.Reservations {
+		if canonicalDUID(res.DUID) == duid {
+			continue
+		}
+		if res.IP != "" && net.ParseIP(res.IP).Equal(ip) {
+			return false
+		}
+		if res.Prefix != "" {
+			if rip, _, err := net.ParseCIDR(res.Prefix); err == nil && rip.Equal(ip) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// reservationConflict logs a reserved address or prefix that is still bound
+// to another client, typically one leased before the reservation was added.
+func (h *Handler) reservationConflict(sub *config.Subnet6Config, ip net.IP, duid string, iaid uint32) {
+	attrs := []any{"subnet", sub.Network, "ip", ip.String(), "duid", duid, "iaid", iaid}
+	if l := h.leases.Store().GetByIP(ip); l != nil {
+		attrs = append(attrs, "held_by", l.DUID, "held_iaid", l.IAID, "expires", l.Expiry)
+	}
+	h.logger.Warn("reserved DHCPv6 binding held by another client", attrs...)
+}
+
+// reserved reports whether res reserves an address (or, for prefix, a
+// delegated prefix) for the client.
+func reserved(res *config.Reservation6Config, prefix bool) bool {
+	if res == nil {
+		return false
+	}
+	if prefix {
+		return res.Prefix != ""
+	}
+	return res.IP != ""
+}
+
+// renewalsOnly reports whether only existing bindings may be served because
+// HA communications with the peer are interrupted.
+func (h *Handler) renewalsOnly() bool {
+	return h.ha != nil && h.ha.RenewalsOnly()
+}
+
+// hasBinding reports whether the client holds a binding for the IA in sub.
+func (h *Handler) hasBinding(sub *config.Subnet6Config, duid string, iaid uint32, prefix bool) bool {
+	l := h.leases.FindLease6(duid, iaid, prefix)
+	return l != nil && l.Subnet == sub.Network
+}
+
+// knownClient reports whether the client has a reservation in sub or a
+// binding for any IA in its message.
+func (h *Handler) knownClient(req *request, sub *config.Subnet6Config) bool {
+	duid := dhcpv6.FormatDUID(req.msg.Options.Get(dhcpv6.OptionClientID))
+	if findReservation(sub, duid) != nil {
+		return true
+	}
+	for _, prefix := range []bool{false, true} {
+		code := dhcpv6.OptionIANA
+		if prefix {
+			code = dhcpv6.OptionIAPD
+		}
+		for _, data := range req.msg.Options.GetAll(code) {
+			if ia, err := dhcpv6.DecodeIA(data); err == nil && h.hasBinding(sub, duid, ia.IAID, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findReservation returns the subnet's reservation for a DUID, or nil.
+func findReservation(sub *config.Subnet6Config, duid string) *config.Reservation6Config {
+	for i := range sub.Reservations {
+		if canonicalDUID(sub.Reservations[i].DUID) == duid {
+			return &sub.Reservations[i]
+		}
+	}
+	return nil
+}
+
+// canonicalDUID normalises a configured DUID to the colon-separated form
+// used in the lease store.
+func canonicalDUID(s string) string {
+	b, err := dhcpv6.ParseDUID(s)
+	if err != nil {
+		return ""
+	}
+	return dhcpv6.FormatDUID(b)
+}
+
+// poolFor returns the range string of the IA_NA pool containing ip, or "".
+func poolFor(sub *config.Subnet6Config, ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	v := new(big.Int).SetBytes(ip.To16())
+	for _, p := range sub.Pools {
+		start := new(big.Int).SetBytes(net.ParseIP(p.RangeStart).To16())
+		end := new(big.Int).SetBytes(net.ParseIP(p.RangeEnd).To16())
+		if v.Cmp(start) >= 0 && v.Cmp(end) <= 0 {
+			return p.RangeStart + "-" + p.RangeEnd
+		}
+	}
+	return ""
+}
+
+// probe walks up to maxProbes slots of [0, size) starting at seed mod size,
+// stopping when fn returns true.
+func probe(size *big.Int, seed uint64, fn func(i *big.Int) bool) {
+	i := new(big.Int).Mod(new(big.Int).SetUint64(seed), size)
+	n := int64(maxProbes)
+	if size.IsInt64() && size.Int64() < n {
+		n = size.Int64()
+	}
+	one := big.NewInt(1)
+	for ; n > 0; n-- {
+		if fn(i) {
+			return
+		}
+		i.Add(i, one)
+		if i.Cmp(size) >= 0 {
+			i.SetInt64(0)
+		}
+	}
+}
+
+// clientHash spreads clients across a range so allocation doesn't always
+// start scanning at the bottom.
+func clientHash(duid string, iaid uint32, prefix bool) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(duid))
+	f.Write([]byte{byte(iaid >> 24), byte(iaid >> 16), byte(iaid >> 8), byte(iaid)})
+	if prefix {
+		f.Write([]byte("pd"))
+	}
+	return f.Sum64()
+}
+
+// bindingOption encodes an IA Address (plen 0) or IA Prefix option.
+func bindingOption(ip net.IP, plen int, preferred, valid time.Duration) (dhcpv6.OptionCode, []byte) {
+	if plen > 0 {
+		return dhcpv6.OptionIAPrefix, (&dhcpv6.IAPrefix{
+			PreferredLifetime: seconds(preferred),
+			ValidLifetime:     seconds(valid),
+			PrefixLen:         uint8(plen),
+			Prefix:            ip,
+		}).Encode()
+	}
+	return dhcpv6.OptionIAAddr, (&dhcpv6.IAAddress{
+		IP:                ip,
+		PreferredLifetime: seconds(preferred),
+		ValidLifetime:     seconds(valid),
+	}).Encode()
+}
+
+// decodeBinding extracts the address or prefix from an IA Address or IA
+// Prefix option body, or nil if malformed.
+func decodeBinding(data []byte, prefix bool) (net.IP, int) {
+	if prefix {
+		p, err := dhcpv6.DecodeIAPrefix(data)
+		if err != nil {
+			return nil, 0
+		}
+		return p.Prefix, int(p.PrefixLen)
+	}
+	a, err := dhcpv6.DecodeIAAddress(data)
+	if err != nil {
+		return nil, 0
+	}
+	return a.IP, 0
+}
+
+func bigToIP(v *big.Int) net.IP {
+	b := v.Bytes()
+	ip := make(net.IP, net.IPv6len)
+	copy(ip[net.IPv6len-len(b):], b)
+	return ip
+}
+
+func seconds(d time.Duration) uint32 {
+	return uint32(d / time.Second)
+}
@@ -0,0 +1,325 @@
+// Package dhcp6 implements the stateful DHCPv6 server (RFC 8415): IA_NA
+// address assignment and IA_PD prefix delegation, sharing the lease store
+// with the DHCPv4 engine.
+package dhcp6
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
+)
+
+// HAChecker is satisfied by the HA FSM — lets the handler skip messages when
+// standby, and stick to existing bindings while HA communications are
+// interrupted.
+type HAChecker interface {
+	IsActive() bool
+	RenewalsOnly() bool
+}
+
+// LoadBalancer is satisfied by the HA load balancer — tells the handler
+// which clients the peer answers.
+type LoadBalancer interface {
+	InScope(key []byte) bool
+}
+
+// Handler processes DHCPv6 messages.
+type Handler struct {
+	cfg        *config.Config
+	leases     *lease.Manager
+	logger     *slog.Logger
+	serverDUID []byte
+	ha         HAChecker
+	lb         LoadBalancer
+}
+
+// NewHandler creates a new DHCPv6 message handler. The server DUID is a
+// DUID-LL built from the hardware address of server.interface, or of the
+// first Ethernet interface found.
+func NewHandler(cfg *config.Config, leases *lease.Manager, logger *slog.Logger) *Handler {
+	h := &Handler{
+		cfg:    cfg,
+		leases: leases,
+		logger: logger,
+	}
+	mac := interfaceMAC(cfg.Server.Interface)
+	if mac == nil {
+		logger.Warn("no Ethernet interface found for DHCPv6 server DUID, using zero address")
+		mac = make(net.HardwareAddr, 6)
+	}
+	h.serverDUID = dhcpv6.NewDUIDLL(mac)
+	logger.Info("DHCPv6 server DUID", "duid", dhcpv6.FormatDUID(h.serverDUID))
+	return h
+}
+
+// SetHA sets the HA state checker (call after FSM is created).
+func (h *Handler) SetHA(ha HAChecker) {
+	h.ha = ha
+}
+
+// SetLoadBalancer enables HA load balancing (nil for active/standby).
+// Clients are split by a hash of their DUID.
+func (h *Handler) SetLoadBalancer(lb LoadBalancer) {
+	h.lb = lb
+}
+
+// UpdateConfig updates the handler's configuration (for hot-reload).
+func (h *Handler) UpdateConfig(cfg *config.Config) {
+	h.cfg = cfg
+}
+
+// HandlePacket processes one DHCPv6 datagram received on iface and returns
+// the encoded reply, or nil if the message is dropped. Relay-forward chains
+// are unwrapped and the reply is wrapped back into matching relay-replies.
+func (h *Handler) HandlePacket(ctx context.Context, data []byte, iface string) ([]byte, error) {
+	req, err := decodeRequest(data, iface)
+	if err != nil {
+		return nil, err
+	}
+	metrics.DHCPv6PacketsReceived.WithLabelValues(req.msg.Type.String()).Inc()
+
+	// HA guard: if we have an FSM and we are NOT the active node, silently drop.
+	if h.ha != nil && !h.ha.IsActive() {
+		return nil, nil
+	}
+	if h.lb != nil {
+		if duid := req.msg.Options.Get(dhcpv6.OptionClientID); duid != nil && !h.lb.InScope(duid) {
+			metrics.HALoadBalanceSkipped.Inc()
+			return nil, nil
+		}
+	}
+
+	reply := h.handle(ctx, req)
+	if reply == nil {
+		return nil, nil
+	}
+	metrics.DHCPv6PacketsSent.WithLabelValues(reply.Type.String()).Inc()
+	return req.wrap(reply), nil
+}
+
+// handle dispatches a client message by type (RFC 8415 §18.3).
+func (h *Handler) handle(ctx context.Context, req *request) *dhcpv6.Message {
+	msg := req.msg
+	clientID := msg.Options.Get(dhcpv6.OptionClientID)
+	serverID := msg.Options.Get(dhcpv6.OptionServerID)
+
+	h.logger.Debug("received DHCPv6 message",
+		"msg_type", msg.Type.String(),
+		"xid", fmt.Sprintf("%x", msg.TransactionID),
+		"duid", dhcpv6.FormatDUID(clientID),
+		"relayed", len(req.relays) > 0)
+
+	// Server-ID rules (RFC 8415 §16): Solicit, Confirm and Rebind must not
+	// carry one; Request, Renew, Release and Decline must carry ours.
+	switch msg.Type {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeConfirm, dhcpv6.MessageTypeRebind:
+		if clientID == nil || serverID != nil {
+			return nil
+		}
+	case dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew,
+		dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+		if clientID == nil || string(serverID) != string(h.serverDUID) {
+			return nil
+		}
+	case dhcpv6.MessageTypeInformationRequest:
+		if serverID != nil && string(serverID) != string(h.serverDUID) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	idx, sub := h.findSubnet(req)
+	if sub == nil && msg.Type != dhcpv6.MessageTypeInformationRequest {
+		h.logger.Debug("no DHCPv6 subnet for message",
+			"msg_type", msg.Type.String(),
+			"link_addr", fmt.Sprint(req.linkAddr()),
+			"interface", req.iface)
+		return nil
+	}
+
+	// The peer may be handing out the same free addresses right now
+	if (msg.Type == dhcpv6.MessageTypeSolicit || msg.Type == dhcpv6.MessageTypeRequest) &&
+		h.renewalsOnly() && !h.knownClient(req, sub) {
+		metrics.HARenewalsOnlyDropped.Inc()
+		h.logger.Info("DHCPv6 message from new client ignored — HA communications interrupted",
+			"msg_type", msg.Type.String(),
+			"duid", dhcpv6.FormatDUID(clientID))
+		return nil
+	}
+
+	switch msg.Type {
+	case dhcpv6.MessageTypeSolicit:
+		if sub.RapidCommit && msg.Options.Has(dhcpv6.OptionRapidCommit) {
+			reply := h.newReply(msg, dhcpv6.MessageTypeReply, sub)
+			reply.Options.Add(dhcpv6.OptionRapidCommit, nil)
+			h.assignIAs(ctx, req, idx, sub, reply, true)
+			return reply
+		}
+		reply := h.newReply(msg, dhcpv6.MessageTypeAdvertise, sub)
+		h.assignIAs(ctx, req, idx, sub, reply, false)
+		return reply
+	case dhcpv6.MessageTypeRequest:
+		reply := h.newReply(msg, dhcpv6.MessageTypeReply, sub)
+		h.assignIAs(ctx, req, idx, sub, reply, true)
+		return reply
+	case dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind:
+		reply := h.newReply(msg, dhcpv6.MessageTypeReply, sub)
+		h.extendIAs(req, idx, sub, reply)
+		return reply
+	case dhcpv6.MessageTypeRelease:
+		h.releaseIAs(req, false)
+		reply := h.newReply(msg, dhcpv6.MessageTypeReply, nil)
+		reply.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusSuccess, "released"))
+		return reply
+	case dhcpv6.MessageTypeDecline:
+		h.releaseIAs(req, true)
+		reply := h.newReply(msg, dhcpv6.MessageTypeReply, nil)
+		reply.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusSuccess, "declined"))
+		return reply
+	case dhcpv6.MessageTypeConfirm:
+		return h.confirm(msg, sub)
+	case dhcpv6.MessageTypeInformationRequest:
+		return h.newReply(msg, dhcpv6.MessageTypeReply, sub)
+	}
+	return nil
+}
+
+// newReply builds a reply carrying the client and server IDs and the
+// subnet's DNS configuration when the client asked for it (or sent no ORO).
+func (h *Handler) newReply(msg *dhcpv6.Message, typ dhcpv6.MessageType, sub *config.Subnet6Config) *dhcpv6.Message {
+	reply := &dhcpv6.Message{Type: typ, TransactionID: msg.TransactionID}
+	if cid := msg.Options.Get(dhcpv6.OptionClientID); cid != nil {
+		reply.Options.Add(dhcpv6.OptionClientID, cid)
+	}
+	reply.Options.Add(dhcpv6.OptionServerID, h.serverDUID)
+	if sub == nil {
+		return reply
+	}
+
+	oro := msg.Options.RequestedOptions()
+	wants := func(code dhcpv6.OptionCode) bool {
+		if len(oro) == 0 {
+			return true
+		}
+		for _, c := range oro {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	if len(sub.DNSServers) > 0 && wants(dhcpv6.OptionDNSServers) {
+		var ips []net.IP
+		for _, s := range sub.DNSServers {
+			if ip := net.ParseIP(s); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		reply.Options.Add(dhcpv6.OptionDNSServers, dhcpv6.EncodeIPList(ips))
+	}
+	if len(sub.DomainSearch) > 0 && wants(dhcpv6.OptionDomainList) {
+		reply.Options.Add(dhcpv6.OptionDomainList, dhcpv6.EncodeDomainList(sub.DomainSearch))
+	}
+	return reply
+}
+
+// confirm answers a Confirm: Success if every address the client holds is
+// on the link, NotOnLink otherwise (RFC 8415 §18.3.3).
+func (h *Handler) confirm(msg *dhcpv6.Message, sub *config.Subnet6Config) *dhcpv6.Message {
+	_, network, err := net.ParseCIDR(sub.Network)
+	if err != nil {
+		return nil
+	}
+	seen := false
+	onLink := true
+	for _, data := range msg.Options.GetAll(dhcpv6.OptionIANA) {
+		ia, err := dhcpv6.DecodeIA(data)
+		if err != nil {
+			continue
+		}
+		for _, ad := range ia.Options.GetAll(dhcpv6.OptionIAAddr) {
+			addr, err := dhcpv6.DecodeIAAddress(ad)
+			if err != nil {
+				continue
+			}
+			seen = true
+			if !network.Contains(addr.IP) {
+				onLink = false
+			}
+		}
+	}
+	if !seen {
+		return nil
+	}
+	reply := h.newReply(msg, dhcpv6.MessageTypeReply, nil)
+	if onLink {
+		reply.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusSuccess, "all addresses on link"))
+	} else {
+		reply.Options.Add(dhcpv6.OptionStatusCode, dhcpv6.EncodeStatusCode(dhcpv6.StatusNotOnLink, "address not on link"))
+	}
+	return reply
+}
+
+// findSubnet selects the subnet for a message: by the link-address of the
+// relay closest to the client, otherwise by receiving interface, otherwise
+// the only configured subnet.
+func (h *Handler) findSubnet(req *request) (int, *config.Subnet6Config) {
+	if link := req.linkAddr(); link != nil {
+		for i := range h.cfg.Subnets6 {
+			_, network, err := net.ParseCIDR(h.cfg.Subnets6[i].Network)
+			if err == nil && network.Contains(link) {
+				return i, &h.cfg.Subnets6[i]
+			}
+		}
+		return -1, nil
+	}
+	for i := range h.cfg.Subnets6 {
+		if req.iface != "" && h.cfg.Subnets6[i].Interface == req.iface {
+			return i, &h.cfg.Subnets6[i]
+		}
+	}
+	if len(h.cfg.Subnets6) == 1 {
+		return 0, &h.cfg.Subnets6[0]
+	}
+	return -1, nil
+}
+
+// clientHostname returns the short hostname and FQDN from the Client FQDN option.
+func clientHostname(msg *dhcpv6.Message) (hostname, fqdn string) {
+	name := msg.Options.ClientFQDN()
+	if name == "" {
+		return "", ""
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		return name[:i], name
+	}
+	return name, ""
+}
+
+// interfaceMAC returns the hardware address of the named interface, or of
+// the first interface with an Ethernet address when name is empty or has none.
+func interfaceMAC(name string) net.HardwareAddr {
+	if name != "" {
+		if ifi, err := net.InterfaceByName(name); err == nil && len(ifi.HardwareAddr) == 6 {
+			return ifi.HardwareAddr
+		}
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback == 0 && len(ifi.HardwareAddr) == 6 {
+			return ifi.HardwareAddr
+		}
+	}
+	return nil
+}
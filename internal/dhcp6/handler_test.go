@@ -0,0 +1,330 @@
+package dhcp6
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
+)
+
+var testClientDUID = []byte{0, 3, 0, 1, 0xaa, 0xbb, 0xcc, 0, 0, 1}
+
+func testHandler(t *testing.T) (*Handler, *lease.Store) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	bus := events.NewBus(100, logger)
+	go bus.Start()
+	t.Cleanup(bus.Stop)
+
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Defaults: config.DefaultsConfig{LeaseTime: "1h"},
+		Subnets6: []config.Subnet6Config{{
+			Network:       "2001:db8:1::/64",
+			DNSServers:    []string{"2001:db8::53"},
+			DomainSearch:  []string{"example.com"},
+			ValidLifetime: "2h",
+			RapidCommit:   true,
+			Pools:         []config.Pool6Config{{RangeStart: "2001:db8:1::100", RangeEnd: "2001:db8:1::1ff"}},
+			PDPools:       []config.PDPoolConfig{{Prefix: "2001:db8:ff00::/40", DelegatedLength: 56}},
+			Reservations: []config.Reservation6Config{{
+				DUID: "0003000100000000beef", IP: "2001:db8:1::10", Hostname: "printer",
+			}},
+		}},
+	}
+	h := NewHandler(cfg, lease.NewManager(store, cfg, bus, logger), logger)
+	return h, store
+}
+
+func testMessage(typ dhcpv6.MessageType, duid, serverID []byte, iaids ...uint32) *dhcpv6.Message {
+	m := &dhcpv6.Message{Type: typ, TransactionID: [3]byte{1, 2, 3}}
+	m.Options.Add(dhcpv6.OptionClientID, duid)
+	if serverID != nil {
+		m.Options.Add(dhcpv6.OptionServerID, serverID)
+	}
+	for _, id := range iaids {
+		m.Options.Add(dhcpv6.OptionIANA, (&dhcpv6.IA{IAID: id}).Encode())
+	}
+	return m
+}
+
+// relayed wraps a client message in a relay-forward from the 2001:db8:1::/64 link.
+func relayed(m *dhcpv6.Message) []byte {
+	r := &dhcpv6.RelayMessage{
+		Type:     dhcpv6.MessageTypeRelayForward,
+		LinkAddr: net.ParseIP("2001:db8:1::1"),
+		PeerAddr: net.ParseIP("fe80::aabb:ccff:fe00:1"),
+	}
+	r.Options.Add(dhcpv6.OptionInterfaceID, []byte("ge-0/0/7"))
+	r.Options.Add(dhcpv6.OptionRelayMsg, m.Encode())
+	return r.Encode()
+}
+
+// exchange sends data through the handler and unwraps a relay-reply if present.
+func exchange(t *testing.T, h *Handler, data []byte) *dhcpv6.Message {
+	t.Helper()
+	out, err := h.HandlePacket(context.Background(), data, "")
+	if err != nil {
+		t.Fatalf("HandlePacket: %v", err)
+	}
+	if out == nil {
+		return nil
+	}
+	if dhcpv6.MessageType(out[0]) == dhcpv6.MessageTypeRelayReply {
+		rr, err := dhcpv6.DecodeRelayMessage(out)
+		if err != nil {
+			t.Fatalf("DecodeRelayMessage: %v", err)
+		}
+		if string(rr.Options.Get(dhcpv6.OptionInterfaceID)) != "ge-0/0/7" {
+			t.Errorf("relay-reply interface-id = %q", rr.Options.Get(dhcpv6.OptionInterfaceID))
+		}
+		out = rr.Options.Get(dhcpv6.OptionRelayMsg)
+	}
+	m, err := dhcpv6.DecodeMessage(out)
+	if err != nil {
+		t.Fatalf("DecodeMessage: %v", err)
+	}
+	return m
+}
+
+// boundAddress returns the first IA Address in the reply's first IA_NA.
+func boundAddress(t *testing.T, m *dhcpv6.Message) *dhcpv6.IAAddress {
+	t.Helper()
+	ia, err := dhcpv6.DecodeIA(m.Options.Get(dhcpv6.OptionIANA))
+	if err != nil {
+		t.Fatalf("DecodeIA: %v", err)
+	}
+	data := ia.Options.Get(dhcpv6.OptionIAAddr)
+	if data == nil {
+		code, msg, _ := dhcpv6.DecodeStatusCode(ia.Options.Get(dhcpv6.OptionStatusCode))
+		t.Fatalf("IA_NA has no address, status %v %q", code, msg)
+	}
+	a, err := dhcpv6.DecodeIAAddress(data)
+	if err != nil {
+		t.Fatalf("DecodeIAAddress: %v", err)
+	}
+	return a
+}
+
+func TestSolicitRequestRenewRelease(t *testing.T) {
+	h, store := testHandler(t)
+
+	adv := exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeSolicit, testClientDUID, nil, 1)))
+	if adv == nil || adv.Type != dhcpv6.MessageTypeAdvertise {
+		t.Fatalf("expected ADVERTISE, got %v", adv)
+	}
+	offered := boundAddress(t, adv).IP
+	if poolFor(&h.cfg.Subnets6[0], offered) == "" {
+		t.Fatalf("advertised %s outside the pool", offered)
+	}
+	if store.Count() != 0 {
+		t.Error("ADVERTISE must not create a binding")
+	}
+	if dns, _ := dhcpv6.DecodeIPList(adv.Options.Get(dhcpv6.OptionDNSServers)); len(dns) != 1 {
+		t.Errorf("dns servers = %v", dns)
+	}
+	serverID := adv.Options.Get(dhcpv6.OptionServerID)
+
+	// Request for the advertised address commits the binding.
+	reply := exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeRequest, testClientDUID, serverID, 1)))
+	if reply == nil || reply.Type != dhcpv6.MessageTypeReply {
+		t.Fatalf("expected REPLY, got %v", reply)
+	}
+	a := boundAddress(t, reply)
+	if !a.IP.Equal(offered) || a.ValidLifetime != 7200 {
+		t.Errorf("bound %s valid %d, want %s valid 7200", a.IP, a.ValidLifetime, offered)
+	}
+	l := store.GetByIP(offered)
+	if l == nil || l.DUID != dhcpv6.FormatDUID(testClientDUID) || l.IAID != 1 {
+		t.Fatalf("stored lease = %+v", l)
+	}
+	if l.MAC.String() != "aa:bb:cc:00:00:01" || l.RelayInfo == nil || l.RelayInfo.CircuitID != "ge-0/0/7" {
+		t.Errorf("lease MAC %v relay %+v", l.MAC, l.RelayInfo)
+	}
+
+	// A Request carrying someone else's server ID is ignored.
+	if got := exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeRequest, testClientDUID, []byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6}, 1))); got != nil {
+		t.Errorf("request for another server answered: %v", got)
+	}
+
+	// Renew extends the same address; an unknown IA gets NoBinding.
+	renew := exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeRenew, testClientDUID, serverID, 1)))
+	if a := boundAddress(t, renew); !a.IP.Equal(offered) {
+		t.Errorf("renewed %s, want %s", a.IP, offered)
+	}
+	renew = exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeRenew, testClientDUID, serverID, 99)))
+	ia, _ := dhcpv6.DecodeIA(renew.Options.Get(dhcpv6.OptionIANA))
+	if code, _, _ := dhcpv6.DecodeStatusCode(ia.Options.Get(dhcpv6.OptionStatusCode)); code != dhcpv6.StatusNoBinding {
+		t.Errorf("unknown IA status = %v, want NoBinding", code)
+	}
+
+	// Release removes the binding.
+	rel := testMessage(dhcpv6.MessageTypeRelease, testClientDUID, serverID)
+	held := &dhcpv6.IA{IAID: 1}
+	held.Options.Add(dhcpv6.OptionIAAddr, (&dhcpv6.IAAddress{IP: offered}).Encode())
+	rel.Options.Add(dhcpv6.OptionIANA, held.Encode())
+	if got := exchange(t, h, relayed(rel)); got == nil || got.Type != dhcpv6.MessageTypeReply {
+		t.Fatalf("expected REPLY to release, got %v", got)
+	}
+	if store.GetByIP(offered) != nil {
+		t.Error("lease still present after release")
+	}
+}
+
+func TestRapidCommitPrefixDelegation(t *testing.T) {
+	h, store := testHandler(t)
+
+	sol := testMessage(dhcpv6.MessageTypeSolicit, testClientDUID, nil)
+	sol.Options.Add(dhcpv6.OptionRapidCommit, nil)
+	sol.Options.Add(dhcpv6.OptionIAPD, (&dhcpv6.IA{IAID: 5}).Encode())
+
+	reply := exchange(t, h, sol.Encode())
+	if reply == nil || reply.Type != dhcpv6.MessageTypeReply || !reply.Options.Has(dhcpv6.OptionRapidCommit) {
+		t.Fatalf("expected rapid-commit REPLY, got %v", reply)
+	}
+	ia, err := dhcpv6.DecodeIA(reply.Options.Get(dhcpv6.OptionIAPD))
+	if err != nil {
+		t.Fatalf("DecodeIA: %v", err)
+	}
+	p, err := dhcpv6.DecodeIAPrefix(ia.Options.Get(dhcpv6.OptionIAPrefix))
+	if err != nil {
+		t.Fatalf("DecodeIAPrefix: %v", err)
+	}
+	_, pool, _ := net.ParseCIDR("2001:db8:ff00::/40")
+	if p.PrefixLen != 56 || !pool.Contains(p.Prefix) {
+		t.Errorf("delegated %s/%d, want a /56 from %s", p.Prefix, p.PrefixLen, pool)
+	}
+	l := store.GetByDUID(dhcpv6.FormatDUID(testClientDUID), 5, true)
+	if l == nil || !l.IP.Equal(p.Prefix) || l.PrefixLen != 56 {
+		t.Fatalf("stored prefix lease = %+v", l)
+	}
+
+	// The same client asking again gets the same prefix.
+	again := exchange(t, h, sol.Encode())
+	ia, _ = dhcpv6.DecodeIA(again.Options.Get(dhcpv6.OptionIAPD))
+	if p2, _ := dhcpv6.DecodeIAPrefix(ia.Options.Get(dhcpv6.OptionIAPrefix)); !p2.Prefix.Equal(p.Prefix) {
+		t.Errorf("second delegation %s, want %s", p2.Prefix, p.Prefix)
+	}
+}
+
+func TestReservationAndConfirm(t *testing.T) {
+	h, _ := testHandler(t)
+	duid, _ := dhcpv6.ParseDUID("00:03:00:01:00:00:00:00:be:ef")
+
+	adv := exchange(t, h, relayed(testMessage(dhcpv6.MessageTypeSolicit, duid, nil, 1)))
+	if a := boundAddress(t, adv); !a.IP.Equal(net.ParseIP("2001:db8:1::10")) {
+		t.Errorf("reserved client got %s, want 2001:db8:1::10", a.IP)
+	}
+
+	// Another client asking for the reserved address doesn't get it.
+	sol := testMessage(dhcpv6.MessageTypeSolicit, testClientDUID, nil)
+	hint := &dhcpv6.IA{IAID: 1}
+	hint.Options.Add(dhcpv6.OptionIAAddr, (&dhcpv6.IAAddress{IP: net.ParseIP("2001:db8:1::10")}).Encode())
+	sol.Options.Add(dhcpv6.OptionIANA, hint.Encode())
+	if a := boundAddress(t, exchange(t, h, relayed(sol))); a.IP.Equal(net.ParseIP("2001:db8:1::10")) {
+		t.Error("reserved address handed to another client")
+	}
+
+	tests := []struct {
+		ip   string
+		want dhcpv6.StatusCode
+	}{
+		{"2001:db8:1::123", dhcpv6.StatusSuccess},
+		{"2001:db8:9::123", dhcpv6.StatusNotOnLink},
+	}
+	for _, tt := range tests {
+		conf := testMessage(dhcpv6.MessageTypeConfirm, testClientDUID, nil)
+		ia := &dhcpv6.IA{IAID: 1}
+		ia.Options.Add(dhcpv6.OptionIAAddr, (&dhcpv6.IAAddress{IP: net.ParseIP(tt.ip)}).Encode())
+		conf.Options.Add(dhcpv6.OptionIANA, ia.Encode())
+		reply := exchange(t, h, relayed(conf))
+		if reply == nil {
+			t.Fatalf("Confirm %s: no reply", tt.ip)
+		}
+		if code, _, _ := dhcpv6.DecodeStatusCode(reply.Options.Get(dhcpv6.OptionStatusCode)); code != tt.want {
+			t.Errorf("Confirm %s: status %v, want %v", tt.ip, code, tt.want)
+		}
+	}
+}
+
+func TestReservationHeldByAnotherClient(t *testing.T) {
+	h, store := testHandler(t)
+	h.cfg.Subnets6[0].Reservations[0].Prefix = "2001:db8:ff00:100::/56"
+	duid, _ := dhcpv6.ParseDUID("00:03:00:01:00:00:00:00:be:ef")
+
+	// Both were leased to another client before the reservation was added
+	now := time.Now()
+	other := dhcpv6.FormatDUID(testClientDUID)
+	for _, l := range []*lease.Lease{
+		{IP: net.ParseIP("2001:db8:1::10"), DUID: other, IAID: 1},
+		{IP: net.ParseIP("2001:db8:ff00:100::"), DUID: other, IAID: 5, PrefixLen: 56},
+	} {
+		l.Subnet, l.State, l.Start, l.Expiry = "2001:db8:1::/64", dhcpv4.LeaseStateActive, now, now.Add(time.Hour)
+		if err := store.Put(l); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	req := testMessage(dhcpv6.MessageTypeSolicit, duid, nil, 1)
+	req.Options.Add(dhcpv6.OptionRapidCommit, nil)
+	req.Options.Add(dhcpv6.OptionIAPD, (&dhcpv6.IA{IAID: 5}).Encode())
+	reply := exchange(t, h, relayed(req))
+	if reply == nil {
+		t.Fatal("no reply")
+	}
+	for _, tt := range []struct {
+		code dhcpv6.OptionCode
+		want dhcpv6.StatusCode
+	}{
+		{dhcpv6.OptionIANA, dhcpv6.StatusNoAddrsAvail},
+		{dhcpv6.OptionIAPD, dhcpv6.StatusNoPrefixAvail},
+	} {
+		ia, err := dhcpv6.DecodeIA(reply.Options.Get(tt.code))
+		if err != nil {
+			t.Fatalf("DecodeIA %d: %v", tt.code, err)
+		}
+		if code, _, _ := dhcpv6.DecodeStatusCode(ia.Options.Get(dhcpv6.OptionStatusCode)); code != tt.want {
+			t.Errorf("option %d status = %v, want %v", tt.code, code, tt.want)
+		}
+	}
+	for _, ip := range []string{"2001:db8:1::10", "2001:db8:ff00:100::"} {
+		if l := store.GetByIP(net.ParseIP(ip)); l == nil || l.DUID != other {
+			t.Errorf("lease on %s = %+v, want it kept by %s", ip, l, other)
+		}
+	}
+	if store.GetByDUID(dhcpv6.FormatDUID(duid), 1, false) != nil {
+		t.Error("reserved client got a second binding for the held address")
+	}
+}
+
+func TestDecodeRequestRejectsBadRelays(t *testing.T) {
+	inner := testMessage(dhcpv6.MessageTypeSolicit, testClientDUID, nil, 1).Encode()
+	data := inner
+	for i := 0; i <= dhcpv6.HopCountLimit+1; i++ {
+		r := &dhcpv6.RelayMessage{Type: dhcpv6.MessageTypeRelayForward, HopCount: uint8(i)}
+		r.Options.Add(dhcpv6.OptionRelayMsg, data)
+		data = r.Encode()
+	}
+	if _, err := decodeRequest(data, ""); err == nil {
+		t.Error("expected error for relay chain over the hop limit")
+	}
+
+	noMsg := &dhcpv6.RelayMessage{Type: dhcpv6.MessageTypeRelayForward}
+	if _, err := decodeRequest(noMsg.Encode(), ""); err == nil {
+		t.Error("expected error for relay-forward without relay message")
+	}
+}
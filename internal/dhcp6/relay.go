@@ -0,0 +1,117 @@
+package dhcp6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
+)
+
+// request is a client message together with the relay-forward chain it
+// arrived through (outermost relay first).
+type request struct {
+	msg    *dhcpv6.Message
+	relays []*dhcpv6.RelayMessage
+	iface  string
+}
+
+// decodeRequest unwraps relay-forward messages down to the client message
+// (RFC 8415 §19.2).
+func decodeRequest(data []byte, iface string) (*request, error) {
+	req := &request{iface: iface}
+	for len(data) > 0 && dhcpv6.MessageType(data[0]).IsRelay() {
+		if len(req.relays) > dhcpv6.HopCountLimit {
+			return nil, fmt.Errorf("relay chain exceeds %d hops", dhcpv6.HopCountLimit)
+		}
+		r, err := dhcpv6.DecodeRelayMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		if r.Type != dhcpv6.MessageTypeRelayForward {
+			return nil, fmt.Errorf("unexpected %s from relay", r.Type)
+		}
+		data = r.Options.Get(dhcpv6.OptionRelayMsg)
+		if data == nil {
+			return nil, fmt.Errorf("relay-forward without relay message option")
+		}
+		req.relays = append(req.relays, r)
+	}
+	msg, err := dhcpv6.DecodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	req.msg = msg
+	return req, nil
+}
+
+// innermost returns the relay closest to the client, or nil.
+func (r *request) innermost() *dhcpv6.RelayMessage {
+	if len(r.relays) == 0 {
+		return nil
+	}
+	return r.relays[len(r.relays)-1]
+}
+
+// linkAddr returns the link-address identifying the client's link: that of
+// the relay closest to the client, or the next one out if it is unspecified
+// (RFC 8415 §13.1). Nil for messages received directly.
+func (r *request) linkAddr() net.IP {
+	for i := len(r.relays) - 1; i >= 0; i-- {
+		if a := r.relays[i].LinkAddr; !a.IsUnspecified() {
+			return a
+		}
+	}
+	return nil
+}
+
+// relayInfo returns the relay data stored with a lease: the link-address
+// plus the Interface-ID and Remote-ID (RFC 4649) of the relay closest to the
+// client, mirroring giaddr and Option 82 for DHCPv4.
+func (r *request) relayInfo() *lease.RelayInfo {
+	in := r.innermost()
+	if in == nil {
+		return nil
+	}
+	ri := &lease.RelayInfo{
+		GIAddr:    r.linkAddr(),
+		CircuitID: string(in.Options.Get(dhcpv6.OptionInterfaceID)),
+	}
+	if rid := in.Options.Get(dhcpv6.OptionRemoteID); len(rid) > 4 {
+		ri.RemoteID = string(rid[4:]) // skip enterprise number
+	}
+	return ri
+}
+
+// clientMAC returns the client's link-layer address: the relay-supplied
+// Client Link-Layer Address option (RFC 6939) if present, otherwise the one
+// embedded in the DUID. May be nil.
+func (r *request) clientMAC() net.HardwareAddr {
+	if in := r.innermost(); in != nil {
+		if ll := in.Options.Get(dhcpv6.OptionClientLinkLayerAddr); len(ll) == 8 && ll[0] == 0 && ll[1] == 1 {
+			return net.HardwareAddr(append([]byte(nil), ll[2:]...))
+		}
+	}
+	return dhcpv6.MACFromDUID(r.msg.Options.Get(dhcpv6.OptionClientID))
+}
+
+// wrap encodes the reply and nests it in a relay-reply for every relay the
+// request came through, echoing each relay's Interface-ID (RFC 8415 §19.3).
+func (r *request) wrap(reply *dhcpv6.Message) []byte {
+	out := reply.Encode()
+	for i := len(r.relays) - 1; i >= 0; i-- {
+		in := r.relays[i]
+		rr := &dhcpv6.RelayMessage{
+			Type:     dhcpv6.MessageTypeRelayReply,
+			HopCount: in.HopCount,
+			LinkAddr: in.LinkAddr,
+			PeerAddr: in.PeerAddr,
+		}
+		if id := in.Options.Get(dhcpv6.OptionInterfaceID); id != nil {
+			rr.Options.Add(dhcpv6.OptionInterfaceID, id)
+		}
+		rr.Options.Add(dhcpv6.OptionRelayMsg, out)
+		out = rr.Encode()
+	}
+	return out
+}
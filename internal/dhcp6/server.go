@@ -0,0 +1,278 @@
+package dhcp6
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+
+	"golang.org/x/net/ipv6"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv6"
+)
+
+// SO_BINDTODEVICE pins the socket to a specific interface (Linux only, value 25).
+const soBindToDevice = 25
+
+// Server is a DHCPv6 listener on one interface: UDP port 547, joined to
+// All_DHCP_Relay_Agents_and_Servers (ff02::1:2) for directly attached
+// clients and reachable by unicast for relays.
+type Server struct {
+	conn    *net.UDPConn
+	handler *Handler
+	logger  *slog.Logger
+	iface   string
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+// NewServer creates a new DHCPv6 server for the given interface.
+func NewServer(handler *Handler, iface string, logger *slog.Logger) *Server {
+	return &Server{
+		handler: handler,
+		logger:  logger,
+		iface:   iface,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start begins listening for DHCPv6 messages.
+func (s *Server) Start(ctx context.Context) error {
+	iface := s.iface
+	logger := s.logger
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				// SO_REUSEADDR — allow one listener per interface on the same port
+				if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+					logger.Warn("failed to set SO_REUSEADDR", "error", err)
+				}
+				if iface != "" {
+					if err := syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, soBindToDevice, iface); err != nil {
+						logger.Debug("SO_BINDTODEVICE not available (non-Linux?)", "interface", iface, "error", err)
+					}
+				}
+			})
+		},
+	}
+
+	addr := fmt.Sprintf("[::]:%d", dhcpv6.ServerPort)
+	pc, err := lc.ListenPacket(ctx, "udp6", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	s.conn = pc.(*net.UDPConn)
+
+	if iface != "" {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			s.conn.Close()
+			return fmt.Errorf("interface %s: %w", iface, err)
+		}
+		group := &net.UDPAddr{IP: dhcpv6.AllRelayAgentsAndServers}
+		if err := ipv6.NewPacketConn(s.conn).JoinGroup(ifi, group); err != nil {
+			s.conn.Close()
+			return fmt.Errorf("joining %s on %s: %w", group.IP, iface, err)
+		}
+	}
+
+	s.logger.Info("DHCPv6 server started",
+		"address", addr,
+		"interface", s.iface)
+
+	s.wg.Add(1)
+	go s.serve(ctx)
+	return nil
+}
+
+// serve is the main message processing loop.
+func (s *Server) serve(ctx context.Context) {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		default:
+		}
+
+		n, src, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.logger.Error("reading UDP packet", "error", err)
+			continue
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		s.wg.Add(1)
+		go func(data []byte, src *net.UDPAddr) {
+			defer s.wg.Done()
+			s.processPacket(ctx, data, src)
+		}(data, src)
+	}
+}
+
+// processPacket handles one datagram. Replies go back to the source address:
+// the client for direct traffic, the relay agent for relay-forward.
+func (s *Server) processPacket(ctx context.Context, data []byte, src *net.UDPAddr) {
+	reply, err := s.handler.HandlePacket(ctx, data, s.iface)
+	if err != nil {
+		metrics.PacketErrors.WithLabelValues("decode").Inc()
+		s.logger.Warn("dropping malformed DHCPv6 message",
+			"error", err,
+			"src", src.String(),
+			"size", len(data))
+		return
+	}
+	if reply == nil {
+		return
+	}
+	if _, err := s.conn.WriteToUDP(reply, src); err != nil {
+		metrics.PacketErrors.WithLabelValues("send").Inc()
+		s.logger.Error("sending DHCPv6 reply",
+			"error", err,
+			"dst", src.String())
+	}
+}
+
+// Stop gracefully shuts down the server.
+func (s *Server) Stop() {
+	close(s.done)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.wg.Wait()
+	s.logger.Info("DHCPv6 server stopped", "interface", s.iface)
+}
+
+// ServerGroup manages one DHCPv6 listener per interface declared in the
+// subnet6 configs. All listeners share the same Handler.
+type ServerGroup struct {
+	handler *Handler
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	servers map[string]*Server // interface name → server
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewServerGroup creates a new DHCPv6 server group with a shared handler.
+func NewServerGroup(handler *Handler, logger *slog.Logger) *ServerGroup {
+	return &ServerGroup{
+		handler: handler,
+		logger:  logger,
+		servers: make(map[string]*Server),
+	}
+}
+
+// Start creates listeners for all unique interfaces in the config and begins
+// serving. With no subnet6 configured nothing listens until a Reload adds one.
+func (g *ServerGroup) Start(ctx context.Context, cfg *config.Config) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.handler.UpdateConfig(cfg)
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	for _, iface := range collectInterfaces(cfg) {
+		if err := g.startListener(iface); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reload updates the set of active listeners based on current config. On a
+// group that is not started only the handler config is updated.
+func (g *ServerGroup) Reload(cfg *config.Config) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.handler.UpdateConfig(cfg)
+	if g.ctx == nil {
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, iface := range collectInterfaces(cfg) {
+		wanted[iface] = true
+	}
+	for iface := range wanted {
+		if _, exists := g.servers[iface]; !exists {
+			if err := g.startListener(iface); err != nil {
+				g.logger.Error("failed to start DHCPv6 listener on new interface",
+					"interface", iface, "error", err)
+			}
+		}
+	}
+	for iface, srv := range g.servers {
+		if !wanted[iface] {
+			g.logger.Info("stopping DHCPv6 listener for removed interface", "interface", iface)
+			srv.Stop()
+			delete(g.servers, iface)
+		}
+	}
+}
+
+// Stop shuts down all listeners.
+func (g *ServerGroup) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cancel != nil {
+		g.cancel()
+	}
+	g.ctx, g.cancel = nil, nil
+	for iface, srv := range g.servers {
+		srv.Stop()
+		delete(g.servers, iface)
+	}
+}
+
+// Handler returns the shared message handler.
+func (g *ServerGroup) Handler() *Handler {
+	return g.handler
+}
+
+// startListener creates and starts a single listener. Caller must hold g.mu.
+func (g *ServerGroup) startListener(iface string) error {
+	srv := NewServer(g.handler, iface, g.logger)
+	if err := srv.Start(g.ctx); err != nil {
+		return fmt.Errorf("starting DHCPv6 listener on %s: %w", iface, err)
+	}
+	g.servers[iface] = srv
+	return nil
+}
+
+// collectInterfaces returns deduplicated interface names from subnet6
+// configs, falling back to server.interface. Empty when no subnet6 exists.
+func collectInterfaces(cfg *config.Config) []string {
+	if len(cfg.Subnets6) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var result []string
+	for _, sub := range cfg.Subnets6 {
+		if sub.Interface != "" && !seen[sub.Interface] {
+			seen[sub.Interface] = true
+			result = append(result, sub.Interface)
+		}
+	}
+	if len(result) == 0 {
+		result = []string{cfg.Server.Interface}
+	}
+	return result
+}
@@ -10,6 +10,7 @@ import (
 // cacheEntry holds a cached DNS response.
 type cacheEntry struct {
 	msg       *dns.Msg
+	state     ValidationState // DNSSEC result, empty when not validating
 	expiresAt time.Time
 }
 
@@ -37,20 +38,32 @@ func cacheKey(name string, qtype, qclass uint16) string {
 
 // Get retrieves a cached response. Returns nil if not found or expired.
 func (c *Cache) Get(name string, qtype, qclass uint16) *dns.Msg {
+	msg, _ := c.GetValidated(name, qtype, qclass)
+	return msg
+}
+
+// GetValidated retrieves a cached response along with its DNSSEC state.
+func (c *Cache) GetValidated(name string, qtype, qclass uint16) (*dns.Msg, ValidationState) {
 	c.mu.RLock()
 	entry, ok := c.entries[cacheKey(name, qtype, qclass)]
 	c.mu.RUnlock()
 
 	if !ok || time.Now().After(entry.expiresAt) {
-		return nil
+		return nil, ""
 	}
 
-	return entry.msg.Copy()
+	return entry.msg.Copy(), entry.state
 }
 
 // Set stores a DNS response in the cache. TTL is derived from the answer section
 // or the provided default if no answers have a TTL.
 func (c *Cache) Set(msg *dns.Msg, defaultTTL time.Duration) {
+	c.SetValidated(msg, defaultTTL, "")
+}
+
+// SetValidated stores a DNS response with its DNSSEC state. Bogus answers
+// are kept no longer than errors.
+func (c *Cache) SetValidated(msg *dns.Msg, defaultTTL time.Duration, state ValidationState) {
 	if msg == nil || len(msg.Question) == 0 {
 		return
 	}
@@ -72,7 +85,7 @@ func (c *Cache) Set(msg *dns.Msg, defaultTTL time.Duration) {
 	}
 
 	// Don't cache errors for long
-	if msg.Rcode != dns.RcodeSuccess {
+	if msg.Rcode != dns.RcodeSuccess || state == ValidationBogus {
 		if ttl > 30*time.Second {
 			ttl = 30 * time.Second
 		}
@@ -88,6 +101,7 @@ func (c *Cache) Set(msg *dns.Msg, defaultTTL time.Duration) {
 
 	c.entries[cacheKey(q.Name, q.Qtype, q.Qclass)] = &cacheEntry{
 		msg:       msg.Copy(),
+		state:     state,
 		expiresAt: time.Now().Add(ttl),
 	}
 }
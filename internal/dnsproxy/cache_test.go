@@ -112,6 +112,23 @@ func TestCacheErrorTTLCapped(t *testing.T) {
 	}
 }
 
+func TestCacheValidationState(t *testing.T) {
+	c := NewCache(100)
+
+	c.SetValidated(makeTestMsg("secure.example.com", dns.TypeA, 300), 5*time.Minute, ValidationSecure)
+	if msg, state := c.GetValidated("secure.example.com.", dns.TypeA, dns.ClassINET); msg == nil || state != ValidationSecure {
+		t.Errorf("GetValidated() state = %q, want secure", state)
+	}
+
+	c.SetValidated(makeTestMsg("bogus.example.com", dns.TypeA, 300), 5*time.Minute, ValidationBogus)
+	c.mu.RLock()
+	entry := c.entries[cacheKey("bogus.example.com.", dns.TypeA, dns.ClassINET)]
+	c.mu.RUnlock()
+	if remaining := time.Until(entry.expiresAt); remaining > 35*time.Second {
+		t.Errorf("bogus TTL should be capped at 30s, got %v", remaining)
+	}
+}
+
 func TestCacheFlush(t *testing.T) {
 	c := NewCache(100)
 
@@ -0,0 +1,873 @@
+package dnsproxy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ValidationState is the DNSSEC outcome for an upstream answer (RFC 4035 §4.3).
+type ValidationState string
+
+const (
+	ValidationSecure   ValidationState = "secure"   // chain of trust verified
+	ValidationInsecure ValidationState = "insecure" // provably unsigned
+	ValidationBogus    ValidationState = "bogus"    // should be signed, but isn't or doesn't verify
+)
+
+const (
+	maxKeyCacheTTL = time.Hour   // validated keys and delegations are trusted this long at most
+	bogusCacheTTL  = time.Minute // a broken chain is remembered this long
+	maxZoneCache   = 10000
+)
+
+// Validator checks upstream answers against a chain of trust starting at
+// the configured trust anchors. DS and DNSKEY lookups go through the same
+// exchange function as client queries, so zone overrides apply to them.
+type Validator struct {
+	anchors  map[string][]*dns.DS // lowercased zone -> trusted DS records
+	exchange func(*dns.Msg) (*dns.Msg, error)
+
+	mu    sync.Mutex
+	zones map[string]*zoneState // what the chain walk learnt, by name
+}
+
+// zoneState is what the chain walk learnt about one name.
+type zoneState struct {
+	name     string
+	keys     []*dns.DNSKEY // validated keys of a signed zone
+	insecure bool          // an unsigned delegation, or a zone without usable DS
+	notCut   bool          // not a zone apex, just a name in its parent zone
+	err      error         // the chain to this name is broken
+	expires  time.Time
+}
+
+// lookupError is a DS or DNSKEY lookup that failed in transit. Unlike a
+// broken chain it is not cached.
+type lookupError struct{ error }
+
+func (e lookupError) Unwrap() error { return e.error }
+
+// Algorithms and DS digests the validator can check. Zones that only use
+// others are treated as unsigned (RFC 4035 §5.2).
+var (
+	supportedAlgorithms = map[uint8]bool{
+		dns.RSASHA1: true, dns.RSASHA1NSEC3SHA1: true, dns.RSASHA256: true, dns.RSASHA512: true,
+		dns.ECDSAP256SHA256: true, dns.ECDSAP384SHA384: true, dns.ED25519: true,
+	}
+	supportedDigests = map[uint8]bool{dns.SHA1: true, dns.SHA256: true, dns.SHA384: true}
+)
+
+// NewValidator creates a validator from DS or DNSKEY trust anchors in
+// presentation format. DNSKEY anchors are reduced to their SHA-256 DS.
+func NewValidator(anchors []string, exchange func(*dns.Msg) (*dns.Msg, error)) (*Validator, error) {
+	v := &Validator{
+		anchors:  make(map[string][]*dns.DS),
+		exchange: exchange,
+		zones:    make(map[string]*zoneState),
+	}
+	for _, a := range anchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			return nil, fmt.Errorf("trust anchor %q: %w", a, err)
+		}
+		var ds *dns.DS
+		switch rr := rr.(type) {
+		case *dns.DS:
+			ds = rr
+		case *dns.DNSKEY:
+			ds = rr.ToDS(dns.SHA256)
+		}
+		if ds == nil {
+			return nil, fmt.Errorf("trust anchor %q is not a DS or DNSKEY record", a)
+		}
+		zone := strings.ToLower(dns.Fqdn(ds.Hdr.Name))
+		v.anchors[zone] = append(v.anchors[zone], ds)
+	}
+	if len(v.anchors) == 0 {
+		return nil, errors.New("no trust anchors")
+	}
+	return v, nil
+}
+
+// Validate returns the DNSSEC state of resp, an answer to a query sent
+// with the DO and CD bits set. A bogus result comes with the reason.
+func (v *Validator) Validate(resp *dns.Msg) (ValidationState, error) {
+	if len(resp.Question) == 0 || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+		// Nothing to validate in a SERVFAIL or REFUSED
+		return ValidationInsecure, nil
+	}
+	qname := strings.ToLower(resp.Question[0].Name)
+	qtype := resp.Question[0].Qtype
+	now := time.Now()
+	state := ValidationSecure
+
+	// Every answer RRset must verify or come from an unsigned zone. A
+	// CNAME synthesized from a verified DNAME is unsigned (RFC 6672 §5.3.1)
+	answer := rrsets(resp.Answer)
+	var expanded, unsignedCNAMEs []*rrset
+	var dnames []*dns.DNAME
+	for _, set := range answer {
+		if set.rrtype == dns.TypeCNAME && len(set.sigs) == 0 {
+			unsignedCNAMEs = append(unsignedCNAMEs, set)
+			continue
+		}
+		st, err := v.checkRRset(set, now)
+		if err != nil {
+			return ValidationBogus, err
+		}
+		if st == ValidationSecure && int(set.verified.Labels) < dns.CountLabel(set.name) {
+			expanded = append(expanded, set)
+		}
+		if st == ValidationSecure && set.rrtype == dns.TypeDNAME {
+			for _, rr := range set.rrs {
+				dnames = append(dnames, rr.(*dns.DNAME))
+			}
+		}
+		state = worse(state, st)
+	}
+	for _, set := range unsignedCNAMEs {
+		if synthesized(set, dnames) {
+			continue
+		}
+		st, err := v.checkRRset(set, now)
+		if err != nil {
+			return ValidationBogus, err
+		}
+		state = worse(state, st)
+	}
+	if len(answer) > 0 && resp.Rcode == dns.RcodeSuccess && len(expanded) == 0 {
+		return state, nil
+	}
+
+	// NXDOMAIN, NODATA and wildcard answers need signed NSEC or NSEC3
+	// records proving them, unless the name is in an unsigned zone
+	proof, st, err := v.authority(resp.Ns, now)
+	if err != nil {
+		return ValidationBogus, err
+	}
+	if st == ValidationInsecure && len(expanded) == 0 {
+		return ValidationInsecure, nil
+	}
+
+	// A wildcard answer is only right if the name itself doesn't exist
+	// (RFC 4035 §5.3.4)
+	for _, set := range expanded {
+		if err := proof.wildcardAnswer(set.name, int(set.verified.Labels)); err != nil {
+			return ValidationBogus, err
+		}
+	}
+	if len(answer) > 0 && resp.Rcode == dns.RcodeSuccess {
+		return state, nil
+	}
+
+	// The denial is for the end of the CNAME chain, if any
+	target := cnameTarget(qname, answer)
+	if proof.empty() {
+		st, err := v.proveInsecure(target, now)
+		if err != nil {
+			return ValidationBogus, fmt.Errorf("denial of existence for %s: %w", target, err)
+		}
+		return worse(state, st), nil
+	}
+	if resp.Rcode == dns.RcodeNameError {
+		st, err = proof.nxdomain(target)
+	} else {
+		st, err = proof.nodata(target, qtype)
+	}
+	if err != nil {
+		return ValidationBogus, err
+	}
+	return worse(state, st), nil
+}
+
+// authority verifies the signed RRsets of an authority section and
+// returns its NSEC and NSEC3 records. The state is insecure when the
+// section comes from an unsigned zone.
+func (v *Validator) authority(section []dns.RR, now time.Time) (*denialProof, ValidationState, error) {
+	proof := &denialProof{}
+	for _, set := range rrsets(section) {
+		if len(set.sigs) == 0 {
+			continue
+		}
+		st, err := v.checkRRset(set, now)
+		if err != nil {
+			return nil, ValidationBogus, err
+		}
+		if st == ValidationInsecure {
+			return &denialProof{}, ValidationInsecure, nil
+		}
+		for _, rr := range set.rrs {
+			switch rr := rr.(type) {
+			case *dns.NSEC:
+				proof.nsec = append(proof.nsec, rr)
+			case *dns.NSEC3:
+				proof.nsec3 = append(proof.nsec3, rr)
+			}
+		}
+	}
+	return proof, ValidationSecure, nil
+}
+
+// cnameTarget follows the CNAME chain in an answer section from qname.
+func cnameTarget(qname string, answer []*rrset) string {
+	for range answer {
+		next := ""
+		for _, set := range answer {
+			if set.rrtype == dns.TypeCNAME && set.name == qname {
+				next = strings.ToLower(set.rrs[0].(*dns.CNAME).Target)
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		qname = next
+	}
+	return qname
+}
+
+// synthesized reports whether a CNAME RRset is exactly the one a DNAME
+// substitutes for its owner: the owner below the DNAME's, pointing at the
+// same labels under the DNAME target.
+func synthesized(set *rrset, dnames []*dns.DNAME) bool {
+	if len(set.rrs) != 1 {
+		return false
+	}
+	target := strings.ToLower(dns.Fqdn(set.rrs[0].(*dns.CNAME).Target))
+	for _, d := range dnames {
+		owner := strings.ToLower(dns.Fqdn(d.Hdr.Name))
+		if set.name == owner || !dns.IsSubDomain(owner, set.name) {
+			continue
+		}
+		labels := dns.SplitDomainName(set.name)
+		prefix := labels[:len(labels)-dns.CountLabel(owner)]
+		want := dns.Fqdn(strings.Join(append(prefix, dns.SplitDomainName(strings.ToLower(d.Target))...), "."))
+		if target == want {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRRset verifies one RRset, or for an unsigned one checks that it
+// comes from an unsigned zone.
+func (v *Validator) checkRRset(set *rrset, now time.Time) (ValidationState, error) {
+	if len(set.sigs) == 0 {
+		return v.proveInsecure(set.name, now)
+	}
+	signer := strings.ToLower(dns.Fqdn(set.sigs[0].SignerName))
+	if !dns.IsSubDomain(signer, set.name) {
+		return ValidationBogus, fmt.Errorf("%s %s is signed by %s, which is not an enclosing zone", set.name, dns.TypeToString[set.rrtype], signer)
+	}
+	zone, err := v.chain(signer, now)
+	if err != nil {
+		return ValidationBogus, err
+	}
+	if zone.insecure {
+		return ValidationInsecure, nil
+	}
+	if zone.name != signer {
+		return ValidationBogus, fmt.Errorf("no chain of trust to %s", signer)
+	}
+	if err := verifyRRset(zone, set, now); err != nil {
+		return ValidationBogus, err
+	}
+	return ValidationSecure, nil
+}
+
+// proveInsecure checks that unsigned data for name really comes from an
+// unsigned zone: between the trust anchor and name there must be a
+// delegation without DS records.
+func (v *Validator) proveInsecure(name string, now time.Time) (ValidationState, error) {
+	zone, err := v.chain(name, now)
+	if err != nil {
+		return ValidationBogus, err
+	}
+	if zone.insecure {
+		return ValidationInsecure, nil
+	}
+	return ValidationBogus, fmt.Errorf("unsigned answer for %s from signed zone %s", name, zone.name)
+}
+
+// chain walks from the closest trust anchor down to name a label at a
+// time and returns the zone name is in. The result is insecure when no
+// anchor covers name or the walk crosses an unsigned delegation.
+func (v *Validator) chain(name string, now time.Time) (*zoneState, error) {
+	anchor, found := "", false
+	for a := range v.anchors {
+		if dns.IsSubDomain(a, name) && (!found || dns.CountLabel(a) > dns.CountLabel(anchor)) {
+			anchor, found = a, true
+		}
+	}
+	if !found {
+		return &zoneState{name: name, insecure: true}, nil
+	}
+
+	zone, err := v.cached(anchor, now, func() (*zoneState, error) {
+		return v.fetchKeys(anchor, v.anchors[anchor], now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - dns.CountLabel(anchor) - 1; i >= 0 && !zone.insecure; i-- {
+		child := strings.ToLower(dns.Fqdn(strings.Join(labels[i:], ".")))
+		parent := zone
+		st, err := v.cached(child, now, func() (*zoneState, error) {
+			return v.delegation(parent, child, now)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !st.notCut {
+			zone = st
+		}
+	}
+	return zone, nil
+}
+
+// cached returns the state for name, calling fetch when it is unknown or
+// has expired. Broken chains are cached briefly; failed lookups are not.
+func (v *Validator) cached(name string, now time.Time, fetch func() (*zoneState, error)) (*zoneState, error) {
+	v.mu.Lock()
+	st, ok := v.zones[name]
+	v.mu.Unlock()
+	if ok && now.Before(st.expires) {
+		return st, st.err
+	}
+
+	st, err := fetch()
+	if err != nil {
+		var le lookupError
+		if errors.As(err, &le) {
+			return nil, err
+		}
+		st = &zoneState{name: name, err: err, expires: now.Add(bogusCacheTTL)}
+	}
+
+	v.mu.Lock()
+	if len(v.zones) >= maxZoneCache {
+		v.zones = make(map[string]*zoneState)
+	}
+	v.zones[name] = st
+	v.mu.Unlock()
+	return st, st.err
+}
+
+// delegation finds out what child is, given the signed zone parent above
+// it: a signed zone (DS records), an unsigned delegation, or just a name
+// inside parent.
+func (v *Validator) delegation(parent *zoneState, child string, now time.Time) (*zoneState, error) {
+	resp, err := v.query(child, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range rrsets(resp.Answer) {
+		if set.name != child || set.rrtype != dns.TypeDS {
+			continue
+		}
+		if err := verifyRRset(parent, set, now); err != nil {
+			return nil, fmt.Errorf("DS for %s: %w", child, err)
+		}
+		ds := make([]*dns.DS, 0, len(set.rrs))
+		for _, rr := range set.rrs {
+			ds = append(ds, rr.(*dns.DS))
+		}
+		return v.fetchKeys(child, ds, now)
+	}
+
+	// No DS: the parent's signed NSEC or NSEC3 records say why
+	for _, set := range rrsets(resp.Ns) {
+		if set.rrtype != dns.TypeNSEC && set.rrtype != dns.TypeNSEC3 {
+			continue
+		}
+		if err := verifyRRset(parent, set, now); err != nil {
+			return nil, fmt.Errorf("denial of DS for %s: %w", child, err)
+		}
+		for _, rr := range set.rrs {
+			switch rr := rr.(type) {
+			case *dns.NSEC:
+				if strings.EqualFold(rr.Hdr.Name, child) {
+					return delegationFromBitmap(child, rr.TypeBitMap, ttlOf(set, now))
+				}
+				if nsecCovers(rr.Hdr.Name, rr.NextDomain, child) {
+					return &zoneState{name: child, notCut: true, expires: ttlOf(set, now)}, nil
+				}
+			case *dns.NSEC3:
+				if rr.Match(child) {
+					return delegationFromBitmap(child, rr.TypeBitMap, ttlOf(set, now))
+				}
+				if rr.Cover(child) {
+					// An opt-out span may hide unsigned delegations (RFC 5155 §6)
+					optOut := rr.Flags&1 != 0
+					return &zoneState{name: child, insecure: optOut, notCut: !optOut, expires: ttlOf(set, now)}, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no signed proof that %s has no DS records", child)
+}
+
+// delegationFromBitmap reads the NSEC or NSEC3 type bitmap for child: NS
+// without DS is an unsigned delegation, anything else a name in the parent.
+func delegationFromBitmap(child string, types []uint16, expires time.Time) (*zoneState, error) {
+	if hasType(types, dns.TypeDS) {
+		return nil, fmt.Errorf("%s has DS records, but none were returned", child)
+	}
+	if hasType(types, dns.TypeNS) && !hasType(types, dns.TypeSOA) {
+		return &zoneState{name: child, insecure: true, expires: expires}, nil
+	}
+	return &zoneState{name: child, notCut: true, expires: expires}, nil
+}
+
+// fetchKeys looks up the DNSKEY RRset of zone and accepts it when a key
+// matching one of the DS records has signed it.
+func (v *Validator) fetchKeys(zone string, ds []*dns.DS, now time.Time) (*zoneState, error) {
+	var usable []*dns.DS
+	for _, d := range ds {
+		if supportedAlgorithms[d.Algorithm] && supportedDigests[d.DigestType] {
+			usable = append(usable, d)
+		}
+	}
+	if len(usable) == 0 {
+		return &zoneState{name: zone, insecure: true, expires: now.Add(maxKeyCacheTTL)}, nil
+	}
+
+	resp, err := v.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	var keySet *rrset
+	for _, set := range rrsets(resp.Answer) {
+		if set.name == zone && set.rrtype == dns.TypeDNSKEY {
+			keySet = set
+		}
+	}
+	if keySet == nil {
+		return nil, fmt.Errorf("no DNSKEY records for %s", zone)
+	}
+
+	var keys, trusted []*dns.DNSKEY
+	for _, rr := range keySet.rrs {
+		k := rr.(*dns.DNSKEY)
+		keys = append(keys, k)
+		for _, d := range usable {
+			if k.KeyTag() != d.KeyTag || k.Algorithm != d.Algorithm {
+				continue
+			}
+			if kds := k.ToDS(d.DigestType); kds != nil && strings.EqualFold(kds.Digest, d.Digest) {
+				trusted = append(trusted, k)
+				break
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("no DNSKEY for %s matches its DS records", zone)
+	}
+	if err := verifyRRset(&zoneState{name: zone, keys: trusted}, keySet, now); err != nil {
+		return nil, fmt.Errorf("DNSKEY set for %s: %w", zone, err)
+	}
+	return &zoneState{name: zone, keys: keys, expires: ttlOf(keySet, now)}, nil
+}
+
+// query looks up a DS or DNSKEY RRset with the DO and CD bits set.
+func (v *Validator) query(name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+	resp, err := v.exchange(m)
+	if err != nil {
+		return nil, lookupError{fmt.Errorf("looking up %s %s: %w", name, dns.TypeToString[qtype], err)}
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, lookupError{fmt.Errorf("looking up %s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])}
+	}
+	return resp, nil
+}
+
+// verifyRRset checks that a current signature on set was made by one of
+// zone's keys.
+func verifyRRset(zone *zoneState, set *rrset, now time.Time) error {
+	err := fmt.Errorf("%s %s has no signature from %s", set.name, dns.TypeToString[set.rrtype], zone.name)
+	for _, sig := range set.sigs {
+		if !strings.EqualFold(dns.Fqdn(sig.SignerName), zone.name) {
+			continue
+		}
+		if !sig.ValidityPeriod(now) {
+			err = fmt.Errorf("signature on %s %s by key %d is outside its validity period", set.name, dns.TypeToString[set.rrtype], sig.KeyTag)
+			continue
+		}
+		for _, k := range zone.keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if verr := sig.Verify(k, set.rrs); verr != nil {
+				err = fmt.Errorf("signature on %s %s by key %d: %w", set.name, dns.TypeToString[set.rrtype], sig.KeyTag, verr)
+				continue
+			}
+			set.verified = sig
+			return nil
+		}
+	}
+	return err
+}
+
+// rrset is one RRset from a message section with the signatures covering it.
+type rrset struct {
+	name     string
+	rrtype   uint16
+	rrs      []dns.RR
+	sigs     []*dns.RRSIG
+	verified *dns.RRSIG // the signature verifyRRset accepted
+}
+
+// rrsets groups a message section into RRsets in order of appearance.
+func rrsets(section []dns.RR) []*rrset {
+	var sets []*rrset
+	index := make(map[string]*rrset)
+	key := func(name string, t uint16) string { return strings.ToLower(name) + "|" + strconv.Itoa(int(t)) }
+
+	var sigs []*dns.RRSIG
+	for _, rr := range section {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+			continue
+		}
+		h := rr.Header()
+		set := index[key(h.Name, h.Rrtype)]
+		if set == nil {
+			set = &rrset{name: strings.ToLower(h.Name), rrtype: h.Rrtype}
+			index[key(h.Name, h.Rrtype)] = set
+			sets = append(sets, set)
+		}
+		set.rrs = append(set.rrs, rr)
+	}
+	for _, sig := range sigs {
+		if set := index[key(sig.Hdr.Name, sig.TypeCovered)]; set != nil {
+			set.sigs = append(set.sigs, sig)
+		}
+	}
+	return sets
+}
+
+// ttlOf returns when set expires from the validator's cache.
+func ttlOf(set *rrset, now time.Time) time.Time {
+	ttl := maxKeyCacheTTL
+	for _, rr := range set.rrs {
+		if d := time.Duration(rr.Header().Ttl) * time.Second; d < ttl {
+			ttl = d
+		}
+	}
+	return now.Add(ttl)
+}
+
+// hasType reports whether an NSEC or NSEC3 type bitmap lists t.
+func hasType(types []uint16, t uint16) bool {
+	for _, bt := range types {
+		if bt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// nsecCovers reports whether name falls strictly between an NSEC record's
+// owner and next name. The last NSEC in a zone wraps round to the apex.
+func nsecCovers(owner, next, name string) bool {
+	if canonicalCompare(owner, name) >= 0 {
+		return false
+	}
+	if canonicalCompare(owner, next) >= 0 {
+		return true
+	}
+	return canonicalCompare(name, next) < 0
+}
+
+// denialProof is the verified NSEC and NSEC3 records of a response.
+type denialProof struct {
+	nsec  []*dns.NSEC
+	nsec3 []*dns.NSEC3
+}
+
+func (p *denialProof) empty() bool {
+	return len(p.nsec) == 0 && len(p.nsec3) == 0
+}
+
+// nxdomain checks that name does not exist and no wildcard could have
+// answered for it (RFC 4035 §5.4, RFC 5155 §8.4). An NSEC3 opt-out span
+// only makes the answer insecure.
+func (p *denialProof) nxdomain(name string) (ValidationState, error) {
+	if len(p.nsec3) > 0 {
+		ce, cover, err := p.closestEncloser(name)
+		if err != nil {
+			return ValidationBogus, err
+		}
+		if ce == name {
+			return ValidationBogus, fmt.Errorf("NXDOMAIN for %s, but an NSEC3 record says it exists", name)
+		}
+		if p.nsec3Covering(wildcardOf(ce)) == nil {
+			return ValidationBogus, fmt.Errorf("no NSEC3 proves there is no wildcard %s", wildcardOf(ce))
+		}
+		if cover.Flags&1 != 0 {
+			return ValidationInsecure, nil
+		}
+		return ValidationSecure, nil
+	}
+
+	cover := p.nsecCovering(name)
+	if cover == nil {
+		return ValidationBogus, fmt.Errorf("no NSEC proves that %s does not exist", name)
+	}
+	ce := lastLabels(name, max(dns.CompareDomainName(name, cover.Hdr.Name), dns.CompareDomainName(name, cover.NextDomain)))
+	if p.nsecCovering(wildcardOf(ce)) == nil {
+		return ValidationBogus, fmt.Errorf("no NSEC proves there is no wildcard %s", wildcardOf(ce))
+	}
+	return ValidationSecure, nil
+}
+
+// nodata checks that name exists without records of type qtype, or that
+// a wildcard for it does (RFC 4035 §5.4, RFC 5155 §8.5-8.7).
+func (p *denialProof) nodata(name string, qtype uint16) (ValidationState, error) {
+	lacks := func(types []uint16) bool {
+		return !hasType(types, qtype) && !hasType(types, dns.TypeCNAME)
+	}
+	if len(p.nsec3) > 0 {
+		if m := p.nsec3Matching(name); m != nil {
+			if !lacks(m.TypeBitMap) {
+				return ValidationBogus, fmt.Errorf("NODATA for %s %s, but its NSEC3 record lists the type", name, dns.TypeToString[qtype])
+			}
+			return ValidationSecure, nil
+		}
+		ce, cover, err := p.closestEncloser(name)
+		if err != nil {
+			return ValidationBogus, err
+		}
+		if qtype == dns.TypeDS && cover.Flags&1 != 0 {
+			// An unsigned delegation in an opt-out span
+			return ValidationInsecure, nil
+		}
+		if m := p.nsec3Matching(wildcardOf(ce)); m != nil && lacks(m.TypeBitMap) {
+			return ValidationSecure, nil
+		}
+		return ValidationBogus, fmt.Errorf("no NSEC3 proves that %s has no %s records", name, dns.TypeToString[qtype])
+	}
+
+	if m := p.nsecMatching(name); m != nil {
+		if !lacks(m.TypeBitMap) {
+			return ValidationBogus, fmt.Errorf("NODATA for %s %s, but its NSEC record lists the type", name, dns.TypeToString[qtype])
+		}
+		return ValidationSecure, nil
+	}
+	if cover := p.nsecCovering(name); cover != nil {
+		// An empty non-terminal: names exist below it
+		if dns.IsSubDomain(name, strings.ToLower(cover.NextDomain)) {
+			return ValidationSecure, nil
+		}
+		ce := lastLabels(name, max(dns.CompareDomainName(name, cover.Hdr.Name), dns.CompareDomainName(name, cover.NextDomain)))
+		if m := p.nsecMatching(wildcardOf(ce)); m != nil && lacks(m.TypeBitMap) {
+			return ValidationSecure, nil
+		}
+	}
+	return ValidationBogus, fmt.Errorf("no NSEC proves that %s has no %s records", name, dns.TypeToString[qtype])
+}
+
+// wildcardAnswer checks that name, answered from a wildcard whose
+// signature has labels labels, does not exist itself.
+func (p *denialProof) wildcardAnswer(name string, labels int) error {
+	if len(p.nsec3) > 0 {
+		// The next closer name is the one right below the wildcard's parent
+		if p.nsec3Covering(lastLabels(name, labels+1)) == nil {
+			return fmt.Errorf("wildcard answer for %s without an NSEC3 proving the name does not exist", name)
+		}
+		return nil
+	}
+	if p.nsecCovering(name) == nil {
+		return fmt.Errorf("wildcard answer for %s without an NSEC proving the name does not exist", name)
+	}
+	return nil
+}
+
+// closestEncloser finds the longest existing ancestor of name and the
+// NSEC3 covering the next closer name below it (RFC 5155 §8.3). When name
+// itself exists it is returned with a nil cover.
+func (p *denialProof) closestEncloser(name string) (string, *dns.NSEC3, error) {
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		ce := dns.Fqdn(strings.Join(labels[i:], "."))
+		if p.nsec3Matching(ce) == nil {
+			continue
+		}
+		if i == 0 {
+			return ce, nil, nil
+		}
+		next := dns.Fqdn(strings.Join(labels[i-1:], "."))
+		cover := p.nsec3Covering(next)
+		if cover == nil {
+			return "", nil, fmt.Errorf("no NSEC3 covers %s, the next closer name to %s", next, name)
+		}
+		return ce, cover, nil
+	}
+	return "", nil, fmt.Errorf("no NSEC3 proves the closest encloser of %s", name)
+}
+
+func (p *denialProof) nsecMatching(name string) *dns.NSEC {
+	for _, rr := range p.nsec {
+		if strings.EqualFold(rr.Hdr.Name, name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+func (p *denialProof) nsecCovering(name string) *dns.NSEC {
+	for _, rr := range p.nsec {
+		if nsecCovers(rr.Hdr.Name, rr.NextDomain, name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+func (p *denialProof) nsec3Matching(name string) *dns.NSEC3 {
+	for _, rr := range p.nsec3 {
+		if rr.Match(name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+func (p *denialProof) nsec3Covering(name string) *dns.NSEC3 {
+	for _, rr := range p.nsec3 {
+		if rr.Cover(name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+// wildcardOf returns the wildcard name directly below name.
+func wildcardOf(name string) string {
+	if name == "." {
+		return "*."
+	}
+	return "*." + name
+}
+
+// lastLabels returns the last n labels of name.
+func lastLabels(name string, n int) string {
+	labels := dns.SplitDomainName(name)
+	if n <= 0 {
+		return "."
+	}
+	if n > len(labels) {
+		n = len(labels)
+	}
+	return strings.ToLower(dns.Fqdn(strings.Join(labels[len(labels)-n:], ".")))
+}
+
+// canonicalCompare orders names as in RFC 4034 §6.1, comparing labels
+// from the right.
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// worse returns the weaker of two validation states.
+func worse(a, b ValidationState) ValidationState {
+	rank := map[ValidationState]int{ValidationSecure: 0, ValidationInsecure: 1, ValidationBogus: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// dnssecQuery copies r with the DO and CD bits set, so upstreams return
+// signatures and leave validation to the proxy.
+func dnssecQuery(r *dns.Msg) *dns.Msg {
+	q := r.Copy()
+	q.CheckingDisabled = true
+	if opt := q.IsEdns0(); opt != nil {
+		opt.SetDo()
+	} else {
+		q.SetEdns0(4096, true)
+	}
+	return q
+}
+
+// dnssecReply prepares an upstream answer for the client that asked r.
+// When the answer was validated, bogus ones become SERVFAIL unless the
+// client set CD, secure ones get the AD bit, and DNSSEC records are
+// dropped unless the client set DO.
+func dnssecReply(r, resp *dns.Msg, state ValidationState) *dns.Msg {
+	clientOpt := r.IsEdns0()
+	if state == ValidationBogus && !r.CheckingDisabled {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		if clientOpt != nil {
+			// RFC 8914 extended error 6, DNSSEC Bogus
+			m.SetEdns0(clientOpt.UDPSize(), clientOpt.Do())
+			opt := m.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeDNSBogus})
+		}
+		return m
+	}
+
+	// SetReply would turn NXDOMAIN into NOERROR
+	rcode := resp.Rcode
+	resp.SetReply(r)
+	resp.Rcode = rcode
+	if state == "" {
+		return resp
+	}
+
+	resp.AuthenticatedData = state == ValidationSecure
+	if clientOpt != nil && clientOpt.Do() {
+		return resp
+	}
+	qtype := r.Question[0].Qtype
+	resp.Answer = stripDNSSEC(resp.Answer, qtype)
+	resp.Ns = stripDNSSEC(resp.Ns, qtype)
+	extra := resp.Extra[:0]
+	for _, rr := range resp.Extra {
+		if opt, ok := rr.(*dns.OPT); ok {
+			if clientOpt == nil {
+				continue
+			}
+			opt.SetDo(false)
+		}
+		extra = append(extra, rr)
+	}
+	resp.Extra = stripDNSSEC(extra, qtype)
+	return resp
+}
+
+// stripDNSSEC drops RRSIG, NSEC and NSEC3 records, other than those the
+// client asked for (RFC 4035 §3.2.1).
+func stripDNSSEC(rrs []dns.RR, qtype uint16) []dns.RR {
+	out := rrs[:0]
+	for _, rr := range rrs {
+		switch t := rr.Header().Rrtype; t {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			if t != qtype {
+				continue
+			}
+		}
+		out = append(out, rr)
+	}
+	return out
+}
@@ -0,0 +1,375 @@
+package dnsproxy
+
+import (
+	"crypto"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is a zone with one signing key.
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns rrs followed by a signature valid from an hour ago to an
+// hour from now.
+func (z *testZone) sign(t *testing.T, rrs ...dns.RR) []dns.RR {
+	return z.signAt(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), rrs...)
+}
+
+func (z *testZone) signAt(t *testing.T, inception, expiration time.Time, rrs ...dns.RR) []dns.RR {
+	t.Helper()
+	h := rrs[0].Header()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: h.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: h.Ttl},
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  z.key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, rrs); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrs, sig)
+}
+
+func testA(name, ip string) *dns.A {
+	return &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(ip)}
+}
+
+func testNSEC(owner, next string, types ...uint16) *dns.NSEC {
+	return &dns.NSEC{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300}, NextDomain: next, TypeBitMap: types}
+}
+
+// testNSEC3 returns an NSEC3 record in zone for the hash of owner, with an
+// unsalted SHA-1 hash and no extra iterations.
+func testNSEC3(zone, owner, nextHash string, types ...uint16) *dns.NSEC3 {
+	return &dns.NSEC3{
+		Hdr:        dns.RR_Header{Name: dns.HashName(owner, dns.SHA1, 0, "") + "." + zone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+		Hash:       dns.SHA1,
+		HashLength: 20,
+		NextDomain: nextHash,
+		TypeBitMap: types,
+	}
+}
+
+// testResolver stands in for a recursive upstream, answering from a
+// locally signed root and test. zone.
+type testResolver struct {
+	answers   map[string][]dns.RR // "name|TYPE" -> answer section
+	authority map[string][]dns.RR // "name|TYPE" -> authority section sent with the answer
+	denials   map[string][]dns.RR // name -> authority section when there is no answer
+	nxdomain  map[string]bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (tr *testResolver) exchange(r *dns.Msg) (*dns.Msg, error) {
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+	tr.mu.Lock()
+	tr.queries = append(tr.queries, name+"|"+dns.TypeToString[q.Qtype])
+	tr.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if rrs, ok := tr.answers[name+"|"+dns.TypeToString[q.Qtype]]; ok {
+		m.Answer = append([]dns.RR(nil), rrs...)
+		m.Ns = append([]dns.RR(nil), tr.authority[name+"|"+dns.TypeToString[q.Qtype]]...)
+		return m, nil
+	}
+	if tr.nxdomain[name] {
+		m.Rcode = dns.RcodeNameError
+	}
+	m.Ns = append([]dns.RR(nil), tr.denials[name]...)
+	return m, nil
+}
+
+func (tr *testResolver) lookups() int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return len(tr.queries)
+}
+
+// newTestResolver signs a root and a test. zone and returns a stand-in
+// resolver for them with the root's DS as trust anchor.
+//
+//	www.test.           signed A
+//	bad.test.           A whose signature does not match
+//	expired.test.       A with an expired signature
+//	nosig.test.         unsigned A in the signed zone
+//	www.insecure.test.  A under a delegation without DS
+//	missing.test.       NXDOMAIN with signed NSECs for the name and the wildcard
+//	forged.test.        NXDOMAIN without proof
+//	unrelated.test.     NXDOMAIN with a signed NSEC that doesn't cover it
+//	dangling.test.      NXDOMAIN with an NSEC for the name but not the wildcard
+//	empty.test.         NODATA with a signed NSEC for another name
+//	listed.test.        NODATA whose NSEC lists the type asked for
+//	host.wild.test.     A expanded from *.wild.test. with an NSEC for the name
+//	fake.wild.test.     A expanded from *.wild.test. without one
+//	gone.test.          NXDOMAIN with signed NSEC3 records
+//	stray.test.         NXDOMAIN with a signed NSEC3 that doesn't cover it
+//	www.alias.test.     signed DNAME alias.test. -> test. with the unsigned
+//	                    CNAME it synthesizes and the signed target A
+//	mail.alias.test.    signed DNAME with an unsigned CNAME it doesn't synthesize
+func newTestResolver(t *testing.T) (*testResolver, string) {
+	t.Helper()
+	root := newTestZone(t, ".")
+	tld := newTestZone(t, "test.")
+
+	bad := tld.sign(t, testA("bad.test.", "192.0.2.2"))
+	bad[0] = testA("bad.test.", "192.0.2.66")
+
+	nodata := func(owner string, types ...uint16) []dns.RR {
+		return tld.sign(t, testNSEC(owner, owner+"\\000."+strings.TrimSuffix(owner, "test."), types...))
+	}
+	// A signed *.wild.test. A record, served under another name
+	expand := func(name string) []dns.RR {
+		rrs := tld.sign(t, testA("*.wild.test.", "192.0.2.7"))
+		for _, rr := range rrs {
+			rr.Header().Name = name
+		}
+		return rrs
+	}
+	// alias.test. DNAME test., with an unsigned CNAME and the signed answer
+	dname := func(name, target string) []dns.RR {
+		rrs := tld.sign(t, &dns.DNAME{Hdr: dns.RR_Header{Name: "alias.test.", Rrtype: dns.TypeDNAME, Class: dns.ClassINET, Ttl: 300}, Target: "test."})
+		rrs = append(rrs, &dns.CNAME{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300}, Target: target})
+		return append(rrs, tld.sign(t, testA(target, "192.0.2.1"))...)
+	}
+	apex := tld.sign(t, testNSEC("test.", "bad.test.", dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY))
+	apexHash := dns.HashName("test.", dns.SHA1, 0, "")
+	wwwHash := dns.HashName("www.test.", dns.SHA1, 0, "")
+	tr := &testResolver{
+		answers: map[string][]dns.RR{
+			".|DNSKEY":             root.sign(t, root.key),
+			"test.|DS":             root.sign(t, tld.key.ToDS(dns.SHA256)),
+			"test.|DNSKEY":         tld.sign(t, tld.key),
+			"www.test.|A":          tld.sign(t, testA("www.test.", "192.0.2.1")),
+			"bad.test.|A":          bad,
+			"expired.test.|A":      tld.signAt(t, time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour), testA("expired.test.", "192.0.2.3")),
+			"nosig.test.|A":        {testA("nosig.test.", "192.0.2.4")},
+			"www.insecure.test.|A": {testA("www.insecure.test.", "192.0.2.5")},
+			"host.wild.test.|A":    expand("host.wild.test."),
+			"fake.wild.test.|A":    expand("fake.wild.test."),
+			"www.alias.test.|A":    dname("www.alias.test.", "www.test."),
+			"mail.alias.test.|A":   dname("mail.alias.test.", "www.test."),
+		},
+		authority: map[string][]dns.RR{
+			"host.wild.test.|A": tld.sign(t, testNSEC("*.wild.test.", "www.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)),
+			"fake.wild.test.|A": nodata("www.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+		},
+		denials: map[string][]dns.RR{
+			"insecure.test.":  tld.sign(t, testNSEC("insecure.test.", "missing.test.", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)),
+			"www.test.":       nodata("www.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			"bad.test.":       nodata("bad.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			"expired.test.":   nodata("expired.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			"nosig.test.":     nodata("nosig.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			"missing.test.":   append(tld.sign(t, testNSEC("insecure.test.", "nosig.test.", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)), apex...),
+			"unrelated.test.": append(nodata("www.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC), apex...),
+			"dangling.test.":  tld.sign(t, testNSEC("bad.test.", "expired.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)),
+			"empty.test.":     nodata("www.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			"listed.test.":    nodata("listed.test.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+			// One NSEC3 for the apex whose span wraps round the whole zone
+			"gone.test.":  tld.sign(t, testNSEC3("test.", "test.", apexHash, dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY)),
+			"stray.test.": tld.sign(t, testNSEC3("test.", "www.test.", wwwHash, dns.TypeA, dns.TypeRRSIG)),
+		},
+		nxdomain: map[string]bool{
+			"missing.test.": true, "forged.test.": true, "unrelated.test.": true, "dangling.test.": true,
+			"gone.test.": true, "stray.test.": true,
+		},
+	}
+	return tr, root.key.ToDS(dns.SHA256).String()
+}
+
+func TestValidator(t *testing.T) {
+	tr, anchor := newTestResolver(t)
+	v, err := NewValidator([]string{anchor}, tr.exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want ValidationState
+	}{
+		{"www.test.", ValidationSecure},
+		{"bad.test.", ValidationBogus},
+		{"expired.test.", ValidationBogus},
+		{"nosig.test.", ValidationBogus},
+		{"www.insecure.test.", ValidationInsecure},
+		{"missing.test.", ValidationSecure},
+		{"forged.test.", ValidationBogus},
+		{"unrelated.test.", ValidationBogus},
+		{"dangling.test.", ValidationBogus},
+		{"empty.test.", ValidationBogus},
+		{"listed.test.", ValidationBogus},
+		{"host.wild.test.", ValidationSecure},
+		{"fake.wild.test.", ValidationBogus},
+		{"gone.test.", ValidationSecure},
+		{"stray.test.", ValidationBogus},
+		{"www.alias.test.", ValidationSecure},
+		{"mail.alias.test.", ValidationBogus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := tr.exchange(testQuery(tt.name))
+			got, err := v.Validate(resp)
+			if got != tt.want {
+				t.Errorf("Validate() = %s (%v), want %s", got, err, tt.want)
+			}
+			if (err != nil) != (tt.want == ValidationBogus) {
+				t.Errorf("Validate() error = %v for a %s answer", err, got)
+			}
+		})
+	}
+}
+
+func TestValidatorCachesChain(t *testing.T) {
+	tr, anchor := newTestResolver(t)
+	v, err := NewValidator([]string{anchor}, tr.exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, _ := tr.exchange(testQuery("www.test."))
+	if st, err := v.Validate(resp); st != ValidationSecure {
+		t.Fatalf("Validate() = %s (%v), want secure", st, err)
+	}
+	before := tr.lookups()
+	if st, _ := v.Validate(resp); st != ValidationSecure {
+		t.Fatalf("second Validate() = %s, want secure", st)
+	}
+	if tr.lookups() != before {
+		t.Errorf("second validation made %d more lookups, want none", tr.lookups()-before)
+	}
+}
+
+func TestValidatorTrustAnchors(t *testing.T) {
+	tr, _ := newTestResolver(t)
+	resp, _ := tr.exchange(testQuery("www.test."))
+
+	// A root key nobody signed with
+	other := newTestZone(t, ".")
+	v, err := NewValidator([]string{other.key.ToDS(dns.SHA256).String()}, tr.exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := v.Validate(resp); st != ValidationBogus {
+		t.Errorf("Validate() with the wrong anchor = %s, want bogus", st)
+	}
+
+	// Names outside every anchor are insecure
+	v, err = NewValidator([]string{other.key.ToDS(dns.SHA256).String()}, tr.exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.anchors = map[string][]*dns.DS{"example.": v.anchors["."]}
+	if st, _ := v.Validate(resp); st != ValidationInsecure {
+		t.Errorf("Validate() outside the anchor = %s, want insecure", st)
+	}
+
+	if _, err := NewValidator([]string{"example.com. IN A 192.0.2.1"}, tr.exchange); err == nil {
+		t.Error("expected error for an A record as trust anchor")
+	}
+}
+
+func TestServerDNSSEC(t *testing.T) {
+	tr, anchor := newTestResolver(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp, _ := tr.exchange(r)
+		w.WriteMsg(resp)
+	})}
+	started := make(chan struct{})
+	upstream.NotifyStartedFunc = func() { close(started) }
+	go upstream.ActivateAndServe()
+	<-started
+	defer upstream.Shutdown()
+
+	cfg := testConfig()
+	cfg.Forwarders = []string{pc.LocalAddr().String()}
+	cfg.DNSSEC.Validate = true
+	cfg.DNSSEC.TrustAnchors = []string{anchor}
+	s := NewServer(cfg, testLogger())
+
+	ask := func(name string, do, cd bool) (*dns.Msg, QueryLogEntry) {
+		t.Helper()
+		q := testQuery(name)
+		q.CheckingDisabled = cd
+		if do {
+			q.SetEdns0(1232, true)
+		}
+		w := &dohResponseWriter{}
+		s.handleQuery(w, q)
+		if w.msg == nil {
+			t.Fatalf("no response for %s", name)
+		}
+		return w.msg, s.queryLog.Recent(1)[0]
+	}
+	hasSig := func(m *dns.Msg) bool {
+		for _, rr := range m.Answer {
+			if rr.Header().Rrtype == dns.TypeRRSIG {
+				return true
+			}
+		}
+		return false
+	}
+
+	resp, entry := ask("www.test.", true, false)
+	if !resp.AuthenticatedData || !hasSig(resp) || entry.DNSSEC != "secure" {
+		t.Errorf("secure answer with DO: AD = %v, signatures = %v, log = %q", resp.AuthenticatedData, hasSig(resp), entry.DNSSEC)
+	}
+	resp, entry = ask("www.test.", false, false)
+	if !resp.AuthenticatedData || hasSig(resp) || resp.IsEdns0() != nil || entry.Status != "cached" {
+		t.Errorf("cached secure answer without DO: AD = %v, signatures = %v, status = %q", resp.AuthenticatedData, hasSig(resp), entry.Status)
+	}
+
+	resp, entry = ask("www.insecure.test.", false, false)
+	if resp.AuthenticatedData || len(resp.Answer) != 1 || entry.DNSSEC != "insecure" {
+		t.Errorf("insecure answer: AD = %v, answers = %d, log = %q", resp.AuthenticatedData, len(resp.Answer), entry.DNSSEC)
+	}
+
+	for _, from := range []string{"upstream", "cache"} {
+		resp, entry = ask("bad.test.", true, false)
+		if resp.Rcode != dns.RcodeServerFailure || len(resp.Answer) != 0 || entry.Status != "bogus" {
+			t.Errorf("bogus answer from %s: rcode = %s, status = %q", from, dns.RcodeToString[resp.Rcode], entry.Status)
+		}
+	}
+	resp, _ = ask("bad.test.", false, true)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 || resp.AuthenticatedData {
+		t.Errorf("bogus answer with CD: rcode = %s, answers = %d, AD = %v", dns.RcodeToString[resp.Rcode], len(resp.Answer), resp.AuthenticatedData)
+	}
+
+	resp, _ = ask("missing.test.", false, false)
+	if resp.Rcode != dns.RcodeNameError || !resp.AuthenticatedData || len(resp.Ns) != 0 {
+		t.Errorf("signed NXDOMAIN: rcode = %s, AD = %v, authority = %d", dns.RcodeToString[resp.Rcode], resp.AuthenticatedData, len(resp.Ns))
+	}
+}
@@ -0,0 +1,105 @@
+package dnsproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/quic"
+)
+
+const (
+	doqALPN          = "doq" // TLS application protocol for DNS-over-QUIC
+	doqProtocolError = 0x2   // RFC 9250 error code for a malformed query
+)
+
+// doqServer is a DNS-over-QUIC listener (RFC 9250). Each query arrives on
+// its own bidirectional stream, length-prefixed as on TCP, and the answer
+// goes back on the same stream.
+type doqServer struct {
+	endpoint *quic.Endpoint
+	handler  dns.HandlerFunc
+	logger   *slog.Logger
+	cancel   context.CancelFunc
+}
+
+// listenDoQ starts a DoQ listener on addr serving queries with handler.
+func listenDoQ(addr string, cert tls.Certificate, handler dns.HandlerFunc, logger *slog.Logger) (*doqServer, error) {
+	ep, err := quic.Listen("udp", addr, &quic.Config{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{doqALPN},
+			MinVersion:   tls.VersionTLS13,
+		},
+		MaxIdleTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &doqServer{endpoint: ep, handler: handler, logger: logger, cancel: cancel}
+	go s.serve(ctx)
+	return s, nil
+}
+
+// serve accepts connections until the server is shut down.
+func (s *doqServer) serve(ctx context.Context) {
+	for {
+		conn, err := s.endpoint.Accept(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("DoQ listener error", "error", err)
+			}
+			return
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// serveConn answers every stream the client opens on conn.
+func (s *doqServer) serveConn(ctx context.Context, conn *quic.Conn) {
+	remote := net.UDPAddrFromAddrPort(conn.RemoteAddr())
+	for {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		go s.serveStream(ctx, stream, remote)
+	}
+}
+
+// serveStream reads one query from stream and writes the answer back.
+func (s *doqServer) serveStream(ctx context.Context, stream *quic.Stream, remote net.Addr) {
+	defer stream.Close()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	stream.SetReadContext(ctx)
+	stream.SetWriteContext(ctx)
+
+	msg, err := readStreamMsg(stream)
+	if err != nil {
+		stream.Reset(doqProtocolError)
+		return
+	}
+	w := &dohResponseWriter{remote: remote}
+	s.handler(w, msg)
+	if w.msg == nil {
+		stream.Reset(doqProtocolError)
+		return
+	}
+	if err := writeStreamMsg(stream, w.msg); err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.Debug("DoQ write failed", "remote", remote.String(), "error", err)
+	}
+}
+
+// shutdown closes the listener and every open connection.
+func (s *doqServer) shutdown() {
+	s.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.endpoint.Close(ctx)
+}
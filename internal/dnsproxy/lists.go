@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +29,9 @@ type ListStatus struct {
 	LastError       string    `json:"last_error,omitempty"`
 	RefreshInterval string    `json:"refresh_interval"`
 	NextRefresh     time.Time `json:"next_refresh"`
+
+	Schedule   []config.DNSScheduleConfig `json:"schedule,omitempty"`
+	InSchedule bool                       `json:"in_schedule"` // the schedule allows the list right now
 }
 
 // ListManager manages dynamic DNS filter lists (blocklists and allowlists).
@@ -42,9 +46,17 @@ type ListManager struct {
 }
 
 type managedList struct {
-	cfg     config.DNSListConfig
-	domains map[string]struct{} // lowercased FQDN -> present
-	status  ListStatus
+	cfg      config.DNSListConfig
+	domains  map[string]struct{} // lowercased FQDN -> present
+	status   ListStatus
+	used     bool     // enabled by a filtering policy
+	schedule []window // when the list applies; empty means always
+}
+
+// active reports whether the list is downloaded: it is enabled globally or
+// by some filtering policy.
+func (ml *managedList) active() bool {
+	return ml.cfg.Enabled || ml.used
 }
 
 // NewListManager creates a list manager from config. Call Start() to begin refresh loops.
@@ -66,7 +78,9 @@ func NewListManager(cfgs []config.DNSListConfig, logger *slog.Logger) *ListManag
 				Action:          c.Action,
 				Enabled:         c.Enabled,
 				RefreshInterval: c.RefreshInterval,
+				Schedule:        c.Schedule,
 			},
+			schedule: compileSchedule(c.Schedule),
 		}
 		if ml.cfg.Action == "" {
 			ml.cfg.Action = "nxdomain"
@@ -83,13 +97,26 @@ func NewListManager(cfgs []config.DNSListConfig, logger *slog.Logger) *ListManag
 	return lm
 }
 
+// Use marks the named lists as enabled by a filtering policy, so they are
+// downloaded and refreshed even if they are not enabled globally. Call it
+// before Start.
+func (lm *ListManager) Use(names []string) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	for i := range lm.lists {
+		if slices.Contains(names, lm.lists[i].cfg.Name) {
+			lm.lists[i].used = true
+		}
+	}
+}
+
 // Start performs initial download of all lists and begins periodic refresh goroutines.
 func (lm *ListManager) Start(ctx context.Context) {
 	ctx, lm.cancel = context.WithCancel(ctx)
 
-	// Initial fetch for all enabled lists
+	// Initial fetch for all active lists
 	for i := range lm.lists {
-		if !lm.lists[i].cfg.Enabled {
+		if !lm.lists[i].active() {
 			continue
 		}
 		lm.refreshList(i)
@@ -97,7 +124,7 @@ func (lm *ListManager) Start(ctx context.Context) {
 
 	// Start refresh goroutines
 	for i := range lm.lists {
-		if !lm.lists[i].cfg.Enabled {
+		if !lm.lists[i].active() {
 			continue
 		}
 		interval := lm.parseInterval(lm.lists[i].cfg.RefreshInterval)
@@ -123,7 +150,7 @@ func (lm *ListManager) Start(ctx context.Context) {
 	enabled := 0
 	total := 0
 	for _, ml := range lm.lists {
-		if ml.cfg.Enabled {
+		if ml.active() {
 			enabled++
 			total += len(ml.domains)
 		}
@@ -141,10 +168,25 @@ func (lm *ListManager) Stop() {
 	}
 }
 
-// Check tests a domain against all active lists.
+// Check tests a domain against all enabled lists whose schedule allows them at now.
 // Returns (blocked bool, action string, listName string).
 // Allowlists take priority — if a domain is on any allowlist, it is never blocked.
-func (lm *ListManager) Check(qname string) (blocked bool, action string, listName string) {
+func (lm *ListManager) Check(qname string, now time.Time) (blocked bool, action string, listName string) {
+	return lm.check(qname, func(ml *managedList) bool {
+		return ml.cfg.Enabled && inSchedule(ml.schedule, now)
+	})
+}
+
+// CheckLists is Check against only the named lists, whether or not they are
+// enabled globally. Filtering policies use it for the lists they enable.
+func (lm *ListManager) CheckLists(qname string, names []string, now time.Time) (blocked bool, action string, listName string) {
+	return lm.check(qname, func(ml *managedList) bool {
+		return slices.Contains(names, ml.cfg.Name) && inSchedule(ml.schedule, now)
+	})
+}
+
+// check tests a domain against the lists use selects, allowlists first.
+func (lm *ListManager) check(qname string, use func(*managedList) bool) (blocked bool, action string, listName string) {
 	domain := strings.ToLower(strings.TrimSuffix(qname, "."))
 	if domain == "" {
 		return false, "", ""
@@ -156,10 +198,10 @@ func (lm *ListManager) Check(qname string) (blocked bool, action string, listNam
 	// Check allowlists first
 	for i := range lm.lists {
 		ml := &lm.lists[i]
-		if !ml.cfg.Enabled || ml.cfg.Type != "allow" {
+		if !use(ml) || ml.cfg.Type != "allow" {
 			continue
 		}
-		if lm.matchDomain(ml, domain) {
+		if matchDomain(ml.domains, domain) {
 			return false, "", ""
 		}
 	}
@@ -167,10 +209,10 @@ func (lm *ListManager) Check(qname string) (blocked bool, action string, listNam
 	// Check blocklists
 	for i := range lm.lists {
 		ml := &lm.lists[i]
-		if !ml.cfg.Enabled || ml.cfg.Type != "block" {
+		if !use(ml) || ml.cfg.Type != "block" {
 			continue
 		}
-		if lm.matchDomain(ml, domain) {
+		if matchDomain(ml.domains, domain) {
 			return true, ml.cfg.Action, ml.cfg.Name
 		}
 	}
@@ -178,16 +220,16 @@ func (lm *ListManager) Check(qname string) (blocked bool, action string, listNam
 	return false, "", ""
 }
 
-// matchDomain checks if a domain or any of its parent domains are in the list.
-func (lm *ListManager) matchDomain(ml *managedList, domain string) bool {
+// matchDomain checks if a domain or any of its parent domains are in the set.
+func matchDomain(domains map[string]struct{}, domain string) bool {
 	// Exact match
-	if _, ok := ml.domains[domain]; ok {
+	if _, ok := domains[domain]; ok {
 		return true
 	}
 	// Walk up parent domains (e.g. ads.example.com -> example.com -> com)
 	parts := strings.SplitN(domain, ".", 2)
 	for len(parts) == 2 && parts[1] != "" {
-		if _, ok := ml.domains[parts[1]]; ok {
+		if _, ok := domains[parts[1]]; ok {
 			return true
 		}
 		parts = strings.SplitN(parts[1], ".", 2)
@@ -200,19 +242,21 @@ func (lm *ListManager) Statuses() []ListStatus {
 	lm.mu.RLock()
 	defer lm.mu.RUnlock()
 
+	now := time.Now()
 	result := make([]ListStatus, len(lm.lists))
 	for i, ml := range lm.lists {
 		s := ml.status
 		s.DomainCount = len(ml.domains)
+		s.InSchedule = inSchedule(ml.schedule, now)
 		result[i] = s
 	}
 	return result
 }
 
-// RefreshAll forces an immediate refresh of all enabled lists.
+// RefreshAll forces an immediate refresh of all active lists.
 func (lm *ListManager) RefreshAll() {
 	for i := range lm.lists {
-		if lm.lists[i].cfg.Enabled {
+		if lm.lists[i].active() {
 			lm.refreshList(i)
 		}
 	}
@@ -233,7 +277,8 @@ func (lm *ListManager) RefreshByName(name string) error {
 func (lm *ListManager) TestDomain(domain string) map[string]interface{} {
 	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
 
-	blocked, action, listName := lm.Check(domain + ".")
+	now := time.Now()
+	blocked, action, listName := lm.Check(domain+".", now)
 
 	result := map[string]interface{}{
 		"domain":  domain,
@@ -252,13 +297,14 @@ func (lm *ListManager) TestDomain(domain string) map[string]interface{} {
 	var matches []map[string]interface{}
 	for i := range lm.lists {
 		ml := &lm.lists[i]
-		if !ml.cfg.Enabled {
+		if !ml.active() {
 			continue
 		}
-		if lm.matchDomain(ml, domain) {
+		if matchDomain(ml.domains, domain) {
 			matches = append(matches, map[string]interface{}{
-				"list": ml.cfg.Name,
-				"type": ml.cfg.Type,
+				"list":        ml.cfg.Name,
+				"type":        ml.cfg.Type,
+				"in_schedule": inSchedule(ml.schedule, now),
 			})
 		}
 	}
@@ -273,7 +319,7 @@ func (lm *ListManager) TotalDomains() int {
 	defer lm.mu.RUnlock()
 	total := 0
 	for _, ml := range lm.lists {
-		if ml.cfg.Enabled {
+		if ml.active() {
 			total += len(ml.domains)
 		}
 	}
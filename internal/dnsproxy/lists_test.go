@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/miekg/dns"
@@ -167,7 +168,7 @@ func TestListManagerCheck(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.qname, func(t *testing.T) {
-			blocked, action, _ := lm.Check(tt.qname)
+			blocked, action, _ := lm.Check(tt.qname, time.Now())
 			if blocked != tt.blocked {
 				t.Errorf("Check(%q) blocked = %v, want %v", tt.qname, blocked, tt.blocked)
 			}
@@ -196,13 +197,13 @@ func TestListManagerAllowlistPriority(t *testing.T) {
 	lm.mu.Unlock()
 
 	// ads.example.com should be blocked
-	blocked, _, _ := lm.Check("ads.example.com.")
+	blocked, _, _ := lm.Check("ads.example.com.", time.Now())
 	if !blocked {
 		t.Error("ads.example.com should be blocked")
 	}
 
 	// good.example.com is on both lists, allowlist wins
-	blocked, _, _ = lm.Check("good.example.com.")
+	blocked, _, _ = lm.Check("good.example.com.", time.Now())
 	if blocked {
 		t.Error("good.example.com should NOT be blocked (allowlist priority)")
 	}
@@ -219,7 +220,7 @@ func TestListManagerDisabledList(t *testing.T) {
 	}
 	lm.mu.Unlock()
 
-	blocked, _, _ := lm.Check("ads.example.com.")
+	blocked, _, _ := lm.Check("ads.example.com.", time.Now())
 	if blocked {
 		t.Error("disabled list should not block anything")
 	}
@@ -244,7 +245,7 @@ func TestListManagerActions(t *testing.T) {
 		lm.lists[0].domains = map[string]struct{}{"blocked.com": {}}
 		lm.mu.Unlock()
 
-		_, action, name := lm.Check("blocked.com.")
+		_, action, name := lm.Check("blocked.com.", time.Now())
 		if action != tt.action {
 			t.Errorf("action = %q, want %q", action, tt.action)
 		}
@@ -396,12 +397,12 @@ func TestListManagerRefreshFromHTTP(t *testing.T) {
 	}
 
 	// Verify blocking works
-	blocked, _, _ := lm.Check("ads.test.com.")
+	blocked, _, _ := lm.Check("ads.test.com.", time.Now())
 	if !blocked {
 		t.Error("ads.test.com should be blocked after refresh")
 	}
 
-	blocked, _, _ = lm.Check("safe.test.com.")
+	blocked, _, _ = lm.Check("safe.test.com.", time.Now())
 	if blocked {
 		t.Error("safe.test.com should not be blocked")
 	}
@@ -0,0 +1,192 @@
+package dnsproxy
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxOverrideDuration is the longest a temporary filtering override may last.
+const MaxOverrideDuration = 7 * 24 * time.Hour
+
+// Override actions.
+const (
+	OverridePause = "pause" // no filtering at all for one client
+	OverrideAllow = "allow" // never block a domain
+	OverrideBlock = "block" // always block a domain
+)
+
+// Override is a temporary exception to DNS filtering that expires on its own.
+type Override struct {
+	ID      string    `json:"id"`
+	Action  string    `json:"action"`
+	Domain  string    `json:"domain,omitempty"`
+	Client  string    `json:"client,omitempty"` // IP or MAC; empty for every client
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	By      string    `json:"by,omitempty"`
+}
+
+// Overrides holds the active temporary filtering overrides. They live in
+// memory only, so a restart drops them along with the rest of the
+// proxy's runtime state.
+type Overrides struct {
+	mu     sync.RWMutex
+	items  []Override
+	nextID uint64
+}
+
+// NewOverrides creates an empty override set.
+func NewOverrides() *Overrides {
+	return &Overrides{}
+}
+
+// Add validates o, normalises its domain and client, and stores it until
+// now+d. Returns the stored override with its ID filled in.
+func (ov *Overrides) Add(o Override, d time.Duration, now time.Time) (Override, error) {
+	o.Action = strings.ToLower(o.Action)
+	o.Domain = strings.ToLower(strings.Trim(strings.TrimSpace(o.Domain), "."))
+	client, err := normaliseClient(o.Client)
+	if err != nil {
+		return Override{}, err
+	}
+	o.Client = client
+
+	switch o.Action {
+	case OverridePause:
+		if o.Client == "" {
+			return Override{}, fmt.Errorf("pause needs a client")
+		}
+		if o.Domain != "" {
+			return Override{}, fmt.Errorf("pause applies to every domain, got %q", o.Domain)
+		}
+	case OverrideAllow, OverrideBlock:
+		if o.Domain == "" {
+			return Override{}, fmt.Errorf("%s needs a domain", o.Action)
+		}
+	default:
+		return Override{}, fmt.Errorf("invalid action %q (must be pause, allow, or block)", o.Action)
+	}
+	if d <= 0 || d > MaxOverrideDuration {
+		return Override{}, fmt.Errorf("duration %s out of range (0 to %s)", d, MaxOverrideDuration)
+	}
+
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+	ov.nextID++
+	o.ID = strconv.FormatUint(ov.nextID, 10)
+	o.Created = now
+	o.Expires = now.Add(d)
+	ov.items = append(ov.items, o)
+	return o, nil
+}
+
+// Remove deletes the override with the given ID.
+func (ov *Overrides) Remove(id string) (Override, bool) {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+	for i, o := range ov.items {
+		if o.ID == id {
+			ov.items = slices.Delete(ov.items, i, i+1)
+			return o, true
+		}
+	}
+	return Override{}, false
+}
+
+// List returns the overrides still in effect at now, soonest to expire first.
+func (ov *Overrides) List(now time.Time) []Override {
+	ov.mu.RLock()
+	defer ov.mu.RUnlock()
+	result := make([]Override, 0, len(ov.items))
+	for _, o := range ov.items {
+		if now.Before(o.Expires) {
+			result = append(result, o)
+		}
+	}
+	slices.SortFunc(result, func(a, b Override) int {
+		return a.Expires.Compare(b.Expires)
+	})
+	return result
+}
+
+// Expire removes and returns the overrides that have run out by now.
+func (ov *Overrides) Expire(now time.Time) []Override {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+	var expired []Override
+	kept := ov.items[:0]
+	for _, o := range ov.items {
+		if now.Before(o.Expires) {
+			kept = append(kept, o)
+		} else {
+			expired = append(expired, o)
+		}
+	}
+	clear(ov.items[len(kept):])
+	ov.items = kept
+	return expired
+}
+
+// Check returns the action of the override that decides qname for the
+// client at now, or "" if none applies. Overrides for the client beat
+// ones for every client; within each, pause beats allow beats block.
+func (ov *Overrides) Check(qname string, c Client, now time.Time) string {
+	domain := strings.ToLower(strings.TrimSuffix(qname, "."))
+
+	ov.mu.RLock()
+	defer ov.mu.RUnlock()
+	if len(ov.items) == 0 {
+		return ""
+	}
+
+	best, bestRank := "", 0
+	for _, o := range ov.items {
+		if !now.Before(o.Expires) {
+			continue
+		}
+		if o.Domain != "" && domain != o.Domain && !strings.HasSuffix(domain, "."+o.Domain) {
+			continue
+		}
+		rank := 0
+		switch o.Action {
+		case OverridePause:
+			rank = 3
+		case OverrideAllow:
+			rank = 2
+		case OverrideBlock:
+			rank = 1
+		}
+		switch {
+		case o.Client == "":
+		case o.Client == c.MAC || (c.IP != nil && o.Client == c.IP.String()):
+			rank += 3
+		default:
+			continue
+		}
+		if rank > bestRank {
+			best, bestRank = o.Action, rank
+		}
+	}
+	return best
+}
+
+// normaliseClient returns an override client as a canonical IP or
+// lowercase MAC, or "" for every client.
+func normaliseClient(client string) (string, error) {
+	client = strings.TrimSpace(client)
+	if client == "" {
+		return "", nil
+	}
+	if ip := net.ParseIP(client); ip != nil {
+		return ip.String(), nil
+	}
+	if hw, err := net.ParseMAC(client); err == nil {
+		return hw.String(), nil
+	}
+	return "", fmt.Errorf("client %q is not an IP or MAC address", client)
+}
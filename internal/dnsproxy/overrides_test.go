@@ -0,0 +1,235 @@
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/miekg/dns"
+)
+
+func TestOverridesAdd(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		o          Override
+		d          time.Duration
+		wantErr    bool
+		wantClient string
+		wantDomain string
+	}{
+		{"pause client", Override{Action: "pause", Client: "AA:BB:CC:DD:EE:01"}, 30 * time.Minute, false, "aa:bb:cc:dd:ee:01", ""},
+		{"allow domain", Override{Action: "Allow", Domain: "Example.COM."}, time.Hour, false, "", "example.com"},
+		{"block for ip", Override{Action: "block", Domain: "games.example", Client: "10.0.0.5"}, time.Hour, false, "10.0.0.5", "games.example"},
+		{"pause everyone", Override{Action: "pause"}, time.Hour, true, "", ""},
+		{"pause with domain", Override{Action: "pause", Client: "10.0.0.5", Domain: "example.com"}, time.Hour, true, "", ""},
+		{"allow without domain", Override{Action: "allow"}, time.Hour, true, "", ""},
+		{"bad action", Override{Action: "mute", Domain: "example.com"}, time.Hour, true, "", ""},
+		{"bad client", Override{Action: "allow", Domain: "example.com", Client: "laptop"}, time.Hour, true, "", ""},
+		{"zero duration", Override{Action: "allow", Domain: "example.com"}, 0, true, "", ""},
+		{"too long", Override{Action: "allow", Domain: "example.com"}, 8 * 24 * time.Hour, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewOverrides().Add(tt.o, tt.d, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID == "" || got.Client != tt.wantClient || got.Domain != tt.wantDomain || !got.Expires.Equal(now.Add(tt.d)) {
+				t.Errorf("Add() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestOverridesCheck(t *testing.T) {
+	now := time.Now()
+	ov := NewOverrides()
+	add := func(o Override, d time.Duration) {
+		t.Helper()
+		if _, err := ov.Add(o, d, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(Override{Action: "block", Domain: "games.example"}, time.Hour)
+	add(Override{Action: "allow", Domain: "games.example", Client: "10.0.0.5"}, time.Hour)
+	add(Override{Action: "allow", Domain: "ads.example"}, time.Hour)
+	add(Override{Action: "block", Domain: "ads.example", Client: "aa:bb:cc:dd:ee:09"}, time.Hour)
+	add(Override{Action: "pause", Client: "aa:bb:cc:dd:ee:01"}, time.Hour)
+	add(Override{Action: "block", Domain: "old.example"}, time.Minute)
+
+	kid := Client{IP: net.ParseIP("10.0.0.5")}
+	paused := Client{IP: net.ParseIP("10.0.0.6"), MAC: "aa:bb:cc:dd:ee:01"}
+	other := Client{IP: net.ParseIP("10.0.0.7"), MAC: "aa:bb:cc:dd:ee:09"}
+
+	tests := []struct {
+		name   string
+		qname  string
+		client Client
+		at     time.Time
+		want   string
+	}{
+		{"global block", "www.games.example.", other, now, "block"},
+		{"client allow beats global block", "games.example.", kid, now, "allow"},
+		{"pause beats global block", "games.example.", paused, now, "pause"},
+		{"pause covers every domain", "example.org.", paused, now, "pause"},
+		{"client block beats global allow", "ads.example.", other, now, "block"},
+		{"global allow", "ads.example.", kid, now, "allow"},
+		{"suffix only on label boundary", "notgames.example.", other, now, ""},
+		{"no override", "example.org.", other, now, ""},
+		{"expired", "old.example.", other, now.Add(2 * time.Minute), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ov.Check(tt.qname, tt.client, tt.at); got != tt.want {
+				t.Errorf("Check(%q) = %q, want %q", tt.qname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverridesExpire(t *testing.T) {
+	now := time.Now()
+	ov := NewOverrides()
+	short, _ := ov.Add(Override{Action: "allow", Domain: "a.example"}, time.Minute, now)
+	long, _ := ov.Add(Override{Action: "allow", Domain: "b.example"}, time.Hour, now)
+
+	if got := ov.List(now); len(got) != 2 || got[0].ID != short.ID {
+		t.Fatalf("List() = %+v, want both, soonest first", got)
+	}
+
+	expired := ov.Expire(now.Add(10 * time.Minute))
+	if len(expired) != 1 || expired[0].ID != short.ID {
+		t.Errorf("Expire() = %+v, want only %s", expired, short.ID)
+	}
+	if got := ov.List(now); len(got) != 1 || got[0].ID != long.ID {
+		t.Errorf("List() after expiry = %+v", got)
+	}
+
+	if _, ok := ov.Remove(long.ID); !ok {
+		t.Error("Remove() did not find the override")
+	}
+	if _, ok := ov.Remove(long.ID); ok {
+		t.Error("Remove() found an override twice")
+	}
+}
+
+func TestServerOverrides(t *testing.T) {
+	cfg := testConfig()
+	cfg.Forwarders = nil
+	cfg.Policies = []config.DNSPolicyConfig{
+		{Name: "kids", Subnets: []string{"10.0.20.0/24"}, Block: []string{"games.example"}},
+	}
+	s := NewServer(cfg, testLogger())
+
+	bus := events.NewBus(10, testLogger())
+	ch := bus.Subscribe(10)
+	go bus.Start()
+	defer bus.Stop()
+	s.SetEventBus(bus)
+
+	query := func(name, source string) int {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeA)
+		w := &addrWriter{remote: &net.UDPAddr{IP: net.ParseIP(source), Port: 40000}}
+		s.handleQuery(w, msg)
+		if w.msg == nil {
+			t.Fatalf("no response to %s", source)
+		}
+		return w.msg.Rcode
+	}
+
+	if rcode := query("games.example.", "10.0.20.5"); rcode != dns.RcodeNameError {
+		t.Fatalf("policy block rcode = %s, want NXDOMAIN", dns.RcodeToString[rcode])
+	}
+
+	o, err := s.AddOverride(Override{Action: "pause", Client: "10.0.20.5", By: "admin"}, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evt := <-ch:
+		if evt.Type != events.EventDNSOverrideAdd || evt.Override == nil || evt.Override.By != "admin" {
+			t.Errorf("event = %+v, want dns.override_added by admin", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event for the new override")
+	}
+
+	// Paused: no forwarders, so the unfiltered lookup fails
+	if rcode := query("games.example.", "10.0.20.5"); rcode != dns.RcodeServerFailure {
+		t.Errorf("paused client rcode = %s, want SERVFAIL", dns.RcodeToString[rcode])
+	}
+
+	if _, err := s.AddOverride(Override{Action: "block", Domain: "social.example"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	if rcode := query("www.social.example.", "10.0.1.9"); rcode != dns.RcodeNameError {
+		t.Errorf("override block rcode = %s, want NXDOMAIN", dns.RcodeToString[rcode])
+	}
+	if entry := s.GetQueryLog().Recent(1)[0]; entry.ListName != "override" {
+		t.Errorf("query log list = %q, want override", entry.ListName)
+	}
+
+	if _, ok := s.RemoveOverride(o.ID, "admin"); !ok {
+		t.Fatal("RemoveOverride did not find the pause")
+	}
+	if evt := <-ch; evt.Type != events.EventDNSOverrideRemove {
+		t.Errorf("event = %s, want dns.override_removed", evt.Type)
+	}
+	if rcode := query("games.example.", "10.0.20.5"); rcode != dns.RcodeNameError {
+		t.Errorf("rcode after removing the pause = %s, want NXDOMAIN", dns.RcodeToString[rcode])
+	}
+}
+
+func TestPauseSkipsSafeSearch(t *testing.T) {
+	cfg := testConfig()
+	cfg.Forwarders = nil
+	cfg.Policies = []config.DNSPolicyConfig{
+		{Name: "kids", Subnets: []string{"10.0.20.0/24"}, SafeSearch: true},
+	}
+	s := NewServer(cfg, testLogger())
+
+	// Both answers are cached, so neither path needs an upstream
+	for _, rr := range []string{"www.google.com. 300 IN A 142.250.1.1", "forcesafesearch.google.com. 300 IN A 216.239.38.120"} {
+		a, err := dns.NewRR(rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := new(dns.Msg)
+		msg.SetQuestion(a.Header().Name, dns.TypeA)
+		msg.Answer = []dns.RR{a}
+		s.cache.Set(msg, time.Minute)
+	}
+
+	query := func() *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion("www.google.com.", dns.TypeA)
+		w := &addrWriter{remote: &net.UDPAddr{IP: net.ParseIP("10.0.20.5"), Port: 40000}}
+		s.handleQuery(w, msg)
+		if w.msg == nil {
+			t.Fatal("no response")
+		}
+		return w.msg
+	}
+
+	if resp := query(); len(resp.Answer) == 0 || resp.Answer[0].Header().Rrtype != dns.TypeCNAME {
+		t.Fatalf("answer = %v, want a safe search CNAME", resp.Answer)
+	}
+	if _, err := s.AddOverride(Override{Action: "pause", Client: "10.0.20.5"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	resp := query()
+	if len(resp.Answer) != 1 || resp.Answer[0].Header().Rrtype != dns.TypeA {
+		t.Errorf("paused answer = %v, want the plain A record", resp.Answer)
+	}
+	if got := s.TestDomainFor("www.google.com", "10.0.20.5"); got["safe_search"] != nil {
+		t.Errorf("TestDomainFor safe_search = %v for a paused client", got["safe_search"])
+	}
+}
@@ -0,0 +1,148 @@
+package dnsproxy
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// Client identifies the sender of a DNS query for filtering policies.
+type Client struct {
+	IP       net.IP
+	MAC      string // lowercase, empty if the source has no lease
+	Type     string // fingerprint device type
+	Reserved bool   // the MAC has a DHCP reservation
+}
+
+// Policy is a compiled filtering group (config.DNSPolicyConfig).
+type Policy struct {
+	Name        string
+	SafeSearch  bool
+	lists       []string
+	blockAction string
+
+	reserved bool
+	subnets  []*net.IPNet
+	macs     map[string]struct{}
+	types    map[string]struct{} // lowercased device types
+	block    map[string]struct{}
+	allow    map[string]struct{}
+}
+
+// NewPolicies compiles filtering policies from config, keeping their order.
+// Entries that fail to parse are skipped; config validation rejects them.
+func NewPolicies(cfgs []config.DNSPolicyConfig) []*Policy {
+	policies := make([]*Policy, 0, len(cfgs))
+	for _, c := range cfgs {
+		p := &Policy{
+			Name:        c.Name,
+			SafeSearch:  c.SafeSearch,
+			lists:       c.Lists,
+			blockAction: c.BlockAction,
+			reserved:    c.Reserved,
+			macs:        make(map[string]struct{}, len(c.MACs)),
+			types:       make(map[string]struct{}, len(c.DeviceTypes)),
+			block:       domainSet(c.Block),
+			allow:       domainSet(c.Allow),
+		}
+		if p.blockAction == "" {
+			p.blockAction = "nxdomain"
+		}
+		for _, cidr := range c.Subnets {
+			if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+				p.subnets = append(p.subnets, ipNet)
+			}
+		}
+		for _, mac := range c.MACs {
+			if hw, err := net.ParseMAC(mac); err == nil {
+				p.macs[hw.String()] = struct{}{}
+			}
+		}
+		for _, t := range c.DeviceTypes {
+			p.types[strings.ToLower(t)] = struct{}{}
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+// MatchPolicy returns the first policy that selects the client, or nil if
+// none does and the global lists apply.
+func MatchPolicy(policies []*Policy, c Client) *Policy {
+	for _, p := range policies {
+		if p.Selects(c) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Selects reports whether any of the policy's selectors matches the client.
+func (p *Policy) Selects(c Client) bool {
+	if c.IP != nil {
+		for _, n := range p.subnets {
+			if n.Contains(c.IP) {
+				return true
+			}
+		}
+	}
+	if c.MAC != "" {
+		if _, ok := p.macs[c.MAC]; ok {
+			return true
+		}
+	}
+	if p.reserved && c.Reserved {
+		return true
+	}
+	if c.Type != "" {
+		if _, ok := p.types[strings.ToLower(c.Type)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check tests a domain for a client under this policy: its custom allow
+// rules, then its custom block rules, then the lists it enables that are
+// in schedule at now.
+func (p *Policy) Check(qname string, lists *ListManager, now time.Time) (blocked bool, action string, listName string) {
+	domain := strings.ToLower(strings.TrimSuffix(qname, "."))
+	if domain == "" || matchDomain(p.allow, domain) {
+		return false, "", ""
+	}
+	if matchDomain(p.block, domain) {
+		return true, p.blockAction, "custom"
+	}
+	if lists == nil || len(p.lists) == 0 {
+		return false, "", ""
+	}
+	return lists.CheckLists(qname, p.lists, now)
+}
+
+// PolicyLists returns the names of every list some policy enables.
+func PolicyLists(cfgs []config.DNSPolicyConfig) []string {
+	var names []string
+	for _, c := range cfgs {
+		for _, name := range c.Lists {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// domainSet lowercases domains into a set for matchDomain.
+func domainSet(domains []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.Trim(strings.TrimSpace(d), "."))
+		if d != "" {
+			set[d] = struct{}{}
+		}
+	}
+	return set
+}
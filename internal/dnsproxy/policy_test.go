@@ -0,0 +1,166 @@
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/miekg/dns"
+)
+
+// addrWriter is a dohResponseWriter that reports a client address.
+type addrWriter struct {
+	dohResponseWriter
+	remote net.Addr
+}
+
+func (w *addrWriter) RemoteAddr() net.Addr { return w.remote }
+
+func TestMatchPolicy(t *testing.T) {
+	policies := NewPolicies([]config.DNSPolicyConfig{
+		{Name: "kids", Subnets: []string{"10.0.20.0/24"}},
+		{Name: "iot", DeviceTypes: []string{"IoT", "Camera"}},
+		{Name: "tv", MACs: []string{"AA:BB:CC:00:00:01"}},
+		{Name: "known", Reserved: true},
+	})
+
+	tests := []struct {
+		name   string
+		client Client
+		want   string
+	}{
+		{"subnet", Client{IP: net.ParseIP("10.0.20.7")}, "kids"},
+		{"device type", Client{IP: net.ParseIP("10.0.1.5"), MAC: "aa:bb:cc:00:00:09", Type: "iot"}, "iot"},
+		{"mac", Client{IP: net.ParseIP("10.0.1.6"), MAC: "aa:bb:cc:00:00:01"}, "tv"},
+		{"reserved", Client{IP: net.ParseIP("10.0.1.7"), MAC: "aa:bb:cc:00:00:02", Reserved: true}, "known"},
+		{"first match wins", Client{IP: net.ParseIP("10.0.20.8"), Type: "camera"}, "kids"},
+		{"none", Client{IP: net.ParseIP("10.0.1.8"), MAC: "aa:bb:cc:00:00:03", Type: "laptop"}, ""},
+		{"unknown source", Client{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if p := MatchPolicy(policies, tt.client); p != nil {
+				got = p.Name
+			}
+			if got != tt.want {
+				t.Errorf("MatchPolicy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	lm := NewListManager([]config.DNSListConfig{
+		{Name: "ads", URL: "http://test", Type: "block", Format: "domains", Action: "nxdomain", Enabled: true},
+		{Name: "adult", URL: "http://test", Type: "block", Format: "domains", Action: "refuse"},
+	}, testListLogger())
+	lm.lists[0].domains = map[string]struct{}{"ads.example.com": {}}
+	lm.lists[1].domains = map[string]struct{}{"adult.example": {}, "games.example": {}}
+
+	p := NewPolicies([]config.DNSPolicyConfig{{
+		Name:        "kids",
+		Subnets:     []string{"10.0.20.0/24"},
+		Lists:       []string{"adult"},
+		Block:       []string{"Video.Example."},
+		Allow:       []string{"games.example"},
+		BlockAction: "zero",
+	}})[0]
+
+	tests := []struct {
+		qname   string
+		blocked bool
+		action  string
+		list    string
+	}{
+		{"www.adult.example.", true, "refuse", "adult"}, // list only the policy enables
+		{"cdn.video.example.", true, "zero", "custom"},  // custom block rule
+		{"games.example.", false, "", ""},               // custom allow beats the list
+		{"ads.example.com.", false, "", ""},             // global list does not apply
+		{"example.org.", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qname, func(t *testing.T) {
+			blocked, action, list := p.Check(tt.qname, lm, time.Now())
+			if blocked != tt.blocked || action != tt.action || list != tt.list {
+				t.Errorf("Check(%q) = %v, %q, %q, want %v, %q, %q",
+					tt.qname, blocked, action, list, tt.blocked, tt.action, tt.list)
+			}
+		})
+	}
+
+	// Clients without a policy still see only the enabled lists
+	if blocked, _, _ := lm.Check("adult.example.", time.Now()); blocked {
+		t.Error("a list enabled only by a policy blocked a query outside it")
+	}
+}
+
+func TestServerPolicyQuery(t *testing.T) {
+	cfg := testConfig()
+	cfg.Forwarders = nil
+	cfg.Policies = []config.DNSPolicyConfig{
+		{Name: "iot", DeviceTypes: []string{"camera"}, Block: []string{"telemetry.example"}},
+	}
+	s := NewServer(cfg, testLogger())
+	s.DeviceMap().Update(net.ParseIP("10.0.0.50"), "AA:BB:CC:DD:EE:01", "cam1", "")
+	s.SetDeviceTypes(func(mac string) string {
+		if mac == "aa:bb:cc:dd:ee:01" {
+			return "Camera"
+		}
+		return ""
+	})
+
+	query := func(source string) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion("api.telemetry.example.", dns.TypeA)
+		w := &addrWriter{remote: &net.UDPAddr{IP: net.ParseIP(source), Port: 40000}}
+		s.handleQuery(w, msg)
+		if w.msg == nil {
+			t.Fatalf("no response to %s", source)
+		}
+		return w.msg
+	}
+
+	if resp := query("10.0.0.50"); resp.Rcode != dns.RcodeNameError {
+		t.Errorf("camera rcode = %s, want NXDOMAIN", dns.RcodeToString[resp.Rcode])
+	}
+	entry := s.GetQueryLog().Recent(1)[0]
+	if entry.Status != "blocked" || entry.Policy != "iot" || entry.ListName != "custom" || entry.DeviceHostname != "cam1" {
+		t.Errorf("query log entry = %+v, want blocked by the iot policy", entry)
+	}
+
+	// Another client is not filtered; with no forwarders the lookup fails
+	if resp := query("10.0.0.51"); resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("other client rcode = %s, want SERVFAIL", dns.RcodeToString[resp.Rcode])
+	}
+	if entry := s.GetQueryLog().Recent(1)[0]; entry.Policy != "" {
+		t.Errorf("query log policy = %q for a client no policy selects", entry.Policy)
+	}
+
+	if got := s.TestDomainFor("telemetry.example", "10.0.0.50"); got["blocked"] != true || got["policy"] != "iot" {
+		t.Errorf("TestDomainFor = %v, want blocked by iot", got)
+	}
+}
+
+func TestSafeSearchTarget(t *testing.T) {
+	tests := []struct {
+		qname string
+		want  string
+	}{
+		{"www.google.com.", "forcesafesearch.google.com."},
+		{"www.google.co.uk.", "forcesafesearch.google.com."},
+		{"google.de.", "forcesafesearch.google.com."},
+		{"WWW.Bing.com.", "strict.bing.com."},
+		{"duckduckgo.com.", "safe.duckduckgo.com."},
+		{"m.youtube.com.", "restrict.youtube.com."},
+		{"mail.google.com.", ""},
+		{"google.internal.example.", ""},
+		{"example.com.", ""},
+	}
+	for _, tt := range tests {
+		if got := safeSearchTarget(tt.qname); got != tt.want {
+			t.Errorf("safeSearchTarget(%q) = %q, want %q", tt.qname, got, tt.want)
+		}
+	}
+}
@@ -11,24 +11,28 @@ type QueryLogEntry struct {
 	Name      string    `json:"name"`
 	Type      string    `json:"type"`
 	Source    string    `json:"source"`
-	Status    string    `json:"status"` // "allowed", "blocked", "cached", "local", "forwarded", "failed"
+	Status    string    `json:"status"` // "allowed", "blocked", "safesearch", "cached", "local", "forwarded", "failed", "bogus"
 	Latency   float64   `json:"latency_ms"`
 	Answer    string    `json:"answer,omitempty"`
 	ListName  string    `json:"list_name,omitempty"`
 	Action    string    `json:"action,omitempty"`
+	Policy    string    `json:"policy,omitempty"` // filtering policy that applied to the client
+	DNSSEC    string    `json:"dnssec,omitempty"` // "secure", "insecure" or "bogus" when validating
 	// Device identity fields (populated by DNS-to-device mapping)
 	DeviceMAC      string `json:"device_mac,omitempty"`
 	DeviceHostname string `json:"device_hostname,omitempty"`
 	DeviceType     string `json:"device_type,omitempty"`
 }
 
-// QueryLog is a thread-safe ring buffer for DNS query log entries.
+// QueryLog is a thread-safe ring buffer for DNS query log entries, backed
+// by a QueryStore when the log is persisted.
 type QueryLog struct {
 	mu       sync.RWMutex
 	entries  []QueryLogEntry
 	capacity int
 	head     int
 	count    int
+	store    *QueryStore
 
 	// Subscribers for live streaming
 	subMu  sync.RWMutex
@@ -56,8 +60,13 @@ func (q *QueryLog) Add(entry QueryLogEntry) {
 	if q.count < q.capacity {
 		q.count++
 	}
+	store := q.store
 	q.mu.Unlock()
 
+	if store != nil {
+		store.Add(entry)
+	}
+
 	// Notify subscribers (non-blocking)
 	q.subMu.RLock()
 	for _, ch := range q.subs {
@@ -90,6 +99,77 @@ func (q *QueryLog) Recent(n int) []QueryLogEntry {
 	return result
 }
 
+// SetStore persists new entries to store and answers Search and Top from
+// it. nil goes back to memory only.
+func (q *QueryLog) SetStore(store *QueryStore) {
+	q.mu.Lock()
+	q.store = store
+	q.mu.Unlock()
+}
+
+// Persistent reports whether entries are written to disk.
+func (q *QueryLog) Persistent() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.store != nil
+}
+
+// Search returns the entries matching f, newest first, from disk when the
+// log is persisted and from the ring buffer otherwise.
+func (q *QueryLog) Search(f QueryFilter) ([]QueryLogEntry, error) {
+	q.mu.RLock()
+	store := q.store
+	q.mu.RUnlock()
+	if store != nil {
+		return store.Search(f)
+	}
+
+	f = f.normalise()
+	var results []QueryLogEntry
+	for _, e := range q.Recent(q.Count()) {
+		if len(results) >= f.Limit {
+			break
+		}
+		if f.matches(e) {
+			results = append(results, e)
+		}
+	}
+	return results, nil
+}
+
+// Top returns the busiest domains, blocked domains and clients between
+// from and to: from the hourly aggregates when the log is persisted, by
+// counting the ring buffer otherwise.
+func (q *QueryLog) Top(from, to time.Time, n int) (TopStats, error) {
+	q.mu.RLock()
+	store := q.store
+	q.mu.RUnlock()
+	if store != nil {
+		return store.Top(from, to, n)
+	}
+
+	from, to = statsRange(from, to)
+	total := newHourStats(0)
+	for _, e := range q.Recent(q.Count()) {
+		if !e.Timestamp.Before(from) && e.Timestamp.Before(to) {
+			total.add(e)
+		}
+	}
+	return total.top(from, to, n), nil
+}
+
+// Total returns the number of entries Search can reach: on disk when
+// persisted, in the ring buffer otherwise.
+func (q *QueryLog) Total() int {
+	q.mu.RLock()
+	store := q.store
+	q.mu.RUnlock()
+	if store != nil {
+		return store.Count()
+	}
+	return q.Count()
+}
+
 // Subscribe returns a channel that receives new query log entries.
 func (q *QueryLog) Subscribe(bufSize int) (int, chan QueryLogEntry) {
 	q.subMu.Lock()
@@ -0,0 +1,671 @@
+package dnsproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketQueries      = []byte("dns_querylog")
+	bucketQueryClients = []byte("dns_querylog_client") // client IP or MAC \x00 entry key
+	bucketQueryDomains = []byte("dns_querylog_domain") // query name \x00 entry key
+	bucketQueryStatus  = []byte("dns_querylog_status") // status \x00 entry key
+	bucketQueryHourly  = []byte("dns_querylog_hourly") // hour start → hourStats
+)
+
+const (
+	queryStoreBuffer    = 10000          // entries waiting to be written
+	queryStoreBatch     = 1000           // entries per write transaction
+	queryStoreFlush     = time.Second    // how often waiting entries are written
+	queryStorePrune     = time.Minute    // how often retention runs
+	queryStorePruneTx   = 5000           // entries deleted per transaction
+	hourlyTopSize       = 100            // entries kept per top table of a finished hour
+	hourlySnapshotLimit = 1000           // entries kept per table when saving the current hour
+	defaultStatsRange   = 24 * time.Hour // Top range when none is given
+	maxQueryResults     = 5000           // Search limit cap
+	queryKeyLen         = 12             // 8 byte timestamp + 4 byte sequence
+)
+
+// QueryFilter selects query log entries. Zero fields match everything.
+type QueryFilter struct {
+	Client string    // source IP or device MAC
+	Domain string    // exact query name, trailing dot optional
+	Status string    // "blocked", "forwarded", ...
+	From   time.Time // inclusive
+	To     time.Time // inclusive
+	Limit  int       // default 100
+}
+
+// normalise lowercases the filter's names and applies the default limit.
+func (f QueryFilter) normalise() QueryFilter {
+	f.Client = strings.ToLower(strings.TrimSpace(f.Client))
+	f.Domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(f.Domain), "."))
+	f.Status = strings.ToLower(strings.TrimSpace(f.Status))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	if f.Limit > maxQueryResults {
+		f.Limit = maxQueryResults
+	}
+	return f
+}
+
+// matches reports whether e passes every set field of a normalised filter.
+func (f QueryFilter) matches(e QueryLogEntry) bool {
+	if f.Client != "" && entryClient(e) != f.Client && strings.ToLower(e.DeviceMAC) != f.Client {
+		return false
+	}
+	if f.Domain != "" && entryDomain(e) != f.Domain {
+		return false
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	if !f.From.IsZero() && e.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && e.Timestamp.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// TopEntry is one row of a top table.
+type TopEntry struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// TopStats summarises the queries in a time range.
+type TopStats struct {
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Queries    int64      `json:"queries"`
+	Blocked    int64      `json:"blocked"`
+	TopDomains []TopEntry `json:"top_domains"`
+	TopBlocked []TopEntry `json:"top_blocked"`
+	TopClients []TopEntry `json:"top_clients"`
+}
+
+// hourStats is the pre-aggregated summary of one hour of queries.
+type hourStats struct {
+	Hour           int64            `json:"hour"` // unix seconds at the start of the hour
+	Queries        int64            `json:"queries"`
+	Blocked        int64            `json:"blocked"`
+	Domains        map[string]int64 `json:"domains"`
+	BlockedDomains map[string]int64 `json:"blocked_domains"`
+	Clients        map[string]int64 `json:"clients"`
+}
+
+func newHourStats(hour int64) *hourStats {
+	return &hourStats{
+		Hour:           hour,
+		Domains:        make(map[string]int64),
+		BlockedDomains: make(map[string]int64),
+		Clients:        make(map[string]int64),
+	}
+}
+
+// add counts one query.
+func (h *hourStats) add(e QueryLogEntry) {
+	h.Queries++
+	domain := entryDomain(e)
+	h.Domains[domain]++
+	if e.Status == "blocked" {
+		h.Blocked++
+		h.BlockedDomains[domain]++
+	}
+	if c := entryClient(e); c != "" {
+		h.Clients[c]++
+	}
+}
+
+// merge adds o's counts into h.
+func (h *hourStats) merge(o *hourStats) {
+	h.Queries += o.Queries
+	h.Blocked += o.Blocked
+	for k, v := range o.Domains {
+		h.Domains[k] += v
+	}
+	for k, v := range o.BlockedDomains {
+		h.BlockedDomains[k] += v
+	}
+	for k, v := range o.Clients {
+		h.Clients[k] += v
+	}
+}
+
+// trimmed returns a copy of h keeping the n biggest rows of each table.
+func (h *hourStats) trimmed(n int) *hourStats {
+	keep := func(m map[string]int64) map[string]int64 {
+		out := make(map[string]int64, n)
+		for _, e := range topN(m, n) {
+			out[e.Name] = e.Count
+		}
+		return out
+	}
+	return &hourStats{
+		Hour:           h.Hour,
+		Queries:        h.Queries,
+		Blocked:        h.Blocked,
+		Domains:        keep(h.Domains),
+		BlockedDomains: keep(h.BlockedDomains),
+		Clients:        keep(h.Clients),
+	}
+}
+
+// QueryStore is the on-disk DNS query log: every entry keyed by time,
+// indexed by client, domain and status, with per-hour top tables kept
+// alongside so dashboards don't scan raw entries. Writes are batched in
+// the background; entries that arrive faster than they can be written
+// are dropped rather than slowing queries down.
+type QueryStore struct {
+	db     boltdb.DB
+	logger *slog.Logger
+
+	maxAge      time.Duration
+	maxEntries  int
+	statsMaxAge time.Duration
+
+	ch    chan QueryLogEntry
+	done  chan struct{}
+	wg    sync.WaitGroup
+	seq   uint32
+	count atomic.Int64
+
+	hourMu sync.Mutex
+	hour   *hourStats // the hour being aggregated
+}
+
+// NewQueryStore opens the query log buckets in db and loads the current
+// hour's aggregates. Call Start to begin writing.
+func NewQueryStore(db boltdb.DB, cfg config.DNSQueryLogConfig, logger *slog.Logger) (*QueryStore, error) {
+	qs := &QueryStore{
+		db:          db,
+		logger:      logger,
+		maxAge:      parseDurationOr(cfg.MaxAge, config.DefaultDNSQueryLogMaxAge),
+		maxEntries:  cfg.MaxEntries,
+		statsMaxAge: parseDurationOr(cfg.StatsMaxAge, config.DefaultDNSQueryStatsMaxAge),
+		ch:          make(chan QueryLogEntry, queryStoreBuffer),
+		done:        make(chan struct{}),
+	}
+	if qs.maxEntries <= 0 {
+		qs.maxEntries = config.DefaultDNSQueryLogEntries
+	}
+
+	hour := time.Now().Truncate(time.Hour).Unix()
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketQueries, bucketQueryClients, bucketQueryDomains, bucketQueryStatus, bucketQueryHourly} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("creating %s bucket: %w", name, err)
+			}
+		}
+		qs.count.Store(int64(tx.Bucket(bucketQueries).Stats().KeyN))
+
+		qs.hour = newHourStats(hour)
+		if data := tx.Bucket(bucketQueryHourly).Get(uint64Key(uint64(hour))); data != nil {
+			var saved hourStats
+			if err := json.Unmarshal(data, &saved); err == nil {
+				qs.hour.merge(&saved)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
+
+// Start begins writing queued entries and enforcing retention.
+func (qs *QueryStore) Start() {
+	qs.wg.Add(1)
+	go qs.run()
+}
+
+// Stop writes what is queued, saves the current hour's aggregates and
+// stops the background writer.
+func (qs *QueryStore) Stop() {
+	close(qs.done)
+	qs.wg.Wait()
+}
+
+// Add queues an entry for writing. Never blocks.
+func (qs *QueryStore) Add(e QueryLogEntry) {
+	select {
+	case qs.ch <- e:
+	default:
+		metrics.DNSQueryLogDropped.Inc()
+	}
+}
+
+// Count returns the number of entries on disk.
+func (qs *QueryStore) Count() int {
+	return int(qs.count.Load())
+}
+
+func (qs *QueryStore) run() {
+	defer qs.wg.Done()
+	flush := time.NewTicker(queryStoreFlush)
+	defer flush.Stop()
+	prune := time.NewTicker(queryStorePrune)
+	defer prune.Stop()
+
+	batch := make([]QueryLogEntry, 0, queryStoreBatch)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := qs.write(batch); err != nil {
+			qs.logger.Error("failed to write DNS query log", "entries", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-qs.ch:
+			batch = append(batch, e)
+			if len(batch) >= queryStoreBatch {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case now := <-prune.C:
+			write()
+			if _, err := qs.Prune(now); err != nil {
+				qs.logger.Error("failed to prune DNS query log", "error", err)
+			}
+			if err := qs.saveHour(); err != nil {
+				qs.logger.Error("failed to save DNS query stats", "error", err)
+			}
+		case <-qs.done:
+			for len(qs.ch) > 0 {
+				batch = append(batch, <-qs.ch)
+				if len(batch) >= queryStoreBatch {
+					write()
+				}
+			}
+			write()
+			if err := qs.saveHour(); err != nil {
+				qs.logger.Error("failed to save DNS query stats", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// write stores a batch of entries with their index keys and counts them
+// into the hourly aggregates. A finished hour is saved trimmed to its top
+// rows when the first entry of the next one arrives. Entries that arrive
+// after their hour has finished are added to its saved aggregates.
+func (qs *QueryStore) write(batch []QueryLogEntry) error {
+	var finished []*hourStats
+	late := make(map[int64]*hourStats)
+	qs.hourMu.Lock()
+	for _, e := range batch {
+		hour := e.Timestamp.Truncate(time.Hour).Unix()
+		if hour > qs.hour.Hour {
+			finished = append(finished, qs.hour.trimmed(hourlyTopSize))
+			qs.hour = newHourStats(hour)
+		}
+		if hour == qs.hour.Hour {
+			qs.hour.add(e)
+			continue
+		}
+		h := late[hour]
+		if h == nil {
+			h = newHourStats(hour)
+			late[hour] = h
+		}
+		h.add(e)
+	}
+	qs.hourMu.Unlock()
+
+	written := 0
+	err := qs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueries)
+		for _, e := range batch {
+			qs.seq++
+			key := queryKey(e.Timestamp, qs.seq)
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("marshalling query log entry: %w", err)
+			}
+			if err := b.Put(key, data); err != nil {
+				return fmt.Errorf("storing query log entry: %w", err)
+			}
+			if err := forEachIndex(tx, e, key, func(idx *bolt.Bucket, k []byte) error {
+				return idx.Put(k, []byte{})
+			}); err != nil {
+				return err
+			}
+			written++
+		}
+		for _, h := range finished {
+			if err := putHour(tx, h); err != nil {
+				return err
+			}
+		}
+		for hour, h := range late {
+			total := newHourStats(hour)
+			if data := tx.Bucket(bucketQueryHourly).Get(uint64Key(uint64(hour))); data != nil {
+				var saved hourStats
+				if err := json.Unmarshal(data, &saved); err == nil {
+					total.merge(&saved)
+				}
+			}
+			total.merge(h)
+			if err := putHour(tx, total.trimmed(hourlyTopSize)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	qs.count.Add(int64(written))
+	return nil
+}
+
+// saveHour stores the hour being aggregated so a restart picks it up.
+func (qs *QueryStore) saveHour() error {
+	qs.hourMu.Lock()
+	h := qs.hour.trimmed(hourlySnapshotLimit)
+	qs.hourMu.Unlock()
+	return qs.db.Update(func(tx *bolt.Tx) error {
+		return putHour(tx, h)
+	})
+}
+
+// Prune deletes entries older than max_age and the oldest entries beyond
+// max_entries, and hourly aggregates older than stats_max_age. Returns the
+// number of entries deleted.
+func (qs *QueryStore) Prune(now time.Time) (int, error) {
+	cutoff := queryKey(now.Add(-qs.maxAge), 0)
+	deleted := 0
+	for {
+		n := 0
+		err := qs.db.Update(func(tx *bolt.Tx) error {
+			c := tx.Bucket(bucketQueries).Cursor()
+			excess := int(qs.count.Load()) - qs.maxEntries
+			for k, v := c.First(); k != nil && n < queryStorePruneTx; k, v = c.First() {
+				if bytes.Compare(k, cutoff) >= 0 && n >= excess {
+					break
+				}
+				var e QueryLogEntry
+				if err := json.Unmarshal(v, &e); err == nil {
+					if err := forEachIndex(tx, e, k, func(idx *bolt.Bucket, ik []byte) error {
+						return idx.Delete(ik)
+					}); err != nil {
+						return err
+					}
+				}
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+		qs.count.Add(int64(-n))
+		if n < queryStorePruneTx {
+			break
+		}
+	}
+
+	statsCutoff := uint64(now.Add(-qs.statsMaxAge).Truncate(time.Hour).Unix())
+	err := qs.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketQueryHourly).Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < statsCutoff; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if deleted > 0 {
+		qs.logger.Debug("pruned DNS query log", "deleted", deleted, "remaining", qs.count.Load())
+	}
+	return deleted, err
+}
+
+// Search returns the entries matching f, newest first. It walks the most
+// selective index the filter allows: client, then domain, then status,
+// and otherwise the entries themselves within the time range.
+func (qs *QueryStore) Search(f QueryFilter) ([]QueryLogEntry, error) {
+	f = f.normalise()
+	bucket, prefix := bucketQueries, []byte(nil)
+	switch {
+	case f.Client != "":
+		bucket, prefix = bucketQueryClients, indexPrefix(f.Client)
+	case f.Domain != "":
+		bucket, prefix = bucketQueryDomains, indexPrefix(f.Domain)
+	case f.Status != "":
+		bucket, prefix = bucketQueryStatus, indexPrefix(f.Status)
+	}
+
+	var results []QueryLogEntry
+	err := qs.db.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(bucketQueries)
+		c := tx.Bucket(bucket).Cursor()
+
+		upper := append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, queryKeyLen)...)
+		if !f.To.IsZero() {
+			upper = append(append([]byte{}, prefix...), queryKey(f.To, ^uint32(0))...)
+		}
+		k, v := c.Seek(upper)
+		if k == nil {
+			k, v = c.Last()
+		}
+		for ; k != nil && bytes.Compare(k, upper) > 0; k, v = c.Prev() {
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(results) < f.Limit; k, v = c.Prev() {
+			key := k[len(prefix):]
+			if len(key) != queryKeyLen {
+				continue
+			}
+			if !f.From.IsZero() && keyTime(key).Before(f.From) {
+				break
+			}
+			if prefix != nil {
+				v = entries.Get(key)
+			}
+			var e QueryLogEntry
+			if v == nil || json.Unmarshal(v, &e) != nil {
+				continue
+			}
+			if f.matches(e) {
+				results = append(results, e)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// Top sums the hourly aggregates for the hours overlapping [from, to) and
+// returns the n biggest rows of each table. A zero from means the last 24
+// hours; a zero to means now.
+func (qs *QueryStore) Top(from, to time.Time, n int) (TopStats, error) {
+	from, to = statsRange(from, to)
+	total := newHourStats(0)
+
+	current := qs.currentHour()
+	err := qs.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketQueryHourly).Cursor()
+		start := uint64Key(uint64(from.Truncate(time.Hour).Unix()))
+		for k, v := c.Seek(start); k != nil && int64(binary.BigEndian.Uint64(k)) < to.Unix(); k, v = c.Next() {
+			var h hourStats
+			if err := json.Unmarshal(v, &h); err != nil {
+				continue
+			}
+			if h.Hour == current {
+				continue // counted from memory below
+			}
+			total.merge(&h)
+		}
+		return nil
+	})
+	if err != nil {
+		return TopStats{}, err
+	}
+
+	qs.hourMu.Lock()
+	if qs.hour.Hour >= from.Truncate(time.Hour).Unix() && qs.hour.Hour < to.Unix() {
+		total.merge(qs.hour)
+	}
+	qs.hourMu.Unlock()
+
+	return total.top(from, to, n), nil
+}
+
+// currentHour returns the start of the hour being aggregated.
+func (qs *QueryStore) currentHour() int64 {
+	qs.hourMu.Lock()
+	defer qs.hourMu.Unlock()
+	return qs.hour.Hour
+}
+
+// top turns summed counts into TopStats.
+func (h *hourStats) top(from, to time.Time, n int) TopStats {
+	if n <= 0 {
+		n = 10
+	}
+	return TopStats{
+		From:       from,
+		To:         to,
+		Queries:    h.Queries,
+		Blocked:    h.Blocked,
+		TopDomains: topN(h.Domains, n),
+		TopBlocked: topN(h.BlockedDomains, n),
+		TopClients: topN(h.Clients, n),
+	}
+}
+
+// statsRange applies Top's defaults to a time range.
+func statsRange(from, to time.Time) (time.Time, time.Time) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultStatsRange)
+	}
+	return from, to
+}
+
+// topN returns the n biggest counts in m, biggest first, ties by name.
+func topN(m map[string]int64, n int) []TopEntry {
+	rows := make([]TopEntry, 0, len(m))
+	for k, v := range m {
+		rows = append(rows, TopEntry{Name: k, Count: v})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	if len(rows) > n {
+		rows = rows[:n]
+	}
+	return rows
+}
+
+// forEachIndex calls fn with every index bucket and key for the entry
+// stored under key.
+func forEachIndex(tx *bolt.Tx, e QueryLogEntry, key []byte, fn func(*bolt.Bucket, []byte) error) error {
+	add := func(bucket []byte, value string) error {
+		if value == "" {
+			return nil
+		}
+		return fn(tx.Bucket(bucket), append(indexPrefix(value), key...))
+	}
+	if err := add(bucketQueryClients, entryClient(e)); err != nil {
+		return err
+	}
+	if mac := strings.ToLower(e.DeviceMAC); mac != "" && mac != entryClient(e) {
+		if err := add(bucketQueryClients, mac); err != nil {
+			return err
+		}
+	}
+	if err := add(bucketQueryDomains, entryDomain(e)); err != nil {
+		return err
+	}
+	return add(bucketQueryStatus, e.Status)
+}
+
+// putHour stores an hour's aggregates.
+func putHour(tx *bolt.Tx, h *hourStats) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("marshalling query stats: %w", err)
+	}
+	return tx.Bucket(bucketQueryHourly).Put(uint64Key(uint64(h.Hour)), data)
+}
+
+// queryKey is the time-ordered key of an entry.
+func queryKey(t time.Time, seq uint32) []byte {
+	key := make([]byte, queryKeyLen)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint32(key[8:], seq)
+	return key
+}
+
+// keyTime returns the timestamp in an entry key.
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// indexPrefix is the index key prefix for a value.
+func indexPrefix(value string) []byte {
+	return append([]byte(value), 0)
+}
+
+func uint64Key(v uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, v)
+	return key
+}
+
+// entryClient returns the source IP of an entry, without the port.
+func entryClient(e QueryLogEntry) string {
+	host, _, err := net.SplitHostPort(e.Source)
+	if err != nil {
+		host = e.Source
+	}
+	return strings.ToLower(host)
+}
+
+// entryDomain returns an entry's query name without the trailing dot.
+func entryDomain(e QueryLogEntry) string {
+	return strings.ToLower(strings.TrimSuffix(e.Name, "."))
+}
+
+// parseDurationOr parses s, falling back to def when it is empty or bad.
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
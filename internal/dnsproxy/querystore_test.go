@@ -0,0 +1,223 @@
+package dnsproxy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testQueryDB(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "querylog.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testQueryStore(t *testing.T, db *bolt.DB, cfg config.DNSQueryLogConfig) *QueryStore {
+	t.Helper()
+	qs, err := NewQueryStore(db, cfg, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qs
+}
+
+// testQueries returns six queries a minute apart, starting at base.
+func testQueries(base time.Time) []QueryLogEntry {
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+	return []QueryLogEntry{
+		{Timestamp: at(0), Name: "example.com.", Source: "10.0.0.5:5353", Status: "forwarded"},
+		{Timestamp: at(1), Name: "ads.example.", Source: "10.0.0.5:5353", Status: "blocked", DeviceMAC: "AA:BB:CC:DD:EE:05"},
+		{Timestamp: at(2), Name: "example.com.", Source: "10.0.0.6:5353", Status: "cached"},
+		{Timestamp: at(3), Name: "ads.example.", Source: "10.0.0.50:5353", Status: "blocked"},
+		{Timestamp: at(4), Name: "example.com.", Source: "10.0.0.5:5353", Status: "cached"},
+		{Timestamp: at(5), Name: "tracker.example.", Source: "10.0.0.6:5353", Status: "blocked"},
+	}
+}
+
+func TestQueryStoreSearch(t *testing.T) {
+	base := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	qs := testQueryStore(t, testQueryDB(t), config.DNSQueryLogConfig{})
+	if err := qs.write(testQueries(base)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   []int // minutes past base, newest first
+	}{
+		{"everything", QueryFilter{}, []int{5, 4, 3, 2, 1, 0}},
+		{"limit", QueryFilter{Limit: 2}, []int{5, 4}},
+		{"client ip", QueryFilter{Client: "10.0.0.5"}, []int{4, 1, 0}},
+		{"client ip is not a prefix", QueryFilter{Client: "10.0.0.50"}, []int{3}},
+		{"client mac", QueryFilter{Client: "aa:bb:cc:dd:ee:05"}, []int{1}},
+		{"domain", QueryFilter{Domain: "Example.com."}, []int{4, 2, 0}},
+		{"status", QueryFilter{Status: "blocked"}, []int{5, 3, 1}},
+		{"client and status", QueryFilter{Client: "10.0.0.6", Status: "blocked"}, []int{5}},
+		{"time range", QueryFilter{From: base.Add(time.Minute), To: base.Add(3 * time.Minute)}, []int{3, 2, 1}},
+		{"domain in range", QueryFilter{Domain: "ads.example", To: base.Add(2 * time.Minute)}, []int{1}},
+		{"no match", QueryFilter{Domain: "nothing.example"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qs.Search(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search() returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, m := range tt.want {
+				if want := base.Add(time.Duration(m) * time.Minute); !got[i].Timestamp.Equal(want) {
+					t.Errorf("entry %d at %s, want %s", i, got[i].Timestamp.Format("15:04"), want.Format("15:04"))
+				}
+			}
+		})
+	}
+}
+
+func TestQueryStorePrune(t *testing.T) {
+	base := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+
+	t.Run("by age", func(t *testing.T) {
+		qs := testQueryStore(t, testQueryDB(t), config.DNSQueryLogConfig{MaxAge: "1h"})
+		if err := qs.write(testQueries(base)); err != nil {
+			t.Fatal(err)
+		}
+		deleted, err := qs.Prune(base.Add(time.Hour + 150*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 3 || qs.Count() != 3 {
+			t.Errorf("Prune() deleted %d leaving %d, want 3 and 3", deleted, qs.Count())
+		}
+		// Index entries go with the entries
+		if got, _ := qs.Search(QueryFilter{Client: "10.0.0.5"}); len(got) != 1 {
+			t.Errorf("client search after prune = %d entries, want 1", len(got))
+		}
+	})
+
+	t.Run("by count", func(t *testing.T) {
+		qs := testQueryStore(t, testQueryDB(t), config.DNSQueryLogConfig{MaxEntries: 4})
+		if err := qs.write(testQueries(base)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := qs.Prune(base.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := qs.Search(QueryFilter{})
+		if len(got) != 4 || !got[3].Timestamp.Equal(base.Add(2*time.Minute)) {
+			t.Errorf("Prune() kept %d entries, want the newest 4", len(got))
+		}
+	})
+}
+
+func TestQueryStoreTop(t *testing.T) {
+	// Aggregation starts at the hour the store was opened in
+	base := time.Now().Truncate(time.Hour)
+	db := testQueryDB(t)
+	qs := testQueryStore(t, db, config.DNSQueryLogConfig{})
+	if err := qs.write(testQueries(base)); err != nil {
+		t.Fatal(err)
+	}
+	// The next hour's first query rolls this one over to disk
+	if err := qs.write(testQueries(base.Add(time.Hour))[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := qs.Top(base, base.Add(2*time.Hour), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Queries != 7 || stats.Blocked != 3 {
+		t.Errorf("Top() counted %d queries, %d blocked; want 7 and 3", stats.Queries, stats.Blocked)
+	}
+	if len(stats.TopDomains) != 2 || stats.TopDomains[0] != (TopEntry{"example.com", 4}) {
+		t.Errorf("TopDomains = %+v", stats.TopDomains)
+	}
+	if len(stats.TopBlocked) != 2 || stats.TopBlocked[0] != (TopEntry{"ads.example", 2}) {
+		t.Errorf("TopBlocked = %+v", stats.TopBlocked)
+	}
+	if stats.TopClients[0] != (TopEntry{"10.0.0.5", 4}) {
+		t.Errorf("TopClients = %+v", stats.TopClients)
+	}
+
+	if stats, _ := qs.Top(base.Add(time.Hour), base.Add(2*time.Hour), 10); stats.Queries != 1 {
+		t.Errorf("Top() for the second hour counted %d queries, want 1", stats.Queries)
+	}
+}
+
+func TestQueryStoreLateEntries(t *testing.T) {
+	base := time.Now().Truncate(time.Hour)
+	db := testQueryDB(t)
+	qs := testQueryStore(t, db, config.DNSQueryLogConfig{})
+
+	// Queued entries from the previous hour, written once this one started
+	if err := qs.write(testQueries(base.Add(-time.Hour))[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := qs.write(testQueries(base.Add(-time.Hour))[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := qs.Top(base.Add(-time.Hour), base, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Queries != 6 || stats.Blocked != 3 {
+		t.Errorf("Top() for the previous hour counted %d queries, %d blocked; want 6 and 3", stats.Queries, stats.Blocked)
+	}
+	if stats, _ := qs.Top(base, base.Add(time.Hour), 10); stats.Queries != 0 {
+		t.Errorf("Top() for the current hour counted %d queries, want 0", stats.Queries)
+	}
+}
+
+func TestQueryStoreReopen(t *testing.T) {
+	db := testQueryDB(t)
+	qs := testQueryStore(t, db, config.DNSQueryLogConfig{})
+	qs.Start()
+	for _, e := range testQueries(time.Now().Truncate(time.Hour)) {
+		qs.Add(e)
+	}
+	qs.Stop()
+
+	qs = testQueryStore(t, db, config.DNSQueryLogConfig{})
+	if qs.Count() != 6 {
+		t.Errorf("Count() after reopening = %d, want 6", qs.Count())
+	}
+	stats, err := qs.Top(time.Time{}, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Queries != 6 {
+		t.Errorf("Top() after reopening counted %d queries, want 6", stats.Queries)
+	}
+}
+
+func TestQueryLogSearchMemory(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	ql := NewQueryLog(100)
+	for _, e := range testQueries(base) {
+		ql.Add(e)
+	}
+
+	got, err := ql.Search(QueryFilter{Client: "10.0.0.6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Timestamp.Equal(base.Add(5*time.Minute)) {
+		t.Errorf("Search() = %+v", got)
+	}
+
+	stats, _ := ql.Top(time.Time{}, time.Time{}, 1)
+	if stats.Queries != 6 || len(stats.TopBlocked) != 1 || stats.TopBlocked[0].Name != "ads.example" {
+		t.Errorf("Top() = %+v", stats)
+	}
+}
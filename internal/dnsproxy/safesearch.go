@@ -0,0 +1,77 @@
+package dnsproxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// safeSearchHosts maps search engine hostnames to the address each engine
+// serves with safe search forced on.
+var safeSearchHosts = map[string]string{
+	"www.bing.com":             "strict.bing.com.",
+	"duckduckgo.com":           "safe.duckduckgo.com.",
+	"www.duckduckgo.com":       "safe.duckduckgo.com.",
+	"www.youtube.com":          "restrict.youtube.com.",
+	"m.youtube.com":            "restrict.youtube.com.",
+	"youtubei.googleapis.com":  "restrict.youtube.com.",
+	"youtube.googleapis.com":   "restrict.youtube.com.",
+	"www.youtube-nocookie.com": "restrict.youtube.com.",
+	"www.google.com":           "forcesafesearch.google.com.",
+	"google.com":               "forcesafesearch.google.com.",
+}
+
+// safeSearchTarget returns the safe search hostname to answer with for
+// qname, or "" if qname is not a search engine. Country Google domains
+// (www.google.co.uk, google.de) map like google.com.
+func safeSearchTarget(qname string) string {
+	domain := strings.ToLower(strings.TrimSuffix(qname, "."))
+	if target, ok := safeSearchHosts[domain]; ok {
+		return target
+	}
+	rest := strings.TrimPrefix(domain, "www.")
+	if tld, ok := strings.CutPrefix(rest, "google."); ok && isGoogleTLD(tld) {
+		return "forcesafesearch.google.com."
+	}
+	return ""
+}
+
+// isGoogleTLD reports whether tld looks like a Google country suffix:
+// "de", "co.uk", "com.au".
+func isGoogleTLD(tld string) bool {
+	labels := strings.Split(tld, ".")
+	switch len(labels) {
+	case 1:
+		return len(labels[0]) == 2
+	case 2:
+		return (labels[0] == "co" || labels[0] == "com") && len(labels[1]) == 2
+	}
+	return false
+}
+
+// safeSearch answers r with a CNAME from the queried name to target,
+// followed by target's own records from the cache or upstream.
+func (s *Server) safeSearch(r *dns.Msg, target string) (*dns.Msg, error) {
+	q := r.Question[0]
+	upstream := s.cache.Get(target, q.Qtype, q.Qclass)
+	if upstream == nil {
+		query := new(dns.Msg)
+		query.SetQuestion(target, q.Qtype)
+		query.RecursionDesired = true
+		var err error
+		if upstream, err = s.forward(query); err != nil {
+			return nil, fmt.Errorf("resolving safe search host %s: %w", target, err)
+		}
+		s.cache.Set(upstream, s.cacheTTL)
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	resp.Rcode = upstream.Rcode
+	resp.Answer = append([]dns.RR{&dns.CNAME{
+		Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: target,
+	}}, upstream.Answer...)
+	return resp, nil
+}
@@ -0,0 +1,69 @@
+package dnsproxy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// window is a compiled config.DNSScheduleConfig.
+type window struct {
+	days       uint8 // bit per time.Weekday
+	start, end int   // minutes since midnight
+}
+
+// compileSchedule turns schedule config into windows. Entries that fail
+// to parse are skipped; config validation rejects them.
+func compileSchedule(cfgs []config.DNSScheduleConfig) []window {
+	var windows []window
+	for _, c := range cfgs {
+		start, err1 := time.Parse("15:04", c.Start)
+		end, err2 := time.Parse("15:04", c.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		w := window{
+			start: start.Hour()*60 + start.Minute(),
+			end:   end.Hour()*60 + end.Minute(),
+		}
+		for _, d := range c.Days {
+			if wd, ok := config.ScheduleDays[strings.ToLower(d)]; ok {
+				w.days |= 1 << wd
+			}
+		}
+		if w.days == 0 {
+			w.days = 0x7f
+		}
+		windows = append(windows, w)
+	}
+	return windows
+}
+
+// inSchedule reports whether now falls in any of the windows. No windows
+// means always.
+func inSchedule(windows []window, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	today := now.Weekday()
+	yesterday := (today + 6) % 7
+	for _, w := range windows {
+		if w.start < w.end {
+			if w.days&(1<<today) != 0 && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// Runs past midnight: the evening part today, the morning part
+		// belongs to a window that started yesterday
+		if w.days&(1<<today) != 0 && minute >= w.start {
+			return true
+		}
+		if w.days&(1<<yesterday) != 0 && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
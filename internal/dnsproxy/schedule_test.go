@@ -0,0 +1,68 @@
+package dnsproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+func TestInSchedule(t *testing.T) {
+	workday := compileSchedule([]config.DNSScheduleConfig{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"},
+	})
+	bedtime := compileSchedule([]config.DNSScheduleConfig{
+		{Days: []string{"Sun"}, Start: "22:00", End: "06:30"},
+	})
+	daily := compileSchedule([]config.DNSScheduleConfig{{Start: "12:00", End: "13:00"}})
+
+	// 2026-10-12 is a Monday
+	at := func(day int, clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 10, 12+day, t.Hour(), t.Minute(), 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name    string
+		windows []window
+		now     time.Time
+		want    bool
+	}{
+		{"no schedule", nil, at(0, "03:00"), true},
+		{"weekday inside", workday, at(2, "09:00"), true},
+		{"weekday end is exclusive", workday, at(2, "17:00"), false},
+		{"weekday before", workday, at(2, "08:59"), false},
+		{"weekend", workday, at(5, "10:00"), false},
+		{"overnight evening", bedtime, at(6, "23:15"), true},
+		{"overnight next morning", bedtime, at(7, "06:00"), true},
+		{"overnight morning of start day", bedtime, at(6, "05:00"), false},
+		{"overnight over", bedtime, at(7, "07:00"), false},
+		{"no days means every day", daily, at(5, "12:30"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inSchedule(tt.windows, tt.now); got != tt.want {
+				t.Errorf("inSchedule(%s) = %v, want %v", tt.now.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListManagerCheckSchedule(t *testing.T) {
+	lm := NewListManager([]config.DNSListConfig{{
+		Name: "social", URL: "http://test", Type: "block", Format: "domains", Action: "nxdomain", Enabled: true,
+		Schedule: []config.DNSScheduleConfig{{Days: []string{"mon"}, Start: "09:00", End: "17:00"}},
+	}}, testListLogger())
+	lm.lists[0].domains = map[string]struct{}{"social.example": {}}
+
+	monday := time.Date(2026, 10, 12, 10, 0, 0, 0, time.Local)
+	if blocked, _, _ := lm.Check("social.example.", monday); !blocked {
+		t.Error("scheduled list did not block inside its window")
+	}
+	if blocked, _, _ := lm.Check("social.example.", monday.Add(8*time.Hour)); blocked {
+		t.Error("scheduled list blocked outside its window")
+	}
+	if blocked, _, _ := lm.CheckLists("social.example.", []string{"social"}, monday.Add(24*time.Hour)); blocked {
+		t.Error("policy list blocked outside its window")
+	}
+}
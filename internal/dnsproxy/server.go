@@ -1,7 +1,6 @@
 package dnsproxy
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/base64"
@@ -10,6 +9,7 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -33,11 +33,27 @@ type Server struct {
 	udpServer *dns.Server
 	tcpServer *dns.Server
 	dohServer *http.Server
-
-	forwarders    []string
-	upstream      *UpstreamTracker
-	zoneOverrides map[string]config.DNSZoneOverride // lowercased zone -> override
-	cacheTTL      time.Duration
+	dotServer *dns.Server
+	doqServer *doqServer
+
+	// Upstreams are swapped on reload while queries are in flight
+	upstreamMu        sync.RWMutex
+	forwarders        []string
+	upstream          *UpstreamTracker
+	zoneOverrides     map[string]config.DNSZoneOverride // lowercased zone -> override
+	overrideUpstreams map[string]transport              // lowercased zone -> override nameserver
+	validator         *Validator                        // nil unless DNSSEC validation is on
+	cacheTTL          time.Duration
+
+	// Filtering policies and what they need to know about clients
+	clientMu   sync.RWMutex
+	policies   []*Policy
+	reserved   map[string]struct{} // MACs with a DHCP reservation
+	deviceType func(mac string) string
+
+	overrides *Overrides
+	bus       *events.Bus
+	stopCh    chan struct{}
 
 	mu      sync.RWMutex
 	started bool
@@ -51,24 +67,22 @@ func NewServer(cfg *config.DNSProxyConfig, logger *slog.Logger) *Server {
 	}
 
 	s := &Server{
-		cfg:           cfg,
-		zone:          NewZone(cfg.Domain, uint32(cfg.TTL)),
-		cache:         NewCache(cfg.CacheSize),
-		lists:         NewListManager(cfg.Lists, logger),
-		queryLog:      NewQueryLog(5000),
-		deviceMap:     NewDeviceMapper(),
-		logger:        logger,
-		forwarders:    cfg.Forwarders,
-		upstream:      NewUpstreamTracker(cfg.Forwarders, logger),
-		zoneOverrides: make(map[string]config.DNSZoneOverride),
-		cacheTTL:      cacheTTL,
-	}
-
-	// Index zone overrides by lowercase zone name
-	for _, zo := range cfg.ZoneOverrides {
-		key := strings.ToLower(dns.Fqdn(zo.Zone))
-		s.zoneOverrides[key] = zo
+		cfg:        cfg,
+		zone:       NewZone(cfg.Domain, uint32(cfg.TTL)),
+		cache:      NewCache(cfg.CacheSize),
+		lists:      NewListManager(cfg.Lists, logger),
+		queryLog:   NewQueryLog(5000),
+		deviceMap:  NewDeviceMapper(),
+		logger:     logger,
+		forwarders: cfg.Forwarders,
+		upstream:   NewUpstreamTracker(cfg.Forwarders, logger),
+		cacheTTL:   cacheTTL,
+		policies:   NewPolicies(cfg.Policies),
+		overrides:  NewOverrides(),
 	}
+	s.lists.Use(PolicyLists(cfg.Policies))
+	s.zoneOverrides, s.overrideUpstreams = buildZoneOverrides(cfg.ZoneOverrides, logger)
+	s.validator = s.newValidator(cfg.DNSSEC)
 
 	// Load static records
 	for _, rec := range cfg.StaticRecords {
@@ -159,25 +173,43 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}
 
+	// Start DoT and DoQ if configured; both use the DoH certificate
+	if s.cfg.ListenDoT != "" || s.cfg.ListenDoQ != "" {
+		if err := s.startEncrypted(mux); err != nil {
+			s.logger.Error("DoT/DoQ listeners failed to start", "error", err)
+			// Non-fatal — UDP/TCP still work
+		}
+	}
+
 	// Start list manager (downloads lists, begins refresh loops)
 	if len(s.cfg.Lists) > 0 {
 		s.lists.Start(ctx)
 	}
 
 	// Start upstream latency tracker
+	s.upstreamMu.RLock()
 	if s.upstream != nil {
 		s.upstream.Start()
 	}
+	s.upstreamMu.RUnlock()
+
+	// Expire temporary filtering overrides
+	s.stopCh = make(chan struct{})
+	go s.expireOverrides(s.stopCh)
 
 	s.started = true
 	s.logger.Info("DNS proxy started",
 		"udp", s.cfg.ListenUDP,
 		"doh", s.cfg.ListenDoH,
+		"dot", s.cfg.ListenDoT,
+		"doq", s.cfg.ListenDoQ,
 		"domain", s.cfg.Domain,
-		"forwarders", len(s.forwarders),
-		"zone_overrides", len(s.zoneOverrides),
+		"forwarders", len(s.cfg.Forwarders),
+		"zone_overrides", len(s.cfg.ZoneOverrides),
 		"static_records", s.zone.Count(),
 		"filter_lists", len(s.cfg.Lists),
+		"policies", len(s.cfg.Policies),
+		"dnssec", s.cfg.DNSSEC.Validate,
 		"cache_size", s.cfg.CacheSize)
 
 	return nil
@@ -192,9 +224,18 @@ func (s *Server) Stop() {
 		return
 	}
 
+	s.upstreamMu.RLock()
 	if s.upstream != nil {
 		s.upstream.Stop()
 	}
+	for _, t := range s.overrideUpstreams {
+		t.close()
+	}
+	s.upstreamMu.RUnlock()
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.stopCh = nil
+	}
 	if s.lists != nil {
 		s.lists.Stop()
 	}
@@ -209,6 +250,13 @@ func (s *Server) Stop() {
 		defer cancel()
 		s.dohServer.Shutdown(ctx)
 	}
+	if s.dotServer != nil {
+		s.dotServer.Shutdown()
+	}
+	if s.doqServer != nil {
+		s.doqServer.shutdown()
+		s.doqServer = nil
+	}
 
 	s.started = false
 	s.logger.Info("DNS proxy stopped")
@@ -235,22 +283,58 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 		"type", qtype,
 		"source", source)
 
-	// 1. Check filter lists (blocklists/allowlists)
-	if s.lists != nil {
-		if blocked, action, listName := s.lists.Check(qname); blocked {
-			resp := BlockResponse(r, action)
+	// The client's filtering policy, if any, replaces the global lists
+	client := s.Client(source)
+	policy := s.policyFor(client)
+	policyName := ""
+	if policy != nil {
+		policyName = policy.Name
+		metrics.DNSPolicyQueries.WithLabelValues(policyName).Inc()
+	}
+
+	// 1. Check overrides and filter lists (blocklists/allowlists)
+	if blocked, action, listName := s.check(qname, client, policy, start); blocked {
+		resp := BlockResponse(r, action)
+		w.WriteMsg(resp)
+		elapsed := time.Since(start).Seconds()
+		s.logger.Debug("DNS query blocked by list",
+			"name", qname, "list", listName, "action", action, "policy", policyName)
+		s.addQueryLog(QueryLogEntry{
+			Timestamp: start, Name: qname, Type: qtype, Source: source,
+			Status: "blocked", Latency: float64(time.Since(start).Microseconds()) / 1000,
+			ListName: listName, Action: action, Policy: policyName,
+		})
+		metrics.DNSQueriesTotal.WithLabelValues(qtype, "blocked").Inc()
+		metrics.DNSQueryDuration.WithLabelValues("blocked").Observe(elapsed)
+		metrics.DNSBlockedTotal.WithLabelValues(listName, action).Inc()
+		return
+	}
+
+	// 1b. Enforce safe search for policies that ask for it
+	if s.safeSearchOn(qname, client, policy, start) {
+		if target := safeSearchTarget(qname); target != "" {
+			resp, err := s.safeSearch(r, target)
+			if err != nil {
+				s.logger.Debug("DNS safe search lookup failed", "name", qname, "error", err)
+				dns.HandleFailed(w, r)
+				s.addQueryLog(QueryLogEntry{
+					Timestamp: start, Name: qname, Type: qtype, Source: source,
+					Status: "failed", Latency: float64(time.Since(start).Microseconds()) / 1000,
+					Policy: policyName,
+				})
+				metrics.DNSQueriesTotal.WithLabelValues(qtype, "failed").Inc()
+				metrics.DNSQueryDuration.WithLabelValues("failed").Observe(time.Since(start).Seconds())
+				metrics.DNSUpstreamErrors.Inc()
+				return
+			}
 			w.WriteMsg(resp)
-			elapsed := time.Since(start).Seconds()
-			s.logger.Debug("DNS query blocked by list",
-				"name", qname, "list", listName, "action", action)
 			s.addQueryLog(QueryLogEntry{
 				Timestamp: start, Name: qname, Type: qtype, Source: source,
-				Status: "blocked", Latency: float64(time.Since(start).Microseconds()) / 1000,
-				ListName: listName, Action: action,
+				Status: "safesearch", Latency: float64(time.Since(start).Microseconds()) / 1000,
+				Answer: target, Policy: policyName,
 			})
-			metrics.DNSQueriesTotal.WithLabelValues(qtype, "blocked").Inc()
-			metrics.DNSQueryDuration.WithLabelValues("blocked").Observe(elapsed)
-			metrics.DNSBlockedTotal.WithLabelValues(listName, action).Inc()
+			metrics.DNSQueriesTotal.WithLabelValues(qtype, "safesearch").Inc()
+			metrics.DNSQueryDuration.WithLabelValues("safesearch").Observe(time.Since(start).Seconds())
 			return
 		}
 	}
@@ -272,7 +356,7 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 		s.addQueryLog(QueryLogEntry{
 			Timestamp: start, Name: qname, Type: qtype, Source: source,
 			Status: "local", Latency: float64(time.Since(start).Microseconds()) / 1000,
-			Answer: answer,
+			Answer: answer, Policy: policyName,
 		})
 		metrics.DNSQueriesTotal.WithLabelValues(qtype, "local").Inc()
 		metrics.DNSQueryDuration.WithLabelValues("local").Observe(elapsed)
@@ -280,29 +364,39 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 	}
 
 	// 3. Check cache
-	if cached := s.cache.Get(qname, q.Qtype, q.Qclass); cached != nil {
-		cached.SetReply(r)
-		w.WriteMsg(cached)
-		elapsed := time.Since(start).Seconds()
-		s.logger.Debug("DNS query answered from cache", "name", qname)
+	if cached, state := s.cache.GetValidated(qname, q.Qtype, q.Qclass); cached != nil {
+		status := "cached"
+		if state == ValidationBogus && !r.CheckingDisabled {
+			status = "bogus"
+		}
 		answer := ""
 		if len(cached.Answer) > 0 {
 			answer = cached.Answer[0].String()
 		}
+		w.WriteMsg(dnssecReply(r, cached, state))
+		elapsed := time.Since(start).Seconds()
+		s.logger.Debug("DNS query answered from cache", "name", qname)
 		s.addQueryLog(QueryLogEntry{
 			Timestamp: start, Name: qname, Type: qtype, Source: source,
-			Status: "cached", Latency: float64(time.Since(start).Microseconds()) / 1000,
-			Answer: answer,
+			Status: status, Latency: float64(time.Since(start).Microseconds()) / 1000,
+			Answer: answer, Policy: policyName, DNSSEC: string(state),
 		})
-		metrics.DNSQueriesTotal.WithLabelValues(qtype, "cached").Inc()
-		metrics.DNSQueryDuration.WithLabelValues("cached").Observe(elapsed)
+		metrics.DNSQueriesTotal.WithLabelValues(qtype, status).Inc()
+		metrics.DNSQueryDuration.WithLabelValues(status).Observe(elapsed)
 		metrics.DNSCacheHits.Inc()
 		return
 	}
 	metrics.DNSCacheMisses.Inc()
 
-	// 4. Forward upstream
-	resp, err := s.forward(r)
+	// 4. Forward upstream, asking for signatures when validating
+	s.upstreamMu.RLock()
+	validator := s.validator
+	s.upstreamMu.RUnlock()
+	query := r
+	if validator != nil {
+		query = dnssecQuery(r)
+	}
+	resp, err := s.forward(query)
 	if err != nil {
 		elapsed := time.Since(start).Seconds()
 		s.logger.Debug("DNS forward failed", "name", qname, "error", err)
@@ -310,6 +404,7 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 		s.addQueryLog(QueryLogEntry{
 			Timestamp: start, Name: qname, Type: qtype, Source: source,
 			Status: "failed", Latency: float64(time.Since(start).Microseconds()) / 1000,
+			Policy: policyName,
 		})
 		metrics.DNSQueriesTotal.WithLabelValues(qtype, "failed").Inc()
 		metrics.DNSQueryDuration.WithLabelValues("failed").Observe(elapsed)
@@ -317,9 +412,22 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 		return
 	}
 
-	// Cache the response
-	s.cache.Set(resp, s.cacheTTL)
+	// 5. Validate, then cache the response with its DNSSEC state
+	var state ValidationState
+	if validator != nil {
+		var verr error
+		state, verr = validator.Validate(resp)
+		metrics.DNSSECValidations.WithLabelValues(string(state)).Inc()
+		if verr != nil {
+			s.logger.Info("DNSSEC validation failed", "name", qname, "type", qtype, "error", verr)
+		}
+	}
+	s.cache.SetValidated(resp, s.cacheTTL, state)
 
+	status := "forwarded"
+	if state == ValidationBogus && !r.CheckingDisabled {
+		status = "bogus"
+	}
 	elapsed := time.Since(start).Seconds()
 	answer := ""
 	if len(resp.Answer) > 0 {
@@ -327,14 +435,41 @@ func (s *Server) handleQuery(w dns.ResponseWriter, r *dns.Msg) {
 	}
 	s.addQueryLog(QueryLogEntry{
 		Timestamp: start, Name: qname, Type: qtype, Source: source,
-		Status: "forwarded", Latency: float64(time.Since(start).Microseconds()) / 1000,
-		Answer: answer,
+		Status: status, Latency: float64(time.Since(start).Microseconds()) / 1000,
+		Answer: answer, Policy: policyName, DNSSEC: string(state),
 	})
-	metrics.DNSQueriesTotal.WithLabelValues(qtype, "forwarded").Inc()
-	metrics.DNSQueryDuration.WithLabelValues("forwarded").Observe(elapsed)
+	metrics.DNSQueriesTotal.WithLabelValues(qtype, status).Inc()
+	metrics.DNSQueryDuration.WithLabelValues(status).Observe(elapsed)
 
-	resp.SetReply(r)
-	w.WriteMsg(resp)
+	w.WriteMsg(dnssecReply(r, resp, state))
+}
+
+// check tests qname for the client at now: temporary overrides first,
+// then the client's policy, or the globally enabled lists when the client
+// has none.
+func (s *Server) check(qname string, client Client, policy *Policy, now time.Time) (blocked bool, action string, listName string) {
+	switch s.overrides.Check(qname, client, now) {
+	case OverridePause, OverrideAllow:
+		return false, "", ""
+	case OverrideBlock:
+		return true, "nxdomain", "override"
+	}
+	if policy != nil {
+		return policy.Check(qname, s.lists, now)
+	}
+	if s.lists == nil {
+		return false, "", ""
+	}
+	return s.lists.Check(qname, now)
+}
+
+// safeSearchOn reports whether safe search applies to qname for the client
+// at now: its policy asks for it and filtering isn't paused for it.
+func (s *Server) safeSearchOn(qname string, client Client, policy *Policy, now time.Time) bool {
+	if policy == nil || !policy.SafeSearch {
+		return false
+	}
+	return s.overrides.Check(qname, client, now) != OverridePause
 }
 
 // forward sends a query to the appropriate upstream server.
@@ -345,18 +480,22 @@ func (s *Server) forward(r *dns.Msg) (*dns.Msg, error) {
 
 	qname := strings.ToLower(r.Question[0].Name)
 
-	// Check zone overrides — find the most specific match
+	s.upstreamMu.RLock()
 	override, found := s.findZoneOverride(qname)
+	overrideUpstream := s.overrideUpstreams[strings.ToLower(dns.Fqdn(override.Zone))]
+	upstream := s.upstream
+	s.upstreamMu.RUnlock()
+
+	// Zone overrides take precedence — most specific match wins
 	if found {
-		return s.forwardToOverride(r, override)
+		return forwardToOverride(r, override, overrideUpstream)
 	}
 
-	// Forward to configured upstream servers
-	if len(s.forwarders) == 0 {
+	// Forward to configured upstream servers, fastest first
+	if upstream == nil {
 		return nil, fmt.Errorf("no upstream forwarders configured")
 	}
-
-	return s.forwardToUpstream(r, s.forwarders)
+	return upstream.Exchange(r)
 }
 
 // findZoneOverride returns the most specific zone override for a query name.
@@ -373,104 +512,95 @@ func (s *Server) findZoneOverride(qname string) (config.DNSZoneOverride, bool) {
 }
 
 // forwardToOverride sends a query to a zone override destination.
-func (s *Server) forwardToOverride(r *dns.Msg, zo config.DNSZoneOverride) (*dns.Msg, error) {
-	if zo.DoH && zo.DoHURL != "" {
-		return s.forwardDoH(r, zo.DoHURL)
+func forwardToOverride(r *dns.Msg, zo config.DNSZoneOverride, t transport) (*dns.Msg, error) {
+	if t == nil {
+		return nil, fmt.Errorf("zone override %s has no usable nameserver", zo.Zone)
 	}
-
-	ns := zo.Nameserver
-	if !strings.Contains(ns, ":") {
-		ns = ns + ":53"
+	resp, err := t.exchange(context.Background(), r)
+	if err != nil {
+		return nil, fmt.Errorf("forwarding %s to zone override: %w", zo.Zone, err)
 	}
-
-	return s.forwardToUpstream(r, []string{ns})
+	return resp, nil
 }
 
-// forwardToUpstream tries upstream servers ordered by latency until one succeeds.
-func (s *Server) forwardToUpstream(r *dns.Msg, servers []string) (*dns.Msg, error) {
-	client := &dns.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	// Use latency-aware ordering if tracker is available and we're using the main forwarders
-	ordered := servers
-	if s.upstream != nil && len(servers) == len(s.forwarders) {
-		ordered = s.upstream.BestServers()
-	}
+// buildZoneOverrides indexes zone overrides by lowercase zone name and
+// opens a transport to each override's nameserver or DoH URL.
+func buildZoneOverrides(overrides []config.DNSZoneOverride, logger *slog.Logger) (map[string]config.DNSZoneOverride, map[string]transport) {
+	zones := make(map[string]config.DNSZoneOverride, len(overrides))
+	upstreams := make(map[string]transport, len(overrides))
+	for _, zo := range overrides {
+		key := strings.ToLower(dns.Fqdn(zo.Zone))
+		zones[key] = zo
 
-	var lastErr error
-	for _, server := range ordered {
-		addr := server
-		if !strings.Contains(addr, ":") {
-			addr = addr + ":53"
+		upstream := zo.Nameserver
+		if zo.DoH && zo.DoHURL != "" {
+			upstream = zo.DoHURL
 		}
-
-		start := time.Now()
-		resp, _, err := client.Exchange(r, addr)
-		elapsed := time.Since(start)
-
+		_, t, err := newTransport(upstream)
 		if err != nil {
-			lastErr = fmt.Errorf("forwarding to %s: %w", addr, err)
-			s.logger.Debug("upstream DNS server failed", "server", addr, "error", err)
-			if s.upstream != nil {
-				s.upstream.RecordFailure(addr)
-			}
+			logger.Warn("zone override has an invalid nameserver", "zone", zo.Zone, "nameserver", upstream, "error", err)
 			continue
 		}
-
-		if s.upstream != nil {
-			s.upstream.RecordSuccess(addr, elapsed)
+		if old, ok := upstreams[key]; ok {
+			old.close()
 		}
-		return resp, nil
+		upstreams[key] = t
 	}
-
-	return nil, fmt.Errorf("all upstream servers failed: %w", lastErr)
+	return zones, upstreams
 }
 
-// forwardDoH sends a DNS query via DNS-over-HTTPS (RFC 8484).
-func (s *Server) forwardDoH(r *dns.Msg, url string) (*dns.Msg, error) {
-	packed, err := r.Pack()
-	if err != nil {
-		return nil, fmt.Errorf("packing DNS query for DoH: %w", err)
+// newValidator builds a DNSSEC validator that looks up keys through the
+// proxy's own upstreams. It returns nil when validation is off.
+func (s *Server) newValidator(cfg config.DNSSECConfig) *Validator {
+	if !cfg.Validate {
+		return nil
 	}
-
-	httpClient := &http.Client{
-		Timeout: 5 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			},
-		},
+	anchors := cfg.TrustAnchors
+	if len(anchors) == 0 {
+		anchors = config.DefaultDNSSECTrustAnchors
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewReader(packed))
+	v, err := NewValidator(anchors, s.forward)
 	if err != nil {
-		return nil, fmt.Errorf("creating DoH request: %w", err)
+		s.logger.Error("DNSSEC validation disabled", "error", err)
+		return nil
 	}
-	req.Header.Set("Content-Type", "application/dns-message")
-	req.Header.Set("Accept", "application/dns-message")
+	return v
+}
 
-	resp, err := httpClient.Do(req)
+// startEncrypted starts the DoT and DoQ listeners with the DoH certificate.
+func (s *Server) startEncrypted(mux *dns.ServeMux) error {
+	cert, err := tls.LoadX509KeyPair(s.cfg.DoHTLS.CertFile, s.cfg.DoHTLS.KeyFile)
 	if err != nil {
-		return nil, fmt.Errorf("DoH request to %s: %w", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("DoH server returned status %d", resp.StatusCode)
+		return fmt.Errorf("loading DoT/DoQ certificate: %w", err)
 	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
-	if err != nil {
-		return nil, fmt.Errorf("reading DoH response: %w", err)
+	if s.cfg.ListenDoT != "" {
+		s.dotServer = &dns.Server{
+			Addr:    s.cfg.ListenDoT,
+			Net:     "tcp-tls",
+			Handler: mux,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+		}
+		go func() {
+			s.logger.Info("DNS proxy DoT listener starting", "addr", s.cfg.ListenDoT)
+			if err := s.dotServer.ListenAndServe(); err != nil {
+				s.logger.Error("DoT listener error", "error", err)
+			}
+		}()
 	}
 
-	dnsResp := new(dns.Msg)
-	if err := dnsResp.Unpack(body); err != nil {
-		return nil, fmt.Errorf("unpacking DoH response: %w", err)
+	if s.cfg.ListenDoQ != "" {
+		doq, err := listenDoQ(s.cfg.ListenDoQ, cert, s.handleQuery, s.logger)
+		if err != nil {
+			return fmt.Errorf("starting DoQ listener: %w", err)
+		}
+		s.doqServer = doq
+		s.logger.Info("DNS proxy DoQ listener started", "addr", s.cfg.ListenDoQ)
 	}
-
-	return dnsResp, nil
+	return nil
 }
 
 // startDoH starts the DNS-over-HTTPS listener.
@@ -568,13 +698,20 @@ func (s *Server) handleDoH(w http.ResponseWriter, r *http.Request) {
 	w.Write(packed)
 }
 
-// dohResponseWriter implements dns.ResponseWriter for DoH processing.
+// dohResponseWriter implements dns.ResponseWriter for DoH and DoQ
+// processing.
 type dohResponseWriter struct {
-	msg *dns.Msg
+	msg    *dns.Msg
+	remote net.Addr // client address, when known
 }
 
-func (d *dohResponseWriter) LocalAddr() net.Addr  { return &net.TCPAddr{} }
-func (d *dohResponseWriter) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+func (d *dohResponseWriter) LocalAddr() net.Addr { return &net.TCPAddr{} }
+func (d *dohResponseWriter) RemoteAddr() net.Addr {
+	if d.remote != nil {
+		return d.remote
+	}
+	return &net.TCPAddr{}
+}
 func (d *dohResponseWriter) WriteMsg(msg *dns.Msg) error {
 	d.msg = msg
 	return nil
@@ -636,7 +773,8 @@ func (s *Server) SubscribeToEvents(ctx context.Context, eventCh <-chan events.Ev
 }
 
 func (s *Server) handleEvent(evt events.Event) {
-	if evt.Lease == nil {
+	// Delegated prefixes are routed, not host addresses — never register them.
+	if evt.Lease == nil || evt.Lease.PrefixLen > 0 {
 		return
 	}
 
@@ -687,6 +825,10 @@ func (s *Server) UpdateConfig(cfg *config.DNSProxyConfig) {
 		}
 	}
 
+	if !slices.Equal(PolicyLists(oldCfg.Policies), PolicyLists(cfg.Policies)) {
+		listsChanged = true
+	}
+
 	if listsChanged {
 		// Stop old list manager
 		if s.lists != nil {
@@ -694,6 +836,7 @@ func (s *Server) UpdateConfig(cfg *config.DNSProxyConfig) {
 		}
 		// Create and start new list manager
 		s.lists = NewListManager(cfg.Lists, s.logger)
+		s.lists.Use(PolicyLists(cfg.Policies))
 		if len(cfg.Lists) > 0 {
 			ctx := context.Background()
 			s.lists.Start(ctx)
@@ -701,15 +844,41 @@ func (s *Server) UpdateConfig(cfg *config.DNSProxyConfig) {
 		s.logger.Info("DNS filter lists reloaded", "lists", len(cfg.Lists))
 	}
 
-	// Update forwarders
-	s.forwarders = cfg.Forwarders
+	zones, overrideUpstreams := buildZoneOverrides(cfg.ZoneOverrides, s.logger)
+	dnssecChanged := oldCfg.DNSSEC.Validate != cfg.DNSSEC.Validate ||
+		!slices.Equal(oldCfg.DNSSEC.TrustAnchors, cfg.DNSSEC.TrustAnchors)
 
-	// Rebuild zone overrides
-	newOverrides := make(map[string]config.DNSZoneOverride)
-	for _, ov := range cfg.ZoneOverrides {
-		newOverrides[strings.ToLower(ov.Zone)] = ov
+	s.upstreamMu.Lock()
+	// Rebuild the upstream tracker only when the forwarders change, so
+	// latency history and open connections survive unrelated reloads
+	if !slices.Equal(s.forwarders, cfg.Forwarders) {
+		if s.upstream != nil {
+			s.upstream.Stop()
+		}
+		s.upstream = NewUpstreamTracker(cfg.Forwarders, s.logger)
+		if s.started {
+			s.upstream.Start()
+		}
+		s.forwarders = cfg.Forwarders
+	}
+	for _, t := range s.overrideUpstreams {
+		t.close()
 	}
-	s.zoneOverrides = newOverrides
+	s.zoneOverrides, s.overrideUpstreams = zones, overrideUpstreams
+	if dnssecChanged {
+		s.validator = s.newValidator(cfg.DNSSEC)
+	}
+	s.upstreamMu.Unlock()
+
+	// Cached answers carry the old validation state
+	if dnssecChanged {
+		s.cache.Flush()
+		s.logger.Info("DNSSEC validation reconfigured", "enabled", cfg.DNSSEC.Validate)
+	}
+
+	s.clientMu.Lock()
+	s.policies = NewPolicies(cfg.Policies)
+	s.clientMu.Unlock()
 }
 
 // Lists returns the list manager for API access.
@@ -719,14 +888,20 @@ func (s *Server) Lists() *ListManager {
 
 // Stats returns basic DNS proxy statistics.
 func (s *Server) Stats() map[string]interface{} {
+	s.upstreamMu.RLock()
+	defer s.upstreamMu.RUnlock()
+
 	stats := map[string]interface{}{
-		"zone_records":    s.zone.Count(),
-		"cache_entries":   s.cache.Size(),
-		"forwarders":      len(s.forwarders),
-		"overrides":       len(s.zoneOverrides),
-		"domain":          s.cfg.Domain,
-		"filter_lists":    len(s.cfg.Lists),
-		"blocked_domains": 0,
+		"zone_records":     s.zone.Count(),
+		"cache_entries":    s.cache.Size(),
+		"forwarders":       len(s.forwarders),
+		"overrides":        len(s.zoneOverrides),
+		"domain":           s.cfg.Domain,
+		"filter_lists":     len(s.cfg.Lists),
+		"policies":         len(s.cfg.Policies),
+		"filter_overrides": len(s.overrides.List(time.Now())),
+		"dnssec":           s.validator != nil,
+		"blocked_domains":  0,
 	}
 	if s.lists != nil {
 		stats["blocked_domains"] = s.lists.TotalDomains()
@@ -742,6 +917,177 @@ func (s *Server) DeviceMap() *DeviceMapper {
 	return s.deviceMap
 }
 
+// SetReservations records which MACs hold a DHCP reservation, for policies
+// that select reserved clients.
+func (s *Server) SetReservations(subnets []config.SubnetConfig) {
+	reserved := make(map[string]struct{})
+	for _, sub := range subnets {
+		for _, res := range sub.Reservations {
+			if hw, err := net.ParseMAC(res.MAC); err == nil {
+				reserved[hw.String()] = struct{}{}
+			}
+		}
+	}
+	s.clientMu.Lock()
+	s.reserved = reserved
+	s.clientMu.Unlock()
+}
+
+// SetDeviceTypes sets the lookup from a client MAC to its fingerprinted
+// device type, for policies that select by device_types.
+func (s *Server) SetDeviceTypes(fn func(mac string) string) {
+	s.clientMu.Lock()
+	s.deviceType = fn
+	s.clientMu.Unlock()
+}
+
+// Client returns what the policies know about the sender of a query from
+// source ("ip" or "ip:port").
+func (s *Server) Client(source string) Client {
+	host, _, err := net.SplitHostPort(source)
+	if err != nil {
+		host = source
+	}
+	c := Client{IP: net.ParseIP(host)}
+	if s.deviceMap == nil {
+		return c
+	}
+	dev := s.deviceMap.Lookup(host)
+	if dev == nil || dev.MAC == "" {
+		return c
+	}
+	if hw, err := net.ParseMAC(dev.MAC); err == nil {
+		c.MAC = hw.String()
+	}
+	c.Type = dev.Type
+
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	if c.Type == "" && s.deviceType != nil {
+		c.Type = s.deviceType(c.MAC)
+	}
+	_, c.Reserved = s.reserved[c.MAC]
+	return c
+}
+
+// TestDomainFor is ListManager.TestDomain as seen by a client at source:
+// when a filtering policy selects the client or an override applies, that
+// verdict replaces the global one and the result names the policy.
+func (s *Server) TestDomainFor(domain, source string) map[string]interface{} {
+	result := s.lists.TestDomain(domain)
+	client := s.Client(source)
+	policy := s.policyFor(client)
+	if policy != nil {
+		result["policy"] = policy.Name
+	}
+	if policy == nil && s.overrides.Check(domain, client, time.Now()) == "" {
+		return result
+	}
+	blocked, action, listName := s.check(dns.Fqdn(domain), client, policy, time.Now())
+	result["blocked"] = blocked
+	delete(result, "action")
+	delete(result, "list")
+	if blocked {
+		result["action"] = action
+		result["list"] = listName
+	}
+	if s.safeSearchOn(dns.Fqdn(domain), client, policy, time.Now()) {
+		if target := safeSearchTarget(domain); target != "" {
+			result["safe_search"] = strings.TrimSuffix(target, ".")
+		}
+	}
+	return result
+}
+
+// policyFor returns the filtering policy for the client, or nil.
+func (s *Server) policyFor(c Client) *Policy {
+	s.clientMu.RLock()
+	policies := s.policies
+	s.clientMu.RUnlock()
+	if len(policies) == 0 {
+		return nil
+	}
+	return MatchPolicy(policies, c)
+}
+
+// SetEventBus sets the bus that override changes are published on.
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.clientMu.Lock()
+	s.bus = bus
+	s.clientMu.Unlock()
+}
+
+// Overrides returns the temporary filtering overrides in effect.
+func (s *Server) Overrides() []Override {
+	return s.overrides.List(time.Now())
+}
+
+// AddOverride adds a temporary filtering override lasting d and publishes
+// dns.override_added.
+func (s *Server) AddOverride(o Override, d time.Duration) (Override, error) {
+	o, err := s.overrides.Add(o, d, time.Now())
+	if err != nil {
+		return Override{}, err
+	}
+	s.logger.Info("DNS filtering override added",
+		"id", o.ID, "action", o.Action, "domain", o.Domain,
+		"client", o.Client, "expires", o.Expires, "by", o.By)
+	s.publishOverride(events.EventDNSOverrideAdd, o, o.By)
+	return o, nil
+}
+
+// RemoveOverride ends a temporary filtering override early and publishes
+// dns.override_removed. by names who removed it.
+func (s *Server) RemoveOverride(id, by string) (Override, bool) {
+	o, ok := s.overrides.Remove(id)
+	if !ok {
+		return Override{}, false
+	}
+	s.logger.Info("DNS filtering override removed", "id", o.ID, "by", by)
+	s.publishOverride(events.EventDNSOverrideRemove, o, by)
+	return o, true
+}
+
+// expireOverrides drops overrides as they run out until stop is closed.
+// Lookups already ignore expired overrides; this publishes the event.
+func (s *Server) expireOverrides(stop chan struct{}) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, o := range s.overrides.Expire(now) {
+				s.logger.Info("DNS filtering override expired", "id", o.ID)
+				s.publishOverride(events.EventDNSOverrideExpire, o, "")
+			}
+		}
+	}
+}
+
+// publishOverride publishes an override event if a bus is set.
+func (s *Server) publishOverride(t events.EventType, o Override, by string) {
+	s.clientMu.RLock()
+	bus := s.bus
+	s.clientMu.RUnlock()
+	if bus == nil {
+		return
+	}
+	bus.Publish(events.Event{
+		Type:      t,
+		Timestamp: time.Now(),
+		Override: &events.OverrideData{
+			ID:      o.ID,
+			Action:  o.Action,
+			Domain:  o.Domain,
+			Client:  o.Client,
+			Expires: o.Expires.Unix(),
+			By:      by,
+		},
+	})
+}
+
 // addQueryLog enriches a query log entry with device info and adds it.
 func (s *Server) addQueryLog(entry QueryLogEntry) {
 	if s.deviceMap != nil {
@@ -752,6 +1098,8 @@ func (s *Server) addQueryLog(entry QueryLogEntry) {
 
 // UpstreamStats returns latency and reliability stats for all upstream resolvers.
 func (s *Server) UpstreamStats() []UpstreamStats {
+	s.upstreamMu.RLock()
+	defer s.upstreamMu.RUnlock()
 	if s.upstream == nil {
 		return nil
 	}
@@ -0,0 +1,373 @@
+package dnsproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/miekg/dns"
+	"golang.org/x/net/quic"
+)
+
+const (
+	upstreamTimeout = 5 * time.Second
+	maxIdleTLSConns = 4 // idle DoT connections kept per upstream
+)
+
+// transport sends queries to one upstream resolver. Implementations keep
+// their connections open between queries where the protocol allows it.
+type transport interface {
+	exchange(ctx context.Context, r *dns.Msg) (*dns.Msg, error)
+	close()
+}
+
+// newTransport parses a forwarder or zone override nameserver and returns
+// its tracker key and a transport for it. Plain UDP upstreams keep the
+// bare host:port key they have always had.
+func newTransport(upstream string) (string, transport, error) {
+	scheme, addr, err := config.ParseDNSUpstream(upstream)
+	if err != nil {
+		return "", nil, err
+	}
+	switch scheme {
+	case config.DNSUpstreamUDP:
+		return addr, &dnsTransport{addr: addr, net: "udp"}, nil
+	case config.DNSUpstreamTCP:
+		return scheme + "://" + addr, &dnsTransport{addr: addr, net: "tcp"}, nil
+	case config.DNSUpstreamTLS:
+		return scheme + "://" + addr, newTLSTransport(addr), nil
+	case config.DNSUpstreamHTTPS:
+		return addr, newHTTPSTransport(addr), nil
+	default:
+		return scheme + "://" + addr, newQUICTransport(addr), nil
+	}
+}
+
+// transportProtocol names the protocol t speaks, as shown in upstream stats.
+func transportProtocol(t transport) string {
+	switch t := t.(type) {
+	case *dnsTransport:
+		return t.net
+	case *tlsTransport:
+		return config.DNSUpstreamTLS
+	case *httpsTransport:
+		return config.DNSUpstreamHTTPS
+	default:
+		return config.DNSUpstreamQUIC
+	}
+}
+
+// dnsTransport is plain DNS over UDP or TCP. A truncated UDP answer is
+// retried over TCP.
+type dnsTransport struct {
+	addr string
+	net  string
+}
+
+func (t *dnsTransport) exchange(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: t.net, Timeout: upstreamTimeout}
+	resp, _, err := client.ExchangeContext(ctx, r, t.addr)
+	if err == nil && resp.Truncated && t.net == "udp" {
+		client.Net = "tcp"
+		resp, _, err = client.ExchangeContext(ctx, r, t.addr)
+	}
+	return resp, err
+}
+
+func (t *dnsTransport) close() {}
+
+// tlsTransport is DNS-over-TLS (RFC 7858). Connections go back into a
+// small idle pool after each answer so later queries skip the handshake.
+type tlsTransport struct {
+	addr string
+	tls  *tls.Config
+	idle chan *dns.Conn
+}
+
+func newTLSTransport(addr string) *tlsTransport {
+	host, _, _ := net.SplitHostPort(addr)
+	return &tlsTransport{
+		addr: addr,
+		tls:  &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+		idle: make(chan *dns.Conn, maxIdleTLSConns),
+	}
+}
+
+func (t *tlsTransport) exchange(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	// A pooled connection may have been closed by the server while idle;
+	// if it fails, try once more on a fresh one.
+	select {
+	case conn := <-t.idle:
+		if resp, err := t.exchangeOn(ctx, conn, r); err == nil {
+			return resp, nil
+		}
+	default:
+	}
+
+	client := &dns.Client{Net: "tcp-tls", TLSConfig: t.tls, Timeout: upstreamTimeout}
+	conn, err := client.DialContext(ctx, t.addr)
+	if err != nil {
+		return nil, err
+	}
+	return t.exchangeOn(ctx, conn, r)
+}
+
+// exchangeOn sends r over conn and pools conn again on success.
+func (t *tlsTransport) exchangeOn(ctx context.Context, conn *dns.Conn, r *dns.Msg) (*dns.Msg, error) {
+	deadline := time.Now().Add(upstreamTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+	if err := conn.WriteMsg(r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := conn.ReadMsg()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.Id != r.Id {
+		conn.Close()
+		return nil, dns.ErrId
+	}
+	select {
+	case t.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return resp, nil
+}
+
+func (t *tlsTransport) close() {
+	for {
+		select {
+		case conn := <-t.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// httpsTransport is DNS-over-HTTPS (RFC 8484). The HTTP client keeps its
+// connections alive, and speaks HTTP/2 where the server does.
+type httpsTransport struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSTransport(url string) *httpsTransport {
+	return &httpsTransport{
+		url: url,
+		client: &http.Client{
+			Timeout: upstreamTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{MinVersion: tls.VersionTLS12},
+				ForceAttemptHTTP2: true,
+				MaxIdleConns:      4,
+				IdleConnTimeout:   90 * time.Second,
+			},
+		},
+	}
+}
+
+func (t *httpsTransport) exchange(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 §4.1: ID 0 keeps answers cacheable by HTTP caches
+	q := r.Copy()
+	q.Id = 0
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing DNS query for DoH: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("creating DoH request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DoH request to %s: %w", t.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, fmt.Errorf("reading DoH response: %w", err)
+	}
+
+	dnsResp := new(dns.Msg)
+	if err := dnsResp.Unpack(body); err != nil {
+		return nil, fmt.Errorf("unpacking DoH response: %w", err)
+	}
+	dnsResp.Id = r.Id
+	return dnsResp, nil
+}
+
+func (t *httpsTransport) close() {
+	t.client.CloseIdleConnections()
+}
+
+// quicTransport is DNS-over-QUIC (RFC 9250). One connection is kept open
+// and each query gets its own stream on it.
+type quicTransport struct {
+	addr string
+	cfg  *quic.Config
+
+	mu       sync.Mutex
+	endpoint *quic.Endpoint
+	conn     *quic.Conn
+}
+
+func newQUICTransport(addr string) *quicTransport {
+	host, _, _ := net.SplitHostPort(addr)
+	return &quicTransport{
+		addr: addr,
+		cfg: &quic.Config{
+			TLSConfig: &tls.Config{ServerName: host, NextProtos: []string{doqALPN}, MinVersion: tls.VersionTLS13},
+		},
+	}
+}
+
+func (t *quicTransport) exchange(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamTimeout)
+	defer cancel()
+
+	conn, reused, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.exchangeOn(ctx, conn, r)
+	if err != nil && reused {
+		// The kept connection may have idled out; redial once
+		t.drop(conn)
+		if conn, _, err = t.connect(ctx); err != nil {
+			return nil, err
+		}
+		resp, err = t.exchangeOn(ctx, conn, r)
+	}
+	if err != nil {
+		t.drop(conn)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// connect returns the open connection, dialling one if there is none.
+func (t *quicTransport) connect(ctx context.Context) (*quic.Conn, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		return t.conn, true, nil
+	}
+	if t.endpoint == nil {
+		ep, err := quic.Listen("udp", ":0", nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("opening QUIC endpoint: %w", err)
+		}
+		t.endpoint = ep
+	}
+	conn, err := t.endpoint.Dial(ctx, "udp", t.addr, t.cfg)
+	if err != nil {
+		return nil, false, fmt.Errorf("dialling %s: %w", t.addr, err)
+	}
+	t.conn = conn
+	return conn, false, nil
+}
+
+// drop forgets conn if it is still the open connection.
+func (t *quicTransport) drop(conn *quic.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == conn {
+		t.conn = nil
+	}
+	conn.Abort(nil)
+}
+
+// exchangeOn sends r on a new stream of conn.
+func (t *quicTransport) exchangeOn(ctx context.Context, conn *quic.Conn, r *dns.Msg) (*dns.Msg, error) {
+	stream, err := conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	stream.SetReadContext(ctx)
+	stream.SetWriteContext(ctx)
+
+	// RFC 9250 §4.2.1: the message ID must be 0
+	q := r.Copy()
+	q.Id = 0
+	if err := writeStreamMsg(stream, q); err != nil {
+		return nil, err
+	}
+	stream.CloseWrite()
+	resp, err := readStreamMsg(stream)
+	if err != nil {
+		return nil, err
+	}
+	resp.Id = r.Id
+	return resp, nil
+}
+
+func (t *quicTransport) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.Abort(nil)
+		t.conn = nil
+	}
+	if t.endpoint != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		t.endpoint.Close(ctx)
+		t.endpoint = nil
+	}
+}
+
+// writeStreamMsg writes a DNS message with the 2-byte length prefix used
+// on TCP, DoT and DoQ streams.
+func writeStreamMsg(w io.Writer, m *dns.Msg) error {
+	packed, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(buf, uint16(len(packed)))
+	copy(buf[2:], packed)
+	_, err = w.Write(buf)
+	return err
+}
+
+// readStreamMsg reads a length-prefixed DNS message.
+func readStreamMsg(r io.Reader) (*dns.Msg, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,244 @@
+package dnsproxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/quic"
+)
+
+// testCert returns a self-signed certificate for 127.0.0.1 and a pool
+// that trusts it.
+func testCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "athena-dhcpd test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// answerA answers every A query with 192.0.2.1.
+func answerA(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.IPv4(192, 0, 2, 1),
+	})
+	w.WriteMsg(m)
+}
+
+// startTestDNS runs a plain DNS server over UDP and returns its address.
+func startTestDNS(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(answerA)}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// startTestDoT runs a DNS-over-TLS server and returns its address.
+func startTestDoT(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{Listener: ln, Net: "tcp-tls", Handler: dns.HandlerFunc(answerA)}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return ln.Addr().String()
+}
+
+func testQuery(name string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	return m
+}
+
+func checkAnswer(t *testing.T, q, resp *dns.Msg) {
+	t.Helper()
+	if resp.Id != q.Id {
+		t.Errorf("response ID = %d, want %d", resp.Id, q.Id)
+	}
+	if len(resp.Answer) != 1 {
+		t.Fatalf("got %d answers, want 1", len(resp.Answer))
+	}
+	if a := resp.Answer[0].(*dns.A); !a.A.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("answer = %s, want 192.0.2.1", a.A)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		upstream string
+		wantKey  string
+		wantProt string
+	}{
+		{"1.1.1.1", "1.1.1.1:53", "udp"},
+		{"8.8.8.8:5353", "8.8.8.8:5353", "udp"},
+		{"tcp://9.9.9.9", "tcp://9.9.9.9:53", "tcp"},
+		{"tls://1.1.1.1", "tls://1.1.1.1:853", "tls"},
+		{"https://dns.example", "https://dns.example/dns-query", "https"},
+		{"quic://dns.example:8853", "quic://dns.example:8853", "quic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream, func(t *testing.T) {
+			key, tr, err := newTransport(tt.upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tr.close()
+			if key != tt.wantKey {
+				t.Errorf("key = %q, want %q", key, tt.wantKey)
+			}
+			if p := transportProtocol(tr); p != tt.wantProt {
+				t.Errorf("protocol = %q, want %q", p, tt.wantProt)
+			}
+		})
+	}
+
+	if _, _, err := newTransport("gopher://1.1.1.1"); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+}
+
+func TestTLSTransportReuse(t *testing.T) {
+	cert, pool := testCert(t)
+	addr := startTestDoT(t, cert)
+
+	tr := newTLSTransport(addr)
+	tr.tls.RootCAs = pool
+	defer tr.close()
+
+	for i := 0; i < 3; i++ {
+		q := testQuery("reuse.example.")
+		resp, err := tr.exchange(context.Background(), q)
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		checkAnswer(t, q, resp)
+	}
+	if n := len(tr.idle); n != 1 {
+		t.Errorf("idle connections = %d, want 1", n)
+	}
+}
+
+func TestDoQRoundTrip(t *testing.T) {
+	cert, pool := testCert(t)
+	srv, err := listenDoQ("127.0.0.1:0", cert, answerA, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.shutdown()
+
+	tr := newQUICTransport(srv.endpoint.LocalAddr().String())
+	tr.cfg.TLSConfig.RootCAs = pool
+	defer tr.close()
+
+	var conn *quic.Conn
+	for i := 0; i < 3; i++ {
+		q := testQuery("doq.example.")
+		resp, err := tr.exchange(context.Background(), q)
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		checkAnswer(t, q, resp)
+
+		// Every query after the first rides the same connection
+		tr.mu.Lock()
+		if i > 0 && tr.conn != conn {
+			t.Errorf("query %d opened a new connection", i)
+		}
+		conn = tr.conn
+		tr.mu.Unlock()
+	}
+}
+
+func TestUpstreamTrackerExchange(t *testing.T) {
+	cert, pool := testCert(t)
+	udp := startTestDNS(t)
+	dot := startTestDoT(t, cert)
+
+	// Nothing listens on the first upstream
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	tracker := NewUpstreamTracker([]string{"tcp://" + deadAddr, "tls://" + dot, udp}, testLogger())
+	defer tracker.Stop()
+	tracker.servers["tls://"+dot].transport.(*tlsTransport).tls.RootCAs = pool
+
+	q := testQuery("tracker.example.")
+	resp, err := tracker.Exchange(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAnswer(t, q, resp)
+
+	stats := map[string]UpstreamStats{}
+	for _, s := range tracker.Stats() {
+		stats[s.Address] = s
+	}
+	if s := stats["tcp://"+deadAddr]; s.Failures != 1 || s.Protocol != "tcp" {
+		t.Errorf("dead upstream stats = %+v, want one tcp failure", s)
+	}
+	if s := stats["tls://"+dot]; s.Successes != 1 || s.Protocol != "tls" {
+		t.Errorf("DoT upstream stats = %+v, want one tls success", s)
+	}
+
+	// Latency ordering applies across transports
+	tracker.RecordSuccess(udp, time.Microsecond)
+	if best := tracker.BestServers(); best[0] != udp {
+		t.Errorf("BestServers()[0] = %s, want the faster %s", best[0], udp)
+	}
+	if _, err := tracker.Exchange(testQuery("tracker.example.")); err != nil {
+		t.Fatal(err)
+	}
+	if s := tracker.Stats()[0]; s.Address != udp || s.Successes != 2 {
+		t.Errorf("fastest upstream = %+v, want %s with two successes", s, udp)
+	}
+}
@@ -1,10 +1,11 @@
 package dnsproxy
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"math"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 // UpstreamStats holds latency and reliability stats for an upstream resolver.
 type UpstreamStats struct {
 	Address     string  `json:"address"`
+	Protocol    string  `json:"protocol"`
 	AvgLatency  float64 `json:"avg_latency_ms"`
 	MinLatency  float64 `json:"min_latency_ms"`
 	MaxLatency  float64 `json:"max_latency_ms"`
@@ -33,6 +35,7 @@ type UpstreamTracker struct {
 	order    []string // addresses sorted by latency
 	logger   *slog.Logger
 	done     chan struct{}
+	stopOnce sync.Once
 	interval time.Duration
 
 	// EWMA smoothing factor (0-1, higher = more weight on recent samples)
@@ -40,30 +43,41 @@ type UpstreamTracker struct {
 }
 
 type upstreamState struct {
-	address    string
-	avgLatency float64 // EWMA in ms
-	minLatency float64
-	maxLatency float64
+	address     string
+	protocol    string
+	transport   transport
+	avgLatency  float64 // EWMA in ms
+	minLatency  float64
+	maxLatency  float64
 	lastLatency float64
-	successes  int64
-	failures   int64
-	lastCheck  time.Time
-	healthy    bool
+	successes   int64
+	failures    int64
+	lastCheck   time.Time
+	healthy     bool
 	// consecutive failures for health marking
 	consecutiveFail int
 }
 
 // NewUpstreamTracker creates a tracker for the given upstream addresses.
+// Addresses are host[:port] for plain DNS or tls://, https:// and quic://
+// URIs; invalid ones are logged and skipped.
 func NewUpstreamTracker(addresses []string, logger *slog.Logger) *UpstreamTracker {
 	servers := make(map[string]*upstreamState, len(addresses))
 	order := make([]string, 0, len(addresses))
 
-	for _, addr := range addresses {
-		if !strings.Contains(addr, ":") {
-			addr = addr + ":53"
+	for _, upstream := range addresses {
+		addr, tr, err := newTransport(upstream)
+		if err != nil {
+			logger.Warn("skipping invalid DNS upstream", "upstream", upstream, "error", err)
+			continue
+		}
+		if _, dup := servers[addr]; dup {
+			continue
 		}
 		servers[addr] = &upstreamState{
 			address:    addr,
+			protocol:   transportProtocol(tr),
+			transport:  tr,
 			avgLatency: 50, // initial estimate 50ms
 			minLatency: math.MaxFloat64,
 			healthy:    true,
@@ -86,9 +100,43 @@ func (t *UpstreamTracker) Start() {
 	go t.probeLoop()
 }
 
-// Stop halts the probe loop.
+// Stop halts the probe loop and closes upstream connections.
 func (t *UpstreamTracker) Stop() {
-	close(t.done)
+	t.stopOnce.Do(func() {
+		close(t.done)
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		for _, s := range t.servers {
+			s.transport.close()
+		}
+	})
+}
+
+// Exchange sends r to the upstreams in latency order until one answers.
+func (t *UpstreamTracker) Exchange(r *dns.Msg) (*dns.Msg, error) {
+	servers := t.BestServers()
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no upstream forwarders configured")
+	}
+
+	var lastErr error
+	for _, addr := range servers {
+		t.mu.RLock()
+		tr := t.servers[addr].transport
+		t.mu.RUnlock()
+
+		start := time.Now()
+		resp, err := tr.exchange(context.Background(), r)
+		if err != nil {
+			lastErr = fmt.Errorf("forwarding to %s: %w", addr, err)
+			t.logger.Debug("upstream DNS server failed", "server", addr, "error", err)
+			t.RecordFailure(addr)
+			continue
+		}
+		t.RecordSuccess(addr, time.Since(start))
+		return resp, nil
+	}
+	return nil, fmt.Errorf("all upstream servers failed: %w", lastErr)
 }
 
 // RecordSuccess records a successful query to an upstream with the given latency.
@@ -176,6 +224,7 @@ func (t *UpstreamTracker) Stats() []UpstreamStats {
 		}
 		result = append(result, UpstreamStats{
 			Address:     s.address,
+			Protocol:    s.protocol,
 			AvgLatency:  math.Round(s.avgLatency*100) / 100,
 			MinLatency:  math.Round(minLat*100) / 100,
 			MaxLatency:  math.Round(s.maxLatency*100) / 100,
@@ -225,12 +274,7 @@ func (t *UpstreamTracker) probeLoop() {
 
 // probeAll sends a test query to each upstream and records results.
 func (t *UpstreamTracker) probeAll() {
-	t.mu.RLock()
-	addrs := make([]string, len(t.order))
-	copy(addrs, t.order)
-	t.mu.RUnlock()
-
-	client := &dns.Client{Timeout: 3 * time.Second}
+	addrs := t.BestServers()
 
 	// Probe with a simple query for "." NS
 	msg := new(dns.Msg)
@@ -238,9 +282,15 @@ func (t *UpstreamTracker) probeAll() {
 	msg.RecursionDesired = true
 
 	for _, addr := range addrs {
+		t.mu.RLock()
+		tr := t.servers[addr].transport
+		t.mu.RUnlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		start := time.Now()
-		_, _, err := client.Exchange(msg, addr)
+		_, err := tr.exchange(ctx, msg)
 		elapsed := time.Since(start)
+		cancel()
 
 		if err != nil {
 			t.RecordFailure(addr)
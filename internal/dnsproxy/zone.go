@@ -152,7 +152,7 @@ func (z *Zone) Count() int {
 	return total
 }
 
-// RegisterLease adds A and optional PTR records for a DHCP lease.
+// RegisterLease adds A (or AAAA for IPv6) and optional PTR records for a DHCP lease.
 func (z *Zone) RegisterLease(hostname string, ip net.IP, addPTR bool) {
 	if hostname == "" || ip == nil {
 		return
@@ -161,16 +161,22 @@ func (z *Zone) RegisterLease(hostname string, ip net.IP, addPTR bool) {
 	fqdn := z.fqdn(hostname)
 	ttl := z.ttl
 
-	// A record
-	a := &dns.A{
-		Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
-		A:   ip.To4(),
+	// A/AAAA record
+	if ip4 := ip.To4(); ip4 != nil {
+		z.Add(&dns.A{
+			Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   ip4,
+		})
+	} else {
+		z.Add(&dns.AAAA{
+			Hdr:  dns.RR_Header{Name: fqdn, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl},
+			AAAA: ip.To16(),
+		})
 	}
-	z.Add(a)
 
 	// PTR record
 	if addPTR {
-		ptrName := reverseIP(ip) + ".in-addr.arpa."
+		ptrName := reversePTRName(ip)
 		ptr := &dns.PTR{
 			Hdr: dns.RR_Header{Name: ptrName, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl},
 			Ptr: fqdn,
@@ -179,7 +185,8 @@ func (z *Zone) RegisterLease(hostname string, ip net.IP, addPTR bool) {
 	}
 }
 
-// UnregisterLease removes A and PTR records for a DHCP lease.
+// UnregisterLease removes A (or AAAA for IPv6) and PTR records for a DHCP lease.
+// With no IP, both address families are removed for the hostname.
 func (z *Zone) UnregisterLease(hostname string, ip net.IP) {
 	if hostname == "" && ip == nil {
 		return
@@ -187,12 +194,16 @@ func (z *Zone) UnregisterLease(hostname string, ip net.IP) {
 
 	if hostname != "" {
 		fqdn := z.fqdn(hostname)
-		z.Remove(fqdn, dns.TypeA)
+		if ip == nil || ip.To4() != nil {
+			z.Remove(fqdn, dns.TypeA)
+		}
+		if ip == nil || ip.To4() == nil {
+			z.Remove(fqdn, dns.TypeAAAA)
+		}
 	}
 
 	if ip != nil {
-		ptrName := reverseIP(ip) + ".in-addr.arpa."
-		z.Remove(ptrName, dns.TypePTR)
+		z.Remove(reversePTRName(ip), dns.TypePTR)
 	}
 }
 
@@ -217,6 +228,18 @@ func reverseIP(ip net.IP) string {
 	return fmt.Sprintf("%d.%d.%d.%d", ip[3], ip[2], ip[1], ip[0])
 }
 
+// reversePTRName returns the in-addr.arpa or ip6.arpa owner name for ip.
+func reversePTRName(ip net.IP) string {
+	if ip.To4() != nil {
+		return reverseIP(ip) + ".in-addr.arpa."
+	}
+	name, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
 // rrValue extracts the value string from an RR for comparison.
 func rrValue(rr dns.RR) string {
 	switch v := rr.(type) {
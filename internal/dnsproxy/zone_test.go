@@ -225,6 +225,38 @@ func TestZoneUnregisterLease(t *testing.T) {
 	}
 }
 
+func TestZoneRegisterLeaseV6(t *testing.T) {
+	z := NewZone("example.com", 300)
+	ip4 := net.ParseIP("192.168.1.50")
+	ip6 := net.ParseIP("2001:db8::10")
+	ptr6 := "0.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."
+
+	z.RegisterLease("myhost", ip4, true)
+	z.RegisterLease("myhost", ip6, true)
+
+	rrs := z.Lookup("myhost.example.com.", dns.TypeAAAA)
+	if len(rrs) != 1 {
+		t.Fatalf("AAAA record lookup returned %d records, want 1", len(rrs))
+	}
+	if !rrs[0].(*dns.AAAA).AAAA.Equal(ip6) {
+		t.Errorf("AAAA record IP = %s, want %s", rrs[0].(*dns.AAAA).AAAA, ip6)
+	}
+	if !z.Has(ptr6, dns.TypePTR) {
+		t.Error("ip6.arpa PTR record should exist")
+	}
+	if !z.Has("myhost.example.com.", dns.TypeA) {
+		t.Error("A record should survive AAAA registration")
+	}
+
+	z.UnregisterLease("myhost", ip6)
+	if z.Has("myhost.example.com.", dns.TypeAAAA) || z.Has(ptr6, dns.TypePTR) {
+		t.Error("AAAA and PTR should be removed after UnregisterLease")
+	}
+	if !z.Has("myhost.example.com.", dns.TypeA) {
+		t.Error("A record should survive AAAA removal")
+	}
+}
+
 func TestZoneAllRecords(t *testing.T) {
 	z := NewZone("example.com", 60)
 
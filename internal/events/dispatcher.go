@@ -127,6 +127,9 @@ func matchesSubnet(subnets []string, evt Event) bool {
 	if evt.Conflict != nil && evtSubnet == "" {
 		evtSubnet = evt.Conflict.Subnet
 	}
+	if evt.Pool != nil && evtSubnet == "" {
+		evtSubnet = evt.Pool.Subnet
+	}
 
 	if evtSubnet == "" {
 		return true // No subnet in event = match all
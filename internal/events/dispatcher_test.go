@@ -45,6 +45,7 @@ func TestMatchesSubnet(t *testing.T) {
 		{"matching subnet", []string{"192.168.1.0/24"}, Event{Lease: &LeaseData{Subnet: "192.168.1.0/24"}}, true},
 		{"non-matching subnet", []string{"10.0.0.0/24"}, Event{Lease: &LeaseData{Subnet: "192.168.1.0/24"}}, false},
 		{"conflict subnet match", []string{"192.168.1.0/24"}, Event{Conflict: &ConflictData{Subnet: "192.168.1.0/24"}}, true},
+		{"pool subnet mismatch", []string{"192.168.1.0/24"}, Event{Pool: &PoolData{Subnet: "10.0.0.0/24"}}, false},
 	}
 
 	for _, tt := range tests {
@@ -31,6 +31,13 @@ const (
 	EventRogueDetected     EventType = "rogue.detected"
 	EventRogueResolved     EventType = "rogue.resolved"
 	EventAnomalyDetected   EventType = "anomaly.detected"
+	EventRadiusReject      EventType = "radius.reject"
+	EventTopologyPortMove  EventType = "topology.port_move"
+	EventPoolHigh          EventType = "pool.utilisation_high"
+	EventPoolExhausted     EventType = "pool.exhausted"
+	EventDNSOverrideAdd    EventType = "dns.override_added"
+	EventDNSOverrideRemove EventType = "dns.override_removed"
+	EventDNSOverrideExpire EventType = "dns.override_expired"
 )
 
 // Event is the core event payload passed through the event bus.
@@ -42,6 +49,8 @@ type Event struct {
 	Server    *ServerData   `json:"server,omitempty"`
 	HA        *HAData       `json:"ha,omitempty"`
 	Rogue     *RogueData    `json:"rogue,omitempty"`
+	Pool      *PoolData     `json:"pool,omitempty"`
+	Override  *OverrideData `json:"dns_override,omitempty"`
 	Reason    string        `json:"reason,omitempty"`
 }
 
@@ -49,19 +58,22 @@ type Event struct {
 // MAC is string (not net.HardwareAddr) because HardwareAddr is []byte
 // which JSON encodes as base64 instead of a human-readable MAC string.
 type LeaseData struct {
-	IP       net.IP                 `json:"ip"`
-	MAC      string                 `json:"mac"`
-	ClientID string                 `json:"client_id,omitempty"`
-	Hostname string                 `json:"hostname,omitempty"`
-	FQDN     string                 `json:"fqdn,omitempty"`
-	Subnet   string                 `json:"subnet"`
-	Pool     string                 `json:"pool,omitempty"`
-	Start    int64                  `json:"start"`
-	Expiry   int64                  `json:"expiry"`
-	State    string                 `json:"state"`
-	OldIP    net.IP                 `json:"old_ip,omitempty"`
-	Options  map[string]interface{} `json:"options,omitempty"`
-	Relay    *RelayData             `json:"relay,omitempty"`
+	IP        net.IP                 `json:"ip"`
+	MAC       string                 `json:"mac"`
+	ClientID  string                 `json:"client_id,omitempty"`
+	DUID      string                 `json:"duid,omitempty"`
+	IAID      uint32                 `json:"iaid,omitempty"`
+	PrefixLen int                    `json:"prefix_len,omitempty"`
+	Hostname  string                 `json:"hostname,omitempty"`
+	FQDN      string                 `json:"fqdn,omitempty"`
+	Subnet    string                 `json:"subnet"`
+	Pool      string                 `json:"pool,omitempty"`
+	Start     int64                  `json:"start"`
+	Expiry    int64                  `json:"expiry"`
+	State     string                 `json:"state"`
+	OldIP     net.IP                 `json:"old_ip,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Relay     *RelayData             `json:"relay,omitempty"`
 }
 
 // RelayData carries relay agent info in events.
@@ -108,6 +120,39 @@ type RogueData struct {
 	Count     int    `json:"count"`
 }
 
+// PoolData carries the state of an address pool in pressure events.
+type PoolData struct {
+	Subnet      string  `json:"subnet"`
+	Pool        string  `json:"pool"`
+	Size        uint32  `json:"size"`
+	Allocated   uint32  `json:"allocated"`
+	Utilisation float64 `json:"utilisation"`         // percent
+	Threshold   float64 `json:"threshold,omitempty"` // high_utilisation that was crossed
+	Overflow    string  `json:"overflow,omitempty"`  // pool or subnet the client was given an address from instead
+}
+
+// OverrideData carries a temporary DNS filtering override in events.
+type OverrideData struct {
+	ID      string `json:"id"`
+	Action  string `json:"action"` // "pause", "allow" or "block"
+	Domain  string `json:"domain,omitempty"`
+	Client  string `json:"client,omitempty"` // IP or MAC; empty for every client
+	Expires int64  `json:"expires"`
+	By      string `json:"by,omitempty"`
+}
+
+// String describes the override, e.g. "allow ads.example.com for 10.0.0.5".
+func (o *OverrideData) String() string {
+	s := o.Action
+	if o.Domain != "" {
+		s += " " + o.Domain
+	}
+	if o.Client != "" {
+		s += " for " + o.Client
+	}
+	return s
+}
+
 // MarshalJSON implements custom JSON marshalling for Event.
 func (e *Event) MarshalJSON() ([]byte, error) {
 	type Alias Event
@@ -187,6 +232,34 @@ func (e *Event) ToEnvVars() map[string]string {
 		}
 	}
 
+	if e.Pool != nil {
+		p := e.Pool
+		env["ATHENA_SUBNET"] = p.Subnet
+		env["ATHENA_POOL"] = p.Pool
+		env["ATHENA_POOL_SIZE"] = fmt.Sprintf("%d", p.Size)
+		env["ATHENA_POOL_ALLOCATED"] = fmt.Sprintf("%d", p.Allocated)
+		env["ATHENA_POOL_UTILISATION"] = fmt.Sprintf("%.1f", p.Utilisation)
+		if p.Overflow != "" {
+			env["ATHENA_POOL_OVERFLOW"] = p.Overflow
+		}
+	}
+
+	if e.Override != nil {
+		o := e.Override
+		env["ATHENA_DNS_OVERRIDE_ID"] = o.ID
+		env["ATHENA_DNS_OVERRIDE_ACTION"] = o.Action
+		env["ATHENA_DNS_OVERRIDE_EXPIRES"] = fmt.Sprintf("%d", o.Expires)
+		if o.Domain != "" {
+			env["ATHENA_DNS_OVERRIDE_DOMAIN"] = o.Domain
+		}
+		if o.Client != "" {
+			env["ATHENA_DNS_OVERRIDE_CLIENT"] = o.Client
+		}
+		if o.By != "" {
+			env["ATHENA_DNS_OVERRIDE_BY"] = o.By
+		}
+	}
+
 	if e.Server != nil {
 		env["ATHENA_SERVER_ID"] = e.Server.NodeID
 	}
@@ -201,6 +201,14 @@ func buildSlackPayload(evt Event) ([]byte, error) {
 			text += fmt.Sprintf("\nSubnet: `%s`", evt.Lease.Subnet)
 		}
 	}
+	if evt.Pool != nil {
+		text += fmt.Sprintf("\nPool: `%s` (%s)", evt.Pool.Pool, evt.Pool.Subnet)
+		text += fmt.Sprintf("\nUtilisation: %.1f%% (%d/%d)", evt.Pool.Utilisation, evt.Pool.Allocated, evt.Pool.Size)
+	}
+	if evt.Override != nil {
+		text += fmt.Sprintf("\nOverride: `%s`", evt.Override)
+		text += fmt.Sprintf("\nExpires: %s", time.Unix(evt.Override.Expires, 0).Format(time.RFC3339))
+	}
 	if evt.Conflict != nil {
 		if evt.Conflict.IP != nil {
 			text += fmt.Sprintf("\nConflict IP: `%s`", evt.Conflict.IP)
@@ -234,6 +242,14 @@ func buildTeamsPayload(evt Event) ([]byte, error) {
 			text += fmt.Sprintf("<br>Hostname: %s", evt.Lease.Hostname)
 		}
 	}
+	if evt.Pool != nil {
+		text += fmt.Sprintf("<br>Pool: %s (%s)", evt.Pool.Pool, evt.Pool.Subnet)
+		text += fmt.Sprintf("<br>Utilisation: %.1f%% (%d/%d)", evt.Pool.Utilisation, evt.Pool.Allocated, evt.Pool.Size)
+	}
+	if evt.Override != nil {
+		text += fmt.Sprintf("<br>Override: %s", evt.Override)
+		text += fmt.Sprintf("<br>Expires: %s", time.Unix(evt.Override.Expires, 0).Format(time.RFC3339))
+	}
 	if evt.Conflict != nil {
 		if evt.Conflict.IP != nil {
 			text += fmt.Sprintf("<br>Conflict IP: %s", evt.Conflict.IP)
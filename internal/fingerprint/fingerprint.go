@@ -17,6 +17,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -74,7 +75,7 @@ func (rf *RawFingerprint) ParamListString() string {
 
 // Store provides persistent storage and lookup of device fingerprints.
 type Store struct {
-	db         *bolt.DB
+	db         boltdb.DB
 	logger     *slog.Logger
 	mu         sync.RWMutex
 	cache      map[string]*DeviceInfo // mac → DeviceInfo
@@ -82,7 +83,7 @@ type Store struct {
 }
 
 // NewStore creates a new fingerprint store backed by BoltDB.
-func NewStore(db *bolt.DB, logger *slog.Logger) (*Store, error) {
+func NewStore(db boltdb.DB, logger *slog.Logger) (*Store, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		if _, err := tx.CreateBucketIfNotExists(bucketFingerprints); err != nil {
 			return fmt.Errorf("creating fingerprints bucket: %w", err)
@@ -0,0 +1,181 @@
+package ha
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+)
+
+// Arbiter decides whether this node may take over from a peer whose
+// heartbeats have stopped. A false answer comes with the reason.
+type Arbiter interface {
+	MayTakeOver(ctx context.Context) (bool, string)
+}
+
+// Pinger sends ICMP echo requests; conflict.ICMPProber satisfies it.
+type Pinger interface {
+	Available() bool
+	Probe(ctx context.Context, ip net.IP) (bool, error)
+}
+
+// SplitBrainGuard is the Arbiter built from ha.split_brain. A silent peer
+// only counts as down when at least one gateway answers (so this node is
+// not the isolated one) and the witness has not heard from the peer
+// within the failover timeout (so it is not just the sync link that broke).
+type SplitBrainGuard struct {
+	witness  *WitnessClient
+	peerNode string
+	liveness time.Duration // peer is alive if it reported to the witness this recently
+	interval time.Duration // how often to report to the witness
+	gateways []net.IP
+	pinger   Pinger
+	timeout  time.Duration
+	logger   *slog.Logger
+}
+
+// NewSplitBrainGuard creates the guard for cfg. pinger may be nil when no
+// gateways are configured. Returns nil if no check is configured.
+func NewSplitBrainGuard(cfg *config.HAConfig, pinger Pinger, logger *slog.Logger) *SplitBrainGuard {
+	sb := cfg.SplitBrain
+	if !sb.Enabled() {
+		return nil
+	}
+	timeout, err := time.ParseDuration(sb.CheckTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = config.DefaultHASplitBrainTimeout
+	}
+	liveness, err := time.ParseDuration(cfg.FailoverTimeout)
+	if err != nil || liveness <= 0 {
+		liveness = config.DefaultHAFailoverTimeout
+	}
+	interval, err := time.ParseDuration(cfg.HeartbeatInterval)
+	if err != nil || interval <= 0 {
+		interval = config.DefaultHAHeartbeatInterval
+	}
+
+	g := &SplitBrainGuard{
+		peerNode: peerRole(cfg.Role),
+		liveness: liveness,
+		interval: interval,
+		pinger:   pinger,
+		timeout:  timeout,
+		logger:   logger,
+	}
+	if sb.Witness != "" {
+		g.witness = NewWitnessClient(sb.Witness, sb.Group, cfg.Role, timeout)
+	}
+	for _, gw := range sb.Gateways {
+		if ip := net.ParseIP(gw); ip != nil {
+			g.gateways = append(g.gateways, ip)
+		}
+	}
+	if len(g.gateways) > 0 && (pinger == nil || !pinger.Available()) {
+		logger.Error("ICMP unavailable — split-brain gateway checks are DISABLED",
+			"gateways", sb.Gateways,
+			"hint", "Grant CAP_NET_RAW capability or run as root")
+		g.gateways = nil
+	}
+
+	logger.Info("HA split-brain protection enabled",
+		"witness", sb.Witness,
+		"group", sb.Group,
+		"gateways", len(g.gateways),
+		"check_timeout", timeout.String())
+	return g
+}
+
+// peerRole returns the role of the other node in a pair.
+func peerRole(role string) string {
+	if role == "primary" {
+		return "secondary"
+	}
+	return "primary"
+}
+
+// Start reports to the witness every heartbeat interval until ctx is done,
+// so the peer can tell this node is alive even when the sync link is not.
+func (g *SplitBrainGuard) Start(ctx context.Context) {
+	if g.witness == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+
+		reachable := true
+		for {
+			_, err := g.witness.Report(ctx)
+			switch {
+			case err != nil && reachable:
+				g.logger.Warn("HA witness unreachable", "error", err)
+			case err == nil && !reachable:
+				g.logger.Info("HA witness reachable again")
+			}
+			reachable = err == nil
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// MayTakeOver runs the gateway and witness checks.
+func (g *SplitBrainGuard) MayTakeOver(ctx context.Context) (bool, string) {
+	ok, reason := g.check(ctx)
+	result := "takeover"
+	if !ok {
+		result = "refused"
+	}
+	metrics.HASplitBrainChecks.WithLabelValues(result).Inc()
+	g.logger.Warn("HA split-brain check", "may_take_over", ok, "reason", reason)
+	return ok, reason
+}
+
+func (g *SplitBrainGuard) check(ctx context.Context) (bool, string) {
+	var reason string
+	if len(g.gateways) > 0 {
+		gw := g.reachableGateway(ctx)
+		if gw == nil {
+			return false, "no gateway answered — this node may be the isolated one"
+		}
+		reason = fmt.Sprintf("gateway %s reachable", gw)
+	}
+
+	if g.witness != nil {
+		wctx, cancel := context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+		ages, err := g.witness.Report(wctx)
+		if err != nil {
+			return false, "witness unreachable: " + err.Error()
+		}
+		if age, seen := ages[g.peerNode]; seen && age < g.liveness {
+			return false, fmt.Sprintf("witness heard from the peer %s ago — only the sync link is down", age.Round(time.Millisecond))
+		}
+		reason = "witness has not heard from the peer"
+	}
+	return true, reason
+}
+
+// reachableGateway returns the first gateway that answers an echo request.
+func (g *SplitBrainGuard) reachableGateway(ctx context.Context) net.IP {
+	for _, gw := range g.gateways {
+		pctx, cancel := context.WithTimeout(ctx, g.timeout)
+		ok, err := g.pinger.Probe(pctx, gw)
+		cancel()
+		if err != nil {
+			g.logger.Debug("gateway probe failed", "gateway", gw.String(), "error", err)
+		}
+		if ok {
+			return gw
+		}
+	}
+	return nil
+}
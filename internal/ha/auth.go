@@ -0,0 +1,144 @@
+package ha
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+)
+
+// Reasons a signed frame is rejected, also used as metric labels.
+var (
+	errFrameUnsigned = errors.New("unsigned")
+	errFrameBadMAC   = errors.New("bad_mac")
+	errFrameStale    = errors.New("stale")
+	errFrameReplay   = errors.New("replay")
+)
+
+// frameAuth signs and verifies every sync message with the pre-shared key
+// (ha.auth_key). The MAC covers the type, timestamp, nonce and payload. A
+// frame is accepted only if its timestamp is within the window of the
+// local clock and its nonce has not been seen within the window, so a
+// captured frame can't be replayed — nor reflected back to its sender,
+// whose own nonces are remembered too.
+type frameAuth struct {
+	key    []byte
+	window time.Duration
+
+	mu     sync.Mutex
+	seen   map[string]time.Time // nonce → when it leaves the window
+	pruned time.Time
+}
+
+// newFrameAuth returns the frame authenticator for cfg, or nil when no
+// pre-shared key is configured.
+func newFrameAuth(cfg *config.HAConfig) (*frameAuth, error) {
+	key := []byte(cfg.AuthKey)
+	if cfg.AuthKeyFile != "" {
+		data, err := os.ReadFile(cfg.AuthKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ha.auth_key_file: %w", err)
+		}
+		key = bytes.TrimSpace(data)
+		if len(key) < config.MinHAAuthKeyLength {
+			return nil, fmt.Errorf("ha.auth_key_file must hold at least %d characters", config.MinHAAuthKeyLength)
+		}
+	}
+	if len(key) == 0 {
+		return nil, nil
+	}
+	window, err := time.ParseDuration(cfg.AuthWindow)
+	if err != nil || window <= 0 {
+		window = config.DefaultHAAuthWindow
+	}
+	return &frameAuth{key: key, window: window, seen: make(map[string]time.Time)}, nil
+}
+
+// mac computes the MAC of msg.
+func (a *frameAuth) mac(msg *Message) []byte {
+	h := hmac.New(sha256.New, a.key)
+	var hdr [9]byte
+	hdr[0] = byte(msg.Type)
+	binary.BigEndian.PutUint64(hdr[1:], uint64(msg.Timestamp))
+	h.Write(hdr[:])
+	h.Write([]byte(msg.Nonce))
+	h.Write(msg.Payload)
+	return h.Sum(nil)
+}
+
+// sign returns a signed copy of msg, stamped with the current time. msg
+// itself is left alone since it may be sent to several members at once.
+func (a *frameAuth) sign(msg *Message) (*Message, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("generating frame nonce: %w", err)
+	}
+	signed := *msg
+	signed.Timestamp = time.Now().Unix()
+	signed.Nonce = hex.EncodeToString(nonce[:])
+	signed.MAC = hex.EncodeToString(a.mac(&signed))
+	a.remember(signed.Nonce, time.Now())
+	return &signed, nil
+}
+
+// verify checks msg's MAC, age and nonce. The error is one of the
+// errFrame reasons.
+func (a *frameAuth) verify(msg *Message) error {
+	if msg.MAC == "" || msg.Nonce == "" {
+		return errFrameUnsigned
+	}
+	got, err := hex.DecodeString(msg.MAC)
+	if err != nil || !hmac.Equal(got, a.mac(msg)) {
+		return errFrameBadMAC
+	}
+	now := time.Now()
+	if d := now.Sub(time.Unix(msg.Timestamp, 0)); d > a.window || d < -a.window {
+		return errFrameStale
+	}
+	if !a.remember(msg.Nonce, now) {
+		return errFrameReplay
+	}
+	return nil
+}
+
+// remember records nonce and reports whether it was new.
+func (a *frameAuth) remember(nonce string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.pruned) > a.window {
+		for n, until := range a.seen {
+			if now.After(until) {
+				delete(a.seen, n)
+			}
+		}
+		a.pruned = now
+	}
+	if _, dup := a.seen[nonce]; dup {
+		return false
+	}
+	// Frames may be up to a window old or early, so keep the nonce for two
+	a.seen[nonce] = now.Add(2 * a.window)
+	return true
+}
+
+// checkFrame verifies msg when a key is configured, counting failures.
+func (a *frameAuth) checkFrame(msg *Message) error {
+	if a == nil {
+		return nil
+	}
+	if err := a.verify(msg); err != nil {
+		metrics.HAAuthFailures.WithLabelValues(err.Error()).Inc()
+		return fmt.Errorf("rejecting HA frame: %w", err)
+	}
+	return nil
+}
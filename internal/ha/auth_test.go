@@ -0,0 +1,131 @@
+package ha
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+const testAuthKey = "0123456789abcdef0123"
+
+func newTestAuth(t *testing.T, key string) *frameAuth {
+	t.Helper()
+	a, err := newFrameAuth(&config.HAConfig{AuthKey: key, AuthWindow: "30s"})
+	if err != nil {
+		t.Fatalf("newFrameAuth error: %v", err)
+	}
+	return a
+}
+
+func TestFrameAuth(t *testing.T) {
+	msg, err := NewHeartbeat("ACTIVE", 1, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewHeartbeat error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(m *Message)
+		verify *frameAuth // defaults to a second node with the same key
+		want   error
+	}{
+		{"valid", func(*Message) {}, nil, nil},
+		{"unsigned", func(m *Message) { m.MAC, m.Nonce = "", "" }, nil, errFrameUnsigned},
+		{"tampered payload", func(m *Message) { m.Payload = []byte(`{"state":"STANDBY"}`) }, nil, errFrameBadMAC},
+		{"tampered type", func(m *Message) { m.Type++ }, nil, errFrameBadMAC},
+		{"wrong key", func(*Message) {}, newTestAuth(t, "another-key-entirely"), errFrameBadMAC},
+		{"timestamp altered", func(m *Message) { m.Timestamp -= 60 }, nil, errFrameBadMAC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newTestAuth(t, testAuthKey)
+			verifier := tt.verify
+			if verifier == nil {
+				verifier = newTestAuth(t, testAuthKey)
+			}
+			signed, err := signer.sign(msg)
+			if err != nil {
+				t.Fatalf("sign error: %v", err)
+			}
+			tt.mutate(signed)
+			if err := verifier.verify(signed); !errors.Is(err, tt.want) {
+				t.Errorf("verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if msg.MAC != "" || msg.Nonce != "" {
+		t.Error("sign modified the original message")
+	}
+}
+
+func TestFrameAuthStale(t *testing.T) {
+	a := newTestAuth(t, testAuthKey)
+	for _, skew := range []int64{-60, 60} {
+		m := &Message{Type: 1, Timestamp: time.Now().Unix() + skew, Nonce: "n"}
+		m.MAC = hexMAC(a, m)
+		if err := a.verify(m); !errors.Is(err, errFrameStale) {
+			t.Errorf("skew %ds: verify = %v, want %v", skew, err, errFrameStale)
+		}
+	}
+}
+
+func TestFrameAuthReplay(t *testing.T) {
+	signer := newTestAuth(t, testAuthKey)
+	verifier := newTestAuth(t, testAuthKey)
+	msg := &Message{Type: 1, Payload: []byte(`{}`)}
+	signed, err := signer.sign(msg)
+	if err != nil {
+		t.Fatalf("sign error: %v", err)
+	}
+	if err := verifier.verify(signed); err != nil {
+		t.Fatalf("first verify = %v", err)
+	}
+	if err := verifier.verify(signed); !errors.Is(err, errFrameReplay) {
+		t.Errorf("replayed verify = %v, want %v", err, errFrameReplay)
+	}
+	// Reflected back to its sender
+	if err := signer.verify(signed); !errors.Is(err, errFrameReplay) {
+		t.Errorf("reflected verify = %v, want %v", err, errFrameReplay)
+	}
+}
+
+func TestNewFrameAuth(t *testing.T) {
+	if a, err := newFrameAuth(&config.HAConfig{}); a != nil || err != nil {
+		t.Errorf("no key: got %v, %v; want nil, nil", a, err)
+	}
+	if err := (*frameAuth)(nil).checkFrame(&Message{}); err != nil {
+		t.Errorf("nil checkFrame = %v", err)
+	}
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	short := filepath.Join(dir, "short")
+	os.WriteFile(good, []byte(testAuthKey+"\n"), 0o600)
+	os.WriteFile(short, []byte("short\n"), 0o600)
+
+	a, err := newFrameAuth(&config.HAConfig{AuthKeyFile: good})
+	if err != nil {
+		t.Fatalf("key file: %v", err)
+	}
+	if string(a.key) != testAuthKey {
+		t.Errorf("key = %q, want %q", a.key, testAuthKey)
+	}
+	if a.window != config.DefaultHAAuthWindow {
+		t.Errorf("window = %v, want %v", a.window, config.DefaultHAAuthWindow)
+	}
+	if _, err := newFrameAuth(&config.HAConfig{AuthKeyFile: short}); err == nil {
+		t.Error("short key file accepted")
+	}
+	if _, err := newFrameAuth(&config.HAConfig{AuthKeyFile: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing key file accepted")
+	}
+}
+
+func hexMAC(a *frameAuth, m *Message) string {
+	return hex.EncodeToString(a.mac(m))
+}
@@ -0,0 +1,1092 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotLeader is returned for changes that only the cluster leader may make.
+var ErrNotLeader = errors.New("this node is not the cluster leader")
+
+// bucketClusterVote holds this node's current term and vote. It is written
+// before a vote goes out, so a restart never hands out a second vote in
+// the same term.
+var (
+	bucketClusterVote = []byte("ha_cluster")
+	keyClusterVote    = []byte("vote")
+)
+
+// voteRecord is the persisted election state.
+type voteRecord struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"voted_for,omitempty"`
+}
+
+// Cluster runs HA across three or more nodes. Every pair of members shares
+// one connection — the member with the lower ID dials — carrying the same
+// lease, conflict and config sync as a two-node pair. One member at a time
+// is elected leader, Raft-style: it serves DHCP while the others stand by,
+// and it keeps the lead only while it can reach a majority, so a
+// partitioned minority never serves.
+//
+// Elections are preceded by a pre-vote: a node that lost touch with the
+// leader first asks whether a majority would vote for it, and only then
+// starts a new term. A member that was merely cut off therefore rejoins
+// without deposing a healthy leader.
+type Cluster struct {
+	cfg       *config.HAConfig
+	self      string
+	fsm       *FSM
+	store     lease.Storage
+	bus       *events.Bus
+	logger    *slog.Logger
+	heartbeat time.Duration // leader announce interval
+	timeout   time.Duration // election timeout base and member liveness (ha.failover_timeout)
+	auth      *frameAuth    // shared by every link
+	certs     *tlsCerts     // shared by every link
+
+	ctx      context.Context
+	listener net.Listener
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu            sync.Mutex
+	members       map[string]*member // every other member by ID
+	memberList    []config.HAMember  // all members, this node included
+	term          uint64
+	votedFor      string
+	leader        string
+	leaderContact time.Time       // last announce from the leader
+	votes         map[string]bool // granted (pre-)votes while campaigning
+	preVoting     bool            // votes are pre-votes for term+1
+	deadline      time.Time       // campaign if no leader is heard from by then
+	announcedAt   time.Time
+	dataTerm      uint64 // term of the newest lease data held here
+	dataSeq       uint64 // leader sequence number of that data
+	stateDirty    bool
+
+	onLeaseUpdate     func(LeaseUpdatePayload)
+	onLeaseAck        func(LeaseAckPayload)
+	onConflictUpdate  func(ConflictUpdatePayload)
+	onConfigSync      func(ConfigSyncPayload)
+	onAdjacencyFormed func(*Peer)
+	onMembership      func([]config.HAMember)
+}
+
+// member is another node of the cluster and the link to it.
+type member struct {
+	id       string
+	address  string
+	link     *Peer
+	lastSeen time.Time
+	up       bool
+}
+
+// MemberStatus describes one member as seen from this node.
+type MemberStatus struct {
+	ID        string      `json:"id"`
+	Address   string      `json:"address"`
+	Self      bool        `json:"self,omitempty"`
+	Leader    bool        `json:"leader,omitempty"`
+	Connected bool        `json:"connected"`
+	LastSeen  time.Time   `json:"last_seen,omitzero"`
+	Queue     QueueStatus `json:"-"`
+}
+
+// ClusterStatus is this node's view of the cluster.
+type ClusterStatus struct {
+	NodeID  string
+	Term    uint64
+	Leader  string
+	Quorum  int
+	Members []MemberStatus
+}
+
+// NewCluster creates the cluster node described by cfg (ha.mode =
+// "cluster"). fsm should be in cluster mode; the cluster moves it between
+// ACTIVE (leader) and STANDBY.
+func NewCluster(cfg *config.HAConfig, fsm *FSM, store lease.Storage, bus *events.Bus, logger *slog.Logger) (*Cluster, error) {
+	heartbeat, err := time.ParseDuration(cfg.HeartbeatInterval)
+	if err != nil || heartbeat <= 0 {
+		heartbeat = config.DefaultHAHeartbeatInterval
+	}
+	timeout, err := time.ParseDuration(cfg.FailoverTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = config.DefaultHAFailoverTimeout
+	}
+
+	auth, err := newFrameAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := newTLSCerts(cfg.TLS, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{
+		cfg:       cfg,
+		self:      cfg.NodeID,
+		fsm:       fsm,
+		store:     store,
+		bus:       bus,
+		logger:    logger.With("node", cfg.NodeID),
+		heartbeat: heartbeat,
+		timeout:   timeout,
+		auth:      auth,
+		certs:     certs,
+		done:      make(chan struct{}),
+		members:   make(map[string]*member),
+	}
+	c.term = store.SyncMark(c.mark("term"))
+	c.dataTerm = store.SyncMark(c.mark("data_term"))
+	c.dataSeq = store.SyncMark(c.mark("data_seq"))
+	vote, ok, err := loadVote(store.DB())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ok && vote.Term >= c.term:
+		c.term, c.votedFor = vote.Term, vote.VotedFor
+	case !ok && c.term > 0:
+		// State from a version that didn't persist votes: count the vote
+		// in the restored term as spent on ourselves rather than risk a
+		// second one.
+		c.votedFor = c.self
+	}
+
+	for _, m := range cfg.Members {
+		if m.ID == c.self {
+			continue
+		}
+		mem, err := c.newMember(m)
+		if err != nil {
+			return nil, err
+		}
+		c.members[m.ID] = mem
+	}
+	c.memberList = append([]config.HAMember(nil), cfg.Members...)
+	return c, nil
+}
+
+// mark names a piece of election state in the lease store.
+func (c *Cluster) mark(kind string) string {
+	return "ha/cluster/" + kind
+}
+
+// newMember creates the link to m. The cfg copy points the link's queue
+// and sync marks at the member, and its TLS name at the member's.
+func (c *Cluster) newMember(m config.HAMember) (*member, error) {
+	linkCfg := *c.cfg
+	linkCfg.PeerAddress = m.Address
+	linkCfg.TLS.PeerName = m.TLSName
+	link, err := newPeer(&linkCfg, c.fsm, c.store, c.bus, c.logger.With("member", m.ID), c.auth, c.certs)
+	if err != nil {
+		return nil, fmt.Errorf("creating link to cluster member %s: %w", m.ID, err)
+	}
+	link.watch = &memberWatch{c: c, id: m.ID}
+	link.onClusterMessage = func(msg *Message) { c.handleMessage(m.ID, msg) }
+	link.OnLeaseUpdate(func(lu LeaseUpdatePayload) {
+		c.noteLeaderData(m.ID, lu.Seq)
+		if c.onLeaseUpdate != nil {
+			c.onLeaseUpdate(lu)
+		}
+	})
+	link.OnLeaseAck(func(la LeaseAckPayload) {
+		if c.onLeaseAck != nil {
+			c.onLeaseAck(la)
+		}
+	})
+	link.OnConflictUpdate(func(cu ConflictUpdatePayload) {
+		if c.onConflictUpdate != nil {
+			c.onConflictUpdate(cu)
+		}
+	})
+	link.OnConfigSync(func(cs ConfigSyncPayload) {
+		if c.onConfigSync != nil {
+			c.onConfigSync(cs)
+		}
+	})
+	link.OnAdjacencyFormed(func() {
+		if c.onAdjacencyFormed != nil {
+			c.onAdjacencyFormed(link)
+		}
+	})
+	if c.self < m.ID {
+		link.hello, err = newMessage(dhcpv4.HAMsgHello, HelloPayload{Node: c.self})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &member{id: m.ID, address: m.Address, link: link}, nil
+}
+
+// OnLeaseUpdate sets a callback for lease updates from any member.
+func (c *Cluster) OnLeaseUpdate(fn func(LeaseUpdatePayload)) { c.onLeaseUpdate = fn }
+
+// OnLeaseAck sets a callback for members' acknowledgements of our updates.
+func (c *Cluster) OnLeaseAck(fn func(LeaseAckPayload)) { c.onLeaseAck = fn }
+
+// OnConflictUpdate sets a callback for conflict updates from any member.
+func (c *Cluster) OnConflictUpdate(fn func(ConflictUpdatePayload)) { c.onConflictUpdate = fn }
+
+// OnConfigSync sets a callback for config sections from any member.
+func (c *Cluster) OnConfigSync(fn func(ConfigSyncPayload)) { c.onConfigSync = fn }
+
+// OnAdjacencyFormed sets a callback run with the member's link every time
+// a connection to a member is established. The leader pushes its config
+// here.
+func (c *Cluster) OnAdjacencyFormed(fn func(*Peer)) { c.onAdjacencyFormed = fn }
+
+// OnMembership sets a callback run with the new member list after a
+// membership change, on every node, so it can be written to the config file.
+func (c *Cluster) OnMembership(fn func([]config.HAMember)) { c.onMembership = fn }
+
+// FSM returns the failover state machine the cluster drives.
+func (c *Cluster) FSM() *FSM {
+	return c.fsm
+}
+
+// Start listens for member connections and starts the links and the
+// election loop.
+func (c *Cluster) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", c.cfg.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", c.cfg.ListenAddress, err)
+	}
+	c.start(ctx, ln)
+	return nil
+}
+
+// start runs the cluster on an existing listener.
+func (c *Cluster) start(ctx context.Context, ln net.Listener) {
+	c.ctx = ctx
+	c.listener = ln
+
+	c.mu.Lock()
+	for _, m := range c.members {
+		m.link.startLink(ctx, m.link.hello != nil)
+	}
+	c.resetDeadline()
+	quorum := c.quorum()
+	c.mu.Unlock()
+
+	c.logger.Info("HA cluster node started",
+		"listen", ln.Addr().String(),
+		"members", len(c.memberList),
+		"quorum", quorum,
+		"term", c.term)
+
+	c.wg.Add(2)
+	go c.acceptLoop(ctx)
+	go c.electionLoop(ctx)
+}
+
+// Stop disconnects from every member.
+func (c *Cluster) Stop() {
+	close(c.done)
+	if c.listener != nil {
+		c.listener.Close()
+	}
+	c.wg.Wait()
+
+	c.mu.Lock()
+	links := make([]*Peer, 0, len(c.members))
+	for _, m := range c.members {
+		links = append(links, m.link)
+	}
+	c.mu.Unlock()
+	for _, l := range links {
+		l.Stop()
+	}
+	c.saveState()
+	c.logger.Info("HA cluster node stopped")
+}
+
+// acceptLoop hands inbound connections to the link of the member named in
+// the connection's hello.
+func (c *Cluster) acceptLoop(ctx context.Context) {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			case <-ctx.Done():
+				return
+			default:
+			}
+			c.logger.Error("accepting cluster connection", "error", err)
+			continue
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.admit(ctx, conn)
+		}()
+	}
+}
+
+// admit completes the TLS handshake if enabled, reads the hello and
+// checks it and the certificate against the member it names.
+func (c *Cluster) admit(ctx context.Context, conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	if c.certs != nil {
+		// Which member dialled is only known from the hello, so the
+		// certificate name is checked below
+		tc, err := c.certs.serverHandshake(conn, "")
+		if err != nil {
+			c.logger.Warn("rejecting cluster connection", "remote", remote, "error", err)
+			return
+		}
+		conn = tc
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := DecodeMessage(conn)
+	if err == nil {
+		err = c.auth.checkFrame(msg)
+	}
+	var hello HelloPayload
+	if err == nil && msg.Type == dhcpv4.HAMsgHello {
+		err = json.Unmarshal(msg.Payload, &hello)
+	} else if err == nil {
+		err = fmt.Errorf("expected hello, got message type %d", msg.Type)
+	}
+	if err != nil {
+		c.logger.Warn("rejecting cluster connection", "remote", remote, "error", err)
+		conn.Close()
+		return
+	}
+
+	c.mu.Lock()
+	m := c.members[hello.Node]
+	c.mu.Unlock()
+	if m == nil {
+		c.logger.Warn("rejecting connection from unknown cluster member", "remote", remote, "node", hello.Node)
+		conn.Close()
+		return
+	}
+	if err := checkPeerName(conn, m.link.tlsName); err != nil {
+		c.logger.Warn("rejecting cluster connection", "member", m.id, "remote", remote, "error", err)
+		conn.Close()
+		return
+	}
+	c.logger.Info("inbound cluster connection accepted", "member", m.id, "remote", remote)
+	m.link.attach(ctx, conn, "inbound")
+}
+
+// electionLoop announces leadership, watches member liveness and starts a
+// campaign when the leader goes quiet.
+func (c *Cluster) electionLoop(ctx context.Context) {
+	defer c.wg.Done()
+	tick := max(c.heartbeat/4, 10*time.Millisecond)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	save := time.NewTicker(c.heartbeat)
+	defer save.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case <-save.C:
+			c.saveState()
+		case <-ticker.C:
+			c.tick()
+		}
+	}
+}
+
+// tick runs one round of the election loop.
+func (c *Cluster) tick() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reachable := 1
+	for _, m := range c.members {
+		alive := m.link.Connected() && now.Sub(m.lastSeen) < c.timeout
+		if alive {
+			reachable++
+		}
+		if m.up && !alive {
+			m.up = false
+			c.publishMember(events.EventHAPeerDown, m.id, "disconnected",
+				fmt.Sprintf("cluster member %s unreachable", m.id))
+		}
+	}
+	metrics.HAClusterMembersReachable.Set(float64(reachable))
+
+	if c.leader == c.self {
+		if reachable < c.quorum() {
+			c.stepDown(fmt.Sprintf("lost contact with a majority (%d of %d reachable)", reachable, len(c.memberList)))
+			return
+		}
+		if now.Sub(c.announcedAt) >= c.heartbeat {
+			c.announce()
+		}
+		return
+	}
+
+	if now.After(c.deadline) {
+		c.campaign(true)
+	}
+}
+
+// quorum is the number of members that make a majority. Caller holds c.mu.
+func (c *Cluster) quorum() int {
+	return len(c.memberList)/2 + 1
+}
+
+// resetDeadline schedules the next campaign a randomised election timeout
+// from now, so members rarely campaign at once. Caller holds c.mu.
+func (c *Cluster) resetDeadline() {
+	c.deadline = time.Now().Add(c.timeout + rand.N(c.timeout))
+}
+
+// campaign asks every member for a pre-vote, or for its vote in a new term
+// when pre is false. Caller holds c.mu.
+func (c *Cluster) campaign(pre bool) {
+	c.resetDeadline()
+	c.preVoting = pre
+	c.votes = map[string]bool{c.self: true}
+	term := c.term + 1
+	if !pre {
+		c.term = term
+		c.votedFor = c.self
+		c.leader = ""
+		c.stateDirty = true
+		metrics.HAClusterElections.Inc()
+		metrics.HAClusterTerm.Set(float64(c.term))
+		if err := c.saveVote(); err != nil {
+			c.logger.Warn("failed to save HA cluster vote, not campaigning", "term", term, "error", err)
+			c.votes = nil
+			return
+		}
+		c.logger.Info("starting cluster election", "term", term)
+	}
+
+	dataTerm, dataSeq, err := c.dataPosition()
+	if err != nil {
+		c.logger.Warn("failed to read lease data position, not campaigning", "term", term, "error", err)
+		c.votes = nil
+		return
+	}
+	c.broadcast(dhcpv4.HAMsgVoteRequest, VoteRequestPayload{
+		Term:      term,
+		Candidate: c.self,
+		DataTerm:  dataTerm,
+		DataSeq:   dataSeq,
+		PreVote:   pre,
+	})
+	c.countVotes()
+}
+
+// countVotes moves on once a majority granted the (pre-)vote. Caller holds c.mu.
+func (c *Cluster) countVotes() {
+	if c.votes == nil || len(c.votes) < c.quorum() {
+		return
+	}
+	if c.preVoting {
+		c.campaign(false)
+		return
+	}
+	c.becomeLeader()
+}
+
+// becomeLeader takes the lead for the current term. Caller holds c.mu.
+func (c *Cluster) becomeLeader() {
+	votes := len(c.votes)
+	c.leader = c.self
+	c.votes = nil
+	c.dataTerm = c.term
+	c.noteOwnSeq()
+	c.stateDirty = true
+	metrics.HAClusterLeader.Set(1)
+	c.announce()
+	c.logger.Warn("elected cluster leader", "term", c.term, "votes", votes, "members", len(c.memberList))
+	c.fsm.transition(dhcpv4.HAStateActive, fmt.Sprintf("elected cluster leader for term %d (%d of %d votes)", c.term, votes, len(c.memberList)))
+}
+
+// stepDown gives up the lead (or stops following a leader). Caller holds c.mu.
+func (c *Cluster) stepDown(reason string) {
+	if c.leader == c.self {
+		c.noteOwnSeq()
+		c.stateDirty = true
+		metrics.HAClusterLeader.Set(0)
+		c.logger.Warn("stepping down as cluster leader", "term", c.term, "reason", reason)
+	}
+	c.leader = ""
+	c.votes = nil
+	c.resetDeadline()
+	c.fsm.transition(dhcpv4.HAStateStandby, reason)
+}
+
+// follow accepts leader as the leader of the current term. Caller holds c.mu.
+func (c *Cluster) follow(leader string) {
+	c.leaderContact = time.Now()
+	c.votes = nil
+	c.resetDeadline()
+	if c.leader == leader {
+		return
+	}
+	if c.leader == c.self {
+		metrics.HAClusterLeader.Set(0)
+	}
+	c.leader = leader
+	c.logger.Info("following cluster leader", "leader", leader, "term", c.term)
+	c.fsm.transition(dhcpv4.HAStateStandby, fmt.Sprintf("following cluster leader %s (term %d)", leader, c.term))
+}
+
+// adoptTerm moves to a newer term seen from another member. Caller holds c.mu.
+func (c *Cluster) adoptTerm(term uint64) {
+	if term <= c.term {
+		return
+	}
+	wasLeader := c.leader == c.self
+	c.term = term
+	c.votedFor = ""
+	c.stateDirty = true
+	metrics.HAClusterTerm.Set(float64(term))
+	if err := c.saveVote(); err != nil {
+		c.logger.Warn("failed to save HA cluster term", "term", term, "error", err)
+	}
+	if wasLeader {
+		c.stepDown(fmt.Sprintf("member in newer term %d", term))
+	}
+	c.leader = ""
+}
+
+// announce tells every member this node leads. Caller holds c.mu.
+func (c *Cluster) announce() {
+	c.announcedAt = time.Now()
+	c.broadcast(dhcpv4.HAMsgLeaderAnnounce, LeaderAnnouncePayload{Term: c.term, Leader: c.self})
+}
+
+// dataPosition returns the term and leader sequence number of the newest
+// lease data held here. Caller holds c.mu.
+func (c *Cluster) dataPosition() (uint64, uint64, error) {
+	if c.leader == c.self {
+		seq, err := c.store.CurrentSeq()
+		return c.term, seq, err
+	}
+	return c.dataTerm, c.dataSeq, nil
+}
+
+// noteOwnSeq records the local sequence number as the data position while
+// this node leads, keeping the previous one if the store can't be read.
+// Caller holds c.mu.
+func (c *Cluster) noteOwnSeq() {
+	seq, err := c.store.CurrentSeq()
+	if err != nil {
+		c.logger.Warn("failed to read lease sequence, keeping previous data position", "seq", c.dataSeq, "error", err)
+		return
+	}
+	c.dataSeq = seq
+}
+
+// noteLeaderData records lease data received from the leader of the
+// current term.
+func (c *Cluster) noteLeaderData(from string, seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if from != c.leader {
+		return
+	}
+	if c.dataTerm != c.term {
+		c.dataTerm, c.dataSeq = c.term, 0
+	}
+	if seq > c.dataSeq {
+		c.dataSeq = seq
+		c.stateDirty = true
+	}
+}
+
+// saveState persists the term and data position if they changed.
+func (c *Cluster) saveState() {
+	c.mu.Lock()
+	if !c.stateDirty {
+		c.mu.Unlock()
+		return
+	}
+	term, dataTerm, dataSeq := c.term, c.dataTerm, c.dataSeq
+	c.stateDirty = false
+	c.mu.Unlock()
+
+	for name, v := range map[string]uint64{"term": term, "data_term": dataTerm, "data_seq": dataSeq} {
+		if err := c.store.SetSyncMark(c.mark(name), v); err != nil {
+			c.logger.Warn("failed to save HA cluster state", "error", err)
+			return
+		}
+	}
+}
+
+// saveVote persists the current term and vote. Caller holds c.mu.
+func (c *Cluster) saveVote() error {
+	data, err := json.Marshal(voteRecord{Term: c.term, VotedFor: c.votedFor})
+	if err != nil {
+		return err
+	}
+	return c.store.DB().Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketClusterVote)
+		if err != nil {
+			return fmt.Errorf("creating HA cluster bucket: %w", err)
+		}
+		return b.Put(keyClusterVote, data)
+	})
+}
+
+// loadVote reads the election state saved by saveVote. ok is false if
+// none was saved.
+func loadVote(db boltdb.DB) (vote voteRecord, ok bool, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketClusterVote)
+		if b == nil {
+			return nil
+		}
+		data := b.Get(keyClusterVote)
+		if data == nil {
+			return nil
+		}
+		ok = true
+		if err := json.Unmarshal(data, &vote); err != nil {
+			return fmt.Errorf("unmarshalling HA cluster vote: %w", err)
+		}
+		return nil
+	})
+	return vote, ok, err
+}
+
+// handleMessage processes election and membership traffic from member from.
+func (c *Cluster) handleMessage(from string, msg *Message) {
+	switch msg.Type {
+	case dhcpv4.HAMsgVoteRequest:
+		var req VoteRequestPayload
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			c.logger.Error("failed to unmarshal vote request", "member", from, "error", err)
+			return
+		}
+		c.handleVoteRequest(from, req)
+
+	case dhcpv4.HAMsgVoteResponse:
+		var resp VoteResponsePayload
+		if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+			c.logger.Error("failed to unmarshal vote response", "member", from, "error", err)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !resp.PreVote && resp.Term > c.term {
+			c.adoptTerm(resp.Term)
+			return
+		}
+		want := c.term
+		if c.preVoting {
+			want = c.term + 1
+		}
+		if resp.Granted && resp.PreVote == c.preVoting && resp.Term == want && c.votes != nil {
+			c.votes[from] = true
+			c.countVotes()
+		}
+
+	case dhcpv4.HAMsgLeaderAnnounce:
+		var la LeaderAnnouncePayload
+		if err := json.Unmarshal(msg.Payload, &la); err != nil {
+			c.logger.Error("failed to unmarshal leader announce", "member", from, "error", err)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if la.Term < c.term {
+			return
+		}
+		c.adoptTerm(la.Term)
+		if c.leader == c.self {
+			c.logger.Error("two cluster leaders in one term", "term", la.Term, "other", la.Leader)
+			c.stepDown("another member leads term " + fmt.Sprint(la.Term))
+			return
+		}
+		c.follow(la.Leader)
+
+	case dhcpv4.HAMsgMembership:
+		var mp MembershipPayload
+		if err := json.Unmarshal(msg.Payload, &mp); err != nil {
+			c.logger.Error("failed to unmarshal membership", "member", from, "error", err)
+			return
+		}
+		c.mu.Lock()
+		stale := mp.Term < c.term
+		c.mu.Unlock()
+		if stale {
+			return
+		}
+		c.applyMembers(mp.Members)
+	}
+}
+
+// handleVoteRequest answers a (pre-)vote request from a candidate.
+func (c *Cluster) handleVoteRequest(from string, req VoteRequestPayload) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Without our own position we can't tell the candidate is current
+	dataTerm, dataSeq, err := c.dataPosition()
+	if err != nil {
+		c.logger.Warn("failed to read lease data position, refusing vote", "candidate", req.Candidate, "error", err)
+	}
+	upToDate := err == nil && (req.DataTerm > dataTerm || (req.DataTerm == dataTerm && req.DataSeq >= dataSeq))
+
+	var granted bool
+	if req.PreVote {
+		// Don't help depose a leader we can still hear
+		leaderAlive := c.leader == c.self ||
+			(c.leader != "" && time.Since(c.leaderContact) < c.timeout)
+		granted = req.Term > c.term && upToDate && !leaderAlive
+	} else {
+		c.adoptTerm(req.Term)
+		granted = req.Term == c.term && upToDate && (c.votedFor == "" || c.votedFor == req.Candidate)
+		if granted {
+			c.votedFor = req.Candidate
+			c.stateDirty = true
+			// The vote must be on disk before the candidate can count it
+			if err := c.saveVote(); err != nil {
+				c.logger.Warn("failed to save HA cluster vote, refusing it", "candidate", req.Candidate, "term", req.Term, "error", err)
+				c.votedFor = ""
+				granted = false
+			} else {
+				c.resetDeadline()
+			}
+		}
+	}
+
+	term := c.term
+	if req.PreVote {
+		term = req.Term
+	}
+	c.logger.Debug("vote request", "candidate", req.Candidate, "term", req.Term, "pre_vote", req.PreVote, "granted", granted)
+	c.sendTo(from, dhcpv4.HAMsgVoteResponse, VoteResponsePayload{
+		Term:    term,
+		Voter:   c.self,
+		Granted: granted,
+		PreVote: req.PreVote,
+	})
+}
+
+// broadcast sends a message to every connected member. Caller holds c.mu.
+func (c *Cluster) broadcast(t dhcpv4.HAMessageType, v interface{}) {
+	msg, err := newMessage(t, v)
+	if err != nil {
+		return
+	}
+	for _, m := range c.members {
+		if m.link.Connected() {
+			go m.link.sendMessage(msg)
+		}
+	}
+}
+
+// sendTo sends a message to one member. Caller holds c.mu.
+func (c *Cluster) sendTo(id string, t dhcpv4.HAMessageType, v interface{}) {
+	m := c.members[id]
+	if m == nil {
+		return
+	}
+	msg, err := newMessage(t, v)
+	if err != nil {
+		return
+	}
+	go m.link.sendMessage(msg)
+}
+
+// links returns the link to every member.
+func (c *Cluster) links() []*Peer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	links := make([]*Peer, 0, len(c.members))
+	for _, m := range c.members {
+		links = append(links, m.link)
+	}
+	return links
+}
+
+// IsLeader reports whether this node currently leads the cluster.
+func (c *Cluster) IsLeader() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader == c.self
+}
+
+// Leader returns the ID of the current leader ("" during an election).
+func (c *Cluster) Leader() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader
+}
+
+// Campaign starts an election right away (manual failover). Members still
+// only vote for this node if its lease data is at least as recent as theirs.
+func (c *Cluster) Campaign() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader == c.self {
+		return
+	}
+	c.campaign(false)
+}
+
+// QueueLeaseUpdate queues a local lease change for every member.
+func (c *Cluster) QueueLeaseUpdate(l *lease.Lease, potential time.Time) {
+	for _, link := range c.links() {
+		link.QueueLeaseUpdate(l, potential)
+	}
+}
+
+// SendConflictUpdate sends a conflict table entry to every member.
+func (c *Cluster) SendConflictUpdate(ip net.IP, detectedAt time.Time, method, responderMAC, subnet string,
+	probeCount int, permanent bool) error {
+	var firstErr error
+	for _, link := range c.links() {
+		if !link.Connected() {
+			continue
+		}
+		if err := link.SendConflictUpdate(ip, detectedAt, method, responderMAC, subnet, probeCount, permanent); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SendConfigSync sends a config section to every connected member.
+// Members that are down get the full config when they reconnect.
+func (c *Cluster) SendConfigSync(section string, data []byte) error {
+	var firstErr error
+	for _, link := range c.links() {
+		if !link.Connected() {
+			continue
+		}
+		if err := link.SendConfigSync(section, data); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WaitAck blocks until enough members to make a majority (with this node)
+// have acknowledged the queued update for ip, for at most
+// ha.sync_ack_timeout. Members that are not connected are not waited for.
+func (c *Cluster) WaitAck(ctx context.Context, ip net.IP) error {
+	c.mu.Lock()
+	need := c.quorum() - 1
+	c.mu.Unlock()
+
+	var connected []*Peer
+	for _, link := range c.links() {
+		if link.Connected() {
+			connected = append(connected, link)
+		}
+	}
+	need = min(need, len(connected))
+	if need == 0 {
+		return nil
+	}
+
+	results := make(chan error, len(connected))
+	for _, link := range connected {
+		go func() { results <- link.WaitAck(ctx, ip) }()
+	}
+	acked := 0
+	var lastErr error
+	for range connected {
+		err := <-results
+		if err == nil {
+			acked++
+			if acked >= need {
+				return nil
+			}
+			continue
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("%d of %d members acknowledged: %w", acked, need, lastErr)
+}
+
+// Members returns the member list, this node included.
+func (c *Cluster) Members() []config.HAMember {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]config.HAMember(nil), c.memberList...)
+}
+
+// SetMembers changes the cluster membership. Only the leader may do so;
+// the new list is sent to every old and new member.
+func (c *Cluster) SetMembers(members []config.HAMember) error {
+	c.mu.Lock()
+	if c.leader != c.self {
+		c.mu.Unlock()
+		return ErrNotLeader
+	}
+	check := *c.cfg
+	check.Members = members
+	if err := config.ValidateHACluster(check); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	term := c.term
+	c.mu.Unlock()
+
+	// Tell the old members before any of them is disconnected
+	msg, err := newMessage(dhcpv4.HAMsgMembership, MembershipPayload{Term: term, Members: members})
+	if err != nil {
+		return err
+	}
+	for _, link := range c.links() {
+		if link.Connected() {
+			link.sendMessage(msg)
+		}
+	}
+	// New members are started with the full list in their config
+	c.applyMembers(members)
+	return nil
+}
+
+// applyMembers switches to a new member list: links to new members are
+// started and links to removed ones stopped.
+func (c *Cluster) applyMembers(members []config.HAMember) {
+	c.mu.Lock()
+	wanted := make(map[string]config.HAMember, len(members))
+	for _, m := range members {
+		wanted[m.ID] = m
+	}
+	if _, ok := wanted[c.self]; !ok {
+		c.mu.Unlock()
+		c.logger.Error("this node was removed from the HA cluster — not applying the new member list",
+			"hint", "disable HA or remove the node from service")
+		return
+	}
+
+	var stopped []*Peer
+	for id, m := range c.members {
+		if nm, ok := wanted[id]; !ok || nm.Address != m.address {
+			stopped = append(stopped, m.link)
+			delete(c.members, id)
+		}
+	}
+	var added []string
+	for id, m := range wanted {
+		if id == c.self || c.members[id] != nil {
+			continue
+		}
+		mem, err := c.newMember(m)
+		if err != nil {
+			c.logger.Error("failed to add cluster member", "member", id, "error", err)
+			continue
+		}
+		c.members[id] = mem
+		if c.ctx != nil {
+			mem.link.startLink(c.ctx, mem.link.hello != nil)
+		}
+		added = append(added, id)
+	}
+	c.memberList = append([]config.HAMember(nil), members...)
+	c.cfg.Members = c.memberList
+	quorum := c.quorum()
+	c.mu.Unlock()
+
+	for _, l := range stopped {
+		l.Stop()
+	}
+	sort.Strings(added)
+	c.logger.Warn("HA cluster membership changed",
+		"members", len(members),
+		"quorum", quorum,
+		"added", added,
+		"removed", len(stopped))
+	if c.onMembership != nil {
+		c.onMembership(members)
+	}
+}
+
+// Status returns this node's view of the cluster.
+func (c *Cluster) Status() ClusterStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := ClusterStatus{NodeID: c.self, Term: c.term, Leader: c.leader, Quorum: c.quorum()}
+	for _, m := range c.memberList {
+		ms := MemberStatus{ID: m.ID, Address: m.Address, Leader: m.ID == c.leader}
+		if m.ID == c.self {
+			ms.Self, ms.Connected = true, true
+		} else if mem := c.members[m.ID]; mem != nil {
+			ms.Connected = mem.link.Connected()
+			ms.LastSeen = mem.lastSeen
+			ms.Queue = mem.link.QueueStatus()
+		}
+		st.Members = append(st.Members, ms)
+	}
+	return st
+}
+
+// publishMember publishes a peer up/down event for a member.
+func (c *Cluster) publishMember(t events.EventType, id, state, reason string) {
+	c.bus.Publish(events.Event{
+		Type:      t,
+		Timestamp: time.Now(),
+		HA:        &events.HAData{PeerState: state},
+		Reason:    reason,
+	})
+	c.logger.Info("cluster member "+state, "member", id)
+}
+
+// memberWatch is the linkWatcher of one member link.
+type memberWatch struct {
+	c  *Cluster
+	id string
+}
+
+// PeerUp records a heartbeat (or new connection) from the member.
+func (w *memberWatch) PeerUp() {
+	c := w.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.members[w.id]
+	if m == nil {
+		return
+	}
+	m.lastSeen = time.Now()
+	if !m.up {
+		m.up = true
+		c.publishMember(events.EventHAPeerUp, w.id, "connected", fmt.Sprintf("cluster member %s up", w.id))
+	}
+}
+
+// BulkSyncComplete is a no-op: members don't wait for a sync to serve.
+func (w *memberWatch) BulkSyncComplete() {}
+
+// PeerClaimedActive ignores two-node failover claims; the election decides.
+func (w *memberWatch) PeerClaimedActive(reason string) {
+	w.c.logger.Warn("ignoring failover claim from cluster member", "member", w.id, "reason", reason)
+}
+
+// State returns this node's HA state for heartbeats.
+func (w *memberWatch) State() dhcpv4.HAState {
+	return w.c.fsm.State()
+}
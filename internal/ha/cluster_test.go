@@ -0,0 +1,328 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testNode is one in-process cluster member.
+type testNode struct {
+	c       *Cluster
+	store   *lease.Store
+	updates chan LeaseUpdatePayload
+	ln      net.Listener
+	stopped bool
+}
+
+// newTestCluster listens on n loopback ports and returns the member list
+// and the listeners, which newTestNode takes over.
+func newTestCluster(t *testing.T, n int) ([]config.HAMember, []net.Listener) {
+	t.Helper()
+	var members []config.HAMember
+	var lns []net.Listener
+	for i := range n {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen error: %v", err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		members = append(members, config.HAMember{ID: string(rune('a' + i)), Address: ln.Addr().String()})
+		lns = append(lns, ln)
+	}
+	return members, lns
+}
+
+// newTestNode starts member id of a cluster made of members.
+func newTestNode(t *testing.T, id string, members []config.HAMember, ln net.Listener) *testNode {
+	t.Helper()
+	return newTestNodeWith(t, id, members, ln, nil)
+}
+
+// newTestNodeWith is newTestNode with a hook to adjust the config.
+func newTestNodeWith(t *testing.T, id string, members []config.HAMember, ln net.Listener, adjust func(*config.HAConfig)) *testNode {
+	t.Helper()
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), id+".db"))
+	if err != nil {
+		t.Fatalf("NewStore error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	fsm, bus := newTestFSM("member")
+	t.Cleanup(bus.Stop)
+	fsm.SetMode(config.HAModeCluster)
+	cfg := &config.HAConfig{
+		Mode:              config.HAModeCluster,
+		NodeID:            id,
+		Members:           append([]config.HAMember(nil), members...),
+		HeartbeatInterval: "50ms",
+		FailoverTimeout:   "300ms",
+	}
+	if adjust != nil {
+		adjust(cfg)
+	}
+	c, err := NewCluster(cfg, fsm, store, bus, fsm.logger)
+	if err != nil {
+		t.Fatalf("NewCluster error: %v", err)
+	}
+
+	n := &testNode{c: c, store: store, updates: make(chan LeaseUpdatePayload, 16), ln: ln}
+	c.OnLeaseUpdate(func(lu LeaseUpdatePayload) { n.updates <- lu })
+	c.start(context.Background(), ln)
+	t.Cleanup(n.stop)
+	return n
+}
+
+func (n *testNode) stop() {
+	if !n.stopped {
+		n.stopped = true
+		n.c.Stop()
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// agreedLeader returns the running node every running node follows, or nil.
+func agreedLeader(nodes []*testNode) *testNode {
+	var leader *testNode
+	for _, n := range nodes {
+		if !n.stopped && n.c.IsLeader() {
+			if leader != nil {
+				return nil
+			}
+			leader = n
+		}
+	}
+	if leader == nil {
+		return nil
+	}
+	for _, n := range nodes {
+		if !n.stopped && n.c.Leader() != leader.c.self {
+			return nil
+		}
+	}
+	return leader
+}
+
+func TestClusterElectionAndReplication(t *testing.T) {
+	members, lns := newTestCluster(t, 3)
+	var nodes []*testNode
+	for i, m := range members {
+		nodes = append(nodes, newTestNode(t, m.ID, members, lns[i]))
+	}
+
+	var leader *testNode
+	waitFor(t, "a leader", func() bool { leader = agreedLeader(nodes); return leader != nil })
+	for _, n := range nodes {
+		want := dhcpv4.HAStateStandby
+		if n == leader {
+			want = dhcpv4.HAStateActive
+		}
+		waitFor(t, n.c.self+" in "+string(want), func() bool { return n.c.FSM().State() == want })
+	}
+
+	// A lease change on the leader reaches every other member
+	seq, err := leader.store.NextSeq()
+	if err != nil {
+		t.Fatalf("NextSeq: %v", err)
+	}
+	l := &lease.Lease{
+		IP:        net.IPv4(192, 168, 1, 10),
+		MAC:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Subnet:    "192.168.1.0/24",
+		State:     dhcpv4.LeaseStateActive,
+		Expiry:    time.Now().Add(time.Hour),
+		UpdateSeq: seq,
+	}
+	leader.c.QueueLeaseUpdate(l, time.Time{})
+	for _, n := range nodes {
+		if n == leader {
+			continue
+		}
+		select {
+		case lu := <-n.updates:
+			if lu.IP != "192.168.1.10" {
+				t.Errorf("%s got update for %s", n.c.self, lu.IP)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s did not receive the lease update", n.c.self)
+		}
+	}
+	if err := leader.c.WaitAck(context.Background(), l.IP); err != nil {
+		t.Errorf("WaitAck: %v", err)
+	}
+
+	// The remaining majority elects a new leader in a later term
+	term := leader.c.Status().Term
+	leader.stop()
+	var next *testNode
+	waitFor(t, "a new leader", func() bool { next = agreedLeader(nodes); return next != nil })
+	if st := next.c.Status(); st.Term <= term {
+		t.Errorf("new leader term = %d, want > %d", st.Term, term)
+	}
+
+	// Alone, the new leader has no majority and steps down
+	for _, n := range nodes {
+		if n != next {
+			n.stop()
+		}
+	}
+	waitFor(t, "leader without quorum to step down", func() bool {
+		return !next.c.IsLeader() && next.c.FSM().State() == dhcpv4.HAStateStandby
+	})
+}
+
+func TestClusterVoteSurvivesRestart(t *testing.T) {
+	members, _ := newTestCluster(t, 3)
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "a.db"))
+	if err != nil {
+		t.Fatalf("NewStore error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	fsm, bus := newTestFSM("member")
+	t.Cleanup(bus.Stop)
+	fsm.SetMode(config.HAModeCluster)
+	cfg := &config.HAConfig{Mode: config.HAModeCluster, NodeID: "a", Members: members}
+
+	c, err := NewCluster(cfg, fsm, store, bus, fsm.logger)
+	if err != nil {
+		t.Fatalf("NewCluster error: %v", err)
+	}
+	c.handleVoteRequest("b", VoteRequestPayload{Term: 5, Candidate: "b"})
+	if c.votedFor != "b" {
+		t.Fatalf("votedFor = %q, want b", c.votedFor)
+	}
+
+	// Restart without a clean Stop: the vote was saved before the reply
+	c2, err := NewCluster(cfg, fsm, store, bus, fsm.logger)
+	if err != nil {
+		t.Fatalf("NewCluster after restart error: %v", err)
+	}
+	if c2.term != 5 || c2.votedFor != "b" {
+		t.Fatalf("restored term %d vote %q, want 5 and b", c2.term, c2.votedFor)
+	}
+	c2.handleVoteRequest("c", VoteRequestPayload{Term: 5, Candidate: "c"})
+	if c2.votedFor != "b" {
+		t.Errorf("second vote in term 5 went to %q", c2.votedFor)
+	}
+
+	// A newer term frees the vote
+	c2.handleVoteRequest("c", VoteRequestPayload{Term: 6, Candidate: "c"})
+	if c2.term != 6 || c2.votedFor != "c" {
+		t.Errorf("term %d vote %q, want 6 and c", c2.term, c2.votedFor)
+	}
+}
+
+func TestClusterMembership(t *testing.T) {
+	members, lns := newTestCluster(t, 4)
+	var nodes []*testNode
+	for i, m := range members[:3] {
+		nodes = append(nodes, newTestNode(t, m.ID, members[:3], lns[i]))
+	}
+
+	var leader *testNode
+	waitFor(t, "a leader", func() bool { leader = agreedLeader(nodes); return leader != nil })
+	for _, n := range nodes {
+		if n != leader {
+			if err := n.c.SetMembers(members); !errors.Is(err, ErrNotLeader) {
+				t.Errorf("SetMembers on follower %s = %v, want ErrNotLeader", n.c.self, err)
+			}
+			break
+		}
+	}
+	if err := leader.c.SetMembers(members[:2]); err == nil {
+		t.Error("SetMembers accepted a two-member cluster")
+	}
+
+	// Add a fourth member, started with the full list
+	var written [][]config.HAMember
+	leader.c.OnMembership(func(m []config.HAMember) { written = append(written, m) })
+	d := newTestNode(t, "d", members, lns[3])
+	nodes = append(nodes, d)
+	if err := leader.c.SetMembers(members); err != nil {
+		t.Fatalf("SetMembers: %v", err)
+	}
+	if len(written) != 1 || len(written[0]) != 4 {
+		t.Errorf("OnMembership calls = %v, want one with 4 members", written)
+	}
+	for _, n := range nodes {
+		waitFor(t, n.c.self+" to see 4 members", func() bool {
+			st := n.c.Status()
+			return len(st.Members) == 4 && st.Quorum == 3
+		})
+	}
+	waitFor(t, "d to follow the leader", func() bool { return d.c.Leader() == leader.c.self })
+
+	// Remove it again
+	if err := leader.c.SetMembers(members[:3]); err != nil {
+		t.Fatalf("SetMembers: %v", err)
+	}
+	for _, n := range nodes[:3] {
+		if got := len(n.c.Members()); got != 3 {
+			waitFor(t, n.c.self+" to see 3 members", func() bool { return len(n.c.Members()) == 3 })
+		}
+	}
+}
+
+func TestClusterAuthAndTLS(t *testing.T) {
+	members, lns := newTestCluster(t, 3)
+	ca := newTestCA(t)
+	tlsCfgs := make(map[string]config.HATLSConfig)
+	for i := range members {
+		members[i].TLSName = "node-" + members[i].ID
+		tlsCfgs[members[i].ID] = ca.issue(t, t.TempDir(), members[i].TLSName, false)
+	}
+	var nodes []*testNode
+	for i, m := range members {
+		nodes = append(nodes, newTestNodeWith(t, m.ID, members, lns[i], func(cfg *config.HAConfig) {
+			cfg.AuthKey = testAuthKey
+			cfg.TLS = tlsCfgs[m.ID]
+		}))
+	}
+	var leader *testNode
+	waitFor(t, "a leader", func() bool { leader = agreedLeader(nodes); return leader != nil })
+
+	// A member's certificate without the key can't get a frame accepted
+	certs := loadTestCerts(t, tlsCfgs["b"])
+	conn, err := net.Dial("tcp", members[0].Address)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	tc, err := certs.clientHandshake(conn, "node-a")
+	if err != nil {
+		t.Fatalf("TLS handshake: %v", err)
+	}
+	defer tc.Close()
+	unsigned := metrics.HAAuthFailures.WithLabelValues("unsigned")
+	before := testutil.ToFloat64(unsigned)
+	hello, _ := newMessage(dhcpv4.HAMsgHello, HelloPayload{Node: "b"})
+	data, _ := EncodeMessage(hello)
+	tc.Write(data)
+	tc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := tc.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("unsigned hello: read = %v, want the connection closed", err)
+	}
+	if got := testutil.ToFloat64(unsigned) - before; got != 1 {
+		t.Errorf("unsigned frame failures = %v, want 1", got)
+	}
+}
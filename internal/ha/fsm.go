@@ -1,26 +1,35 @@
 package ha
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
 // FSM implements the failover state machine with explicit states:
-// PARTNER_UP, PARTNER_DOWN, ACTIVE, STANDBY, RECOVERY.
+// PARTNER_UP, PARTNER_DOWN, ACTIVE, STANDBY, RECOVERY, LOAD_BALANCING in
+// load-balancing mode, and COMMUNICATIONS_INTERRUPTED when a split-brain
+// check is configured.
 type FSM struct {
 	state           dhcpv4.HAState
-	role            string // "primary" or "secondary"
+	role            string // "primary" or "secondary" ("member" in cluster mode)
+	mode            string // config.HAModeActiveStandby, HAModeLoadBalancing or HAModeCluster
 	lastHeartbeat   time.Time
+	partnerDownAt   time.Time
+	startedAt       time.Time
 	failoverTimeout time.Duration
 	bus             *events.Bus
 	logger          *slog.Logger
 	mu              sync.RWMutex
 	onStateChange   func(old, new dhcpv4.HAState)
+	arbiter         Arbiter
+	arbitratedAt    time.Time
 }
 
 // NewFSM creates a new failover state machine.
@@ -33,6 +42,8 @@ func NewFSM(role string, failoverTimeout time.Duration, bus *events.Bus, logger
 	fsm := &FSM{
 		state:           initialState,
 		role:            role,
+		mode:            config.HAModeActiveStandby,
+		startedAt:       time.Now(),
 		failoverTimeout: failoverTimeout,
 		bus:             bus,
 		logger:          logger,
@@ -46,6 +57,72 @@ func NewFSM(role string, failoverTimeout time.Duration, bus *events.Bus, logger
 	return fsm
 }
 
+// SetMode switches the FSM to the given HA mode. Call before the peer
+// starts. In load-balancing mode both nodes start in RECOVERY and serve
+// only after the first bulk lease sync with the peer (or once the peer has
+// not shown up within the failover timeout). In cluster mode every node
+// starts in STANDBY and the Cluster election decides which one serves.
+func (f *FSM) SetMode(mode string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mode = mode
+	switch mode {
+	case config.HAModeLoadBalancing:
+		f.state = dhcpv4.HAStateRecovery
+		f.logger.Info("HA FSM in load-balancing mode",
+			"role", f.role,
+			"initial_state", string(f.state))
+	case config.HAModeCluster:
+		f.state = dhcpv4.HAStateStandby
+		f.logger.Info("HA FSM in cluster mode",
+			"initial_state", string(f.state))
+	}
+}
+
+// SetArbiter installs the split-brain check consulted before this node
+// takes over from a silent peer. Call before the peer starts.
+func (f *FSM) SetArbiter(a Arbiter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.arbiter = a
+}
+
+// MayTakeOver consults the arbiter, if any, on whether this node may serve
+// alone. Without an arbiter the answer is always yes.
+func (f *FSM) MayTakeOver(ctx context.Context) (bool, string) {
+	f.mu.Lock()
+	a := f.arbiter
+	f.arbitratedAt = time.Now()
+	f.mu.Unlock()
+	if a == nil {
+		return true, "no split-brain check configured"
+	}
+	return a.MayTakeOver(ctx)
+}
+
+// Mode returns the configured HA mode.
+func (f *FSM) Mode() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.mode
+}
+
+// loadBalancing reports whether the FSM runs in load-balancing mode.
+func (f *FSM) loadBalancing() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.mode == config.HAModeLoadBalancing
+}
+
+// IsServing reports whether a node in state s answers DHCP clients.
+func IsServing(s dhcpv4.HAState) bool {
+	switch s {
+	case dhcpv4.HAStateActive, dhcpv4.HAStatePartnerDown, dhcpv4.HAStateLoadBalancing, dhcpv4.HAStateCommInterrupted:
+		return true
+	}
+	return false
+}
+
 // State returns the current HA state.
 func (f *FSM) State() dhcpv4.HAState {
 	f.mu.RLock()
@@ -64,7 +141,48 @@ func (f *FSM) Role() string {
 func (f *FSM) IsActive() bool {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return f.state == dhcpv4.HAStateActive || f.state == dhcpv4.HAStatePartnerDown
+	return IsServing(f.state)
+}
+
+// RenewalsOnly reports whether the node may only extend existing bindings.
+// In active-standby mode both nodes draw from the same pools, so while
+// communications are interrupted neither hands out new addresses. In
+// load-balancing mode each keeps using its own half.
+func (f *FSM) RenewalsOnly() bool {
+	return f.State() == dhcpv4.HAStateCommInterrupted && !f.loadBalancing()
+}
+
+// HoldsVIP reports whether a node in state s should hold the virtual IPs.
+// In load-balancing mode both nodes serve, so the VIPs stay with the
+// primary until one of them runs alone in PARTNER_DOWN. While
+// communications are interrupted they also stay with the primary.
+func (f *FSM) HoldsVIP(s dhcpv4.HAState) bool {
+	if s == dhcpv4.HAStateCommInterrupted {
+		return f.Role() == "primary"
+	}
+	if !f.loadBalancing() {
+		return IsServing(s)
+	}
+	return s == dhcpv4.HAStatePartnerDown || (s == dhcpv4.HAStateLoadBalancing && f.Role() == "primary")
+}
+
+// IsConfigSource reports whether this node pushes its config to the peer
+// when an adjacency forms: the active node, or in load-balancing mode the
+// primary (or whichever node ran alone in PARTNER_DOWN). Both nodes serve
+// while communications are interrupted, so the primary wins then too.
+func (f *FSM) IsConfigSource() bool {
+	if !f.loadBalancing() && f.State() != dhcpv4.HAStateCommInterrupted {
+		return f.IsActive()
+	}
+	return f.Role() == "primary" || f.State() == dhcpv4.HAStatePartnerDown
+}
+
+// PartnerDownSince returns when the FSM entered PARTNER_DOWN, or the zero
+// time when it is in any other state.
+func (f *FSM) PartnerDownSince() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.partnerDownAt
 }
 
 // LastHeartbeat returns when the last heartbeat was received from the peer.
@@ -90,11 +208,16 @@ func (f *FSM) transition(newState dhcpv4.HAState, reason string) {
 		return
 	}
 	f.state = newState
+	if newState == dhcpv4.HAStatePartnerDown {
+		f.partnerDownAt = time.Now()
+	} else {
+		f.partnerDownAt = time.Time{}
+	}
 	cb := f.onStateChange
 	f.mu.Unlock()
 
-	isNowActive := newState == dhcpv4.HAStateActive || newState == dhcpv4.HAStatePartnerDown
-	wasActive := oldState == dhcpv4.HAStateActive || oldState == dhcpv4.HAStatePartnerDown
+	isNowActive := IsServing(newState)
+	wasActive := IsServing(oldState)
 
 	f.logger.Warn("HA state transition",
 		"old_state", string(oldState),
@@ -134,7 +257,7 @@ func (f *FSM) PeerUp() {
 	f.mu.Unlock()
 
 	// Publish peer_up event on first heartbeat or after being down
-	if wasZero || currentState == dhcpv4.HAStatePartnerDown {
+	if wasZero || currentState == dhcpv4.HAStatePartnerDown || currentState == dhcpv4.HAStateCommInterrupted {
 		f.bus.Publish(events.Event{
 			Type:      events.EventHAPeerUp,
 			Timestamp: time.Now(),
@@ -151,11 +274,19 @@ func (f *FSM) PeerUp() {
 			"downtime", time.Since(prevHB).Round(time.Millisecond).String(),
 			"current_state", string(currentState))
 		f.transition(dhcpv4.HAStateRecovery, "peer reconnected")
+	case dhcpv4.HAStateCommInterrupted:
+		f.logger.Warn("communications with peer restored",
+			"interrupted_for", time.Since(prevHB).Round(time.Millisecond).String())
+		f.transition(dhcpv4.HAStateRecovery, "peer communications restored")
 	case dhcpv4.HAStateRecovery:
 		// Stay in recovery until bulk sync completes
-	case dhcpv4.HAStateActive:
-		// Primary stays ACTIVE when peer connects — just update heartbeat timestamp
+	case dhcpv4.HAStateActive, dhcpv4.HAStateLoadBalancing:
+		// Already serving alongside the peer — just update heartbeat timestamp
 	case dhcpv4.HAStateStandby:
+		if f.loadBalancing() {
+			f.transition(dhcpv4.HAStateRecovery, "peer heartbeat received — waiting for lease sync")
+			return
+		}
 		f.transition(dhcpv4.HAStatePartnerUp, "peer heartbeat received")
 	}
 }
@@ -182,6 +313,23 @@ func (f *FSM) PeerDown() {
 		"last_heartbeat_ago", time.Since(lastHB).Round(time.Millisecond).String(),
 		"failover_timeout", f.failoverTimeout.String())
 
+	// A silent peer may just mean a broken sync link — if the split-brain
+	// check can't confirm it is gone, keep to what is safe with both up
+	if ok, why := f.MayTakeOver(context.Background()); !ok {
+		f.transition(dhcpv4.HAStateCommInterrupted, "peer heartbeat timeout, takeover refused: "+why)
+		return
+	}
+
+	if f.loadBalancing() {
+		// Either node takes over all clients; the peer's free addresses are
+		// only used after the safety period (see LoadBalancer.PoolShare).
+		switch currentState {
+		case dhcpv4.HAStateLoadBalancing, dhcpv4.HAStateRecovery, dhcpv4.HAStateStandby:
+			f.transition(dhcpv4.HAStatePartnerDown, "peer heartbeat timeout while load balancing")
+		}
+		return
+	}
+
 	switch currentState {
 	case dhcpv4.HAStateActive:
 		// Primary was active, peer went away — move to PARTNER_DOWN (still serving)
@@ -218,6 +366,10 @@ func (f *FSM) BulkSyncComplete() {
 	})
 
 	if currentState == dhcpv4.HAStateRecovery {
+		if f.loadBalancing() {
+			f.transition(dhcpv4.HAStateLoadBalancing, "bulk sync complete — load balancing with peer")
+			return
+		}
 		if f.role == "primary" {
 			f.transition(dhcpv4.HAStateActive, "bulk sync complete — primary resuming active")
 		} else {
@@ -226,12 +378,24 @@ func (f *FSM) BulkSyncComplete() {
 	}
 }
 
+// PeerClaimedActive is called when the peer announces it took over; this
+// node steps back to standby.
+func (f *FSM) PeerClaimedActive(reason string) {
+	f.transition(dhcpv4.HAStateStandby, "peer claimed active: "+reason)
+}
+
 // ClaimActive forces this node to the ACTIVE state (manual failover or admin action).
+// In load-balancing mode it declares the partner down instead, so this node
+// serves every client and the safety period starts.
 func (f *FSM) ClaimActive(reason string) {
 	f.logger.Warn("manual failover requested",
 		"role", f.role,
 		"current_state", string(f.State()),
 		"reason", reason)
+	if f.loadBalancing() {
+		f.transition(dhcpv4.HAStatePartnerDown, fmt.Sprintf("manual claim: %s", reason))
+		return
+	}
 	f.transition(dhcpv4.HAStateActive, fmt.Sprintf("manual claim: %s", reason))
 }
 
@@ -243,8 +407,25 @@ func (f *FSM) CheckHeartbeatTimeout() {
 	currentState := f.state
 	f.mu.RUnlock()
 
+	if currentState == dhcpv4.HAStateCommInterrupted {
+		f.recheckTakeover()
+		return
+	}
+
 	if lastHB.IsZero() {
-		return // No heartbeat received yet
+		// No heartbeat received yet. A load-balancing node whose peer never
+		// shows up would otherwise wait in RECOVERY forever.
+		f.mu.RLock()
+		waited := time.Since(f.startedAt)
+		f.mu.RUnlock()
+		if f.loadBalancing() && currentState == dhcpv4.HAStateRecovery && waited > f.failoverTimeout {
+			if ok, why := f.MayTakeOver(context.Background()); !ok {
+				f.transition(dhcpv4.HAStateCommInterrupted, "peer not seen since startup, takeover refused: "+why)
+				return
+			}
+			f.transition(dhcpv4.HAStatePartnerDown, "peer not seen since startup")
+		}
+		return
 	}
 
 	silence := time.Since(lastHB)
@@ -257,6 +438,24 @@ func (f *FSM) CheckHeartbeatTimeout() {
 	}
 }
 
+// recheckTakeover repeats the split-brain check once per failover timeout
+// while communications are interrupted, and moves to PARTNER_DOWN once it
+// confirms the peer is gone.
+func (f *FSM) recheckTakeover() {
+	f.mu.RLock()
+	last := f.arbitratedAt
+	f.mu.RUnlock()
+	if time.Since(last) < f.failoverTimeout {
+		return
+	}
+
+	ok, why := f.MayTakeOver(context.Background())
+	if !ok || f.State() != dhcpv4.HAStateCommInterrupted {
+		return
+	}
+	f.transition(dhcpv4.HAStatePartnerDown, "split-brain check confirmed peer down: "+why)
+}
+
 // String returns a human-readable state description.
 func (f *FSM) String() string {
 	f.mu.RLock()
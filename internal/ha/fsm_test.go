@@ -1,11 +1,13 @@
 package ha
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
@@ -202,3 +204,123 @@ func TestFSMString(t *testing.T) {
 		t.Error("String() should not be empty")
 	}
 }
+
+func TestFSMLoadBalancing(t *testing.T) {
+	fsm, bus := newTestFSM("secondary")
+	defer bus.Stop()
+	fsm.SetMode(config.HAModeLoadBalancing)
+
+	if fsm.State() != dhcpv4.HAStateRecovery || fsm.IsActive() {
+		t.Fatalf("load-balancing initial state = %s, want RECOVERY (not serving)", fsm.State())
+	}
+
+	fsm.PeerUp()
+	fsm.BulkSyncComplete()
+	if fsm.State() != dhcpv4.HAStateLoadBalancing || !fsm.IsActive() {
+		t.Errorf("state after bulk sync = %s, want LOAD_BALANCING (serving)", fsm.State())
+	}
+	if fsm.HoldsVIP(fsm.State()) {
+		t.Error("secondary should not hold VIPs while load balancing")
+	}
+
+	fsm.PeerDown()
+	if fsm.State() != dhcpv4.HAStatePartnerDown || fsm.PartnerDownSince().IsZero() {
+		t.Errorf("state after PeerDown = %s, want PARTNER_DOWN with timestamp", fsm.State())
+	}
+	if !fsm.HoldsVIP(fsm.State()) {
+		t.Error("survivor in PARTNER_DOWN should hold VIPs")
+	}
+
+	fsm.PeerUp()
+	if fsm.State() != dhcpv4.HAStateRecovery || !fsm.PartnerDownSince().IsZero() {
+		t.Errorf("state after peer return = %s, want RECOVERY", fsm.State())
+	}
+}
+
+func TestFSMLoadBalancingPeerNeverSeen(t *testing.T) {
+	fsm, bus := newTestFSM("primary")
+	defer bus.Stop()
+	fsm.SetMode(config.HAModeLoadBalancing)
+	fsm.failoverTimeout = 20 * time.Millisecond
+
+	fsm.CheckHeartbeatTimeout()
+	if fsm.State() != dhcpv4.HAStateRecovery {
+		t.Errorf("state before timeout = %s, want RECOVERY", fsm.State())
+	}
+	time.Sleep(40 * time.Millisecond)
+	fsm.CheckHeartbeatTimeout()
+	if fsm.State() != dhcpv4.HAStatePartnerDown {
+		t.Errorf("state after timeout = %s, want PARTNER_DOWN", fsm.State())
+	}
+}
+
+// testArbiter answers every split-brain check with the same verdict.
+type testArbiter struct{ allow bool }
+
+func (a *testArbiter) MayTakeOver(context.Context) (bool, string) {
+	return a.allow, "test verdict"
+}
+
+func TestFSMSplitBrainGuard(t *testing.T) {
+	fsm, bus := newTestFSM("primary")
+	defer bus.Stop()
+	arb := &testArbiter{}
+	fsm.SetArbiter(arb)
+
+	fsm.PeerUp()
+	fsm.PeerDown()
+	if fsm.State() != dhcpv4.HAStateCommInterrupted {
+		t.Fatalf("state after refused takeover = %s, want COMMUNICATIONS_INTERRUPTED", fsm.State())
+	}
+	if !fsm.IsActive() || !fsm.RenewalsOnly() {
+		t.Error("COMMUNICATIONS_INTERRUPTED should serve renewals only")
+	}
+	if !fsm.HoldsVIP(fsm.State()) {
+		t.Error("primary should keep the VIPs while communications are interrupted")
+	}
+
+	// The check is repeated once per failover timeout, not every tick
+	arb.allow = true
+	fsm.CheckHeartbeatTimeout()
+	if fsm.State() != dhcpv4.HAStateCommInterrupted {
+		t.Errorf("state = %s, check repeated before the failover timeout", fsm.State())
+	}
+	fsm.mu.Lock()
+	fsm.arbitratedAt = time.Time{}
+	fsm.mu.Unlock()
+	fsm.CheckHeartbeatTimeout()
+	if fsm.State() != dhcpv4.HAStatePartnerDown || fsm.RenewalsOnly() {
+		t.Errorf("state after confirmed takeover = %s, want PARTNER_DOWN", fsm.State())
+	}
+
+	fsm.PeerUp()
+	if fsm.State() != dhcpv4.HAStateRecovery {
+		t.Errorf("state after peer return = %s, want RECOVERY", fsm.State())
+	}
+}
+
+func TestFSMSplitBrainGuardLoadBalancing(t *testing.T) {
+	fsm, bus := newTestFSM("secondary")
+	defer bus.Stop()
+	fsm.SetMode(config.HAModeLoadBalancing)
+	fsm.SetArbiter(&testArbiter{})
+
+	fsm.PeerUp()
+	fsm.BulkSyncComplete()
+	fsm.PeerDown()
+	if fsm.State() != dhcpv4.HAStateCommInterrupted {
+		t.Fatalf("state after refused takeover = %s, want COMMUNICATIONS_INTERRUPTED", fsm.State())
+	}
+	// Pools are already split, so each node keeps allocating from its half
+	if fsm.RenewalsOnly() {
+		t.Error("load-balancing node should not be limited to renewals")
+	}
+	if fsm.HoldsVIP(fsm.State()) {
+		t.Error("secondary should not take the VIPs while communications are interrupted")
+	}
+
+	fsm.PeerUp()
+	if fsm.State() != dhcpv4.HAStateRecovery {
+		t.Errorf("state after peer return = %s, want RECOVERY", fsm.State())
+	}
+}
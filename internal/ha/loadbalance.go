@@ -0,0 +1,173 @@
+package ha
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// loadbMxTbl is the RFC 3074 §6 mixing table for the Pearson hash.
+var loadbMxTbl = [256]byte{
+	251, 175, 119, 215, 81, 14, 79, 191, 103, 49, 181, 143, 186, 157, 0,
+	232, 31, 32, 55, 60, 152, 58, 17, 237, 174, 70, 160, 144, 220, 90, 57,
+	223, 59, 3, 18, 140, 111, 166, 203, 196, 134, 243, 124, 95, 222, 179,
+	197, 65, 180, 48, 36, 15, 107, 46, 233, 130, 165, 30, 123, 161, 209, 23,
+	97, 16, 40, 91, 219, 61, 100, 10, 210, 109, 250, 127, 22, 138, 29, 108,
+	244, 67, 207, 9, 178, 204, 74, 98, 126, 249, 167, 116, 34, 77, 193,
+	200, 121, 5, 20, 113, 71, 35, 128, 13, 182, 94, 25, 226, 227, 199, 75,
+	27, 41, 245, 230, 224, 43, 225, 177, 26, 155, 150, 212, 142, 218, 115,
+	241, 73, 88, 105, 39, 114, 62, 255, 192, 201, 145, 214, 168, 158, 221,
+	148, 154, 122, 12, 84, 82, 163, 44, 139, 228, 236, 205, 242, 217, 11,
+	187, 146, 159, 64, 86, 239, 195, 42, 106, 198, 118, 112, 184, 172, 87,
+	2, 173, 117, 176, 229, 247, 253, 137, 185, 99, 164, 102, 147, 45, 66,
+	231, 52, 141, 211, 194, 206, 246, 238, 56, 110, 78, 248, 63, 240, 189,
+	93, 92, 51, 53, 183, 19, 171, 72, 50, 33, 104, 101, 69, 8, 252, 83, 120,
+	76, 135, 85, 54, 202, 125, 188, 213, 96, 235, 136, 208, 162, 129, 190,
+	132, 156, 38, 47, 1, 7, 254, 24, 4, 216, 131, 89, 21, 28, 133, 37, 153,
+	149, 80, 170, 68, 6, 169, 234, 151,
+}
+
+// ClientBucket returns the RFC 3074 hash bucket (0–255) for a client key —
+// the client identifier if the client sent one, otherwise chaddr.
+func ClientBucket(key []byte) uint8 {
+	hash := byte(len(key))
+	for i := len(key); i > 0; {
+		i--
+		hash = loadbMxTbl[hash^key[i]]
+	}
+	return hash
+}
+
+// LoadBalancer splits clients and pool space between two serving peers, in
+// the style of the ISC failover draft. The primary answers hash buckets
+// 0–127 and allocates from the first half of every pool, the secondary
+// takes the rest. Lease times are capped at the MCLT beyond what the peer
+// has acknowledged, so a peer that disappears can safely take over the
+// other half once the safety period has passed in PARTNER_DOWN.
+type LoadBalancer struct {
+	fsm    *FSM
+	role   string
+	mclt   time.Duration
+	safety time.Duration
+
+	mu     sync.Mutex
+	acked  map[string]time.Time     // IP → expiry the peer knows about
+	wanted map[string]time.Duration // IP → lease time before the MCLT cap
+}
+
+// NewLoadBalancer creates a load balancer for the given HA config.
+func NewLoadBalancer(cfg *config.HAConfig, fsm *FSM) *LoadBalancer {
+	mclt, err := time.ParseDuration(cfg.MCLT)
+	if err != nil || mclt <= 0 {
+		mclt = config.DefaultHAMCLT
+	}
+	safety, err := time.ParseDuration(cfg.SafetyPeriod)
+	if err != nil || safety < 0 {
+		safety = mclt
+	}
+	return &LoadBalancer{
+		fsm:    fsm,
+		role:   cfg.Role,
+		mclt:   mclt,
+		safety: safety,
+		acked:  make(map[string]time.Time),
+		wanted: make(map[string]time.Duration),
+	}
+}
+
+// MCLT returns the maximum client lead time.
+func (lb *LoadBalancer) MCLT() time.Duration {
+	return lb.mclt
+}
+
+// InScope reports whether this node should answer a client. While both
+// peers are up each answers its own buckets; in PARTNER_DOWN the survivor
+// answers everyone.
+func (lb *LoadBalancer) InScope(key []byte) bool {
+	switch lb.fsm.State() {
+	case dhcpv4.HAStateLoadBalancing:
+		primary := ClientBucket(key) < 128
+		return primary == (lb.role == "primary")
+	case dhcpv4.HAStatePartnerDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// PoolShare returns which slice of each pool new addresses come from, as
+// (index, count). After the safety period in PARTNER_DOWN the peer's free
+// addresses are reclaimed and the whole pool is used.
+func (lb *LoadBalancer) PoolShare() (index, count int) {
+	if lb.Reclaimed() {
+		return 0, 1
+	}
+	if lb.role == "primary" {
+		return 0, 2
+	}
+	return 1, 2
+}
+
+// Reclaimed reports whether the safety period in PARTNER_DOWN has passed
+// and this node may allocate from the peer's share of the pools.
+func (lb *LoadBalancer) Reclaimed() bool {
+	since := lb.fsm.PartnerDownSince()
+	return !since.IsZero() && time.Since(since) >= lb.safety
+}
+
+// LeaseTime caps a lease at the MCLT beyond the expiry the peer has
+// acknowledged for ip. A binding the peer has never seen gets at most the
+// MCLT; once the peer acknowledges the full lease, renewals get it all.
+func (lb *LoadBalancer) LeaseTime(ip net.IP, desired time.Duration) time.Duration {
+	key := ip.String()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.wanted[key] = desired
+	limit := lb.mclt
+	if known := time.Until(lb.acked[key]); known > 0 {
+		limit += known
+	}
+	if desired > limit {
+		return limit
+	}
+	return desired
+}
+
+// PotentialExpiry returns the expiry to ask the peer to acknowledge for a
+// binding granted at start: the uncapped lease time, so the next renewal
+// can be given in full.
+func (lb *LoadBalancer) PotentialExpiry(ip net.IP, start time.Time) time.Time {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if d, ok := lb.wanted[ip.String()]; ok {
+		return start.Add(d)
+	}
+	return time.Time{}
+}
+
+// Acknowledge records that the peer knows about a binding for ip lasting
+// until expiry — either it acknowledged our update or it sent us its own.
+func (lb *LoadBalancer) Acknowledge(ip net.IP, expiry time.Time) {
+	key := ip.String()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if expiry.After(lb.acked[key]) {
+		lb.acked[key] = expiry
+	}
+}
+
+// Forget drops the MCLT state for an address whose binding has ended.
+func (lb *LoadBalancer) Forget(ip net.IP) {
+	key := ip.String()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	delete(lb.acked, key)
+	delete(lb.wanted, key)
+}
@@ -0,0 +1,120 @@
+package ha
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+func newTestLoadBalancer(role string) (*LoadBalancer, *FSM, func()) {
+	fsm, bus := newTestFSM(role)
+	fsm.SetMode(config.HAModeLoadBalancing)
+	lb := NewLoadBalancer(&config.HAConfig{Role: role, MCLT: "1h", SafetyPeriod: "1h"}, fsm)
+	return lb, fsm, bus.Stop
+}
+
+func TestClientBucket(t *testing.T) {
+	mac := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	if ClientBucket(mac) != ClientBucket(append([]byte(nil), mac...)) {
+		t.Error("ClientBucket is not deterministic")
+	}
+
+	// The hash should spread clients roughly evenly over the two halves
+	low := 0
+	for i := 0; i < 1000; i++ {
+		key := []byte{0x02, 0x00, 0x00, 0x00, byte(i >> 8), byte(i)}
+		if ClientBucket(key) < 128 {
+			low++
+		}
+	}
+	if low < 400 || low > 600 {
+		t.Errorf("%d of 1000 clients hashed to the primary, want roughly half", low)
+	}
+}
+
+func TestLoadBalancerInScope(t *testing.T) {
+	primary, pfsm, stop := newTestLoadBalancer("primary")
+	defer stop()
+	secondary, sfsm, stop2 := newTestLoadBalancer("secondary")
+	defer stop2()
+
+	key := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	if primary.InScope(key) || secondary.InScope(key) {
+		t.Error("no client should be in scope during RECOVERY")
+	}
+
+	pfsm.BulkSyncComplete()
+	sfsm.BulkSyncComplete()
+	if primary.InScope(key) == secondary.InScope(key) {
+		t.Error("exactly one peer should answer a client while load balancing")
+	}
+
+	sfsm.PeerDown()
+	if !secondary.InScope(key) {
+		t.Error("survivor in PARTNER_DOWN should answer every client")
+	}
+}
+
+func TestLoadBalancerLeaseTime(t *testing.T) {
+	lb, _, stop := newTestLoadBalancer("primary")
+	defer stop()
+
+	ip := net.IPv4(192, 168, 1, 10)
+	if got := lb.LeaseTime(ip, 8*time.Hour); got != time.Hour {
+		t.Errorf("first lease = %s, want MCLT 1h", got)
+	}
+	if got := lb.LeaseTime(ip, 30*time.Minute); got != 30*time.Minute {
+		t.Errorf("short lease = %s, want 30m uncapped", got)
+	}
+
+	// Peer acknowledges the full 8h potential expiry — renewals get it all
+	start := time.Now()
+	lb.LeaseTime(ip, 8*time.Hour)
+	potential := lb.PotentialExpiry(ip, start)
+	if want := start.Add(8 * time.Hour); !potential.Equal(want) {
+		t.Errorf("PotentialExpiry = %s, want %s", potential, want)
+	}
+	lb.Acknowledge(ip, potential)
+	if got := lb.LeaseTime(ip, 8*time.Hour); got != 8*time.Hour {
+		t.Errorf("lease after ack = %s, want 8h", got)
+	}
+
+	// An older ack never shortens what the peer already knows
+	lb.Acknowledge(ip, start)
+	if got := lb.LeaseTime(ip, 8*time.Hour); got != 8*time.Hour {
+		t.Errorf("lease after stale ack = %s, want 8h", got)
+	}
+
+	lb.Forget(ip)
+	if got := lb.LeaseTime(ip, 8*time.Hour); got != time.Hour {
+		t.Errorf("lease after Forget = %s, want MCLT 1h", got)
+	}
+}
+
+func TestLoadBalancerPoolShare(t *testing.T) {
+	lb, fsm, stop := newTestLoadBalancer("secondary")
+	defer stop()
+
+	if i, n := lb.PoolShare(); i != 1 || n != 2 {
+		t.Errorf("secondary share = %d/%d, want 1/2", i, n)
+	}
+
+	fsm.PeerDown()
+	if fsm.State() != dhcpv4.HAStatePartnerDown {
+		t.Fatalf("state = %s, want PARTNER_DOWN", fsm.State())
+	}
+	if lb.Reclaimed() {
+		t.Error("peer's addresses reclaimed before the safety period")
+	}
+
+	lb.safety = 0
+	if !lb.Reclaimed() {
+		t.Error("peer's addresses not reclaimed after the safety period")
+	}
+	if i, n := lb.PoolShare(); i != 0 || n != 1 {
+		t.Errorf("share after reclaim = %d/%d, want whole pool", i, n)
+	}
+}
@@ -0,0 +1,289 @@
+package ha
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketOutbox holds one sub-bucket of unacknowledged lease updates per peer.
+var bucketOutbox = []byte("ha_outbox")
+
+// outboxRecord is a queued lease update as stored in BoltDB.
+type outboxRecord struct {
+	Update   LeaseUpdatePayload `json:"update"`
+	QueuedAt int64              `json:"queued_at"` // unix nanoseconds
+}
+
+// errUpdateEvicted is returned to an ack waiter whose update was pushed out
+// of a full queue. The peer never saw it, so it must not count as acked.
+var errUpdateEvicted = errors.New("lease update evicted from full HA queue")
+
+// ackWaiter is how callers wait for a binding to leave the queue. err is set
+// before done is closed: nil when the peer acknowledged, errUpdateEvicted
+// when the update was dropped instead.
+type ackWaiter struct {
+	done chan struct{}
+	err  error
+}
+
+func newAckWaiter() *ackWaiter {
+	return &ackWaiter{done: make(chan struct{})}
+}
+
+func (w *ackWaiter) finish(err error) {
+	w.err = err
+	close(w.done)
+}
+
+// pendingUpdate tracks the queued update for one binding.
+type pendingUpdate struct {
+	id     uint64
+	queued time.Time  // when the binding first had an unacknowledged update
+	sent   time.Time  // zero until sent on the current connection
+	wait   *ackWaiter // finished once the binding leaves the queue
+}
+
+// QueueStatus describes the lease updates waiting for the peer.
+type QueueStatus struct {
+	Queued        int           // not yet sent on the current connection
+	Unacked       int           // sent, waiting for the peer's acknowledgement
+	OldestUnacked time.Duration // age of the oldest update not yet acknowledged
+}
+
+// outbox is a bounded, durable queue of lease updates for the peer. It
+// keeps the latest unacknowledged update per binding: a newer change to
+// the same address replaces the queued one. Entries are removed when the
+// peer acknowledges them and survive restarts, so nothing is lost when the
+// connection or the process goes away.
+type outbox struct {
+	db     boltdb.DB
+	name   []byte
+	limit  int
+	mu     sync.Mutex
+	byIP   map[string]*pendingUpdate
+	sentTo uint64        // highest id sent on the current connection
+	gen    uint64        // bumped per connection so stale sends are not counted
+	kick   chan struct{} // wakes the sender
+}
+
+// outboxKey encodes an entry id as a sortable bucket key.
+func outboxKey(id uint64) []byte {
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], id)
+	return k[:]
+}
+
+// openOutbox opens (or creates) the queue named name and restores the
+// entries left over from the last run.
+func openOutbox(db boltdb.DB, name string, limit int) (*outbox, error) {
+	o := &outbox{
+		db:    db,
+		name:  []byte(name),
+		limit: limit,
+		byIP:  make(map[string]*pendingUpdate),
+		kick:  make(chan struct{}, 1),
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(bucketOutbox)
+		if err != nil {
+			return fmt.Errorf("creating HA outbox bucket: %w", err)
+		}
+		b, err := root.CreateBucketIfNotExists(o.name)
+		if err != nil {
+			return fmt.Errorf("creating HA outbox for %s: %w", name, err)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rec outboxRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("unmarshalling HA outbox entry: %w", err)
+			}
+			o.byIP[rec.Update.IP] = &pendingUpdate{
+				id:     binary.BigEndian.Uint64(k),
+				queued: time.Unix(0, rec.QueuedAt),
+				wait:   newAckWaiter(),
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// bucket returns this queue's bucket inside tx.
+func (o *outbox) bucket(tx *bolt.Tx) *bolt.Bucket {
+	return tx.Bucket(bucketOutbox).Bucket(o.name)
+}
+
+// put queues lu, replacing any queued update for the same address. When
+// the queue is full the oldest entry is evicted and returned so the
+// caller can arrange a resync for it.
+func (o *outbox) put(lu LeaseUpdatePayload) (evicted *LeaseUpdatePayload, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	prev := o.byIP[lu.IP]
+	queued := now
+	if prev != nil {
+		queued = prev.queued
+	}
+
+	var id uint64
+	err = o.db.Update(func(tx *bolt.Tx) error {
+		b := o.bucket(tx)
+		if prev != nil {
+			if err := b.Delete(outboxKey(prev.id)); err != nil {
+				return err
+			}
+		} else if o.limit > 0 && len(o.byIP) >= o.limit {
+			k, v := b.Cursor().First()
+			if k != nil {
+				var rec outboxRecord
+				if err := json.Unmarshal(v, &rec); err == nil {
+					evicted = &rec.Update
+				}
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		id, _ = b.NextSequence()
+		lu.ID = id
+		data, err := json.Marshal(outboxRecord{Update: lu, QueuedAt: queued.UnixNano()})
+		if err != nil {
+			return err
+		}
+		return b.Put(outboxKey(id), data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queueing lease update for %s: %w", lu.IP, err)
+	}
+
+	if evicted != nil {
+		if p := o.byIP[evicted.IP]; p != nil {
+			p.wait.finish(errUpdateEvicted)
+			delete(o.byIP, evicted.IP)
+		}
+	}
+	p := &pendingUpdate{id: id, queued: queued, wait: newAckWaiter()}
+	if prev != nil {
+		p.wait = prev.wait
+	}
+	o.byIP[lu.IP] = p
+
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+	return evicted, nil
+}
+
+// unsent returns up to n queued updates not yet sent on this connection,
+// oldest first, and the connection generation to pass to markSent.
+func (o *outbox) unsent(n int) ([]LeaseUpdatePayload, uint64, error) {
+	o.mu.Lock()
+	from, gen := o.sentTo+1, o.gen
+	o.mu.Unlock()
+
+	var out []LeaseUpdatePayload
+	err := o.db.View(func(tx *bolt.Tx) error {
+		c := o.bucket(tx).Cursor()
+		for k, v := c.Seek(outboxKey(from)); k != nil && len(out) < n; k, v = c.Next() {
+			var rec outboxRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("unmarshalling HA outbox entry: %w", err)
+			}
+			out = append(out, rec.Update)
+		}
+		return nil
+	})
+	return out, gen, err
+}
+
+// markSent records that the update with id was written to the peer on
+// connection generation gen.
+func (o *outbox) markSent(ip string, id, gen uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if gen != o.gen {
+		return
+	}
+	o.sentTo = max(o.sentTo, id)
+	if p := o.byIP[ip]; p != nil && p.id == id {
+		p.sent = time.Now()
+	}
+}
+
+// rewind marks every entry unsent so it is replayed on a new connection.
+func (o *outbox) rewind() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sentTo = 0
+	o.gen++
+	for _, p := range o.byIP {
+		p.sent = time.Time{}
+	}
+	if len(o.byIP) > 0 {
+		select {
+		case o.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ack removes the entry for ip if id is still its latest update.
+func (o *outbox) ack(ip string, id uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p := o.byIP[ip]
+	if p == nil || p.id != id {
+		return nil
+	}
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		return o.bucket(tx).Delete(outboxKey(id))
+	})
+	if err != nil {
+		return fmt.Errorf("removing acknowledged lease update for %s: %w", ip, err)
+	}
+	p.wait.finish(nil)
+	delete(o.byIP, ip)
+	return nil
+}
+
+// pending returns the waiter for the queued update for ip, or nil if none
+// is queued.
+func (o *outbox) pending(ip string) *ackWaiter {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if p := o.byIP[ip]; p != nil {
+		return p.wait
+	}
+	return nil
+}
+
+// status counts the queued and unacknowledged entries.
+func (o *outbox) status() QueueStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var st QueueStatus
+	now := time.Now()
+	for _, p := range o.byIP {
+		if p.sent.IsZero() {
+			st.Queued++
+		} else {
+			st.Unacked++
+		}
+		st.OldestUnacked = max(st.OldestUnacked, now.Sub(p.queued))
+	}
+	return st
+}
@@ -16,11 +16,23 @@ import (
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
-// Peer manages the TCP connection to the HA partner node.
+// linkWatcher follows the liveness and sync progress of the node at the
+// other end of a link. The FSM watches the peer of a two-node pair; a
+// Cluster watches each of its member links.
+type linkWatcher interface {
+	PeerUp()
+	BulkSyncComplete()
+	PeerClaimedActive(reason string)
+	State() dhcpv4.HAState
+}
+
+// Peer manages the TCP connection to the HA partner node. In cluster mode
+// there is one Peer per member, started as a link of the Cluster.
 type Peer struct {
 	cfg               *config.HAConfig
 	fsm               *FSM
-	leaseStore        *lease.Store
+	watch             linkWatcher
+	leaseStore        lease.Storage
 	bus               *events.Bus
 	logger            *slog.Logger
 	conn              net.Conn
@@ -30,29 +42,93 @@ type Peer struct {
 	done              chan struct{}
 	wg                sync.WaitGroup
 	onLeaseUpdate     func(LeaseUpdatePayload)
+	onLeaseAck        func(LeaseAckPayload)
 	onConflictUpdate  func(ConflictUpdatePayload)
 	onConfigSync      func(ConfigSyncPayload)
 	onAdjacencyFormed func()
+	onClusterMessage  func(*Message) // election and membership traffic (cluster links)
+	hello             *Message       // sent first on outbound connections (cluster links)
 	lastConnErr       string
 	lastConnErrAt     time.Time
+	outbox            *outbox       // local lease changes waiting for the peer's acknowledgement
+	ackTimeout        time.Duration // how long WaitAck waits (ha.sync_ack_timeout)
+	auth              *frameAuth    // signs and checks every frame (nil without ha.auth_key)
+	certs             *tlsCerts     // mutual TLS (nil unless ha.tls.enabled)
+	tlsName           string        // name the peer's certificate must carry
+
+	// Incremental resync state, persisted in the lease store per peer.
+	syncMu    sync.Mutex
+	peerSeq   uint64 // highest peer sequence number applied here
+	ackedSeq  uint64 // highest of our sequence numbers the peer acknowledged
+	gapFrom   uint64 // resend changes after this seq once the queue drains (0 = none)
+	syncDirty bool
 }
 
 // NewPeer creates a new HA peer manager.
-func NewPeer(cfg *config.HAConfig, fsm *FSM, store *lease.Store, bus *events.Bus, logger *slog.Logger) (*Peer, error) {
+func NewPeer(cfg *config.HAConfig, fsm *FSM, store lease.Storage, bus *events.Bus, logger *slog.Logger) (*Peer, error) {
+	auth, err := newFrameAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := newTLSCerts(cfg.TLS, logger)
+	if err != nil {
+		return nil, err
+	}
+	return newPeer(cfg, fsm, store, bus, logger, auth, certs)
+}
+
+// newPeer creates a peer manager using the given frame authenticator and
+// certificates, which cluster links share.
+func newPeer(cfg *config.HAConfig, fsm *FSM, store lease.Storage, bus *events.Bus, logger *slog.Logger,
+	auth *frameAuth, certs *tlsCerts) (*Peer, error) {
 	hbInterval, err := time.ParseDuration(cfg.HeartbeatInterval)
 	if err != nil {
 		hbInterval = time.Second
 	}
+	ackTimeout, err := time.ParseDuration(cfg.SyncAckTimeout)
+	if err != nil || ackTimeout <= 0 {
+		ackTimeout = config.DefaultHASyncAckTimeout
+	}
+	queueSize := cfg.SyncQueueSize
+	if queueSize <= 0 {
+		queueSize = config.DefaultHASyncQueueSize
+	}
+	ob, err := openOutbox(store.DB(), cfg.PeerAddress, queueSize)
+	if err != nil {
+		return nil, fmt.Errorf("opening HA update queue: %w", err)
+	}
 
-	return &Peer{
+	p := &Peer{
 		cfg:               cfg,
 		fsm:               fsm,
+		watch:             fsm,
 		leaseStore:        store,
 		bus:               bus,
 		logger:            logger,
 		heartbeatInterval: hbInterval,
 		done:              make(chan struct{}),
-	}, nil
+		outbox:            ob,
+		ackTimeout:        ackTimeout,
+		auth:              auth,
+		certs:             certs,
+		tlsName:           peerName(cfg.TLS.PeerName, cfg.PeerAddress),
+	}
+	p.peerSeq = store.SyncMark(p.syncMark("peer_seq"))
+	p.ackedSeq = store.SyncMark(p.syncMark("acked_seq"))
+	return p, nil
+}
+
+// syncMark names a per-peer sequence number in the lease store.
+func (p *Peer) syncMark(kind string) string {
+	return "ha/" + p.cfg.PeerAddress + "/" + kind
+}
+
+// SyncStatus returns the highest peer sequence number applied here and
+// the highest of our own the peer has acknowledged.
+func (p *Peer) SyncStatus() (peerSeq, ackedSeq uint64) {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	return p.peerSeq, p.ackedSeq
 }
 
 // OnLeaseUpdate sets a callback for incoming lease updates from the peer.
@@ -60,6 +136,11 @@ func (p *Peer) OnLeaseUpdate(fn func(LeaseUpdatePayload)) {
 	p.onLeaseUpdate = fn
 }
 
+// OnLeaseAck sets a callback for acknowledgements of our lease updates.
+func (p *Peer) OnLeaseAck(fn func(LeaseAckPayload)) {
+	p.onLeaseAck = fn
+}
+
 // OnConflictUpdate sets a callback for incoming conflict updates from the peer.
 func (p *Peer) OnConflictUpdate(fn func(ConflictUpdatePayload)) {
 	p.onConflictUpdate = fn
@@ -122,22 +203,34 @@ func (p *Peer) Start(ctx context.Context) error {
 
 	// Only secondary connects outbound to primary.
 	// Primary is active and just listens for the secondary to connect.
-	if p.cfg.Role == "secondary" {
+	if p.cfg.Role != "secondary" {
+		p.logger.Info("primary role — waiting for secondary to connect inbound")
+	}
+	p.startLink(ctx, p.cfg.Role == "secondary")
+
+	// Heartbeat timeout checker
+	p.wg.Add(1)
+	go p.timeoutLoop(ctx)
+
+	return nil
+}
+
+// startLink starts the heartbeat and lease update senders, and the
+// outbound connection loop if dial is set. Inbound connections are passed
+// in with attach.
+func (p *Peer) startLink(ctx context.Context, dial bool) {
+	if dial {
 		p.wg.Add(1)
 		go p.connectLoop(ctx)
-	} else {
-		p.logger.Info("primary role — waiting for secondary to connect inbound")
 	}
 
 	// Heartbeat sender
 	p.wg.Add(1)
 	go p.heartbeatLoop(ctx)
 
-	// Heartbeat timeout checker
+	// Lease update sender
 	p.wg.Add(1)
-	go p.timeoutLoop(ctx)
-
-	return nil
+	go p.updateLoop(ctx)
 }
 
 // Stop shuts down the HA peer connection.
@@ -152,20 +245,338 @@ func (p *Peer) Stop() {
 	}
 	p.mu.Unlock()
 	p.wg.Wait()
+	p.saveSyncState()
 	p.logger.Info("HA peer stopped")
 }
 
-// SendLeaseUpdate sends a lease change to the peer.
+// SendLeaseUpdate queues a lease change for the peer. It is stored until
+// the peer acknowledges it and replayed after a reconnect.
 func (p *Peer) SendLeaseUpdate(l *lease.Lease) error {
-	msg, err := NewLeaseUpdate(
-		l.IP, l.MAC, l.ClientID, l.Hostname,
-		l.Subnet, l.Pool, string(l.State),
-		l.Start, l.Expiry, l.UpdateSeq,
-	)
+	return p.enqueue(leaseUpdateFromLease(l, time.Time{}))
+}
+
+// QueueLeaseUpdate queues a local lease change for the peer without
+// waiting for it to be sent. potential is the expiry to ask the peer to
+// acknowledge (zero for the lease's own expiry).
+func (p *Peer) QueueLeaseUpdate(l *lease.Lease, potential time.Time) {
+	if err := p.enqueue(leaseUpdateFromLease(l, potential)); err != nil {
+		metrics.HASyncErrors.Inc()
+		p.logger.Error("failed to queue HA lease update", "ip", l.IP.String(), "error", err)
+		p.markGap(l.UpdateSeq)
+	}
+}
+
+// enqueue stores lu in the durable queue. If that evicts the oldest
+// binding, the changes from there on are resent as a delta instead.
+func (p *Peer) enqueue(lu LeaseUpdatePayload) error {
+	evicted, err := p.outbox.put(lu)
 	if err != nil {
-		return fmt.Errorf("creating lease update message: %w", err)
+		return err
+	}
+	if evicted != nil {
+		metrics.HASyncErrors.Inc()
+		p.logger.Warn("HA lease update queue full, falling back to resync for oldest binding",
+			"ip", evicted.IP, "seq", evicted.Seq)
+		p.markGap(evicted.Seq)
+	}
+	return nil
+}
+
+// WaitAck blocks until the peer has acknowledged the queued update for ip,
+// for at most ha.sync_ack_timeout. It returns at once when nothing is
+// queued for ip or the peer is not connected — a missing peer must not
+// stop this node from serving. An update evicted from a full queue is an
+// error: the peer only gets it with the next resync.
+func (p *Peer) WaitAck(ctx context.Context, ip net.IP) error {
+	if !p.Connected() {
+		return nil
+	}
+	wait := p.outbox.pending(ip.String())
+	if wait == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(p.ackTimeout)
+	defer timer.Stop()
+	select {
+	case <-wait.done:
+		if wait.err != nil {
+			return fmt.Errorf("peer did not get %s: %w", ip, wait.err)
+		}
+		return nil
+	case <-timer.C:
+		metrics.HASyncAckTimeouts.Inc()
+		return fmt.Errorf("peer did not acknowledge %s within %s", ip, p.ackTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QueueStatus reports the lease updates still waiting for the peer.
+func (p *Peer) QueueStatus() QueueStatus {
+	return p.outbox.status()
+}
+
+// markGap records that the update with sequence number seq never reached
+// the peer. Acknowledgements stop advancing past it until the changes are
+// resent.
+func (p *Peer) markGap(seq uint64) {
+	if seq == 0 {
+		return
+	}
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	if p.gapFrom == 0 || seq-1 < p.gapFrom {
+		p.gapFrom = seq - 1
+	}
+	p.ackedSeq = min(p.ackedSeq, p.gapFrom)
+	p.syncDirty = true
+}
+
+// takeGap returns and clears the pending gap.
+func (p *Peer) takeGap() uint64 {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	gap := p.gapFrom
+	p.gapFrom = 0
+	return gap
+}
+
+// leaseUpdateFromLease builds the wire form of a lease.
+func leaseUpdateFromLease(l *lease.Lease, potential time.Time) LeaseUpdatePayload {
+	lu := LeaseUpdatePayload{
+		IP:        l.IP.String(),
+		MAC:       l.MAC.String(),
+		ClientID:  l.ClientID,
+		Hostname:  l.Hostname,
+		Subnet:    l.Subnet,
+		Pool:      l.Pool,
+		State:     string(l.State),
+		Start:     l.Start.Unix(),
+		Expiry:    l.Expiry.Unix(),
+		Seq:       l.UpdateSeq,
+		DUID:      l.DUID,
+		IAID:      l.IAID,
+		PrefixLen: l.PrefixLen,
+	}
+	if !potential.IsZero() {
+		lu.PotentialExpiry = potential.Unix()
+	}
+	return lu
+}
+
+// Lease converts a received update back into a lease.
+func (lu LeaseUpdatePayload) Lease() *lease.Lease {
+	mac, _ := net.ParseMAC(lu.MAC)
+	return &lease.Lease{
+		IP:          net.ParseIP(lu.IP),
+		MAC:         mac,
+		ClientID:    lu.ClientID,
+		DUID:        lu.DUID,
+		IAID:        lu.IAID,
+		PrefixLen:   lu.PrefixLen,
+		Hostname:    lu.Hostname,
+		Subnet:      lu.Subnet,
+		Pool:        lu.Pool,
+		State:       dhcpv4.LeaseState(lu.State),
+		Start:       time.Unix(lu.Start, 0),
+		Expiry:      time.Unix(lu.Expiry, 0),
+		LastUpdated: time.Now(),
+		UpdateSeq:   lu.Seq,
+	}
+}
+
+// KnownExpiry returns the expiry the sender of lu has committed to: its
+// potential expiry if it sent one, otherwise the lease expiry.
+func (lu LeaseUpdatePayload) KnownExpiry() time.Time {
+	if lu.PotentialExpiry != 0 {
+		return time.Unix(lu.PotentialExpiry, 0)
+	}
+	return time.Unix(lu.Expiry, 0)
+}
+
+// SendBulkSync streams every active lease to the peer between BULK_START
+// and BULK_END, in batches of ha.sync_batch_size. The peer's FSM leaves
+// RECOVERY when it sees BULK_END.
+func (p *Peer) SendBulkSync() error {
+	seq, err := p.leaseStore.CurrentSeq()
+	if err != nil {
+		return fmt.Errorf("reading lease sequence for bulk sync: %w", err)
+	}
+	var leases []LeaseUpdatePayload
+	p.leaseStore.ForEach(func(l *lease.Lease) bool {
+		if l.State == dhcpv4.LeaseStateActive {
+			leases = append(leases, leaseUpdateFromLease(l, time.Time{}))
+		}
+		return true
+	})
+
+	err = p.streamLeases(leases,
+		BulkStartPayload{TotalLeases: len(leases)},
+		BulkEndPayload{LeasesTransferred: len(leases), Seq: seq})
+	if err != nil {
+		return err
+	}
+	metrics.HASyncOperations.WithLabelValues("bulk_sync").Inc()
+	p.logger.Info("bulk lease sync sent to peer", "leases", len(leases))
+	return nil
+}
+
+// SendChangesSince streams only the lease changes after fromSeq, falling
+// back to a full bulk sync when fromSeq is 0, when the peer has not
+// acknowledged that far, or when the deletion history no longer reaches
+// back to it.
+func (p *Peer) SendChangesSince(fromSeq uint64) error {
+	_, acked := p.SyncStatus()
+	from := min(fromSeq, acked)
+	if from == 0 {
+		return p.SendBulkSync()
+	}
+
+	seq, err := p.leaseStore.CurrentSeq()
+	if err != nil {
+		return fmt.Errorf("reading lease sequence for incremental sync: %w", err)
+	}
+	changes, ok := p.leaseStore.ChangesSince(from)
+	if !ok {
+		p.logger.Info("peer too far behind for incremental sync, sending full table",
+			"from_seq", from, "local_seq", seq)
+		return p.SendBulkSync()
+	}
+
+	leases := make([]LeaseUpdatePayload, 0, len(changes))
+	for _, l := range changes {
+		leases = append(leases, leaseUpdateFromLease(l, time.Time{}))
+	}
+	err = p.streamLeases(leases,
+		BulkStartPayload{TotalLeases: len(leases), Incremental: true, FromSeq: from},
+		BulkEndPayload{LeasesTransferred: len(leases), Incremental: true, Seq: seq})
+	if err != nil {
+		return err
+	}
+	metrics.HASyncOperations.WithLabelValues("incremental_sync").Inc()
+	p.logger.Info("incremental lease sync sent to peer", "from_seq", from, "changes", len(leases))
+	return nil
+}
+
+// streamLeases sends leases between BULK_START and BULK_END in batches of
+// ha.sync_batch_size.
+func (p *Peer) streamLeases(leases []LeaseUpdatePayload, startPayload BulkStartPayload, endPayload BulkEndPayload) error {
+	start, err := newMessage(dhcpv4.HAMsgBulkStart, startPayload)
+	if err != nil {
+		return err
+	}
+	if err := p.sendMessage(start); err != nil {
+		return fmt.Errorf("sending bulk start: %w", err)
+	}
+
+	batch := p.batchSize()
+	for i := 0; i < len(leases); i += batch {
+		end := min(i+batch, len(leases))
+		msg, err := newMessage(dhcpv4.HAMsgBulkData, BulkDataPayload{Leases: leases[i:end]})
+		if err != nil {
+			return err
+		}
+		if err := p.sendMessage(msg); err != nil {
+			return fmt.Errorf("sending bulk data: %w", err)
+		}
+	}
+
+	end, err := newMessage(dhcpv4.HAMsgBulkEnd, endPayload)
+	if err != nil {
+		return err
+	}
+	if err := p.sendMessage(end); err != nil {
+		return fmt.Errorf("sending bulk end: %w", err)
+	}
+	return nil
+}
+
+// batchSize returns ha.sync_batch_size or its default.
+func (p *Peer) batchSize() int {
+	if p.cfg.SyncBatchSize > 0 {
+		return p.cfg.SyncBatchSize
+	}
+	return config.DefaultHASyncBatchSize
+}
+
+// requestResync asks a newly connected peer for the lease changes we
+// have not seen yet.
+func (p *Peer) requestResync() {
+	from, _ := p.SyncStatus()
+	msg, err := NewStateRequest(from)
+	if err != nil {
+		return
+	}
+	if err := p.sendMessage(msg); err != nil {
+		metrics.HASyncErrors.Inc()
+		p.logger.Warn("lease resync request to peer failed", "error", err)
+		return
+	}
+	p.logger.Info("requested lease changes from peer", "from_seq", from)
+}
+
+// notePeerSeq records that a peer change with sequence number seq has
+// been applied.
+func (p *Peer) notePeerSeq(seq uint64) {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	if seq > p.peerSeq {
+		p.peerSeq = seq
+		p.syncDirty = true
+	}
+}
+
+// noteAcked records the peer's acknowledgement of our sequence numbers.
+// It does not advance past an unsent gap.
+func (p *Peer) noteAcked(acks []LeaseAck) {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	for _, a := range acks {
+		seq := a.Seq
+		if p.gapFrom != 0 {
+			seq = min(seq, p.gapFrom)
+		}
+		if seq > p.ackedSeq {
+			p.ackedSeq = seq
+			p.syncDirty = true
+		}
+	}
+}
+
+// saveSyncState persists the per-peer sequence numbers if they changed.
+func (p *Peer) saveSyncState() {
+	p.syncMu.Lock()
+	if !p.syncDirty {
+		p.syncMu.Unlock()
+		return
+	}
+	peerSeq, acked := p.peerSeq, p.ackedSeq
+	p.syncDirty = false
+	p.syncMu.Unlock()
+
+	if err := p.leaseStore.SetSyncMark(p.syncMark("peer_seq"), peerSeq); err != nil {
+		p.logger.Warn("failed to save HA sync state", "error", err)
+		return
+	}
+	if err := p.leaseStore.SetSyncMark(p.syncMark("acked_seq"), acked); err != nil {
+		p.logger.Warn("failed to save HA sync state", "error", err)
+	}
+}
+
+// sendLeaseAcks acknowledges received lease updates.
+func (p *Peer) sendLeaseAcks(updates []LeaseUpdatePayload) {
+	acks := make([]LeaseAck, 0, len(updates))
+	for _, lu := range updates {
+		acks = append(acks, LeaseAck{IP: lu.IP, Seq: lu.Seq, Expiry: lu.KnownExpiry().Unix(), ID: lu.ID})
+	}
+	msg, err := NewLeaseAck(acks)
+	if err != nil {
+		return
+	}
+	if err := p.sendMessage(msg); err != nil {
+		p.logger.Debug("lease ack send failed", "error", err)
 	}
-	return p.sendMessage(msg)
 }
 
 // SendConflictUpdate sends a conflict table entry to the peer.
@@ -187,7 +598,19 @@ func (p *Peer) sendMessage(msg *Message) error {
 	if conn == nil {
 		return fmt.Errorf("no peer connection")
 	}
+	return p.writeMessage(conn, msg)
+}
 
+// writeMessage signs msg if a pre-shared key is set, encodes it and
+// writes it to conn.
+func (p *Peer) writeMessage(conn net.Conn, msg *Message) error {
+	if p.auth != nil {
+		signed, err := p.auth.sign(msg)
+		if err != nil {
+			return err
+		}
+		msg = signed
+	}
 	data, err := EncodeMessage(msg)
 	if err != nil {
 		return err
@@ -228,24 +651,46 @@ func (p *Peer) acceptLoop(ctx context.Context) {
 		p.logger.Info("inbound peer connection accepted",
 			"remote", conn.RemoteAddr().String(),
 			"local", conn.LocalAddr().String())
-		p.setConn(conn)
-		p.fsm.PeerUp()
-
-		// Notify adjacency formed (primary pushes config here)
-		if p.onAdjacencyFormed != nil {
-			p.logger.Info("HA adjacency formed (inbound)", "remote", conn.RemoteAddr().String())
-			go p.onAdjacencyFormed()
+		if p.certs == nil {
+			p.attach(ctx, conn, "inbound")
+			continue
 		}
-
-		// Handle incoming messages
+		// Handshake off the accept loop so a stalled client can't block it
 		p.wg.Add(1)
-		go func(c net.Conn) {
+		go func() {
 			defer p.wg.Done()
-			p.handleConnection(ctx, c)
-		}(conn)
+			tc, err := p.certs.serverHandshake(conn, p.tlsName)
+			if err != nil {
+				p.logger.Warn("rejecting peer connection", "remote", conn.RemoteAddr().String(), "error", err)
+				return
+			}
+			p.attach(ctx, tc, "inbound")
+		}()
 	}
 }
 
+// attach makes conn the active peer connection: the peer counts as up,
+// the adjacency callback runs, lease changes we missed are requested and
+// incoming messages are handled until the connection drops.
+func (p *Peer) attach(ctx context.Context, conn net.Conn, direction string) {
+	p.setConn(conn)
+	p.watch.PeerUp()
+
+	// Notify adjacency formed (primary pushes config here)
+	if p.onAdjacencyFormed != nil {
+		p.logger.Info("HA adjacency formed ("+direction+")", "remote", conn.RemoteAddr().String())
+		go p.onAdjacencyFormed()
+	}
+	go p.requestResync()
+
+	// Handle incoming messages
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.handleConnection(ctx, conn)
+	}()
+}
+
 // connectLoop attempts to connect to the peer (outbound).
 func (p *Peer) connectLoop(ctx context.Context) {
 	defer p.wg.Done()
@@ -267,11 +712,11 @@ func (p *Peer) connectLoop(ctx context.Context) {
 		p.mu.Unlock()
 
 		if hasConn {
-			time.Sleep(5 * time.Second)
+			p.sleep(ctx, 5*time.Second)
 			continue
 		}
 
-		conn, err := net.DialTimeout("tcp", p.cfg.PeerAddress, 5*time.Second)
+		conn, err := p.dial()
 		if err != nil {
 			p.mu.Lock()
 			p.lastConnErr = err.Error()
@@ -286,7 +731,7 @@ func (p *Peer) connectLoop(ctx context.Context) {
 				p.logger.Debug("failed to connect to peer",
 					"address", p.cfg.PeerAddress, "error", err)
 			}
-			time.Sleep(backoff)
+			p.sleep(ctx, backoff)
 			backoff *= 2
 			if backoff > maxBackoff {
 				backoff = maxBackoff
@@ -294,26 +739,41 @@ func (p *Peer) connectLoop(ctx context.Context) {
 			continue
 		}
 
+		if p.hello != nil {
+			if err := p.writeMessage(conn, p.hello); err != nil {
+				conn.Close()
+				p.logger.Debug("cluster hello failed", "address", p.cfg.PeerAddress, "error", err)
+				p.sleep(ctx, backoff)
+				continue
+			}
+		}
+
 		p.mu.Lock()
 		p.lastConnErr = ""
 		p.mu.Unlock()
 		backoff = time.Second
 		p.logger.Info("outbound peer connection established", "address", p.cfg.PeerAddress)
-		p.setConn(conn)
-		p.fsm.PeerUp()
+		p.attach(ctx, conn, "outbound")
+	}
+}
 
-		// Notify adjacency formed (primary pushes config here)
-		if p.onAdjacencyFormed != nil {
-			p.logger.Info("HA adjacency formed (outbound)", "address", p.cfg.PeerAddress)
-			go p.onAdjacencyFormed()
-		}
+// dial connects to the peer, completing the TLS handshake if enabled.
+func (p *Peer) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", p.cfg.PeerAddress, 5*time.Second)
+	if err != nil || p.certs == nil {
+		return conn, err
+	}
+	return p.certs.clientHandshake(conn, p.tlsName)
+}
 
-		// Handle incoming messages on this connection
-		p.wg.Add(1)
-		go func(c net.Conn) {
-			defer p.wg.Done()
-			p.handleConnection(ctx, c)
-		}(conn)
+// sleep waits for d, returning early when the peer is stopped.
+func (p *Peer) sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-p.done:
+	case <-t.C:
 	}
 }
 
@@ -348,6 +808,10 @@ func (p *Peer) handleConnection(ctx context.Context, conn net.Conn) {
 			p.logger.Warn("peer connection read error", "remote", remote, "error", err)
 			return
 		}
+		if err := p.auth.checkFrame(msg); err != nil {
+			p.logger.Warn("dropping peer connection", "remote", remote, "error", err)
+			return
+		}
 
 		p.handleMessage(msg)
 	}
@@ -363,7 +827,7 @@ func (p *Peer) handleMessage(msg *Message) {
 			return
 		}
 		metrics.HAHeartbeatsReceived.Inc()
-		p.fsm.PeerUp()
+		p.watch.PeerUp()
 		p.logger.Debug("heartbeat received",
 			"peer_state", hb.State,
 			"peer_leases", hb.LeaseCount,
@@ -380,6 +844,62 @@ func (p *Peer) handleMessage(msg *Message) {
 		if p.onLeaseUpdate != nil {
 			p.onLeaseUpdate(lu)
 		}
+		p.notePeerSeq(lu.Seq)
+		p.sendLeaseAcks([]LeaseUpdatePayload{lu})
+
+	case dhcpv4.HAMsgBulkData:
+		var bd BulkDataPayload
+		if err := json.Unmarshal(msg.Payload, &bd); err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to unmarshal bulk data", "error", err)
+			return
+		}
+		for _, lu := range bd.Leases {
+			if p.onLeaseUpdate != nil {
+				p.onLeaseUpdate(lu)
+			}
+			p.notePeerSeq(lu.Seq)
+		}
+		p.sendLeaseAcks(bd.Leases)
+
+	case dhcpv4.HAMsgLeaseAck:
+		var la LeaseAckPayload
+		if err := json.Unmarshal(msg.Payload, &la); err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to unmarshal lease ack", "error", err)
+			return
+		}
+		p.noteAcked(la.Leases)
+		for _, a := range la.Leases {
+			if a.ID == 0 {
+				continue
+			}
+			if err := p.outbox.ack(a.IP, a.ID); err != nil {
+				p.logger.Warn("failed to remove acknowledged lease update", "ip", a.IP, "error", err)
+			}
+		}
+		if p.onLeaseAck != nil {
+			p.onLeaseAck(la)
+		}
+
+	case dhcpv4.HAMsgStateRequest:
+		var sr StateRequestPayload
+		if err := json.Unmarshal(msg.Payload, &sr); err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to unmarshal state request", "error", err)
+			return
+		}
+		// A pending gap is covered by this resync
+		from := sr.FromSeq
+		if gap := p.takeGap(); gap != 0 {
+			from = min(from, gap)
+		}
+		go func() {
+			if err := p.SendChangesSince(from); err != nil {
+				metrics.HASyncErrors.Inc()
+				p.logger.Warn("lease resync to peer failed", "error", err)
+			}
+		}()
 
 	case dhcpv4.HAMsgConflictUpdate:
 		var cu ConflictUpdatePayload
@@ -394,11 +914,38 @@ func (p *Peer) handleMessage(msg *Message) {
 		}
 
 	case dhcpv4.HAMsgBulkStart:
-		p.logger.Info("peer bulk sync starting")
+		var bs BulkStartPayload
+		if err := json.Unmarshal(msg.Payload, &bs); err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to unmarshal bulk start", "error", err)
+			return
+		}
+		p.logger.Info("peer bulk sync starting",
+			"leases", bs.TotalLeases,
+			"incremental", bs.Incremental,
+			"from_seq", bs.FromSeq)
 
 	case dhcpv4.HAMsgBulkEnd:
-		p.logger.Info("peer bulk sync complete")
-		p.fsm.BulkSyncComplete()
+		var be BulkEndPayload
+		if err := json.Unmarshal(msg.Payload, &be); err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to unmarshal bulk end", "error", err)
+			return
+		}
+		p.syncMu.Lock()
+		// A full sync restarts our view of the peer's numbering — its
+		// database may have been recreated with lower sequence numbers.
+		if !be.Incremental || be.Seq > p.peerSeq {
+			p.peerSeq = be.Seq
+			p.syncDirty = true
+		}
+		p.syncMu.Unlock()
+		p.saveSyncState()
+		p.logger.Info("peer bulk sync complete",
+			"leases", be.LeasesTransferred,
+			"incremental", be.Incremental,
+			"peer_seq", be.Seq)
+		p.watch.BulkSyncComplete()
 
 	case dhcpv4.HAMsgConfigSync:
 		var cs ConfigSyncPayload
@@ -420,8 +967,13 @@ func (p *Peer) handleMessage(msg *Message) {
 			return
 		}
 		p.logger.Warn("peer claimed active role", "reason", fc.Reason)
-		// If peer claims active, we become standby
-		p.fsm.transition(dhcpv4.HAStateStandby, "peer claimed active: "+fc.Reason)
+		p.watch.PeerClaimedActive(fc.Reason)
+
+	case dhcpv4.HAMsgHello, dhcpv4.HAMsgVoteRequest, dhcpv4.HAMsgVoteResponse,
+		dhcpv4.HAMsgLeaderAnnounce, dhcpv4.HAMsgMembership:
+		if p.onClusterMessage != nil {
+			p.onClusterMessage(msg)
+		}
 
 	default:
 		p.logger.Warn("unknown HA message type", "type", msg.Type)
@@ -443,10 +995,15 @@ func (p *Peer) heartbeatLoop(ctx context.Context) {
 		case <-p.done:
 			return
 		case <-ticker.C:
+			p.saveSyncState()
+			seq, err := p.leaseStore.CurrentSeq()
+			if err != nil {
+				p.logger.Warn("failed to read lease sequence for heartbeat", "error", err)
+			}
 			msg, err := NewHeartbeat(
-				string(p.fsm.State()),
+				string(p.watch.State()),
 				p.leaseStore.Count(),
-				p.leaseStore.NextSeq(),
+				seq,
 				time.Since(startTime),
 			)
 			if err != nil {
@@ -461,6 +1018,71 @@ func (p *Peer) heartbeatLoop(ctx context.Context) {
 	}
 }
 
+// updateLoop sends queued lease updates whenever something is queued and
+// retries every heartbeat interval. Updates stay queued until the peer
+// acknowledges them; a new connection replays everything unacknowledged.
+// Bindings evicted from a full queue are resent as a delta once it drains.
+func (p *Peer) updateLoop(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-p.outbox.kick:
+		case <-ticker.C:
+		}
+		if p.Connected() && p.flushUpdates() {
+			if gap := p.takeGap(); gap != 0 {
+				if err := p.SendChangesSince(gap); err != nil {
+					p.markGap(gap + 1)
+				}
+			}
+		}
+		p.updateQueueMetrics()
+	}
+}
+
+// flushUpdates sends every queued update not yet sent on the current
+// connection. Reports whether the queue was drained.
+func (p *Peer) flushUpdates() bool {
+	for {
+		batch, gen, err := p.outbox.unsent(p.batchSize())
+		if err != nil {
+			metrics.HASyncErrors.Inc()
+			p.logger.Error("failed to read HA update queue", "error", err)
+			return false
+		}
+		if len(batch) == 0 {
+			return true
+		}
+		for _, lu := range batch {
+			msg, err := newLeaseUpdateMessage(lu)
+			if err != nil {
+				continue
+			}
+			if err := p.sendMessage(msg); err != nil {
+				metrics.HASyncErrors.Inc()
+				p.logger.Debug("lease update send failed", "ip", lu.IP, "error", err)
+				return false
+			}
+			p.outbox.markSent(lu.IP, lu.ID, gen)
+		}
+	}
+}
+
+// updateQueueMetrics exports the queue depth and acknowledgement lag.
+func (p *Peer) updateQueueMetrics() {
+	st := p.outbox.status()
+	metrics.HAUpdateQueueLength.Set(float64(st.Queued))
+	metrics.HAUnackedUpdates.Set(float64(st.Unacked))
+	metrics.HAOldestUnackedSeconds.Set(st.OldestUnacked.Seconds())
+}
+
 // timeoutLoop periodically checks for heartbeat timeout.
 func (p *Peer) timeoutLoop(ctx context.Context) {
 	defer p.wg.Done()
@@ -479,7 +1101,8 @@ func (p *Peer) timeoutLoop(ctx context.Context) {
 	}
 }
 
-// setConn sets the active peer connection.
+// setConn sets the active peer connection and replays every
+// unacknowledged lease update over it.
 func (p *Peer) setConn(conn net.Conn) {
 	p.mu.Lock()
 	if p.conn != nil {
@@ -487,6 +1110,7 @@ func (p *Peer) setConn(conn net.Conn) {
 	}
 	p.conn = conn
 	p.mu.Unlock()
+	p.outbox.rewind()
 }
 
 // Connected returns true if the peer connection is active.
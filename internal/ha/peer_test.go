@@ -0,0 +1,258 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/lease"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// newTestPeer returns a peer wired to one end of an in-memory connection
+// and the other end for the test to read from.
+func newTestPeer(t *testing.T) (*Peer, *lease.Store, net.Conn) {
+	t.Helper()
+	store, err := lease.NewStore(filepath.Join(t.TempDir(), "peer.db"))
+	if err != nil {
+		t.Fatalf("NewStore error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	fsm, bus := newTestFSM("primary")
+	t.Cleanup(bus.Stop)
+	p, err := NewPeer(&config.HAConfig{PeerAddress: "10.0.0.2:8068", SyncBatchSize: 2}, fsm, store, bus, fsm.logger)
+	if err != nil {
+		t.Fatalf("NewPeer error: %v", err)
+	}
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() { local.Close(); remote.Close() })
+	p.setConn(local)
+	return p, store, remote
+}
+
+// readSync collects one BULK_START … BULK_END exchange from conn.
+func readSync(t *testing.T, conn net.Conn) (BulkStartPayload, []LeaseUpdatePayload, BulkEndPayload) {
+	t.Helper()
+	var start BulkStartPayload
+	var leases []LeaseUpdatePayload
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		msg, err := DecodeMessage(conn)
+		if err != nil {
+			t.Fatalf("DecodeMessage error: %v", err)
+		}
+		switch msg.Type {
+		case dhcpv4.HAMsgBulkStart:
+			json.Unmarshal(msg.Payload, &start)
+		case dhcpv4.HAMsgBulkData:
+			var bd BulkDataPayload
+			json.Unmarshal(msg.Payload, &bd)
+			leases = append(leases, bd.Leases...)
+		case dhcpv4.HAMsgBulkEnd:
+			var end BulkEndPayload
+			json.Unmarshal(msg.Payload, &end)
+			return start, leases, end
+		}
+	}
+}
+
+func TestPeerSendChangesSince(t *testing.T) {
+	p, store, remote := newTestPeer(t)
+
+	now := time.Now()
+	for i := byte(1); i <= 5; i++ {
+		seq, err := store.NextSeq()
+		if err != nil {
+			t.Fatalf("NextSeq: %v", err)
+		}
+		store.Put(&lease.Lease{
+			IP:        net.IPv4(192, 168, 1, i),
+			MAC:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, i},
+			Subnet:    "192.168.1.0/24",
+			State:     dhcpv4.LeaseStateActive,
+			Start:     now,
+			Expiry:    now.Add(time.Hour),
+			UpdateSeq: seq,
+		})
+	}
+
+	// Nothing acknowledged yet — the peer gets the whole table
+	go p.SendChangesSince(3)
+	start, leases, end := readSync(t, remote)
+	if start.Incremental || len(leases) != 5 || end.Seq != 5 {
+		t.Errorf("first sync: incremental=%v leases=%d seq=%d, want full sync of 5 at seq 5",
+			start.Incremental, len(leases), end.Seq)
+	}
+
+	// Once the peer has acknowledged seq 3 only the delta is sent
+	p.noteAcked([]LeaseAck{{Seq: 3}})
+	go p.SendChangesSince(3)
+	start, leases, end = readSync(t, remote)
+	if !start.Incremental || len(leases) != 2 || leases[0].Seq != 4 || end.Seq != 5 {
+		t.Errorf("delta sync: incremental=%v leases=%d, want seq 4 and 5 only", start.Incremental, len(leases))
+	}
+
+	// A dropped update holds the acknowledged seq back until it is resent
+	p.markGap(2)
+	if _, acked := p.SyncStatus(); acked != 1 {
+		t.Errorf("acked after gap = %d, want 1", acked)
+	}
+	p.noteAcked([]LeaseAck{{Seq: 5}})
+	if _, acked := p.SyncStatus(); acked != 1 {
+		t.Errorf("acked advanced past gap to %d", acked)
+	}
+	if gap := p.takeGap(); gap != 1 {
+		t.Errorf("takeGap = %d, want 1", gap)
+	}
+}
+
+func TestPeerSyncStatePersists(t *testing.T) {
+	p, store, _ := newTestPeer(t)
+
+	p.notePeerSeq(17)
+	p.noteAcked([]LeaseAck{{Seq: 9}})
+	p.saveSyncState()
+
+	p2, err := NewPeer(p.cfg, p.fsm, store, p.bus, p.logger)
+	if err != nil {
+		t.Fatalf("NewPeer error: %v", err)
+	}
+	if peerSeq, acked := p2.SyncStatus(); peerSeq != 17 || acked != 9 {
+		t.Errorf("restored sync state = %d/%d, want 17/9", peerSeq, acked)
+	}
+}
+
+// readUpdate reads the next LEASE_UPDATE from conn.
+func readUpdate(t *testing.T, conn net.Conn) LeaseUpdatePayload {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		msg, err := DecodeMessage(conn)
+		if err != nil {
+			t.Fatalf("DecodeMessage error: %v", err)
+		}
+		if msg.Type == dhcpv4.HAMsgLeaseUpdate {
+			var lu LeaseUpdatePayload
+			json.Unmarshal(msg.Payload, &lu)
+			return lu
+		}
+	}
+}
+
+func TestPeerUpdateQueue(t *testing.T) {
+	p, store, remote := newTestPeer(t)
+
+	l := &lease.Lease{
+		IP:        net.IPv4(192, 168, 1, 10),
+		MAC:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x10},
+		Subnet:    "192.168.1.0/24",
+		State:     dhcpv4.LeaseStateActive,
+		Expiry:    time.Now().Add(time.Hour),
+		UpdateSeq: 1,
+	}
+	p.QueueLeaseUpdate(l, time.Time{})
+	l2 := l.Clone()
+	l2.IP = net.IPv4(192, 168, 1, 11)
+	l2.UpdateSeq = 2
+	p.QueueLeaseUpdate(l2, time.Time{})
+
+	// A newer change to the same binding replaces the queued one
+	l.UpdateSeq = 3
+	p.QueueLeaseUpdate(l, time.Time{})
+	if st := p.QueueStatus(); st.Queued != 2 || st.Unacked != 0 {
+		t.Fatalf("queue = %+v, want 2 queued", st)
+	}
+
+	drained := make(chan bool)
+	go func() { drained <- p.flushUpdates() }()
+	first, second := readUpdate(t, remote), readUpdate(t, remote)
+	if first.IP != "192.168.1.11" || second.IP != "192.168.1.10" || second.Seq != 3 {
+		t.Fatalf("sent %s/%d then %s/%d, want .11 then .10 at seq 3", first.IP, first.Seq, second.IP, second.Seq)
+	}
+	if !<-drained {
+		t.Error("flushUpdates did not drain the queue")
+	}
+	if st := p.QueueStatus(); st.Queued != 0 || st.Unacked != 2 {
+		t.Errorf("after send = %+v, want 2 unacked", st)
+	}
+
+	// Acknowledge one; WaitAck returns at once for it and times out for the other
+	p.handleMessage(mustMessage(t, dhcpv4.HAMsgLeaseAck, LeaseAckPayload{Leases: []LeaseAck{{IP: first.IP, Seq: first.Seq, ID: first.ID}}}))
+	if err := p.WaitAck(context.Background(), net.ParseIP(first.IP)); err != nil {
+		t.Errorf("WaitAck for acknowledged binding: %v", err)
+	}
+	p.ackTimeout = 10 * time.Millisecond
+	if err := p.WaitAck(context.Background(), l.IP); err == nil {
+		t.Error("WaitAck for unacknowledged binding returned nil")
+	}
+
+	// A new connection replays the unacknowledged update
+	local2, remote2 := net.Pipe()
+	t.Cleanup(func() { local2.Close(); remote2.Close() })
+	p.setConn(local2)
+	go p.flushUpdates()
+	if lu := readUpdate(t, remote2); lu.IP != "192.168.1.10" || lu.ID != second.ID {
+		t.Errorf("replayed %s id %d, want 192.168.1.10 id %d", lu.IP, lu.ID, second.ID)
+	}
+
+	// And so does a restart
+	p2, err := NewPeer(p.cfg, p.fsm, store, p.bus, p.logger)
+	if err != nil {
+		t.Fatalf("NewPeer error: %v", err)
+	}
+	if st := p2.QueueStatus(); st.Queued != 1 || st.OldestUnacked <= 0 {
+		t.Errorf("restored queue = %+v, want 1 queued", st)
+	}
+}
+
+func TestPeerUpdateQueueBounded(t *testing.T) {
+	p, _, _ := newTestPeer(t)
+	p.outbox.limit = 2
+
+	var oldest *ackWaiter
+	for i := byte(1); i <= 3; i++ {
+		p.QueueLeaseUpdate(&lease.Lease{
+			IP:        net.IPv4(192, 168, 1, i),
+			State:     dhcpv4.LeaseStateActive,
+			UpdateSeq: uint64(i) + 4,
+		}, time.Time{})
+		if i == 1 {
+			oldest = p.outbox.pending("192.168.1.1")
+		}
+	}
+	if st := p.QueueStatus(); st.Queued != 2 {
+		t.Errorf("queue length = %d, want 2", st.Queued)
+	}
+	if p.outbox.pending("192.168.1.1") != nil {
+		t.Error("oldest binding not evicted")
+	}
+	// Anyone waiting on the evicted binding must not see it as acknowledged
+	select {
+	case <-oldest.done:
+		if !errors.Is(oldest.err, errUpdateEvicted) {
+			t.Errorf("evicted waiter err = %v, want errUpdateEvicted", oldest.err)
+		}
+	default:
+		t.Error("evicted waiter not released")
+	}
+	// The evicted change is resent by a resync from just before it
+	if gap := p.takeGap(); gap != 4 {
+		t.Errorf("gap = %d, want 4", gap)
+	}
+}
+
+func mustMessage(t *testing.T, typ dhcpv4.HAMessageType, v interface{}) *Message {
+	t.Helper()
+	msg, err := newMessage(typ, v)
+	if err != nil {
+		t.Fatalf("newMessage error: %v", err)
+	}
+	return msg
+}
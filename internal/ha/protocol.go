@@ -9,14 +9,18 @@ import (
 	"net"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
-// Message is the wire-format message exchanged between HA peers.
+// Message is the wire-format message exchanged between HA peers. Nonce
+// and MAC are only set when ha.auth_key is configured (see frameAuth).
 type Message struct {
 	Type      dhcpv4.HAMessageType `json:"type"`
 	Timestamp int64                `json:"timestamp"`
 	Payload   json.RawMessage      `json:"payload,omitempty"`
+	Nonce     string               `json:"nonce,omitempty"`
+	MAC       string               `json:"mac,omitempty"`
 }
 
 // HeartbeatPayload is sent periodically to confirm peer liveness.
@@ -39,18 +43,60 @@ type LeaseUpdatePayload struct {
 	Start    int64  `json:"start"`
 	Expiry   int64  `json:"expiry"`
 	Seq      uint64 `json:"seq"`
+
+	// DHCPv6 bindings only (MAC may be empty).
+	DUID      string `json:"duid,omitempty"`
+	IAID      uint32 `json:"iaid,omitempty"`
+	PrefixLen int    `json:"prefix_len,omitempty"`
+
+	// PotentialExpiry is the expiry the sender would like the peer to
+	// acknowledge, so later renewals can exceed the MCLT (load balancing).
+	PotentialExpiry int64 `json:"potential_expiry,omitempty"`
+
+	// ID identifies the sender's queue entry for a single update (0 in
+	// bulk syncs). The acknowledgement echoes it.
+	ID uint64 `json:"id,omitempty"`
+}
+
+// LeaseAckPayload acknowledges lease updates, one entry per binding.
+type LeaseAckPayload struct {
+	Leases []LeaseAck `json:"leases"`
+}
+
+// LeaseAck confirms the peer stored a binding. Expiry echoes the potential
+// expiry of the update (or its expiry if none was sent), ID its queue entry.
+type LeaseAck struct {
+	IP     string `json:"ip"`
+	Seq    uint64 `json:"seq"`
+	Expiry int64  `json:"expiry"`
+	ID     uint64 `json:"id,omitempty"`
+}
+
+// BulkDataPayload carries one batch of leases during a bulk sync.
+type BulkDataPayload struct {
+	Leases []LeaseUpdatePayload `json:"leases"`
 }
 
 // BulkStartPayload signals the beginning of a bulk sync.
 type BulkStartPayload struct {
-	TotalLeases    int `json:"total_leases"`
-	TotalConflicts int `json:"total_conflicts"`
+	TotalLeases    int    `json:"total_leases"`
+	TotalConflicts int    `json:"total_conflicts"`
+	Incremental    bool   `json:"incremental,omitempty"` // only changes after FromSeq follow
+	FromSeq        uint64 `json:"from_seq,omitempty"`
 }
 
 // BulkEndPayload signals the completion of a bulk sync.
 type BulkEndPayload struct {
-	LeasesTransferred    int `json:"leases_transferred"`
-	ConflictsTransferred int `json:"conflicts_transferred"`
+	LeasesTransferred    int    `json:"leases_transferred"`
+	ConflictsTransferred int    `json:"conflicts_transferred"`
+	Incremental          bool   `json:"incremental,omitempty"`
+	Seq                  uint64 `json:"seq"` // sender's sequence number when the sync started
+}
+
+// StateRequestPayload asks the peer for every lease change after FromSeq,
+// in the peer's own sequence numbering. FromSeq 0 asks for a full sync.
+type StateRequestPayload struct {
+	FromSeq uint64 `json:"from_seq"`
 }
 
 // ConflictUpdatePayload carries a conflict table entry to the peer.
@@ -79,6 +125,47 @@ type ConfigSyncPayload struct {
 	Timestamp int64           `json:"timestamp"`
 }
 
+// HelloPayload is the first message on a cluster connection and names
+// the node that dialled.
+type HelloPayload struct {
+	Node string `json:"node"`
+}
+
+// VoteRequestPayload asks a member for its vote. DataTerm and DataSeq
+// describe the newest lease data the candidate holds: the term in which it
+// last led or received leases from the leader, and that leader's sequence
+// number. Members only vote for candidates at least as up to date. A
+// pre-vote asks whether the member would vote in Term without anyone
+// moving to it.
+type VoteRequestPayload struct {
+	Term      uint64 `json:"term"`
+	Candidate string `json:"candidate"`
+	DataTerm  uint64 `json:"data_term"`
+	DataSeq   uint64 `json:"data_seq"`
+	PreVote   bool   `json:"pre_vote,omitempty"`
+}
+
+// VoteResponsePayload answers a vote request.
+type VoteResponsePayload struct {
+	Term    uint64 `json:"term"`
+	Voter   string `json:"voter"`
+	Granted bool   `json:"granted"`
+	PreVote bool   `json:"pre_vote,omitempty"`
+}
+
+// LeaderAnnouncePayload is sent by the leader every heartbeat interval.
+type LeaderAnnouncePayload struct {
+	Term   uint64 `json:"term"`
+	Leader string `json:"leader"`
+}
+
+// MembershipPayload carries the cluster member list after a change made
+// on the leader of Term.
+type MembershipPayload struct {
+	Term    uint64            `json:"term"`
+	Members []config.HAMember `json:"members"`
+}
+
 // EncodeMessage serializes a Message to bytes with a length prefix (4-byte big-endian).
 func EncodeMessage(msg *Message) ([]byte, error) {
 	data, err := json.Marshal(msg)
@@ -142,7 +229,7 @@ func NewHeartbeat(state string, leaseCount int, seq uint64, uptime time.Duration
 // NewLeaseUpdate creates a lease update message.
 func NewLeaseUpdate(ip net.IP, mac net.HardwareAddr, clientID, hostname, subnet, pool, state string,
 	start, expiry time.Time, seq uint64) (*Message, error) {
-	payload, err := json.Marshal(LeaseUpdatePayload{
+	return newLeaseUpdateMessage(LeaseUpdatePayload{
 		IP:       ip.String(),
 		MAC:      mac.String(),
 		ClientID: clientID,
@@ -154,6 +241,11 @@ func NewLeaseUpdate(ip net.IP, mac net.HardwareAddr, clientID, hostname, subnet,
 		Expiry:   expiry.Unix(),
 		Seq:      seq,
 	})
+}
+
+// newLeaseUpdateMessage wraps an already-populated lease update payload.
+func newLeaseUpdateMessage(lu LeaseUpdatePayload) (*Message, error) {
+	payload, err := json.Marshal(lu)
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +256,29 @@ func NewLeaseUpdate(ip net.IP, mac net.HardwareAddr, clientID, hostname, subnet,
 	}, nil
 }
 
+// NewLeaseAck creates an acknowledgement for received lease updates.
+func NewLeaseAck(acks []LeaseAck) (*Message, error) {
+	return newMessage(dhcpv4.HAMsgLeaseAck, LeaseAckPayload{Leases: acks})
+}
+
+// NewStateRequest creates a request for lease changes after fromSeq.
+func NewStateRequest(fromSeq uint64) (*Message, error) {
+	return newMessage(dhcpv4.HAMsgStateRequest, StateRequestPayload{FromSeq: fromSeq})
+}
+
+// newMessage wraps a JSON payload in a message of the given type.
+func newMessage(t dhcpv4.HAMessageType, v interface{}) (*Message, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Type:      t,
+		Timestamp: time.Now().Unix(),
+		Payload:   payload,
+	}, nil
+}
+
 // NewConfigSync creates a config sync message for a given section.
 func NewConfigSync(section string, data json.RawMessage) (*Message, error) {
 	payload, err := json.Marshal(ConfigSyncPayload{
@@ -0,0 +1,238 @@
+package ha
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+)
+
+// tlsReloadCheck is how often the certificate files are checked for changes.
+const tlsReloadCheck = time.Second
+
+// tlsHandshakeTimeout bounds the TLS handshake on a new sync connection.
+const tlsHandshakeTimeout = 5 * time.Second
+
+// tlsCerts holds this node's certificate and the CA pool from ha.tls. The
+// files are checked at most once a second when a connection is set up and
+// reloaded when they change, so rotated certificates apply to the next
+// connection without restarting anything. Established connections keep
+// the certificate they were set up with.
+type tlsCerts struct {
+	cfg    config.HATLSConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	stamp   string // size and mtime of the files at the last load
+	checked time.Time
+}
+
+// newTLSCerts loads the files named in cfg, or returns nil when TLS is off.
+func newTLSCerts(cfg config.HATLSConfig, logger *slog.Logger) (*tlsCerts, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	t := &tlsCerts{cfg: cfg, logger: logger}
+	stamp, err := t.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(stamp); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// fileStamp summarises the size and mtime of the three files.
+func (t *tlsCerts) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, f := range []string{t.cfg.CertFile, t.cfg.KeyFile, t.cfg.CAFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", fmt.Errorf("HA TLS: %w", err)
+		}
+		fmt.Fprintf(&b, "%d/%d;", fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// load reads the key pair and CA. Caller holds t.mu or owns t.
+func (t *tlsCerts) load(stamp string) error {
+	cert, err := tls.LoadX509KeyPair(t.cfg.CertFile, t.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading HA TLS key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(t.cfg.CAFile)
+	if err != nil {
+		return fmt.Errorf("reading HA TLS CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no certificates found in HA TLS CA file %s", t.cfg.CAFile)
+	}
+	t.cert, t.pool, t.stamp = &cert, pool, stamp
+	return nil
+}
+
+// current returns the certificate and CA pool, reloading them first if
+// the files changed. A failed reload keeps the previous ones.
+func (t *tlsCerts) current() (*tls.Certificate, *x509.CertPool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.checked) < tlsReloadCheck {
+		return t.cert, t.pool
+	}
+	t.checked = time.Now()
+	stamp, err := t.fileStamp()
+	if err != nil || stamp == t.stamp {
+		return t.cert, t.pool
+	}
+	if err := t.load(stamp); err != nil {
+		t.logger.Error("HA TLS certificate reload failed — keeping the previous certificate", "error", err)
+		t.stamp = stamp // don't retry until the files change again
+		return t.cert, t.pool
+	}
+	metrics.HATLSReloads.Inc()
+	t.logger.Info("HA TLS certificates reloaded", "cert_file", t.cfg.CertFile)
+	return t.cert, t.pool
+}
+
+// serverConfig is the TLS config for inbound connections. The peer must
+// present a certificate from the CA; if name is set it must also carry it
+// (in cluster mode the name is checked once the hello says who dialled).
+func (t *tlsCerts) serverConfig(name string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := t.current()
+			return cert, nil
+		},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return t.verify(cs, name)
+		},
+	}
+}
+
+// clientConfig is the TLS config for dialling a peer expected to carry name.
+func (t *tlsCerts) clientConfig(name string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The chain and name are checked in VerifyConnection so CN-only
+		// certificates can be pinned too
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := t.current()
+			return cert, nil
+		},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return t.verify(cs, name)
+		},
+	}
+}
+
+// verify checks the peer's certificate chains to the CA and, if name is
+// set, carries it.
+func (t *tlsCerts) verify(cs tls.ConnectionState, name string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("peer presented no certificate")
+	}
+	_, pool := t.current()
+	leaf := cs.PeerCertificates[0]
+	inter := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		inter.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: inter,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("peer certificate not trusted: %w", err)
+	}
+	if name != "" && !certHasName(leaf, name) {
+		return fmt.Errorf("peer certificate is for %q, expected %q", certNames(leaf), name)
+	}
+	return nil
+}
+
+// certHasName reports whether cert carries name as a DNS or IP SAN or as
+// its common name.
+func certHasName(cert *x509.Certificate, name string) bool {
+	return cert.VerifyHostname(name) == nil || cert.Subject.CommonName == name
+}
+
+// certNames lists the names in cert for error messages.
+func certNames(cert *x509.Certificate) string {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return strings.Join(names, ",")
+}
+
+// peerName returns the name a peer's certificate must carry: the
+// configured one, else the host part of its address.
+func peerName(configured, address string) string {
+	if configured != "" {
+		return configured
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// serverHandshake wraps an accepted connection in TLS and completes the
+// handshake, checking the peer against name.
+func (t *tlsCerts) serverHandshake(conn net.Conn, name string) (net.Conn, error) {
+	tc := tls.Server(conn, t.serverConfig(name))
+	return tc, t.handshake(tc)
+}
+
+// clientHandshake wraps a dialled connection in TLS and completes the
+// handshake, checking the peer against name.
+func (t *tlsCerts) clientHandshake(conn net.Conn, name string) (net.Conn, error) {
+	tc := tls.Client(conn, t.clientConfig(name))
+	return tc, t.handshake(tc)
+}
+
+func (t *tlsCerts) handshake(tc *tls.Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+	defer cancel()
+	if err := tc.HandshakeContext(ctx); err != nil {
+		tc.Close()
+		metrics.HAAuthFailures.WithLabelValues("tls").Inc()
+		return fmt.Errorf("TLS handshake with %s: %w", tc.RemoteAddr(), err)
+	}
+	return nil
+}
+
+// checkPeerName verifies that the TLS peer on conn carries name. Plain
+// connections pass.
+func checkPeerName(conn net.Conn, name string) error {
+	tc, ok := conn.(*tls.Conn)
+	if !ok || name == "" {
+		return nil
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 || !certHasName(certs[0], name) {
+		metrics.HAAuthFailures.WithLabelValues("tls").Inc()
+		return fmt.Errorf("peer certificate does not carry %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package ha
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// testCA issues certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for cn (with cn as a DNS SAN too, unless
+// cnOnly) and the CA to dir, returning the ha.tls config for them.
+func (ca *testCA) issue(t *testing.T, dir, cn string, cnOnly bool) config.HATLSConfig {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if !cnOnly {
+		tmpl.DNSNames = []string{cn}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.HATLSConfig{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "node.crt"),
+		KeyFile:  filepath.Join(dir, "node.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	writeFile(t, cfg.CAFile, ca.pem)
+	return cfg
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadTestCerts(t *testing.T, cfg config.HATLSConfig) *tlsCerts {
+	t.Helper()
+	certs, err := newTLSCerts(cfg, slog.Default())
+	if err != nil {
+		t.Fatalf("newTLSCerts error: %v", err)
+	}
+	return certs
+}
+
+// tlsPair handshakes server and client over loopback, each expecting the
+// other to carry the given name, and returns the two errors.
+func tlsPair(t *testing.T, server, client *tlsCerts, serverExpects, clientExpects string) (serverErr, clientErr error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer ln.Close()
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		tc, err := server.serverHandshake(conn, serverExpects)
+		if err == nil {
+			tc.Close()
+		}
+		done <- err
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	tc, clientErr := client.clientHandshake(conn, clientExpects)
+	if clientErr == nil {
+		// The server's verdict on our certificate arrives after our side
+		// of the handshake completes
+		tc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		tc.Read(make([]byte, 1))
+		tc.Close()
+	}
+	return <-done, clientErr
+}
+
+func TestTLSPeerNamePinning(t *testing.T) {
+	ca := newTestCA(t)
+	a := loadTestCerts(t, ca.issue(t, t.TempDir(), "node-a", false))
+	b := loadTestCerts(t, ca.issue(t, t.TempDir(), "node-b", true))
+	rogue := loadTestCerts(t, newTestCA(t).issue(t, t.TempDir(), "node-b", false))
+
+	tests := []struct {
+		name          string
+		client        *tlsCerts
+		serverExpects string
+		clientExpects string
+		wantOK        bool
+	}{
+		{"names match", b, "node-b", "node-a", true},
+		{"no pinning", b, "", "", true},
+		{"server expects another name", b, "node-c", "node-a", false},
+		{"client expects another name", b, "node-b", "node-c", false},
+		{"certificate from another CA", rogue, "node-b", "node-a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverErr, clientErr := tlsPair(t, a, tt.client, tt.serverExpects, tt.clientExpects)
+			if ok := serverErr == nil && clientErr == nil; ok != tt.wantOK {
+				t.Errorf("handshake ok = %v, want %v (server: %v, client: %v)", ok, tt.wantOK, serverErr, clientErr)
+			}
+		})
+	}
+}
+
+func TestTLSCertReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certs := loadTestCerts(t, ca.issue(t, dir, "node-a", false))
+	peer := loadTestCerts(t, ca.issue(t, t.TempDir(), "node-b", false))
+
+	// Rotate to a certificate with a new name; the next handshake uses it
+	// once the check interval has passed
+	ca.issue(t, dir, "node-a2", false)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "node.crt"), future, future)
+	certs.checked = time.Time{}
+	if _, clientErr := tlsPair(t, certs, peer, "node-b", "node-a2"); clientErr != nil {
+		t.Fatalf("handshake after rotation: %v", clientErr)
+	}
+
+	// A broken rotation keeps the last good certificate
+	writeFile(t, filepath.Join(dir, "node.key"), []byte("not a key"))
+	certs.checked = time.Time{}
+	cert, _ := certs.current()
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "node-a2" {
+		t.Errorf("after failed reload cert = %q, want node-a2", leaf.Subject.CommonName)
+	}
+}
+
+func TestPeerName(t *testing.T) {
+	tests := []struct {
+		configured, address, want string
+	}{
+		{"dhcp-b.example.net", "10.0.0.2:8067", "dhcp-b.example.net"},
+		{"", "10.0.0.2:8067", "10.0.0.2"},
+		{"", "dhcp-b:8067", "dhcp-b"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := peerName(tt.configured, tt.address); got != tt.want {
+			t.Errorf("peerName(%q, %q) = %q, want %q", tt.configured, tt.address, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// witnessPath is the single endpoint of the witness HTTP API.
+const witnessPath = "/v1/witness"
+
+// witnessForget drops nodes that have not reported for this long.
+const witnessForget = 24 * time.Hour
+
+// WitnessReport is what a node sends to the witness.
+type WitnessReport struct {
+	Group string `json:"group"`
+	Node  string `json:"node"`
+}
+
+// WitnessStatus is the witness's view of one group: how long ago each node
+// last reported, in milliseconds. Ages avoid any clock skew between hosts.
+type WitnessStatus struct {
+	Group    string           `json:"group"`
+	LastSeen map[string]int64 `json:"last_seen_ms"`
+}
+
+// WitnessServer is a tiny arbiter run on a third host (athena-dhcpd
+// -witness). HA nodes report to it every heartbeat interval; when a node
+// loses its peer it asks the witness whether the peer is still reporting.
+// If it is, only the link between the nodes is broken.
+type WitnessServer struct {
+	logger *slog.Logger
+	mu     sync.Mutex
+	groups map[string]map[string]time.Time // group → node → last report
+}
+
+// NewWitnessServer creates an empty witness.
+func NewWitnessServer(logger *slog.Logger) *WitnessServer {
+	return &WitnessServer{
+		logger: logger,
+		groups: make(map[string]map[string]time.Time),
+	}
+}
+
+// ServeHTTP handles POST (report and get status) and GET ?group= (status only).
+func (w *WitnessServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != witnessPath {
+		http.NotFound(rw, r)
+		return
+	}
+
+	var group string
+	switch r.Method {
+	case http.MethodGet:
+		group = r.URL.Query().Get("group")
+	case http.MethodPost:
+		var rep WitnessReport
+		if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 4096)).Decode(&rep); err != nil || rep.Node == "" {
+			http.Error(rw, "invalid report", http.StatusBadRequest)
+			return
+		}
+		group = rep.Group
+		w.record(group, rep.Node, r.RemoteAddr)
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.Status(group))
+}
+
+// record notes a report from node in group.
+func (w *WitnessServer) record(group, node, remote string) {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nodes := w.groups[group]
+	if nodes == nil {
+		nodes = make(map[string]time.Time)
+		w.groups[group] = nodes
+	}
+	if _, known := nodes[node]; !known {
+		w.logger.Info("witness: node registered", "group", group, "node", node, "remote", remote)
+	}
+	nodes[node] = now
+	for n, seen := range nodes {
+		if now.Sub(seen) > witnessForget {
+			delete(nodes, n)
+		}
+	}
+}
+
+// Status returns the last-report ages of every node in group.
+func (w *WitnessServer) Status(group string) WitnessStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	st := WitnessStatus{Group: group, LastSeen: make(map[string]int64)}
+	for node, seen := range w.groups[group] {
+		st.LastSeen[node] = time.Since(seen).Milliseconds()
+	}
+	return st
+}
+
+// WitnessClient reports to a witness and reads back its status.
+type WitnessClient struct {
+	url    string
+	group  string
+	node   string
+	client *http.Client
+}
+
+// NewWitnessClient creates a client for the witness at baseURL, reporting
+// as node within group.
+func NewWitnessClient(baseURL, group, node string, timeout time.Duration) *WitnessClient {
+	return &WitnessClient{
+		url:    strings.TrimRight(baseURL, "/") + witnessPath,
+		group:  group,
+		node:   node,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Report tells the witness this node is alive and returns when every node
+// in the group last reported.
+func (c *WitnessClient) Report(ctx context.Context) (map[string]time.Duration, error) {
+	body, err := json.Marshal(WitnessReport{Group: c.group, Node: c.node})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building witness request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting witness: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("witness returned %s", resp.Status)
+	}
+
+	var st WitnessStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("decoding witness status: %w", err)
+	}
+	ages := make(map[string]time.Duration, len(st.LastSeen))
+	for node, ms := range st.LastSeen {
+		ages[node] = time.Duration(ms) * time.Millisecond
+	}
+	return ages, nil
+}
@@ -0,0 +1,84 @@
+package ha
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// testPinger answers echo requests from a fixed set of addresses.
+type testPinger map[string]bool
+
+func (p testPinger) Available() bool { return true }
+
+func (p testPinger) Probe(_ context.Context, ip net.IP) (bool, error) {
+	return p[ip.String()], nil
+}
+
+func TestWitness(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	srv := httptest.NewServer(NewWitnessServer(logger))
+	defer srv.Close()
+
+	primary := NewWitnessClient(srv.URL, "dc1", "primary", time.Second)
+	other := NewWitnessClient(srv.URL, "dc2", "secondary", time.Second)
+
+	ages, err := primary.Report(context.Background())
+	if err != nil {
+		t.Fatalf("Report error: %v", err)
+	}
+	if _, ok := ages["primary"]; !ok || len(ages) != 1 {
+		t.Errorf("ages = %v, want only primary", ages)
+	}
+
+	// Groups are kept apart
+	if ages, _ := other.Report(context.Background()); len(ages) != 1 {
+		t.Errorf("dc2 ages = %v, want only its own node", ages)
+	}
+}
+
+func TestSplitBrainGuard(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	srv := httptest.NewServer(NewWitnessServer(logger))
+	defer srv.Close()
+
+	cfg := &config.HAConfig{
+		Role:            "primary",
+		FailoverTimeout: "10s",
+		SplitBrain: config.HASplitBrainConfig{
+			Witness:      srv.URL,
+			Group:        "dc1",
+			Gateways:     []string{"192.168.1.1", "192.168.2.1"},
+			CheckTimeout: "1s",
+		},
+	}
+	pinger := testPinger{}
+	guard := NewSplitBrainGuard(cfg, pinger, logger)
+	ctx := context.Background()
+
+	if ok, _ := guard.MayTakeOver(ctx); ok {
+		t.Error("takeover allowed with no gateway reachable")
+	}
+
+	pinger["192.168.2.1"] = true
+	if ok, why := guard.MayTakeOver(ctx); !ok {
+		t.Errorf("takeover refused with peer silent at the witness: %s", why)
+	}
+
+	// The peer still reports to the witness — only the sync link is down
+	NewWitnessClient(srv.URL, "dc1", "secondary", time.Second).Report(ctx)
+	if ok, _ := guard.MayTakeOver(ctx); ok {
+		t.Error("takeover allowed while the witness hears from the peer")
+	}
+
+	srv.Close()
+	if ok, _ := guard.MayTakeOver(ctx); ok {
+		t.Error("takeover allowed with the witness unreachable")
+	}
+}
@@ -0,0 +1,147 @@
+package lease
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// maxTombstones bounds the deletion history kept for incremental sync.
+// A peer further behind than this gets a full bulk sync instead.
+const maxTombstones = 10000
+
+// Meta bucket keys.
+var (
+	keySeq       = []byte("seq")
+	keyTombFloor = []byte("tombstone_floor")
+	syncMarkPfx  = "sync_mark/"
+)
+
+// seqKey encodes a sequence number as a sortable bucket key.
+func seqKey(seq uint64) []byte {
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], seq)
+	return k[:]
+}
+
+// loadTombstoneState restores the tombstone floor and count and raises the
+// sequence counter past any deletion recorded before the last shutdown.
+func (s *Store) loadTombstoneState(tx *bolt.Tx) error {
+	meta := tx.Bucket(bucketMeta)
+	if v := meta.Get(keySeq); len(v) == 8 {
+		s.seq = max(s.seq, binary.BigEndian.Uint64(v))
+	}
+	if v := meta.Get(keyTombFloor); len(v) == 8 {
+		s.tombFloor = binary.BigEndian.Uint64(v)
+	}
+	s.tombCount = tx.Bucket(bucketTombstones).Stats().KeyN
+	return nil
+}
+
+// putTombstone records the deletion of l at seq inside a write transaction,
+// pruning the oldest tombstone once more than maxTombstones are kept.
+// Returns the new floor (0 if nothing was pruned).
+func (s *Store) putTombstone(tx *bolt.Tx, l *Lease, seq uint64) (uint64, error) {
+	t := l.Clone()
+	t.State = dhcpv4.LeaseStateReleased
+	t.UpdateSeq = seq
+	t.LastUpdated = time.Now()
+	data, err := json.Marshal(t)
+	if err != nil {
+		return 0, fmt.Errorf("marshalling tombstone for %s: %w", l.IP, err)
+	}
+
+	b := tx.Bucket(bucketTombstones)
+	if err := b.Put(seqKey(seq), data); err != nil {
+		return 0, fmt.Errorf("writing tombstone for %s: %w", l.IP, err)
+	}
+	meta := tx.Bucket(bucketMeta)
+	if err := meta.Put(keySeq, seqKey(seq)); err != nil {
+		return 0, fmt.Errorf("writing lease sequence: %w", err)
+	}
+	s.tombCount++
+
+	var floor uint64
+	for s.tombCount > maxTombstones {
+		k, _ := b.Cursor().First()
+		if k == nil {
+			break
+		}
+		floor = binary.BigEndian.Uint64(k)
+		if err := b.Delete(k); err != nil {
+			return 0, fmt.Errorf("pruning tombstone: %w", err)
+		}
+		s.tombCount--
+	}
+	if floor > 0 {
+		if err := meta.Put(keyTombFloor, seqKey(floor)); err != nil {
+			return 0, fmt.Errorf("writing tombstone floor: %w", err)
+		}
+	}
+	return floor, nil
+}
+
+// ChangesSince returns every active or expired-reclaimed lease and every
+// deletion with a sequence number above seq, in sequence order. Deletions come back as
+// leases in the released state. ok is false when the history no longer
+// reaches back to seq (or seq is ahead of this store, e.g. after the
+// database was recreated) and the caller must fall back to a full sync.
+func (s *Store) ChangesSince(seq uint64) (changes []*Lease, ok bool) {
+	s.mu.RLock()
+	cur, floor := s.seq, s.tombFloor
+	if seq > cur || seq < floor {
+		s.mu.RUnlock()
+		return nil, false
+	}
+	for _, l := range s.byIP {
+		if l.UpdateSeq > seq && (l.State == dhcpv4.LeaseStateActive || l.State == dhcpv4.LeaseStateReclaimed) {
+			changes = append(changes, l.Clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTombstones).Cursor()
+		for k, v := c.Seek(seqKey(seq + 1)); k != nil; k, v = c.Next() {
+			t := &Lease{}
+			if err := json.Unmarshal(v, t); err != nil {
+				return fmt.Errorf("unmarshalling tombstone: %w", err)
+			}
+			changes = append(changes, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].UpdateSeq < changes[j].UpdateSeq })
+	return changes, true
+}
+
+// SyncMark returns a sequence number recorded under name with
+// SetSyncMark (0 if none). HA uses these to remember how far each peer
+// has synced across restarts.
+func (s *Store) SyncMark(name string) uint64 {
+	var seq uint64
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get([]byte(syncMarkPfx + name)); len(v) == 8 {
+			seq = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return seq
+}
+
+// SetSyncMark records a sequence number under name.
+func (s *Store) SetSyncMark(name string, seq uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put([]byte(syncMarkPfx+name), seqKey(seq))
+	})
+}
@@ -16,25 +16,155 @@ import (
 
 // Manager handles lease allocation, renewal, release, and expiry.
 type Manager struct {
-	store  *Store
+	store  Storage
 	cfg    *config.Config
 	bus    *events.Bus
 	logger *slog.Logger
 	mu     sync.Mutex
+
+	onChange func(*Lease)                        // HA lease sync hook
+	onFreed  func(l *Lease, holdUntil time.Time) // pool bitmap hook
+
+	offerScans map[string]offerScan // subnet -> last ReclaimOffers scan
+}
+
+// offerScan remembers when ReclaimOffers can next find something in a
+// subnet: no offer there is old enough before next.
+type offerScan struct {
+	olderThan time.Duration
+	next      time.Time
 }
 
 // NewManager creates a new lease manager.
-func NewManager(store *Store, cfg *config.Config, bus *events.Bus, logger *slog.Logger) *Manager {
+func NewManager(store Storage, cfg *config.Config, bus *events.Bus, logger *slog.Logger) *Manager {
 	return &Manager{
 		store:  store,
 		cfg:    cfg,
 		bus:    bus,
 		logger: logger,
+
+		offerScans: make(map[string]offerScan),
+	}
+}
+
+// OnLeaseChange sets a callback run for every binding this server
+// confirms, releases, declines or expires. It receives a copy of the lease
+// with State set to the outcome and must not block.
+func (m *Manager) OnLeaseChange(fn func(*Lease)) {
+	m.onChange = fn
+}
+
+// notifyChange passes a copy of l in the given state to the change hook.
+func (m *Manager) notifyChange(l *Lease, state dhcpv4.LeaseState) {
+	if m.onChange == nil || l == nil {
+		return
+	}
+	c := l.Clone()
+	c.State = state
+	m.onChange(c)
+}
+
+// OnAddressFreed sets a callback run when a DHCPv4 lease ends by expiry
+// or release, so the address can go back to its pool. holdUntil is the end
+// of the subnet's affinity_period, during which the address is kept for
+// the same client, or zero when anyone may have it now. Must not block.
+func (m *Manager) OnAddressFreed(fn func(l *Lease, holdUntil time.Time)) {
+	m.onFreed = fn
+}
+
+// notifyFreed passes l to the address freed hook.
+func (m *Manager) notifyFreed(l *Lease, holdUntil time.Time) {
+	if m.onFreed == nil || l.IsV6() {
+		return
 	}
+	m.onFreed(l, holdUntil)
+}
+
+// retire removes a lease that ended at the given time. An active DHCPv4
+// lease in a subnet with an affinity_period is kept instead, as an
+// expired-reclaimed record that holds the address for the same client
+// until the period is over; that record is returned (nil otherwise).
+func (m *Manager) retire(l *Lease, ended time.Time) (*Lease, error) {
+	affinity := m.cfg.AffinityPeriod(l.Subnet)
+	if affinity <= 0 || l.IsV6() || l.State != dhcpv4.LeaseStateActive {
+		if err := m.store.Delete(l.IP); err != nil {
+			return nil, err
+		}
+		m.notifyFreed(l, time.Time{})
+		return nil, nil
+	}
+
+	r := l.Clone()
+	r.State = dhcpv4.LeaseStateReclaimed
+	r.Expiry = ended.Add(affinity)
+	r.LastUpdated = time.Now()
+	seq, err := m.store.NextSeq()
+	if err != nil {
+		return nil, err
+	}
+	r.UpdateSeq = seq
+	if err := m.store.Put(r); err != nil {
+		return nil, err
+	}
+	metrics.LeaseOperations.WithLabelValues("reclaim").Inc()
+	m.notifyFreed(r, r.Expiry)
+	return r, nil
+}
+
+// ApplyPeerLease merges a binding received from the HA peer. The copy with
+// the later start time wins: an active or expired-reclaimed binding
+// replaces an older local one, and a released, declined or expired binding
+// removes the local one unless it was renewed here since. No events are
+// published — the peer already published them. Reports whether the local
+// store changed.
+func (m *Manager) ApplyPeerLease(l *Lease) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.store.GetByIP(l.IP)
+	if existing != nil && existing.Start.After(l.Start) {
+		return false, nil
+	}
+
+	if l.State != dhcpv4.LeaseStateActive && l.State != dhcpv4.LeaseStateReclaimed {
+		if existing == nil {
+			return false, nil
+		}
+		if err := m.store.Delete(l.IP); err != nil {
+			return false, fmt.Errorf("removing peer lease %s: %w", l.IP, err)
+		}
+		if existing.State == dhcpv4.LeaseStateActive {
+			metrics.LeasesActive.Dec()
+		}
+		return true, nil
+	}
+
+	if existing != nil && existing.State == l.State &&
+		existing.Start.Equal(l.Start) && existing.Expiry.Equal(l.Expiry) {
+		return false, nil
+	}
+
+	c := l.Clone()
+	seq, err := m.store.NextSeq()
+	if err != nil {
+		return false, fmt.Errorf("storing peer lease %s: %w", l.IP, err)
+	}
+	c.UpdateSeq = seq
+	if err := m.store.Put(c); err != nil {
+		return false, fmt.Errorf("storing peer lease %s: %w", l.IP, err)
+	}
+	wasActive := existing != nil && existing.State == dhcpv4.LeaseStateActive
+	switch {
+	case l.State == dhcpv4.LeaseStateActive && !wasActive:
+		metrics.LeasesActive.Inc()
+	case l.State == dhcpv4.LeaseStateReclaimed && wasActive:
+		metrics.LeasesActive.Dec()
+	}
+	return true, nil
 }
 
 // Store returns the underlying lease store.
-func (m *Manager) Store() *Store {
+func (m *Manager) Store() Storage {
 	return m.store
 }
 
@@ -68,13 +198,29 @@ func (m *Manager) FindReservation(clientID string, mac net.HardwareAddr, subnetI
 	return nil
 }
 
-// CreateOffer creates a new lease in the "offered" state.
+// CreateOffer creates a new lease in the "offered" state. Offering an
+// address held by an expired-reclaimed lease ends that lease's affinity.
 func (m *Manager) CreateOffer(ip net.IP, mac net.HardwareAddr, clientID, hostname, subnet, pool string,
 	leaseTime time.Duration, relayInfo *RelayInfo) (*Lease, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	now := time.Now()
+	if existing := m.store.GetByIP(ip); existing != nil && existing.State == dhcpv4.LeaseStateReclaimed {
+		if existing.MAC.String() == mac.String() {
+			metrics.LeaseOperations.WithLabelValues("reuse").Inc()
+		} else {
+			metrics.LeaseOperations.WithLabelValues("evict").Inc()
+			m.logger.Info("held address given to another client — pool under pressure",
+				"ip", ip.String(),
+				"mac", mac.String(),
+				"held_for", existing.MAC.String())
+		}
+	}
+	seq, err := m.store.NextSeq()
+	if err != nil {
+		return nil, fmt.Errorf("creating offer for %s: %w", ip, err)
+	}
 	l := &Lease{
 		IP:          ip,
 		MAC:         mac,
@@ -86,7 +232,7 @@ func (m *Manager) CreateOffer(ip net.IP, mac net.HardwareAddr, clientID, hostnam
 		Start:       now,
 		Expiry:      now.Add(leaseTime),
 		LastUpdated: now,
-		UpdateSeq:   m.store.NextSeq(),
+		UpdateSeq:   seq,
 		RelayInfo:   relayInfo,
 	}
 
@@ -123,6 +269,10 @@ func (m *Manager) ConfirmLease(ip net.IP, mac net.HardwareAddr, clientID, hostna
 
 	isRenew := existing != nil && existing.State == dhcpv4.LeaseStateActive
 
+	seq, err := m.store.NextSeq()
+	if err != nil {
+		return nil, fmt.Errorf("confirming lease for %s: %w", ip, err)
+	}
 	l := &Lease{
 		IP:          ip,
 		MAC:         mac,
@@ -134,7 +284,7 @@ func (m *Manager) ConfirmLease(ip net.IP, mac net.HardwareAddr, clientID, hostna
 		Start:       now,
 		Expiry:      now.Add(leaseTime),
 		LastUpdated: now,
-		UpdateSeq:   m.store.NextSeq(),
+		UpdateSeq:   seq,
 		RelayInfo:   relayInfo,
 	}
 
@@ -165,11 +315,74 @@ func (m *Manager) ConfirmLease(ip net.IP, mac net.HardwareAddr, clientID, hostna
 		Timestamp: now,
 		Lease:     m.leaseToEventData(l),
 	})
+	m.notifyChange(l, dhcpv4.LeaseStateActive)
 
 	return l, nil
 }
 
-// Release handles a DHCPRELEASE — marks the lease as released and removes it.
+// FindLease6 looks up the DHCPv6 binding for a client's IA — the delegated
+// prefix if prefix is true, otherwise the address.
+func (m *Manager) FindLease6(duid string, iaid uint32, prefix bool) *Lease {
+	return m.store.GetByDUID(duid, iaid, prefix)
+}
+
+// ConfirmLease6 activates a DHCPv6 binding (RFC 8415 §18.3.2). DHCPv6 has no
+// offered state: the binding is created when the server sends a Reply.
+// tmpl carries the client identity, address or prefix, subnet and pool;
+// state, timestamps and sequence are filled in here.
+func (m *Manager) ConfirmLease6(tmpl *Lease, validLifetime time.Duration) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	existing := m.store.GetByDUID(tmpl.DUID, tmpl.IAID, tmpl.IsPrefix())
+	isRenew := existing != nil && existing.IP.Equal(tmpl.IP) && existing.State == dhcpv4.LeaseStateActive
+
+	l := tmpl.Clone()
+	l.State = dhcpv4.LeaseStateActive
+	l.Start = now
+	l.Expiry = now.Add(validLifetime)
+	l.LastUpdated = now
+	seq, err := m.store.NextSeq()
+	if err != nil {
+		return nil, fmt.Errorf("confirming DHCPv6 lease for %s: %w", l.IP, err)
+	}
+	l.UpdateSeq = seq
+
+	if err := m.store.Put(l); err != nil {
+		return nil, fmt.Errorf("confirming DHCPv6 lease for %s: %w", l.IP, err)
+	}
+
+	eventType := events.EventLeaseAck
+	if isRenew {
+		metrics.LeaseOperations.WithLabelValues("renew").Inc()
+		eventType = events.EventLeaseRenew
+	} else {
+		metrics.LeaseOperations.WithLabelValues("ack").Inc()
+		metrics.LeasesActive.Inc()
+	}
+
+	m.logger.Info("DHCPv6 lease confirmed",
+		"ip", l.IP.String(),
+		"prefix_len", l.PrefixLen,
+		"duid", l.DUID,
+		"iaid", l.IAID,
+		"subnet", l.Subnet,
+		"is_renew", isRenew,
+		"msg_type", "REPLY")
+
+	m.bus.Publish(events.Event{
+		Type:      eventType,
+		Timestamp: now,
+		Lease:     m.leaseToEventData(l),
+	})
+	m.notifyChange(l, dhcpv4.LeaseStateActive)
+
+	return l, nil
+}
+
+// Release handles a DHCPRELEASE — marks the lease as released and removes
+// it, or keeps it as expired-reclaimed during the subnet's affinity_period.
 func (m *Manager) Release(ip net.IP, mac net.HardwareAddr) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -179,6 +392,7 @@ func (m *Manager) Release(ip net.IP, mac net.HardwareAddr) error {
 		m.logger.Warn("release for unknown lease",
 			"ip", ip.String(),
 			"mac", mac.String())
+		m.notifyFreed(&Lease{IP: ip, MAC: mac}, time.Time{})
 		return nil
 	}
 
@@ -192,16 +406,20 @@ func (m *Manager) Release(ip net.IP, mac net.HardwareAddr) error {
 
 	eventData := m.leaseToEventData(l)
 
-	if err := m.store.Delete(ip); err != nil {
+	kept, err := m.retire(l, time.Now())
+	if err != nil {
 		return fmt.Errorf("releasing lease for %s: %w", ip, err)
 	}
 
 	metrics.LeaseOperations.WithLabelValues("release").Inc()
-	metrics.LeasesActive.Dec()
+	if l.State == dhcpv4.LeaseStateActive {
+		metrics.LeasesActive.Dec()
+	}
 
 	m.logger.Info("lease released",
 		"ip", ip.String(),
 		"mac", mac.String(),
+		"held", kept != nil,
 		"msg_type", "DHCPRELEASE")
 
 	m.bus.Publish(events.Event{
@@ -209,6 +427,11 @@ func (m *Manager) Release(ip net.IP, mac net.HardwareAddr) error {
 		Timestamp: time.Now(),
 		Lease:     eventData,
 	})
+	if kept != nil {
+		m.notifyChange(kept, dhcpv4.LeaseStateReclaimed)
+	} else {
+		m.notifyChange(l, dhcpv4.LeaseStateReleased)
+	}
 
 	return nil
 }
@@ -247,11 +470,68 @@ func (m *Manager) Decline(ip net.IP, mac net.HardwareAddr) error {
 		Timestamp: time.Now(),
 		Lease:     eventData,
 	})
+	m.notifyChange(l, dhcpv4.LeaseStateDeclined)
 
 	return nil
 }
 
-// ExpireLeases finds and removes expired leases. Called by the GC goroutine.
+// ReclaimOffers removes the offers in a subnet that have gone unanswered
+// for longer than olderThan, handing their addresses back to the pools,
+// and returns how many it removed. Used when a pool runs out: an offer
+// otherwise keeps its address for a whole lease time.
+//
+// Every DISCOVER into a full pool calls this, so the lease table is only
+// scanned once the oldest remaining offer in the subnet can have gone
+// stale; offers made since the last scan are newer still.
+func (m *Manager) ReclaimOffers(subnet string, olderThan time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := m.offerScans[subnet]; ok && last.olderThan == olderThan && now.Before(last.next) {
+		return 0
+	}
+
+	cutoff := now.Add(-olderThan)
+	next := now.Add(olderThan)
+	var stale []*Lease
+	m.store.ForEach(func(l *Lease) bool {
+		if l.Subnet != subnet || l.State != dhcpv4.LeaseStateOffered {
+			return true
+		}
+		if l.Start.Before(cutoff) {
+			stale = append(stale, l.Clone())
+		} else if due := l.Start.Add(olderThan); due.Before(next) {
+			next = due
+		}
+		return true
+	})
+	m.offerScans[subnet] = offerScan{olderThan: olderThan, next: next}
+
+	n := 0
+	for _, l := range stale {
+		if err := m.store.Delete(l.IP); err != nil {
+			m.logger.Error("failed to reclaim offer",
+				"ip", l.IP.String(),
+				"error", err)
+			continue
+		}
+		n++
+		metrics.LeaseOperations.WithLabelValues("offer_reclaim").Inc()
+		metrics.LeasesOffered.Dec()
+		m.logger.Info("unanswered offer reclaimed",
+			"ip", l.IP.String(),
+			"mac", l.MAC.String(),
+			"subnet", subnet)
+		m.notifyFreed(l, time.Time{})
+		m.notifyChange(l, dhcpv4.LeaseStateExpired)
+	}
+	return n
+}
+
+// ExpireLeases finds and removes expired leases — or keeps them as
+// expired-reclaimed during the subnet's affinity_period — and removes
+// expired-reclaimed leases whose period is over. Called by the GC goroutine.
 func (m *Manager) ExpireLeases() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -264,15 +544,35 @@ func (m *Manager) ExpireLeases() int {
 		return true
 	})
 
+	n := 0
 	for _, l := range expired {
+		if l.State == dhcpv4.LeaseStateReclaimed {
+			// The client did not come back: the address is anyone's now
+			if err := m.store.Delete(l.IP); err != nil {
+				m.logger.Error("failed to delete expired-reclaimed lease",
+					"ip", l.IP.String(),
+					"error", err)
+				continue
+			}
+			m.logger.Debug("lease affinity ended",
+				"ip", l.IP.String(),
+				"mac", l.MAC.String(),
+				"subnet", l.Subnet)
+			m.notifyFreed(l, time.Time{})
+			m.notifyChange(l, dhcpv4.LeaseStateExpired)
+			continue
+		}
+
 		eventData := m.leaseToEventData(l)
 
-		if err := m.store.Delete(l.IP); err != nil {
+		kept, err := m.retire(l, l.Expiry)
+		if err != nil {
 			m.logger.Error("failed to delete expired lease",
 				"ip", l.IP.String(),
 				"error", err)
 			continue
 		}
+		n++
 
 		metrics.LeaseOperations.WithLabelValues("expire").Inc()
 		metrics.LeasesActive.Dec()
@@ -280,31 +580,40 @@ func (m *Manager) ExpireLeases() int {
 		m.logger.Info("lease expired",
 			"ip", l.IP.String(),
 			"mac", l.MAC.String(),
-			"subnet", l.Subnet)
+			"subnet", l.Subnet,
+			"held", kept != nil)
 
 		m.bus.Publish(events.Event{
 			Type:      events.EventLeaseExpire,
 			Timestamp: time.Now(),
 			Lease:     eventData,
 		})
+		if kept != nil {
+			m.notifyChange(kept, dhcpv4.LeaseStateReclaimed)
+		} else {
+			m.notifyChange(l, dhcpv4.LeaseStateExpired)
+		}
 	}
 
-	return len(expired)
+	return n
 }
 
 // leaseToEventData converts a lease to event payload.
 func (m *Manager) leaseToEventData(l *Lease) *events.LeaseData {
 	d := &events.LeaseData{
-		IP:       l.IP,
-		MAC:      l.MAC.String(),
-		ClientID: l.ClientID,
-		Hostname: l.Hostname,
-		FQDN:     l.FQDN,
-		Subnet:   l.Subnet,
-		Pool:     l.Pool,
-		Start:    l.Start.Unix(),
-		Expiry:   l.Expiry.Unix(),
-		State:    string(l.State),
+		IP:        l.IP,
+		MAC:       l.MAC.String(),
+		ClientID:  l.ClientID,
+		DUID:      l.DUID,
+		IAID:      l.IAID,
+		PrefixLen: l.PrefixLen,
+		Hostname:  l.Hostname,
+		FQDN:      l.FQDN,
+		Subnet:    l.Subnet,
+		Pool:      l.Pool,
+		Start:     l.Start.Unix(),
+		Expiry:    l.Expiry.Unix(),
+		State:     string(l.State),
 	}
 	if l.RelayInfo != nil {
 		d.Relay = &events.RelayData{
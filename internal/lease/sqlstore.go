@@ -0,0 +1,410 @@
+package lease
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, registered as "sqlite"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// sqlMigrations upgrade the SQL schema; entry i brings it to version i+1.
+// Append only — never edit a migration that has shipped.
+var sqlMigrations = []string{
+	// 1: leases, deletion history, sequence counter and sync marks
+	`CREATE TABLE leases (
+		ip         TEXT PRIMARY KEY,
+		mac        TEXT NOT NULL DEFAULT '',
+		client_id  TEXT NOT NULL DEFAULT '',
+		hostname   TEXT NOT NULL DEFAULT '',
+		v6_key     TEXT NOT NULL DEFAULT '',
+		subnet     TEXT NOT NULL DEFAULT '',
+		state      TEXT NOT NULL,
+		start_time TEXT NOT NULL,
+		expiry     TEXT NOT NULL,
+		update_seq INTEGER NOT NULL,
+		data       TEXT NOT NULL
+	);
+	CREATE INDEX leases_mac ON leases (mac);
+	CREATE INDEX leases_client_id ON leases (client_id);
+	CREATE INDEX leases_hostname ON leases (hostname);
+	CREATE INDEX leases_v6_key ON leases (v6_key);
+	CREATE INDEX leases_update_seq ON leases (update_seq);
+	CREATE TABLE lease_tombstones (
+		seq  INTEGER PRIMARY KEY,
+		data TEXT NOT NULL
+	);
+	CREATE TABLE lease_meta (
+		name  TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);
+	INSERT INTO lease_meta (name, value) VALUES ('seq', 0), ('tombstone_floor', 0);
+	CREATE TABLE lease_sync_marks (
+		name TEXT PRIMARY KEY,
+		seq  INTEGER NOT NULL
+	);`,
+}
+
+// SQLStore keeps leases in an SQLite database. Unlike Store it has no
+// in-memory copy: every lookup reads the database, so several servers
+// and reporting tools can share the file. Besides the JSON-encoded lease
+// in data, the leases table has the fields worth querying as columns.
+type SQLStore struct {
+	db     *sql.DB
+	shared *boltdb.Handle // server state besides leases; nil when opened by OpenBackend
+}
+
+// NewSQLiteStore opens or creates the SQLite lease database at path and
+// brings its schema up to date. shared is the BoltDB file returned by DB;
+// the store closes it on Close.
+func NewSQLiteStore(path string, shared *boltdb.Handle) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("opening lease database %s: %w", path, err)
+	}
+	s := &SQLStore{db: db, shared: shared}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating lease database %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// sqliteDSN adds the connection settings every connection needs: wait for
+// other writers instead of failing, WAL so readers don't block the writer,
+// and write transactions that take the lock up front.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+}
+
+// migrate applies the migrations the database hasn't seen yet, each in
+// its own transaction.
+func (s *SQLStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqlMigrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, len(sqlMigrations))
+	}
+	for v := version + 1; v <= len(sqlMigrations); v++ {
+		err := s.tx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqlMigrations[v-1]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+				v, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version the database is at.
+func (s *SQLStore) SchemaVersion() (int, error) {
+	var v int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// tx runs fn in a transaction, committing if it returns nil.
+func (s *SQLStore) tx(fn func(*sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the SQLite database and the shared BoltDB file.
+func (s *SQLStore) Close() error {
+	err := s.db.Close()
+	if s.shared != nil {
+		if cerr := s.shared.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// DB returns the BoltDB file holding the rest of the server's state.
+func (s *SQLStore) DB() *boltdb.Handle {
+	return s.shared
+}
+
+// sqlTime formats a time for the text columns; it sorts chronologically.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Put creates or updates a lease.
+func (s *SQLStore) Put(l *Lease) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("marshalling lease for %s: %w", l.IP, err)
+	}
+	ip := l.IP.String()
+	var mac, v6 string
+	if l.IsV6() {
+		v6 = l.v6Key()
+	} else {
+		mac = l.MAC.String()
+	}
+
+	return s.tx(func(tx *sql.Tx) error {
+		// A client holds one binding: drop the one it is moving away from
+		if l.IsV6() {
+			_, err = tx.Exec(`DELETE FROM leases WHERE v6_key = ? AND ip <> ?`, v6, ip)
+		} else {
+			_, err = tx.Exec(`DELETE FROM leases WHERE mac = ? AND v6_key = '' AND ip <> ?`, mac, ip)
+		}
+		if err != nil {
+			return fmt.Errorf("replacing previous lease for %s: %w", l.IP, err)
+		}
+		_, err = tx.Exec(`INSERT INTO leases
+			(ip, mac, client_id, hostname, v6_key, subnet, state, start_time, expiry, update_seq, data)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (ip) DO UPDATE SET
+				mac = excluded.mac, client_id = excluded.client_id, hostname = excluded.hostname,
+				v6_key = excluded.v6_key, subnet = excluded.subnet, state = excluded.state,
+				start_time = excluded.start_time, expiry = excluded.expiry,
+				update_seq = excluded.update_seq, data = excluded.data`,
+			ip, mac, l.ClientID, l.Hostname, v6, l.Subnet, string(l.State),
+			sqlTime(l.Start), sqlTime(l.Expiry), l.UpdateSeq, string(data))
+		if err != nil {
+			return fmt.Errorf("writing lease for %s: %w", l.IP, err)
+		}
+		// Sequence numbers never go backwards, even for leases copied in
+		_, err = tx.Exec(`UPDATE lease_meta SET value = MAX(value, ?) WHERE name = 'seq'`, l.UpdateSeq)
+		return err
+	})
+}
+
+// Delete removes a lease by IP.
+func (s *SQLStore) Delete(ip net.IP) error {
+	return s.tx(func(tx *sql.Tx) error {
+		var data string
+		err := tx.QueryRow(`SELECT data FROM leases WHERE ip = ?`, ip.String()).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading lease for %s: %w", ip, err)
+		}
+		l := &Lease{}
+		if err := json.Unmarshal([]byte(data), l); err != nil {
+			return fmt.Errorf("unmarshalling lease %s: %w", ip, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM leases WHERE ip = ?`, ip.String()); err != nil {
+			return fmt.Errorf("deleting lease for %s: %w", ip, err)
+		}
+		return putSQLTombstone(tx, l)
+	})
+}
+
+// putSQLTombstone records the deletion of l under the next sequence
+// number, pruning the oldest tombstones beyond maxTombstones.
+func putSQLTombstone(tx *sql.Tx, l *Lease) error {
+	var seq uint64
+	if err := tx.QueryRow(`UPDATE lease_meta SET value = value + 1 WHERE name = 'seq' RETURNING value`).Scan(&seq); err != nil {
+		return fmt.Errorf("advancing lease sequence: %w", err)
+	}
+	t := l.Clone()
+	t.State = dhcpv4.LeaseStateReleased
+	t.UpdateSeq = seq
+	t.LastUpdated = time.Now()
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshalling tombstone for %s: %w", l.IP, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO lease_tombstones (seq, data) VALUES (?, ?)`, seq, string(data)); err != nil {
+		return fmt.Errorf("writing tombstone for %s: %w", l.IP, err)
+	}
+
+	var floor sql.NullInt64
+	err = tx.QueryRow(`SELECT seq FROM lease_tombstones ORDER BY seq DESC LIMIT 1 OFFSET ?`, maxTombstones).Scan(&floor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("pruning tombstones: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM lease_tombstones WHERE seq <= ?`, floor.Int64); err != nil {
+		return fmt.Errorf("pruning tombstones: %w", err)
+	}
+	_, err = tx.Exec(`UPDATE lease_meta SET value = MAX(value, ?) WHERE name = 'tombstone_floor'`, floor.Int64)
+	return err
+}
+
+// queryLeases decodes the data column of every row a query returns.
+func (s *SQLStore) queryLeases(query string, args ...any) ([]*Lease, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var leases []*Lease
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		l := &Lease{}
+		if err := json.Unmarshal([]byte(data), l); err != nil {
+			return nil, fmt.Errorf("unmarshalling lease: %w", err)
+		}
+		leases = append(leases, l)
+	}
+	return leases, rows.Err()
+}
+
+// getOne returns the first lease a query finds, or nil.
+func (s *SQLStore) getOne(query string, args ...any) *Lease {
+	leases, err := s.queryLeases(query+` LIMIT 1`, args...)
+	if err != nil || len(leases) == 0 {
+		return nil
+	}
+	return leases[0]
+}
+
+// GetByIP returns a lease by IP address.
+func (s *SQLStore) GetByIP(ip net.IP) *Lease {
+	return s.getOne(`SELECT data FROM leases WHERE ip = ?`, ip.String())
+}
+
+// GetByMAC returns the DHCPv4 lease held by a MAC address.
+func (s *SQLStore) GetByMAC(mac net.HardwareAddr) *Lease {
+	return s.getOne(`SELECT data FROM leases WHERE mac = ? AND v6_key = '' ORDER BY update_seq DESC`, mac.String())
+}
+
+// GetByClientID returns a DHCPv4 lease by client identifier.
+func (s *SQLStore) GetByClientID(clientID string) *Lease {
+	if clientID == "" {
+		return nil
+	}
+	return s.getOne(`SELECT data FROM leases WHERE client_id = ? AND v6_key = '' ORDER BY update_seq DESC`, clientID)
+}
+
+// GetByHostname returns the most recently updated DHCPv4 lease for a hostname.
+func (s *SQLStore) GetByHostname(hostname string) *Lease {
+	if hostname == "" {
+		return nil
+	}
+	return s.getOne(`SELECT data FROM leases WHERE hostname = ? AND v6_key = '' ORDER BY update_seq DESC`, hostname)
+}
+
+// GetByDUID returns the DHCPv6 lease bound to a DUID and IAID — the IA_PD
+// binding if prefix is true, otherwise the IA_NA one.
+func (s *SQLStore) GetByDUID(duid string, iaid uint32, prefix bool) *Lease {
+	return s.getOne(`SELECT data FROM leases WHERE v6_key = ?`, v6Key(duid, iaid, prefix))
+}
+
+// All returns all leases.
+func (s *SQLStore) All() []*Lease {
+	leases, _ := s.queryLeases(`SELECT data FROM leases`)
+	if leases == nil {
+		leases = []*Lease{}
+	}
+	return leases
+}
+
+// Count returns the total number of leases.
+func (s *SQLStore) Count() int {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM leases`).Scan(&n)
+	return n
+}
+
+// ForEach iterates over all leases with a callback. The leases are read
+// up front, so fn may call back into the store.
+func (s *SQLStore) ForEach(fn func(*Lease) bool) {
+	for _, l := range s.All() {
+		if !fn(l) {
+			return
+		}
+	}
+}
+
+// CurrentSeq returns the last sequence number handed out.
+func (s *SQLStore) CurrentSeq() (uint64, error) {
+	var seq uint64
+	if err := s.db.QueryRow(`SELECT value FROM lease_meta WHERE name = 'seq'`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("reading lease sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// NextSeq returns and increments the sequence counter, which is shared by
+// every server using the database.
+func (s *SQLStore) NextSeq() (uint64, error) {
+	var seq uint64
+	if err := s.db.QueryRow(`UPDATE lease_meta SET value = value + 1 WHERE name = 'seq' RETURNING value`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("advancing lease sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// ChangesSince returns every active or expired-reclaimed lease and every
+// deletion with a sequence number above seq, in sequence order (see
+// Store.ChangesSince).
+func (s *SQLStore) ChangesSince(seq uint64) (changes []*Lease, ok bool) {
+	var cur, floor uint64
+	err := s.db.QueryRow(`SELECT
+		(SELECT value FROM lease_meta WHERE name = 'seq'),
+		(SELECT value FROM lease_meta WHERE name = 'tombstone_floor')`).Scan(&cur, &floor)
+	if err != nil || seq > cur || seq < floor {
+		return nil, false
+	}
+	active, err := s.queryLeases(`SELECT data FROM leases WHERE update_seq > ? AND state IN (?, ?)`,
+		seq, string(dhcpv4.LeaseStateActive), string(dhcpv4.LeaseStateReclaimed))
+	if err != nil {
+		return nil, false
+	}
+	deleted, err := s.queryLeases(`SELECT data FROM lease_tombstones WHERE seq > ?`, seq)
+	if err != nil {
+		return nil, false
+	}
+	changes = append(active, deleted...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].UpdateSeq < changes[j].UpdateSeq })
+	return changes, true
+}
+
+// SyncMark returns a sequence number recorded under name with
+// SetSyncMark (0 if none).
+func (s *SQLStore) SyncMark(name string) uint64 {
+	var seq uint64
+	s.db.QueryRow(`SELECT seq FROM lease_sync_marks WHERE name = ?`, name).Scan(&seq)
+	return seq
+}
+
+// SetSyncMark records a sequence number under name.
+func (s *SQLStore) SetSyncMark(name string, seq uint64) error {
+	_, err := s.db.Exec(`INSERT INTO lease_sync_marks (name, seq) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET seq = excluded.seq`, name, seq)
+	return err
+}
@@ -0,0 +1,194 @@
+package lease
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
+)
+
+// forEachBackend runs fn against a fresh store of every backend. reopen
+// closes the store and opens the same database again.
+func forEachBackend(t *testing.T, fn func(t *testing.T, s Storage, reopen func() Storage)) {
+	for _, backend := range []string{config.LeaseBackendBoltDB, config.LeaseBackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "leases."+backend)
+			open := func() Storage {
+				s, err := OpenBackend(backend, path)
+				if err != nil {
+					t.Fatalf("OpenBackend(%s): %v", backend, err)
+				}
+				t.Cleanup(func() { s.Close() })
+				return s
+			}
+			s := open()
+			fn(t, s, func() Storage {
+				s.Close()
+				return open()
+			})
+		})
+	}
+}
+
+// nextSeq hands out the backend's next sequence number, failing the test
+// if it can't.
+func nextSeq(t testing.TB, s Storage) uint64 {
+	t.Helper()
+	seq, err := s.NextSeq()
+	if err != nil {
+		t.Fatalf("NextSeq: %v", err)
+	}
+	return seq
+}
+
+func testLease(last byte, mac net.HardwareAddr, seq uint64) *Lease {
+	now := time.Now()
+	return &Lease{
+		IP: net.IPv4(192, 168, 1, last), MAC: mac, Subnet: "192.168.1.0/24",
+		State: dhcpv4.LeaseStateActive, Start: now, Expiry: now.Add(time.Hour), LastUpdated: now,
+		UpdateSeq: seq,
+	}
+}
+
+func TestStorageLookups(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage, reopen func() Storage) {
+		mac, _ := net.ParseMAC("00:11:22:33:44:55")
+		l := testLease(100, mac, nextSeq(t, s))
+		l.ClientID = "01:00:11:22:33:44:55"
+		l.Hostname = "printer"
+		if err := s.Put(l); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		na := &Lease{
+			IP: net.ParseIP("2001:db8::100"), MAC: mac, DUID: "00:03:00:01:00:11:22:33:44:55", IAID: 1, Hostname: "printer",
+			State: dhcpv4.LeaseStateActive, Start: l.Start, Expiry: l.Expiry, UpdateSeq: nextSeq(t, s),
+		}
+		if err := s.Put(na); err != nil {
+			t.Fatalf("Put v6: %v", err)
+		}
+
+		for name, got := range map[string]*Lease{
+			"GetByIP":       s.GetByIP(l.IP),
+			"GetByMAC":      s.GetByMAC(mac),
+			"GetByClientID": s.GetByClientID(l.ClientID),
+			"GetByHostname": s.GetByHostname("printer"),
+		} {
+			if got == nil || !got.IP.Equal(l.IP) || got.Hostname != "printer" {
+				t.Errorf("%s = %+v, want the v4 lease", name, got)
+			}
+		}
+		if got := s.GetByDUID(na.DUID, 1, false); got == nil || !got.IP.Equal(na.IP) {
+			t.Errorf("GetByDUID = %+v, want %s", got, na.IP)
+		}
+		if s.GetByIP(net.IPv4(192, 168, 1, 1)) != nil || s.GetByHostname("") != nil {
+			t.Error("lookup of a missing lease returned one")
+		}
+
+		// The client moves to another address: the old lease goes
+		moved := testLease(101, mac, nextSeq(t, s))
+		if err := s.Put(moved); err != nil {
+			t.Fatalf("Put moved: %v", err)
+		}
+		if s.GetByIP(l.IP) != nil {
+			t.Error("old address still leased after the MAC moved")
+		}
+		if s.Count() != 2 || len(s.All()) != 2 {
+			t.Errorf("Count = %d, All = %d, want 2", s.Count(), len(s.All()))
+		}
+
+		s = reopen()
+		if got := s.GetByMAC(mac); got == nil || !got.IP.Equal(moved.IP) {
+			t.Errorf("GetByMAC after reopen = %+v, want %s", got, moved.IP)
+		}
+		if s.GetByIP(l.IP) != nil || s.Count() != 2 {
+			t.Errorf("old address back after reopen, Count = %d", s.Count())
+		}
+		if got, err := s.CurrentSeq(); err != nil || got != 3 {
+			t.Errorf("CurrentSeq after reopen = %d, %v, want 3", got, err)
+		}
+	})
+}
+
+func TestStorageChangesSince(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Storage, reopen func() Storage) {
+		var ips []net.IP
+		for i := byte(10); i < 13; i++ {
+			l := testLease(i, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, i}, nextSeq(t, s))
+			if err := s.Put(l); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			ips = append(ips, l.IP)
+		}
+		if err := s.Delete(ips[1]); err != nil { // seq 4
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.Delete(ips[1]); err != nil {
+			t.Fatalf("Delete of a missing lease: %v", err)
+		}
+		s.SetSyncMark("peer", 42)
+
+		s = reopen()
+		changes, ok := s.ChangesSince(2)
+		if !ok || len(changes) != 2 || changes[0].UpdateSeq != 3 || changes[1].UpdateSeq != 4 {
+			t.Fatalf("ChangesSince(2) = %v, %v, want seq 3 and the deletion at 4", changes, ok)
+		}
+		if !changes[1].IP.Equal(ips[1]) || changes[1].State != dhcpv4.LeaseStateReleased {
+			t.Errorf("deletion = %s %s, want %s released", changes[1].IP, changes[1].State, ips[1])
+		}
+		if _, ok := s.ChangesSince(99); ok {
+			t.Error("ChangesSince past the current seq should require a full sync")
+		}
+		if got := s.SyncMark("peer"); got != 42 {
+			t.Errorf("SyncMark = %d, want 42", got)
+		}
+	})
+}
+
+func TestSQLStoreMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.sqlite")
+	for range 2 { // the second open finds nothing to do
+		s, err := NewSQLiteStore(path, nil)
+		if err != nil {
+			t.Fatalf("NewSQLiteStore: %v", err)
+		}
+		v, err := s.SchemaVersion()
+		s.Close()
+		if err != nil || v != len(sqlMigrations) {
+			t.Fatalf("SchemaVersion = %d, %v, want %d", v, err, len(sqlMigrations))
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	src, err := OpenBackend(config.LeaseBackendBoltDB, filepath.Join(dir, "leases.db"))
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+	for i := byte(1); i <= 5; i++ {
+		src.Put(testLease(i, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, i}, nextSeq(t, src)))
+	}
+
+	dst, err := OpenBackend(config.LeaseBackendSQLite, filepath.Join(dir, "leases.sqlite"))
+	if err != nil {
+		t.Fatalf("open destination: %v", err)
+	}
+	defer dst.Close()
+	n, err := Migrate(dst, src)
+	if err != nil || n != 5 {
+		t.Fatalf("Migrate = %d, %v, want 5", n, err)
+	}
+	if dst.Count() != 5 {
+		t.Errorf("destination Count = %d, want 5", dst.Count())
+	}
+	if got := dst.GetByIP(net.IPv4(192, 168, 1, 3)); got == nil || got.UpdateSeq != 3 {
+		t.Errorf("copied lease = %+v, want update_seq 3", got)
+	}
+	if got, err := dst.NextSeq(); err != nil || got != 6 {
+		t.Errorf("NextSeq after migration = %d, %v, want 6", got, err)
+	}
+}
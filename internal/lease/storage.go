@@ -0,0 +1,133 @@
+package lease
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// Storage persists leases. Store (BoltDB, the default) and SQLStore
+// implement it; everything outside this package works against Storage.
+type Storage interface {
+	// Put creates or updates a lease. A DHCPv4 lease replaces any other
+	// lease held by the same MAC, a DHCPv6 one any other lease for the
+	// same DUID, IAID and IA type.
+	Put(l *Lease) error
+	// Delete removes a lease by IP, recording the deletion for ChangesSince.
+	Delete(ip net.IP) error
+
+	GetByIP(ip net.IP) *Lease
+	GetByMAC(mac net.HardwareAddr) *Lease
+	GetByClientID(clientID string) *Lease
+	GetByHostname(hostname string) *Lease
+	GetByDUID(duid string, iaid uint32, prefix bool) *Lease
+	All() []*Lease
+	Count() int
+	// ForEach calls fn for every lease until it returns false. fn must
+	// not modify the lease.
+	ForEach(fn func(*Lease) bool)
+
+	CurrentSeq() (uint64, error)
+	NextSeq() (uint64, error)
+	ChangesSince(seq uint64) (changes []*Lease, ok bool)
+	SyncMark(name string) uint64
+	SetSyncMark(name string, seq uint64) error
+
+	// DB returns the BoltDB file holding the server's other state (config,
+	// audit log, fingerprints, the HA update queue, ...). With the BoltDB
+	// backend the leases live in it too.
+	DB() *boltdb.Handle
+	Close() error
+}
+
+var (
+	_ Storage = (*Store)(nil)
+	_ Storage = (*SQLStore)(nil)
+)
+
+// Open opens the lease storage configured in [server]: lease_backend
+// selects where leases live, lease_db is always the BoltDB file for the
+// rest of the server's state.
+func Open(cfg config.ServerConfig) (Storage, error) {
+	switch cfg.LeaseBackend {
+	case "", config.LeaseBackendBoltDB:
+		return openStore(cfg.LeaseDB)
+	case config.LeaseBackendSQLite:
+		shared, err := boltdb.Open(cfg.LeaseDB)
+		if err != nil {
+			return nil, fmt.Errorf("opening database %s: %w", cfg.LeaseDB, err)
+		}
+		s, err := NewSQLiteStore(cfg.LeaseDSN, shared)
+		if err != nil {
+			shared.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown lease backend %q", cfg.LeaseBackend)
+}
+
+// openStore is NewStore returning a nil Storage, not a nil *Store, on error.
+func openStore(path string) (Storage, error) {
+	s, err := NewStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DBCheck returns the check a snapshot of lease_db has to pass (see
+// boltdb.Validate): CheckDB when the leases live in it, nil otherwise.
+func DBCheck(cfg config.ServerConfig) func(*bolt.Tx) error {
+	if cfg.LeaseBackend == "" || cfg.LeaseBackend == config.LeaseBackendBoltDB {
+		return CheckDB
+	}
+	return nil
+}
+
+// OpenBackend opens the leases at path in the given backend on their own,
+// without the rest of the server's state; DB returns nil for an SQLite
+// store opened this way. Used by Migrate.
+func OpenBackend(backend, path string) (Storage, error) {
+	switch backend {
+	case config.LeaseBackendBoltDB:
+		return openStore(path)
+	case config.LeaseBackendSQLite:
+		s, err := NewSQLiteStore(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown lease backend %q", backend)
+}
+
+// Migrate copies every lease from src into dst, keeping sequence numbers,
+// and returns how many were copied. Leases already in dst with the same
+// IP are overwritten. Deletion history and HA sync marks are not copied,
+// so HA peers do a full resync afterwards.
+func Migrate(dst, src Storage) (int, error) {
+	n := 0
+	for _, l := range src.All() {
+		if err := dst.Put(l); err != nil {
+			return n, fmt.Errorf("copying lease %s: %w", l.IP, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// SameFile reports whether two lease database paths name the same file.
+func SameFile(a, b string) bool {
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return os.SameFile(fa, fb)
+}
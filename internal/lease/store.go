@@ -6,6 +6,7 @@ import (
 	"net"
 	"sync"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -19,24 +20,29 @@ var (
 	bucketExcluded   = []byte("excluded_ips")
 	bucketMeta       = []byte("meta")
 	bucketEventLog   = []byte("event_log")
+	bucketTombstones = []byte("lease_tombstones")
 )
 
 // Store provides lease persistence via BoltDB with in-memory indexes for O(1) lookup.
 type Store struct {
-	db       *bolt.DB
+	db       *boltdb.Handle
 	mu       sync.RWMutex
 	byIP     map[string]*Lease            // IP string → Lease
 	byMAC    map[string]*Lease            // MAC string → Lease
 	byCID    map[string]*Lease            // Client-ID hex → Lease
 	byHost   map[string]*Lease            // Hostname → Lease
+	byDUID   map[string]*Lease            // DUID|IAID|na/pd → DHCPv6 Lease
 	seq      uint64
+
+	// Deleted leases are kept as tombstones so a peer can be sent only the
+	// changes after a sequence number (see ChangesSince).
+	tombFloor uint64 // tombstones at or below this seq have been pruned
+	tombCount int
 }
 
 // NewStore opens or creates a BoltDB database and initializes the in-memory indexes.
 func NewStore(path string) (*Store, error) {
-	db, err := bolt.Open(path, 0600, &bolt.Options{
-		NoSync: false,
-	})
+	db, err := boltdb.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("opening lease database %s: %w", path, err)
 	}
@@ -46,7 +52,7 @@ func NewStore(path string) (*Store, error) {
 		for _, b := range [][]byte{
 			bucketLeases, bucketIndexMAC, bucketIndexCID,
 			bucketIndexHost, bucketConflicts, bucketExcluded,
-			bucketMeta, bucketEventLog,
+			bucketMeta, bucketEventLog, bucketTombstones,
 		} {
 			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
 				return fmt.Errorf("creating bucket %s: %w", b, err)
@@ -65,6 +71,7 @@ func NewStore(path string) (*Store, error) {
 		byMAC:  make(map[string]*Lease),
 		byCID:  make(map[string]*Lease),
 		byHost: make(map[string]*Lease),
+		byDUID: make(map[string]*Lease),
 	}
 
 	// Load existing leases into memory
@@ -81,27 +88,57 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// loadAll reads all leases from BoltDB into in-memory indexes.
+// loadAll reads all leases from BoltDB into in-memory indexes and restores
+// the sequence counter, so sequence numbers never go backwards across restarts.
 func (s *Store) loadAll() error {
 	return s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketLeases)
-		return b.ForEach(func(k, v []byte) error {
+		err := b.ForEach(func(k, v []byte) error {
 			l := &Lease{}
 			if err := json.Unmarshal(v, l); err != nil {
 				return fmt.Errorf("unmarshalling lease %s: %w", k, err)
 			}
 			s.indexLease(l)
+			s.seq = max(s.seq, l.UpdateSeq)
 			return nil
 		})
+		if err != nil {
+			return err
+		}
+		return s.loadTombstoneState(tx)
+	})
+}
+
+// CheckDB verifies that tx holds a lease database this version can load:
+// the lease buckets exist and every lease decodes. It validates snapshots
+// before they are kept or restored.
+func CheckDB(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketLeases, bucketMeta} {
+		if tx.Bucket(name) == nil {
+			return fmt.Errorf("not a lease database: bucket %s missing", name)
+		}
+	}
+	return tx.Bucket(bucketLeases).ForEach(func(k, v []byte) error {
+		var l Lease
+		if err := json.Unmarshal(v, &l); err != nil {
+			return fmt.Errorf("unmarshalling lease %s: %w", k, err)
+		}
+		return nil
 	})
 }
 
 // indexLease adds a lease to all in-memory indexes (caller must hold write lock or be in init).
+// DHCPv6 leases are indexed by IP and DUID only, so they never shadow a
+// DHCPv4 lease for the same MAC or hostname.
 func (s *Store) indexLease(l *Lease) {
 	ipKey := l.IP.String()
 	macKey := l.MAC.String()
 
 	s.byIP[ipKey] = l
+	if l.IsV6() {
+		s.byDUID[l.v6Key()] = l
+		return
+	}
 	s.byMAC[macKey] = l
 	if l.ClientID != "" {
 		s.byCID[l.ClientID] = l
@@ -117,6 +154,10 @@ func (s *Store) unindexLease(l *Lease) {
 	macKey := l.MAC.String()
 
 	delete(s.byIP, ipKey)
+	if l.IsV6() {
+		delete(s.byDUID, l.v6Key())
+		return
+	}
 	delete(s.byMAC, macKey)
 	if l.ClientID != "" {
 		delete(s.byCID, l.ClientID)
@@ -133,12 +174,32 @@ func (s *Store) Put(l *Lease) error {
 		return fmt.Errorf("marshalling lease for %s: %w", l.IP, err)
 	}
 
+	// A binding that moved to a new address or prefix replaces the old record.
+	var moved *Lease
+	s.mu.RLock()
+	if l.IsV6() {
+		if old, ok := s.byDUID[l.v6Key()]; ok && !old.IP.Equal(l.IP) {
+			moved = old
+		}
+	} else if old, ok := s.byMAC[l.MAC.String()]; ok && !old.IP.Equal(l.IP) {
+		moved = old
+	}
+	s.mu.RUnlock()
+
 	err = s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketLeases)
 		ipKey := []byte(l.IP.String())
 		if err := b.Put(ipKey, data); err != nil {
 			return fmt.Errorf("writing lease for %s: %w", l.IP, err)
 		}
+		if moved != nil {
+			if err := b.Delete([]byte(moved.IP.String())); err != nil {
+				return fmt.Errorf("deleting replaced lease %s: %w", moved.IP, err)
+			}
+		}
+		if l.IsV6() {
+			return nil
+		}
 
 		// Update MAC index
 		macBucket := tx.Bucket(bucketIndexMAC)
@@ -169,9 +230,19 @@ func (s *Store) Put(l *Lease) error {
 	}
 
 	s.mu.Lock()
-	// Remove old lease for this MAC if IP changed
-	if old, ok := s.byMAC[l.MAC.String()]; ok && !old.IP.Equal(l.IP) {
-		s.unindexLease(old)
+	if l.IsV6() {
+		if old, ok := s.byDUID[l.v6Key()]; ok && !old.IP.Equal(l.IP) {
+			s.unindexLease(old)
+		}
+	} else {
+		if old, ok := s.byMAC[l.MAC.String()]; ok && !old.IP.Equal(l.IP) {
+			// Remove old lease for this MAC if IP changed
+			s.unindexLease(old)
+		}
+		if old, ok := s.byIP[l.IP.String()]; ok && !old.IsV6() && old.MAC.String() != l.MAC.String() {
+			// The address went to another client (e.g. a held one under pool pressure)
+			s.unindexLease(old)
+		}
 	}
 	s.indexLease(l)
 	s.mu.Unlock()
@@ -191,11 +262,24 @@ func (s *Store) Delete(ip net.IP) error {
 		return nil
 	}
 
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	seq, err := s.NextSeq()
+	if err != nil {
+		return err
+	}
+	var floor uint64
+	err = s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketLeases)
 		if err := b.Delete([]byte(ipStr)); err != nil {
 			return fmt.Errorf("deleting lease for %s: %w", ip, err)
 		}
+		var err error
+		if floor, err = s.putTombstone(tx, l, seq); err != nil {
+			return err
+		}
+
+		if l.IsV6() {
+			return nil
+		}
 
 		macBucket := tx.Bucket(bucketIndexMAC)
 		_ = macBucket.Delete([]byte(l.MAC.String()))
@@ -217,6 +301,7 @@ func (s *Store) Delete(ip net.IP) error {
 
 	s.mu.Lock()
 	s.unindexLease(l)
+	s.tombFloor = max(s.tombFloor, floor)
 	s.mu.Unlock()
 
 	return nil
@@ -266,6 +351,18 @@ func (s *Store) GetByHostname(hostname string) *Lease {
 	return l.Clone()
 }
 
+// GetByDUID returns the DHCPv6 lease bound to a DUID and IAID — the IA_PD
+// binding if prefix is true, otherwise the IA_NA one. O(1) via in-memory index.
+func (s *Store) GetByDUID(duid string, iaid uint32, prefix bool) *Lease {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.byDUID[v6Key(duid, iaid, prefix)]
+	if !ok {
+		return nil
+	}
+	return l.Clone()
+}
+
 // All returns all active leases (cloned).
 func (s *Store) All() []*Lease {
 	s.mu.RLock()
@@ -295,15 +392,23 @@ func (s *Store) ForEach(fn func(*Lease) bool) {
 	}
 }
 
+// CurrentSeq returns the last sequence number handed out.
+func (s *Store) CurrentSeq() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seq, nil
+}
+
 // NextSeq returns and increments the monotonic sequence counter.
-func (s *Store) NextSeq() uint64 {
+func (s *Store) NextSeq() (uint64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.seq++
-	return s.seq
+	return s.seq, nil
 }
 
-// DB returns the underlying BoltDB instance (for conflict table, etc.).
-func (s *Store) DB() *bolt.DB {
+// DB returns the underlying database, shared with the conflict table,
+// config store, etc.
+func (s *Store) DB() *boltdb.Handle {
 	return s.db
 }
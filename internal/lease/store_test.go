@@ -1,11 +1,15 @@
 package lease
 
 import (
+	"io"
+	"log/slog"
 	"net"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
 )
 
@@ -277,9 +281,9 @@ func TestStorePersistence(t *testing.T) {
 func TestStoreNextSeq(t *testing.T) {
 	store := newTestStore(t)
 
-	s1 := store.NextSeq()
-	s2 := store.NextSeq()
-	s3 := store.NextSeq()
+	s1 := nextSeq(t, store)
+	s2 := nextSeq(t, store)
+	s3 := nextSeq(t, store)
 
 	if s1 != 1 || s2 != 2 || s3 != 3 {
 		t.Errorf("NextSeq() sequence = %d, %d, %d, want 1, 2, 3", s1, s2, s3)
@@ -323,3 +327,173 @@ func TestStoreForEach(t *testing.T) {
 		t.Errorf("ForEach with early stop visited %d leases, want 1", count)
 	}
 }
+
+func TestStoreV6Bindings(t *testing.T) {
+	store := newTestStore(t)
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	now := time.Now()
+
+	v4 := &Lease{
+		IP: net.IPv4(192, 168, 1, 100), MAC: mac, Hostname: "host",
+		State: dhcpv4.LeaseStateActive, Start: now, Expiry: now.Add(time.Hour),
+	}
+	na := &Lease{
+		IP: net.ParseIP("2001:db8::100"), MAC: mac, DUID: "00:03:00:01:00:11:22:33:44:55", IAID: 1, Hostname: "host",
+		State: dhcpv4.LeaseStateActive, Start: now, Expiry: now.Add(time.Hour),
+	}
+	pd := &Lease{
+		IP: net.ParseIP("2001:db8:ff00::"), DUID: na.DUID, IAID: 1, PrefixLen: 56,
+		State: dhcpv4.LeaseStateActive, Start: now, Expiry: now.Add(time.Hour),
+	}
+	for _, l := range []*Lease{v4, na, pd} {
+		if err := store.Put(l); err != nil {
+			t.Fatalf("Put %s: %v", l.IP, err)
+		}
+	}
+
+	// The v6 bindings must not displace the v4 lease for the same MAC/hostname.
+	if got := store.GetByMAC(mac); got == nil || !got.IP.Equal(v4.IP) {
+		t.Errorf("GetByMAC = %v, want v4 lease", got)
+	}
+	if got := store.GetByHostname("host"); got == nil || !got.IP.Equal(v4.IP) {
+		t.Errorf("GetByHostname = %v, want v4 lease", got)
+	}
+	if got := store.GetByDUID(na.DUID, 1, false); got == nil || !got.IP.Equal(na.IP) {
+		t.Errorf("GetByDUID(na) = %v", got)
+	}
+	if got := store.GetByDUID(na.DUID, 1, true); got == nil || got.PrefixLen != 56 {
+		t.Errorf("GetByDUID(pd) = %v", got)
+	}
+
+	// Moving the IA_NA binding replaces the old address, in memory and on disk.
+	moved := na.Clone()
+	moved.IP = net.ParseIP("2001:db8::200")
+	if err := store.Put(moved); err != nil {
+		t.Fatalf("Put moved: %v", err)
+	}
+	if store.GetByIP(na.IP) != nil {
+		t.Error("old v6 address still indexed")
+	}
+	if store.Count() != 3 {
+		t.Errorf("Count() = %d, want 3", store.Count())
+	}
+
+	path := store.DB().Path()
+	store.Close()
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Count() != 3 {
+		t.Errorf("reopened Count() = %d, want 3", reopened.Count())
+	}
+	if got := reopened.GetByDUID(na.DUID, 1, true); got == nil || got.MAC != nil {
+		t.Errorf("reloaded prefix lease = %+v, want empty MAC", got)
+	}
+}
+
+func TestStoreChangesSince(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "changes.db")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore error: %v", err)
+	}
+
+	now := time.Now()
+	put := func(last byte) net.IP {
+		ip := net.IPv4(192, 168, 1, last)
+		mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, last}
+		if err := store.Put(&Lease{
+			IP: ip, MAC: mac, Subnet: "192.168.1.0/24",
+			State: dhcpv4.LeaseStateActive, Start: now, Expiry: now.Add(time.Hour),
+			UpdateSeq: nextSeq(t, store),
+		}); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+		return ip
+	}
+
+	put(10)            // seq 1
+	ip11 := put(11)    // seq 2
+	put(12)            // seq 3
+	store.Delete(ip11) // seq 4 (tombstone)
+
+	changes, ok := store.ChangesSince(2)
+	if !ok {
+		t.Fatal("ChangesSince(2) not available")
+	}
+	if len(changes) != 2 || changes[0].UpdateSeq != 3 || changes[1].UpdateSeq != 4 {
+		t.Fatalf("ChangesSince(2) = %d changes, want seq 3 (lease) and 4 (deletion)", len(changes))
+	}
+	if !changes[1].IP.Equal(ip11) || changes[1].State != dhcpv4.LeaseStateReleased {
+		t.Errorf("deletion = %s %s, want %s released", changes[1].IP, changes[1].State, ip11)
+	}
+
+	if _, ok := store.ChangesSince(99); ok {
+		t.Error("ChangesSince past the current seq should require a full sync")
+	}
+
+	// Sequence numbers and deletions survive a restart
+	store.SetSyncMark("peer", 42)
+	store.Close()
+	store2, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer store2.Close()
+
+	if got, err := store2.CurrentSeq(); err != nil || got != 4 {
+		t.Errorf("CurrentSeq after reopen = %d, %v, want 4", got, err)
+	}
+	if got := store2.SyncMark("peer"); got != 42 {
+		t.Errorf("SyncMark after reopen = %d, want 42", got)
+	}
+	changes, ok = store2.ChangesSince(3)
+	if !ok || len(changes) != 1 || !changes[0].IP.Equal(ip11) {
+		t.Errorf("ChangesSince(3) after reopen = %v, %v, want the deletion of %s", changes, ok, ip11)
+	}
+}
+
+func TestReclaimOffersScansOnlyWhenDue(t *testing.T) {
+	store := newTestStore(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := events.NewBus(10, logger)
+	m := NewManager(store, &config.Config{}, bus, logger)
+
+	const subnet = "10.0.0.0/24"
+	offer := func(last byte, age time.Duration) {
+		t.Helper()
+		err := store.Put(&Lease{
+			IP:     net.IPv4(10, 0, 0, last),
+			MAC:    net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, 0, last},
+			Subnet: subnet,
+			State:  dhcpv4.LeaseStateOffered,
+			Start:  time.Now().Add(-age),
+			Expiry: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+
+	offer(10, 2*time.Minute)
+	offer(11, 10*time.Second)
+	if n := m.ReclaimOffers(subnet, time.Minute); n != 1 {
+		t.Fatalf("reclaimed %d offers, want 1", n)
+	}
+	// .11 is the oldest offer left and isn't stale for another ~50s, so
+	// nothing made since can be either: the next calls don't scan
+	offer(12, 2*time.Minute)
+	if n := m.ReclaimOffers(subnet, time.Minute); n != 0 {
+		t.Errorf("scanned again before any offer was due, reclaimed %d", n)
+	}
+	// A different threshold (config reload) scans at once
+	if n := m.ReclaimOffers(subnet, 5*time.Second); n != 2 {
+		t.Errorf("reclaimed %d offers after the threshold changed, want 2", n)
+	}
+	if store.Count() != 0 {
+		t.Errorf("Count = %d, want 0", store.Count())
+	}
+}
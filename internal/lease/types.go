@@ -3,6 +3,7 @@ package lease
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
 	"time"
 
@@ -14,6 +15,9 @@ type Lease struct {
 	IP          net.IP              `json:"ip"`
 	MAC         net.HardwareAddr    `json:"mac"`
 	ClientID    string              `json:"client_id,omitempty"`
+	DUID        string              `json:"duid,omitempty"`       // DHCPv6 client DUID (hex)
+	IAID        uint32              `json:"iaid,omitempty"`       // DHCPv6 identity association ID
+	PrefixLen   int                 `json:"prefix_len,omitempty"` // delegated prefix length (IA_PD), 0 for addresses
 	Hostname    string              `json:"hostname,omitempty"`
 	FQDN        string              `json:"fqdn,omitempty"`
 	Subnet      string              `json:"subnet"`
@@ -32,6 +36,30 @@ type RelayInfo struct {
 	GIAddr    net.IP `json:"giaddr,omitempty"`
 	CircuitID string `json:"circuit_id,omitempty"`
 	RemoteID  string `json:"remote_id,omitempty"`
+	RelayID   string `json:"relay_id,omitempty"` // hex, RFC 6925 sub-option 12
+}
+
+// IsV6 returns true for DHCPv6 leases (IA_NA addresses and IA_PD prefixes).
+func (l *Lease) IsV6() bool {
+	return l.DUID != ""
+}
+
+// IsPrefix returns true for a delegated prefix (IA_PD) rather than an address.
+func (l *Lease) IsPrefix() bool {
+	return l.PrefixLen > 0
+}
+
+// v6Key identifies a DHCPv6 binding: one lease per DUID, IAID and IA type.
+func (l *Lease) v6Key() string {
+	return v6Key(l.DUID, l.IAID, l.IsPrefix())
+}
+
+func v6Key(duid string, iaid uint32, prefix bool) string {
+	kind := "na"
+	if prefix {
+		kind = "pd"
+	}
+	return fmt.Sprintf("%s|%d|%s", duid, iaid, kind)
 }
 
 // IsExpired returns true if the lease has expired.
@@ -81,6 +109,11 @@ func (l *Lease) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	l.IP = net.ParseIP(aux.IP)
+	l.MAC = nil
+	if aux.MAC == "" {
+		// DHCPv6 leases whose DUID carries no link-layer address
+		return nil
+	}
 	var err error
 	l.MAC, err = net.ParseMAC(aux.MAC)
 	if err != nil {
@@ -99,8 +132,10 @@ func (l *Lease) Clone() *Lease {
 	c := *l
 	c.IP = make(net.IP, len(l.IP))
 	copy(c.IP, l.IP)
-	c.MAC = make(net.HardwareAddr, len(l.MAC))
-	copy(c.MAC, l.MAC)
+	if l.MAC != nil {
+		c.MAC = make(net.HardwareAddr, len(l.MAC))
+		copy(c.MAC, l.MAC)
+	}
 	if l.Options != nil {
 		c.Options = make(map[string]string, len(l.Options))
 		for k, v := range l.Options {
@@ -42,6 +42,25 @@ var (
 	}, []string{"msg_type"})
 )
 
+// --- DHCPv6 Packet Metrics ---
+
+var (
+	// DHCPv6PacketsReceived counts DHCPv6 messages received by message type
+	// (the innermost message for relayed traffic).
+	DHCPv6PacketsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcpv6_packets_received_total",
+		Help:      "Total DHCPv6 messages received, by message type.",
+	}, []string{"msg_type"})
+
+	// DHCPv6PacketsSent counts DHCPv6 messages sent by message type.
+	DHCPv6PacketsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcpv6_packets_sent_total",
+		Help:      "Total DHCPv6 messages sent, by message type.",
+	}, []string{"msg_type"})
+)
+
 // --- Lease Metrics ---
 
 var (
@@ -63,7 +82,7 @@ var (
 	LeaseOperations = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "lease_operations_total",
-		Help:      "Total lease operations, by type (offer, ack, renew, release, decline, expire).",
+		Help:      "Total lease operations, by type (offer, ack, renew, release, decline, expire, reclaim, reuse, evict, offer_reclaim).",
 	}, []string{"operation"})
 )
 
@@ -84,6 +103,13 @@ var (
 		Help:      "Number of allocated IPs in the pool.",
 	}, []string{"subnet", "pool"})
 
+	// PoolHeld is the free IPs in each pool kept for a returning client.
+	PoolHeld = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_held",
+		Help:      "Number of free IPs in the pool held for the client that last had them (affinity_period).",
+	}, []string{"subnet", "pool"})
+
 	// PoolUtilization is the utilization percentage of each pool.
 	PoolUtilization = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: namespace,
@@ -97,6 +123,83 @@ var (
 		Name:      "pool_exhausted_total",
 		Help:      "Total times a pool was exhausted during allocation.",
 	}, []string{"subnet"})
+
+	// PoolOverflow counts clients given an address from an overflow pool or
+	// overflow subnet because the pool they matched was full.
+	PoolOverflow = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pool_overflow_total",
+		Help:      "Total clients given an address from an overflow pool or subnet, by the subnet they came from.",
+	}, []string{"subnet"})
+
+	// LeasesShortened counts leases handed out with a shorter time because
+	// their pool was above a pressure lease_time threshold.
+	LeasesShortened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leases_shortened_total",
+		Help:      "Total leases shortened because their pool was above a pressure threshold.",
+	}, []string{"subnet"})
+)
+
+// --- Database Metrics ---
+
+var (
+	// DBSize is the size of the lease database file.
+	DBSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_size_bytes",
+		Help:      "Size of the lease database file in bytes.",
+	})
+
+	// DBFree is the space in the lease database a compaction would reclaim.
+	DBFree = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_free_bytes",
+		Help:      "Bytes of the lease database file a compaction would reclaim.",
+	})
+
+	// DBBucketKeys is the number of keys in each top-level bucket.
+	DBBucketKeys = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_bucket_keys",
+		Help:      "Number of keys in each lease database bucket.",
+	}, []string{"bucket"})
+
+	// DBBucketBytes is the space used by each top-level bucket.
+	DBBucketBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_bucket_bytes",
+		Help:      "Bytes of pages in use by each lease database bucket.",
+	}, []string{"bucket"})
+
+	// DBCompactions counts compactions by result.
+	DBCompactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_compactions_total",
+		Help:      "Total lease database compactions, by result (success, error).",
+	}, []string{"result"})
+
+	// DBCompactionDuration tracks how long compactions held writes.
+	DBCompactionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_compaction_duration_seconds",
+		Help:      "Lease database compaction duration in seconds, during which writes wait.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+	})
+
+	// DBSnapshots counts snapshots by result.
+	DBSnapshots = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_snapshots_total",
+		Help:      "Total lease database snapshots, by result (success, error).",
+	}, []string{"result"})
+
+	// DBLastSnapshot is when the last snapshot succeeded.
+	DBLastSnapshot = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_last_snapshot_timestamp_seconds",
+		Help:      "Unix time of the last successful lease database snapshot.",
+	})
 )
 
 // --- Conflict Detection Metrics ---
@@ -230,6 +333,108 @@ var (
 		Name:      "ha_sync_errors_total",
 		Help:      "Total HA sync errors.",
 	})
+
+	// HALoadBalanceSkipped counts packets left to the peer in load-balancing mode.
+	HALoadBalanceSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_load_balance_skipped_total",
+		Help:      "Total DHCP packets ignored because the client hashes to the HA peer.",
+	})
+
+	// HAMCLTCapped counts leases shortened to stay within the MCLT.
+	HAMCLTCapped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_mclt_capped_total",
+		Help:      "Total lease times shortened to the MCLT beyond the peer's acknowledged expiry.",
+	})
+
+	// HASplitBrainChecks counts split-brain checks before a takeover by result.
+	HASplitBrainChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_split_brain_checks_total",
+		Help:      "Total split-brain checks before taking over from a silent peer.",
+	}, []string{"result"})
+
+	// HARenewalsOnlyDropped counts requests for new addresses ignored in
+	// COMMUNICATIONS_INTERRUPTED.
+	HARenewalsOnlyDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_renewals_only_dropped_total",
+		Help:      "Total requests for new addresses ignored while HA communications are interrupted.",
+	})
+
+	// HAUpdateQueueLength tracks lease updates queued for the peer but not yet sent.
+	HAUpdateQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_update_queue_length",
+		Help:      "Lease updates queued for the HA peer and not yet sent.",
+	})
+
+	// HAUnackedUpdates tracks lease updates sent to the peer but not yet acknowledged.
+	HAUnackedUpdates = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_unacked_updates",
+		Help:      "Lease updates sent to the HA peer and awaiting acknowledgement.",
+	})
+
+	// HAOldestUnackedSeconds tracks how long the oldest unacknowledged update has waited.
+	HAOldestUnackedSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_oldest_unacked_seconds",
+		Help:      "Age of the oldest lease update the HA peer has not acknowledged.",
+	})
+
+	// HASyncAckTimeouts counts DHCPACKs withheld because the peer did not
+	// acknowledge the binding in time (ha.sync_ack).
+	HASyncAckTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_sync_ack_timeouts_total",
+		Help:      "Total DHCPACKs withheld because the HA peer did not acknowledge the binding in time.",
+	})
+
+	// HAAuthFailures counts sync frames and connections rejected by the
+	// pre-shared key or TLS checks, by reason.
+	HAAuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_auth_failures_total",
+		Help:      "Total HA sync frames and connections rejected by authentication, by reason.",
+	}, []string{"reason"})
+
+	// HATLSReloads counts reloads of the HA TLS certificate files.
+	HATLSReloads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_tls_reloads_total",
+		Help:      "Total reloads of the HA TLS certificate files after they changed.",
+	})
+
+	// HAClusterTerm is the current election term in cluster mode.
+	HAClusterTerm = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_cluster_term",
+		Help:      "Current HA cluster election term.",
+	})
+
+	// HAClusterLeader is 1 while this node leads the cluster.
+	HAClusterLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_cluster_leader",
+		Help:      "Whether this node is the HA cluster leader (1) or not (0).",
+	})
+
+	// HAClusterMembersReachable counts the members this node can reach,
+	// itself included.
+	HAClusterMembersReachable = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ha_cluster_members_reachable",
+		Help:      "Number of HA cluster members reachable from this node, itself included.",
+	})
+
+	// HAClusterElections counts elections this node started.
+	HAClusterElections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ha_cluster_elections_total",
+		Help:      "Total HA cluster elections started by this node.",
+	})
 )
 
 // --- API Metrics ---
@@ -277,6 +482,65 @@ var (
 	}, []string{"type"})
 )
 
+// --- RADIUS Metrics ---
+
+var (
+	// RADIUSAuth counts RADIUS authorization decisions in the DHCP path by result.
+	RADIUSAuth = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "radius_auth_total",
+		Help:      "Total RADIUS authorizations for DHCP clients.",
+	}, []string{"subnet", "result"})
+)
+
+// --- Leasequery Metrics ---
+
+var (
+	// LeaseQueries counts DHCPLEASEQUERY and DHCPBULKLEASEQUERY requests by
+	// transport, query type and result.
+	LeaseQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leasequery_total",
+		Help:      "Total leasequery requests, by transport, query type and result.",
+	}, []string{"transport", "query", "result"})
+
+	// BulkLeaseQueryConnections tracks open bulk leasequery TCP connections.
+	BulkLeaseQueryConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bulk_leasequery_connections",
+		Help:      "Number of open bulk leasequery TCP connections.",
+	})
+
+	// BulkLeaseQueryBindings counts bindings sent in bulk leasequery replies.
+	BulkLeaseQueryBindings = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bulk_leasequery_bindings_total",
+		Help:      "Total bindings sent in bulk leasequery replies.",
+	})
+)
+
+// --- Port Automation Metrics ---
+
+var (
+	// PortAutoExecutions counts port automation rule runs from lease events.
+	PortAutoExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "portauto_executions_total",
+		Help:      "Total port automation rule executions triggered by lease events.",
+	}, []string{"rule", "result"})
+)
+
+// --- Topology Metrics ---
+
+var (
+	// TopologyPortMoves counts devices seen on a different switch port than before.
+	TopologyPortMoves = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "topology_port_moves_total",
+		Help:      "Total devices that moved to a different switch port.",
+	})
+)
+
 // --- DNS Proxy Metrics ---
 
 var (
@@ -323,6 +587,20 @@ var (
 		Help:      "Total DNS queries blocked by filter lists.",
 	}, []string{"list", "action"})
 
+	// DNSPolicyQueries counts DNS queries by the filtering policy that applied.
+	DNSPolicyQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dns_policy_queries_total",
+		Help:      "Total DNS queries from clients selected by a filtering policy.",
+	}, []string{"policy"})
+
+	// DNSQueryLogDropped counts query log entries not written to disk.
+	DNSQueryLogDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dns_querylog_dropped_total",
+		Help:      "Total DNS query log entries dropped because the on-disk log could not keep up.",
+	})
+
 	// DNSZoneRecords is the current number of records in the local zone.
 	DNSZoneRecords = promauto.NewGauge(prometheus.GaugeOpts{
 		Namespace: namespace,
@@ -336,6 +614,13 @@ var (
 		Name:      "dns_upstream_errors_total",
 		Help:      "Total failed DNS upstream forward attempts.",
 	})
+
+	// DNSSECValidations counts DNSSEC validation results for upstream answers.
+	DNSSECValidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dns_dnssec_validations_total",
+		Help:      "Total DNSSEC validations of upstream answers by result.",
+	}, []string{"result"})
 )
 
 // --- Server Info ---
@@ -4,7 +4,9 @@ package pool
 import (
 	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
 	"github.com/athena-dhcpd/athena-dhcpd/pkg/dhcpv4"
@@ -20,15 +22,44 @@ type Pool struct {
 	endU      uint32
 	size      uint32
 	bitmap    []uint64 // 1 bit per IP: 1=allocated, 0=free
+	held      []uint64 // 1 bit per IP: free, but kept for the client that last had it
+	holdUntil map[uint32]time.Time
 	allocated uint32
 	mu        sync.Mutex
 
+	// When each IP was last freed, on a clock that ticks once per release
+	// (0 = never used), and for every bitmap word the oldest of those
+	// times among its allocatable IPs. 4 bytes per IP: 256 KiB for a /16.
+	freedAt    []uint32
+	wordOldest []uint32
+	clock      uint32
+
+	// New addresses are only handed out from offsets [shareLo, shareHi).
+	// HA load balancing gives each peer its own slice of every pool.
+	shareLo uint32
+	shareHi uint32
+
+	// The highest pressure level reported by SignalPressure since the pool
+	// last dropped below it.
+	signalled Pressure
+
 	// Pool matching criteria
 	MatchCircuitID   string
 	MatchRemoteID    string
 	MatchVendorClass string
 	MatchUserClass   string
+	MatchRadiusClass string
+	ClientClasses    []string
 	LeaseTime        string
+
+	// Overflow pools are left out by SelectPool and only handed out from
+	// once the pool a client matches is full (SelectOverflow).
+	Overflow bool
+
+	// Strategy picks the next free IP: config.PoolStrategyLRU (the
+	// default when empty), config.PoolStrategySequential or
+	// config.PoolStrategyRandom. Set before the pool is used.
+	Strategy string
 }
 
 // NewPool creates a new IP pool from a range within a network.
@@ -50,15 +81,21 @@ func NewPool(name string, start, end net.IP, network *net.IPNet) (*Pool, error)
 	size := endU - startU + 1
 	bitmapSize := (size + 63) / 64
 
+	// freedAt and wordOldest start at 0: no address has been used yet
 	return &Pool{
-		Name:    name,
-		Start:   start.To4(),
-		End:     end.To4(),
-		Network: network,
-		startU:  startU,
-		endU:    endU,
-		size:    size,
-		bitmap:  make([]uint64, bitmapSize),
+		Name:       name,
+		Start:      start.To4(),
+		End:        end.To4(),
+		Network:    network,
+		startU:     startU,
+		endU:       endU,
+		size:       size,
+		bitmap:     make([]uint64, bitmapSize),
+		held:       make([]uint64, bitmapSize),
+		holdUntil:  make(map[uint32]time.Time),
+		freedAt:    make([]uint32, size),
+		wordOldest: make([]uint32, bitmapSize),
+		shareHi:    size,
 	}, nil
 }
 
@@ -74,7 +111,14 @@ func (p *Pool) Allocated() uint32 {
 	return p.allocated
 }
 
-// Available returns the number of free IPs.
+// Held returns the number of free IPs kept for a returning client.
+func (p *Pool) Held() uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return uint32(len(p.holdUntil))
+}
+
+// Available returns the number of free IPs, including held ones.
 func (p *Pool) Available() uint32 {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -132,8 +176,34 @@ func (p *Pool) clear(offset uint32) {
 	}
 }
 
-// Allocate finds the next free IP in the pool. Returns nil if pool is full.
-// Uses bitmap scanning — NOT a linear IP scan.
+// isHeld returns true if the IP at offset is free but held.
+func (p *Pool) isHeld(offset uint32) bool {
+	return p.held[offset/64]&(1<<(offset%64)) != 0
+}
+
+// allocatable returns true if the IP at offset is neither allocated nor held.
+func (p *Pool) allocatable(offset uint32) bool {
+	return !p.isSet(offset) && !p.isHeld(offset)
+}
+
+// unhold drops the hold on offset.
+func (p *Pool) unhold(offset uint32) {
+	p.held[offset/64] &^= 1 << (offset % 64)
+	delete(p.holdUntil, offset)
+}
+
+// claim marks a free or held offset as allocated. Must be called under lock.
+func (p *Pool) claim(offset uint32) {
+	if p.isHeld(offset) {
+		p.unhold(offset)
+	}
+	p.set(offset)
+	p.touch(offset / 64)
+}
+
+// Allocate hands out a free IP from the pool's share and marks it
+// allocated, in the order described at candidates. Returns nil if the pool
+// is full.
 func (p *Pool) Allocate() net.IP {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -142,30 +212,82 @@ func (p *Pool) Allocate() net.IP {
 		return nil
 	}
 
-	// Scan bitmap words for a word with at least one free bit
-	for w := uint32(0); w < uint32(len(p.bitmap)); w++ {
-		if p.bitmap[w] == ^uint64(0) {
-			continue // All bits set in this word
+	offsets := p.candidates(1)
+	if len(offsets) == 0 {
+		return nil
+	}
+	p.claim(offsets[0])
+	p.updateMetrics()
+	return p.offsetToIP(offsets[0])
+}
+
+// candidates returns up to n unallocated offsets within the pool's share in
+// the order they are handed out: free ones as the pool's strategy picks
+// them, and only when none is left the held ones whose hold ends soonest.
+// Must be called under lock.
+func (p *Pool) candidates(n int) []uint32 {
+	// Picked offsets are marked in the bitmap so the next pick passes
+	// them over, and unmarked again before returning
+	var out []uint32
+	for len(out) < n {
+		offset, ok := p.pick()
+		if !ok {
+			break
 		}
-		// Find first zero bit in this word
-		word := p.bitmap[w]
-		for bit := uint32(0); bit < 64; bit++ {
-			if word&(1<<bit) == 0 {
-				offset := w*64 + bit
-				if offset >= p.size {
-					return nil // Past end of pool
-				}
-				p.set(offset)
-				p.updateMetrics()
-				return p.offsetToIP(offset)
+		p.bitmap[offset/64] |= 1 << (offset % 64)
+		p.touch(offset / 64)
+		out = append(out, offset)
+	}
+	for _, offset := range out {
+		p.bitmap[offset/64] &^= 1 << (offset % 64)
+		p.touch(offset / 64)
+	}
+
+	if len(out) < n && len(p.holdUntil) > 0 {
+		var held []uint32
+		for offset := range p.holdUntil {
+			if offset >= p.shareLo && offset < p.shareHi {
+				held = append(held, offset)
 			}
 		}
+		sort.Slice(held, func(i, j int) bool {
+			ti, tj := p.holdUntil[held[i]], p.holdUntil[held[j]]
+			if !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+			return held[i] < held[j]
+		})
+		out = append(out, held[:min(len(held), n-len(out))]...)
 	}
+	return out
+}
 
-	return nil
+// SetShare restricts new allocations to the index-th of count equal slices
+// of the pool. count <= 1 hands out from the whole pool again. Addresses
+// outside the share can still be claimed with AllocateSpecific.
+func (p *Pool) SetShare(index, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if count <= 1 || index < 0 || index >= count {
+		p.shareLo, p.shareHi = 0, p.size
+		return
+	}
+	p.shareLo = uint32(uint64(p.size) * uint64(index) / uint64(count))
+	p.shareHi = uint32(uint64(p.size) * uint64(index+1) / uint64(count))
+}
+
+// Owns reports whether ip falls in the slice this pool allocates from.
+func (p *Pool) Owns(ip net.IP) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offset, ok := p.ipToOffset(ip)
+	return ok && offset >= p.shareLo && offset < p.shareHi
 }
 
 // AllocateSpecific tries to allocate a specific IP. Returns false if already allocated or out of range.
+// A held IP is allocated: the caller decides whether the client may have it.
 func (p *Pool) AllocateSpecific(ip net.IP) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -177,12 +299,12 @@ func (p *Pool) AllocateSpecific(ip net.IP) bool {
 	if p.isSet(offset) {
 		return false
 	}
-	p.set(offset)
+	p.claim(offset)
 	p.updateMetrics()
 	return true
 }
 
-// Release frees a previously allocated IP.
+// Release frees a previously allocated or held IP.
 func (p *Pool) Release(ip net.IP) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -191,14 +313,49 @@ func (p *Pool) Release(ip net.IP) bool {
 	if !ok {
 		return false
 	}
-	if !p.isSet(offset) {
+	switch {
+	case p.isHeld(offset):
+		p.unhold(offset)
+	case p.isSet(offset):
+		p.clear(offset)
+	default:
+		return false
+	}
+	p.freedAt[offset] = p.tick()
+	p.touch(offset / 64)
+	p.updateMetrics()
+	return true
+}
+
+// Hold frees an IP but keeps it for the client that had it until the
+// given time: Allocate and AllocateN only hand it out once every other
+// address in the share is taken. AllocateSpecific claims it back; Release
+// ends the hold. Holding a held IP moves the end of the hold.
+func (p *Pool) Hold(ip net.IP, until time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offset, ok := p.ipToOffset(ip)
+	if !ok {
 		return false
 	}
 	p.clear(offset)
+	p.held[offset/64] |= 1 << (offset % 64)
+	p.holdUntil[offset] = until
+	p.touch(offset / 64)
 	p.updateMetrics()
 	return true
 }
 
+// IsHeld checks if a specific IP is free but held for a returning client.
+func (p *Pool) IsHeld(ip net.IP) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offset, ok := p.ipToOffset(ip)
+	return ok && p.isHeld(offset)
+}
+
 // Contains checks if an IP is within this pool's range.
 func (p *Pool) Contains(ip net.IP) bool {
 	u := dhcpv4.IPToUint32(ip.To4())
@@ -217,8 +374,8 @@ func (p *Pool) IsAllocated(ip net.IP) bool {
 	return p.isSet(offset)
 }
 
-// AllocateN returns up to n free IPs without marking them as allocated.
-// Useful for parallel conflict probing.
+// AllocateN returns up to n free IPs, in the order Allocate hands them
+// out, without marking them as allocated. Useful for parallel conflict probing.
 func (p *Pool) AllocateN(n int) []net.IP {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -228,22 +385,9 @@ func (p *Pool) AllocateN(n int) []net.IP {
 	}
 
 	var ips []net.IP
-	for w := uint32(0); w < uint32(len(p.bitmap)) && len(ips) < n; w++ {
-		if p.bitmap[w] == ^uint64(0) {
-			continue
-		}
-		word := p.bitmap[w]
-		for bit := uint32(0); bit < 64 && len(ips) < n; bit++ {
-			if word&(1<<bit) == 0 {
-				offset := w*64 + bit
-				if offset >= p.size {
-					return ips
-				}
-				ips = append(ips, p.offsetToIP(offset))
-			}
-		}
+	for _, offset := range p.candidates(n) {
+		ips = append(ips, p.offsetToIP(offset))
 	}
-
 	return ips
 }
 
@@ -273,6 +417,7 @@ func (p *Pool) InitMetrics() {
 	alloc := p.allocated
 	p.mu.Unlock()
 	metrics.PoolAllocated.WithLabelValues(subnet, p.Name).Set(float64(alloc))
+	metrics.PoolHeld.WithLabelValues(subnet, p.Name).Set(float64(p.Held()))
 	if p.size > 0 {
 		metrics.PoolUtilization.WithLabelValues(subnet, p.Name).Set(float64(alloc) / float64(p.size) * 100)
 	}
@@ -282,6 +427,7 @@ func (p *Pool) InitMetrics() {
 func (p *Pool) updateMetrics() {
 	subnet := p.Subnet()
 	metrics.PoolAllocated.WithLabelValues(subnet, p.Name).Set(float64(p.allocated))
+	metrics.PoolHeld.WithLabelValues(subnet, p.Name).Set(float64(len(p.holdUntil)))
 	if p.size > 0 {
 		metrics.PoolUtilization.WithLabelValues(subnet, p.Name).Set(float64(p.allocated) / float64(p.size) * 100)
 	}
@@ -3,6 +3,7 @@ package pool
 import (
 	"net"
 	"testing"
+	"time"
 )
 
 func newTestPool(t *testing.T) *Pool {
@@ -234,3 +235,115 @@ func TestPoolString(t *testing.T) {
 		t.Error("String() returned empty")
 	}
 }
+
+func TestPoolShare(t *testing.T) {
+	// 11 addresses split in two: .100-.104 and .105-.110
+	first := newTestPool(t)
+	first.SetShare(0, 2)
+	second := newTestPool(t)
+	second.SetShare(1, 2)
+
+	if !first.Owns(net.IPv4(192, 168, 1, 104)) || first.Owns(net.IPv4(192, 168, 1, 105)) {
+		t.Error("first share should be .100-.104")
+	}
+	if !second.Owns(net.IPv4(192, 168, 1, 105)) || second.Owns(net.IPv4(192, 168, 1, 104)) {
+		t.Error("second share should be .105-.110")
+	}
+
+	var got []string
+	for ip := first.Allocate(); ip != nil; ip = first.Allocate() {
+		got = append(got, ip.String())
+	}
+	if len(got) != 5 || got[0] != "192.168.1.100" || got[4] != "192.168.1.104" {
+		t.Errorf("first share allocated %v", got)
+	}
+
+	if ip := second.Allocate(); !ip.Equal(net.IPv4(192, 168, 1, 105)) {
+		t.Errorf("second share first allocation = %s, want 192.168.1.105", ip)
+	}
+	if n := len(second.AllocateN(20)); n != 5 {
+		t.Errorf("second share AllocateN(20) = %d, want 5", n)
+	}
+
+	// Outside the share only AllocateSpecific works
+	if !second.AllocateSpecific(net.IPv4(192, 168, 1, 100)) {
+		t.Error("AllocateSpecific outside share should succeed")
+	}
+
+	// Back to the whole pool
+	first.SetShare(0, 1)
+	if ip := first.Allocate(); !ip.Equal(net.IPv4(192, 168, 1, 105)) {
+		t.Errorf("after SetShare(0, 1) allocation = %s, want 192.168.1.105", ip)
+	}
+}
+
+func TestPoolAllocationOrder(t *testing.T) {
+	p := newTestPool(t)
+	ip := func(last byte) net.IP { return net.IPv4(192, 168, 1, last) }
+	for range 4 {
+		p.Allocate() // .100-.103
+	}
+	p.Release(ip(102))
+	p.Release(ip(100))
+
+	// Never-used addresses go first, even though .100 and .102 are free
+	if got := p.Allocate(); !got.Equal(ip(104)) {
+		t.Errorf("Allocate = %s, want the never-used 192.168.1.104", got)
+	}
+	for range 6 {
+		p.Allocate() // .105-.110
+	}
+
+	// Then the longest free
+	want := []net.IP{ip(102), ip(100)}
+	if got := p.AllocateN(5); len(got) != 2 || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("AllocateN = %v, want %v", got, want)
+	}
+	if got := p.Allocate(); !got.Equal(ip(102)) {
+		t.Errorf("Allocate = %s, want the longest free 192.168.1.102", got)
+	}
+	if !p.AllocateSpecific(ip(100)) || p.Allocate() != nil {
+		t.Error("pool should be full")
+	}
+}
+
+func TestPoolHold(t *testing.T) {
+	p := newTestPool(t)
+	ip := func(last byte) net.IP { return net.IPv4(192, 168, 1, last) }
+	for range 11 {
+		p.Allocate()
+	}
+	now := time.Now()
+	p.Hold(ip(100), now.Add(2*time.Hour))
+	p.Hold(ip(101), now.Add(time.Hour))
+	p.Release(ip(102))
+
+	if p.Allocated() != 8 || p.Held() != 2 || !p.IsHeld(ip(100)) || p.IsAllocated(ip(100)) {
+		t.Errorf("Allocated = %d, Held = %d, want 8 and 2", p.Allocated(), p.Held())
+	}
+
+	// Held addresses only go once nothing else is free, soonest hold end first
+	if got := p.Allocate(); !got.Equal(ip(102)) {
+		t.Errorf("Allocate = %s, want the free 192.168.1.102", got)
+	}
+	if got := p.AllocateN(5); len(got) != 2 || !got[0].Equal(ip(101)) || !got[1].Equal(ip(100)) {
+		t.Errorf("AllocateN under pressure = %v, want .101 then .100", got)
+	}
+	if got := p.Allocate(); !got.Equal(ip(101)) {
+		t.Errorf("Allocate under pressure = %s, want 192.168.1.101", got)
+	}
+
+	// The client comes back for its address
+	if !p.AllocateSpecific(ip(100)) || p.IsHeld(ip(100)) || p.Held() != 0 {
+		t.Error("AllocateSpecific should claim a held address")
+	}
+
+	// Releasing a held address frees it for anyone
+	p.Hold(ip(105), now.Add(time.Hour))
+	if !p.Release(ip(105)) || p.IsHeld(ip(105)) {
+		t.Error("Release should end a hold")
+	}
+	if got := p.Allocate(); !got.Equal(ip(105)) {
+		t.Errorf("Allocate after the hold ended = %s, want 192.168.1.105", got)
+	}
+}
@@ -11,6 +11,8 @@ type MatchCriteria struct {
 	RemoteID    string
 	VendorClass string
 	UserClass   string
+	RadiusClass string   // Class attribute from the RADIUS Access-Accept
+	Classes     []string // client classes the client belongs to
 }
 
 // Matches returns true if the pool's match criteria are satisfied by the given client attributes.
@@ -37,12 +39,36 @@ func (p *Pool) Matches(criteria MatchCriteria) bool {
 			return false
 		}
 	}
-	return true
+	if p.MatchRadiusClass != "" {
+		if !matchGlob(p.MatchRadiusClass, criteria.RadiusClass) {
+			return false
+		}
+	}
+	return p.AllowsClasses(criteria.Classes)
+}
+
+// AllowsClasses returns true if a client in the given classes may use the
+// pool: the pool is not limited to client classes, or the client is in one.
+func (p *Pool) AllowsClasses(classes []string) bool {
+	return len(p.ClientClasses) == 0 || inAnyClass(p.ClientClasses, classes)
+}
+
+// inAnyClass returns true if any of the client's classes is in allowed.
+func inAnyClass(allowed, classes []string) bool {
+	for _, a := range allowed {
+		for _, c := range classes {
+			if a == c {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // HasMatchCriteria returns true if the pool has any match constraints.
 func (p *Pool) HasMatchCriteria() bool {
-	return p.MatchCircuitID != "" || p.MatchRemoteID != "" || p.MatchVendorClass != "" || p.MatchUserClass != ""
+	return p.MatchCircuitID != "" || p.MatchRemoteID != "" || p.MatchVendorClass != "" || p.MatchUserClass != "" ||
+		p.MatchRadiusClass != "" || len(p.ClientClasses) > 0
 }
 
 // matchGlob performs glob-style matching. Falls back to prefix match if glob fails.
@@ -65,10 +91,14 @@ func matchGlob(pattern, value string) bool {
 // SelectPool finds the best matching pool from a list for the given criteria.
 // Pools with match criteria that match are preferred over pools without.
 // Returns the first match, or the first pool with no criteria if no specific match.
+// Overflow pools are never selected.
 func SelectPool(pools []*Pool, criteria MatchCriteria) *Pool {
 	var defaultPool *Pool
 
 	for _, p := range pools {
+		if p.Overflow {
+			continue
+		}
 		if p.HasMatchCriteria() {
 			if p.Matches(criteria) {
 				return p
@@ -80,3 +110,14 @@ func SelectPool(pools []*Pool, criteria MatchCriteria) *Pool {
 
 	return defaultPool
 }
+
+// SelectOverflow returns the first overflow pool that the client matches
+// and that still has free addresses, or nil.
+func SelectOverflow(pools []*Pool, criteria MatchCriteria) *Pool {
+	for _, p := range pools {
+		if p.Overflow && p.Available() > 0 && (!p.HasMatchCriteria() || p.Matches(criteria)) {
+			return p
+		}
+	}
+	return nil
+}
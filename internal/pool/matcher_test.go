@@ -199,6 +199,7 @@ func TestHasMatchCriteria(t *testing.T) {
 		{"vendor_class only", &Pool{MatchVendorClass: "Cisco"}, true},
 		{"user_class only", &Pool{MatchUserClass: "VOIP"}, true},
 		{"all criteria", &Pool{MatchCircuitID: "x", MatchRemoteID: "y", MatchVendorClass: "z", MatchUserClass: "w"}, true},
+		{"client_classes only", &Pool{ClientClasses: []string{"voip"}}, true},
 	}
 
 	for _, tt := range tests {
@@ -278,3 +279,68 @@ func TestSelectPool(t *testing.T) {
 		}
 	})
 }
+
+func TestPoolMatchesClientClasses(t *testing.T) {
+	p := newMatcherPool(t, "phones", "", "", "", "")
+	p.ClientClasses = []string{"voip", "printers"}
+
+	tests := []struct {
+		name    string
+		classes []string
+		want    bool
+	}{
+		{"member of one class", []string{"guests", "voip"}, true},
+		{"member of none", []string{"guests"}, false},
+		{"no classes", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Matches(MatchCriteria{Classes: tt.classes}); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolMatchesRadiusClass(t *testing.T) {
+	p := newMatcherPool(t, "staff", "", "", "", "")
+	p.MatchRadiusClass = "staff-*"
+
+	if !p.HasMatchCriteria() {
+		t.Error("HasMatchCriteria() should be true with MatchRadiusClass")
+	}
+	if !p.Matches(MatchCriteria{RadiusClass: "staff-eng"}) {
+		t.Error("staff-eng should match staff-*")
+	}
+	if p.Matches(MatchCriteria{RadiusClass: "guest"}) {
+		t.Error("guest should not match staff-*")
+	}
+	if p.Matches(MatchCriteria{}) {
+		t.Error("missing class should not match")
+	}
+}
+
+func TestSelectOverflow(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/24")
+	base, _ := NewPool("base", net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 10), network)
+	staff, _ := NewPool("staff", net.IPv4(10, 0, 0, 20), net.IPv4(10, 0, 0, 20), network)
+	staff.Overflow = true
+	staff.ClientClasses = []string{"staff"}
+	spill, _ := NewPool("spill", net.IPv4(10, 0, 0, 30), net.IPv4(10, 0, 0, 30), network)
+	spill.Overflow = true
+	pools := []*Pool{staff, spill, base}
+
+	if got := SelectPool(pools, MatchCriteria{Classes: []string{"staff"}}); got != base {
+		t.Errorf("SelectPool = %v, want base: overflow pools are never selected", got)
+	}
+	if got := SelectOverflow(pools, MatchCriteria{Classes: []string{"staff"}}); got != staff {
+		t.Errorf("SelectOverflow for staff = %v, want staff", got)
+	}
+	if got := SelectOverflow(pools, MatchCriteria{}); got != spill {
+		t.Errorf("SelectOverflow = %v, want spill", got)
+	}
+	spill.Allocate()
+	if got := SelectOverflow(pools, MatchCriteria{}); got != nil {
+		t.Errorf("SelectOverflow with the overflow pool full = %v, want nil", got)
+	}
+}
@@ -0,0 +1,27 @@
+package pool
+
+// Pressure is how close a pool is to running out of addresses.
+type Pressure int
+
+// Pressure levels, in rising order.
+const (
+	PressureNormal    Pressure = iota
+	PressureHigh               // utilisation above the subnet's high_utilisation
+	PressureExhausted          // an allocation found no free address
+)
+
+// SignalPressure records the pool's current pressure level and reports
+// whether it rose above the highest level signalled so far, i.e. whether an
+// alert is due. Dropping to a lower level re-arms the alerts above it, so
+// a pool that fills up, drains and fills up again alerts twice.
+func (p *Pool) SignalPressure(level Pressure) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if level <= p.signalled {
+		p.signalled = level
+		return false
+	}
+	p.signalled = level
+	return true
+}
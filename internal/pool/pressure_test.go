@@ -0,0 +1,25 @@
+package pool
+
+import "testing"
+
+func TestSignalPressure(t *testing.T) {
+	p := newTestPool(t)
+	steps := []struct {
+		level Pressure
+		want  bool
+	}{
+		{PressureNormal, false},
+		{PressureHigh, true},
+		{PressureHigh, false}, // already signalled
+		{PressureExhausted, true},
+		{PressureHigh, false}, // draining
+		{PressureExhausted, true},
+		{PressureNormal, false},
+		{PressureHigh, true}, // re-armed
+	}
+	for i, s := range steps {
+		if got := p.SignalPressure(s.level); got != s.want {
+			t.Errorf("step %d: SignalPressure(%d) = %v, want %v", i, s.level, got, s.want)
+		}
+	}
+}
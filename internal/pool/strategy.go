@@ -0,0 +1,116 @@
+package pool
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+// noneFree is the wordOldest value of a word with nothing allocatable.
+const noneFree = math.MaxUint32
+
+// pick returns the next allocatable offset within the pool's share that
+// the pool's strategy hands out. Must be called under lock.
+//
+//   - lru: the IP free the longest — never-used ones first, lowest first
+//   - sequential: the lowest free IP
+//   - random: a free IP at random
+func (p *Pool) pick() (uint32, bool) {
+	lo, hi := p.shareLo, p.shareHi
+	if lo >= hi {
+		return 0, false
+	}
+	switch p.Strategy {
+	case config.PoolStrategySequential:
+		return p.nextAllocatable(lo, hi)
+	case config.PoolStrategyRandom:
+		from := lo + rand.Uint32N(hi-lo)
+		if offset, ok := p.nextAllocatable(from, hi); ok {
+			return offset, true
+		}
+		return p.nextAllocatable(lo, from)
+	default:
+		return p.oldestFree(lo, hi)
+	}
+}
+
+// nextAllocatable returns the first allocatable offset in [from, to),
+// skipping bitmap words with nothing allocatable.
+func (p *Pool) nextAllocatable(from, to uint32) (uint32, bool) {
+	for offset := from; offset < to; {
+		w := offset / 64
+		if p.bitmap[w]|p.held[w] == ^uint64(0) {
+			offset = (w + 1) * 64 // Nothing allocatable in this word
+			continue
+		}
+		if p.allocatable(offset) {
+			return offset, true
+		}
+		offset++
+	}
+	return 0, false
+}
+
+// oldestFree returns the allocatable offset in [lo, hi) with the oldest
+// free time, the lowest on ties. Words wholly inside the range are
+// compared by wordOldest; only the edge words are looked at address by
+// address.
+func (p *Pool) oldestFree(lo, hi uint32) (uint32, bool) {
+	bestWord, best := uint32(0), uint32(noneFree)
+	for w := lo / 64; w*64 < hi; w++ {
+		oldest := p.wordOldest[w]
+		if w*64 < lo || w*64+64 > hi {
+			oldest = p.oldestIn(max(lo, w*64), min(hi, w*64+64))
+		}
+		if oldest < best {
+			bestWord, best = w, oldest
+		}
+	}
+	if best == noneFree {
+		return 0, false
+	}
+	for offset := max(lo, bestWord*64); offset < min(hi, bestWord*64+64); offset++ {
+		if p.allocatable(offset) && p.freedAt[offset] == best {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// oldestIn returns the oldest free time among the allocatable offsets in
+// [from, to), or noneFree.
+func (p *Pool) oldestIn(from, to uint32) uint32 {
+	oldest := uint32(noneFree)
+	for offset := from; offset < to; offset++ {
+		if p.allocatable(offset) && p.freedAt[offset] < oldest {
+			oldest = p.freedAt[offset]
+		}
+	}
+	return oldest
+}
+
+// touch recomputes wordOldest for bitmap word w after one of its IPs
+// changed state.
+func (p *Pool) touch(w uint32) {
+	p.wordOldest[w] = p.oldestIn(w*64, min(p.size, w*64+64))
+}
+
+// tick advances the free clock and returns the new time. In the unlikely
+// event of it running out, every free time collapses to 1: the order among
+// used IPs is forgotten, never-used ones still go first.
+func (p *Pool) tick() uint32 {
+	if p.clock == noneFree-1 {
+		for i, t := range p.freedAt {
+			if t != 0 {
+				p.freedAt[i] = 1
+			}
+		}
+		for w := range p.wordOldest {
+			p.touch(uint32(w))
+		}
+		p.clock = 1
+	}
+	p.clock++
+	return p.clock
+}
@@ -0,0 +1,126 @@
+package pool
+
+import (
+	"math/rand/v2"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/config"
+)
+
+func TestPoolStrategies(t *testing.T) {
+	ip := func(last byte) net.IP { return net.IPv4(192, 168, 1, last) }
+	tests := []struct {
+		strategy string
+		want     net.IP // after .100-.105 are handed out and .104 then .101 freed
+	}{
+		{"", ip(106)},                            // never used first
+		{config.PoolStrategyLRU, ip(106)},        // never used first
+		{config.PoolStrategySequential, ip(101)}, // lowest free
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			p := newTestPool(t)
+			p.Strategy = tt.strategy
+			for range 6 {
+				p.Allocate()
+			}
+			p.Release(ip(104))
+			p.Release(ip(101))
+			if got := p.Allocate(); !got.Equal(tt.want) {
+				t.Errorf("Allocate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Random hands out every free address exactly once
+	p := newTestPool(t)
+	p.Strategy = config.PoolStrategyRandom
+	p.SetShare(1, 2) // .105-.110
+	seen := map[string]bool{}
+	for range 6 {
+		got := p.Allocate()
+		if got == nil || seen[got.String()] || !p.Owns(got) {
+			t.Fatalf("Allocate = %s, seen %v", got, seen)
+		}
+		seen[got.String()] = true
+	}
+	if got := p.Allocate(); got != nil {
+		t.Errorf("Allocate on a full share = %s, want nil", got)
+	}
+}
+
+// reuseDistances churns a pool of 1024 with about 900 addresses in use,
+// releasing a random one and allocating another 20000 times, and returns,
+// sorted, how many releases happened between an address being freed and
+// handed out again.
+func reuseDistances(t *testing.T, strategy string) []int {
+	_, network, _ := net.ParseCIDR("10.0.0.0/22")
+	p, err := NewPool("churn", net.IPv4(10, 0, 0, 0), net.IPv4(10, 0, 3, 255), network)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	p.Strategy = strategy
+	inUse := make([]net.IP, 900)
+	for i := range inUse {
+		inUse[i] = p.Allocate()
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	freedAt := map[string]int{}
+	var distances []int
+	for step := range 20000 {
+		i := rng.IntN(len(inUse))
+		p.Release(inUse[i])
+		freedAt[inUse[i].String()] = step
+
+		inUse[i] = p.Allocate()
+		if at, ok := freedAt[inUse[i].String()]; ok {
+			distances = append(distances, step-at)
+		}
+	}
+	slices.Sort(distances)
+	return distances
+}
+
+func TestStrategyReuseDistance(t *testing.T) {
+	p10 := map[string]int{}
+	for _, strategy := range []string{config.PoolStrategyLRU, config.PoolStrategySequential, config.PoolStrategyRandom} {
+		d := reuseDistances(t, strategy)
+		p10[strategy] = d[len(d)/10]
+		if strategy == config.PoolStrategyLRU && d[0] < 1024-900 {
+			// Every other free address is handed out first
+			t.Errorf("lru shortest reuse distance = %d, want at least %d", d[0], 1024-900)
+		}
+	}
+	t.Logf("10th percentile reuse distance: %v", p10)
+	if !(p10[config.PoolStrategyLRU] > p10[config.PoolStrategyRandom] && p10[config.PoolStrategyRandom] > p10[config.PoolStrategySequential]) {
+		t.Errorf("10th percentile reuse distance = %v, want lru > random > sequential", p10)
+	}
+}
+
+func TestStrategyLargePool(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/16")
+	p, err := NewPool("big", net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 255, 254), network)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	if got := len(p.AllocateN(65534)); got != 65534 {
+		t.Fatalf("AllocateN = %d, want 65534", got)
+	}
+	for range 65534 {
+		p.Allocate()
+	}
+	// Free addresses spread over the pool; they come back oldest first
+	var freed []net.IP
+	for i := 0; i < 65534; i += 4099 {
+		ip := p.offsetToIP(uint32(i))
+		p.Release(ip)
+		freed = append(freed, ip)
+	}
+	for _, want := range freed {
+		if got := p.Allocate(); !got.Equal(want) {
+			t.Fatalf("Allocate = %s, want %s", got, want)
+		}
+	}
+}
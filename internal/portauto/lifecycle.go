@@ -0,0 +1,264 @@
+package portauto
+
+import (
+	"strings"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+)
+
+// maxHistory is how many executions are kept per rule.
+const maxHistory = 50
+
+// Webhooks from lease events run on a small worker pool so a slow endpoint
+// can't stall the event consumer. When the queue is full the webhook is
+// dropped and recorded as failed.
+const (
+	webhookWorkers   = 4
+	webhookQueueSize = 256
+	webhookTimeout   = 10 * time.Second
+)
+
+// Execution records one run of a rule triggered by a lease event.
+type Execution struct {
+	Time    time.Time      `json:"time"`
+	Event   string         `json:"event"`
+	MAC     string         `json:"mac"`
+	IP      string         `json:"ip"`
+	Subnet  string         `json:"subnet"`
+	Actions []ActionResult `json:"actions"`
+
+	seq uint64 // lets a webhook worker find the entry to fill in its result
+}
+
+// webhookJob is a webhook queued by Process. idx is the action's position in
+// the execution's Actions.
+type webhookJob struct {
+	action Action
+	ctx    LeaseContext
+	rule   string
+	seq    uint64
+	idx    int
+}
+
+// Enricher fills in the parts of a LeaseContext that lease events don't
+// carry, like the fingerprinted device type and the MAC vendor.
+type Enricher func(ctx *LeaseContext)
+
+// Start subscribes to the event bus and runs rules on lease.ack, lease.renew,
+// lease.release and lease.expire. It blocks until Stop is called.
+// enrich may be nil.
+func (e *Engine) Start(bus *events.Bus, enrich Enricher) {
+	ch := bus.Subscribe(500)
+	e.stateMu.Lock()
+	e.bus, e.ch = bus, ch
+	e.stateMu.Unlock()
+	e.logger.Info("port automation subscribed to lease events")
+
+	for {
+		select {
+		case evt, ok := <-ch:
+			if !ok {
+				return
+			}
+			if ctx, ok := leaseContextFromEvent(evt); ok {
+				if enrich != nil {
+					enrich(&ctx)
+				}
+				e.Process(ctx)
+			}
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Stop unsubscribes from the event bus.
+func (e *Engine) Stop() {
+	close(e.done)
+	e.stateMu.Lock()
+	bus, ch := e.bus, e.ch
+	e.stateMu.Unlock()
+	if ch != nil {
+		bus.Unsubscribe(ch)
+	}
+}
+
+// leaseContextFromEvent builds the rule context for a lease lifecycle event.
+func leaseContextFromEvent(evt events.Event) (LeaseContext, bool) {
+	switch evt.Type {
+	case events.EventLeaseAck, events.EventLeaseRenew, events.EventLeaseRelease, events.EventLeaseExpire:
+	default:
+		return LeaseContext{}, false
+	}
+	if evt.Lease == nil || evt.Lease.MAC == "" {
+		return LeaseContext{}, false
+	}
+	l := evt.Lease
+	ctx := LeaseContext{
+		MAC:      l.MAC,
+		Hostname: l.Hostname,
+		Subnet:   l.Subnet,
+		Event:    string(evt.Type),
+	}
+	if l.IP != nil {
+		ctx.IP = l.IP.String()
+	}
+	if l.Relay != nil {
+		ctx.CircuitID = l.Relay.CircuitID
+		ctx.RemoteID = l.Relay.RemoteID
+	}
+	return ctx, true
+}
+
+// Process runs every matching rule for a lease event and returns the rules
+// that executed. A rule that already ran for a MAC with an identical context
+// is skipped on ack/renew, so renewals don't re-fire webhooks; release and
+// expire always run and reset that state. Webhooks are queued for the worker
+// pool; their results show up in the history once they return.
+func (e *Engine) Process(ctx LeaseContext) []MatchResult {
+	down := ctx.Event == string(events.EventLeaseRelease) || ctx.Event == string(events.EventLeaseExpire)
+	sig := contextSignature(ctx)
+
+	var executed []MatchResult
+	for _, m := range e.Evaluate(ctx) {
+		if !down {
+			e.stateMu.Lock()
+			rules := e.applied[ctx.MAC]
+			if rules == nil {
+				rules = make(map[string]string)
+				e.applied[ctx.MAC] = rules
+			}
+			dup := rules[m.Rule] == sig
+			rules[m.Rule] = sig
+			e.stateMu.Unlock()
+			if dup {
+				metrics.PortAutoExecutions.WithLabelValues(m.Rule, "deduplicated").Inc()
+				continue
+			}
+		}
+
+		exec := Execution{
+			Time:   time.Now(),
+			Event:  ctx.Event,
+			MAC:    ctx.MAC,
+			IP:     ctx.IP,
+			Subnet: ctx.Subnet,
+		}
+		e.stateMu.Lock()
+		e.execSeq++
+		exec.seq = e.execSeq
+		e.stateMu.Unlock()
+
+		result := "executed"
+		var queued []webhookJob
+		for i, a := range m.Actions {
+			if a.Type == "webhook" {
+				// Status is filled in by the worker once the webhook returns
+				queued = append(queued, webhookJob{action: a, ctx: ctx, rule: m.Rule, seq: exec.seq, idx: i})
+				exec.Actions = append(exec.Actions, ActionResult{Type: a.Type})
+				continue
+			}
+			ar := e.executeAction(a, ctx, m.Rule)
+			if ar.Error != "" {
+				result = "failed"
+			}
+			exec.Actions = append(exec.Actions, ar)
+		}
+		// Record before queueing so the workers always find the entry
+		e.record(m.Rule, exec)
+		for _, job := range queued {
+			select {
+			case e.webhooks <- job:
+			default:
+				e.logger.Warn("port-auto webhook queue full, dropping webhook", "rule", m.Rule, "mac", ctx.MAC, "url", job.action.URL)
+				e.setActionResult(job, ActionResult{Type: job.action.Type, Error: "webhook queue full"})
+				result = "failed"
+			}
+		}
+		metrics.PortAutoExecutions.WithLabelValues(m.Rule, result).Inc()
+		executed = append(executed, m)
+	}
+
+	// The lease is gone — the next ack runs every rule again
+	if down {
+		e.forgetMAC(ctx.MAC)
+	}
+	return executed
+}
+
+// contextSignature identifies the parts of a lease context that matter for
+// deduplication. The event itself is left out so ack and renew compare equal.
+func contextSignature(ctx LeaseContext) string {
+	return strings.Join([]string{
+		ctx.IP, ctx.Subnet, ctx.Hostname, ctx.CircuitID, ctx.RemoteID, ctx.DeviceType, ctx.Vendor,
+	}, "|")
+}
+
+func (e *Engine) forgetMAC(mac string) {
+	e.stateMu.Lock()
+	delete(e.applied, mac)
+	e.stateMu.Unlock()
+}
+
+// webhookWorker fires queued webhooks until Stop is called.
+func (e *Engine) webhookWorker() {
+	for {
+		select {
+		case job := <-e.webhooks:
+			ar := e.executeAction(job.action, job.ctx, job.rule)
+			if ar.Error != "" {
+				metrics.PortAutoExecutions.WithLabelValues(job.rule, "webhook_failed").Inc()
+			}
+			e.setActionResult(job, ar)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// setActionResult fills in a queued action's result in the rule history. The
+// entry may already have been pushed out of the history, which is fine.
+func (e *Engine) setActionResult(job webhookJob, ar ActionResult) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	h := e.history[job.rule]
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].seq == job.seq {
+			h[i].Actions[job.idx] = ar
+			return
+		}
+	}
+}
+
+func (e *Engine) record(rule string, exec Execution) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	h := append(e.history[rule], exec)
+	if len(h) > maxHistory {
+		h = h[len(h)-maxHistory:]
+	}
+	e.history[rule] = h
+}
+
+// History returns recent executions, newest first. With rule set, only that
+// rule's executions are returned.
+func (e *Engine) History(rule string) map[string][]Execution {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	out := make(map[string][]Execution)
+	for name, h := range e.history {
+		if rule != "" && name != rule {
+			continue
+		}
+		cp := make([]Execution, len(h))
+		for i := range h {
+			cp[i] = h[len(h)-1-i]
+			// Webhook workers fill in results later, so don't share the slice
+			cp[i].Actions = append([]ActionResult(nil), cp[i].Actions...)
+		}
+		out[name] = cp
+	}
+	return out
+}
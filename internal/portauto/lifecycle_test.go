@@ -0,0 +1,205 @@
+package portauto
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+)
+
+func TestProcessDeduplicatesRenewals(t *testing.T) {
+	e := NewEngine(testLogger())
+	e.SetRules([]Rule{{Name: "log-all", Enabled: true, Actions: []Action{{Type: "log"}}}})
+
+	ctx := LeaseContext{MAC: "aa:bb:cc:00:00:01", IP: "10.0.0.5", Subnet: "10.0.0.0/24", Event: "lease.ack"}
+	if got := e.Process(ctx); len(got) != 1 {
+		t.Fatalf("first ack: %d executions, want 1", len(got))
+	}
+
+	ctx.Event = "lease.renew"
+	if got := e.Process(ctx); len(got) != 0 {
+		t.Errorf("identical renew should be deduplicated, got %d", len(got))
+	}
+
+	// A changed context (new IP) runs again
+	ctx.IP = "10.0.0.6"
+	if got := e.Process(ctx); len(got) != 1 {
+		t.Errorf("renew with new IP: %d executions, want 1", len(got))
+	}
+
+	// Release always runs and resets the state
+	ctx.Event = "lease.release"
+	if got := e.Process(ctx); len(got) != 1 {
+		t.Errorf("release: %d executions, want 1", len(got))
+	}
+	ctx.Event = "lease.ack"
+	if got := e.Process(ctx); len(got) != 1 {
+		t.Errorf("ack after release: %d executions, want 1", len(got))
+	}
+
+	hist := e.History("log-all")["log-all"]
+	if len(hist) != 4 {
+		t.Fatalf("history has %d entries, want 4", len(hist))
+	}
+	if hist[0].Event != "lease.ack" || hist[1].Event != "lease.release" {
+		t.Errorf("history not newest first: %s, %s", hist[0].Event, hist[1].Event)
+	}
+}
+
+func TestRuleEventFilter(t *testing.T) {
+	e := NewEngine(testLogger())
+	e.SetRules([]Rule{{
+		Name:    "disable-on-expiry",
+		Enabled: true,
+		Events:  []string{"lease.release", "lease.expire"},
+		Actions: []Action{{Type: "log"}},
+	}})
+
+	if got := e.Process(LeaseContext{MAC: "aa:bb:cc:00:00:01", Event: "lease.ack"}); len(got) != 0 {
+		t.Error("rule limited to release/expire should not run on ack")
+	}
+	if got := e.Process(LeaseContext{MAC: "aa:bb:cc:00:00:01", Event: "lease.expire"}); len(got) != 1 {
+		t.Error("rule should run on expire")
+	}
+	// Evaluate without an event (test endpoint) ignores the filter
+	if got := e.Evaluate(LeaseContext{MAC: "aa:bb:cc:00:00:01"}); len(got) != 1 {
+		t.Error("Evaluate without event should ignore the event filter")
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	e := NewEngine(testLogger())
+	e.SetRules([]Rule{{Name: "r", Enabled: true, Actions: []Action{{Type: "log"}}}})
+	for i := 0; i < maxHistory+10; i++ {
+		e.Process(LeaseContext{MAC: "aa:bb:cc:00:00:01", IP: net.IPv4(10, 0, byte(i>>8), byte(i)).String(), Event: "lease.ack"})
+	}
+	if n := len(e.History("")["r"]); n != maxHistory {
+		t.Errorf("history has %d entries, want %d", n, maxHistory)
+	}
+}
+
+func TestStartRunsRulesFromBus(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&p)
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus(100, testLogger())
+	go bus.Start()
+	defer bus.Stop()
+
+	e := NewEngine(testLogger())
+	e.SetRules([]Rule{{
+		Name:        "printers",
+		Enabled:     true,
+		DeviceTypes: []string{"printer"},
+		Actions:     []Action{{Type: "webhook", URL: srv.URL, VLAN: 30}},
+	}})
+	go e.Start(bus, func(ctx *LeaseContext) {
+		ctx.DeviceType = "printer"
+		ctx.Vendor = "HP"
+	})
+	defer e.Stop()
+
+	lease := &events.LeaseData{
+		IP:     net.IPv4(10, 0, 0, 9),
+		MAC:    "aa:bb:cc:00:00:09",
+		Subnet: "10.0.0.0/24",
+		Relay:  &events.RelayData{CircuitID: "eth0/1/9"},
+	}
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(payloads)
+	}
+
+	// The engine subscribes asynchronously — keep acking until the first
+	// webhook arrives. Repeats are deduplicated, so only one fires.
+	deadline := time.Now().Add(2 * time.Second)
+	for received() == 0 && time.Now().Before(deadline) {
+		bus.Publish(events.Event{Type: events.EventLeaseAck, Lease: lease})
+		time.Sleep(20 * time.Millisecond)
+	}
+	bus.Publish(events.Event{Type: events.EventLeaseRenew, Lease: lease})
+	bus.Publish(events.Event{Type: events.EventLeaseDiscover, Lease: lease})
+	bus.Publish(events.Event{Type: events.EventLeaseExpire, Lease: lease})
+	for received() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 2 {
+		t.Fatalf("got %d webhooks, want 2 (ack + expire, renew deduplicated)", len(payloads))
+	}
+	first := payloads[0]
+	if first["event"] != "lease.ack" || first["vendor"] != "HP" || first["circuit_id"] != "eth0/1/9" || first["vlan"] != float64(30) {
+		t.Errorf("ack payload = %v", first)
+	}
+	if payloads[1]["event"] != "lease.expire" {
+		t.Errorf("second payload event = %v", payloads[1]["event"])
+	}
+
+	hist := e.History("printers")["printers"]
+	if len(hist) != 2 || hist[1].Actions[0].Status != http.StatusOK {
+		t.Errorf("history = %+v", hist)
+	}
+}
+
+func TestSlowWebhookDoesNotBlockProcess(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	e := NewEngine(testLogger())
+	defer e.Stop()
+	e.SetRules([]Rule{{Name: "hook", Enabled: true, Actions: []Action{{Type: "webhook", URL: srv.URL}}}})
+
+	start := time.Now()
+	for i := 0; i < webhookWorkers+webhookQueueSize+5; i++ {
+		mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0, byte(i >> 8), byte(i)}.String()
+		e.Process(LeaseContext{MAC: mac, IP: "10.0.0.5", Event: "lease.ack"})
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Process blocked on a slow webhook for %v", d)
+	}
+
+	// Workers are stuck and the queue is full, so the newest run was dropped
+	hist := e.History("hook")["hook"]
+	if len(hist) == 0 || hist[0].Actions[0].Error != "webhook queue full" {
+		t.Errorf("newest execution = %+v, want a dropped webhook", hist[0])
+	}
+}
+
+func TestReleaseForgetsMAC(t *testing.T) {
+	e := NewEngine(testLogger())
+	e.SetRules([]Rule{
+		{Name: "a", Enabled: true, Actions: []Action{{Type: "log"}}},
+		{Name: "b", Enabled: true, Actions: []Action{{Type: "log"}}},
+	})
+	ctx := LeaseContext{MAC: "aa:bb:cc:00:00:01", IP: "10.0.0.5", Event: "lease.ack"}
+	e.Process(ctx)
+	ctx.Event = "lease.expire"
+	e.Process(ctx)
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	if len(e.applied) != 0 {
+		t.Errorf("applied still has %d MACs after expiry", len(e.applied))
+	}
+}
@@ -6,6 +6,7 @@ package portauto
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -14,6 +15,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 )
 
 // Action defines what to do when a rule matches.
@@ -36,6 +39,7 @@ type Rule struct {
 	CircuitIDs  []string `json:"circuit_ids,omitempty"`  // regex patterns
 	RemoteIDs   []string `json:"remote_ids,omitempty"`   // regex patterns
 	DeviceTypes []string `json:"device_types,omitempty"` // from fingerprinting
+	Events      []string `json:"events,omitempty"`       // lease events that trigger the rule, empty = all
 	Actions     []Action `json:"actions"`
 
 	// Compiled regexes (not serialized)
@@ -54,6 +58,7 @@ type LeaseContext struct {
 	RemoteID   string `json:"remote_id"`
 	DeviceType string `json:"device_type"`
 	Vendor     string `json:"vendor"`
+	Event      string `json:"event,omitempty"` // lease event that triggered evaluation, empty for tests
 }
 
 // MatchResult holds the result of evaluating rules against a lease.
@@ -62,20 +67,46 @@ type MatchResult struct {
 	Actions []Action `json:"actions"`
 }
 
+// ActionResult is the outcome of running one action.
+type ActionResult struct {
+	Type   string `json:"type"`
+	Status int    `json:"status,omitempty"` // HTTP status for webhooks
+	Error  string `json:"error,omitempty"`
+}
+
 // Engine evaluates port automation rules against lease events.
 type Engine struct {
 	mu     sync.RWMutex
 	rules  []Rule
 	logger *slog.Logger
 	client *http.Client
+
+	// Lease lifecycle state, see lifecycle.go
+	stateMu  sync.Mutex
+	applied  map[string]map[string]string // mac -> rule -> signature of the last executed context
+	history  map[string][]Execution       // rule -> recent executions, newest last
+	execSeq  uint64
+	webhooks chan webhookJob
+
+	bus  *events.Bus
+	ch   chan events.Event
+	done chan struct{}
 }
 
 // NewEngine creates a new port automation engine.
 func NewEngine(logger *slog.Logger) *Engine {
-	return &Engine{
-		logger: logger,
-		client: &http.Client{Timeout: 10 * time.Second},
+	e := &Engine{
+		logger:   logger,
+		client:   &http.Client{Timeout: webhookTimeout},
+		applied:  make(map[string]map[string]string),
+		history:  make(map[string][]Execution),
+		webhooks: make(chan webhookJob, webhookQueueSize),
+		done:     make(chan struct{}),
+	}
+	for i := 0; i < webhookWorkers; i++ {
+		go e.webhookWorker()
 	}
+	return e
 }
 
 // SetRules replaces all rules. Compiles regex patterns.
@@ -109,6 +140,11 @@ func (e *Engine) SetRules(rules []Rule) error {
 	e.mu.Lock()
 	e.rules = compiled
 	e.mu.Unlock()
+
+	// Rules changed — let the next renewal re-apply them
+	e.stateMu.Lock()
+	e.applied = make(map[string]map[string]string)
+	e.stateMu.Unlock()
 	return nil
 }
 
@@ -186,13 +222,23 @@ func (e *Engine) matches(r *Rule, ctx LeaseContext) bool {
 			return false
 		}
 	}
+	if len(r.Events) > 0 && ctx.Event != "" {
+		if !containsStr(r.Events, ctx.Event) {
+			return false
+		}
+	}
 	return true
 }
 
-func (e *Engine) executeAction(a Action, ctx LeaseContext, ruleName string) {
+func (e *Engine) executeAction(a Action, ctx LeaseContext, ruleName string) ActionResult {
+	result := ActionResult{Type: a.Type}
 	switch a.Type {
 	case "webhook":
-		e.fireWebhook(a, ctx, ruleName)
+		status, err := e.fireWebhook(a, ctx, ruleName)
+		result.Status = status
+		if err != nil {
+			result.Error = err.Error()
+		}
 	case "log":
 		e.logger.Info("port-auto rule matched",
 			"rule", ruleName,
@@ -205,10 +251,16 @@ func (e *Engine) executeAction(a Action, ctx LeaseContext, ruleName string) {
 			"tag", a.Tag,
 			"vlan", a.VLAN,
 			"mac", ctx.MAC)
+	default:
+		result.Error = fmt.Sprintf("unknown action type %q", a.Type)
 	}
+	return result
 }
 
-func (e *Engine) fireWebhook(a Action, ctx LeaseContext, ruleName string) {
+func (e *Engine) fireWebhook(a Action, ctx LeaseContext, ruleName string) (int, error) {
+	reqCtx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
 	payload := map[string]interface{}{
 		"rule":        ruleName,
 		"action":      a.Type,
@@ -222,6 +274,9 @@ func (e *Engine) fireWebhook(a Action, ctx LeaseContext, ruleName string) {
 		"vendor":      ctx.Vendor,
 		"timestamp":   time.Now().UTC().Format(time.RFC3339),
 	}
+	if ctx.Event != "" {
+		payload["event"] = ctx.Event
+	}
 	if a.VLAN > 0 {
 		payload["vlan"] = a.VLAN
 	}
@@ -235,10 +290,10 @@ func (e *Engine) fireWebhook(a Action, ctx LeaseContext, ruleName string) {
 		method = "POST"
 	}
 
-	req, err := http.NewRequest(method, a.URL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(reqCtx, method, a.URL, bytes.NewReader(body))
 	if err != nil {
 		e.logger.Warn("port-auto webhook request failed", "error", err, "url", a.URL)
-		return
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	for k, v := range a.Headers {
@@ -248,10 +303,14 @@ func (e *Engine) fireWebhook(a Action, ctx LeaseContext, ruleName string) {
 	resp, err := e.client.Do(req)
 	if err != nil {
 		e.logger.Warn("port-auto webhook failed", "error", err, "url", a.URL, "rule", ruleName)
-		return
+		return 0, err
 	}
 	resp.Body.Close()
 	e.logger.Debug("port-auto webhook fired", "url", a.URL, "status", resp.StatusCode, "rule", ruleName)
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
 }
 
 func matchesAny(res []*regexp.Regexp, s string) bool {
@@ -5,8 +5,11 @@ package radius
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,8 +31,37 @@ type SubnetConfig struct {
 	Enabled        bool         `json:"enabled" toml:"enabled"`
 	Server         ServerConfig `json:"server" toml:"server"`
 	NASIdentifier  string       `json:"nas_identifier" toml:"nas_identifier"`
-	CallingStation bool         `json:"calling_station" toml:"calling_station"` // send MAC as Calling-Station-Id
-	SendOption82   bool         `json:"send_option82" toml:"send_option82"`     // send Option 82 attrs (NAS-Port-Id, Called-Station-Id, NAS-IP-Address)
+	CallingStation bool         `json:"calling_station" toml:"calling_station"`   // send MAC as Calling-Station-Id
+	SendOption82   bool         `json:"send_option82" toml:"send_option82"`       // send Option 82 attrs (NAS-Port-Id, Called-Station-Id, NAS-IP-Address)
+	CacheTTL       string       `json:"cache_ttl" toml:"cache_ttl"`               // how long an Access-Accept is reused, default 5m
+	RejectCacheTTL string       `json:"reject_cache_ttl" toml:"reject_cache_ttl"` // how long an Access-Reject is reused, default 1m
+	FailOpen       bool         `json:"fail_open" toml:"fail_open"`               // serve clients when the server can't be reached
+}
+
+// Validate checks the settings of an enabled subnet.
+func (cfg *SubnetConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(cfg.Server.Address); err != nil {
+		return fmt.Errorf("server address %q: %w", cfg.Server.Address, err)
+	}
+	if cfg.Server.Secret == "" {
+		return fmt.Errorf("server secret is required")
+	}
+	for name, v := range map[string]string{
+		"timeout":          cfg.Server.Timeout,
+		"cache_ttl":        cfg.CacheTTL,
+		"reject_cache_ttl": cfg.RejectCacheTTL,
+	} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s %q", name, v)
+		}
+	}
+	return nil
 }
 
 // Option82Info holds DHCP relay agent (Option 82) data for RADIUS attributes.
@@ -40,6 +72,12 @@ type Option82Info struct {
 	GIAddr    string // relay agent IP → NAS-IP-Address
 }
 
+// Default cache lifetimes for authentication results.
+const (
+	defaultCacheTTL       = 5 * time.Minute
+	defaultRejectCacheTTL = time.Minute
+)
+
 // AuthResult holds the result of a RADIUS authentication attempt.
 type AuthResult struct {
 	Accepted bool              `json:"accepted"`
@@ -47,6 +85,18 @@ type AuthResult struct {
 	Attrs    map[string]string `json:"attrs,omitempty"`
 	Error    string            `json:"error,omitempty"`
 	Latency  float64           `json:"latency_ms"`
+	Cached   bool              `json:"cached,omitempty"`
+
+	// Authorization attributes from an Access-Accept.
+	FramedIP       net.IP `json:"framed_ip,omitempty"`       // Framed-IP-Address (8)
+	SessionTimeout uint32 `json:"session_timeout,omitempty"` // Session-Timeout (27), seconds
+	Class          string `json:"class,omitempty"`           // Class (25)
+}
+
+// cacheEntry is a remembered authentication result.
+type cacheEntry struct {
+	result  AuthResult
+	expires time.Time
 }
 
 // Client handles RADIUS authentication for DHCP clients.
@@ -54,6 +104,7 @@ type Client struct {
 	logger  *slog.Logger
 	mu      sync.RWMutex
 	subnets map[string]*SubnetConfig // subnet CIDR -> config
+	cache   map[string]cacheEntry    // subnet|mac -> last result
 }
 
 // NewClient creates a new RADIUS client.
@@ -61,14 +112,17 @@ func NewClient(logger *slog.Logger) *Client {
 	return &Client{
 		logger:  logger,
 		subnets: make(map[string]*SubnetConfig),
+		cache:   make(map[string]cacheEntry),
 	}
 }
 
 // SetSubnet configures RADIUS for a specific subnet.
+// Cached results for the subnet are dropped.
 func (c *Client) SetSubnet(subnet string, cfg *SubnetConfig) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.subnets[subnet] = cfg
+	c.flushSubnetLocked(subnet)
 }
 
 // RemoveSubnet removes RADIUS configuration for a subnet.
@@ -76,6 +130,36 @@ func (c *Client) RemoveSubnet(subnet string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	delete(c.subnets, subnet)
+	c.flushSubnetLocked(subnet)
+}
+
+// ReplaceSubnets swaps in a complete set of per-subnet configs, e.g. after
+// loading from the database or a peer sync. The cache is cleared.
+func (c *Client) ReplaceSubnets(subnets map[string]SubnetConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subnets = make(map[string]*SubnetConfig, len(subnets))
+	for k, v := range subnets {
+		cfg := v
+		c.subnets[k] = &cfg
+	}
+	c.cache = make(map[string]cacheEntry)
+}
+
+// FlushCache forgets all cached authentication results.
+func (c *Client) FlushCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = make(map[string]cacheEntry)
+}
+
+func (c *Client) flushSubnetLocked(subnet string) {
+	prefix := subnet + "|"
+	for k := range c.cache {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.cache, k)
+		}
+	}
 }
 
 // GetSubnet returns the RADIUS configuration for a subnet.
@@ -101,8 +185,19 @@ func (c *Client) ListSubnets() map[string]SubnetConfig {
 	return result
 }
 
+// Enabled reports whether RADIUS is switched on for a subnet.
+func (c *Client) Enabled(subnet string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cfg, ok := c.subnets[subnet]
+	return ok && cfg.Enabled
+}
+
 // Authenticate performs RADIUS Access-Request for a DHCP client.
 // The opt82 parameter may be nil if no relay agent info is present.
+// Accepts and rejects are cached per subnet and MAC for the subnet's
+// cache_ttl / reject_cache_ttl; transport errors are never cached. With
+// fail_open set, an unreachable server yields an accept with Code "fail_open".
 func (c *Client) Authenticate(ctx context.Context, subnet string, mac net.HardwareAddr, username string, opt82 *Option82Info) AuthResult {
 	c.mu.RLock()
 	cfg, ok := c.subnets[subnet]
@@ -112,7 +207,50 @@ func (c *Client) Authenticate(ctx context.Context, subnet string, mac net.Hardwa
 		return AuthResult{Accepted: true, Code: "no_radius", Latency: 0}
 	}
 
-	return c.doAuth(ctx, cfg, mac, username, opt82)
+	key := subnet + "|" + mac.String()
+	now := time.Now()
+	c.mu.RLock()
+	entry, hit := c.cache[key]
+	c.mu.RUnlock()
+	if hit && now.Before(entry.expires) {
+		result := entry.result
+		result.Cached = true
+		result.Latency = 0
+		return result
+	}
+
+	result := c.doAuth(ctx, cfg, mac, username, opt82)
+	if result.Code == "error" {
+		if cfg.FailOpen {
+			result.Accepted = true
+			result.Code = "fail_open"
+		}
+		return result
+	}
+
+	ttl := parseTTL(cfg.CacheTTL, defaultCacheTTL)
+	if !result.Accepted {
+		ttl = parseTTL(cfg.RejectCacheTTL, defaultRejectCacheTTL)
+	}
+	if ttl > 0 {
+		c.mu.Lock()
+		c.pruneLocked(now)
+		c.cache[key] = cacheEntry{result: result, expires: now.Add(ttl)}
+		c.mu.Unlock()
+	}
+	return result
+}
+
+// pruneLocked drops expired cache entries once the cache gets large.
+func (c *Client) pruneLocked(now time.Time) {
+	if len(c.cache) < 1024 {
+		return
+	}
+	for k, e := range c.cache {
+		if !now.Before(e.expires) {
+			delete(c.cache, k)
+		}
+	}
 }
 
 // Test performs a test authentication against a RADIUS server.
@@ -182,6 +320,11 @@ func (c *Client) doAuth(ctx context.Context, cfg *SubnetConfig, mac net.Hardware
 		Code:     resp.Code.String(),
 		Latency:  latency,
 	}
+	if result.Accepted {
+		readReplyAttrs(resp, &result)
+	} else if msg := rfc2865.ReplyMessage_GetString(resp); msg != "" {
+		result.Attrs = map[string]string{"Reply-Message": msg}
+	}
 
 	c.logger.Debug("RADIUS auth result",
 		"server", cfg.Server.Address,
@@ -226,6 +369,41 @@ func (c *Client) doAuthWithPassword(ctx context.Context, cfg *SubnetConfig, user
 	}
 }
 
+// readReplyAttrs copies the authorization attributes of an Access-Accept.
+func readReplyAttrs(resp *radius.Packet, result *AuthResult) {
+	attrs := make(map[string]string)
+	if ip := rfc2865.FramedIPAddress_Get(resp); ip != nil && ip.To4() != nil {
+		result.FramedIP = ip.To4()
+		attrs["Framed-IP-Address"] = result.FramedIP.String()
+	}
+	if _, ok := resp.Lookup(rfc2865.SessionTimeout_Type); ok {
+		result.SessionTimeout = uint32(rfc2865.SessionTimeout_Get(resp))
+		attrs["Session-Timeout"] = strconv.FormatUint(uint64(result.SessionTimeout), 10)
+	}
+	if class := rfc2865.Class_GetString(resp); class != "" {
+		result.Class = class
+		attrs["Class"] = class
+	}
+	if msg := rfc2865.ReplyMessage_GetString(resp); msg != "" {
+		attrs["Reply-Message"] = msg
+	}
+	if len(attrs) > 0 {
+		result.Attrs = attrs
+	}
+}
+
+// parseTTL parses a cache lifetime. Empty means def; "0" disables caching.
+func parseTTL(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
 func parseTimeout(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil || d <= 0 {
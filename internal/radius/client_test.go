@@ -5,7 +5,12 @@ import (
 	"log/slog"
 	"net"
 	"os"
+	"sync/atomic"
 	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
 )
 
 func testLogger() *slog.Logger {
@@ -268,3 +273,161 @@ func TestGetSubnetReturnsCopy(t *testing.T) {
 		t.Error("GetSubnet should return a copy")
 	}
 }
+
+// startTestServer runs a RADIUS server on a random local UDP port. It counts
+// requests and answers with whatever handler returns.
+func startTestServer(t *testing.T, secret string, handler func(r *radius.Request) *radius.Packet) (string, *int32) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var count int32
+	srv := &radius.PacketServer{
+		SecretSource: radius.StaticSecretSource([]byte(secret)),
+		Handler: radius.HandlerFunc(func(w radius.ResponseWriter, r *radius.Request) {
+			atomic.AddInt32(&count, 1)
+			w.Write(handler(r))
+		}),
+	}
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return conn.LocalAddr().String(), &count
+}
+
+func TestAuthAcceptAttributes(t *testing.T) {
+	var gotUser, gotPort string
+	addr, _ := startTestServer(t, "s3cret", func(r *radius.Request) *radius.Packet {
+		gotUser = rfc2865.UserName_GetString(r.Packet)
+		gotPort = rfc2869.NASPortID_GetString(r.Packet)
+		resp := r.Response(radius.CodeAccessAccept)
+		rfc2865.FramedIPAddress_Set(resp, net.IPv4(10, 0, 0, 50))
+		rfc2865.SessionTimeout_Set(resp, 600)
+		rfc2865.Class_SetString(resp, "staff")
+		return resp
+	})
+
+	c := NewClient(testLogger())
+	c.SetSubnet("10.0.0.0/24", &SubnetConfig{
+		Enabled:      true,
+		Server:       ServerConfig{Address: addr, Secret: "s3cret", Timeout: "2s"},
+		SendOption82: true,
+	})
+
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01}
+	result := c.Authenticate(context.Background(), "10.0.0.0/24", mac, mac.String(), &Option82Info{CircuitID: "eth0/1/3"})
+
+	if !result.Accepted {
+		t.Fatalf("expected accept, got %+v", result)
+	}
+	if !result.FramedIP.Equal(net.IPv4(10, 0, 0, 50)) {
+		t.Errorf("FramedIP = %v", result.FramedIP)
+	}
+	if result.SessionTimeout != 600 {
+		t.Errorf("SessionTimeout = %d", result.SessionTimeout)
+	}
+	if result.Class != "staff" {
+		t.Errorf("Class = %q", result.Class)
+	}
+	if result.Attrs["Class"] != "staff" {
+		t.Errorf("Attrs = %v", result.Attrs)
+	}
+	if gotUser != mac.String() || gotPort != "eth0/1/3" {
+		t.Errorf("server saw user %q port %q", gotUser, gotPort)
+	}
+}
+
+func TestAuthCachesResults(t *testing.T) {
+	reject := int32(0)
+	addr, count := startTestServer(t, "s3cret", func(r *radius.Request) *radius.Packet {
+		if atomic.LoadInt32(&reject) == 1 {
+			return r.Response(radius.CodeAccessReject)
+		}
+		return r.Response(radius.CodeAccessAccept)
+	})
+
+	c := NewClient(testLogger())
+	c.SetSubnet("10.0.0.0/24", &SubnetConfig{
+		Enabled:        true,
+		Server:         ServerConfig{Address: addr, Secret: "s3cret", Timeout: "2s"},
+		CacheTTL:       "1h",
+		RejectCacheTTL: "0",
+	})
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01}
+
+	first := c.Authenticate(context.Background(), "10.0.0.0/24", mac, "u", nil)
+	second := c.Authenticate(context.Background(), "10.0.0.0/24", mac, "u", nil)
+	if !first.Accepted || !second.Accepted {
+		t.Fatal("expected accepts")
+	}
+	if first.Cached || !second.Cached {
+		t.Errorf("cached flags = %v, %v", first.Cached, second.Cached)
+	}
+	if n := atomic.LoadInt32(count); n != 1 {
+		t.Errorf("server saw %d requests, want 1", n)
+	}
+
+	// Rejects with a zero TTL are not cached, and flushing forces a new request.
+	atomic.StoreInt32(&reject, 1)
+	c.FlushCache()
+	for i := 0; i < 2; i++ {
+		if r := c.Authenticate(context.Background(), "10.0.0.0/24", mac, "u", nil); r.Accepted || r.Cached {
+			t.Errorf("attempt %d: %+v", i, r)
+		}
+	}
+	if n := atomic.LoadInt32(count); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+}
+
+func TestAuthFailOpen(t *testing.T) {
+	c := NewClient(testLogger())
+	c.SetSubnet("10.0.0.0/24", &SubnetConfig{
+		Enabled:  true,
+		Server:   ServerConfig{Address: "127.0.0.1:19999", Secret: "test", Timeout: "200ms"},
+		FailOpen: true,
+	})
+
+	result := c.Authenticate(context.Background(), "10.0.0.0/24",
+		net.HardwareAddr{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01}, "u", nil)
+	if !result.Accepted || result.Code != "fail_open" {
+		t.Errorf("result = %+v, want fail_open accept", result)
+	}
+	if result.Error == "" {
+		t.Error("fail_open should keep the error")
+	}
+}
+
+func TestReplaceSubnets(t *testing.T) {
+	c := NewClient(testLogger())
+	c.SetSubnet("10.0.0.0/24", &SubnetConfig{Enabled: true})
+	c.ReplaceSubnets(map[string]SubnetConfig{"192.168.1.0/24": {Enabled: true}})
+
+	if c.Enabled("10.0.0.0/24") {
+		t.Error("old subnet should be gone")
+	}
+	if !c.Enabled("192.168.1.0/24") {
+		t.Error("new subnet should be enabled")
+	}
+}
+
+func TestSubnetConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SubnetConfig
+		wantErr bool
+	}{
+		{"disabled", SubnetConfig{}, false},
+		{"valid", SubnetConfig{Enabled: true, Server: ServerConfig{Address: "10.0.0.5:1812", Secret: "x"}, CacheTTL: "10m"}, false},
+		{"no port", SubnetConfig{Enabled: true, Server: ServerConfig{Address: "10.0.0.5", Secret: "x"}}, true},
+		{"no secret", SubnetConfig{Enabled: true, Server: ServerConfig{Address: "10.0.0.5:1812"}}, true},
+		{"bad ttl", SubnetConfig{Enabled: true, Server: ServerConfig{Address: "10.0.0.5:1812", Secret: "x"}, RejectCacheTTL: "soon"}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
 	bolt "go.etcd.io/bbolt"
 )
@@ -32,7 +33,7 @@ type ServerEntry struct {
 
 // Detector monitors the network for rogue DHCP servers.
 type Detector struct {
-	db     *bolt.DB
+	db     boltdb.DB
 	bus    *events.Bus
 	logger *slog.Logger
 	ownIPs map[string]bool // our server IPs (to exclude)
@@ -42,7 +43,7 @@ type Detector struct {
 }
 
 // NewDetector creates a new rogue DHCP server detector.
-func NewDetector(db *bolt.DB, bus *events.Bus, ownIPs []net.IP, logger *slog.Logger) (*Detector, error) {
+func NewDetector(db boltdb.DB, bus *events.Bus, ownIPs []net.IP, logger *slog.Logger) (*Detector, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists(bucketRogue)
 		return err
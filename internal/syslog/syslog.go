@@ -483,6 +483,30 @@ func formatKV(evt events.Event) string {
 		parts = append(parts, fmt.Sprintf("count=%d", r.Count))
 	}
 
+	if evt.Pool != nil {
+		p := evt.Pool
+		parts = append(parts, fmt.Sprintf("subnet=%s pool=%s", p.Subnet, p.Pool))
+		parts = append(parts, fmt.Sprintf("allocated=%d size=%d utilisation=%.1f", p.Allocated, p.Size, p.Utilisation))
+		if p.Overflow != "" {
+			parts = append(parts, fmt.Sprintf("overflow=%s", p.Overflow))
+		}
+	}
+
+	if evt.Override != nil {
+		o := evt.Override
+		parts = append(parts, fmt.Sprintf("override_id=%s action=%s", o.ID, o.Action))
+		if o.Domain != "" {
+			parts = append(parts, fmt.Sprintf("domain=%s", o.Domain))
+		}
+		if o.Client != "" {
+			parts = append(parts, fmt.Sprintf("client=%s", o.Client))
+		}
+		parts = append(parts, fmt.Sprintf("expires=%d", o.Expires))
+		if o.By != "" {
+			parts = append(parts, fmt.Sprintf("by=%s", o.By))
+		}
+	}
+
 	if evt.Reason != "" {
 		parts = append(parts, fmt.Sprintf("reason=%s", evt.Reason))
 	}
@@ -564,6 +588,30 @@ func (f *Forwarder) formatCEF(evt events.Event) string {
 		ext = append(ext, fmt.Sprintf("cn1=%d cn1Label=DetectionCount", r.Count))
 	}
 
+	if evt.Pool != nil {
+		p := evt.Pool
+		ext = append(ext, fmt.Sprintf("cs1=%s cs1Label=Subnet", cefEscape(p.Subnet)))
+		ext = append(ext, fmt.Sprintf("cs3=%s cs3Label=Pool", cefEscape(p.Pool)))
+		ext = append(ext, fmt.Sprintf("cn1=%d cn1Label=PoolSize", p.Size))
+		ext = append(ext, fmt.Sprintf("cn2=%d cn2Label=PoolAllocated", p.Allocated))
+	}
+
+	if evt.Override != nil {
+		o := evt.Override
+		ext = append(ext, fmt.Sprintf("act=%s", cefEscape(o.Action)))
+		ext = append(ext, fmt.Sprintf("cs1=%s cs1Label=OverrideID", cefEscape(o.ID)))
+		if o.Domain != "" {
+			ext = append(ext, fmt.Sprintf("dhost=%s", cefEscape(o.Domain)))
+		}
+		if o.Client != "" {
+			ext = append(ext, fmt.Sprintf("cs2=%s cs2Label=Client", cefEscape(o.Client)))
+		}
+		if o.By != "" {
+			ext = append(ext, fmt.Sprintf("suser=%s", cefEscape(o.By)))
+		}
+		ext = append(ext, fmt.Sprintf("end=%d", o.Expires*1000))
+	}
+
 	if evt.Reason != "" {
 		ext = append(ext, fmt.Sprintf("msg=%s", cefEscape(evt.Reason)))
 	}
@@ -634,6 +682,20 @@ func cefSignatureID(t events.EventType) string {
 		return "401"
 	case events.EventAnomalyDetected:
 		return "500"
+	case events.EventRadiusReject:
+		return "600"
+	case events.EventTopologyPortMove:
+		return "700"
+	case events.EventPoolHigh:
+		return "800"
+	case events.EventPoolExhausted:
+		return "801"
+	case events.EventDNSOverrideAdd:
+		return "900"
+	case events.EventDNSOverrideRemove:
+		return "901"
+	case events.EventDNSOverrideExpire:
+		return "902"
 	default:
 		return "999"
 	}
@@ -679,6 +741,20 @@ func cefEventName(t events.EventType) string {
 		return "Rogue DHCP Server Resolved"
 	case events.EventAnomalyDetected:
 		return "Network Anomaly Detected"
+	case events.EventRadiusReject:
+		return "RADIUS Access Rejected"
+	case events.EventTopologyPortMove:
+		return "Device Moved Switch Port"
+	case events.EventPoolHigh:
+		return "DHCP Pool Utilisation High"
+	case events.EventPoolExhausted:
+		return "DHCP Pool Exhausted"
+	case events.EventDNSOverrideAdd:
+		return "DNS Filtering Override Added"
+	case events.EventDNSOverrideRemove:
+		return "DNS Filtering Override Removed"
+	case events.EventDNSOverrideExpire:
+		return "DNS Filtering Override Expired"
 	default:
 		return string(t)
 	}
@@ -691,17 +767,18 @@ func cefSeverity(t events.EventType) int {
 		return 7
 	case events.EventConflictPermanent:
 		return 7
-	case events.EventConflictDetected:
+	case events.EventConflictDetected, events.EventPoolExhausted:
 		return 5
 	case events.EventAnomalyDetected:
 		return 5
 	case events.EventHAFailover, events.EventHAPeerDown:
 		return 5
-	case events.EventLeaseDecline:
+	case events.EventLeaseDecline, events.EventRadiusReject, events.EventPoolHigh:
 		return 4
 	case events.EventConflictDecline:
 		return 4
-	case events.EventLeaseNak:
+	case events.EventLeaseNak, events.EventTopologyPortMove,
+		events.EventDNSOverrideAdd, events.EventDNSOverrideRemove:
 		return 3
 	case events.EventConflictResolved, events.EventRogueResolved:
 		return 2
@@ -716,14 +793,16 @@ func eventSeverity(t events.EventType) int {
 	switch t {
 	case events.EventRogueDetected:
 		return SeverityWarning
-	case events.EventConflictDetected, events.EventConflictPermanent:
+	case events.EventConflictDetected, events.EventConflictPermanent, events.EventPoolExhausted:
 		return SeverityWarning
 	case events.EventAnomalyDetected:
 		return SeverityWarning
-	case events.EventLeaseDecline:
+	case events.EventLeaseDecline, events.EventRadiusReject, events.EventPoolHigh:
 		return SeverityNotice
 	case events.EventHAFailover:
 		return SeverityNotice
+	case events.EventDNSOverrideAdd, events.EventDNSOverrideRemove:
+		return SeverityNotice
 	default:
 		return SeverityInfo
 	}
@@ -94,6 +94,7 @@ func TestEventSeverity(t *testing.T) {
 		{events.EventConflictDetected, SeverityWarning},
 		{events.EventAnomalyDetected, SeverityWarning},
 		{events.EventLeaseDecline, SeverityNotice},
+		{events.EventRadiusReject, SeverityNotice},
 		{events.EventHAFailover, SeverityNotice},
 	}
 
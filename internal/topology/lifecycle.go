@@ -0,0 +1,101 @@
+package topology
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+	"github.com/athena-dhcpd/athena-dhcpd/internal/metrics"
+)
+
+// Start subscribes to the event bus and keeps the map in step with relayed
+// leases: lease.ack and lease.renew record the device, lease.release and
+// lease.expire take it off its port. A device turning up on a new port
+// fires topology.port_move. It blocks until Stop is called.
+func (m *Map) Start(bus *events.Bus) {
+	ch := bus.Subscribe(500)
+	m.mu.Lock()
+	m.bus, m.ch = bus, ch
+	m.mu.Unlock()
+	m.logger.Info("topology map subscribed to lease events")
+
+	for {
+		select {
+		case evt, ok := <-ch:
+			if !ok {
+				return
+			}
+			m.handleEvent(evt)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Stop unsubscribes from the event bus.
+func (m *Map) Stop() {
+	close(m.done)
+	m.mu.Lock()
+	bus, ch := m.bus, m.ch
+	m.mu.Unlock()
+	if ch != nil {
+		bus.Unsubscribe(ch)
+	}
+}
+
+// handleEvent applies one lease event to the map.
+func (m *Map) handleEvent(evt events.Event) {
+	l := evt.Lease
+	if l == nil || l.MAC == "" {
+		return
+	}
+
+	switch evt.Type {
+	case events.EventLeaseAck, events.EventLeaseRenew:
+		if l.Relay == nil {
+			return
+		}
+		le := LeaseEvent{
+			CircuitID: l.Relay.CircuitID,
+			RemoteID:  l.Relay.RemoteID,
+			MAC:       l.MAC,
+			Hostname:  l.Hostname,
+			Subnet:    l.Subnet,
+		}
+		if l.Relay.GIAddr != nil {
+			le.GIAddr = l.Relay.GIAddr.String()
+		}
+		if l.IP != nil {
+			le.IP = l.IP.String()
+		}
+		move := m.Record(le)
+		if move == nil {
+			return
+		}
+
+		metrics.TopologyPortMoves.Inc()
+		m.logger.Info("device moved to a new switch port",
+			"mac", move.MAC,
+			"from_switch", move.FromSwitch,
+			"from_port", move.FromPort,
+			"to_switch", move.ToSwitch,
+			"to_port", move.ToPort)
+
+		m.mu.RLock()
+		bus := m.bus
+		m.mu.RUnlock()
+		if bus != nil {
+			bus.Publish(events.Event{
+				Type:      events.EventTopologyPortMove,
+				Timestamp: time.Now(),
+				Lease:     l,
+				Reason:    fmt.Sprintf("moved from %s port %s", move.FromSwitch, move.FromPort),
+			})
+		}
+
+	case events.EventLeaseRelease, events.EventLeaseExpire:
+		if m.RemoveDevice(l.MAC) {
+			m.logger.Debug("device aged out of topology", "mac", l.MAC, "event", string(evt.Type))
+		}
+	}
+}
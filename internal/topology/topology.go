@@ -12,6 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+
+	"github.com/athena-dhcpd/athena-dhcpd/internal/boltdb"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -58,16 +61,47 @@ type LeaseEvent struct {
 	Subnet    string
 }
 
+// PortMove describes a device seen on a different switch port than before.
+type PortMove struct {
+	MAC        string `json:"mac"`
+	FromSwitch string `json:"from_switch"`
+	FromPort   string `json:"from_port"`
+	ToSwitch   string `json:"to_switch"`
+	ToPort     string `json:"to_port"`
+}
+
+// SwitchUsage summarises port utilisation on one switch. Ports are the ones
+// learned so far; a port is active while it has at least one device.
+type SwitchUsage struct {
+	ID          string  `json:"id"`
+	Label       string  `json:"label,omitempty"`
+	Ports       int     `json:"ports"`
+	ActivePorts int     `json:"active_ports"`
+	Devices     int     `json:"devices"`
+	Utilization float64 `json:"utilization"` // active ports, percent
+}
+
+// location is where a device currently sits in the tree.
+type location struct {
+	switchKey string
+	portKey   string
+}
+
 // Map holds the full network topology learned from Option 82 data.
 type Map struct {
-	db      *bolt.DB
-	logger  *slog.Logger
-	mu      sync.RWMutex
+	db       boltdb.DB
+	logger   *slog.Logger
+	mu       sync.RWMutex
 	switches map[string]*SwitchNode // keyed by remote-id or giaddr
+	devices  map[string]location    // MAC -> current switch/port
+
+	bus  *events.Bus
+	ch   chan events.Event
+	done chan struct{}
 }
 
 // NewMap creates a new topology map backed by BoltDB.
-func NewMap(db *bolt.DB, logger *slog.Logger) (*Map, error) {
+func NewMap(db boltdb.DB, logger *slog.Logger) (*Map, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists(bucketTopology)
 		return err
@@ -80,6 +114,8 @@ func NewMap(db *bolt.DB, logger *slog.Logger) (*Map, error) {
 		db:       db,
 		logger:   logger,
 		switches: make(map[string]*SwitchNode),
+		devices:  make(map[string]location),
+		done:     make(chan struct{}),
 	}
 
 	if err := m.loadAll(); err != nil {
@@ -89,10 +125,11 @@ func NewMap(db *bolt.DB, logger *slog.Logger) (*Map, error) {
 	return m, nil
 }
 
-// Record processes a lease event and updates the topology map.
-func (m *Map) Record(evt LeaseEvent) {
+// Record processes a lease event and updates the topology map. If the device
+// was last seen on another port it is moved, and the move is returned.
+func (m *Map) Record(evt LeaseEvent) *PortMove {
 	if evt.CircuitID == "" && evt.RemoteID == "" {
-		return // no option 82 data
+		return nil // no option 82 data
 	}
 
 	now := time.Now()
@@ -142,6 +179,27 @@ func (m *Map) Record(evt LeaseEvent) {
 	}
 	port.LastSeen = now
 
+	// Take the device off the port it was last seen on
+	var move *PortMove
+	if prev, ok := m.devices[evt.MAC]; ok && prev != (location{switchKey, portKey}) {
+		if oldSw := m.switches[prev.switchKey]; oldSw != nil {
+			if oldPort := oldSw.Ports[prev.portKey]; oldPort != nil {
+				oldPort.Devices = removeDevice(oldPort.Devices, evt.MAC)
+			}
+			if prev.switchKey != switchKey {
+				m.persist(prev.switchKey, oldSw)
+			}
+		}
+		move = &PortMove{
+			MAC:        evt.MAC,
+			FromSwitch: prev.switchKey,
+			FromPort:   prev.portKey,
+			ToSwitch:   switchKey,
+			ToPort:     portKey,
+		}
+	}
+	m.devices[evt.MAC] = location{switchKey, portKey}
+
 	// Update or add device on this port
 	found := false
 	for _, dev := range port.Devices {
@@ -166,6 +224,42 @@ func (m *Map) Record(evt LeaseEvent) {
 	}
 
 	m.persist(switchKey, sw)
+	return move
+}
+
+// RemoveDevice takes a device off the map, e.g. when its lease ends.
+// Its switch and port stay so labels survive. Returns false if the device
+// wasn't on the map.
+func (m *Map) RemoveDevice(mac string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loc, ok := m.devices[mac]
+	if !ok {
+		return false
+	}
+	delete(m.devices, mac)
+
+	sw := m.switches[loc.switchKey]
+	if sw == nil {
+		return false
+	}
+	if port := sw.Ports[loc.portKey]; port != nil {
+		port.Devices = removeDevice(port.Devices, mac)
+	}
+	m.persist(loc.switchKey, sw)
+	return true
+}
+
+// removeDevice returns devices without the one with the given MAC.
+func removeDevice(devices []*DeviceNode, mac string) []*DeviceNode {
+	out := devices[:0]
+	for _, d := range devices {
+		if d.MAC != mac {
+			out = append(out, d)
+		}
+	}
+	return out
 }
 
 // SetLabel sets a friendly label for a switch or port.
@@ -240,6 +334,35 @@ func (m *Map) Stats() map[string]int {
 	}
 }
 
+// Usage returns per-switch port utilisation, busiest first.
+func (m *Map) Usage() []SwitchUsage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]SwitchUsage, 0, len(m.switches))
+	for _, sw := range m.switches {
+		u := SwitchUsage{ID: sw.ID, Label: sw.Label, Ports: len(sw.Ports)}
+		for _, p := range sw.Ports {
+			if len(p.Devices) > 0 {
+				u.ActivePorts++
+			}
+			u.Devices += len(p.Devices)
+		}
+		if u.Ports > 0 {
+			u.Utilization = float64(u.ActivePorts) / float64(u.Ports) * 100
+		}
+		result = append(result, u)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Utilization != result[j].Utilization {
+			return result[i].Utilization > result[j].Utilization
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
 // persist writes a switch node to BoltDB.
 func (m *Map) persist(key string, sw *SwitchNode) {
 	data, _ := json.Marshal(sw)
@@ -257,6 +380,11 @@ func (m *Map) loadAll() error {
 			var sw SwitchNode
 			if err := json.Unmarshal(v, &sw); err == nil {
 				m.switches[string(k)] = &sw
+				for portKey, p := range sw.Ports {
+					for _, d := range p.Devices {
+						m.devices[d.MAC] = location{string(k), portKey}
+					}
+				}
 			}
 			return nil
 		})
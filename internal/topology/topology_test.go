@@ -2,11 +2,14 @@ package topology
 
 import (
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/athena-dhcpd/athena-dhcpd/internal/events"
+
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -221,3 +224,132 @@ func TestIgnoreNoOption82(t *testing.T) {
 		t.Error("should ignore events without option 82 data")
 	}
 }
+
+func TestPortMove(t *testing.T) {
+	db := testDB(t)
+	m, err := NewMap(db, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mv := m.Record(LeaseEvent{CircuitID: "port1", RemoteID: "sw1", MAC: "aa:00:00:00:00:01", IP: "10.0.0.1"}); mv != nil {
+		t.Fatalf("first sighting reported as move: %+v", mv)
+	}
+	if mv := m.Record(LeaseEvent{CircuitID: "port1", RemoteID: "sw1", MAC: "aa:00:00:00:00:01", IP: "10.0.0.1"}); mv != nil {
+		t.Fatalf("same port reported as move: %+v", mv)
+	}
+
+	mv := m.Record(LeaseEvent{CircuitID: "port7", RemoteID: "sw2", MAC: "aa:00:00:00:00:01", IP: "10.0.0.1"})
+	if mv == nil {
+		t.Fatal("expected a port move")
+	}
+	if mv.FromSwitch != "sw1" || mv.FromPort != "port1" || mv.ToSwitch != "sw2" || mv.ToPort != "port7" {
+		t.Errorf("move = %+v", mv)
+	}
+	if stats := m.Stats(); stats["devices"] != 1 {
+		t.Errorf("device should only be on its new port, devices = %d", stats["devices"])
+	}
+
+	// The index survives a reload
+	m2, err := NewMap(db, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mv := m2.Record(LeaseEvent{CircuitID: "port1", RemoteID: "sw1", MAC: "aa:00:00:00:00:01"}); mv == nil || mv.FromSwitch != "sw2" {
+		t.Errorf("move after reload = %+v", mv)
+	}
+}
+
+func TestRemoveDeviceAndUsage(t *testing.T) {
+	db := testDB(t)
+	m, err := NewMap(db, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Record(LeaseEvent{CircuitID: "port1", RemoteID: "sw1", MAC: "aa:00:00:00:00:01"})
+	m.Record(LeaseEvent{CircuitID: "port2", RemoteID: "sw1", MAC: "aa:00:00:00:00:02"})
+	m.Record(LeaseEvent{CircuitID: "port1", RemoteID: "sw2", MAC: "aa:00:00:00:00:03"})
+
+	if !m.RemoveDevice("aa:00:00:00:00:02") {
+		t.Fatal("RemoveDevice returned false")
+	}
+	if m.RemoveDevice("aa:00:00:00:00:02") {
+		t.Error("second RemoveDevice should return false")
+	}
+
+	stats := m.Stats()
+	if stats["ports"] != 3 || stats["devices"] != 2 {
+		t.Errorf("ports should stay, devices go: %v", stats)
+	}
+
+	usage := m.Usage()
+	if len(usage) != 2 {
+		t.Fatalf("usage has %d switches", len(usage))
+	}
+	if usage[0].ID != "sw2" || usage[0].Utilization != 100 {
+		t.Errorf("usage[0] = %+v, want sw2 at 100%%", usage[0])
+	}
+	if usage[1].ID != "sw1" || usage[1].Ports != 2 || usage[1].ActivePorts != 1 || usage[1].Utilization != 50 {
+		t.Errorf("usage[1] = %+v, want sw1 1/2 ports", usage[1])
+	}
+}
+
+func TestStartFollowsLeaseEvents(t *testing.T) {
+	db := testDB(t)
+	m, err := NewMap(db, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus(100, testLogger())
+	go bus.Start()
+	defer bus.Stop()
+	sub := bus.Subscribe(100)
+
+	go m.Start(bus)
+	defer m.Stop()
+
+	lease := func(typ events.EventType, circuit string) events.Event {
+		return events.Event{Type: typ, Lease: &events.LeaseData{
+			MAC:   "aa:00:00:00:00:01",
+			IP:    net.IPv4(10, 0, 0, 5),
+			Relay: &events.RelayData{GIAddr: net.IPv4(10, 0, 0, 1), CircuitID: circuit, RemoteID: "sw1"},
+		}}
+	}
+
+	// Re-publish until the map has subscribed and recorded the lease
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Stats()["devices"] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("ack was not recorded")
+		}
+		bus.Publish(lease(events.EventLeaseAck, "port1"))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bus.Publish(lease(events.EventLeaseRenew, "port2"))
+	timeout := time.After(2 * time.Second)
+	for moved := false; !moved; {
+		select {
+		case evt := <-sub:
+			if evt.Type == events.EventTopologyPortMove {
+				if evt.Reason != "moved from sw1 port port1" {
+					t.Errorf("reason = %q", evt.Reason)
+				}
+				moved = true
+			}
+		case <-timeout:
+			t.Fatal("no topology.port_move event")
+		}
+	}
+
+	bus.Publish(lease(events.EventLeaseExpire, "port2"))
+	deadline = time.Now().Add(2 * time.Second)
+	for m.Stats()["devices"] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired device was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -15,6 +15,15 @@ const (
 	MessageTypeNak      MessageType = 6 // DHCPNAK
 	MessageTypeRelease  MessageType = 7 // DHCPRELEASE
 	MessageTypeInform   MessageType = 8 // DHCPINFORM
+
+	// Leasequery (RFC 4388) and bulk leasequery (RFC 6926)
+	MessageTypeLeaseQuery       MessageType = 10 // DHCPLEASEQUERY
+	MessageTypeLeaseUnassigned  MessageType = 11 // DHCPLEASEUNASSIGNED
+	MessageTypeLeaseUnknown     MessageType = 12 // DHCPLEASEUNKNOWN
+	MessageTypeLeaseActive      MessageType = 13 // DHCPLEASEACTIVE
+	MessageTypeBulkLeaseQuery   MessageType = 14 // DHCPBULKLEASEQUERY
+	MessageTypeLeaseQueryDone   MessageType = 15 // DHCPLEASEQUERYDONE
+	MessageTypeLeaseQueryStatus MessageType = 17 // DHCPLEASEQUERYSTATUS
 )
 
 func (m MessageType) String() string {
@@ -35,6 +44,20 @@ func (m MessageType) String() string {
 		return "DHCPRELEASE"
 	case MessageTypeInform:
 		return "DHCPINFORM"
+	case MessageTypeLeaseQuery:
+		return "DHCPLEASEQUERY"
+	case MessageTypeLeaseUnassigned:
+		return "DHCPLEASEUNASSIGNED"
+	case MessageTypeLeaseUnknown:
+		return "DHCPLEASEUNKNOWN"
+	case MessageTypeLeaseActive:
+		return "DHCPLEASEACTIVE"
+	case MessageTypeBulkLeaseQuery:
+		return "DHCPBULKLEASEQUERY"
+	case MessageTypeLeaseQueryDone:
+		return "DHCPLEASEQUERYDONE"
+	case MessageTypeLeaseQueryStatus:
+		return "DHCPLEASEQUERYSTATUS"
 	default:
 		return "UNKNOWN"
 	}
@@ -128,11 +151,21 @@ const (
 	OptionUserClass              OptionCode = 77
 	OptionClientFQDN             OptionCode = 81
 	OptionRelayAgentInfo         OptionCode = 82
+	OptionClientLastTransTime    OptionCode = 91 // RFC 4388
+	OptionAssociatedIP           OptionCode = 92 // RFC 4388
 	OptionSubnetSelection        OptionCode = 118
+	OptionDomainSearch           OptionCode = 119
 	OptionClasslessStaticRoute   OptionCode = 121
 	OptionVIVendorClass          OptionCode = 124
 	OptionVIVendorSpecific       OptionCode = 125
 	OptionTFTPServerAddress      OptionCode = 150
+	OptionStatusCode             OptionCode = 151 // RFC 6926
+	OptionBaseTime               OptionCode = 152 // RFC 6926
+	OptionStartTimeOfState       OptionCode = 153 // RFC 6926
+	OptionQueryStartTime         OptionCode = 154 // RFC 6926
+	OptionQueryEndTime           OptionCode = 155 // RFC 6926
+	OptionDHCPState              OptionCode = 156 // RFC 6926
+	OptionDataSource             OptionCode = 157 // RFC 6926
 	OptionEnd                    OptionCode = 255
 )
 
@@ -140,7 +173,39 @@ const (
 const (
 	RelaySubOptionCircuitID  byte = 1
 	RelaySubOptionRemoteID   byte = 2
-	RelaySubOptionLinkSelect byte = 5 // RFC 3527
+	RelaySubOptionLinkSelect byte = 5  // RFC 3527
+	RelaySubOptionRelayID    byte = 12 // RFC 6925
+)
+
+// Leasequery status codes carried in option 151 (RFC 6926 §6.2.2)
+type LeaseQueryStatus byte
+
+const (
+	LeaseQueryStatusSuccess         LeaseQueryStatus = 0
+	LeaseQueryStatusUnspecFail      LeaseQueryStatus = 1
+	LeaseQueryStatusQueryTerminated LeaseQueryStatus = 2
+	LeaseQueryStatusMalformedQuery  LeaseQueryStatus = 3
+	LeaseQueryStatusNotAllowed      LeaseQueryStatus = 4
+)
+
+// Binding states carried in option 156 (RFC 6926 §6.2.6)
+type DHCPState byte
+
+const (
+	DHCPStateAvailable     DHCPState = 1
+	DHCPStateActive        DHCPState = 2
+	DHCPStateExpired       DHCPState = 3
+	DHCPStateReleased      DHCPState = 4
+	DHCPStateAbandoned     DHCPState = 5
+	DHCPStateReset         DHCPState = 6
+	DHCPStateRemote        DHCPState = 7
+	DHCPStateTransitioning DHCPState = 8
+)
+
+// Option Overload values (RFC 2132 §9.3)
+const (
+	OverloadFile  byte = 1 // 'file' field holds options
+	OverloadSName byte = 2 // 'sname' field holds options
 )
 
 // DHCP Packet Size Limits
@@ -175,6 +240,10 @@ const (
 	LeaseStateExpired  LeaseState = "expired"
 	LeaseStateReleased LeaseState = "released"
 	LeaseStateDeclined LeaseState = "declined"
+
+	// LeaseStateReclaimed is an expired or released lease whose address is
+	// still kept for the same client during the subnet's affinity_period.
+	LeaseStateReclaimed LeaseState = "expired-reclaimed"
 )
 
 // Conflict Detection Methods
@@ -195,6 +264,16 @@ const (
 	HAStateActive      HAState = "ACTIVE"
 	HAStateStandby     HAState = "STANDBY"
 	HAStateRecovery    HAState = "RECOVERY"
+
+	// HAStateLoadBalancing is the normal state in load-balancing mode: both
+	// peers serve, each for its own half of the client hash space.
+	HAStateLoadBalancing HAState = "LOAD_BALANCING"
+
+	// HAStateCommInterrupted is entered when the peer goes silent but the
+	// split-brain check cannot confirm it is down. The node keeps serving
+	// existing bindings without handing out addresses the peer might also
+	// hand out.
+	HAStateCommInterrupted HAState = "COMMUNICATIONS_INTERRUPTED"
 )
 
 // HA Message Types
@@ -212,4 +291,12 @@ const (
 	HAMsgConflictUpdate HAMessageType = 0x09
 	HAMsgConflictBulk   HAMessageType = 0x0A
 	HAMsgConfigSync     HAMessageType = 0x0B
+	HAMsgLeaseAck       HAMessageType = 0x0C
+
+	// Cluster mode (three or more nodes)
+	HAMsgHello          HAMessageType = 0x0D
+	HAMsgVoteRequest    HAMessageType = 0x0E
+	HAMsgVoteResponse   HAMessageType = 0x0F
+	HAMsgLeaderAnnounce HAMessageType = 0x10
+	HAMsgMembership     HAMessageType = 0x11
 )
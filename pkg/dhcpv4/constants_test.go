@@ -15,6 +15,10 @@ func TestMessageTypeString(t *testing.T) {
 		{MessageTypeNak, "DHCPNAK"},
 		{MessageTypeRelease, "DHCPRELEASE"},
 		{MessageTypeInform, "DHCPINFORM"},
+		{MessageTypeLeaseQuery, "DHCPLEASEQUERY"},
+		{MessageTypeLeaseActive, "DHCPLEASEACTIVE"},
+		{MessageTypeBulkLeaseQuery, "DHCPBULKLEASEQUERY"},
+		{MessageTypeLeaseQueryDone, "DHCPLEASEQUERYDONE"},
 		{MessageType(99), "UNKNOWN"},
 	}
 	for _, tt := range tests {
@@ -45,6 +49,10 @@ func TestOptionCodeValues(t *testing.T) {
 		{OptionRebindingTime, 59},
 		{OptionClientIdentifier, 61},
 		{OptionRelayAgentInfo, 82},
+		{OptionClientLastTransTime, 91},
+		{OptionAssociatedIP, 92},
+		{OptionStatusCode, 151},
+		{OptionDHCPState, 156},
 		{OptionClasslessStaticRoute, 121},
 		{OptionEnd, 255},
 	}
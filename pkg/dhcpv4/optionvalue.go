@@ -0,0 +1,330 @@
+package dhcpv4
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Option value types accepted in configuration (subnet/reservation [[option]] blocks).
+const (
+	OptionValueIP         = "ip"          // "192.168.1.1"
+	OptionValueIPList     = "ip_list"     // ["192.168.1.1", "192.168.1.2"]
+	OptionValueString     = "string"      // "pxelinux.0"
+	OptionValueUint8      = "uint8"       // 1
+	OptionValueUint16     = "uint16"      // 1500
+	OptionValueUint32     = "uint32"      // 3600
+	OptionValueInt32      = "int32"       // -3600
+	OptionValueBool       = "bool"        // true
+	OptionValueBytes      = "bytes"       // "01:04:c0:a8:01:01" or "0104c0a80101"
+	OptionValueHex        = "hex"         // alias for bytes
+	OptionValueUint16List = "uint16_list" // [576, 1500]
+	OptionValueRoutes     = "routes"      // ["10.0.0.0/8 192.168.1.1"] — RFC 3442
+	OptionValueDomainList = "domain_list" // ["example.com", "corp.example.com"] — RFC 3397
+)
+
+// EncodeOptionValue converts a configured option value into its wire format.
+// The value is whatever the TOML or JSON decoder produced (string, int64,
+// float64, bool, or a []interface{} of those).
+func EncodeOptionValue(optType string, value interface{}) ([]byte, error) {
+	switch strings.ToLower(optType) {
+	case OptionValueIP:
+		s, err := valueString(value)
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(strings.TrimSpace(s)).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address %q", s)
+		}
+		return []byte(ip), nil
+
+	case OptionValueIPList, "ips":
+		items, err := valueStringList(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("ip_list must contain at least one address")
+		}
+		buf := make([]byte, 0, len(items)*4)
+		for _, s := range items {
+			ip := net.ParseIP(s).To4()
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IPv4 address %q", s)
+			}
+			buf = append(buf, ip...)
+		}
+		return buf, nil
+
+	case OptionValueString, "text":
+		s, err := valueString(value)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			return nil, fmt.Errorf("string value must not be empty")
+		}
+		return []byte(s), nil
+
+	case OptionValueUint8:
+		n, err := valueInt(value, 0, math.MaxUint8)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{byte(n)}, nil
+
+	case OptionValueUint16:
+		n, err := valueInt(value, 0, math.MaxUint16)
+		if err != nil {
+			return nil, err
+		}
+		return Uint16ToBytes(uint16(n)), nil
+
+	case OptionValueUint32:
+		n, err := valueInt(value, 0, math.MaxUint32)
+		if err != nil {
+			return nil, err
+		}
+		return Uint32ToBytes(uint32(n)), nil
+
+	case OptionValueInt32:
+		n, err := valueInt(value, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return nil, err
+		}
+		return Int32ToBytes(int32(n)), nil
+
+	case OptionValueBool:
+		b, err := valueBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return []byte{0x01}, nil
+		}
+		return []byte{0x00}, nil
+
+	case OptionValueBytes, OptionValueHex:
+		s, err := valueString(value)
+		if err != nil {
+			return nil, err
+		}
+		return ParseHexBytes(s)
+
+	case OptionValueUint16List:
+		items, err := valueList(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("uint16_list must contain at least one value")
+		}
+		buf := make([]byte, 0, len(items)*2)
+		for _, item := range items {
+			n, err := valueInt(item, 0, math.MaxUint16)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, Uint16ToBytes(uint16(n))...)
+		}
+		return buf, nil
+
+	case OptionValueRoutes, "cidr_routes":
+		items, err := valueStringList(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("routes must contain at least one route")
+		}
+		routes := make([]CIDRRoute, 0, len(items))
+		for _, s := range items {
+			r, err := ParseCIDRRoute(s)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, r)
+		}
+		return CIDRRoutesToBytes(routes), nil
+
+	case OptionValueDomainList, "domain_search":
+		items, err := valueStringList(value)
+		if err != nil {
+			return nil, err
+		}
+		return DomainListToBytes(items)
+
+	default:
+		return nil, fmt.Errorf("unknown option type %q", optType)
+	}
+}
+
+// ParseHexBytes parses a hex string, allowing ':', '-' or whitespace separators
+// and an optional 0x prefix ("01:04:c0:a8:01:01", "0x0104c0a80101").
+func ParseHexBytes(s string) ([]byte, error) {
+	clean := strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	clean = strings.NewReplacer(":", "", "-", "", " ", "", "\t", "").Replace(clean)
+	if clean == "" {
+		return nil, fmt.Errorf("hex value must not be empty")
+	}
+	b, err := hex.DecodeString(clean)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex value %q: %w", s, err)
+	}
+	return b, nil
+}
+
+// ParseCIDRRoute parses "10.0.0.0/8 192.168.1.1", "10.0.0.0/8,192.168.1.1"
+// or "10.0.0.0/8 via 192.168.1.1" into a CIDRRoute.
+func ParseCIDRRoute(s string) (CIDRRoute, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+	if len(fields) == 3 && strings.EqualFold(fields[1], "via") {
+		fields = []string{fields[0], fields[2]}
+	}
+	if len(fields) != 2 {
+		return CIDRRoute{}, fmt.Errorf("invalid route %q: expected \"<cidr> <gateway>\"", s)
+	}
+	_, network, err := net.ParseCIDR(fields[0])
+	if err != nil || network.IP.To4() == nil {
+		return CIDRRoute{}, fmt.Errorf("invalid route destination %q", fields[0])
+	}
+	gw := net.ParseIP(fields[1]).To4()
+	if gw == nil {
+		return CIDRRoute{}, fmt.Errorf("invalid route gateway %q", fields[1])
+	}
+	ones, _ := network.Mask.Size()
+	return CIDRRoute{Destination: network.IP.To4(), PrefixLen: ones, Gateway: gw}, nil
+}
+
+// DomainListToBytes encodes a domain search list per RFC 3397 using
+// uncompressed RFC 1035 label sequences.
+func DomainListToBytes(domains []string) ([]byte, error) {
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("domain list must contain at least one domain")
+	}
+	var buf []byte
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.TrimSpace(d), ".")
+		if d == "" {
+			return nil, fmt.Errorf("empty domain in search list")
+		}
+		for _, label := range strings.Split(d, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid label %q in domain %q", label, d)
+			}
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+		buf = append(buf, 0)
+	}
+	return buf, nil
+}
+
+// valueString extracts a string from a decoded config value.
+func valueString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case nil:
+		return "", fmt.Errorf("value is required")
+	default:
+		return "", fmt.Errorf("expected string value, got %T", v)
+	}
+}
+
+// valueList normalises a decoded config value into a slice.
+// A comma-separated string is accepted as a convenience.
+func valueList(v interface{}) ([]interface{}, error) {
+	switch l := v.(type) {
+	case []interface{}:
+		return l, nil
+	case []string:
+		out := make([]interface{}, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out, nil
+	case string:
+		var out []interface{}
+		for _, part := range strings.Split(l, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+		return out, nil
+	case nil:
+		return nil, fmt.Errorf("value is required")
+	default:
+		return []interface{}{v}, nil
+	}
+}
+
+// valueStringList normalises a decoded config value into a slice of strings.
+func valueStringList(v interface{}) ([]string, error) {
+	items, err := valueList(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, err := valueString(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, strings.TrimSpace(s))
+	}
+	return out, nil
+}
+
+// valueInt extracts an integer in [min, max] from a decoded config value.
+// TOML yields int64, JSON yields float64, and numeric strings are accepted.
+func valueInt(v interface{}, min, max int64) (int64, error) {
+	var n int64
+	switch x := v.(type) {
+	case int64:
+		n = x
+	case int:
+		n = int64(x)
+	case float64:
+		if x != math.Trunc(x) {
+			return 0, fmt.Errorf("expected integer value, got %v", x)
+		}
+		n = int64(x)
+	case string:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(x), 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", x)
+		}
+		n = parsed
+	case nil:
+		return 0, fmt.Errorf("value is required")
+	default:
+		return 0, fmt.Errorf("expected integer value, got %T", v)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range (%d-%d)", n, min, max)
+	}
+	return n, nil
+}
+
+// valueBool extracts a boolean from a decoded config value.
+func valueBool(v interface{}) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		if err != nil {
+			return false, fmt.Errorf("invalid boolean %q", x)
+		}
+		return b, nil
+	case nil:
+		return false, fmt.Errorf("value is required")
+	default:
+		return false, fmt.Errorf("expected boolean value, got %T", v)
+	}
+}
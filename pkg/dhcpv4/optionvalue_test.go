@@ -0,0 +1,71 @@
+package dhcpv4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeOptionValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		value interface{}
+		want  []byte
+	}{
+		{"ip", "ip", "192.168.1.10", []byte{192, 168, 1, 10}},
+		{"ip list", "ip_list", []interface{}{"10.0.0.1", "10.0.0.2"}, []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		{"ip list csv", "ip_list", "10.0.0.1, 10.0.0.2", []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		{"string", "string", "pxelinux.0", []byte("pxelinux.0")},
+		{"uint8 toml", "uint8", int64(8), []byte{8}},
+		{"uint16 json", "uint16", float64(1500), []byte{0x05, 0xdc}},
+		{"uint32", "uint32", int64(3600), []byte{0, 0, 0x0e, 0x10}},
+		{"int32 negative", "int32", int64(-1), []byte{0xff, 0xff, 0xff, 0xff}},
+		{"bool", "bool", true, []byte{1}},
+		{"hex colons", "hex", "01:04:c0:a8:01:01", []byte{1, 4, 192, 168, 1, 1}},
+		{"bytes plain", "bytes", "0x0a0b", []byte{0x0a, 0x0b}},
+		{"uint16 list", "uint16_list", []interface{}{int64(576), int64(1500)}, []byte{0x02, 0x40, 0x05, 0xdc}},
+		{"routes", "routes", []interface{}{"10.0.0.0/8 192.168.1.1", "0.0.0.0/0 via 192.168.1.254"},
+			[]byte{8, 10, 192, 168, 1, 1, 0, 192, 168, 1, 254}},
+		{"domain list", "domain_list", []interface{}{"example.com", "a.b."},
+			[]byte{7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 1, 'a', 1, 'b', 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeOptionValue(tt.typ, tt.value)
+			if err != nil {
+				t.Fatalf("EncodeOptionValue error: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EncodeOptionValue(%q, %v) = %v, want %v", tt.typ, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeOptionValueErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		value interface{}
+	}{
+		{"bad ip", "ip", "not-an-ip"},
+		{"ipv6", "ip", "2001:db8::1"},
+		{"uint8 overflow", "uint8", int64(256)},
+		{"uint16 negative", "uint16", int64(-1)},
+		{"fractional", "uint32", float64(1.5)},
+		{"bad hex", "hex", "zz"},
+		{"bad bool", "bool", "maybe"},
+		{"bad route", "routes", []interface{}{"10.0.0.0/8"}},
+		{"empty string", "string", ""},
+		{"missing value", "string", nil},
+		{"unknown type", "float", "1.0"},
+		{"empty domain label", "domain_list", []interface{}{"a..b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EncodeOptionValue(tt.typ, tt.value); err == nil {
+				t.Errorf("EncodeOptionValue(%q, %v) expected error", tt.typ, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package dhcpv4
+
+import "testing"
+
+func TestValidateOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    OptionCode
+		data    []byte
+		wantErr bool
+	}{
+		{"router list", OptionRouter, []byte{10, 0, 0, 1, 10, 0, 0, 2}, false},
+		{"router partial address", OptionRouter, []byte{10, 0, 0, 1, 10}, true},
+		{"mtu uint16", OptionInterfaceMTU, []byte{0x05, 0xdc}, false},
+		{"mtu as uint32", OptionInterfaceMTU, []byte{0, 0, 0x05, 0xdc}, true},
+		{"empty domain name", OptionDomainName, nil, true},
+		{"unknown code", OptionCode(224), []byte("anything"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOption(tt.code, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOption(%d, %v) error = %v, wantErr %v", tt.code, tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
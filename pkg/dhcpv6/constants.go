@@ -0,0 +1,150 @@
+// Package dhcpv6 provides constants and encoding helpers for DHCPv6 messages (RFC 8415).
+package dhcpv6
+
+import "net"
+
+// DHCPv6 Message Types (RFC 8415 §7.3)
+type MessageType byte
+
+const (
+	MessageTypeSolicit            MessageType = 1  // SOLICIT
+	MessageTypeAdvertise          MessageType = 2  // ADVERTISE
+	MessageTypeRequest            MessageType = 3  // REQUEST
+	MessageTypeConfirm            MessageType = 4  // CONFIRM
+	MessageTypeRenew              MessageType = 5  // RENEW
+	MessageTypeRebind             MessageType = 6  // REBIND
+	MessageTypeReply              MessageType = 7  // REPLY
+	MessageTypeRelease            MessageType = 8  // RELEASE
+	MessageTypeDecline            MessageType = 9  // DECLINE
+	MessageTypeReconfigure        MessageType = 10 // RECONFIGURE
+	MessageTypeInformationRequest MessageType = 11 // INFORMATION-REQUEST
+	MessageTypeRelayForward       MessageType = 12 // RELAY-FORW
+	MessageTypeRelayReply         MessageType = 13 // RELAY-REPL
+)
+
+func (m MessageType) String() string {
+	switch m {
+	case MessageTypeSolicit:
+		return "SOLICIT"
+	case MessageTypeAdvertise:
+		return "ADVERTISE"
+	case MessageTypeRequest:
+		return "REQUEST"
+	case MessageTypeConfirm:
+		return "CONFIRM"
+	case MessageTypeRenew:
+		return "RENEW"
+	case MessageTypeRebind:
+		return "REBIND"
+	case MessageTypeReply:
+		return "REPLY"
+	case MessageTypeRelease:
+		return "RELEASE"
+	case MessageTypeDecline:
+		return "DECLINE"
+	case MessageTypeReconfigure:
+		return "RECONFIGURE"
+	case MessageTypeInformationRequest:
+		return "INFORMATION-REQUEST"
+	case MessageTypeRelayForward:
+		return "RELAY-FORW"
+	case MessageTypeRelayReply:
+		return "RELAY-REPL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// IsRelay reports whether the message uses the relay agent message format (RFC 8415 §9).
+func (m MessageType) IsRelay() bool {
+	return m == MessageTypeRelayForward || m == MessageTypeRelayReply
+}
+
+// DHCPv6 Option Codes (RFC 8415 §21 and extensions)
+type OptionCode uint16
+
+const (
+	OptionClientID            OptionCode = 1
+	OptionServerID            OptionCode = 2
+	OptionIANA                OptionCode = 3
+	OptionIATA                OptionCode = 4
+	OptionIAAddr              OptionCode = 5
+	OptionORO                 OptionCode = 6
+	OptionPreference          OptionCode = 7
+	OptionElapsedTime         OptionCode = 8
+	OptionRelayMsg            OptionCode = 9
+	OptionAuth                OptionCode = 11
+	OptionUnicast             OptionCode = 12
+	OptionStatusCode          OptionCode = 13
+	OptionRapidCommit         OptionCode = 14
+	OptionUserClass           OptionCode = 15
+	OptionVendorClass         OptionCode = 16
+	OptionVendorOpts          OptionCode = 17
+	OptionInterfaceID         OptionCode = 18
+	OptionReconfMsg           OptionCode = 19
+	OptionReconfAccept        OptionCode = 20
+	OptionDNSServers          OptionCode = 23 // RFC 3646
+	OptionDomainList          OptionCode = 24 // RFC 3646
+	OptionIAPD                OptionCode = 25
+	OptionIAPrefix            OptionCode = 26
+	OptionInformationRefresh  OptionCode = 32
+	OptionRemoteID            OptionCode = 37 // RFC 4649
+	OptionClientFQDN          OptionCode = 39 // RFC 4704
+	OptionClientLinkLayerAddr OptionCode = 79 // RFC 6939
+)
+
+// DHCPv6 Status Codes (RFC 8415 §21.13)
+type StatusCode uint16
+
+const (
+	StatusSuccess       StatusCode = 0
+	StatusUnspecFail    StatusCode = 1
+	StatusNoAddrsAvail  StatusCode = 2
+	StatusNoBinding     StatusCode = 3
+	StatusNotOnLink     StatusCode = 4
+	StatusUseMulticast  StatusCode = 5
+	StatusNoPrefixAvail StatusCode = 6
+)
+
+func (s StatusCode) String() string {
+	switch s {
+	case StatusSuccess:
+		return "Success"
+	case StatusUnspecFail:
+		return "UnspecFail"
+	case StatusNoAddrsAvail:
+		return "NoAddrsAvail"
+	case StatusNoBinding:
+		return "NoBinding"
+	case StatusNotOnLink:
+		return "NotOnLink"
+	case StatusUseMulticast:
+		return "UseMulticast"
+	case StatusNoPrefixAvail:
+		return "NoPrefixAvail"
+	default:
+		return "Unknown"
+	}
+}
+
+// DUID Types (RFC 8415 §11)
+type DUIDType uint16
+
+const (
+	DUIDTypeLLT  DUIDType = 1 // link-layer address plus time
+	DUIDTypeEN   DUIDType = 2 // vendor-assigned, based on enterprise number
+	DUIDTypeLL   DUIDType = 3 // link-layer address
+	DUIDTypeUUID DUIDType = 4 // RFC 6355
+)
+
+// Relay hop limit (RFC 8415 §7.6)
+const HopCountLimit = 8
+
+// DHCPv6 Ports
+const (
+	ServerPort = 547
+	ClientPort = 546
+)
+
+// AllRelayAgentsAndServers is the link-scoped multicast group servers join (RFC 8415 §7.1).
+var AllRelayAgentsAndServers = net.ParseIP("ff02::1:2")
@@ -0,0 +1,437 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Option is a single DHCPv6 option (RFC 8415 §21.1).
+type Option struct {
+	Code OptionCode
+	Data []byte
+}
+
+// Options is an ordered list of options. DHCPv6 allows repeats (several
+// IA_NA, IA Address or Status Code options), so this is not a map.
+type Options []Option
+
+// Get returns the data of the first option with the given code, or nil.
+func (o Options) Get(code OptionCode) []byte {
+	for _, opt := range o {
+		if opt.Code == code {
+			return opt.Data
+		}
+	}
+	return nil
+}
+
+// GetAll returns the data of every option with the given code.
+func (o Options) GetAll(code OptionCode) [][]byte {
+	var out [][]byte
+	for _, opt := range o {
+		if opt.Code == code {
+			out = append(out, opt.Data)
+		}
+	}
+	return out
+}
+
+// Has reports whether an option with the given code is present.
+func (o Options) Has(code OptionCode) bool {
+	for _, opt := range o {
+		if opt.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends an option.
+func (o *Options) Add(code OptionCode, data []byte) {
+	*o = append(*o, Option{Code: code, Data: data})
+}
+
+// Encode serializes the options in order.
+func (o Options) Encode() []byte {
+	var buf []byte
+	for _, opt := range o {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(opt.Code))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(opt.Data)))
+		buf = append(buf, opt.Data...)
+	}
+	return buf
+}
+
+// DecodeOptions parses a sequence of options.
+func DecodeOptions(b []byte) (Options, error) {
+	var opts Options
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("truncated option header: %d bytes", len(b))
+		}
+		code := OptionCode(binary.BigEndian.Uint16(b[0:2]))
+		length := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+length {
+			return nil, fmt.Errorf("option %d length %d exceeds remaining %d bytes", code, length, len(b)-4)
+		}
+		data := make([]byte, length)
+		copy(data, b[4:4+length])
+		opts = append(opts, Option{Code: code, Data: data})
+		b = b[4+length:]
+	}
+	return opts, nil
+}
+
+// RequestedOptions returns the option codes listed in the Option Request option.
+func (o Options) RequestedOptions() []OptionCode {
+	data := o.Get(OptionORO)
+	codes := make([]OptionCode, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		codes = append(codes, OptionCode(binary.BigEndian.Uint16(data[i:i+2])))
+	}
+	return codes
+}
+
+// Message is a client/server message (RFC 8415 §8).
+type Message struct {
+	Type          MessageType
+	TransactionID [3]byte
+	Options       Options
+}
+
+// DecodeMessage parses a client/server message.
+func DecodeMessage(b []byte) (*Message, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("message too short: %d bytes", len(b))
+	}
+	m := &Message{Type: MessageType(b[0])}
+	if m.Type.IsRelay() {
+		return nil, fmt.Errorf("%s is a relay message", m.Type)
+	}
+	copy(m.TransactionID[:], b[1:4])
+	opts, err := DecodeOptions(b[4:])
+	if err != nil {
+		return nil, err
+	}
+	m.Options = opts
+	return m, nil
+}
+
+// Encode serializes the message.
+func (m *Message) Encode() []byte {
+	buf := []byte{byte(m.Type), m.TransactionID[0], m.TransactionID[1], m.TransactionID[2]}
+	return append(buf, m.Options.Encode()...)
+}
+
+// RelayMessage is a relay-forward or relay-reply message (RFC 8415 §9).
+type RelayMessage struct {
+	Type     MessageType
+	HopCount uint8
+	LinkAddr net.IP
+	PeerAddr net.IP
+	Options  Options
+}
+
+// DecodeRelayMessage parses a relay agent message.
+func DecodeRelayMessage(b []byte) (*RelayMessage, error) {
+	if len(b) < 34 {
+		return nil, fmt.Errorf("relay message too short: %d bytes", len(b))
+	}
+	r := &RelayMessage{
+		Type:     MessageType(b[0]),
+		HopCount: b[1],
+		LinkAddr: net.IP(append([]byte(nil), b[2:18]...)),
+		PeerAddr: net.IP(append([]byte(nil), b[18:34]...)),
+	}
+	if !r.Type.IsRelay() {
+		return nil, fmt.Errorf("%s is not a relay message", r.Type)
+	}
+	opts, err := DecodeOptions(b[34:])
+	if err != nil {
+		return nil, err
+	}
+	r.Options = opts
+	return r, nil
+}
+
+// Encode serializes the relay message.
+func (r *RelayMessage) Encode() []byte {
+	buf := []byte{byte(r.Type), r.HopCount}
+	buf = append(buf, ip16(r.LinkAddr)...)
+	buf = append(buf, ip16(r.PeerAddr)...)
+	return append(buf, r.Options.Encode()...)
+}
+
+// IA is an IA_NA or IA_PD option body (RFC 8415 §21.4, §21.21). Both share
+// the same layout; the embedded options carry IA Address or IA Prefix.
+type IA struct {
+	IAID    uint32
+	T1      uint32
+	T2      uint32
+	Options Options
+}
+
+// DecodeIA parses an IA_NA or IA_PD option body.
+func DecodeIA(b []byte) (*IA, error) {
+	if len(b) < 12 {
+		return nil, fmt.Errorf("IA option too short: %d bytes", len(b))
+	}
+	ia := &IA{
+		IAID: binary.BigEndian.Uint32(b[0:4]),
+		T1:   binary.BigEndian.Uint32(b[4:8]),
+		T2:   binary.BigEndian.Uint32(b[8:12]),
+	}
+	opts, err := DecodeOptions(b[12:])
+	if err != nil {
+		return nil, fmt.Errorf("IA %d: %w", ia.IAID, err)
+	}
+	ia.Options = opts
+	return ia, nil
+}
+
+// Encode serializes the IA option body.
+func (ia *IA) Encode() []byte {
+	buf := binary.BigEndian.AppendUint32(nil, ia.IAID)
+	buf = binary.BigEndian.AppendUint32(buf, ia.T1)
+	buf = binary.BigEndian.AppendUint32(buf, ia.T2)
+	return append(buf, ia.Options.Encode()...)
+}
+
+// IAAddress is an IA Address option body (RFC 8415 §21.6).
+type IAAddress struct {
+	IP                net.IP
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+	Options           Options
+}
+
+// DecodeIAAddress parses an IA Address option body.
+func DecodeIAAddress(b []byte) (*IAAddress, error) {
+	if len(b) < 24 {
+		return nil, fmt.Errorf("IA Address option too short: %d bytes", len(b))
+	}
+	a := &IAAddress{
+		IP:                net.IP(append([]byte(nil), b[0:16]...)),
+		PreferredLifetime: binary.BigEndian.Uint32(b[16:20]),
+		ValidLifetime:     binary.BigEndian.Uint32(b[20:24]),
+	}
+	opts, err := DecodeOptions(b[24:])
+	if err != nil {
+		return nil, err
+	}
+	a.Options = opts
+	return a, nil
+}
+
+// Encode serializes the IA Address option body.
+func (a *IAAddress) Encode() []byte {
+	buf := append([]byte(nil), ip16(a.IP)...)
+	buf = binary.BigEndian.AppendUint32(buf, a.PreferredLifetime)
+	buf = binary.BigEndian.AppendUint32(buf, a.ValidLifetime)
+	return append(buf, a.Options.Encode()...)
+}
+
+// IAPrefix is an IA Prefix option body (RFC 8415 §21.22).
+type IAPrefix struct {
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+	PrefixLen         uint8
+	Prefix            net.IP
+	Options           Options
+}
+
+// DecodeIAPrefix parses an IA Prefix option body.
+func DecodeIAPrefix(b []byte) (*IAPrefix, error) {
+	if len(b) < 25 {
+		return nil, fmt.Errorf("IA Prefix option too short: %d bytes", len(b))
+	}
+	p := &IAPrefix{
+		PreferredLifetime: binary.BigEndian.Uint32(b[0:4]),
+		ValidLifetime:     binary.BigEndian.Uint32(b[4:8]),
+		PrefixLen:         b[8],
+		Prefix:            net.IP(append([]byte(nil), b[9:25]...)),
+	}
+	if p.PrefixLen > 128 {
+		return nil, fmt.Errorf("invalid prefix length %d", p.PrefixLen)
+	}
+	opts, err := DecodeOptions(b[25:])
+	if err != nil {
+		return nil, err
+	}
+	p.Options = opts
+	return p, nil
+}
+
+// Encode serializes the IA Prefix option body.
+func (p *IAPrefix) Encode() []byte {
+	buf := binary.BigEndian.AppendUint32(nil, p.PreferredLifetime)
+	buf = binary.BigEndian.AppendUint32(buf, p.ValidLifetime)
+	buf = append(buf, p.PrefixLen)
+	buf = append(buf, ip16(p.Prefix)...)
+	return append(buf, p.Options.Encode()...)
+}
+
+// EncodeStatusCode builds a Status Code option body (RFC 8415 §21.13).
+func EncodeStatusCode(code StatusCode, message string) []byte {
+	buf := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(buf, message...)
+}
+
+// DecodeStatusCode parses a Status Code option body.
+func DecodeStatusCode(b []byte) (StatusCode, string, error) {
+	if len(b) < 2 {
+		return 0, "", fmt.Errorf("status code option too short: %d bytes", len(b))
+	}
+	return StatusCode(binary.BigEndian.Uint16(b[0:2])), string(b[2:]), nil
+}
+
+// EncodeIPList serializes a list of IPv6 addresses (e.g. DNS Recursive Name Server).
+func EncodeIPList(ips []net.IP) []byte {
+	buf := make([]byte, 0, len(ips)*16)
+	for _, ip := range ips {
+		buf = append(buf, ip16(ip)...)
+	}
+	return buf
+}
+
+// DecodeIPList parses a list of IPv6 addresses.
+func DecodeIPList(b []byte) ([]net.IP, error) {
+	if len(b)%16 != 0 {
+		return nil, fmt.Errorf("invalid IPv6 list length %d: must be multiple of 16", len(b))
+	}
+	ips := make([]net.IP, 0, len(b)/16)
+	for i := 0; i < len(b); i += 16 {
+		ips = append(ips, net.IP(append([]byte(nil), b[i:i+16]...)))
+	}
+	return ips, nil
+}
+
+// EncodeDomainList serializes domain names in uncompressed DNS wire format
+// (RFC 8415 §10), as used by the Domain Search List option.
+func EncodeDomainList(domains []string) []byte {
+	var buf []byte
+	for _, d := range domains {
+		buf = append(buf, encodeDomainName(d)...)
+	}
+	return buf
+}
+
+func encodeDomainName(name string) []byte {
+	var buf []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			continue
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// DecodeDomainList parses a sequence of uncompressed wire-format domain names.
+func DecodeDomainList(b []byte) ([]string, error) {
+	var names []string
+	var labels []string
+	for i := 0; i < len(b); {
+		n := int(b[i])
+		i++
+		if n == 0 {
+			names = append(names, strings.Join(labels, "."))
+			labels = nil
+			continue
+		}
+		if i+n > len(b) {
+			return nil, fmt.Errorf("truncated domain label at offset %d", i-1)
+		}
+		labels = append(labels, string(b[i:i+n]))
+		i += n
+	}
+	if labels != nil {
+		// A partial name (RFC 4704 allows an unterminated client FQDN).
+		names = append(names, strings.Join(labels, "."))
+	}
+	return names, nil
+}
+
+// ClientFQDN returns the domain name from a Client FQDN option (RFC 4704 §4.1),
+// or "" if the option is absent or malformed.
+func (o Options) ClientFQDN() string {
+	data := o.Get(OptionClientFQDN)
+	if len(data) < 2 {
+		return ""
+	}
+	names, err := DecodeDomainList(data[1:])
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// FormatDUID renders a DUID as colon-separated hex, the form used in config
+// and the lease store.
+func FormatDUID(duid []byte) string {
+	parts := make([]string, len(duid))
+	for i, b := range duid {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// ParseDUID parses a DUID written as hex, with or without ':' or '-' separators.
+func ParseDUID(s string) ([]byte, error) {
+	clean := strings.NewReplacer(":", "", "-", "").Replace(strings.TrimSpace(s))
+	if clean == "" {
+		return nil, fmt.Errorf("empty DUID")
+	}
+	b, err := hex.DecodeString(clean)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DUID %q: %w", s, err)
+	}
+	if len(b) < 3 || len(b) > 130 {
+		return nil, fmt.Errorf("invalid DUID %q: length %d out of range", s, len(b))
+	}
+	return b, nil
+}
+
+// NewDUIDLL builds a DUID-LL from an Ethernet address.
+func NewDUIDLL(mac net.HardwareAddr) []byte {
+	buf := binary.BigEndian.AppendUint16(nil, uint16(DUIDTypeLL))
+	buf = binary.BigEndian.AppendUint16(buf, 1) // hardware type: Ethernet
+	return append(buf, mac...)
+}
+
+// MACFromDUID extracts the Ethernet address embedded in a DUID-LLT or DUID-LL,
+// or returns nil for other DUID types.
+func MACFromDUID(duid []byte) net.HardwareAddr {
+	if len(duid) < 4 {
+		return nil
+	}
+	hwType := binary.BigEndian.Uint16(duid[2:4])
+	var addr []byte
+	switch DUIDType(binary.BigEndian.Uint16(duid[0:2])) {
+	case DUIDTypeLLT:
+		if len(duid) < 8 {
+			return nil
+		}
+		addr = duid[8:]
+	case DUIDTypeLL:
+		addr = duid[4:]
+	default:
+		return nil
+	}
+	if hwType != 1 || len(addr) != 6 {
+		return nil
+	}
+	return net.HardwareAddr(append([]byte(nil), addr...))
+}
+
+// ip16 returns the 16-byte form of ip, or the unspecified address.
+func ip16(ip net.IP) []byte {
+	if v6 := ip.To16(); v6 != nil {
+		return v6
+	}
+	return net.IPv6unspecified
+}
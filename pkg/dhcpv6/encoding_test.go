@@ -0,0 +1,154 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	ia := &IA{IAID: 7, T1: 1800, T2: 2880}
+	addr := &IAAddress{IP: net.ParseIP("2001:db8::100"), PreferredLifetime: 3600, ValidLifetime: 7200}
+	ia.Options.Add(OptionIAAddr, addr.Encode())
+
+	m := &Message{Type: MessageTypeRequest, TransactionID: [3]byte{1, 2, 3}}
+	m.Options.Add(OptionClientID, []byte{0, 3, 0, 1, 0xaa, 0xbb, 0xcc, 0, 0, 1})
+	m.Options.Add(OptionIANA, ia.Encode())
+	m.Options.Add(OptionORO, []byte{0, 23, 0, 24})
+
+	got, err := DecodeMessage(m.Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage: %v", err)
+	}
+	if got.Type != MessageTypeRequest || got.TransactionID != m.TransactionID {
+		t.Errorf("header = %v %x", got.Type, got.TransactionID)
+	}
+	oro := got.Options.RequestedOptions()
+	if len(oro) != 2 || oro[0] != OptionDNSServers || oro[1] != OptionDomainList {
+		t.Errorf("ORO = %v", oro)
+	}
+
+	gotIA, err := DecodeIA(got.Options.Get(OptionIANA))
+	if err != nil {
+		t.Fatalf("DecodeIA: %v", err)
+	}
+	if gotIA.IAID != 7 || gotIA.T1 != 1800 || gotIA.T2 != 2880 {
+		t.Errorf("IA = %+v", gotIA)
+	}
+	gotAddr, err := DecodeIAAddress(gotIA.Options.Get(OptionIAAddr))
+	if err != nil {
+		t.Fatalf("DecodeIAAddress: %v", err)
+	}
+	if !gotAddr.IP.Equal(addr.IP) || gotAddr.ValidLifetime != 7200 {
+		t.Errorf("IA Address = %+v", gotAddr)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"short message", func() error { _, err := DecodeMessage([]byte{1, 0}); return err }},
+		{"relay as message", func() error { _, err := DecodeMessage([]byte{12, 0, 0, 0}); return err }},
+		{"truncated option", func() error { _, err := DecodeOptions([]byte{0, 1, 0, 9, 0}); return err }},
+		{"short relay", func() error { _, err := DecodeRelayMessage(make([]byte, 20)); return err }},
+		{"short IA", func() error { _, err := DecodeIA(make([]byte, 8)); return err }},
+		{"bad prefix length", func() error {
+			_, err := DecodeIAPrefix(append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 200}, make([]byte, 16)...))
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if tt.fn() == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRelayRoundTrip(t *testing.T) {
+	inner := &Message{Type: MessageTypeSolicit, TransactionID: [3]byte{9, 9, 9}}
+	r := &RelayMessage{
+		Type:     MessageTypeRelayForward,
+		HopCount: 1,
+		LinkAddr: net.ParseIP("2001:db8:1::1"),
+		PeerAddr: net.ParseIP("fe80::1"),
+	}
+	r.Options.Add(OptionInterfaceID, []byte("ge-0/0/1"))
+	r.Options.Add(OptionRelayMsg, inner.Encode())
+
+	got, err := DecodeRelayMessage(r.Encode())
+	if err != nil {
+		t.Fatalf("DecodeRelayMessage: %v", err)
+	}
+	if got.HopCount != 1 || !got.LinkAddr.Equal(r.LinkAddr) || !got.PeerAddr.Equal(r.PeerAddr) {
+		t.Errorf("relay = %+v", got)
+	}
+	if string(got.Options.Get(OptionInterfaceID)) != "ge-0/0/1" {
+		t.Errorf("interface-id = %q", got.Options.Get(OptionInterfaceID))
+	}
+	if !bytes.Equal(got.Options.Get(OptionRelayMsg), inner.Encode()) {
+		t.Error("relay message payload mismatch")
+	}
+}
+
+func TestIAPrefixRoundTrip(t *testing.T) {
+	p := &IAPrefix{PreferredLifetime: 100, ValidLifetime: 200, PrefixLen: 56, Prefix: net.ParseIP("2001:db8:ff00::")}
+	p.Options.Add(OptionStatusCode, EncodeStatusCode(StatusSuccess, "ok"))
+	got, err := DecodeIAPrefix(p.Encode())
+	if err != nil {
+		t.Fatalf("DecodeIAPrefix: %v", err)
+	}
+	if got.PrefixLen != 56 || !got.Prefix.Equal(p.Prefix) || got.ValidLifetime != 200 {
+		t.Errorf("prefix = %+v", got)
+	}
+	code, msg, err := DecodeStatusCode(got.Options.Get(OptionStatusCode))
+	if err != nil || code != StatusSuccess || msg != "ok" {
+		t.Errorf("status = %v %q %v", code, msg, err)
+	}
+}
+
+func TestDomainList(t *testing.T) {
+	b := EncodeDomainList([]string{"example.com", "lab.example.com."})
+	got, err := DecodeDomainList(b)
+	if err != nil {
+		t.Fatalf("DecodeDomainList: %v", err)
+	}
+	if len(got) != 2 || got[0] != "example.com" || got[1] != "lab.example.com" {
+		t.Errorf("domains = %v", got)
+	}
+
+	var opts Options
+	opts.Add(OptionClientFQDN, append([]byte{0x01}, 4, 'h', 'o', 's', 't'))
+	if fqdn := opts.ClientFQDN(); fqdn != "host" {
+		t.Errorf("ClientFQDN = %q, want host", fqdn)
+	}
+}
+
+func TestDUID(t *testing.T) {
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01}
+	ll := NewDUIDLL(mac)
+	if s := FormatDUID(ll); s != "00:03:00:01:aa:bb:cc:00:00:01" {
+		t.Errorf("FormatDUID = %s", s)
+	}
+	if got := MACFromDUID(ll); got.String() != mac.String() {
+		t.Errorf("MACFromDUID(LL) = %v", got)
+	}
+
+	llt, err := ParseDUID("0001000112345678aabbcc000001")
+	if err != nil {
+		t.Fatalf("ParseDUID: %v", err)
+	}
+	if got := MACFromDUID(llt); got.String() != mac.String() {
+		t.Errorf("MACFromDUID(LLT) = %v", got)
+	}
+	if got := MACFromDUID([]byte{0, 2, 0, 0, 0, 9, 1, 2}); got != nil {
+		t.Errorf("MACFromDUID(EN) = %v, want nil", got)
+	}
+
+	for _, bad := range []string{"", "zz:zz", "00:01"} {
+		if _, err := ParseDUID(bad); err == nil {
+			t.Errorf("ParseDUID(%q): expected error", bad)
+		}
+	}
+}
@@ -4,6 +4,7 @@ const variants: Record<string, string> = {
   active: 'bg-success/15 text-success border-success/30',
   offered: 'bg-info/15 text-info border-info/30',
   expired: 'bg-text-muted/15 text-text-muted border-text-muted/30',
+  'expired-reclaimed': 'bg-warning/15 text-warning border-warning/30',
   declined: 'bg-danger/15 text-danger border-danger/30',
   conflict: 'bg-danger/15 text-danger border-danger/30',
   permanent: 'bg-danger/15 text-danger border-danger/30',
@@ -1,4 +1,4 @@
-import type { HAConfig } from '@/lib/configTypes'
+import type { HAConfig, HAMember } from '@/lib/configTypes'
 import { Section, FieldGrid, Field, TextInput, NumberInput, Toggle, Select } from '@/components/FormFields'
 
 export default function HASection({ value, onChange }: {
@@ -20,15 +20,28 @@ export default function HASection({ value, onChange }: {
       {value.enabled && (
         <>
           <FieldGrid>
-            <Field label="Role">
-              <Select value={value.role} onChange={v => set('role', v)} options={[
-                { value: 'primary', label: 'Primary — starts as active' },
-                { value: 'secondary', label: 'Secondary — starts as standby' },
-              ]} />
-            </Field>
-            <Field label="Peer Address" hint="the other node's ip:port">
-              <TextInput value={value.peer_address} onChange={v => set('peer_address', v)} placeholder="192.168.1.2:8067" mono />
-            </Field>
+            {value.mode === 'cluster' ? (
+              <>
+                <Field label="Node ID" hint="this node's id in the member list">
+                  <TextInput value={value.node_id} onChange={v => set('node_id', v)} placeholder="site-a" mono />
+                </Field>
+                <Field label="Members" hint="comma-separated id=ip:port, this node included — at least three">
+                  <TextInput value={formatMembers(value.member)} onChange={v => set('member', parseMembers(v, value.member))} placeholder="site-a=10.1.0.10:8068,site-b=10.2.0.10:8068,site-c=10.3.0.10:8068" mono />
+                </Field>
+              </>
+            ) : (
+              <>
+                <Field label="Role">
+                  <Select value={value.role} onChange={v => set('role', v)} options={[
+                    { value: 'primary', label: 'Primary — starts as active' },
+                    { value: 'secondary', label: 'Secondary — starts as standby' },
+                  ]} />
+                </Field>
+                <Field label="Peer Address" hint="the other node's ip:port">
+                  <TextInput value={value.peer_address} onChange={v => set('peer_address', v)} placeholder="192.168.1.2:8067" mono />
+                </Field>
+              </>
+            )}
             <Field label="Listen Address" hint="bind address for peer connections">
               <TextInput value={value.listen_address} onChange={v => set('listen_address', v)} placeholder="0.0.0.0:8067" mono />
             </Field>
@@ -41,8 +54,75 @@ export default function HASection({ value, onChange }: {
             <Field label="Sync Batch Size" hint="leases per batch during bulk sync">
               <NumberInput value={value.sync_batch_size} onChange={v => set('sync_batch_size', v)} min={1} />
             </Field>
+            <Field label="Sync Queue Size" hint="bindings kept until the peer acknowledges them">
+              <NumberInput value={value.sync_queue_size} onChange={v => set('sync_queue_size', v)} min={1} />
+            </Field>
+            <Field label="Mode">
+              <Select value={value.mode || 'active-standby'} onChange={v => set('mode', v)} options={[
+                { value: 'active-standby', label: 'Active-standby' },
+                { value: 'load-balancing', label: 'Load balancing — both nodes serve' },
+                { value: 'cluster', label: 'Cluster — three or more nodes elect a leader' },
+              ]} />
+            </Field>
+            {value.mode === 'load-balancing' && (
+              <>
+                <Field label="MCLT" hint="max lease time past what the peer has acknowledged">
+                  <TextInput value={value.mclt} onChange={v => set('mclt', v)} placeholder="1h" mono />
+                </Field>
+                <Field label="Safety Period" hint="partner-down wait before using the peer's free addresses">
+                  <TextInput value={value.safety_period} onChange={v => set('safety_period', v)} placeholder="same as MCLT" mono />
+                </Field>
+              </>
+            )}
           </FieldGrid>
 
+          <Toggle
+            checked={value.sync_ack}
+            onChange={v => set('sync_ack', v)}
+            label="Synchronous Lease Updates"
+            description="Hold each DHCPACK until the peer has acknowledged the binding"
+          />
+          {value.sync_ack && (
+            <FieldGrid>
+              <Field label="Acknowledgement Timeout" hint="withhold the DHCPACK if the peer takes longer">
+                <TextInput value={value.sync_ack_timeout} onChange={v => set('sync_ack_timeout', v)} placeholder="1s" mono />
+              </Field>
+            </FieldGrid>
+          )}
+
+          <div className="pt-3 border-t border-border/50">
+            <h4 className="text-xs font-semibold text-text-muted uppercase tracking-wider mb-3">Split-Brain Protection</h4>
+            <FieldGrid>
+              <Field label="Witness URL" hint="arbiter on a third host (athena-dhcpd -witness)">
+                <TextInput value={value.split_brain.witness} onChange={v => set('split_brain', { ...value.split_brain, witness: v })} placeholder="http://10.0.0.9:8069" mono />
+              </Field>
+              <Field label="Witness Group" hint="same on both nodes">
+                <TextInput value={value.split_brain.group} onChange={v => set('split_brain', { ...value.split_brain, group: v })} placeholder="default" mono />
+              </Field>
+              <Field label="Gateways" hint="comma-separated — one must answer ping before takeover">
+                <TextInput value={(value.split_brain.gateways ?? []).join(',')} onChange={v => set('split_brain', { ...value.split_brain, gateways: v.split(',').map(s => s.trim()) })} placeholder="192.168.1.1" mono />
+              </Field>
+              <Field label="Check Timeout">
+                <TextInput value={value.split_brain.check_timeout} onChange={v => set('split_brain', { ...value.split_brain, check_timeout: v })} placeholder="2s" mono />
+              </Field>
+            </FieldGrid>
+          </div>
+
+          <div className="pt-3 border-t border-border/50">
+            <h4 className="text-xs font-semibold text-text-muted uppercase tracking-wider mb-3">Authentication</h4>
+            <FieldGrid>
+              <Field label="Pre-Shared Key" hint="16+ characters, same on every node — signs every sync message">
+                <TextInput value={value.auth_key} onChange={v => set('auth_key', v)} placeholder="leave empty to use a key file" mono />
+              </Field>
+              <Field label="Key File" hint="instead of an inline key">
+                <TextInput value={value.auth_key_file} onChange={v => set('auth_key_file', v)} placeholder="/etc/athena-dhcpd/ha.key" mono />
+              </Field>
+              <Field label="Clock Window" hint="reject messages further off the local clock">
+                <TextInput value={value.auth_window} onChange={v => set('auth_window', v)} placeholder="30s" mono />
+              </Field>
+            </FieldGrid>
+          </div>
+
           <div className="pt-3 border-t border-border/50">
             <h4 className="text-xs font-semibold text-text-muted uppercase tracking-wider mb-3">TLS</h4>
             <Toggle
@@ -62,6 +142,9 @@ export default function HASection({ value, onChange }: {
                 <Field label="CA File" hint="for peer certificate verification">
                   <TextInput value={value.tls.ca_file} onChange={v => set('tls', { ...value.tls, ca_file: v })} placeholder="/etc/athena-dhcpd/tls/ca.crt" mono />
                 </Field>
+                <Field label="Peer Name" hint="SAN or CN the peer's certificate must carry">
+                  <TextInput value={value.tls.peer_name} onChange={v => set('tls', { ...value.tls, peer_name: v })} placeholder="host of the peer address" mono />
+                </Field>
               </FieldGrid>
             )}
           </div>
@@ -70,3 +153,16 @@ export default function HASection({ value, onChange }: {
     </Section>
   )
 }
+
+function formatMembers(members: HAMember[] | undefined): string {
+  return (members ?? []).map(m => `${m.id}=${m.address}`).join(',')
+}
+
+// parseMembers keeps each member's tls_name, which the text field doesn't show
+function parseMembers(v: string, prev: HAMember[] | undefined): HAMember[] {
+  return v.split(',').map(s => s.trim()).filter(Boolean).map(s => {
+    const [id, address = ''] = s.split('=')
+    const tls_name = prev?.find(m => m.id === id.trim())?.tls_name
+    return { id: id.trim(), address: address.trim(), ...(tls_name ? { tls_name } : {}) }
+  })
+}
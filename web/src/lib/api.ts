@@ -25,6 +25,9 @@ export interface Lease {
   expiry: string
   last_updated: string
   relay_info?: { giaddr: string; circuit_id: string; remote_id: string }
+  duid?: string
+  iaid?: number
+  prefix_len?: number
 }
 
 export interface Reservation {
@@ -90,6 +93,26 @@ export interface HAStatus {
   is_standby: boolean
   primary_url?: string
   vip?: VIPGroupStatus
+  updates_queued?: number
+  updates_unacked?: number
+  oldest_unacked_seconds?: number
+  node_id?: string
+  term?: number
+  leader?: string
+  quorum?: number
+  members?: HAClusterMember[]
+}
+
+export interface HAClusterMember {
+  id: string
+  address: string
+  self: boolean
+  leader: boolean
+  connected: boolean
+  last_seen?: string
+  updates_queued?: number
+  updates_unacked?: number
+  oldest_unacked_seconds?: number
 }
 
 export interface HealthResponse {
@@ -136,6 +159,23 @@ export interface DhcpEvent {
     interface?: string
     count: number
   }
+  pool?: {
+    subnet: string
+    pool: string
+    size: number
+    allocated: number
+    utilisation: number
+    threshold?: number
+    overflow?: string
+  }
+  dns_override?: {
+    id: string
+    action: string
+    domain?: string
+    client?: string
+    expires: number
+    by?: string
+  }
   reason?: string
 }
 
@@ -234,8 +274,8 @@ export const updateConfig = (config: string) =>
 
 // HA
 export const getHAStatus = () => request<HAStatus>('/ha/status')
-export const triggerFailover = () =>
-  request<void>('/ha/failover', { method: 'POST' })
+export const triggerFailover = (force = false) =>
+  request<void>(`/ha/failover${force ? '?force=true' : ''}`, { method: 'POST' })
 
 // Floating VIPs
 export const getVIPs = () => request<VIPEntry[]>('/vips')
@@ -269,6 +309,8 @@ export interface DNSListStatus {
   last_error: string
   refresh_interval: string
   next_refresh: string
+  schedule?: DNSScheduleType[]
+  in_schedule: boolean
 }
 
 export interface DNSListsResponse {
@@ -281,7 +323,19 @@ export interface DNSTestResult {
   blocked: boolean
   action?: string
   list?: string
-  matches?: { list: string; type: string }[]
+  policy?: string
+  safe_search?: string
+  matches?: { list: string; type: string; in_schedule: boolean }[]
+}
+
+export interface DNSOverride {
+  id: string
+  action: 'pause' | 'allow' | 'block'
+  domain?: string
+  client?: string
+  created: string
+  expires: string
+  by?: string
 }
 
 export const getDNSStats = () => request<DNSStats>('/dns/stats')
@@ -291,11 +345,20 @@ export const refreshDNSLists = (name?: string) =>
     method: 'POST',
     body: JSON.stringify(name ? { name } : {}),
   })
-export const testDNSDomain = (domain: string) =>
+export const testDNSDomain = (domain: string, client?: string) =>
   request<DNSTestResult>('/dns/lists/test', {
     method: 'POST',
-    body: JSON.stringify({ domain }),
+    body: JSON.stringify(client ? { domain, client } : { domain }),
   })
+export const getDNSOverrides = () =>
+  request<{ overrides: DNSOverride[]; count: number }>('/dns/overrides')
+export const addDNSOverride = (o: { action: string; domain?: string; client?: string; duration: string }) =>
+  request<DNSOverride>('/dns/overrides', {
+    method: 'POST',
+    body: JSON.stringify(o),
+  })
+export const removeDNSOverride = (id: string) =>
+  request<{ status: string }>(`/dns/overrides/${id}`, { method: 'DELETE' })
 export const flushDNSCache = () =>
   request<{ status: string }>('/dns/cache/flush', { method: 'POST' })
 
@@ -310,13 +373,55 @@ export interface DNSQueryLogEntry {
   answer?: string
   list_name?: string
   action?: string
+  policy?: string
+  dnssec?: string
   device_mac?: string
   device_hostname?: string
   device_type?: string
 }
 
-export const getDNSQueryLog = (limit?: number) =>
-  request<{ entries: DNSQueryLogEntry[]; total: number }>(`/dns/querylog${limit ? `?limit=${limit}` : ''}`)
+export interface DNSQueryLogParams {
+  client?: string
+  domain?: string
+  status?: string
+  from?: string
+  to?: string
+  limit?: number
+}
+
+export interface DNSTopEntry {
+  name: string
+  count: number
+}
+
+export interface DNSTopStats {
+  from: string
+  to: string
+  queries: number
+  blocked: number
+  top_domains: DNSTopEntry[]
+  top_blocked: DNSTopEntry[]
+  top_clients: DNSTopEntry[]
+}
+
+export const getDNSQueryLog = (params: DNSQueryLogParams = {}) => {
+  const qs = new URLSearchParams()
+  if (params.client) qs.set('client', params.client)
+  if (params.domain) qs.set('domain', params.domain)
+  if (params.status) qs.set('status', params.status)
+  if (params.from) qs.set('from', params.from)
+  if (params.to) qs.set('to', params.to)
+  if (params.limit) qs.set('limit', String(params.limit))
+  return request<{ entries: DNSQueryLogEntry[]; total: number; persistent: boolean }>(`/dns/querylog?${qs.toString()}`)
+}
+
+export const getDNSQueryTop = (from?: string, to?: string, limit?: number) => {
+  const qs = new URLSearchParams()
+  if (from) qs.set('from', from)
+  if (to) qs.set('to', to)
+  if (limit) qs.set('limit', String(limit))
+  return request<DNSTopStats>(`/dns/querylog/top?${qs.toString()}`)
+}
 
 // --- Config API (DB-backed CRUD) ---
 
@@ -330,15 +435,26 @@ export interface SubnetConfig {
   lease_time?: string
   renewal_time?: string
   rebind_time?: string
+  affinity_period?: string
+  overflow_subnet?: string
+  pressure?: PressureConfig
   ntp_servers?: string[]
   pool?: PoolConfig[]
   reservation?: ReservationConfig[]
   option?: OptionConfig[]
 }
 
+export interface PressureConfig {
+  high_utilisation?: number
+  offer_reclaim_after?: string
+  lease_time?: { above: number; lease_time: string }[]
+}
+
 export interface PoolConfig {
   range_start: string
   range_end: string
+  allocation_strategy?: string
+  overflow?: boolean
   lease_time?: string
   match_circuit_id?: string
   match_remote_id?: string
@@ -391,7 +507,19 @@ export interface HAConfigType {
   heartbeat_interval: string
   failover_timeout: string
   sync_batch_size: number
-  tls: { enabled: boolean; cert_file: string; key_file: string; ca_file: string }
+  sync_queue_size?: number
+  sync_ack?: boolean
+  sync_ack_timeout?: string
+  auth_key?: string
+  auth_key_file?: string
+  auth_window?: string
+  mode: string
+  mclt: string
+  safety_period: string
+  node_id?: string
+  member?: { id: string; address: string; tls_name?: string }[]
+  tls: { enabled: boolean; cert_file: string; key_file: string; ca_file: string; peer_name?: string }
+  split_brain?: { witness: string; group: string; gateways?: string[]; check_timeout: string }
 }
 
 export interface HooksConfigType {
@@ -427,6 +555,8 @@ export interface DNSConfigType {
   enabled: boolean
   listen_udp: string
   listen_doh: string
+  listen_dot?: string
+  listen_doq?: string
   domain: string
   ttl: number
   register_leases: boolean
@@ -438,7 +568,42 @@ export interface DNSConfigType {
   doh_tls?: { cert_file?: string; key_file?: string }
   zone_override?: { zone: string; nameserver: string; doh: boolean; doh_url: string }[]
   record?: { name: string; type: string; value: string; ttl: number }[]
-  list?: { name: string; url: string; type: string; format: string; action: string; enabled: boolean; refresh_interval: string }[]
+  list?: { name: string; url: string; type: string; format: string; action: string; enabled: boolean; refresh_interval: string; schedule?: DNSScheduleType[] }[]
+  policy?: DNSPolicyType[]
+  query_log?: DNSQueryLogConfigType
+  dnssec?: DNSSECConfigType
+}
+
+export interface DNSSECConfigType {
+  validate: boolean
+  trust_anchors?: string[]
+}
+
+export interface DNSQueryLogConfigType {
+  persist: boolean
+  path?: string
+  max_age?: string
+  max_entries?: number
+  stats_max_age?: string
+}
+
+export interface DNSScheduleType {
+  days?: string[]
+  start: string
+  end: string
+}
+
+export interface DNSPolicyType {
+  name: string
+  subnets?: string[]
+  macs?: string[]
+  reserved?: boolean
+  device_types?: string[]
+  lists?: string[]
+  block?: string[]
+  allow?: string[]
+  block_action?: string
+  safe_search: boolean
 }
 
 export interface ScriptHookType {
@@ -725,10 +890,20 @@ export interface TopologySwitch {
   ports: Record<string, TopologyPort>
 }
 
+export interface TopologySwitchUsage {
+  id: string
+  label?: string
+  ports: number
+  active_ports: number
+  devices: number
+  utilization: number
+}
+
 export interface TopologyStats {
   switches: number
   ports: number
   devices: number
+  per_switch: TopologySwitchUsage[]
 }
 
 export const v2GetTopology = () => request<TopologySwitch[]>('/topology')
@@ -50,7 +50,32 @@ export interface HAConfig {
   heartbeat_interval: string
   failover_timeout: string
   sync_batch_size: number
+  sync_queue_size: number
+  sync_ack: boolean
+  sync_ack_timeout: string
+  auth_key: string
+  auth_key_file: string
+  auth_window: string
+  mode: string
+  mclt: string
+  safety_period: string
+  node_id: string
+  member: HAMember[]
   tls: HATLSConfig
+  split_brain: HASplitBrainConfig
+}
+
+export interface HAMember {
+  id: string
+  address: string
+  tls_name?: string
+}
+
+export interface HASplitBrainConfig {
+  witness: string
+  group: string
+  gateways: string[]
+  check_timeout: string
 }
 
 export interface HATLSConfig {
@@ -58,6 +83,7 @@ export interface HATLSConfig {
   cert_file: string
   key_file: string
   ca_file: string
+  peer_name: string
 }
 
 export interface HooksConfig {
@@ -278,7 +304,19 @@ export function defaultConfig(): Config {
       heartbeat_interval: '1s',
       failover_timeout: '10s',
       sync_batch_size: 100,
-      tls: { enabled: false, cert_file: '', key_file: '', ca_file: '' },
+      sync_queue_size: 10000,
+      sync_ack: false,
+      sync_ack_timeout: '1s',
+      auth_key: '',
+      auth_key_file: '',
+      auth_window: '30s',
+      mode: 'active-standby',
+      mclt: '1h',
+      safety_period: '',
+      node_id: '',
+      member: [],
+      tls: { enabled: false, cert_file: '', key_file: '', ca_file: '', peer_name: '' },
+      split_brain: { witness: '', group: 'default', gateways: [], check_timeout: '2s' },
     },
     hooks: {
       event_buffer_size: 10000,
@@ -22,7 +22,7 @@ import {
   getVIPs, setVIPs,
   type SubnetConfig, type ReservationConfig, type DefaultsConfig,
   type ConflictDetectionConfig, type HAConfigType, type HooksConfigType,
-  type DDNSConfigType, type DDNSZoneType, type DNSConfigType, type PoolConfig,
+  type DDNSConfigType, type DDNSZoneType, type DNSConfigType, type DNSQueryLogConfigType, type DNSSECConfigType, type PoolConfig,
   type HostnameSanitisationConfig, type SyslogConfig, type VIPEntry,
 } from '@/lib/api'
 
@@ -198,7 +198,7 @@ function SubnetEditor({ subnet, isNew, onSave, onCancel }: {
 }) {
   const [s, setS] = useState<SubnetConfig>({ ...subnet, pool: subnet.pool || [], reservation: subnet.reservation || [] })
 
-  const updatePool = (idx: number, field: keyof PoolConfig, val: string) => {
+  const updatePool = (idx: number, field: keyof PoolConfig, val: string | boolean) => {
     const pools = [...(s.pool || [])]
     pools[idx] = { ...pools[idx], [field]: val }
     setS({ ...s, pool: pools })
@@ -228,6 +228,18 @@ function SubnetEditor({ subnet, isNew, onSave, onCancel }: {
         <Field label="Rebind Time (T2)">
           <TextInput value={s.rebind_time || ''} onChange={v => setS({ ...s, rebind_time: v })} placeholder="10h30m0s" mono />
         </Field>
+        <Field label="Affinity Period" hint="Keep expired/released addresses for the same client">
+          <TextInput value={s.affinity_period || ''} onChange={v => setS({ ...s, affinity_period: v })} placeholder="72h" mono />
+        </Field>
+        <Field label="Overflow Subnet" hint="Subnet on the same link to use once this one is full">
+          <TextInput value={s.overflow_subnet || ''} onChange={v => setS({ ...s, overflow_subnet: v })} placeholder="192.168.2.0/24" mono />
+        </Field>
+        <Field label="High Utilisation %" hint="pool.utilisation_high fires above this">
+          <NumberInput value={s.pressure?.high_utilisation || 90} onChange={v => setS({ ...s, pressure: { ...s.pressure, high_utilisation: v } })} min={1} max={100} />
+        </Field>
+        <Field label="Offer Reclaim After" hint="Reclaim unanswered offers when a pool runs out">
+          <TextInput value={s.pressure?.offer_reclaim_after || ''} onChange={v => setS({ ...s, pressure: { ...s.pressure, offer_reclaim_after: v } })} placeholder="10s" mono />
+        </Field>
         <Field label="Routers">
           <StringArrayInput value={s.routers || []} onChange={v => setS({ ...s, routers: v })} placeholder="192.168.1.1" mono />
         </Field>
@@ -249,6 +261,13 @@ function SubnetEditor({ subnet, isNew, onSave, onCancel }: {
             <FieldGrid>
               <Field label="Range Start"><TextInput value={p.range_start} onChange={v => updatePool(i, 'range_start', v)} placeholder="192.168.1.10" mono /></Field>
               <Field label="Range End"><TextInput value={p.range_end} onChange={v => updatePool(i, 'range_end', v)} placeholder="192.168.1.200" mono /></Field>
+              <Field label="Overflow Pool" hint="Only used once the matching pool is full">
+                <Toggle checked={!!p.overflow} onChange={v => updatePool(i, 'overflow', v)} label="Overflow" />
+              </Field>
+              <Field label="Allocation Strategy" hint="Which free IP new clients get">
+                <Select value={p.allocation_strategy || 'lru'} onChange={v => updatePool(i, 'allocation_strategy', v)}
+                  options={[{ value: 'lru', label: 'Least Recently Freed' }, { value: 'sequential', label: 'Sequential' }, { value: 'random', label: 'Random' }]} />
+              </Field>
               <Field label="Lease Time" hint="Override subnet default">
                 <TextInput value={p.lease_time || ''} onChange={v => updatePool(i, 'lease_time', v)} placeholder="" mono />
               </Field>
@@ -509,6 +528,9 @@ function ConflictTab({ onStatus }: { onStatus: StatusFn }) {
 
 // ============== HA TAB ==============
 
+const splitBrain = (h: HAConfigType) =>
+  h.split_brain ?? { witness: '', group: '', gateways: [], check_timeout: '' }
+
 function HATab({ onStatus }: { onStatus: StatusFn }) {
   const { data, refetch } = useApi(useCallback(() => v2GetHAConfig(), []))
   const { data: vipData, refetch: refetchVIPs } = useApi(useCallback(() => getVIPs(), []))
@@ -519,8 +541,9 @@ function HATab({ onStatus }: { onStatus: StatusFn }) {
 
   const handleSave = async () => {
     if (!current) return
+    const sb = current.split_brain
     try {
-      await v2SetHAConfig(current)
+      await v2SetHAConfig(sb ? { ...current, split_brain: { ...sb, gateways: (sb.gateways || []).filter(Boolean) } } : current)
       onStatus('success', 'HA settings saved')
       setH(null)
       refetch()
@@ -556,16 +579,54 @@ function HATab({ onStatus }: { onStatus: StatusFn }) {
         <Toggle checked={current.enabled} onChange={v => setH({ ...current, enabled: v })} label="Enable High Availability"
           description="Synchronize leases and conflicts with a peer node" />
         <FieldGrid>
-          <Field label="Role">
-            <Select value={current.role || ''} onChange={v => setH({ ...current, role: v })}
-              options={[{ value: 'primary', label: 'Primary' }, { value: 'secondary', label: 'Secondary' }]} placeholder="Select role" />
-          </Field>
-          <Field label="Peer Address"><TextInput value={current.peer_address || ''} onChange={v => setH({ ...current, peer_address: v })} placeholder="10.0.0.2:9067" mono /></Field>
+          {current.mode === 'cluster' ? <>
+            <Field label="Node ID"><TextInput value={current.node_id || ''} onChange={v => setH({ ...current, node_id: v })} placeholder="site-a" mono /></Field>
+            <Field label="Members" hint="id=ip:port, comma-separated">
+              <TextInput value={(current.member || []).map(m => `${m.id}=${m.address}`).join(',')} mono
+                onChange={v => setH({ ...current, member: v.split(',').map(s => s.trim()).filter(Boolean).map(s => { const [id, address = ''] = s.split('='); const tls_name = current.member?.find(m => m.id === id.trim())?.tls_name; return { id: id.trim(), address: address.trim(), ...(tls_name ? { tls_name } : {}) } }) })}
+                placeholder="site-a=10.1.0.10:8068,site-b=10.2.0.10:8068,site-c=10.3.0.10:8068" />
+            </Field>
+          </> : <>
+            <Field label="Role">
+              <Select value={current.role || ''} onChange={v => setH({ ...current, role: v })}
+                options={[{ value: 'primary', label: 'Primary' }, { value: 'secondary', label: 'Secondary' }]} placeholder="Select role" />
+            </Field>
+            <Field label="Peer Address"><TextInput value={current.peer_address || ''} onChange={v => setH({ ...current, peer_address: v })} placeholder="10.0.0.2:9067" mono /></Field>
+          </>}
           <Field label="Listen Address"><TextInput value={current.listen_address || ''} onChange={v => setH({ ...current, listen_address: v })} placeholder="0.0.0.0:9067" mono /></Field>
           <Field label="Heartbeat Interval"><TextInput value={current.heartbeat_interval || ''} onChange={v => setH({ ...current, heartbeat_interval: v })} placeholder="1s" mono /></Field>
           <Field label="Failover Timeout"><TextInput value={current.failover_timeout || ''} onChange={v => setH({ ...current, failover_timeout: v })} placeholder="10s" mono /></Field>
           <Field label="Sync Batch Size"><NumberInput value={current.sync_batch_size} onChange={v => setH({ ...current, sync_batch_size: v })} min={1} /></Field>
+          <Field label="Sync Queue Size"><NumberInput value={current.sync_queue_size ?? 10000} onChange={v => setH({ ...current, sync_queue_size: v })} min={1} /></Field>
+          <Field label="Mode">
+            <Select value={current.mode || 'active-standby'} onChange={v => setH({ ...current, mode: v })}
+              options={[{ value: 'active-standby', label: 'Active-standby' }, { value: 'load-balancing', label: 'Load balancing' }, { value: 'cluster', label: 'Cluster (3+ nodes)' }]} />
+          </Field>
+          {current.mode === 'load-balancing' && <>
+            <Field label="MCLT"><TextInput value={current.mclt || ''} onChange={v => setH({ ...current, mclt: v })} placeholder="1h" mono /></Field>
+            <Field label="Safety Period"><TextInput value={current.safety_period || ''} onChange={v => setH({ ...current, safety_period: v })} placeholder="same as MCLT" mono /></Field>
+          </>}
         </FieldGrid>
+        <Toggle checked={!!current.sync_ack} onChange={v => setH({ ...current, sync_ack: v })} label="Synchronous Lease Updates"
+          description="Hold each DHCPACK until the peer has acknowledged the binding" />
+        {current.sync_ack && <FieldGrid>
+          <Field label="Acknowledgement Timeout"><TextInput value={current.sync_ack_timeout || ''} onChange={v => setH({ ...current, sync_ack_timeout: v })} placeholder="1s" mono /></Field>
+        </FieldGrid>}
+        <Section title="Authentication" defaultOpen={!!(current.auth_key || current.auth_key_file)}>
+          <FieldGrid>
+            <Field label="Pre-Shared Key" hint="16+ characters, same on every node"><TextInput value={current.auth_key || ''} onChange={v => setH({ ...current, auth_key: v })} placeholder="leave empty to use a key file" mono /></Field>
+            <Field label="Key File"><TextInput value={current.auth_key_file || ''} onChange={v => setH({ ...current, auth_key_file: v })} placeholder="/etc/athena-dhcpd/ha.key" mono /></Field>
+            <Field label="Clock Window"><TextInput value={current.auth_window || ''} onChange={v => setH({ ...current, auth_window: v })} placeholder="30s" mono /></Field>
+          </FieldGrid>
+        </Section>
+        <Section title="Split-Brain Protection" defaultOpen={!!(current.split_brain?.witness || current.split_brain?.gateways?.length)}>
+          <FieldGrid>
+            <Field label="Witness URL"><TextInput value={current.split_brain?.witness || ''} onChange={v => setH({ ...current, split_brain: { ...splitBrain(current), witness: v } })} placeholder="http://10.0.0.9:8069" mono /></Field>
+            <Field label="Witness Group"><TextInput value={current.split_brain?.group || ''} onChange={v => setH({ ...current, split_brain: { ...splitBrain(current), group: v } })} placeholder="default" mono /></Field>
+            <Field label="Gateways"><TextInput value={(current.split_brain?.gateways || []).join(',')} onChange={v => setH({ ...current, split_brain: { ...splitBrain(current), gateways: v.split(',').map(s => s.trim()) } })} placeholder="192.168.1.1,192.168.2.1" mono /></Field>
+            <Field label="Check Timeout"><TextInput value={current.split_brain?.check_timeout || ''} onChange={v => setH({ ...current, split_brain: { ...splitBrain(current), check_timeout: v } })} placeholder="2s" mono /></Field>
+          </FieldGrid>
+        </Section>
         {current.tls && (
           <Section title="TLS" defaultOpen={current.tls.enabled}>
             <Toggle checked={current.tls.enabled} onChange={v => setH({ ...current, tls: { ...current.tls, enabled: v } })} label="Enable TLS" />
@@ -573,6 +634,7 @@ function HATab({ onStatus }: { onStatus: StatusFn }) {
               <Field label="Certificate File"><TextInput value={current.tls.cert_file || ''} onChange={v => setH({ ...current, tls: { ...current.tls, cert_file: v } })} /></Field>
               <Field label="Key File"><TextInput value={current.tls.key_file || ''} onChange={v => setH({ ...current, tls: { ...current.tls, key_file: v } })} /></Field>
               <Field label="CA File"><TextInput value={current.tls.ca_file || ''} onChange={v => setH({ ...current, tls: { ...current.tls, ca_file: v } })} /></Field>
+              <Field label="Peer Name" hint="SAN or CN the peer's certificate must carry"><TextInput value={current.tls.peer_name || ''} onChange={v => setH({ ...current, tls: { ...current.tls, peer_name: v } })} placeholder="host of the peer address" /></Field>
             </FieldGrid>
           </Section>
         )}
@@ -646,6 +708,10 @@ const ALL_EVENTS = [
   { group: 'HA', events: ['ha.failover', 'ha.sync_complete'] },
   { group: 'Rogue', events: ['rogue.detected', 'rogue.resolved'] },
   { group: 'Anomaly', events: ['anomaly.detected'] },
+  { group: 'RADIUS', events: ['radius.reject'] },
+  { group: 'Topology', events: ['topology.port_move'] },
+  { group: 'Pool', events: ['pool.utilisation_high', 'pool.exhausted'] },
+  { group: 'DNS', events: ['dns.override_added', 'dns.override_removed', 'dns.override_expired'] },
 ]
 
 function EventSelector({ value, onChange }: { value: string[]; onChange: (v: string[]) => void }) {
@@ -954,6 +1020,8 @@ function DDNSTab({ onStatus }: { onStatus: StatusFn }) {
 
 // ============== DNS PROXY TAB ==============
 
+const SCHEDULE_DAYS = ['mon', 'tue', 'wed', 'thu', 'fri', 'sat', 'sun']
+
 function DNSTab({ onStatus }: { onStatus: StatusFn }) {
   const { data, refetch } = useApi(useCallback(() => v2GetDNSConfig(), []))
   const [d, setD] = useState<DNSConfigType | null>(null)
@@ -973,6 +1041,11 @@ function DNSTab({ onStatus }: { onStatus: StatusFn }) {
 
   if (!current) return <Card className="p-8 text-center text-text-muted">Loading...</Card>
 
+  const ql: DNSQueryLogConfigType = current.query_log || { persist: false }
+  const setQL = (patch: Partial<DNSQueryLogConfigType>) => setD({ ...current, query_log: { ...ql, ...patch } })
+  const sec: DNSSECConfigType = current.dnssec || { validate: false }
+  const setSec = (patch: Partial<DNSSECConfigType>) => setD({ ...current, dnssec: { ...sec, ...patch } })
+
   return (
     <Card className="p-5 space-y-4">
       <Toggle checked={current.enabled} onChange={v => setD({ ...current, enabled: v })} label="Enable DNS Proxy"
@@ -987,12 +1060,45 @@ function DNSTab({ onStatus }: { onStatus: StatusFn }) {
       <Toggle checked={current.register_leases} onChange={v => setD({ ...current, register_leases: v })} label="Register Leases" description="Auto-create DNS A records for active leases" />
       <Toggle checked={current.register_leases_ptr} onChange={v => setD({ ...current, register_leases_ptr: v })} label="Register PTR Records" />
       <Toggle checked={current.use_root_servers} onChange={v => setD({ ...current, use_root_servers: v })} label="Use Root Servers" />
-      <Field label="Forwarders">
-        <StringArrayInput value={current.forwarders || []} onChange={v => setD({ ...current, forwarders: v })} placeholder="8.8.8.8:53" mono />
+      <Field label="Forwarders" hint="host[:port], or tls://, https://, quic:// for encrypted upstreams">
+        <StringArrayInput value={current.forwarders || []} onChange={v => setD({ ...current, forwarders: v })} placeholder="tls://1.1.1.1" mono />
       </Field>
       <Field label="Listen DoH" hint="DNS-over-HTTPS listen address (leave empty to disable)">
         <TextInput value={current.listen_doh || ''} onChange={v => setD({ ...current, listen_doh: v })} placeholder="0.0.0.0:443" mono />
       </Field>
+      <FieldGrid>
+        <Field label="Listen DoT" hint="DNS-over-TLS, needs the TLS certificate below">
+          <TextInput value={current.listen_dot || ''} onChange={v => setD({ ...current, listen_dot: v })} placeholder="0.0.0.0:853" mono />
+        </Field>
+        <Field label="Listen DoQ" hint="DNS-over-QUIC, needs the TLS certificate below">
+          <TextInput value={current.listen_doq || ''} onChange={v => setD({ ...current, listen_doq: v })} placeholder="0.0.0.0:853" mono />
+        </Field>
+      </FieldGrid>
+
+      {/* Query Log */}
+      <Section title="Query Log">
+        <Toggle checked={ql.persist} onChange={v => setQL({ persist: v })} label="Persist Query Log"
+          description="Write every query to disk for search and hourly top tables. Off keeps the last 5000 in memory" />
+        {ql.persist && (
+          <FieldGrid>
+            <Field label="Path" hint="default: dns-querylog.db next to the lease database">
+              <TextInput value={ql.path || ''} onChange={v => setQL({ path: v })} placeholder="/var/lib/athena-dhcpd/dns-querylog.db" mono />
+            </Field>
+            <Field label="Max Age"><TextInput value={ql.max_age || ''} onChange={v => setQL({ max_age: v })} placeholder="168h0m0s" mono /></Field>
+            <Field label="Max Entries"><NumberInput value={ql.max_entries || 0} onChange={v => setQL({ max_entries: v })} min={0} /></Field>
+            <Field label="Top Tables Kept For"><TextInput value={ql.stats_max_age || ''} onChange={v => setQL({ stats_max_age: v })} placeholder="2160h0m0s" mono /></Field>
+          </FieldGrid>
+        )}
+      </Section>
+
+      <Section title="DNSSEC" description="Validate signatures on forwarded answers and fail bogus ones">
+        <Toggle checked={sec.validate} onChange={v => setSec({ validate: v })} label="Validate DNSSEC" />
+        {sec.validate && (
+          <Field label="Trust Anchors" hint="DS or DNSKEY records; default: root KSKs">
+            <StringArrayInput value={sec.trust_anchors || []} onChange={v => setSec({ trust_anchors: v })} placeholder=". IN DS 20326 8 2 E06D44B8..." mono />
+          </Field>
+        )}
+      </Section>
 
       {/* Zone Overrides */}
       <Section title={`Zone Overrides (${(current.zone_override || []).length})`}>
@@ -1087,6 +1193,34 @@ function DNSTab({ onStatus }: { onStatus: StatusFn }) {
                 </Field>
                 <Field label="Refresh Interval"><TextInput value={lst.refresh_interval || ''} onChange={v => updateLst({ refresh_interval: v })} placeholder="24h" mono /></Field>
               </FieldGrid>
+              <Field label="Schedule" hint="Only applies inside these windows; none means always. An end at or before the start runs past midnight">
+                <div className="space-y-2">
+                  {(lst.schedule || []).map((sch, j) => {
+                    const updateSch = (patch: Record<string, unknown>) => {
+                      const n = [...(lst.schedule || [])]; n[j] = { ...n[j], ...patch }; updateLst({ schedule: n })
+                    }
+                    return (
+                      <div key={j} className="flex items-center gap-2 flex-wrap">
+                        {SCHEDULE_DAYS.map(day => (
+                          <label key={day} className="flex items-center gap-1 text-xs cursor-pointer">
+                            <input type="checkbox" checked={(sch.days || []).includes(day)}
+                              onChange={e => updateSch({ days: e.target.checked ? [...(sch.days || []), day] : (sch.days || []).filter(d => d !== day) })}
+                              className="rounded border-border accent-accent" />
+                            {day}
+                          </label>
+                        ))}
+                        <div className="w-20"><TextInput value={sch.start} onChange={v => updateSch({ start: v })} placeholder="09:00" mono /></div>
+                        <span className="text-xs text-text-muted">to</span>
+                        <div className="w-20"><TextInput value={sch.end} onChange={v => updateSch({ end: v })} placeholder="17:00" mono /></div>
+                        <button onClick={() => updateLst({ schedule: (lst.schedule || []).filter((_, idx) => idx !== j) })}
+                          className="p-1 text-text-muted hover:text-danger"><Trash2 className="w-3.5 h-3.5" /></button>
+                      </div>
+                    )
+                  })}
+                  <button onClick={() => updateLst({ schedule: [...(lst.schedule || []), { days: ['mon', 'tue', 'wed', 'thu', 'fri'], start: '09:00', end: '17:00' }] })}
+                    className="flex items-center gap-1.5 text-xs text-accent hover:text-accent-hover"><Plus className="w-3 h-3" /> Add Window</button>
+                </div>
+              </Field>
             </div>
           )
         })}
@@ -1094,9 +1228,68 @@ function DNSTab({ onStatus }: { onStatus: StatusFn }) {
           className="flex items-center gap-1.5 text-xs text-accent hover:text-accent-hover"><Plus className="w-3 h-3" /> Add Filter List</button>
       </Section>
 
-      {/* DoH TLS */}
-      {current.listen_doh && (
-        <Section title="DoH TLS Settings">
+      {/* Filtering Policies */}
+      <Section title={`Filtering Policies (${(current.policy || []).length})`}>
+        <p className="text-xs text-text-muted mb-2">Per-client filtering groups. The first policy that selects a client (by subnet, MAC, reservation or device type) replaces the enabled lists for it</p>
+        {(current.policy || []).map((pol, i) => {
+          const updatePol = (patch: Record<string, unknown>) => {
+            const n = [...(current.policy || [])]; n[i] = { ...n[i], ...patch }; setD({ ...current, policy: n })
+          }
+          return (
+            <div key={i} className="p-3 bg-surface-overlay/30 rounded-lg space-y-2 mb-2">
+              <div className="flex items-center justify-between">
+                <span className="text-xs font-semibold text-text-muted">{pol.name || 'New Policy'}</span>
+                <div className="flex items-center gap-2">
+                  <label className="flex items-center gap-1 text-xs cursor-pointer">
+                    <input type="checkbox" checked={!!pol.reserved} onChange={e => updatePol({ reserved: e.target.checked })}
+                      className="rounded border-border accent-accent" />
+                    Reserved clients
+                  </label>
+                  <label className="flex items-center gap-1 text-xs cursor-pointer">
+                    <input type="checkbox" checked={pol.safe_search} onChange={e => updatePol({ safe_search: e.target.checked })}
+                      className="rounded border-border accent-accent" />
+                    Safe search
+                  </label>
+                  <button onClick={() => setD({ ...current, policy: (current.policy || []).filter((_, idx) => idx !== i) })}
+                    className="p-1 text-text-muted hover:text-danger"><Trash2 className="w-3.5 h-3.5" /></button>
+                </div>
+              </div>
+              <FieldGrid>
+                <Field label="Name"><TextInput value={pol.name || ''} onChange={v => updatePol({ name: v })} placeholder="kids" /></Field>
+                <Field label="Block Action">
+                  <Select value={pol.block_action || 'nxdomain'} onChange={v => updatePol({ block_action: v })}
+                    options={[{ value: 'nxdomain', label: 'NXDOMAIN' }, { value: 'zero', label: '0.0.0.0' }, { value: 'refuse', label: 'REFUSED' }]} />
+                </Field>
+                <Field label="Subnets"><StringArrayInput value={pol.subnets || []} onChange={v => updatePol({ subnets: v })} placeholder="10.0.20.0/24" mono /></Field>
+                <Field label="MACs"><StringArrayInput value={pol.macs || []} onChange={v => updatePol({ macs: v })} placeholder="aa:bb:cc:dd:ee:ff" mono /></Field>
+                <Field label="Device Types" hint="From fingerprinting"><StringArrayInput value={pol.device_types || []} onChange={v => updatePol({ device_types: v })} placeholder="IoT" /></Field>
+                <Field label="Block Domains"><StringArrayInput value={pol.block || []} onChange={v => updatePol({ block: v })} placeholder="tiktok.com" mono /></Field>
+                <Field label="Allow Domains"><StringArrayInput value={pol.allow || []} onChange={v => updatePol({ allow: v })} placeholder="school.example.com" mono /></Field>
+              </FieldGrid>
+              {(current.list || []).length > 0 && (
+                <Field label="Filter Lists">
+                  <div className="flex flex-wrap gap-3">
+                    {(current.list || []).map(lst => (
+                      <label key={lst.name} className="flex items-center gap-1 text-xs cursor-pointer">
+                        <input type="checkbox" checked={(pol.lists || []).includes(lst.name)}
+                          onChange={e => updatePol({ lists: e.target.checked ? [...(pol.lists || []), lst.name] : (pol.lists || []).filter(n => n !== lst.name) })}
+                          className="rounded border-border accent-accent" />
+                        {lst.name}
+                      </label>
+                    ))}
+                  </div>
+                </Field>
+              )}
+            </div>
+          )
+        })}
+        <button onClick={() => setD({ ...current, policy: [...(current.policy || []), { name: '', safe_search: false }] })}
+          className="flex items-center gap-1.5 text-xs text-accent hover:text-accent-hover"><Plus className="w-3 h-3" /> Add Filtering Policy</button>
+      </Section>
+
+      {/* DoH/DoT/DoQ TLS */}
+      {(current.listen_doh || current.listen_dot || current.listen_doq) && (
+        <Section title="TLS Settings" description="Certificate for the DoH, DoT and DoQ listeners">
           <FieldGrid>
             <Field label="TLS Certificate"><TextInput value={current.doh_tls?.cert_file || ''} onChange={v => setD({ ...current, doh_tls: { ...(current.doh_tls || {}), cert_file: v } })} placeholder="/path/to/cert.pem" mono /></Field>
             <Field label="TLS Key"><TextInput value={current.doh_tls?.key_file || ''} onChange={v => setD({ ...current, doh_tls: { ...(current.doh_tls || {}), key_file: v } })} placeholder="/path/to/key.pem" mono /></Field>
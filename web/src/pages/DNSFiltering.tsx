@@ -1,9 +1,9 @@
 import { useState, useCallback } from 'react'
-import { RefreshCw, Search, Shield, ShieldOff, Database, Clock, AlertCircle, CheckCircle, Trash2 } from 'lucide-react'
+import { RefreshCw, Search, Shield, ShieldOff, Database, Clock, AlertCircle, CheckCircle, Trash2, Timer, X } from 'lucide-react'
 import { Card } from '@/components/Card'
 import { useApi } from '@/hooks/useApi'
-import { getDNSStats, getDNSLists, refreshDNSLists, testDNSDomain, flushDNSCache } from '@/lib/api'
-import type { DNSStats, DNSListStatus, DNSTestResult } from '@/lib/api'
+import { getDNSStats, getDNSLists, refreshDNSLists, testDNSDomain, flushDNSCache, getDNSOverrides, addDNSOverride, removeDNSOverride } from '@/lib/api'
+import type { DNSStats, DNSListStatus, DNSTestResult, DNSOverride, DNSScheduleType } from '@/lib/api'
 
 function StatCard({ label, value, icon: Icon, color }: {
   label: string
@@ -35,6 +35,21 @@ function formatTime(ts: string): string {
   return d.toLocaleDateString()
 }
 
+function formatRemaining(ts: string): string {
+  const diff = new Date(ts).getTime() - Date.now()
+  if (diff < 60000) return '<1m left'
+  if (diff < 3600000) return `${Math.ceil(diff / 60000)}m left`
+  if (diff < 86400000) return `${Math.floor(diff / 3600000)}h ${Math.ceil((diff % 3600000) / 60000)}m left`
+  return `${Math.floor(diff / 86400000)}d left`
+}
+
+function formatSchedule(sch: DNSScheduleType): string {
+  const days = sch.days && sch.days.length > 0 ? sch.days.join(', ') : 'daily'
+  return `${days} ${sch.start}–${sch.end}`
+}
+
+const OVERRIDE_DURATIONS = ['15m', '30m', '1h', '2h', '8h', '24h']
+
 function formatNumber(n: number): string {
   if (n >= 1000000) return `${(n / 1000000).toFixed(1)}M`
   if (n >= 1000) return `${(n / 1000).toFixed(1)}K`
@@ -45,13 +60,53 @@ export default function DNSFilteringPage() {
   const { data: stats, refetch: refetchStats } = useApi<DNSStats>(useCallback(() => getDNSStats(), []))
   const { data: listsData, refetch: refetchLists } = useApi(useCallback(() => getDNSLists(), []))
   const [testDomain, setTestDomain] = useState('')
+  const [testClient, setTestClient] = useState('')
   const [testResult, setTestResult] = useState<DNSTestResult | null>(null)
   const [testing, setTesting] = useState(false)
   const [refreshing, setRefreshing] = useState<string | null>(null)
   const [flushing, setFlushing] = useState(false)
   const [status, setStatus] = useState<{ type: 'success' | 'error'; message: string } | null>(null)
+  const { data: overridesData, refetch: refetchOverrides } = useApi(useCallback(() => getDNSOverrides(), []))
+  const [ovAction, setOvAction] = useState('pause')
+  const [ovClient, setOvClient] = useState('')
+  const [ovDomain, setOvDomain] = useState('')
+  const [ovDuration, setOvDuration] = useState('30m')
+  const [adding, setAdding] = useState(false)
 
   const lists: DNSListStatus[] = listsData?.lists || []
+  const overrides: DNSOverride[] = overridesData?.overrides || []
+
+  const handleAddOverride = async () => {
+    setAdding(true)
+    setStatus(null)
+    try {
+      const o = await addDNSOverride({
+        action: ovAction,
+        client: ovClient.trim() || undefined,
+        domain: ovAction === 'pause' ? undefined : ovDomain.trim(),
+        duration: ovDuration,
+      })
+      setStatus({ type: 'success', message: `Override added, expires ${new Date(o.expires).toLocaleTimeString()}` })
+      setOvClient('')
+      setOvDomain('')
+      refetchOverrides()
+    } catch (e) {
+      setStatus({ type: 'error', message: e instanceof Error ? e.message : 'Adding override failed' })
+    } finally {
+      setAdding(false)
+    }
+  }
+
+  const handleRemoveOverride = async (id: string) => {
+    setStatus(null)
+    try {
+      await removeDNSOverride(id)
+      setStatus({ type: 'success', message: 'Override removed' })
+      refetchOverrides()
+    } catch (e) {
+      setStatus({ type: 'error', message: e instanceof Error ? e.message : 'Removing override failed' })
+    }
+  }
 
   const handleTest = async () => {
     if (!testDomain.trim()) return
@@ -59,7 +114,7 @@ export default function DNSFilteringPage() {
     setTestResult(null)
     setStatus(null)
     try {
-      const result = await testDNSDomain(testDomain.trim())
+      const result = await testDNSDomain(testDomain.trim(), testClient.trim() || undefined)
       setTestResult(result)
     } catch (e) {
       setStatus({ type: 'error', message: e instanceof Error ? e.message : 'Test failed' })
@@ -145,7 +200,7 @@ export default function DNSFilteringPage() {
       {/* Domain Test */}
       <Card className="p-5">
         <h3 className="text-sm font-semibold mb-3">Test Domain</h3>
-        <p className="text-xs text-text-muted mb-3">Check if a domain would be blocked by your filter lists</p>
+        <p className="text-xs text-text-muted mb-3">Check if a domain would be blocked by your filter lists. Give a client IP to test under its filtering policy</p>
         <div className="flex gap-2">
           <div className="flex-1 relative">
             <Search className="w-4 h-4 absolute left-3 top-1/2 -translate-y-1/2 text-text-muted" />
@@ -158,6 +213,14 @@ export default function DNSFilteringPage() {
               className="w-full pl-9 pr-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent focus:ring-1 focus:ring-accent/30 outline-none transition-colors font-mono"
             />
           </div>
+          <input
+            type="text"
+            value={testClient}
+            onChange={e => setTestClient(e.target.value)}
+            onKeyDown={e => e.key === 'Enter' && handleTest()}
+            placeholder="Client IP (optional)"
+            className="w-44 px-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent focus:ring-1 focus:ring-accent/30 outline-none transition-colors font-mono"
+          />
           <button onClick={handleTest} disabled={testing || !testDomain.trim()}
             className="px-4 py-2.5 text-sm font-medium rounded-lg bg-accent hover:bg-accent-hover text-white disabled:opacity-40 transition-colors">
             {testing ? 'Testing...' : 'Test'}
@@ -180,7 +243,13 @@ export default function DNSFilteringPage() {
                 {testResult.blocked ? 'BLOCKED' : 'ALLOWED'}
               </span>
               <span className="text-sm font-mono text-text-secondary">{testResult.domain}</span>
+              {testResult.policy && (
+                <span className="text-[10px] px-2 py-0.5 rounded-full font-medium bg-accent/10 text-accent">policy: {testResult.policy}</span>
+              )}
             </div>
+            {testResult.safe_search && !testResult.blocked && (
+              <p className="text-xs text-text-muted ml-7">Safe search: answered with <span className="font-mono text-text-secondary">{testResult.safe_search}</span></p>
+            )}
             {testResult.blocked && (
               <div className="text-xs text-text-muted space-y-1 ml-7">
                 <p>Action: <span className="font-mono text-text-secondary">{testResult.action}</span></p>
@@ -205,6 +274,80 @@ export default function DNSFilteringPage() {
         )}
       </Card>
 
+      {/* Temporary Overrides */}
+      <Card className="p-5">
+        <h3 className="text-sm font-semibold mb-3">Temporary Overrides</h3>
+        <p className="text-xs text-text-muted mb-3">Pause filtering for a device, or allow or block a domain, for a limited time. Client is an IP or MAC; leave it empty to allow or block for everyone</p>
+        <div className="flex gap-2">
+          <select
+            value={ovAction}
+            onChange={e => setOvAction(e.target.value)}
+            className="px-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent outline-none transition-colors"
+          >
+            <option value="pause">Pause filtering</option>
+            <option value="allow">Allow domain</option>
+            <option value="block">Block domain</option>
+          </select>
+          <input
+            type="text"
+            value={ovClient}
+            onChange={e => setOvClient(e.target.value)}
+            placeholder={ovAction === 'pause' ? 'Client IP or MAC' : 'Client (optional)'}
+            className="w-48 px-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent focus:ring-1 focus:ring-accent/30 outline-none transition-colors font-mono"
+          />
+          {ovAction !== 'pause' && (
+            <input
+              type="text"
+              value={ovDomain}
+              onChange={e => setOvDomain(e.target.value)}
+              placeholder="example.com"
+              className="flex-1 px-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent focus:ring-1 focus:ring-accent/30 outline-none transition-colors font-mono"
+            />
+          )}
+          <select
+            value={ovDuration}
+            onChange={e => setOvDuration(e.target.value)}
+            className="px-3 py-2.5 text-sm rounded-lg border border-border bg-surface hover:border-border-hover focus:border-accent outline-none transition-colors"
+          >
+            {OVERRIDE_DURATIONS.map(d => <option key={d} value={d}>{d}</option>)}
+          </select>
+          <button onClick={handleAddOverride}
+            disabled={adding || (ovAction === 'pause' ? !ovClient.trim() : !ovDomain.trim())}
+            className="px-4 py-2.5 text-sm font-medium rounded-lg bg-accent hover:bg-accent-hover text-white disabled:opacity-40 transition-colors">
+            {adding ? 'Adding...' : 'Add'}
+          </button>
+        </div>
+
+        {overrides.length > 0 && (
+          <div className="mt-4 space-y-2">
+            {overrides.map(o => (
+              <div key={o.id} className="flex items-center justify-between px-3 py-2 rounded-lg border border-border">
+                <div className="flex items-center gap-2 text-xs">
+                  <span className={`text-[10px] px-2 py-0.5 rounded-full font-medium ${
+                    o.action === 'block' ? 'bg-danger/10 text-danger'
+                      : o.action === 'allow' ? 'bg-success/10 text-success'
+                      : 'bg-warning/10 text-warning'
+                  }`}>{o.action}</span>
+                  {o.domain && <span className="font-mono text-text-secondary">{o.domain}</span>}
+                  <span className="text-text-muted">for</span>
+                  <span className="font-mono text-text-secondary">{o.client || 'everyone'}</span>
+                  {o.by && <span className="text-text-muted">by {o.by}</span>}
+                </div>
+                <div className="flex items-center gap-3">
+                  <span className="flex items-center gap-1 text-[10px] text-text-muted">
+                    <Timer className="w-3 h-3" /> {formatRemaining(o.expires)}
+                  </span>
+                  <button onClick={() => handleRemoveOverride(o.id)}
+                    className="p-1 rounded hover:bg-surface-overlay transition-colors" title="End override">
+                    <X className="w-3.5 h-3.5 text-text-muted" />
+                  </button>
+                </div>
+              </div>
+            ))}
+          </div>
+        )}
+      </Card>
+
       {/* Filter Lists */}
       <div>
         <h3 className="text-sm font-semibold mb-3">Filter Lists</h3>
@@ -239,6 +382,11 @@ export default function DNSFilteringPage() {
                         {!list.enabled && (
                           <span className="text-[10px] px-1.5 py-0.5 rounded-full bg-warning/10 text-warning font-medium">disabled</span>
                         )}
+                        {list.schedule && list.schedule.length > 0 && (
+                          <span className={`text-[10px] px-1.5 py-0.5 rounded-full font-medium ${
+                            list.in_schedule ? 'bg-success/10 text-success' : 'bg-surface-overlay text-text-muted'
+                          }`}>{list.in_schedule ? 'in schedule' : 'off schedule'}</span>
+                        )}
                       </div>
                       <p className="text-[11px] text-text-muted font-mono mt-0.5 truncate max-w-lg">{list.url}</p>
                     </div>
@@ -274,6 +422,9 @@ export default function DNSFilteringPage() {
                 <div className="mt-3 flex items-center gap-4 text-[10px] text-text-muted">
                   <span>Refresh: <span className="font-mono text-text-secondary">{list.refresh_interval}</span></span>
                   <span>Next: <span className="text-text-secondary">{formatTime(list.next_refresh)}</span></span>
+                  {list.schedule && list.schedule.length > 0 && (
+                    <span>Schedule: <span className="font-mono text-text-secondary">{list.schedule.map(formatSchedule).join('; ')}</span></span>
+                  )}
                 </div>
               </Card>
             ))}
@@ -1,9 +1,13 @@
 import { useState, useEffect, useRef, useCallback } from 'react'
 import { useApi } from '@/hooks/useApi'
-import { getDNSQueryLog, type DNSQueryLogEntry } from '@/lib/api'
+import {
+  getDNSQueryLog, getDNSQueryTop,
+  type DNSQueryLogEntry, type DNSQueryLogParams, type DNSTopEntry,
+} from '@/lib/api'
 import { Table, THead, TH, TD, TR, EmptyRow } from '@/components/Table'
 import { Card } from '@/components/Card'
-import { Activity, Pause, Play, Trash2, Search } from 'lucide-react'
+import { Field, FieldGrid, TextInput } from '@/components/FormFields'
+import { Activity, Pause, Play, Trash2, Search, History, BarChart3 } from 'lucide-react'
 import { formatDate } from '@/lib/utils'
 
 // Parse DNS record string to extract the rdata (answer portion).
@@ -35,7 +39,9 @@ const statusColors: Record<string, string> = {
   cached: 'text-accent',
   local: 'text-accent',
   blocked: 'text-danger',
+  safesearch: 'text-success',
   failed: 'text-warning',
+  bogus: 'text-danger',
 }
 
 const statusBg: Record<string, string> = {
@@ -44,7 +50,29 @@ const statusBg: Record<string, string> = {
   cached: 'bg-accent/15',
   local: 'bg-accent/15',
   blocked: 'bg-danger/15',
+  safesearch: 'bg-success/15',
   failed: 'bg-warning/15',
+  bogus: 'bg-danger/15',
+}
+
+function TopTable({ title, rows, color }: { title: string; rows: DNSTopEntry[]; color: string }) {
+  const max = rows[0]?.count || 1
+  return (
+    <div className="space-y-1.5">
+      <div className="text-[10px] text-text-muted uppercase tracking-wider">{title}</div>
+      {rows.length === 0 ? (
+        <div className="text-xs text-text-muted">—</div>
+      ) : rows.map(r => (
+        <div key={r.name} className="relative text-xs">
+          <div className={`absolute inset-y-0 left-0 rounded ${color}`} style={{ width: `${(r.count / max) * 100}%` }} />
+          <div className="relative flex justify-between gap-2 px-1.5 py-0.5">
+            <span className="font-mono truncate">{r.name}</span>
+            <span className="tabular-nums text-text-muted">{r.count.toLocaleString()}</span>
+          </div>
+        </div>
+      ))}
+    </div>
+  )
 }
 
 export default function DNSQueryLog() {
@@ -52,17 +80,38 @@ export default function DNSQueryLog() {
   const [entries, setEntries] = useState<DNSQueryLogEntry[]>([])
   const [filter, setFilter] = useState('')
   const [statusFilter, setStatusFilter] = useState('')
+  const [history, setHistory] = useState<DNSQueryLogParams>({})
+  const [searching, setSearching] = useState(false)
+  const [searchError, setSearchError] = useState('')
   const eventSourceRef = useRef<EventSource | null>(null)
   const maxEntries = 500
 
   // Load initial entries
-  const { data } = useApi(useCallback(() => getDNSQueryLog(200), []))
+  const { data } = useApi(useCallback(() => getDNSQueryLog({ limit: 200 }), []))
   useEffect(() => {
     if (data?.entries) {
       setEntries(data.entries)
     }
   }, [data])
 
+  // Top tables for the last 24 hours
+  const { data: top } = useApi(useCallback(() => getDNSQueryTop(undefined, undefined, 5), []))
+
+  // Search the stored log; pauses the live stream so results stay put
+  const handleSearch = async () => {
+    setSearching(true)
+    setSearchError('')
+    try {
+      const res = await getDNSQueryLog({ ...history, status: statusFilter, limit: maxEntries })
+      setLive(false)
+      setEntries(res.entries)
+    } catch (err) {
+      setSearchError(err instanceof Error ? err.message : 'Search failed')
+    } finally {
+      setSearching(false)
+    }
+  }
+
   // SSE stream for live updates
   useEffect(() => {
     if (!live) {
@@ -106,7 +155,8 @@ export default function DNSQueryLog() {
       const matchesSource = e.source.toLowerCase().includes(f)
       const matchesMAC = e.device_mac?.toLowerCase().includes(f)
       const matchesHostname = e.device_hostname?.toLowerCase().includes(f)
-      if (!matchesName && !matchesSource && !matchesMAC && !matchesHostname) return false
+      const matchesPolicy = e.policy?.toLowerCase().includes(f)
+      if (!matchesName && !matchesSource && !matchesMAC && !matchesHostname && !matchesPolicy) return false
     }
     if (statusFilter && e.status !== statusFilter) return false
     return true
@@ -126,6 +176,7 @@ export default function DNSQueryLog() {
           <h1 className="text-2xl font-bold">DNS Query Log</h1>
           <p className="text-sm text-text-secondary mt-0.5">
             Live streaming DNS queries · {stats.total} entries
+            {data && <span className="text-text-muted ml-1">({data.total.toLocaleString()} {data.persistent ? 'stored' : 'in memory'})</span>}
           </p>
         </div>
         <div className="flex items-center gap-2">
@@ -166,6 +217,53 @@ export default function DNSQueryLog() {
         </Card>
       </div>
 
+      {/* Top tables */}
+      {top && (
+        <Card className="p-4 space-y-3">
+          <div className="flex items-center gap-2 text-sm font-medium text-text-secondary">
+            <BarChart3 className="w-4 h-4" /> Last 24 hours
+            <span className="text-xs text-text-muted font-normal">
+              · {top.queries.toLocaleString()} queries, {top.blocked.toLocaleString()} blocked
+            </span>
+          </div>
+          <div className="grid grid-cols-3 gap-6">
+            <TopTable title="Top Domains" rows={top.top_domains} color="bg-info/10" />
+            <TopTable title="Top Blocked" rows={top.top_blocked} color="bg-danger/10" />
+            <TopTable title="Top Clients" rows={top.top_clients} color="bg-accent/10" />
+          </div>
+        </Card>
+      )}
+
+      {/* History search */}
+      <Card className="p-4 space-y-3">
+        <div className="flex items-center gap-2 text-sm font-medium text-text-secondary">
+          <History className="w-4 h-4" /> Search History
+        </div>
+        <FieldGrid>
+          <Field label="Client" hint="IP or MAC">
+            <TextInput value={history.client || ''} onChange={v => setHistory({ ...history, client: v })} placeholder="10.0.0.100" mono />
+          </Field>
+          <Field label="Domain" hint="Exact name">
+            <TextInput value={history.domain || ''} onChange={v => setHistory({ ...history, domain: v })} placeholder="ads.example.com" mono />
+          </Field>
+        </FieldGrid>
+        <FieldGrid>
+          <Field label="From" hint="RFC3339">
+            <TextInput value={history.from || ''} onChange={v => setHistory({ ...history, from: v })} placeholder="2026-10-16T00:00:00Z" mono />
+          </Field>
+          <Field label="To" hint="RFC3339">
+            <TextInput value={history.to || ''} onChange={v => setHistory({ ...history, to: v })} placeholder="2026-10-17T00:00:00Z" mono />
+          </Field>
+        </FieldGrid>
+        <div className="flex items-center justify-between pt-1">
+          <span className="text-xs text-danger">{searchError}</span>
+          <button onClick={handleSearch} disabled={searching}
+            className="flex items-center gap-1.5 px-4 py-1.5 text-xs font-medium rounded-lg bg-accent text-white hover:bg-accent-hover disabled:opacity-50 transition-colors">
+            <Search className="w-3.5 h-3.5" /> {searching ? 'Searching...' : 'Search'}
+          </button>
+        </div>
+      </Card>
+
       {/* Filters */}
       <div className="flex items-center gap-3">
         <div className="relative flex-1 max-w-sm">
@@ -188,7 +286,9 @@ export default function DNSQueryLog() {
           <option value="cached">Cached</option>
           <option value="local">Local</option>
           <option value="blocked">Blocked</option>
+          <option value="safesearch">Safe Search</option>
           <option value="failed">Failed</option>
+          <option value="bogus">DNSSEC Bogus</option>
         </select>
         {live && (
           <div className="flex items-center gap-1.5 text-xs text-success">
@@ -236,12 +336,18 @@ export default function DNSQueryLog() {
                     ) : (
                       <span className="text-xs text-text-muted font-mono">{entry.source.split(':')[0]}</span>
                     )}
+                    {entry.policy && (
+                      <span className="text-[10px] text-accent">policy: {entry.policy}</span>
+                    )}
                   </div>
                 </TD>
                 <TD>
                   <span className={`inline-flex px-2 py-0.5 text-[10px] font-semibold uppercase tracking-wider rounded-full ${statusBg[entry.status] || ''} ${statusColors[entry.status] || 'text-text-secondary'}`}>
                     {entry.status}
                   </span>
+                  {entry.dnssec && entry.dnssec !== 'bogus' && (
+                    <span className="block text-[10px] text-text-muted">dnssec: {entry.dnssec}</span>
+                  )}
                 </TD>
                 <TD>
                   <span className="text-xs tabular-nums text-text-muted">{entry.latency_ms.toFixed(1)}ms</span>
@@ -3,7 +3,7 @@ import {
   Activity, Pause, Play, Trash2,
   Search, Send, CheckCircle2, RefreshCw, XCircle, Clock,
   ShieldAlert, ShieldCheck, ShieldX, ShieldOff,
-  ArrowRightLeft, ServerCrash, Radio, AlertTriangle,
+  ArrowRightLeft, ServerCrash, Radio, AlertTriangle, Cable, Gauge, Ban, Timer,
   type LucideIcon,
 } from 'lucide-react'
 import { useState, useRef, useEffect } from 'react'
@@ -40,6 +40,13 @@ const eventMeta: Record<string, EventMeta> = {
   'rogue.detected':     { icon: ServerCrash,   label: 'Rogue Server',       color: 'text-danger',      bg: 'bg-danger/15 text-danger',         category: 'rogue' },
   'rogue.resolved':     { icon: ShieldCheck,   label: 'Rogue Resolved',     color: 'text-success',     bg: 'bg-success/15 text-success',       category: 'rogue' },
   'anomaly.detected':   { icon: AlertTriangle, label: 'Anomaly',            color: 'text-warning',     bg: 'bg-warning/15 text-warning',       category: 'anomaly' },
+  'radius.reject':      { icon: ShieldX,       label: 'RADIUS Reject',      color: 'text-danger',      bg: 'bg-danger/15 text-danger',         category: 'radius' },
+  'topology.port_move': { icon: Cable,         label: 'Port Move',          color: 'text-info',        bg: 'bg-info/15 text-info',             category: 'topology' },
+  'pool.utilisation_high': { icon: Gauge,      label: 'Pool High',          color: 'text-warning',     bg: 'bg-warning/15 text-warning',       category: 'pool' },
+  'pool.exhausted':     { icon: Ban,           label: 'Pool Exhausted',     color: 'text-danger',      bg: 'bg-danger/15 text-danger',         category: 'pool' },
+  'dns.override_added': { icon: Timer,         label: 'DNS Override',       color: 'text-accent',      bg: 'bg-accent/15 text-accent',         category: 'dns' },
+  'dns.override_removed': { icon: XCircle,     label: 'Override Removed',   color: 'text-warning',     bg: 'bg-warning/15 text-warning',       category: 'dns' },
+  'dns.override_expired': { icon: Clock,       label: 'Override Expired',   color: 'text-text-muted',  bg: 'bg-surface-overlay text-text-muted', category: 'dns' },
 }
 
 const defaultMeta: EventMeta = {
@@ -114,6 +121,8 @@ export default function Events() {
         <FilterChip label="HA" count={counts.ha || 0} active={filter === 'ha.'} onClick={() => setFilter(filter === 'ha.' ? '' : 'ha.')} />
         <FilterChip label="Rogue" count={counts.rogue || 0} active={filter === 'rogue.'} onClick={() => setFilter(filter === 'rogue.' ? '' : 'rogue.')} />
         <FilterChip label="Anomaly" count={counts.anomaly || 0} active={filter === 'anomaly.'} onClick={() => setFilter(filter === 'anomaly.' ? '' : 'anomaly.')} />
+        <FilterChip label="Pool" count={counts.pool || 0} active={filter === 'pool.'} onClick={() => setFilter(filter === 'pool.' ? '' : 'pool.')} />
+        <FilterChip label="DNS" count={counts.dns || 0} active={filter === 'dns.'} onClick={() => setFilter(filter === 'dns.' ? '' : 'dns.')} />
 
         <div className="ml-auto">
           <div className={`flex items-center gap-1.5 px-3 py-1.5 rounded-full text-[11px] font-medium ${
@@ -273,6 +282,31 @@ function EventDetails({ event }: { event: DhcpEvent }) {
     )
   }
 
+  if (event.pool) {
+    const p = event.pool
+    return (
+      <div className="flex items-center gap-2 flex-wrap">
+        <Tag label={p.pool} variant="ip" />
+        <span className="text-[10px] text-text-muted">{p.subnet}</span>
+        <Tag label={`${p.utilisation.toFixed(1)}% (${p.allocated}/${p.size})`} variant="method" />
+        {p.overflow && <span className="text-[10px] text-text-muted">overflow → {p.overflow}</span>}
+      </div>
+    )
+  }
+
+  if (event.dns_override) {
+    const o = event.dns_override
+    return (
+      <div className="flex items-center gap-2 flex-wrap">
+        <Tag label={o.action} variant="method" />
+        {o.domain && <Tag label={o.domain} variant="host" />}
+        <span className="text-[10px] text-text-muted">for</span>
+        {o.client ? <Tag label={o.client} variant={o.client.includes(':') && o.client.length === 17 ? 'mac' : 'ip'} /> : <span className="text-[10px] text-text-muted">everyone</span>}
+        {o.by && <span className="text-[10px] text-text-muted">by {o.by}</span>}
+      </div>
+    )
+  }
+
   if (event.reason) {
     return <span className="text-xs text-text-secondary">{event.reason}</span>
   }
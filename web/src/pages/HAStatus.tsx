@@ -3,7 +3,7 @@ import { Shield, Heart, ArrowRightLeft, Server, Globe, CheckCircle, XCircle, Ale
 import { Card, StatCard } from '@/components/Card'
 import StatusBadge from '@/components/StatusBadge'
 import { usePolling } from '@/hooks/useApi'
-import { getHAStatus, triggerFailover, type VIPGroupStatus, type VIPEntryStatus } from '@/lib/api'
+import { getHAStatus, triggerFailover, type VIPGroupStatus, type VIPEntryStatus, type HAStatus as HAStatusType } from '@/lib/api'
 import { timeAgo } from '@/lib/utils'
 
 export default function HAStatus() {
@@ -15,7 +15,18 @@ export default function HAStatus() {
       await triggerFailover()
       refetch()
     } catch (e) {
-      alert(`Error: ${e instanceof Error ? e.message : 'Unknown error'}`)
+      const msg = e instanceof Error ? e.message : 'Unknown error'
+      if (!msg.startsWith('split-brain check refused')) {
+        alert(`Error: ${msg}`)
+        return
+      }
+      if (!confirm(`${msg}\n\nForce the failover anyway? Both nodes may end up serving.`)) return
+      try {
+        await triggerFailover(true)
+        refetch()
+      } catch (e2) {
+        alert(`Error: ${e2 instanceof Error ? e2.message : 'Unknown error'}`)
+      }
     }
   }
 
@@ -58,8 +69,8 @@ export default function HAStatus() {
 
       <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
         <StatCard
-          label="Role"
-          value={ha.role || '—'}
+          label={ha.node_id ? 'Node' : 'Role'}
+          value={ha.node_id || ha.role || '—'}
           icon={Shield}
           color="bg-accent/15"
         />
@@ -77,7 +88,7 @@ export default function HAStatus() {
         />
       </div>
 
-      <Card>
+      {ha.members ? <ClusterCard ha={ha} /> : <Card>
         <h2 className="text-sm font-semibold mb-4">Peer Connection</h2>
         <div className="space-y-3">
           <DetailRow label="Peer Address" value={ha.peer_address || '—'} mono />
@@ -87,14 +98,58 @@ export default function HAStatus() {
           <DetailRow label="State" value={ha.state || '—'}>
             <StatusBadge status={(ha.state || 'unknown').toLowerCase()} />
           </DetailRow>
+          {ha.updates_queued !== undefined && (
+            <DetailRow label="Lease Updates" value={`${ha.updates_queued} queued, ${ha.updates_unacked ?? 0} awaiting ack`} />
+          )}
+          {!!ha.oldest_unacked_seconds && (
+            <DetailRow label="Oldest Unacknowledged" value={`${ha.oldest_unacked_seconds.toFixed(1)}s`} />
+          )}
         </div>
-      </Card>
+      </Card>}
 
       {ha.vip && ha.vip.configured && <VIPCard vip={ha.vip} />}
     </div>
   )
 }
 
+function ClusterCard({ ha }: { ha: HAStatusType }) {
+  const reachable = ha.members?.filter(m => m.connected).length ?? 0
+
+  return (
+    <Card>
+      <div className="flex items-center justify-between mb-4">
+        <h2 className="text-sm font-semibold">Cluster Members</h2>
+        <span className="text-xs text-text-muted">
+          term {ha.term ?? 0} · leader {ha.leader || 'electing…'} · {reachable}/{ha.members?.length ?? 0} reachable (quorum {ha.quorum ?? 0})
+        </span>
+      </div>
+      <div className="space-y-2">
+        {ha.members?.map(m => (
+          <div key={m.id} className="flex items-center justify-between py-2 px-3 border border-border/50 rounded-lg">
+            <div className="flex items-center gap-3">
+              {m.connected ? (
+                <CheckCircle className="w-4 h-4 text-success shrink-0" />
+              ) : (
+                <XCircle className="w-4 h-4 text-danger shrink-0" />
+              )}
+              <span className="text-sm font-mono font-medium">{m.id}</span>
+              <span className="text-[10px] text-text-muted font-mono">{m.address}</span>
+              {m.leader && <span className="text-[10px] px-1.5 py-0.5 rounded bg-success/15 text-success">leader</span>}
+              {m.self && <span className="text-[10px] px-1.5 py-0.5 rounded bg-accent/10 text-accent">this node</span>}
+            </div>
+            {!m.self && (
+              <span className="text-[11px] text-text-muted">
+                {m.last_seen ? `seen ${timeAgo(m.last_seen)}` : 'never seen'}
+                {` · ${m.updates_queued ?? 0} queued, ${m.updates_unacked ?? 0} awaiting ack`}
+              </span>
+            )}
+          </div>
+        ))}
+      </div>
+    </Card>
+  )
+}
+
 function VIPCard({ vip }: { vip: VIPGroupStatus }) {
   const heldCount = vip.entries?.filter(e => e.held).length ?? 0
   const totalCount = vip.entries?.length ?? 0
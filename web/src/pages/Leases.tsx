@@ -89,6 +89,7 @@ export default function Leases() {
           <option value="active">Active</option>
           <option value="offered">Offered</option>
           <option value="expired">Expired</option>
+          <option value="expired-reclaimed">Held (expired-reclaimed)</option>
           <option value="declined">Declined</option>
         </select>
       </div>
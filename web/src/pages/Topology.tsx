@@ -4,7 +4,7 @@ import { Card } from '@/components/Card'
 import { GitBranch, Router, Cable, Monitor, Clock } from 'lucide-react'
 import {
   v2GetTopology, v2GetTopologyStats,
-  type TopologySwitch, type TopologyPort, type TopologyDevice,
+  type TopologySwitch, type TopologyPort, type TopologyDevice, type TopologySwitchUsage,
 } from '@/lib/api'
 
 function formatTime(ts: string) {
@@ -62,12 +62,12 @@ export default function Topology() {
           <p className="text-xs text-text-muted mt-1">Topology is learned from DHCP relay agent Option 82 data</p>
         </Card>
       )}
-      {tree && tree.map(sw => <SwitchCard key={sw.id} sw={sw} />)}
+      {tree && tree.map(sw => <SwitchCard key={sw.id} sw={sw} usage={stats?.per_switch?.find(u => u.id === sw.id)} />)}
     </div>
   )
 }
 
-function SwitchCard({ sw }: { sw: TopologySwitch }) {
+function SwitchCard({ sw, usage }: { sw: TopologySwitch; usage?: TopologySwitchUsage }) {
   const [expanded, setExpanded] = useState(true)
   const portEntries = Object.entries(sw.ports || {}).sort(([a], [b]) => a.localeCompare(b))
 
@@ -85,6 +85,7 @@ function SwitchCard({ sw }: { sw: TopologySwitch }) {
               {sw.remote_id && <span>Remote ID: <span className="font-mono">{sw.remote_id}</span></span>}
               {sw.giaddr && <span>GIAddr: <span className="font-mono">{sw.giaddr}</span></span>}
               <span>{portEntries.length} ports</span>
+              {usage && <span>{usage.active_ports} active ({Math.round(usage.utilization)}%)</span>}
             </div>
           </div>
         </div>